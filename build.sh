@@ -155,7 +155,6 @@ RUN go mod download
 COPY main.go main.go
 COPY open/ open/
 COPY pkg/ pkg/
-COPY util/ util/
 COPY tools/ tools/
 
 # Build
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// forbiddenDeps are the npm-agent dependencies that must not be linked into the openagent binary
+var forbiddenDeps = []string{
+	"npmagent/",
+	"github.com/docker/",
+	"github.com/containerd/",
+	"github.com/cakturk/go-netstat",
+	"github.com/google/gopacket",
+	"github.com/shirou/gopsutil",
+}
+
+func TestBinaryDoesNotImportNpmAgentDependencies(t *testing.T) {
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not available")
+	}
+	out, err := exec.Command(goBin, "list", "-deps", ".").Output()
+	if err != nil {
+		t.Fatalf("go list -deps: %v", err)
+	}
+	for _, pkg := range strings.Fields(string(out)) {
+		for _, prefix := range forbiddenDeps {
+			if strings.HasPrefix(pkg, prefix) {
+				t.Errorf("openagent binary imports %s", pkg)
+			}
+		}
+	}
+}
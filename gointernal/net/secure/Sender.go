@@ -1,6 +1,7 @@
 package secure
 
 import (
+	"errors"
 	"fmt"
 	//"log"
 	//"runtime/debug"
@@ -37,6 +38,21 @@ func Send(f byte, p pack.Pack, flush bool) {
 	}
 }
 
+// ErrSendQueueFull is returned by TrySend when the send queue is full and the pack was discarded
+var ErrSendQueueFull = errors.New("secure send queue is full")
+
+// TrySend queues a pack like Send, and reports when it could not be queued instead of dropping it silently
+func TrySend(f byte, p pack.Pack, flush bool) error {
+	InitSender()
+	if TcpQueue == nil {
+		return errors.New("secure send queue is not initialized")
+	}
+	if !TcpQueue.Put1(TcpSend{f, p, flush}) {
+		return ErrSendQueueFull
+	}
+	return nil
+}
+
 func SendProfile(f byte, p pack.Pack, flush bool) {
 	InitSender()
 	// DEBUG Queue
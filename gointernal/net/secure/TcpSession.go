@@ -108,7 +108,11 @@ func (this *TcpSession) open() (ret bool) {
 
 	//port := conf.WhatapPort
 	conf.Log.Debug(conf.Servers[this.dest])
-	client, err := net.DialTimeout("tcp", conf.Servers[this.dest], time.Duration(conf.TcpConnectionTimeout)*time.Millisecond)
+	dialer := net.Dialer{Timeout: time.Duration(conf.TcpConnectionTimeout) * time.Millisecond}
+	if ip := net.ParseIP(conf.SourceAddress); ip != nil {
+		dialer.LocalAddr = &net.TCPAddr{IP: ip}
+	}
+	client, err := dialer.Dial("tcp", conf.Servers[this.dest])
 	if err != nil {
 		conf.Log.Println("WA173", "Connection error. (invalid whatap.server.host key error.)", err)
 		if client != nil {
@@ -118,7 +122,14 @@ func (this *TcpSession) open() (ret bool) {
 		return false
 	}
 	secure := GetSecurityMaster()
-	secure.DecideAgentOnameOid(stringutil.Tokenizer(client.LocalAddr().String(), ":")[0])
+	identityIP := conf.IdentityIP
+	if identityIP == "" {
+		identityIP = conf.SourceAddress
+	}
+	if identityIP == "" {
+		identityIP = stringutil.Tokenizer(client.LocalAddr().String(), ":")[0]
+	}
+	secure.DecideAgentOnameOid(identityIP)
 	conf.Log.Infoln(">>>>", "oname=", secure.ONAME, ",oid=", secure.OID)
 	client.SetDeadline(time.Now().Add(time.Duration(conf.TcpSoTimeout) * time.Millisecond))
 	conf.Log.Infoln(">>>>", "Write KeyReset")
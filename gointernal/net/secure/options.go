@@ -31,6 +31,11 @@ type tcpSessionConfig struct {
 	OkindName      string
 	OnodeName      string
 
+	// SourceAddress is the local IP connections to the servers are made from, any when empty
+	SourceAddress string
+	// IdentityIP is the IP the agent identity is derived from, the connection's local IP when empty
+	IdentityIP string
+
 	TcpSoTimeout         int32
 	TcpSoSendTimeout     int32
 	TcpConnectionTimeout int32
@@ -206,3 +211,19 @@ func WithConfigObserver(obj *config.ConfigObserver) TcpSessionOption {
 		c.ConfigObserver = obj
 	})
 }
+
+// WithSourceAddress binds the connections to the servers to a local IP, which is also the identity IP
+// unless WithIdentityIP sets another
+func WithSourceAddress(ip string) TcpSessionOption {
+	return newFuncTcpSessionOption(func(c *tcpSessionConfig) {
+		c.SourceAddress = ip
+	})
+}
+
+// WithIdentityIP derives the agent's ONAME and OID from ip instead of the local IP of each connection,
+// which can change between connections on a host with several interfaces
+func WithIdentityIP(ip string) TcpSessionOption {
+	return newFuncTcpSessionOption(func(c *tcpSessionConfig) {
+		c.IdentityIP = ip
+	})
+}
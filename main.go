@@ -10,17 +10,13 @@ package main
 import (
 	"fmt"
 	"github.com/whatap/golib/logger/logfile"
-	"github.com/whatap/golib/util/dateutil"
-	"log"
-	"net/http"
-	_ "net/http/pprof"
 	"open-agent/open"
+	"open-agent/pkg/admin"
 	"open-agent/pkg/config"
+	"open-agent/pkg/instancelock"
 	"os"
 	"os/signal"
 	"runtime"
-	"runtime/pprof"
-	"strconv"
 	"syscall"
 	"time"
 )
@@ -31,37 +27,32 @@ var (
 	buildTime  string // Build timestamp
 )
 
-// startPprofServer starts the pprof HTTP server for performance profiling
+// startPprofServer starts the pprof HTTP server for performance profiling.
+// The bind address, TLS and authentication are configured through admin.LoadServerConfig.
 func startPprofServer(logger *logfile.FileLogger) {
-	// Get pprof port from environment variable, default to 6060
-	pprofPortStr := os.Getenv("PPROF_PORT")
-	if pprofPortStr == "" {
-		pprofPortStr = "6060"
-	}
-
-	pprofPort, err := strconv.Atoi(pprofPortStr)
+	cfg, err := admin.LoadServerConfig()
 	if err != nil {
-		logger.Infoln("pprof", "Invalid PPROF_PORT value, using default 6060")
-		pprofPort = 6060
+		logger.Println("admin", fmt.Sprintf("ERROR: admin/pprof server not started: %v", err))
+		return
 	}
-
-	pprofAddr := fmt.Sprintf(":%d", pprofPort)
-
-	go func() {
-		logger.Infoln("pprof", fmt.Sprintf("Starting pprof server on %s", pprofAddr))
-		logger.Infoln("pprof", "Available endpoints:")
-		logger.Infoln("pprof", fmt.Sprintf("  - CPU Profile: http://localhost%s/debug/pprof/profile", pprofAddr))
-		logger.Infoln("pprof", fmt.Sprintf("  - Heap Profile: http://localhost%s/debug/pprof/heap", pprofAddr))
-		logger.Infoln("pprof", fmt.Sprintf("  - Goroutine Profile: http://localhost%s/debug/pprof/goroutine", pprofAddr))
-		logger.Infoln("pprof", fmt.Sprintf("  - All Profiles: http://localhost%s/debug/pprof/", pprofAddr))
-
-		if err := http.ListenAndServe(pprofAddr, nil); err != nil {
-			logger.Infoln("pprof", fmt.Sprintf("Failed to start pprof server: %v", err))
-		}
-	}()
+	admin.Start(cfg, logger)
 }
 
 func run(home string, logger *logfile.FileLogger) {
+	if home == "" {
+		home = os.Getenv("WHATAP_HOME")
+		if home == "" {
+			home = "./"
+		}
+	}
+
+	// Only one agent may run in a home directory; the lock is held until the process exits
+	if _, err := instancelock.Acquire(home); err != nil {
+		logger.Println("FATAL", "Cannot start the open agent:", err)
+		fmt.Fprintln(os.Stderr, "FATAL: cannot start the open agent:", err)
+		os.Exit(1)
+	}
+
 	// Set up signal handling for graceful shutdown
 	stopper := make(chan os.Signal, 1)
 	signal.Notify(stopper, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
@@ -72,34 +63,27 @@ func run(home string, logger *logfile.FileLogger) {
 	// Enable CPU profiling
 	runtime.SetCPUProfileRate(1)
 
+	// SIGUSR1 writes a discovery/scraper state snapshot without stopping the agent
+	stateDump := make(chan os.Signal, 1)
+	signal.Notify(stateDump, syscall.SIGUSR1)
+	go func() {
+		for range stateDump {
+			path, err := open.DumpState(home)
+			if err != nil {
+				logger.Infoln("run", "Error writing state snapshot", err)
+				continue
+			}
+			logger.Infoln("run", "State snapshot written to", path)
+		}
+	}()
+
 	// Set up signal handling for crash dumps
 	dump := make(chan os.Signal, 1)
 	signal.Notify(dump, syscall.SIGSEGV, syscall.SIGABRT)
 	go func() {
 		<-dump
-		// Create stack dump
-
-		if home == "" {
-			home = os.Getenv("WHATAP_HOME")
-			if home == "" {
-				home = "./"
-			}
-		}
-
-		stackFile := fmt.Sprintf("%s/logs/stack-%s.dump", home, dateutil.YYYYMMDD(dateutil.Now()))
-		f, err := os.Create(stackFile)
-		if err != nil {
-			log.Fatal(err)
-		}
-		defer func(f *os.File) {
-			err := f.Close()
-			if err != nil {
-				logger.Infoln("run", "Error closing stack dump file", err)
-			}
-		}(f)
-
-		err = pprof.Lookup("goroutine").WriteTo(f, 1)
-		if err != nil {
+		// Create stack dump with crash diagnostics
+		if _, err := open.WriteCrashDump(home); err != nil {
 			logger.Infoln("run", "Error writing stack dump file", err)
 			return
 		}
@@ -110,8 +94,10 @@ func run(home string, logger *logfile.FileLogger) {
 	// Start the agent
 	open.BootOpenAgent(version, commitHash, buildTime, logger)
 
-	logger.Infoln("run", "Received termination signal, shutting down")
 	<-stopper
+	logger.Infoln("run", "Received termination signal, shutting down")
+	// Drains queued data within openagent_drain_deadline_seconds, keep terminationGracePeriodSeconds above it
+	open.Shutdown()
 }
 
 func exitOnStdinClose(logger *logfile.FileLogger) {
@@ -141,6 +127,22 @@ func main() {
 		"Just Tap, Always Monitoring\n")
 	fmt.Println(printWhatap)
 
+	// --strict-config exits at startup and rejects reloads when the scrape configuration has problems,
+	// --self-test checks at startup that scraped samples reach the server
+	args := os.Args[:1]
+	for _, arg := range os.Args[1:] {
+		if arg == "--strict-config" {
+			config.SetStrictConfig(true)
+			continue
+		}
+		if arg == "--self-test" {
+			config.SetSelfTest(true)
+			continue
+		}
+		args = append(args, arg)
+	}
+	os.Args = args
+
 	// Check if version is set from environment variable
 	if len(os.Args) > 1 {
 		arg1 := os.Args[1]
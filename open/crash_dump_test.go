@@ -0,0 +1,117 @@
+package open
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"open-agent/pkg/buildinfo"
+	"open-agent/pkg/diagnostics"
+	"open-agent/pkg/model"
+	"open-agent/pkg/scraper"
+	"open-agent/pkg/snapshot"
+)
+
+func TestWriteCrashDump_IncludesDiagnostics(t *testing.T) {
+	home := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(home, "logs"), 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+
+	rawQueue := make(chan *model.ScrapeRawData, 10)
+	rawQueue <- &model.ScrapeRawData{}
+	sm := scraper.NewScraperManager(nil, nil, rawQueue, "test")
+	sm.ScrapeErrors().Add("app/default/pod-a/8080", errors.New("connect timeout after 5s"))
+	sm.ScrapeErrors().Add("app/default/pod-b/8080", errors.New("connection refused"))
+	diagnostics.Beat(diagnostics.ComponentProcessor)
+
+	registerStateSources(snapshot.Sources{
+		Scraper: sm,
+		Queues:  []snapshot.Queue{{Name: "rawQueue", Len: func() int { return len(rawQueue) }, Cap: cap(rawQueue)}},
+	})
+
+	path, err := WriteCrashDump(home)
+	if err != nil {
+		t.Fatalf("WriteCrashDump: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read dump: %v", err)
+	}
+	out := string(data)
+	for _, want := range []string{
+		"goroutine profile:",
+		"## heartbeats", "processor ", "discovery never",
+		"rawQueue 1/10",
+		"active 0",
+		"## last scrape errors (2)", "app/default/pod-a/8080 connect timeout after 5s", "app/default/pod-b/8080 connection refused",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected dump to contain %q\n%s", want, out[strings.Index(out, "# diagnostics"):])
+		}
+	}
+}
+
+func TestWriteCrashDump_KeepsMostRecentDumps(t *testing.T) {
+	home := t.TempDir()
+	dir := filepath.Join(home, "logs")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	// Not a crash dump, so never pruned
+	if err := os.WriteFile(filepath.Join(dir, "whatap-20261016.log"), nil, 0644); err != nil {
+		t.Fatalf("write log: %v", err)
+	}
+	buildinfo.Set("1.2.3", "abc1234")
+
+	// A crash-looping worker: one dump per crash, several within the same second
+	start := time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC)
+	var paths []string
+	for i := 0; i < 7; i++ {
+		path, err := writeCrashDump(home, start.Add(time.Duration(i)*300*time.Millisecond), 3)
+		if err != nil {
+			t.Fatalf("writeCrashDump %d: %v", i, err)
+		}
+		paths = append(paths, path)
+	}
+	if len(paths) != len(uniqueStrings(paths)) {
+		t.Fatalf("dumps overwrote each other: %v", paths)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("read dir: %v", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	want := []string{
+		"stack-20261016-093001.200.dump",
+		"stack-20261016-093001.500.dump",
+		"stack-20261016-093001.800.dump",
+		"whatap-20261016.log",
+	}
+	if strings.Join(names, " ") != strings.Join(want, " ") {
+		t.Errorf("logs dir = %v, want %v", names, want)
+	}
+
+	data, err := os.ReadFile(paths[len(paths)-1])
+	if err != nil {
+		t.Fatalf("read dump: %v", err)
+	}
+	if header := "# openagent 1.2.3 (abc1234) crashed at 2026-10-16T09:30:01.8Z\n"; !strings.HasPrefix(string(data), header) {
+		t.Errorf("expected the dump to start with %q, got %q", header, string(data[:min(len(data), 80)]))
+	}
+}
+
+func uniqueStrings(values []string) map[string]bool {
+	unique := make(map[string]bool, len(values))
+	for _, v := range values {
+		unique[v] = true
+	}
+	return unique
+}
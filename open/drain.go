@@ -0,0 +1,117 @@
+package open
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"open-agent/pkg/config"
+	"open-agent/pkg/diagnostics"
+)
+
+const (
+	// DefaultDrainDeadline is how long shutdown waits for queued data to be processed and sent.
+	// It stays below the default terminationGracePeriodSeconds (30s) so the pod is not killed mid-send.
+	DefaultDrainDeadline = 20 * time.Second
+
+	drainPollInterval = 20 * time.Millisecond
+)
+
+// drainProgressInterval is how often drain progress is logged; shortened in tests
+var drainProgressInterval = 5 * time.Second
+
+// draining is set while shutdown drains the pipeline, and reported on the queue metrics endpoint
+var draining atomic.Bool
+
+// DrainDeadline returns openagent_drain_deadline_seconds; 0 stops without draining
+func DrainDeadline() time.Duration {
+	seconds := config.GetIntWithDefault("openagent_drain_deadline_seconds", int(DefaultDrainDeadline/time.Second))
+	if seconds < 0 {
+		return DefaultDrainDeadline
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// drainStage is one step of the shutdown drain, finished when pending reports nothing left
+type drainStage struct {
+	name    string
+	pending func() int
+	// drained runs once the stage is empty, before the next stage is waited for
+	drained func()
+}
+
+// drainPipeline waits for the stages to empty in order, logging the remaining items of every stage each
+// drainProgressInterval. It returns false when the deadline passes first.
+func drainPipeline(stages []drainStage, deadline time.Duration, logf func(message string)) bool {
+	start := time.Now()
+	expires := start.Add(deadline)
+	lastProgress := start
+
+	poll := time.NewTicker(drainPollInterval)
+	defer poll.Stop()
+	for i := 0; i < len(stages); {
+		if stages[i].pending() == 0 {
+			if stages[i].drained != nil {
+				stages[i].drained()
+			}
+			i++
+			continue
+		}
+
+		now := time.Now()
+		if !now.Before(expires) {
+			logf(fmt.Sprintf("Drain deadline %v passed, giving up with %s", deadline, drainRemaining(stages)))
+			return false
+		}
+		if now.Sub(lastProgress) >= drainProgressInterval {
+			lastProgress = now
+			logf(fmt.Sprintf("Draining for %v, %s (%v left)",
+				now.Sub(start).Round(time.Second), drainRemaining(stages), expires.Sub(now).Round(time.Second)))
+		}
+		<-poll.C
+	}
+	logf(fmt.Sprintf("Drained in %v", time.Since(start).Round(time.Millisecond)))
+	return true
+}
+
+func drainRemaining(stages []drainStage) string {
+	parts := make([]string, len(stages))
+	for i, stage := range stages {
+		parts[i] = fmt.Sprintf("%s=%d", stage.name, stage.pending())
+	}
+	return strings.Join(parts, " ")
+}
+
+// drainOnShutdown lets the processor work off rawQueue and the sender flush processedQueue and its
+// in-flight buffer, once scraping has stopped. The processor's downsample windows are flushed either way.
+func drainOnShutdown(deadline time.Duration) {
+	flushProcessor := func() {
+		if processorInstance != nil {
+			processorInstance.Stop()
+			processorInstance = nil
+		}
+	}
+	defer flushProcessor()
+	if deadline <= 0 || rawQueueInstance == nil || processedQueueInstance == nil || senderInstance == nil {
+		return
+	}
+
+	draining.Store(true)
+	defer draining.Store(false)
+	senderInstance.Flush()
+
+	logf := func(message string) { GetAppLogger().Println("Shutdown", message) }
+	logf(fmt.Sprintf("Draining queued data for up to %v", deadline))
+	drainPipeline([]drainStage{
+		{name: "rawQueue", pending: func() int {
+			pending := len(rawQueueInstance)
+			if _, _, busy := diagnostics.Busy(diagnostics.ComponentProcessor, time.Now()); busy {
+				pending++
+			}
+			return pending
+		}, drained: flushProcessor},
+		{name: "processedQueue", pending: func() int { return len(processedQueueInstance) }},
+		{name: "sender", pending: senderInstance.Pending},
+	}, deadline, logf)
+}
@@ -0,0 +1,166 @@
+package open
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"open-agent/pkg/snapshot"
+)
+
+// drainLog collects drain log messages
+type drainLog struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (l *drainLog) logf(message string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.messages = append(l.messages, message)
+}
+
+func (l *drainLog) count(prefix string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n := 0
+	for _, message := range l.messages {
+		if strings.HasPrefix(message, prefix) {
+			n++
+		}
+	}
+	return n
+}
+
+// slowConsumer takes one item from queue every interval until stop is closed
+func slowConsumer(queue chan int, interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			select {
+			case <-queue:
+			default:
+			}
+		}
+	}
+}
+
+func TestDrainPipeline_RespectsDeadline(t *testing.T) {
+	defer func(interval time.Duration) { drainProgressInterval = interval }(drainProgressInterval)
+	drainProgressInterval = 50 * time.Millisecond
+
+	queue := make(chan int, 1000)
+	for i := 0; i < cap(queue); i++ {
+		queue <- i
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	go slowConsumer(queue, 10*time.Millisecond, stop)
+
+	log := &drainLog{}
+	deadline := 300 * time.Millisecond
+	start := time.Now()
+	ok := drainPipeline([]drainStage{{name: "rawQueue", pending: func() int { return len(queue) }}}, deadline, log.logf)
+	elapsed := time.Since(start)
+
+	if ok {
+		t.Fatal("expected the drain to give up on a loaded queue")
+	}
+	if elapsed < deadline || elapsed > deadline+200*time.Millisecond {
+		t.Errorf("drain returned after %v, want about %v", elapsed, deadline)
+	}
+	if len(queue) == 0 || len(queue) == cap(queue) {
+		t.Errorf("expected the queue to be partly drained, %d left", len(queue))
+	}
+	if n := log.count("Draining for"); n < 3 {
+		t.Errorf("expected progress to be logged every interval, got %d progress lines: %v", n, log.messages)
+	}
+	if log.count("Drain deadline") != 1 {
+		t.Errorf("expected the deadline to be reported once: %v", log.messages)
+	}
+}
+
+func TestDrainPipeline_StagesInOrder(t *testing.T) {
+	rawQueue := make(chan int, 100)
+	processedQueue := make(chan int, 100)
+	for i := 0; i < 50; i++ {
+		rawQueue <- i
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	// The processor moves items to the processed queue, the sender drains that
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			case item := <-rawQueue:
+				processedQueue <- item
+			}
+		}
+	}()
+	go slowConsumer(processedQueue, time.Millisecond, stop)
+
+	var order []string
+	stages := []drainStage{
+		{name: "rawQueue", pending: func() int { return len(rawQueue) }, drained: func() {
+			order = append(order, "rawQueue")
+		}},
+		{name: "processedQueue", pending: func() int { return len(processedQueue) }, drained: func() {
+			order = append(order, "processedQueue")
+		}},
+	}
+	log := &drainLog{}
+	if !drainPipeline(stages, 5*time.Second, log.logf) {
+		t.Fatalf("expected the pipeline to drain: %v", log.messages)
+	}
+	if len(rawQueue) != 0 || len(processedQueue) != 0 {
+		t.Errorf("expected both queues empty, got %d and %d", len(rawQueue), len(processedQueue))
+	}
+	if strings.Join(order, ",") != "rawQueue,processedQueue" {
+		t.Errorf("expected the stages to finish in order, got %v", order)
+	}
+	if log.count("Drained in") != 1 {
+		t.Errorf("expected completion to be logged: %v", log.messages)
+	}
+}
+
+func TestDrainDeadline(t *testing.T) {
+	if got := DrainDeadline(); got != DefaultDrainDeadline {
+		t.Errorf("default drain deadline = %v, want %v", got, DefaultDrainDeadline)
+	}
+	t.Setenv("openagent_drain_deadline_seconds", "45")
+	if got := DrainDeadline(); got != 45*time.Second {
+		t.Errorf("drain deadline = %v, want 45s", got)
+	}
+	t.Setenv("openagent_drain_deadline_seconds", "0")
+	if got := DrainDeadline(); got != 0 {
+		t.Errorf("expected 0 to disable draining, got %v", got)
+	}
+}
+
+func TestWriteQueueMetrics(t *testing.T) {
+	var out strings.Builder
+	writeQueueMetrics(&out, []snapshot.Queue{
+		{Name: "rawQueue", Len: func() int { return 2500 }, Cap: 10000},
+		{Name: "senderBuffer", Len: func() int { return 0 }, Cap: 100},
+	}, true)
+
+	for _, want := range []string{
+		"# TYPE openagent_queue_length gauge",
+		`openagent_queue_length{queue="rawQueue"} 2500`,
+		`openagent_queue_capacity{queue="rawQueue"} 10000`,
+		`openagent_queue_utilization_ratio{queue="rawQueue"} 0.25`,
+		`openagent_queue_length{queue="senderBuffer"} 0`,
+		"openagent_draining 1",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected %q in:\n%s", want, out.String())
+		}
+	}
+}
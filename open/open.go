@@ -2,27 +2,43 @@ package open
 
 import (
 	"context"
+	cryptorand "crypto/rand"
+	"encoding/hex"
 	"fmt"
-	"math/rand"
+	"net/http"
+	"open-agent/pkg/admin"
+	"open-agent/pkg/buildinfo"
+	"open-agent/pkg/client"
 	"open-agent/pkg/config"
 	"open-agent/pkg/control"
 	"open-agent/pkg/counter"
 	"open-agent/pkg/discovery"
+	"open-agent/pkg/ingest"
 	"open-agent/pkg/k8s"
 	"open-agent/pkg/model"
+	"open-agent/pkg/netiface"
 	"open-agent/pkg/processor"
+	"open-agent/pkg/samplegen"
 	"open-agent/pkg/scraper"
+	"open-agent/pkg/selftest"
 	"open-agent/pkg/sender"
+	"open-agent/pkg/snapshot"
+	"open-agent/pkg/status"
 	"open-agent/tools/util/logutil"
 	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/whatap/gointernal/net/secure"
 	golibconfig "github.com/whatap/golib/config"
 	"github.com/whatap/golib/logger/logfile"
 	"github.com/whatap/golib/util/dateutil"
+	"gopkg.in/yaml.v2"
 )
 
 const (
@@ -42,13 +58,6 @@ var appLogger *logfile.FileLogger
 var shutdownCh = make(chan struct{})
 var doneCh = make(chan struct{}, 3) // Buffer for 3 components: scraper, processor, sender
 
-// Debug mode variables
-// Map to store the last value for each metric to calculate deltas
-var deltaMap = make(map[string]int64)
-
-// Last time help information was sent
-var lastHelpSendTime int64 = 0
-
 // SetAppLogger sets the application logger
 func SetAppLogger(logger *logfile.FileLogger) {
 	appLogger = logger
@@ -71,6 +80,7 @@ func BootOpenAgent(version, commitHash, buildTime string, logger *logfile.FileLo
 	if commitHash == "" {
 		commitHash = "unknown"
 	}
+	buildinfo.Set(version, commitHash)
 
 	logutil.Printf("START", "\nWHATAP Open Agent Starting\n")
 	logutil.Printf("START", " Version: %s\n", version)
@@ -204,6 +214,7 @@ func BootOpenAgent(version, commitHash, buildTime string, logger *logfile.FileLo
 		opts = append(opts, secure.WithObjectName(objectNamePattern))
 		logutil.Infof("CONFIG", "object_name pattern: %s", objectNamePattern)
 	}
+	opts = append(opts, sourceAddressOptions()...)
 	secure.StartNet(opts...)
 
 	// Apply initial config from whatap.conf to secure package
@@ -228,71 +239,88 @@ func BootOpenAgent(version, commitHash, buildTime string, logger *logfile.FileLo
 		logutil.Infof("CONFIG", "CounterManager disabled")
 	}
 
-	// Check if test mode is enabled
-	testMode := os.Getenv("test")
-	if testMode == "true" {
-		logutil.Infoln("BootOpenAgent", "test mode enabled, running process method")
-		// Initialize random number generator
-		rand.Seed(time.Now().UnixNano())
-		// Run the process method in a loop
-		for {
-			process(logger)
-			time.Sleep(10 * time.Second)
-		}
-		// The code below will not be executed in test mode
+	// Pipeline-only mode runs the processor and sender without discovery, fed through the ingest endpoint.
+	// Test mode is pipeline only, with the sample generator as the ingest client.
+	testMode := os.Getenv("test") == "true"
+	pipelineOnly := testMode || config.GetBoolWithDefault("openagent_pipeline_only", false)
+	if testMode {
+		logutil.Infoln("BootOpenAgent", "test mode enabled, posting sample metrics to the ingest endpoint")
 	}
 
-	logutil.Infoln("BootOpenAgent, test-mode disabled")
 	// Create channels for communication between components
 	rawQueue := make(chan *model.ScrapeRawData, RawQueueSize)
 	processedQueue := make(chan *model.ConversionResult, ProcessedQueueSize)
-
-	// Create the configuration manager
-	configManager := config.NewConfigManager()
-	// Check if configManager is nil (which happens if the configuration file is missing)
-	if configManager == nil {
-		logutil.Infoln("BootOpenAgent", "Failed to create configuration manager. Please ensure scrape_config.yaml exists.")
-		return
+	rawQueueInstance = rawQueue
+	processedQueueInstance = processedQueue
+	// The pipeline queues, watched by the overload breaker and reported by the snapshot, status and queue metrics
+	pipelineQueues := []snapshot.Queue{
+		{Name: "rawQueue", Len: func() int { return len(rawQueue) }, Cap: cap(rawQueue)},
+		{Name: "processedQueue", Len: func() int { return len(processedQueue) }, Cap: cap(processedQueue)},
+	}
+
+	var configManager *config.ConfigManager
+	var serviceDiscovery *discovery.ServiceDiscoveryImpl
+	var selfTest *selftest.SelfTest
+	if !pipelineOnly {
+		configManager, serviceDiscovery, selfTest = startDiscovery()
+		// configManager is nil when the configuration file is missing
+		if configManager == nil {
+			return
+		}
+	} else {
+		// The scrape configuration is optional here, it only provides the relabel rules of pushed targets
+		configManager = config.NewConfigManager()
 	}
 
-	// Create service discovery
-	serviceDiscovery := discovery.NewServiceDiscovery(configManager)
-	// Start service discovery as an independent component
-	go func() {
-		defer func() {
-			if r := recover(); r != nil {
-				logutil.Errorln("ServiceDiscoveryPanic", fmt.Sprintf("Recovered from panic: %v", r))
-			}
-		}()
-
-		// Load targets from configuration
-		scrapeConfigs := configManager.GetScrapeConfigs()
-		if scrapeConfigs != nil {
-			if err := serviceDiscovery.LoadTargets(scrapeConfigs); err != nil {
-				logutil.Println("ServiceDiscovery", fmt.Sprintf("Failed to load targets: %v", err))
-				return
-			}
-
-			// Start service discovery
-			if err := serviceDiscovery.Start(context.Background()); err != nil {
-				logutil.Infoln("ServiceDiscovery", fmt.Sprintf("Failed to start service discovery: %v", err))
-				return
-			}
-
-			logutil.Infoln("ServiceDiscovery", "Service discovery started successfully")
+	// Create and start the scraper manager with error recovery and shutdown handling
+	userAgent := client.BuildUserAgent(version, commitHash)
+	var scraperManager *scraper.ScraperManager
+	if pipelineOnly {
+		scraperManager = scraper.NewScraperManager(nil, nil, rawQueue, userAgent)
+	} else {
+		scraperManager = scraper.NewScraperManager(configManager, serviceDiscovery, rawQueue, userAgent)
+	}
+	scraperInstance = scraperManager
+	// Per-target scrape byte counters are agent self-metrics and bypass the processor
+	scraperManager.SetSelfMetricsQueue(processedQueue)
+	// Shed scrapes while results cannot be processed or sent
+	scraperManager.SetOverloadQueues(pipelineQueues...)
+	registerPauseEndpoint(scraperManager)
+
+	if pipelineOnly {
+		ingestConfig := ingest.LoadConfig()
+		if testMode && ingestConfig.Token == "" {
+			ingestConfig.Token = randomToken()
+		}
+		var relabelRules ingest.RelabelRules
+		if configManager != nil {
+			relabelRules = ingest.TargetRelabelRules(configManager)
 		} else {
-			logutil.Infoln("ServiceDiscovery", "No scrape configs found, service discovery not started")
+			logutil.Infoln("BootOpenAgent", "No scrape configuration, pushed metrics are not relabeled")
 		}
-	}()
+		ingest.Start(ingestConfig, scraperManager.AddRawData, relabelRules, logger)
+		if testMode {
+			go runSampleClient(ingestConfig, logger)
+		}
+	}
 
-	// Create and start the scraper manager with error recovery and shutdown handling
-	scraperManager := scraper.NewScraperManager(configManager, serviceDiscovery, rawQueue)
+	// openagent_build_info is a self-metric too, sent once per metadata interval
+	go buildinfo.Run(processedQueue, sender.MetadataInterval, shutdownCh)
+
+	stateSources := snapshot.Sources{
+		Scraper: scraperManager,
+		Queues:  pipelineQueues,
+	}
+	if serviceDiscovery != nil {
+		stateSources.Discovery = serviceDiscovery
+	}
+	registerStateSources(stateSources)
 
 	// Configuration changes will be automatically reflected in the next scraping cycle
 	logger.Infoln("BootOpenAgent", "ScraperManager will automatically use latest configuration")
 
 	// ConfigManager automatically handles ConfigMap synchronization
-	if !config.IsForceStandaloneMode() {
+	if !pipelineOnly && !config.IsForceStandaloneMode() {
 		k8sClient := k8s.GetInstance()
 		if k8sClient.IsInitialized() {
 			logger.Infoln("BootOpenAgent", "Kubernetes environment detected - ConfigManager handles ConfigMap synchronization")
@@ -339,7 +367,21 @@ func BootOpenAgent(version, commitHash, buildTime string, logger *logfile.FileLo
 	}()
 
 	// Create and start the newProcessor with error recovery and shutdown handling
-	newProcessor := processor.NewProcessor(rawQueue, processedQueue)
+	var processorOptions []processor.Option
+	if path := config.Get(processor.StaticLabelsFileKey); path != "" {
+		if hook, err := processor.LoadStaticLabelHook(path); err != nil {
+			logger.Println("Processor", fmt.Sprintf("Static labels disabled: %v", err))
+		} else {
+			processorOptions = append(processorOptions, processor.WithSampleHooks(hook))
+			logger.Println("Processor", fmt.Sprintf("Adding static labels from %s", path))
+		}
+	}
+	if !pipelineOnly {
+		// Drop the processor's per-target stats once the scraper stops scraping a target
+		processorOptions = append(processorOptions, processor.WithLiveTargets(scraperManager.HasTarget))
+	}
+	newProcessor := processor.NewProcessor(rawQueue, processedQueue, processorOptions...)
+	processorInstance = newProcessor
 	go func() {
 		defer func() {
 			if r := recover(); r != nil {
@@ -379,6 +421,31 @@ func BootOpenAgent(version, commitHash, buildTime string, logger *logfile.FileLo
 
 	// Create and start the sender with error recovery and shutdown handling
 	senderInstance = sender.NewSender(processedQueue, GetAppLogger(), endpointMeteringEnabled)
+	if selfTest != nil {
+		senderInstance.SetSendCallback(selfTest.Confirm)
+		go selfTest.Wait(shutdownCh)
+	}
+	queues := append(pipelineQueues[:len(pipelineQueues):len(pipelineQueues)],
+		snapshot.Queue{Name: "senderBuffer", Len: senderInstance.Pending, Cap: sender.InFlightBufferSize})
+	// Queue lengths for a HorizontalPodAutoscaler scaling on custom metrics
+	registerQueueMetricsEndpoint(queues)
+	// One status pack per minute for the open agent status dashboard
+	if config.GetBoolWithDefault("openagent_status_enabled", true) {
+		statusSources := status.Sources{
+			Scraper:   scraperManager,
+			Processor: newProcessor,
+			Sender:    senderInstance,
+			Latency:   senderInstance,
+			Queues:    queues,
+		}
+		// Pipeline-only agents have neither targets nor a scrape configuration
+		if !pipelineOnly {
+			statusSources.Targets = serviceDiscovery
+			statusSources.Config = configManager
+		}
+		statusReporter := status.NewStatusReporter(statusSources)
+		go statusReporter.Run(shutdownCh)
+	}
 	go func() {
 		defer func() {
 			if r := recover(); r != nil {
@@ -424,6 +491,61 @@ func BootOpenAgent(version, commitHash, buildTime string, logger *logfile.FileLo
 	logger.Infoln("BootOpenAgent", "OpenAgent started successfully")
 }
 
+// startDiscovery creates the configuration manager and service discovery and starts discovering in the
+// background. The configuration manager is nil when scrape_config.yaml is missing.
+func startDiscovery() (*config.ConfigManager, *discovery.ServiceDiscoveryImpl, *selftest.SelfTest) {
+	// Create the configuration manager
+	configManager := config.NewConfigManager()
+	// Check if configManager is nil (which happens if the configuration file is missing)
+	if configManager == nil {
+		logutil.Infoln("BootOpenAgent", "Failed to create configuration manager. Please ensure scrape_config.yaml exists.")
+		return nil, nil, nil
+	}
+	registerConfigEndpoint(configManager)
+
+	// Create service discovery
+	serviceDiscovery := discovery.NewServiceDiscovery(configManager)
+	discoveryInstance = serviceDiscovery
+
+	// The self-test target is added before the first discovery cycle, its result waits for the sender
+	var selfTest *selftest.SelfTest
+	if configManager.SelfTestEnabled() {
+		selfTest = selftest.New(configManager, serviceDiscovery, configManager.SelfTestDuration())
+		if err := selfTest.Start(); err != nil {
+			selfTest = nil
+		}
+	}
+	// Start service discovery as an independent component
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				logutil.Errorln("ServiceDiscoveryPanic", fmt.Sprintf("Recovered from panic: %v", r))
+			}
+		}()
+
+		// Load targets from configuration
+		targetConfigs := configManager.GetTargetConfigs()
+		if targetConfigs != nil {
+			if err := serviceDiscovery.LoadTargets(targetConfigs); err != nil {
+				logutil.Println("ServiceDiscovery", fmt.Sprintf("Failed to load targets: %v", err))
+				return
+			}
+
+			// Start service discovery
+			if err := serviceDiscovery.Start(context.Background()); err != nil {
+				logutil.Infoln("ServiceDiscovery", fmt.Sprintf("Failed to start service discovery: %v", err))
+				return
+			}
+
+			logutil.Infoln("ServiceDiscovery", "Service discovery started successfully")
+		} else {
+			logutil.Infoln("ServiceDiscovery", "No scrape configs found, service discovery not started")
+		}
+	}()
+
+	return configManager, serviceDiscovery, selfTest
+}
+
 // IsOK checks if the agent is running properly
 func IsOK() bool {
 	// If health check is not ready yet, check if it's time to enable it
@@ -455,6 +577,11 @@ func IsOK() bool {
 
 // Global variables to store component references for shutdown
 var senderInstance *sender.Sender
+var processorInstance *processor.Processor
+var scraperInstance *scraper.ScraperManager
+var discoveryInstance *discovery.ServiceDiscoveryImpl
+var rawQueueInstance chan *model.ScrapeRawData
+var processedQueueInstance chan *model.ConversionResult
 
 // Shutdown gracefully shuts down all components
 func Shutdown() {
@@ -465,6 +592,17 @@ func Shutdown() {
 
 	GetAppLogger().Println("Shutdown", "Initiating graceful shutdown")
 
+	// Stop discovering and scraping right away, nothing new enters the pipeline
+	if discoveryInstance != nil {
+		discoveryInstance.Stop()
+	}
+	if scraperInstance != nil {
+		scraperInstance.Stop()
+	}
+
+	// Process and send what was already scraped, and flush downsample windows, before the sender stops
+	drainOnShutdown(DrainDeadline())
+
 	// Stop the sender if it exists
 	if senderInstance != nil {
 		GetAppLogger().Println("Shutdown", "Stopping sender")
@@ -503,7 +641,10 @@ func Shutdown() {
 	GetAppLogger().Println("Shutdown", "All components shut down successfully")
 }
 
-// Debug mode functions
+// Test mode functions
+
+// sampleInterval is how often test mode posts the sample metrics
+const sampleInterval = 10 * time.Second
 
 // promaxLogMessage logs a message to both the file logger and stdout
 func promaxLogMessage(logger *logfile.FileLogger, tag string, message string) {
@@ -513,296 +654,55 @@ func promaxLogMessage(logger *logfile.FileLogger, tag string, message string) {
 	}
 }
 
-// process creates and sends metrics and help information
-func process(logger *logfile.FileLogger) {
-	// Create metrics
-	metrics := createMetrics()
-	promaxLogMessage(logger, "PromaX", fmt.Sprintf("Created %d metrics", len(metrics)))
-
-	// Create help information if needed
-	helpItems := make([]*model.OpenMxHelp, 0)
-	now := time.Now().UnixMilli()
-
-	// Send help information only once per minute
-	if now-lastHelpSendTime > 60*dateutil.MILLIS_PER_SECOND {
-		for _, mx := range metrics {
-			helpText := model.GetMetricHelp(mx.Metric)
-			metricType := model.GetMetricType(mx.Metric)
-
-			// Use default values if not found
-			if helpText == "" {
-				helpText = fmt.Sprintf("Help information for %s", mx.Metric)
-			}
-			if metricType == "" {
-				metricType = "gauge"
-			}
-
-			mxh := model.NewOpenMxHelp(mx.Metric)
-			mxh.Put("help", helpText)
-			mxh.Put("type", metricType)
-			helpItems = append(helpItems, mxh)
+// runSampleClient posts the sample metrics to the ingest endpoint until shutdown, like any other ingest client
+func runSampleClient(cfg ingest.Config, logger *logfile.FileLogger) {
+	url := "http://" + cfg.Addr() + ingest.Path
+	httpClient := &http.Client{Timeout: sampleInterval}
+	generator := samplegen.NewGenerator()
+	ticker := time.NewTicker(sampleInterval)
+	defer ticker.Stop()
+	for {
+		metrics := generator.Metrics(time.Now().UnixMilli())
+		if err := samplegen.Post(httpClient, url, cfg.Token, "promax", nil, samplegen.Exposition(metrics)); err != nil {
+			promaxLogMessage(logger, "PromaX", fmt.Sprintf("Failed to post %d metrics: %v", len(metrics), err))
+		} else {
+			promaxLogMessage(logger, "PromaX", fmt.Sprintf("Posted %d metrics", len(metrics)))
 		}
-		lastHelpSendTime = now
-	}
-
-	// Send help information if available
-	if len(helpItems) > 0 {
-		helpPack := model.NewOpenMxHelpPack()
-		securityMaster := secure.GetSecurityMaster()
-		if securityMaster == nil {
-			promaxLogMessage(logger, "PromaX", "No security master available")
+		select {
+		case <-shutdownCh:
 			return
+		case <-ticker.C:
 		}
-		// Set PCODE and OID
-		helpPack.SetPCODE(securityMaster.PCODE)
-		helpPack.SetOID(securityMaster.OID)
-		helpPack.SetTime(now)
-		helpPack.SetRecords(helpItems)
-
-		// Get the security master
-		promaxLogMessage(logger, "PromaX", fmt.Sprintf("Sending %d help records", len(helpItems)))
-		secure.Send(secure.NET_SECURE_HIDE, helpPack, true)
-		time.Sleep(1000 * time.Millisecond)
-	}
-
-	//Send metrics
-	metricsPack := model.NewOpenMxPack()
-	metricsPack.SetTime(now)
-	metricsPack.SetRecords(metrics)
-
-	// Get the security master
-	securityMaster := secure.GetSecurityMaster()
-	if securityMaster == nil {
-		promaxLogMessage(logger, "PromaX", "No security master available")
-		return
-	}
-
-	//Set PCODE and OID
-	metricsPack.SetPCODE(securityMaster.PCODE)
-	metricsPack.SetOID(securityMaster.OID)
-
-	promaxLogMessage(logger, "PromaX", fmt.Sprintf("Sending %d metrics", len(metrics)))
-	secure.Send(secure.NET_SECURE_HIDE, metricsPack, true)
-}
-
-// createMetrics creates sample metrics data
-func createMetrics() []*model.OpenMx {
-	metrics := make([]*model.OpenMx, 0, 100)
-
-	// Add metrics with no labels
-	promaxAddNoLabelMetrics(&metrics)
-
-	// Add metrics with one label
-	promaxAddOneLabelsMetrics(&metrics)
-
-	// Add metrics with two labels
-	promaxAddTwoLabelsMetrics(&metrics)
-
-	return metrics
-}
-
-// addDelta adds a random delta to the value for a metric
-func addDelta(metricName string, value float64) float64 {
-	// Generate a random delta between 0 and 99
-	delta := rand.Int63n(100)
-
-	// Add the delta to the stored value for this metric
-	if _, ok := deltaMap[metricName]; !ok {
-		deltaMap[metricName] = 0
 	}
-	deltaMap[metricName] += delta
-
-	// Return the value plus the accumulated delta
-	return value + float64(deltaMap[metricName])
 }
 
-// promaxAddNoLabelMetrics adds metrics with no labels
-func promaxAddNoLabelMetrics(metrics *[]*model.OpenMx) {
-	noLabelData := []struct {
-		name  string
-		value float64
-	}{
-		{"http_requests_total", 1523},
-		{"http_requests_duration_seconds", 0.234},
-		{"http_requests_in_progress", 37},
-		{"http_requests_failed_total", 145},
-		{"http_requests_success_total", 1378},
-		{"cpu_usage_seconds_total", 78456},
-		{"cpu_load_average_1m", 2.5},
-		{"cpu_load_average_5m", 1.8},
-		{"cpu_load_average_15m", 1.2},
-		{"cpu_temperature_celsius", 55.3},
-		{"memory_usage_bytes", 104857600},
-		{"memory_free_bytes", 524288000},
-		{"memory_available_bytes", 314572800},
-		{"memory_swap_used_bytes", 20971520},
-		{"memory_page_faults_total", 845321},
-		{"disk_read_bytes_total", 5832145},
-		{"disk_write_bytes_total", 4123654},
-		{"disk_reads_completed_total", 14578},
-		{"disk_writes_completed_total", 13854},
-		{"network_transmit_bytes_total", 248930124},
-		{"network_receive_bytes_total", 175435678},
-		{"network_transmit_packets_total", 78932},
-		{"network_receive_packets_total", 65421},
-		{"process_cpu_seconds_total", 9854},
-		{"process_memory_usage_bytes", 786432000},
-		{"process_open_fds", 231},
-		{"process_max_fds", 1024},
-		{"process_threads_total", 34},
-		{"database_queries_total", 56412},
-		{"database_queries_duration_seconds", 0.056},
-		{"database_queries_failed_total", 452},
-		{"database_rows_read_total", 35621},
-		{"database_rows_written_total", 19876},
-		{"kafka_messages_in_total", 152000},
-		{"kafka_messages_out_total", 145789},
-		{"kafka_producer_records_total", 58746},
-		{"kafka_consumer_lag_seconds", 3.2},
-		{"redis_commands_processed_total", 12345678},
-		{"redis_connections_active", 487},
-		{"redis_memory_used_bytes", 167772160},
-		{"redis_evicted_keys_total", 287},
-		{"redis_hit_ratio", 0.89},
-		{"redis_misses_total", 4521},
-		{"jvm_memory_used_bytes", 786432000},
-		{"jvm_memory_max_bytes", 2147483648},
-		{"jvm_gc_collection_seconds_total", 120.3},
-		{"jvm_threads_live", 145},
-		{"jvm_threads_peak", 189},
-		{"jvm_classes_loaded", 45210},
-		{"jvm_classes_unloaded_total", 3241},
-		{"jvm_uptime_seconds", 172800},
-		{"http_request_size_bytes", 1783},
-		{"http_response_size_bytes", 3456},
-	}
-
-	for _, data := range noLabelData {
-		// Add a random delta to the value
-		value := addDelta(data.name, data.value)
-
-		// Create the metric with the current timestamp
-		metric := model.NewOpenMxWithCurrentTime(data.name, value)
-		*metrics = append(*metrics, metric)
+// randomToken returns the ingest token of a test mode agent without openagent_ingest_token
+func randomToken() string {
+	b := make([]byte, 16)
+	if _, err := cryptorand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
 	}
+	return hex.EncodeToString(b)
 }
 
-// promaxAddOneLabelsMetrics adds metrics with one label
-func promaxAddOneLabelsMetrics(metrics *[]*model.OpenMx) {
-	oneLabelData := []struct {
-		name   string
-		labels []string
-		value  float64
-	}{
-		{"apiserver_request_duration_seconds_count", []string{"target=kube-apiserver"}, 2999},
-		{"http_requests_total", []string{"method=GET"}, 1023},
-		{"http_requests_total", []string{"method=POST"}, 234},
-		{"http_requests_failed_total", []string{"method=DELETE"}, 54},
-		{"cpu_usage_seconds_total", []string{"core=0"}, 43212},
-		{"cpu_load_average_1m", []string{"region=us-east"}, 3.4},
-		{"memory_usage_bytes", []string{"host=server1"}, 509715200},
-		{"memory_free_bytes", []string{"host=server2"}, 612345678},
-		{"disk_read_bytes_total", []string{"device=sda"}, 3214587},
-		{"disk_write_bytes_total", []string{"device=sdb"}, 8976543},
-		{"network_transmit_bytes_total", []string{"interface=eth0"}, 112000000},
-		{"network_receive_bytes_total", []string{"interface=eth1"}, 65432100},
-		{"process_cpu_seconds_total", []string{"pid=1245"}, 5321},
-		{"process_memory_usage_bytes", []string{"app=nginx"}, 298765432},
-		{"database_queries_total", []string{"db=production"}, 21034},
-		{"database_queries_total", []string{"db=staging"}, 8754},
-		{"kafka_messages_in_total", []string{"topic=events"}, 72000},
-		{"kafka_messages_out_total", []string{"topic=logs"}, 61500},
-		{"redis_commands_processed_total", []string{"instance=cache1"}, 653214},
-		{"redis_memory_used_bytes", []string{"instance=cache2"}, 198765432},
-		{"jvm_memory_used_bytes", []string{"area=heap"}, 456123987},
-		{"jvm_memory_max_bytes", []string{"area=non-heap"}, 987654321},
-		{"jvm_gc_collection_seconds_total", []string{"collector=G1GC"}, 145.7},
-		{"jvm_threads_live", []string{"type=daemon"}, 98},
-		{"jvm_classes_loaded", []string{"app=myApp"}, 32145},
-		{"jvm_uptime_seconds", []string{"host=server3"}, 275400},
-		{"http_request_size_bytes", []string{"api=/login"}, 2093},
-		{"http_response_size_bytes", []string{"api=/user/info"}, 4872},
-		{"disk_inodes_total", []string{"filesystem=ext4"}, 3456789},
-		{"disk_inodes_free", []string{"filesystem=xfs"}, 2765432},
-	}
-
-	for _, data := range oneLabelData {
-		// Create a unique key for the metric with its labels
-		key := data.name
-		for _, label := range data.labels {
-			key += "_" + label
-		}
-
-		// Add a random delta to the value
-		value := addDelta(key, data.value)
-
-		// Create the metric with the current timestamp
-		metric := model.NewOpenMxWithCurrentTime(data.name, value)
-
-		// Add labels
-		for _, labelStr := range data.labels {
-			parts := promaxSplitLabel(labelStr)
-			if len(parts) == 2 {
-				metric.AddLabel(parts[0], parts[1])
-			}
-		}
-
-		*metrics = append(*metrics, metric)
+// sourceAddressOptions selects the local IP of the agent identity and logs it. A configured address is
+// also the source of the connections to the servers; a configured address that cannot be used is logged
+// and replaced by the default selection.
+func sourceAddressOptions() []secure.TcpSessionOption {
+	selection, err := netiface.Detect(netiface.LoadConfig())
+	if err != nil {
+		logutil.Errorf("CONFIG", "Ignoring the configured source address: %v", err)
+		selection, err = netiface.Detect(netiface.Config{})
 	}
-}
-
-// promaxAddTwoLabelsMetrics adds metrics with two labels
-func promaxAddTwoLabelsMetrics(metrics *[]*model.OpenMx) {
-	twoLabelData := []struct {
-		name   string
-		labels []string
-		value  float64
-	}{
-		{"apiserver_request_duration_seconds_count", []string{"target=kube-apiserver", "instance=192.168.0.105"}, 3333},
-		{"http_requests_total", []string{"method=GET", "status=200"}, 982},
-		{"http_requests_total", []string{"method=POST", "status=500"}, 45},
-		{"cpu_usage_seconds_total", []string{"core=1", "node=node1"}, 65478},
-		{"memory_usage_bytes", []string{"host=server2", "region=us-east"}, 312457600},
-		{"disk_read_bytes_total", []string{"device=sdb", "mount=/data"}, 9876543},
-		{"network_transmit_bytes_total", []string{"interface=eth1", "speed=1Gbps"}, 187654321},
-		{"process_cpu_seconds_total", []string{"pid=2378", "app=nginx"}, 7421},
-		{"jvm_memory_used_bytes", []string{"area=non-heap", "pool=Metaspace"}, 298765432},
-		{"database_queries_total", []string{"db=staging", "type=select"}, 19283},
-		{"kafka_messages_in_total", []string{"topic=logs", "partition=3"}, 15423},
-	}
-
-	for _, data := range twoLabelData {
-		// Create a unique key for the metric with its labels
-		key := data.name
-		for _, label := range data.labels {
-			key += "_" + label
-		}
-
-		// Add a random delta to the value
-		value := addDelta(key, data.value)
-
-		// Create the metric with the current timestamp
-		metric := model.NewOpenMxWithCurrentTime(data.name, value)
-
-		// Add labels
-		for _, labelStr := range data.labels {
-			parts := promaxSplitLabel(labelStr)
-			if len(parts) == 2 {
-				metric.AddLabel(parts[0], parts[1])
-			}
-		}
-
-		*metrics = append(*metrics, metric)
+	if err != nil || selection.IP == "" {
+		logutil.Infof("CONFIG", "No source address selected, the agent identity follows the local address of each connection")
+		return nil
 	}
-}
-
-// Helper function to split a label string in the format "key=value" into key and value
-func promaxSplitLabel(label string) []string {
-	idx := strings.Index(label, "=")
-	if idx == -1 {
-		return []string{}
+	logutil.Infof("CONFIG", "Source address: %s (interface %s, %s, bound=%v)", selection.IP, selection.Interface, selection.Reason, selection.Pinned)
+	if selection.Pinned {
+		return []secure.TcpSessionOption{secure.WithSourceAddress(selection.IP)}
 	}
-	return []string{label[:idx], label[idx+1:]}
+	return []secure.TcpSessionOption{secure.WithIdentityIP(selection.IP)}
 }
 
 // getFirstNonEmpty returns the first non-empty string from the given values
@@ -837,3 +737,171 @@ func getEnvInt(key string, def int) int {
 	}
 	return n
 }
+
+var configEndpointOnce sync.Once
+
+// registerConfigEndpoint exposes the loaded scrape configuration on the admin server.
+// Interpolated credentials are redacted.
+func registerConfigEndpoint(configManager *config.ConfigManager) {
+	configEndpointOnce.Do(func() {
+		admin.HandleFunc("/config", func(w http.ResponseWriter, r *http.Request) {
+			data, err := yaml.Marshal(configManager.GetRedactedConfig())
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/yaml")
+			_, _ = w.Write(data)
+		})
+	})
+}
+
+var (
+	pauseScraper   *scraper.ScraperManager
+	pauseScraperMu sync.RWMutex
+)
+
+// registerPauseEndpoint exposes POST /targets/{id}/pause and /resume and the /targets/{id}/debug
+// sample capture on the admin server
+func registerPauseEndpoint(sm *scraper.ScraperManager) {
+	pauseScraperMu.Lock()
+	first := pauseScraper == nil
+	pauseScraper = sm
+	pauseScraperMu.Unlock()
+
+	if first {
+		admin.HandleFunc("/targets/", func(w http.ResponseWriter, r *http.Request) {
+			pauseScraperMu.RLock()
+			sm := pauseScraper
+			pauseScraperMu.RUnlock()
+			sm.TargetsHandler().ServeHTTP(w, r)
+		})
+	}
+}
+
+var (
+	stateSources   *snapshot.Sources
+	stateSourcesMu sync.RWMutex
+)
+
+// registerStateSources records the components included in state snapshots and
+// exposes the snapshot on the admin server
+func registerStateSources(src snapshot.Sources) {
+	stateSourcesMu.Lock()
+	first := stateSources == nil
+	stateSources = &src
+	stateSourcesMu.Unlock()
+
+	if first {
+		admin.HandleFunc("/targets", func(w http.ResponseWriter, r *http.Request) {
+			snap, err := CollectState()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusServiceUnavailable)
+				return
+			}
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			_ = snap.Write(w)
+		})
+	}
+}
+
+// CollectState returns a snapshot of discovery, scraper and runtime state
+func CollectState() (*snapshot.Snapshot, error) {
+	stateSourcesMu.RLock()
+	defer stateSourcesMu.RUnlock()
+	if stateSources == nil {
+		return nil, fmt.Errorf("agent components are not started yet")
+	}
+	return snapshot.Collect(*stateSources), nil
+}
+
+// DefaultMaxStackDumps is the number of crash dumps kept when openagent_max_stack_dumps is not set
+const DefaultMaxStackDumps = 10
+
+// stackDumpTimeFormat names dumps by the crash time to the millisecond, so a crash-looping worker
+// writes a new file per crash instead of overwriting the day's dump
+const stackDumpTimeFormat = "20060102-150405.000"
+
+// WriteCrashDump writes the agent version, goroutine stacks and crash diagnostics to
+// home/logs/stack-YYYYMMDD-HHMMSS.mmm.dump and removes all but the openagent_max_stack_dumps most recent
+// dumps. Called when the supervisor aborts a hung worker, so it avoids waiting on component locks.
+func WriteCrashDump(home string) (string, error) {
+	return writeCrashDump(home, time.Now(), config.GetIntWithDefault("openagent_max_stack_dumps", DefaultMaxStackDumps))
+}
+
+func writeCrashDump(home string, now time.Time, keep int) (string, error) {
+	dir := fmt.Sprintf("%s/logs", home)
+	path := fmt.Sprintf("%s/%s%s%s", dir, logutil.StackDumpPrefix, now.Format(stackDumpTimeFormat), logutil.StackDumpSuffix)
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	fmt.Fprintf(f, "# openagent %s (%s) crashed at %s\n\n", buildinfo.Version(), buildinfo.Commit(), now.Format(time.RFC3339Nano))
+	if err := pprof.Lookup("goroutine").WriteTo(f, 1); err != nil {
+		return path, err
+	}
+
+	var src snapshot.Sources
+	if stateSourcesMu.TryRLock() {
+		if stateSources != nil {
+			src = *stateSources
+		}
+		stateSourcesMu.RUnlock()
+	}
+	snapshot.WriteCrashDiagnostics(f, src, now)
+
+	// Removing old dumps must not lose the new one, so it runs after the dump is written
+	pruneStackDumps(dir, keep)
+	return path, nil
+}
+
+// pruneStackDumps removes all but the keep most recent stack dumps in dir; keep <= 0 keeps every dump
+func pruneStackDumps(dir string, keep int) {
+	if keep <= 0 {
+		return
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	type dump struct {
+		name    string
+		modTime time.Time
+	}
+	var dumps []dump
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, logutil.StackDumpPrefix) || !strings.HasSuffix(name, logutil.StackDumpSuffix) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		dumps = append(dumps, dump{name: name, modTime: info.ModTime()})
+	}
+	if len(dumps) <= keep {
+		return
+	}
+	// Newest first; names sort by crash time when dumps are written within the same mtime tick
+	sort.Slice(dumps, func(i, j int) bool {
+		if !dumps[i].modTime.Equal(dumps[j].modTime) {
+			return dumps[i].modTime.After(dumps[j].modTime)
+		}
+		return dumps[i].name > dumps[j].name
+	})
+	for _, d := range dumps[keep:] {
+		_ = os.Remove(filepath.Join(dir, d.name))
+	}
+}
+
+// DumpState writes a state snapshot under home/logs and returns the file path
+func DumpState(home string) (string, error) {
+	snap, err := CollectState()
+	if err != nil {
+		return "", err
+	}
+	return snap.WriteFile(fmt.Sprintf("%s/logs", home))
+}
@@ -0,0 +1,70 @@
+package open
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"open-agent/pkg/admin"
+	"open-agent/pkg/snapshot"
+)
+
+var (
+	metricsQueues   []snapshot.Queue
+	metricsQueuesMu sync.RWMutex
+)
+
+// registerQueueMetricsEndpoint exposes the pipeline queue lengths on the admin server's /metrics in the
+// Prometheus text format, so a HorizontalPodAutoscaler can scale on them through a custom metrics adapter
+func registerQueueMetricsEndpoint(queues []snapshot.Queue) {
+	metricsQueuesMu.Lock()
+	first := metricsQueues == nil
+	metricsQueues = queues
+	metricsQueuesMu.Unlock()
+
+	if first {
+		admin.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+			metricsQueuesMu.RLock()
+			queues := metricsQueues
+			metricsQueuesMu.RUnlock()
+			w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+			writeQueueMetrics(w, queues, draining.Load())
+		})
+	}
+}
+
+// writeQueueMetrics writes the length, capacity and utilization of each queue and whether the agent is draining
+func writeQueueMetrics(w io.Writer, queues []snapshot.Queue, isDraining bool) {
+	lengths := make([]int, len(queues))
+	for i, q := range queues {
+		lengths[i] = q.Len()
+	}
+
+	fmt.Fprintln(w, "# HELP openagent_queue_length Items waiting in an agent pipeline queue.")
+	fmt.Fprintln(w, "# TYPE openagent_queue_length gauge")
+	for i, q := range queues {
+		fmt.Fprintf(w, "openagent_queue_length{queue=%q} %d\n", q.Name, lengths[i])
+	}
+	fmt.Fprintln(w, "# HELP openagent_queue_capacity Capacity of an agent pipeline queue.")
+	fmt.Fprintln(w, "# TYPE openagent_queue_capacity gauge")
+	for _, q := range queues {
+		fmt.Fprintf(w, "openagent_queue_capacity{queue=%q} %d\n", q.Name, q.Cap)
+	}
+	fmt.Fprintln(w, "# HELP openagent_queue_utilization_ratio Fill ratio (0-1) of an agent pipeline queue.")
+	fmt.Fprintln(w, "# TYPE openagent_queue_utilization_ratio gauge")
+	for i, q := range queues {
+		ratio := 0.0
+		if q.Cap > 0 {
+			ratio = float64(lengths[i]) / float64(q.Cap)
+		}
+		fmt.Fprintf(w, "openagent_queue_utilization_ratio{queue=%q} %g\n", q.Name, ratio)
+	}
+	fmt.Fprintln(w, "# HELP openagent_draining 1 while the agent drains its queues on shutdown.")
+	fmt.Fprintln(w, "# TYPE openagent_draining gauge")
+	value := 0
+	if isDraining {
+		value = 1
+	}
+	fmt.Fprintf(w, "openagent_draining %d\n", value)
+}
@@ -0,0 +1,89 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// AuthConfig holds the credentials accepted by the admin HTTP endpoints.
+// Either a static bearer token, a basic auth username/password pair, or both may be set.
+type AuthConfig struct {
+	BearerToken string
+	Username    string
+	Password    string
+}
+
+// Enabled returns true if any credential is configured
+func (a AuthConfig) Enabled() bool {
+	return a.BearerToken != "" || a.Username != "" || a.Password != ""
+}
+
+// basicAuthEnabled returns true if both the basic auth username and password are configured
+func (a AuthConfig) basicAuthEnabled() bool {
+	return a.Username != "" && a.Password != ""
+}
+
+// Validate rejects a basic auth username without password and the reverse
+func (a AuthConfig) Validate() error {
+	if (a.Username == "") != (a.Password == "") {
+		return errors.New("ADMIN_AUTH_USERNAME and ADMIN_AUTH_PASSWORD must be set together")
+	}
+	return nil
+}
+
+// authorize checks the request credentials against the configured ones
+func (a AuthConfig) authorize(r *http.Request) bool {
+	if !a.Enabled() {
+		return true
+	}
+
+	header := r.Header.Get("Authorization")
+	if a.BearerToken != "" && strings.HasPrefix(header, "Bearer ") {
+		token := strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+		if secureEqual(token, a.BearerToken) {
+			return true
+		}
+	}
+
+	// A half-configured basic auth accepts no credentials rather than an empty password
+	if a.basicAuthEnabled() {
+		if username, password, ok := r.BasicAuth(); ok {
+			// Evaluate both comparisons so the response time does not reveal which one failed
+			userOK := secureEqual(username, a.Username)
+			passOK := secureEqual(password, a.Password)
+			if userOK && passOK {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// AuthMiddleware wraps the handler with the credential check.
+// Unauthorized requests always get the same 401 response before routing,
+// so the response does not reveal whether the requested path exists.
+func AuthMiddleware(auth AuthConfig, next http.Handler) http.Handler {
+	if !auth.Enabled() {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !auth.authorize(r) {
+			if auth.BearerToken == "" {
+				w.Header().Set("WWW-Authenticate", `Basic realm="open-agent"`)
+			} else {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="open-agent"`)
+			}
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// secureEqual compares two strings in constant time
+func secureEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
@@ -0,0 +1,131 @@
+package admin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTestHandler(auth AuthConfig) http.Handler {
+	m := http.NewServeMux()
+	m.HandleFunc("/debug/pprof/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	return AuthMiddleware(auth, m)
+}
+
+func doRequest(h http.Handler, path string, setup func(r *http.Request)) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if setup != nil {
+		setup(req)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAuthMiddleware_NoAuthConfigured(t *testing.T) {
+	h := newTestHandler(AuthConfig{})
+	if rec := doRequest(h, "/debug/pprof/", nil); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 without auth configured, got %d", rec.Code)
+	}
+}
+
+func TestAuthMiddleware_BearerToken(t *testing.T) {
+	h := newTestHandler(AuthConfig{BearerToken: "s3cret"})
+
+	if rec := doRequest(h, "/debug/pprof/", nil); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without credentials, got %d", rec.Code)
+	}
+	rec := doRequest(h, "/debug/pprof/", func(r *http.Request) {
+		r.Header.Set("Authorization", "Bearer wrong")
+	})
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 with wrong token, got %d", rec.Code)
+	}
+	rec = doRequest(h, "/debug/pprof/", func(r *http.Request) {
+		r.Header.Set("Authorization", "Bearer s3cret")
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 with valid token, got %d", rec.Code)
+	}
+}
+
+func TestAuthMiddleware_BasicAuth(t *testing.T) {
+	h := newTestHandler(AuthConfig{Username: "admin", Password: "pw"})
+
+	rec := doRequest(h, "/debug/pprof/", func(r *http.Request) {
+		r.SetBasicAuth("admin", "wrong")
+	})
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 with wrong password, got %d", rec.Code)
+	}
+	if rec.Header().Get("WWW-Authenticate") == "" {
+		t.Fatalf("expected WWW-Authenticate header on 401")
+	}
+	rec = doRequest(h, "/debug/pprof/", func(r *http.Request) {
+		r.SetBasicAuth("admin", "pw")
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 with valid basic auth, got %d", rec.Code)
+	}
+}
+
+func TestAuthMiddleware_PartialBasicAuthDeniesAll(t *testing.T) {
+	for _, auth := range []AuthConfig{{Username: "admin"}, {Password: "pw"}} {
+		if err := auth.Validate(); err == nil {
+			t.Errorf("%+v: expected the partial credentials to be rejected", auth)
+		}
+		h := newTestHandler(auth)
+		for _, password := range []string{"", "pw"} {
+			rec := doRequest(h, "/debug/pprof/", func(r *http.Request) {
+				r.SetBasicAuth("admin", password)
+			})
+			if rec.Code != http.StatusUnauthorized {
+				t.Errorf("%+v: expected 401 for admin:%q, got %d", auth, password, rec.Code)
+			}
+		}
+	}
+	if err := (AuthConfig{Username: "admin", Password: "pw"}).Validate(); err != nil {
+		t.Errorf("unexpected error for complete credentials: %v", err)
+	}
+}
+
+// TestAuthMiddleware_UnknownPathDoesNotLeak verifies that an unauthenticated request
+// to a non-existent path gets the same 401 as an existing one, not a 404.
+func TestAuthMiddleware_UnknownPathDoesNotLeak(t *testing.T) {
+	h := newTestHandler(AuthConfig{BearerToken: "s3cret"})
+
+	existing := doRequest(h, "/debug/pprof/", nil)
+	missing := doRequest(h, "/does/not/exist", nil)
+	if existing.Code != http.StatusUnauthorized || missing.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for both paths, got %d and %d", existing.Code, missing.Code)
+	}
+	if existing.Body.String() != missing.Body.String() {
+		t.Fatalf("expected identical 401 bodies, got %q and %q", existing.Body.String(), missing.Body.String())
+	}
+
+	authorized := doRequest(h, "/does/not/exist", func(r *http.Request) {
+		r.Header.Set("Authorization", "Bearer s3cret")
+	})
+	if authorized.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown path once authorized, got %d", authorized.Code)
+	}
+}
+
+func TestIsLoopback(t *testing.T) {
+	cases := map[string]bool{
+		"127.0.0.1": true,
+		"localhost": true,
+		"::1":       true,
+		"[::1]":     true,
+		"0.0.0.0":   false,
+		"":          false,
+		"10.0.0.5":  false,
+	}
+	for addr, want := range cases {
+		if got := IsLoopback(addr); got != want {
+			t.Errorf("IsLoopback(%q) = %v, want %v", addr, got, want)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"strconv"
+	"strings"
+
+	"github.com/whatap/golib/logger/logfile"
+	"open-agent/pkg/config"
+)
+
+const (
+	defaultBindAddress = "127.0.0.1"
+	defaultPort        = 6060
+)
+
+// mux serves pprof and any admin endpoint registered through Handle/HandleFunc
+var mux = http.NewServeMux()
+
+func init() {
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+}
+
+// Handle registers an admin endpoint. It is served behind the same auth middleware as pprof.
+func Handle(pattern string, handler http.Handler) {
+	mux.Handle(pattern, handler)
+}
+
+// HandleFunc registers an admin endpoint function. It is served behind the same auth middleware as pprof.
+func HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	mux.HandleFunc(pattern, handler)
+}
+
+// ServerConfig holds the admin HTTP server settings
+type ServerConfig struct {
+	BindAddress string
+	Port        int
+	TLSCertFile string
+	TLSKeyFile  string
+	Auth        AuthConfig
+}
+
+// Addr returns the listen address
+func (c ServerConfig) Addr() string {
+	return net.JoinHostPort(c.BindAddress, strconv.Itoa(c.Port))
+}
+
+// TLSEnabled returns true if both certificate and key files are configured
+func (c ServerConfig) TLSEnabled() bool {
+	return c.TLSCertFile != "" && c.TLSKeyFile != ""
+}
+
+// Validate rejects half-configured TLS or basic auth, which would otherwise serve the admin endpoints
+// unencrypted or unauthenticated
+func (c ServerConfig) Validate() error {
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		return errors.New("ADMIN_TLS_CERT_FILE and ADMIN_TLS_KEY_FILE must be set together")
+	}
+	return c.Auth.Validate()
+}
+
+// LoadServerConfig reads the admin server settings from whatap.conf, falling back to environment variables.
+// The settings are returned with an error when they are only partly configured; the server must then not start.
+func LoadServerConfig() (ServerConfig, error) {
+	port := config.GetIntWithDefault("PPROF_PORT", defaultPort)
+	if port <= 0 || port > 65535 {
+		port = defaultPort
+	}
+	cfg := ServerConfig{
+		BindAddress: config.GetWithDefault("ADMIN_BIND_ADDRESS", defaultBindAddress),
+		Port:        port,
+		TLSCertFile: config.Get("ADMIN_TLS_CERT_FILE"),
+		TLSKeyFile:  config.Get("ADMIN_TLS_KEY_FILE"),
+		Auth: AuthConfig{
+			BearerToken: config.Get("ADMIN_AUTH_TOKEN"),
+			Username:    config.Get("ADMIN_AUTH_USERNAME"),
+			Password:    config.Get("ADMIN_AUTH_PASSWORD"),
+		},
+	}
+	return cfg, cfg.Validate()
+}
+
+// IsLoopback returns true if the bind address only accepts local connections
+func IsLoopback(bindAddress string) bool {
+	host := strings.Trim(bindAddress, "[]")
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Start starts the admin HTTP server in a background goroutine. It refuses to start when TLS or the
+// credentials are only partly configured.
+func Start(cfg ServerConfig, logger *logfile.FileLogger) {
+	if err := cfg.Validate(); err != nil {
+		logger.Println("admin", fmt.Sprintf("ERROR: admin/pprof server not started: %v", err))
+		return
+	}
+	if !IsLoopback(cfg.BindAddress) && !cfg.Auth.Enabled() {
+		logger.Println("admin", "WARNING: ********************************************************************")
+		logger.Println("admin", fmt.Sprintf("WARNING: admin/pprof server is bound to non-loopback address %q WITHOUT authentication", cfg.BindAddress))
+		logger.Println("admin", "WARNING: anyone who can reach this port can read heap and goroutine dumps")
+		logger.Println("admin", "WARNING: set ADMIN_AUTH_TOKEN or ADMIN_AUTH_USERNAME/ADMIN_AUTH_PASSWORD")
+		logger.Println("admin", "WARNING: ********************************************************************")
+	}
+
+	scheme := "http"
+	if cfg.TLSEnabled() {
+		scheme = "https"
+	}
+	addr := cfg.Addr()
+	handler := AuthMiddleware(cfg.Auth, mux)
+
+	go func() {
+		logger.Infoln("pprof", fmt.Sprintf("Starting pprof server on %s (tls=%v, auth=%v)", addr, cfg.TLSEnabled(), cfg.Auth.Enabled()))
+		logger.Infoln("pprof", "Available endpoints:")
+		logger.Infoln("pprof", fmt.Sprintf("  - CPU Profile: %s://%s/debug/pprof/profile", scheme, addr))
+		logger.Infoln("pprof", fmt.Sprintf("  - Heap Profile: %s://%s/debug/pprof/heap", scheme, addr))
+		logger.Infoln("pprof", fmt.Sprintf("  - Goroutine Profile: %s://%s/debug/pprof/goroutine", scheme, addr))
+		logger.Infoln("pprof", fmt.Sprintf("  - All Profiles: %s://%s/debug/pprof/", scheme, addr))
+
+		server := &http.Server{Addr: addr, Handler: handler}
+		var err error
+		if cfg.TLSEnabled() {
+			err = server.ListenAndServeTLS(cfg.TLSCertFile, cfg.TLSKeyFile)
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil {
+			logger.Infoln("pprof", fmt.Sprintf("Failed to start pprof server: %v", err))
+		}
+	}()
+}
@@ -0,0 +1,40 @@
+package admin
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadServerConfig_PartialTLSRejected(t *testing.T) {
+	for name, env := range map[string][2]string{
+		"cert only": {"/etc/admin/tls.crt", ""},
+		"key only":  {"", "/etc/admin/tls.key"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("ADMIN_TLS_CERT_FILE", env[0])
+			t.Setenv("ADMIN_TLS_KEY_FILE", env[1])
+			cfg, err := LoadServerConfig()
+			if err == nil || !strings.Contains(err.Error(), "ADMIN_TLS_CERT_FILE") {
+				t.Fatalf("expected the half-configured TLS to be rejected, got %v", err)
+			}
+			if cfg.TLSEnabled() {
+				t.Errorf("TLS must not be reported as enabled")
+			}
+		})
+	}
+
+	t.Setenv("ADMIN_TLS_CERT_FILE", "/etc/admin/tls.crt")
+	t.Setenv("ADMIN_TLS_KEY_FILE", "/etc/admin/tls.key")
+	if cfg, err := LoadServerConfig(); err != nil || !cfg.TLSEnabled() {
+		t.Errorf("expected TLS with both files, got %v (tls=%v)", err, cfg.TLSEnabled())
+	}
+}
+
+func TestServerConfig_ValidateIncludesAuth(t *testing.T) {
+	if err := (ServerConfig{Auth: AuthConfig{Username: "admin"}}).Validate(); err == nil {
+		t.Errorf("expected partial basic auth to be rejected")
+	}
+	if err := (ServerConfig{}).Validate(); err != nil {
+		t.Errorf("unexpected error for plain HTTP without auth: %v", err)
+	}
+}
@@ -0,0 +1,80 @@
+// Package buildinfo holds the version of the running agent and reports it as a metric
+package buildinfo
+
+import (
+	"runtime"
+	"sync"
+	"time"
+
+	"open-agent/pkg/model"
+	"open-agent/tools/util/logutil"
+)
+
+// MetricName is the constant gauge identifying the agent build
+const MetricName = "openagent_build_info"
+
+var (
+	mu      sync.RWMutex
+	version = "dev"
+	commit  = "unknown"
+)
+
+// Set records the version and commit the agent was built with; empty values keep "dev" and "unknown"
+func Set(v, c string) {
+	mu.Lock()
+	defer mu.Unlock()
+	if v != "" {
+		version = v
+	}
+	if c != "" {
+		commit = c
+	}
+}
+
+// Version returns the agent version
+func Version() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return version
+}
+
+// Commit returns the git commit the agent was built from
+func Commit() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return commit
+}
+
+// Result returns openagent_build_info{version,commit,go_version} = 1 as a self-metric result
+func Result(now int64) *model.ConversionResult {
+	om := model.NewOpenMx(MetricName, now, 1)
+	om.AddLabel("version", Version())
+	om.AddLabel("commit", Commit())
+	om.AddLabel("go_version", runtime.Version())
+
+	help := model.NewOpenMxHelp(MetricName)
+	help.Put("help", "Version of the running open agent, always 1")
+	help.Put("type", "gauge")
+
+	result := model.NewConversionResult([]*model.OpenMx{om}, []*model.OpenMxHelp{help})
+	result.SetCollectionTime(now)
+	return result
+}
+
+// Run queues the build info metric right away and then every interval() until stop is closed.
+// interval is read before every wait, so configuration changes apply without a restart.
+func Run(queue chan<- *model.ConversionResult, interval func() time.Duration, stop <-chan struct{}) {
+	for {
+		select {
+		case queue <- Result(time.Now().UnixMilli()):
+		default:
+			logutil.Printf("WARN", "[BUILDINFO] Processed queue is full, dropping %s", MetricName)
+		}
+
+		select {
+		case <-time.After(interval()):
+		case <-stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package buildinfo
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"open-agent/pkg/model"
+)
+
+func TestResult_Labels(t *testing.T) {
+	Set("1.2.3", "abc1234")
+	t.Cleanup(func() { Set("dev", "unknown") })
+
+	result := Result(1700000000000)
+	if len(result.GetOpenMxList()) != 1 {
+		t.Fatalf("expected 1 series, got %d", len(result.GetOpenMxList()))
+	}
+	om := result.GetOpenMxList()[0]
+	if om.Metric != MetricName || om.Value != 1 || om.Timestamp != 1700000000000 {
+		t.Errorf("unexpected series %+v", om)
+	}
+	want := map[string]string{"version": "1.2.3", "commit": "abc1234", "go_version": runtime.Version()}
+	if len(om.Labels) != len(want) {
+		t.Errorf("expected labels %v, got %+v", want, om.Labels)
+	}
+	for _, label := range om.Labels {
+		if want[label.Key] != label.Value {
+			t.Errorf("label %s: expected %q, got %q", label.Key, want[label.Key], label.Value)
+		}
+	}
+	if help := result.GetOpenMxHelpList(); len(help) != 1 || help[0].Metric != MetricName {
+		t.Errorf("unexpected help %+v", help)
+	}
+}
+
+func TestSet_KeepsDefaultsForEmptyValues(t *testing.T) {
+	Set("", "")
+	if Version() != "dev" || Commit() != "unknown" {
+		t.Errorf("expected dev/unknown, got %s/%s", Version(), Commit())
+	}
+}
+
+func TestRun_QueuesEveryInterval(t *testing.T) {
+	queue := make(chan *model.ConversionResult, 10)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		Run(queue, func() time.Duration { return 10 * time.Millisecond }, stop)
+		close(done)
+	}()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case result := <-queue:
+			if result.GetOpenMxList()[0].Metric != MetricName {
+				t.Errorf("unexpected metric %s", result.GetOpenMxList()[0].Metric)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("build info %d not queued", i)
+		}
+	}
+	close(stop)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return after stop")
+	}
+}
@@ -0,0 +1,54 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"k8s.io/client-go/rest"
+
+	"open-agent/pkg/k8s"
+)
+
+// ExecuteGetViaAPIServer scrapes a kube-apiserver pod proxy URL, authenticating with the agent's
+// Kubernetes client configuration (bearer token, CA and client certificates). The target's own
+// TLS and basic auth settings do not apply: the apiserver connects to the pod.
+// Errors, including 403 responses for missing pods/proxy permissions, are returned like any other scrape error.
+func (c *HTTPClient) ExecuteGetViaAPIServer(proxyURL string, headers map[string]string, timeouts Timeouts) ([]byte, string, ResponseStats, error) {
+	return c.ExecuteGetViaAPIServerContext(context.Background(), proxyURL, headers, timeouts)
+}
+
+// ExecuteGetViaAPIServerContext is ExecuteGetViaAPIServer with a request context, e.g. WithSecretParams
+func (c *HTTPClient) ExecuteGetViaAPIServerContext(ctx context.Context, proxyURL string, headers map[string]string, timeouts Timeouts) ([]byte, string, ResponseStats, error) {
+	restConfig := k8s.GetInstance().RestConfig()
+	if restConfig == nil {
+		return nil, "", ResponseStats{}, fmt.Errorf("scraping through the apiserver requires an initialized Kubernetes client")
+	}
+	return c.executeGetWithRestConfig(ctx, restConfig, proxyURL, headers, timeouts)
+}
+
+// executeGetWithRestConfig scrapes a URL with a transport built from restConfig
+func (c *HTTPClient) executeGetWithRestConfig(ctx context.Context, restConfig *rest.Config, targetURL string, headers map[string]string, timeouts Timeouts) ([]byte, string, ResponseStats, error) {
+	// client-go caches the underlying TLS transport per configuration
+	transport, err := rest.TransportFor(restConfig)
+	if err != nil {
+		return nil, "", ResponseStats{}, fmt.Errorf("error creating apiserver transport: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", targetURL, nil)
+	if err != nil {
+		return nil, "", ResponseStats{}, fmt.Errorf("error creating request: %v", err)
+	}
+	setScrapeHeaders(req, headers)
+
+	timeouts = timeouts.withDefaults()
+	client := &http.Client{
+		Timeout:   timeouts.Overall,
+		Transport: transport,
+	}
+	body, contentType, stats, err := c.do(client, req, timeouts)
+	// The connection is to the apiserver, so its certificate is not the target's
+	stats.CertNotAfter = time.Time{}
+	return body, contentType, stats, err
+}
@@ -0,0 +1,51 @@
+package client
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"k8s.io/client-go/rest"
+)
+
+func TestExecuteGetWithRestConfig_BearerTokenAndCA(t *testing.T) {
+	var gotAuth, gotPath string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		gotPath = r.URL.Path
+		if gotAuth != "Bearer agent-token" {
+			http.Error(w, `pods "api-0" is forbidden: cannot get resource "pods/proxy"`, http.StatusForbidden)
+			return
+		}
+		w.Write([]byte("up 1\n"))
+	}))
+	defer server.Close()
+
+	// The test server's CA is the only trusted root, as with the in-cluster CA
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	restConfig := &rest.Config{
+		Host:            server.URL,
+		BearerToken:     "agent-token",
+		TLSClientConfig: rest.TLSClientConfig{CAData: caPEM},
+	}
+
+	c := &HTTPClient{client: &http.Client{}}
+	proxyURL := server.URL + "/api/v1/namespaces/team-a/pods/api-0:8080/proxy/metrics"
+	body, _, _, err := c.executeGetWithRestConfig(context.Background(), restConfig, proxyURL, nil, Timeouts{})
+	if err != nil || string(body) != "up 1\n" {
+		t.Fatalf("unexpected response %q, %v", body, err)
+	}
+	if gotPath != "/api/v1/namespaces/team-a/pods/api-0:8080/proxy/metrics" {
+		t.Errorf("unexpected path %s", gotPath)
+	}
+
+	// Missing RBAC surfaces as a normal scrape error
+	restConfig.BearerToken = "other-token"
+	_, _, _, err = c.executeGetWithRestConfig(context.Background(), restConfig, proxyURL, nil, Timeouts{})
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Fatalf("expected a 403 scrape error, got %v", err)
+	}
+}
@@ -0,0 +1,120 @@
+package client
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// startShortLivedTLSServer serves /metrics with a certificate expiring at notAfter and counts the
+// connections it accepts. It returns the server, the CA file that signed the certificate and the counter.
+func startShortLivedTLSServer(t *testing.T, notAfter time.Time) (*httptest.Server, string, *atomic.Int64) {
+	t.Helper()
+	ca, caKey := mustGenCA(t)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("leaf key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("leaf cert: %v", err)
+	}
+
+	var conns atomic.Int64
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("exporter_up 1\n"))
+	}))
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	srv.TLS = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	caFile := writeFile(t, t.TempDir(), "ca.crt", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw}))
+	return srv, caFile, &conns
+}
+
+func TestResponseStats_CertNotAfter(t *testing.T) {
+	// Certificates carry whole seconds
+	notAfter := time.Now().Add(2 * time.Hour).Truncate(time.Second)
+	srv, caFile, _ := startShortLivedTLSServer(t, notAfter)
+	timeouts := Timeouts{Overall: 5 * time.Second}
+
+	for name, tlsConfig := range map[string]*TLSConfig{
+		"verified":           {CAFile: caFile},
+		"insecureSkipVerify": {InsecureSkipVerify: true},
+	} {
+		_, _, stats, err := GetInstance().ExecuteGetWithStats(srv.URL+"/metrics", tlsConfig, nil, nil, timeouts)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !stats.CertNotAfter.Equal(notAfter) {
+			t.Errorf("%s: CertNotAfter = %v, want %v", name, stats.CertNotAfter, notAfter)
+		}
+	}
+
+	// A plain http response has no certificate
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("exporter_up 1\n"))
+	}))
+	defer plain.Close()
+	if _, _, stats, err := GetInstance().ExecuteGetWithStats(plain.URL, nil, nil, nil, timeouts); err != nil || !stats.CertNotAfter.IsZero() {
+		t.Errorf("expected no certificate for http, got %v (err %v)", stats.CertNotAfter, err)
+	}
+}
+
+func TestResponseStats_CertNotAfterWithConnectionReuse(t *testing.T) {
+	notAfter := time.Now().Add(time.Hour).Truncate(time.Second)
+	srv, caFile, conns := startShortLivedTLSServer(t, notAfter)
+	tlsConfig := &TLSConfig{CAFile: caFile}
+	scrape := func(ctx context.Context) ResponseStats {
+		t.Helper()
+		_, _, stats, err := GetInstance().ExecuteGetWithStatsContext(ctx, srv.URL+"/metrics", tlsConfig, nil, nil, Timeouts{Overall: 5 * time.Second})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return stats
+	}
+
+	// A reused connection still reports the certificate
+	scrape(context.Background())
+	if stats := scrape(context.Background()); !stats.CertNotAfter.Equal(notAfter) {
+		t.Errorf("expected the certificate on a reused connection, got %v", stats.CertNotAfter)
+	}
+	if n := conns.Load(); n != 1 {
+		t.Fatalf("expected the connection to be reused, got %d connections", n)
+	}
+
+	// WithCloseConnection makes the next request handshake again
+	if stats := scrape(WithCloseConnection(context.Background())); !stats.CertNotAfter.Equal(notAfter) {
+		t.Errorf("expected the certificate, got %v", stats.CertNotAfter)
+	}
+	scrape(context.Background())
+	if n := conns.Load(); n != 2 {
+		t.Errorf("expected a new connection after WithCloseConnection, got %d connections", n)
+	}
+}
@@ -0,0 +1,221 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	configPkg "open-agent/pkg/config"
+)
+
+const (
+	// DefaultDNSCacheMinTTL is the shortest time a resolved host is cached
+	DefaultDNSCacheMinTTL = 5 * time.Second
+	// DefaultDNSCacheMaxTTL is the longest time a resolved host is cached, and the TTL used
+	// when the resolver does not report one
+	DefaultDNSCacheMaxTTL = 30 * time.Second
+	// DefaultDNSCacheNegativeTTL is how long a host that does not exist is cached
+	DefaultDNSCacheNegativeTTL = 5 * time.Second
+	// maxDNSCacheEntries bounds the cached hosts; expired entries are evicted first
+	maxDNSCacheEntries = 4096
+)
+
+// hostResolver resolves a host name to addresses. ttl is how long the answer may be cached,
+// or 0 when the resolver does not know.
+type hostResolver interface {
+	LookupHost(ctx context.Context, host string) (addrs []string, ttl time.Duration, err error)
+}
+
+// systemResolver uses the Go resolver, which does not expose record TTLs
+type systemResolver struct{}
+
+func (systemResolver) LookupHost(ctx context.Context, host string) ([]string, time.Duration, error) {
+	addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+	return addrs, 0, err
+}
+
+// dnsEntry is a cached answer: the addresses, or the not-found error of a negative answer
+type dnsEntry struct {
+	addrs   []string
+	err     error
+	expires time.Time
+}
+
+// dnsCache caches host lookups of the scrape transports, so targets addressed by host name
+// do not query DNS on every scrape
+type dnsCache struct {
+	resolver hostResolver
+	now      func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]dnsEntry
+
+	hits, misses, evictions atomic.Int64
+}
+
+func newDNSCache(resolver hostResolver) *dnsCache {
+	return &dnsCache{resolver: resolver, now: time.Now, entries: make(map[string]dnsEntry)}
+}
+
+// scrapeDNS is the cache used by the dialers of all scrape transports
+var scrapeDNS = newDNSCache(systemResolver{})
+
+// DNSCacheStats returns the lookups answered from the scrape DNS cache, the lookups that
+// queried the resolver and the entries dropped because they expired or the cache was full
+func DNSCacheStats() (hits, misses, evictions int64) {
+	return scrapeDNS.hits.Load(), scrapeDNS.misses.Load(), scrapeDNS.evictions.Load()
+}
+
+// dnsCacheEnabled reports whether scrapes use the DNS cache.
+// Read from whatap.conf on every use, so changes apply without a restart.
+func dnsCacheEnabled() bool {
+	return configPkg.GetBoolWithDefault("scrape_dns_cache_enabled", true)
+}
+
+// dnsCacheTTLs returns the configured minimum, maximum and negative TTLs
+func dnsCacheTTLs() (minTTL, maxTTL, negativeTTL time.Duration) {
+	ms := func(key string, def time.Duration) time.Duration {
+		value := time.Duration(configPkg.GetIntWithDefault(key, int(def/time.Millisecond))) * time.Millisecond
+		if value < 0 {
+			return def
+		}
+		return value
+	}
+	minTTL = ms("scrape_dns_cache_min_ttl_ms", DefaultDNSCacheMinTTL)
+	maxTTL = ms("scrape_dns_cache_max_ttl_ms", DefaultDNSCacheMaxTTL)
+	negativeTTL = ms("scrape_dns_cache_negative_ttl_ms", DefaultDNSCacheNegativeTTL)
+	if maxTTL < minTTL {
+		maxTTL = minTTL
+	}
+	return minTTL, maxTTL, negativeTTL
+}
+
+type bypassDNSCacheKey struct{}
+
+// WithoutDNSCache returns a context whose requests resolve the target host on every new connection
+func WithoutDNSCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, bypassDNSCacheKey{}, true)
+}
+
+func bypassDNSCache(ctx context.Context) bool {
+	bypass, _ := ctx.Value(bypassDNSCacheKey{}).(bool)
+	return bypass
+}
+
+// lookup returns the addresses of host, from the cache while the entry is fresh
+func (c *dnsCache) lookup(ctx context.Context, host string) ([]string, error) {
+	now := c.now()
+	c.mu.Lock()
+	entry, ok := c.entries[host]
+	if ok && now.Before(entry.expires) {
+		c.mu.Unlock()
+		c.hits.Add(1)
+		return entry.addrs, entry.err
+	}
+	if ok {
+		delete(c.entries, host)
+		c.evictions.Add(1)
+	}
+	c.mu.Unlock()
+
+	c.misses.Add(1)
+	addrs, ttl, err := c.resolver.LookupHost(ctx, host)
+	minTTL, maxTTL, negativeTTL := dnsCacheTTLs()
+	switch {
+	case err == nil && len(addrs) > 0:
+		if ttl <= 0 || ttl > maxTTL {
+			ttl = maxTTL
+		}
+		if ttl < minTTL {
+			ttl = minTTL
+		}
+		c.store(host, dnsEntry{addrs: addrs, expires: now.Add(ttl)})
+	case isNotFound(err):
+		// Other errors (timeouts, an unreachable server) are not cached, so the next scrape retries
+		c.store(host, dnsEntry{err: err, expires: now.Add(negativeTTL)})
+	}
+	return addrs, err
+}
+
+func (c *dnsCache) store(host string, entry dnsEntry) {
+	if !entry.expires.After(c.now()) {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= maxDNSCacheEntries {
+		c.evictLocked()
+	}
+	c.entries[host] = entry
+}
+
+// evictLocked drops the expired entries, or any one entry if none has expired
+func (c *dnsCache) evictLocked() {
+	now := c.now()
+	evicted := 0
+	for host, entry := range c.entries {
+		if !now.Before(entry.expires) {
+			delete(c.entries, host)
+			evicted++
+		}
+	}
+	if evicted == 0 {
+		for host := range c.entries {
+			delete(c.entries, host)
+			evicted++
+			break
+		}
+	}
+	c.evictions.Add(int64(evicted))
+}
+
+// forget drops the cached addresses of a host, e.g. after none of them accepted a connection
+func (c *dnsCache) forget(host string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, host)
+}
+
+func isNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
+
+// dialContext wraps dial so host names are resolved through the cache. Addresses are tried
+// in order, like net.Dialer does. IP addresses, requests made WithoutDNSCache and a disabled
+// cache dial directly.
+func (c *dnsCache) dialContext(dial func(ctx context.Context, network, address string) (net.Conn, error)) func(ctx context.Context, network, address string) (net.Conn, error) {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		if !dnsCacheEnabled() || bypassDNSCache(ctx) {
+			return dial(ctx, network, address)
+		}
+		host, port, err := net.SplitHostPort(address)
+		if err != nil || net.ParseIP(host) != nil {
+			return dial(ctx, network, address)
+		}
+
+		addrs, err := c.lookup(ctx, host)
+		if err != nil {
+			return nil, &net.OpError{Op: "dial", Net: network, Err: err}
+		}
+		var firstErr error
+		for _, addr := range addrs {
+			conn, err := dial(ctx, network, net.JoinHostPort(addr, port))
+			if err == nil {
+				return conn, nil
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+			if ctx.Err() != nil {
+				break
+			}
+		}
+		// The host may have moved; resolve it again on the next attempt
+		c.forget(host)
+		return nil, firstErr
+	}
+}
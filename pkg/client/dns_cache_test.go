@@ -0,0 +1,165 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// fakeResolver answers from a table and counts the queries per host
+type fakeResolver struct {
+	addrs   map[string][]string
+	ttl     time.Duration
+	err     error
+	queries map[string]int
+}
+
+func (r *fakeResolver) LookupHost(_ context.Context, host string) ([]string, time.Duration, error) {
+	r.queries[host]++
+	if r.err != nil {
+		return nil, 0, r.err
+	}
+	addrs, ok := r.addrs[host]
+	if !ok {
+		return nil, 0, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return addrs, r.ttl, nil
+}
+
+func newFakeDNSCache(t *testing.T, ttl time.Duration) (*dnsCache, *fakeResolver, *time.Time) {
+	t.Setenv("scrape_dns_cache_min_ttl_ms", "5000")
+	t.Setenv("scrape_dns_cache_max_ttl_ms", "60000")
+	t.Setenv("scrape_dns_cache_negative_ttl_ms", "10000")
+	resolver := &fakeResolver{
+		addrs:   map[string][]string{"exporter.local": {"10.0.0.7"}},
+		ttl:     ttl,
+		queries: make(map[string]int),
+	}
+	now := time.Unix(1700000000, 0)
+	cache := newDNSCache(resolver)
+	cache.now = func() time.Time { return now }
+	return cache, resolver, &now
+}
+
+func TestDNSCache_HonorsTTL(t *testing.T) {
+	cache, resolver, now := newFakeDNSCache(t, 20*time.Second)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		addrs, err := cache.lookup(ctx, "exporter.local")
+		if err != nil || len(addrs) != 1 || addrs[0] != "10.0.0.7" {
+			t.Fatalf("unexpected answer %v %v", addrs, err)
+		}
+	}
+	if resolver.queries["exporter.local"] != 1 {
+		t.Errorf("expected 1 query within the TTL, got %d", resolver.queries["exporter.local"])
+	}
+
+	*now = now.Add(19 * time.Second)
+	cache.lookup(ctx, "exporter.local")
+	*now = now.Add(2 * time.Second)
+	cache.lookup(ctx, "exporter.local")
+	if resolver.queries["exporter.local"] != 2 {
+		t.Errorf("expected a new query once the TTL passed, got %d queries", resolver.queries["exporter.local"])
+	}
+	if cache.hits.Load() != 3 || cache.misses.Load() != 2 || cache.evictions.Load() != 1 {
+		t.Errorf("unexpected counters hits=%d misses=%d evictions=%d", cache.hits.Load(), cache.misses.Load(), cache.evictions.Load())
+	}
+}
+
+func TestDNSCache_ClampsTTL(t *testing.T) {
+	for name, tc := range map[string]struct {
+		ttl      time.Duration
+		expected time.Duration
+	}{
+		"below minimum": {time.Second, 5 * time.Second},
+		"above maximum": {time.Hour, time.Minute},
+		"unknown":       {0, time.Minute},
+	} {
+		cache, resolver, now := newFakeDNSCache(t, tc.ttl)
+		cache.lookup(context.Background(), "exporter.local")
+		*now = now.Add(tc.expected - time.Millisecond)
+		cache.lookup(context.Background(), "exporter.local")
+		*now = now.Add(time.Millisecond)
+		cache.lookup(context.Background(), "exporter.local")
+		if resolver.queries["exporter.local"] != 2 {
+			t.Errorf("%s: expected the entry to live %v, got %d queries", name, tc.expected, resolver.queries["exporter.local"])
+		}
+	}
+}
+
+func TestDNSCache_NegativeCaching(t *testing.T) {
+	cache, resolver, now := newFakeDNSCache(t, 0)
+	ctx := context.Background()
+
+	_, err := cache.lookup(ctx, "gone.local")
+	_, err2 := cache.lookup(ctx, "gone.local")
+	if !isNotFound(err) || !isNotFound(err2) {
+		t.Fatalf("expected not found errors, got %v and %v", err, err2)
+	}
+	if resolver.queries["gone.local"] != 1 {
+		t.Errorf("not found answer should be cached, got %d queries", resolver.queries["gone.local"])
+	}
+	*now = now.Add(10 * time.Second)
+	cache.lookup(ctx, "gone.local")
+	if resolver.queries["gone.local"] != 2 {
+		t.Errorf("negative entry should expire after the negative TTL, got %d queries", resolver.queries["gone.local"])
+	}
+
+	// Failures other than not found are retried on the next lookup
+	resolver.err = errors.New("i/o timeout")
+	cache.lookup(ctx, "other.local")
+	cache.lookup(ctx, "other.local")
+	if resolver.queries["other.local"] != 2 {
+		t.Errorf("temporary failures should not be cached, got %d queries", resolver.queries["other.local"])
+	}
+}
+
+func TestDNSCache_DialContext(t *testing.T) {
+	cache, resolver, _ := newFakeDNSCache(t, 0)
+	resolver.addrs["exporter.local"] = []string{"10.0.0.7", "10.0.0.8"}
+
+	var dialed []string
+	fail := map[string]bool{"10.0.0.7:9100": true}
+	dial := cache.dialContext(func(_ context.Context, _, address string) (net.Conn, error) {
+		dialed = append(dialed, address)
+		if fail[address] {
+			return nil, errors.New("connection refused")
+		}
+		client, server := net.Pipe()
+		server.Close()
+		return client, nil
+	})
+
+	conn, err := dial(context.Background(), "tcp", "exporter.local:9100")
+	if err != nil {
+		t.Fatalf("expected the second address to connect: %v", err)
+	}
+	conn.Close()
+	if len(dialed) != 2 || dialed[1] != "10.0.0.8:9100" {
+		t.Errorf("expected addresses tried in order, got %v", dialed)
+	}
+
+	// IP addresses and requests made WithoutDNSCache do not use the cache
+	dialed = nil
+	dial(context.Background(), "tcp", "10.0.0.9:9100")
+	dial(WithoutDNSCache(context.Background()), "tcp", "exporter.local:9100")
+	if len(dialed) != 2 || dialed[0] != "10.0.0.9:9100" || dialed[1] != "exporter.local:9100" {
+		t.Errorf("expected direct dials, got %v", dialed)
+	}
+	if resolver.queries["exporter.local"] != 1 {
+		t.Errorf("expected 1 query, got %d", resolver.queries["exporter.local"])
+	}
+
+	// When no address accepts a connection the host is resolved again
+	fail["10.0.0.8:9100"] = true
+	if _, err := dial(context.Background(), "tcp", "exporter.local:9100"); err == nil {
+		t.Fatal("expected a dial error")
+	}
+	dial(context.Background(), "tcp", "exporter.local:9100")
+	if resolver.queries["exporter.local"] != 2 {
+		t.Errorf("expected a new query after all addresses failed, got %d", resolver.queries["exporter.local"])
+	}
+}
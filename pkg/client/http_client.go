@@ -1,9 +1,12 @@
 package client
 
 import (
+	"compress/gzip"
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"strings"
@@ -21,16 +24,31 @@ const (
 	kubernetesServiceHost    = "KUBERNETES_SERVICE_HOST"
 	kubernetesServicePort    = "KUBERNETES_SERVICE_PORT"
 
-	// protobufAcceptHeader is the prioritized Accept value advertised when
+	// ProtobufAcceptHeader is the prioritized Accept value advertised when
 	// protobuf scraping is enabled. Native histograms are only available via the
 	// Prometheus protobuf format, so it is preferred, followed by OpenMetrics,
 	// text exposition, and a low-quality wildcard fallback.
-	protobufAcceptHeader = "application/vnd.google.protobuf;proto=io.prometheus.client.MetricFamily;encoding=delimited," +
+	ProtobufAcceptHeader = "application/vnd.google.protobuf;proto=io.prometheus.client.MetricFamily;encoding=delimited," +
 		"application/openmetrics-text;version=1.0.0;charset=utf-8," +
 		"text/plain;version=0.0.4;charset=utf-8," +
 		"*/*;q=0.1"
+
+	// DefaultUserAgent is sent when the caller does not provide a User-Agent
+	DefaultUserAgent = "whatap-open-agent"
 )
 
+// BuildUserAgent returns the scrape User-Agent, e.g. "whatap-open-agent/1.2.3 (+abc1234)"
+func BuildUserAgent(version, commitHash string) string {
+	if version == "" {
+		version = "dev"
+	}
+	ua := DefaultUserAgent + "/" + version
+	if commitHash != "" {
+		ua += " (+" + commitHash + ")"
+	}
+	return ua
+}
+
 // TLSConfig represents TLS configuration options
 type TLSConfig struct {
 	// InsecureSkipVerify disables target certificate validation
@@ -56,6 +74,13 @@ type TLSConfig struct {
 
 	// ServerName extension to indicate the name of the server
 	ServerName string `json:"serverName,omitempty" yaml:"serverName,omitempty"`
+
+	// MinVersion and MaxVersion bound the TLS versions (TLS10, TLS11, TLS12, TLS13); empty keeps the Go default
+	MinVersion string `json:"minVersion,omitempty" yaml:"minVersion,omitempty"`
+	MaxVersion string `json:"maxVersion,omitempty" yaml:"maxVersion,omitempty"`
+
+	// Renegotiation allows the server to renegotiate the connection (never, once, freely); empty is never
+	Renegotiation string `json:"renegotiation,omitempty" yaml:"renegotiation,omitempty"`
 }
 
 // Validate validates the TLS configuration to ensure consistency
@@ -127,6 +152,11 @@ func (c *TLSConfig) Validate() error {
 		return fmt.Errorf("keySecret must have both name and key specified")
 	}
 
+	// Validate the TLS versions and renegotiation, and that openagent_tls_forbid_legacy allows them
+	if _, _, _, err := configPkg.ValidateTLSVersions(c.MinVersion, c.MaxVersion, c.Renegotiation); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -149,7 +179,7 @@ func GetInstance() *HTTPClient {
 		}
 
 		// Only try to configure TLS with Kubernetes CA cert in K8s environment
-		k8sClient := k8s.GetInstance()
+		k8sClient := k8s.NewProvider(configPkg.IsForceStandaloneMode())
 		if k8sClient.IsInitialized() {
 			if cert, err := loadKubernetesCACert(); err == nil {
 				rootCAs, _ := x509.SystemCertPool()
@@ -241,14 +271,28 @@ func loadCertificateFromFile(filePath string) ([]byte, error) {
 	return data, nil
 }
 
-// loadCertificateFromSecret loads a certificate from a Kubernetes Secret
+// k8sProvider returns the Kubernetes client Secrets are read from; replaced in tests
+var k8sProvider = func() k8s.K8sProvider {
+	return k8s.NewProvider(configPkg.IsForceStandaloneMode())
+}
+
+// secretKey returns the namespace/name of the Secret a selector refers to
+func secretKey(selector *configPkg.SecretKeySelector) string {
+	namespace := selector.Namespace
+	if namespace == "" {
+		namespace = "default"
+	}
+	return namespace + "/" + selector.Name
+}
+
+// loadCertificateFromSecret loads a certificate from a Kubernetes Secret. Secrets are read from the
+// informer cache, so resolving credentials on every scrape adds no API server calls.
 func loadCertificateFromSecret(secretSelector *configPkg.SecretKeySelector) ([]byte, error) {
 	if secretSelector == nil {
 		return nil, fmt.Errorf("secret selector is nil")
 	}
 
-	// Import k8s package when this function is actually used
-	k8sClient := k8s.GetInstance()
+	k8sClient := k8sProvider()
 	if !k8sClient.IsInitialized() {
 		return nil, fmt.Errorf("kubernetes client not initialized")
 	}
@@ -276,6 +320,9 @@ func loadCertificateFromSecret(secretSelector *configPkg.SecretKeySelector) ([]b
 }
 
 func resolveSecretString(selector *configPkg.SecretKeySelector) (string, error) {
+	if selector.Value != "" {
+		return selector.Value, nil
+	}
 	data, err := loadCertificateFromSecret(selector)
 	if err != nil {
 		return "", err
@@ -315,16 +362,43 @@ func (c *HTTPClient) ExecuteGetWithAuth(targetURL string, tlsConfig *TLSConfig,
 // body together with the response Content-Type, allowing callers to perform
 // content negotiation (e.g. Prometheus protobuf vs. text exposition).
 func (c *HTTPClient) ExecuteGetWithAuthResponse(targetURL string, tlsConfig *TLSConfig, basicAuth *configPkg.BasicAuthConfig, timeout time.Duration) ([]byte, string, error) {
+	return c.ExecuteGetWithHeadersResponse(targetURL, tlsConfig, basicAuth, nil, timeout)
+}
+
+// ExecuteGetWithHeadersResponse is ExecuteGetWithAuthResponse with extra request headers.
+// Headers are applied last, so they override the defaults (User-Agent, Accept, Accept-Encoding).
+func (c *HTTPClient) ExecuteGetWithHeadersResponse(targetURL string, tlsConfig *TLSConfig, basicAuth *configPkg.BasicAuthConfig, headers map[string]string, timeout time.Duration) ([]byte, string, error) {
+	return c.ExecuteGetWithTimeoutsResponse(targetURL, tlsConfig, basicAuth, headers, Timeouts{Overall: timeout})
+}
+
+// ExecuteGetWithTimeoutsResponse is ExecuteGetWithHeadersResponse with separate connect, read and
+// overall timeouts. Timeouts are returned as *TimeoutError naming the phase that timed out.
+func (c *HTTPClient) ExecuteGetWithTimeoutsResponse(targetURL string, tlsConfig *TLSConfig, basicAuth *configPkg.BasicAuthConfig, headers map[string]string, timeouts Timeouts) ([]byte, string, error) {
+	body, contentType, _, err := c.ExecuteGetWithStats(targetURL, tlsConfig, basicAuth, headers, timeouts)
+	return body, contentType, err
+}
+
+// ExecuteGetWithStats is ExecuteGetWithTimeoutsResponse that also reports the response body size
+// on the wire and after decoding. The stats are filled in whenever a response body was read, also
+// for non-2xx responses.
+func (c *HTTPClient) ExecuteGetWithStats(targetURL string, tlsConfig *TLSConfig, basicAuth *configPkg.BasicAuthConfig, headers map[string]string, timeouts Timeouts) ([]byte, string, ResponseStats, error) {
+	return c.ExecuteGetWithStatsContext(context.Background(), targetURL, tlsConfig, basicAuth, headers, timeouts)
+}
+
+// ExecuteGetWithStatsContext is ExecuteGetWithStats with a request context, e.g. WithoutDNSCache
+func (c *HTTPClient) ExecuteGetWithStatsContext(ctx context.Context, targetURL string, tlsConfig *TLSConfig, basicAuth *configPkg.BasicAuthConfig, headers map[string]string, timeouts Timeouts) ([]byte, string, ResponseStats, error) {
+	var stats ResponseStats
 	formattedURL := FormatURL(targetURL)
 	// Log the request
 	if configPkg.IsDebugEnabled() {
 		logutil.Debugf("HTTP_CLIENT", "HTTP Request: GET %s", formattedURL)
 	}
 
-	req, err := http.NewRequest("GET", formattedURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", formattedURL, nil)
 	if err != nil {
-		return nil, "", fmt.Errorf("error creating request: %v", err)
+		return nil, "", stats, fmt.Errorf("error creating request: %v", err)
 	}
+	req.Close = closeConnection(ctx)
 
 	// Authentication
 	authSet := false
@@ -361,7 +435,7 @@ func (c *HTTPClient) ExecuteGetWithAuthResponse(targetURL string, tlsConfig *TLS
 	// 2. Service Account Token (Bearer Token) - only if Basic Auth is not set
 	if !authSet {
 		// Try to add service account token for authentication in K8s environment only
-		k8sClient := k8s.GetInstance()
+		k8sClient := k8s.NewProvider(configPkg.IsForceStandaloneMode())
 		if k8sClient.IsInitialized() {
 			token, err := GetServiceAccountToken()
 			if err == nil {
@@ -381,196 +455,229 @@ func (c *HTTPClient) ExecuteGetWithAuthResponse(targetURL string, tlsConfig *TLS
 		}
 	}
 
-	// Content negotiation. By default the agent keeps requesting application/json
-	// (legacy behavior). When protobuf scraping is enabled the agent advertises a
-	// prioritized Accept list so native-histogram-capable targets can respond with
-	// the Prometheus protobuf format, while still allowing OpenMetrics/text and a
-	// wildcard fallback for targets that do not support protobuf.
-	if configPkg.GetBoolWithDefault("openagent_enable_protobuf", false) {
-		req.Header.Set("Accept", protobufAcceptHeader)
-	} else {
-		req.Header.Set("Accept", "application/json")
-	}
+	setScrapeHeaders(req, headers)
 
-	// Determine the effective timeout
-	effectiveTimeout := timeout
-	if effectiveTimeout == 0 {
-		effectiveTimeout = 10 * time.Second // Default timeout
-	}
+	// Determine the effective timeouts
+	timeouts = timeouts.withDefaults()
+	effectiveTimeout := timeouts.Overall
 
 	if configPkg.IsDebugEnabled() {
-		logutil.Debugf("HTTP_CLIENT", "Using timeout: %v", effectiveTimeout)
+		logutil.Debugf("HTTP_CLIENT", "Using timeout: %v (connect: %v, read: %v)", effectiveTimeout, timeouts.Connect, timeouts.Read)
 	}
 
-	// Use the default client or create a new one with custom TLS config/timeout
-	client := c.client
+	// Use the shared transport or create a new one with custom TLS config
+	client := &http.Client{
+		Timeout:   effectiveTimeout,
+		Transport: c.transportFor(timeouts),
+	}
 	if tlsConfig != nil {
 		// Validate TLS configuration
 		if err := tlsConfig.Validate(); err != nil {
-			return nil, "", fmt.Errorf("invalid TLS configuration: %v", err)
+			return nil, "", stats, fmt.Errorf("invalid TLS configuration: %v", err)
 		}
+		warnLegacyTLS(req.URL.Host, tlsConfig)
 
 		if configPkg.IsDebugEnabled() {
 			logutil.Debugf("HTTP_CLIENT", "Using custom TLS config with InsecureSkipVerify=%v", tlsConfig.InsecureSkipVerify)
 		}
 
-		// Create a custom transport with the specified TLS config
-		customTLSConfig := &tls.Config{
-			InsecureSkipVerify: tlsConfig.InsecureSkipVerify,
+		// File-based TLS settings share a cached transport that is rebuilt when the files change
+		client = &http.Client{
+			Timeout:   effectiveTimeout,
+			Transport: tlsTransports.get(tlsConfig, timeouts),
 		}
+	}
 
-		// Set server name if specified
-		if tlsConfig.ServerName != "" {
-			customTLSConfig.ServerName = tlsConfig.ServerName
-			if configPkg.IsDebugEnabled() {
-				logutil.Debugf("HTTP_CLIENT", "Set server name: %s", tlsConfig.ServerName)
-			}
+	return c.do(client, req, timeouts)
+}
+
+// buildTLSClientConfig loads the CA and client certificate of tlsConfig into a tls.Config
+func buildTLSClientConfig(tlsConfig *TLSConfig) *tls.Config {
+	customTLSConfig := &tls.Config{
+		InsecureSkipVerify: tlsConfig.InsecureSkipVerify,
+	}
+
+	// Versions and renegotiation were checked by Validate before the transport is built
+	if minVersion, maxVersion, renegotiation, err := configPkg.ValidateTLSVersions(tlsConfig.MinVersion, tlsConfig.MaxVersion, tlsConfig.Renegotiation); err == nil {
+		customTLSConfig.MinVersion = minVersion
+		customTLSConfig.MaxVersion = maxVersion
+		customTLSConfig.Renegotiation = renegotiation
+	}
+
+	// Set server name if specified
+	if tlsConfig.ServerName != "" {
+		customTLSConfig.ServerName = tlsConfig.ServerName
+		if configPkg.IsDebugEnabled() {
+			logutil.Debugf("HTTP_CLIENT", "Set server name: %s", tlsConfig.ServerName)
 		}
+	}
 
-		// Configure certificate validation if InsecureSkipVerify is false
-		if !tlsConfig.InsecureSkipVerify {
-			// Load system root CAs as base
-			rootCAs, _ := x509.SystemCertPool()
-			if rootCAs == nil {
-				rootCAs = x509.NewCertPool()
-			}
+	// Configure certificate validation if InsecureSkipVerify is false
+	if !tlsConfig.InsecureSkipVerify {
+		// Load system root CAs as base
+		rootCAs, _ := x509.SystemCertPool()
+		if rootCAs == nil {
+			rootCAs = x509.NewCertPool()
+		}
 
-			// Add CA certificate (prefer file over secret over default K8s CA)
-			var caData []byte
-			var caErr error
-
-			if tlsConfig.CAFile != "" {
-				// Load CA from file
-				caData, caErr = loadCertificateFromFile(tlsConfig.CAFile)
-				if caErr == nil {
-					if rootCAs.AppendCertsFromPEM(caData) {
-						if configPkg.IsDebugEnabled() {
-							logutil.Debugf("HTTP_CLIENT", "Added CA certificate from file: %s", tlsConfig.CAFile)
-						}
-					} else {
-						if configPkg.IsDebugEnabled() {
-							logutil.Debugf("HTTP_CLIENT", "Failed to parse CA certificate from file: %s", tlsConfig.CAFile)
-						}
-					}
-				} else {
+		// Add CA certificate (prefer file over secret over default K8s CA)
+		var caData []byte
+		var caErr error
+
+		if tlsConfig.CAFile != "" {
+			// Load CA from file
+			caData, caErr = loadCertificateFromFile(tlsConfig.CAFile)
+			if caErr == nil {
+				if rootCAs.AppendCertsFromPEM(caData) {
 					if configPkg.IsDebugEnabled() {
-						logutil.Debugf("HTTP_CLIENT", "Failed to load CA certificate from file %s: %v", tlsConfig.CAFile, caErr)
-					}
-				}
-			} else if tlsConfig.CASecret != nil {
-				// Load CA from secret
-				caData, caErr = loadCertificateFromSecret(tlsConfig.CASecret)
-				if caErr == nil {
-					if rootCAs.AppendCertsFromPEM(caData) {
-						if configPkg.IsDebugEnabled() {
-							logutil.Debugf("HTTP_CLIENT", "Added CA certificate from secret: %s/%s", tlsConfig.CASecret.Name, tlsConfig.CASecret.Key)
-						}
-					} else {
-						if configPkg.IsDebugEnabled() {
-							logutil.Debugf("HTTP_CLIENT", "Failed to parse CA certificate from secret: %s/%s", tlsConfig.CASecret.Name, tlsConfig.CASecret.Key)
-						}
+						logutil.Debugf("HTTP_CLIENT", "Added CA certificate from file: %s", tlsConfig.CAFile)
 					}
 				} else {
 					if configPkg.IsDebugEnabled() {
-						logutil.Debugf("HTTP_CLIENT", "Failed to load CA certificate from secret %s/%s: %v", tlsConfig.CASecret.Name, tlsConfig.CASecret.Key, caErr)
+						logutil.Debugf("HTTP_CLIENT", "Failed to parse CA certificate from file: %s", tlsConfig.CAFile)
 					}
 				}
 			} else {
-				// Fall back to default Kubernetes CA only in K8s environment
-				k8sClient := k8s.GetInstance()
-				if k8sClient.IsInitialized() {
-					if cert, err := loadKubernetesCACert(); err == nil {
-						rootCAs.AddCert(cert)
-						if configPkg.IsDebugEnabled() {
-							logutil.Debugf("HTTP_CLIENT", "Added default Kubernetes CA cert to root CA pool")
-						}
-					} else {
-						if configPkg.IsDebugEnabled() {
-							logutil.Debugf("HTTP_CLIENT", "Failed to load default Kubernetes CA cert: %v", err)
-						}
+				if configPkg.IsDebugEnabled() {
+					logutil.Debugf("HTTP_CLIENT", "Failed to load CA certificate from file %s: %v", tlsConfig.CAFile, caErr)
+				}
+			}
+		} else if tlsConfig.CASecret != nil {
+			// Load CA from secret
+			caData, caErr = loadCertificateFromSecret(tlsConfig.CASecret)
+			if caErr == nil {
+				if rootCAs.AppendCertsFromPEM(caData) {
+					if configPkg.IsDebugEnabled() {
+						logutil.Debugf("HTTP_CLIENT", "Added CA certificate from secret: %s/%s", tlsConfig.CASecret.Name, tlsConfig.CASecret.Key)
 					}
 				} else {
 					if configPkg.IsDebugEnabled() {
-						logutil.Debugf("HTTP_CLIENT", "Not in Kubernetes environment, using system CA pool only")
+						logutil.Debugf("HTTP_CLIENT", "Failed to parse CA certificate from secret: %s/%s", tlsConfig.CASecret.Name, tlsConfig.CASecret.Key)
 					}
 				}
-			}
-
-			customTLSConfig.RootCAs = rootCAs
-		}
-
-		// Configure client certificate authentication (mTLS).
-		// This is independent of InsecureSkipVerify: presenting our client
-		// certificate to the server is unrelated to whether we verify the
-		// server's certificate. Targets such as etcd require a client cert
-		// even when server verification is skipped.
-		if (tlsConfig.CertFile != "" && tlsConfig.KeyFile != "") || (tlsConfig.CertSecret != nil && tlsConfig.KeySecret != nil) {
-			var certData, keyData []byte
-			var certErr, keyErr error
-
-			if tlsConfig.CertFile != "" && tlsConfig.KeyFile != "" {
-				// Load client cert and key from files
-				certData, certErr = loadCertificateFromFile(tlsConfig.CertFile)
-				keyData, keyErr = loadCertificateFromFile(tlsConfig.KeyFile)
-				if configPkg.IsDebugEnabled() {
-					logutil.Debugf("HTTP_CLIENT", "Loading client certificate from files: cert=%s, key=%s", tlsConfig.CertFile, tlsConfig.KeyFile)
-				}
-			} else if tlsConfig.CertSecret != nil && tlsConfig.KeySecret != nil {
-				// Load client cert and key from secrets
-				certData, certErr = loadCertificateFromSecret(tlsConfig.CertSecret)
-				keyData, keyErr = loadCertificateFromSecret(tlsConfig.KeySecret)
+			} else {
 				if configPkg.IsDebugEnabled() {
-					logutil.Debugf("HTTP_CLIENT", "Loading client certificate from secrets: cert=%s/%s, key=%s/%s",
-						tlsConfig.CertSecret.Name, tlsConfig.CertSecret.Key, tlsConfig.KeySecret.Name, tlsConfig.KeySecret.Key)
+					logutil.Debugf("HTTP_CLIENT", "Failed to load CA certificate from secret %s/%s: %v", tlsConfig.CASecret.Name, tlsConfig.CASecret.Key, caErr)
 				}
 			}
-
-			if certErr == nil && keyErr == nil && len(certData) > 0 && len(keyData) > 0 {
-				if clientCert, err := tls.X509KeyPair(certData, keyData); err == nil {
-					customTLSConfig.Certificates = []tls.Certificate{clientCert}
+		} else {
+			// Fall back to default Kubernetes CA only in K8s environment
+			k8sClient := k8s.NewProvider(configPkg.IsForceStandaloneMode())
+			if k8sClient.IsInitialized() {
+				if cert, err := loadKubernetesCACert(); err == nil {
+					rootCAs.AddCert(cert)
 					if configPkg.IsDebugEnabled() {
-						logutil.Debugf("HTTP_CLIENT", "Successfully configured client certificate authentication")
+						logutil.Debugf("HTTP_CLIENT", "Added default Kubernetes CA cert to root CA pool")
 					}
 				} else {
 					if configPkg.IsDebugEnabled() {
-						logutil.Debugf("HTTP_CLIENT", "Failed to create client certificate pair: %v", err)
+						logutil.Debugf("HTTP_CLIENT", "Failed to load default Kubernetes CA cert: %v", err)
 					}
 				}
 			} else {
 				if configPkg.IsDebugEnabled() {
-					logutil.Debugf("HTTP_CLIENT", "Failed to load client certificate or key: certErr=%v, keyErr=%v", certErr, keyErr)
+					logutil.Debugf("HTTP_CLIENT", "Not in Kubernetes environment, using system CA pool only")
 				}
 			}
 		}
 
-		transport := &http.Transport{
-			TLSClientConfig: customTLSConfig,
-		}
+		customTLSConfig.RootCAs = rootCAs
+	}
 
-		// Create a new client with the custom transport and timeout
-		client = &http.Client{
-			Timeout:   effectiveTimeout,
-			Transport: transport,
+	// Configure client certificate authentication (mTLS).
+	// This is independent of InsecureSkipVerify: presenting our client
+	// certificate to the server is unrelated to whether we verify the
+	// server's certificate. Targets such as etcd require a client cert
+	// even when server verification is skipped.
+	if (tlsConfig.CertFile != "" && tlsConfig.KeyFile != "") || (tlsConfig.CertSecret != nil && tlsConfig.KeySecret != nil) {
+		var certData, keyData []byte
+		var certErr, keyErr error
+
+		if tlsConfig.CertFile != "" && tlsConfig.KeyFile != "" {
+			// Load client cert and key from files
+			certData, certErr = loadCertificateFromFile(tlsConfig.CertFile)
+			keyData, keyErr = loadCertificateFromFile(tlsConfig.KeyFile)
+			if configPkg.IsDebugEnabled() {
+				logutil.Debugf("HTTP_CLIENT", "Loading client certificate from files: cert=%s, key=%s", tlsConfig.CertFile, tlsConfig.KeyFile)
+			}
+		} else if tlsConfig.CertSecret != nil && tlsConfig.KeySecret != nil {
+			// Load client cert and key from secrets
+			certData, certErr = loadCertificateFromSecret(tlsConfig.CertSecret)
+			keyData, keyErr = loadCertificateFromSecret(tlsConfig.KeySecret)
+			if configPkg.IsDebugEnabled() {
+				logutil.Debugf("HTTP_CLIENT", "Loading client certificate from secrets: cert=%s/%s, key=%s/%s",
+					tlsConfig.CertSecret.Name, tlsConfig.CertSecret.Key, tlsConfig.KeySecret.Name, tlsConfig.KeySecret.Key)
+			}
 		}
-	} else if timeout != 0 {
-		// Create a new client with custom timeout but default transport
-		client = &http.Client{
-			Timeout: effectiveTimeout,
+
+		if certErr == nil && keyErr == nil && len(certData) > 0 && len(keyData) > 0 {
+			if clientCert, err := tls.X509KeyPair(certData, keyData); err == nil {
+				customTLSConfig.Certificates = []tls.Certificate{clientCert}
+				if configPkg.IsDebugEnabled() {
+					logutil.Debugf("HTTP_CLIENT", "Successfully configured client certificate authentication")
+				}
+			} else {
+				if configPkg.IsDebugEnabled() {
+					logutil.Debugf("HTTP_CLIENT", "Failed to create client certificate pair: %v", err)
+				}
+			}
+		} else {
+			if configPkg.IsDebugEnabled() {
+				logutil.Debugf("HTTP_CLIENT", "Failed to load client certificate or key: certErr=%v, keyErr=%v", certErr, keyErr)
+			}
 		}
 	}
 
+	return customTLSConfig
+}
+
+// setScrapeHeaders sets the content negotiation and agent headers of a scrape request.
+// Headers are applied last, so they override the defaults.
+func setScrapeHeaders(req *http.Request, headers map[string]string) {
+	// Content negotiation. By default the agent keeps requesting application/json
+	// (legacy behavior). When protobuf scraping is enabled the agent advertises a
+	// prioritized Accept list so native-histogram-capable targets can respond with
+	// the Prometheus protobuf format, while still allowing OpenMetrics/text and a
+	// wildcard fallback for targets that do not support protobuf.
+	if configPkg.GetBoolWithDefault("openagent_enable_protobuf", false) {
+		req.Header.Set("Accept", ProtobufAcceptHeader)
+	} else {
+		req.Header.Set("Accept", "application/json")
+	}
+
+	// Identify the agent in exporter access logs and request compressed payloads.
+	// Setting Accept-Encoding explicitly disables the transport's transparent
+	// decompression, so gzip responses are decoded in do.
+	req.Header.Set("User-Agent", DefaultUserAgent)
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+}
+
+// do executes a scrape request and reads the (possibly gzip-encoded) response body
+func (c *HTTPClient) do(client *http.Client, req *http.Request, timeouts Timeouts) ([]byte, string, ResponseStats, error) {
+	var stats ResponseStats
+
+	if err := setSecretParams(req); err != nil {
+		return nil, "", stats, err
+	}
+
 	// Log the request start time if debug is enabled
 	startTime := time.Now()
 	if configPkg.IsDebugEnabled() {
-		logutil.Debugf("HTTP_CLIENT", "Sending HTTP request to %s", formattedURL)
+		logutil.Debugf("HTTP_CLIENT", "Sending HTTP request to %s", redactedURL(req))
 	}
 
 	resp, err := client.Do(req)
 	if err != nil {
+		err = redactRequestError(err, req)
 		if configPkg.IsDebugEnabled() {
 			logutil.Debugf("HTTP_CLIENT", "HTTP request failed: %v", err)
 		}
-		return nil, "", fmt.Errorf("error executing request: %v", err)
+		err = annotateTLSError(classifyRequestError(err, timeouts), req.URL, tlsServerName(client))
+		return nil, "", stats, fmt.Errorf("error executing request: %w", err)
 	}
 	defer resp.Body.Close()
 
@@ -581,12 +688,31 @@ func (c *HTTPClient) ExecuteGetWithAuthResponse(targetURL string, tlsConfig *TLS
 		logutil.Debugf("HTTP_CLIENT", "Response Headers: %v", resp.Header)
 	}
 
-	body, err := ioutil.ReadAll(resp.Body)
+	// A reused connection reports the certificate of the handshake that opened it
+	if resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 {
+		stats.CertNotAfter = resp.TLS.PeerCertificates[0].NotAfter
+	}
+
+	// Count the body as received, before decompression
+	wire := &countingReader{r: resp.Body}
+	var reader io.Reader = wire
+	if strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") && !resp.Uncompressed {
+		gz, err := gzip.NewReader(wire)
+		if err != nil {
+			stats.WireBytes = wire.n
+			return nil, "", stats, fmt.Errorf("error decompressing response body: %v", err)
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	body, err := ioutil.ReadAll(reader)
+	stats.WireBytes, stats.BodyBytes = wire.n, int64(len(body))
 	if err != nil {
 		if configPkg.IsDebugEnabled() {
 			logutil.Debugf("HTTP_CLIENT", "Error reading response body: %v", err)
 		}
-		return nil, "", fmt.Errorf("error reading response body: %v", err)
+		return nil, "", stats, fmt.Errorf("error reading response body: %w", classifyBodyError(err, timeouts))
 	}
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
@@ -594,7 +720,11 @@ func (c *HTTPClient) ExecuteGetWithAuthResponse(targetURL string, tlsConfig *TLS
 			logutil.Debugf("HTTP_CLIENT", "HTTP error: %d %s", resp.StatusCode, resp.Status)
 			logutil.Debugf("HTTP_CLIENT", "Response body: %s", string(body))
 		}
-		return nil, "", fmt.Errorf("HTTP error: %d %s", resp.StatusCode, resp.Status)
+		// A rate-limited target tells when to scrape it again
+		if err := retryAfterError(resp, time.Now()); err != nil {
+			return nil, "", stats, err
+		}
+		return nil, "", stats, fmt.Errorf("HTTP error: %d %s", resp.StatusCode, resp.Status)
 	}
 
 	// Log the response body length if debug is enabled
@@ -608,5 +738,5 @@ func (c *HTTPClient) ExecuteGetWithAuthResponse(targetURL string, tlsConfig *TLS
 		logutil.Debugf("HTTP_CLIENT", "Response body preview: %s", preview)
 	}
 
-	return body, resp.Header.Get("Content-Type"), nil
+	return body, resp.Header.Get("Content-Type"), stats, nil
 }
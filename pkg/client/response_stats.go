@@ -0,0 +1,41 @@
+package client
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// ResponseStats reports the size of a scrape response
+type ResponseStats struct {
+	WireBytes int64 // response body bytes as received, compressed if the target used gzip
+	BodyBytes int64 // response body bytes after decoding
+	// CertNotAfter is the expiry of the leaf certificate of an https response, zero for plain http.
+	// It is set whether or not the certificate was verified.
+	CertNotAfter time.Time
+}
+
+type closeConnectionKey struct{}
+
+// WithCloseConnection returns a context whose request closes its connection after the response, so
+// the next request makes a new connection and a new TLS handshake
+func WithCloseConnection(ctx context.Context) context.Context {
+	return context.WithValue(ctx, closeConnectionKey{}, true)
+}
+
+func closeConnection(ctx context.Context) bool {
+	closeConn, _ := ctx.Value(closeConnectionKey{}).(bool)
+	return closeConn
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
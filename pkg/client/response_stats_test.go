@@ -0,0 +1,46 @@
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestExecuteGetWithStats_IdentityAndGzip(t *testing.T) {
+	plain := []byte(strings.Repeat("http_requests_total{code=\"200\",method=\"GET\"} 1027\n", 200))
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	gz.Write(plain)
+	gz.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/gzip" {
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Write(compressed.Bytes())
+			return
+		}
+		w.Write(plain)
+	}))
+	defer server.Close()
+
+	c := &HTTPClient{client: &http.Client{}}
+
+	body, _, stats, err := c.ExecuteGetWithStats(server.URL+"/identity", nil, nil, nil, Timeouts{})
+	if err != nil || !bytes.Equal(body, plain) {
+		t.Fatalf("identity: unexpected body (%d bytes), %v", len(body), err)
+	}
+	if stats.WireBytes != int64(len(plain)) || stats.BodyBytes != int64(len(plain)) {
+		t.Errorf("identity: expected %d/%d bytes, got %+v", len(plain), len(plain), stats)
+	}
+
+	body, _, stats, err = c.ExecuteGetWithStats(server.URL+"/gzip", nil, nil, nil, Timeouts{})
+	if err != nil || !bytes.Equal(body, plain) {
+		t.Fatalf("gzip: unexpected body (%d bytes), %v", len(body), err)
+	}
+	if stats.WireBytes != int64(compressed.Len()) || stats.BodyBytes != int64(len(plain)) {
+		t.Errorf("gzip: expected %d/%d bytes, got %+v", compressed.Len(), len(plain), stats)
+	}
+}
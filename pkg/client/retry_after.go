@@ -0,0 +1,57 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetryAfterError is a 429 Too Many Requests, or a 503 Service Unavailable with a Retry-After header,
+// from a rate-limited target. RetryAfter is when the target asked to be scraped again, zero for a 429
+// without a valid Retry-After.
+type RetryAfterError struct {
+	StatusCode int
+	Status     string
+	RetryAfter time.Time
+}
+
+func (e *RetryAfterError) Error() string {
+	if e.RetryAfter.IsZero() {
+		return fmt.Sprintf("HTTP error: %d %s", e.StatusCode, e.Status)
+	}
+	return fmt.Sprintf("HTTP error: %d %s (retry after %s)", e.StatusCode, e.Status, e.RetryAfter.Format(time.RFC3339))
+}
+
+// retryAfterError returns the error of a rate-limited response, nil for other responses
+func retryAfterError(resp *http.Response, now time.Time) error {
+	retryAfter, ok := ParseRetryAfter(resp.Header.Get("Retry-After"), now)
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+	case resp.StatusCode == http.StatusServiceUnavailable && ok:
+	default:
+		return nil
+	}
+	return &RetryAfterError{StatusCode: resp.StatusCode, Status: resp.Status, RetryAfter: retryAfter}
+}
+
+// ParseRetryAfter parses a Retry-After header, either delay-seconds or an HTTP-date, into the time
+// the request may be retried
+func ParseRetryAfter(value string, now time.Time) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, false
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if seconds < 0 {
+			return time.Time{}, false
+		}
+		return now.Add(time.Duration(seconds) * time.Second), true
+	}
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return date, true
+}
@@ -0,0 +1,66 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	for value, want := range map[string]time.Time{
+		"120":                            now.Add(2 * time.Minute),
+		" 0 ":                            now,
+		"Fri, 16 Oct 2026 09:05:00 GMT":  now.Add(5 * time.Minute),
+		"Friday, 16-Oct-26 09:05:00 GMT": now.Add(5 * time.Minute), // RFC 850
+	} {
+		if got, ok := ParseRetryAfter(value, now); !ok || !got.Equal(want) {
+			t.Errorf("ParseRetryAfter(%q) = %v, %v, want %v", value, got, ok, want)
+		}
+	}
+	for _, value := range []string{"", "-5", "1.5", "soon"} {
+		if got, ok := ParseRetryAfter(value, now); ok {
+			t.Errorf("ParseRetryAfter(%q) = %v, expected it rejected", value, got)
+		}
+	}
+}
+
+func TestExecuteGet_RateLimitedResponses(t *testing.T) {
+	var status int
+	var retryAfter string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if retryAfter != "" {
+			w.Header().Set("Retry-After", retryAfter)
+		}
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	scrape := func(code int, header string) error {
+		status, retryAfter = code, header
+		_, err := GetInstance().ExecuteGetWithAuth(srv.URL+"/metrics", nil, nil, 5*time.Second)
+		return err
+	}
+
+	before := time.Now()
+	var rateLimited *RetryAfterError
+	if err := scrape(http.StatusTooManyRequests, "30"); !errors.As(err, &rateLimited) ||
+		rateLimited.RetryAfter.Before(before.Add(30*time.Second)) || rateLimited.RetryAfter.After(time.Now().Add(30*time.Second)) {
+		t.Fatalf("expected a 429 retrying in 30s, got %v", err)
+	}
+
+	date := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	if err := scrape(http.StatusServiceUnavailable, date.Format(http.TimeFormat)); !errors.As(err, &rateLimited) || !rateLimited.RetryAfter.Equal(date) {
+		t.Fatalf("expected a 503 retrying at %v, got %v", date, err)
+	}
+
+	// A 429 without Retry-After is still rate limiting, a 503 without it is a plain failure
+	if err := scrape(http.StatusTooManyRequests, ""); !errors.As(err, &rateLimited) || !rateLimited.RetryAfter.IsZero() {
+		t.Errorf("expected a 429 without a retry time, got %v", err)
+	}
+	if err := scrape(http.StatusServiceUnavailable, ""); err == nil || errors.As(err, &rateLimited) {
+		t.Errorf("expected a generic HTTP error, got %v", err)
+	}
+}
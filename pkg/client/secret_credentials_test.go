@@ -0,0 +1,182 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+
+	configPkg "open-agent/pkg/config"
+	"open-agent/pkg/k8s"
+)
+
+// informerSecretProvider reads Secrets from an informer on a fake clientset, like K8sClient does
+type informerSecretProvider struct {
+	k8s.NoopK8sProvider
+	informer cache.SharedIndexInformer
+}
+
+func (p *informerSecretProvider) IsInitialized() bool { return true }
+
+func (p *informerSecretProvider) GetSecret(namespace, name string) (*corev1.Secret, error) {
+	obj, exists, err := p.informer.GetStore().GetByKey(namespace + "/" + name)
+	if err != nil || !exists {
+		return nil, fmt.Errorf("secret %s/%s not found", namespace, name)
+	}
+	return obj.(*corev1.Secret), nil
+}
+
+func (p *informerSecretProvider) RegisterSecretHandler(handler func(*corev1.Secret)) {
+	p.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(_, newObj interface{}) { handler(newObj.(*corev1.Secret)) },
+	})
+}
+
+// useFakeSecrets makes the client read Secrets from an informer on a fake clientset holding secrets
+func useFakeSecrets(t *testing.T, secrets ...*corev1.Secret) *fake.Clientset {
+	t.Helper()
+	objects := make([]runtime.Object, 0, len(secrets))
+	for _, secret := range secrets {
+		objects = append(objects, secret)
+	}
+	clientset := fake.NewSimpleClientset(objects...)
+	factory := informers.NewSharedInformerFactory(clientset, 0)
+	provider := &informerSecretProvider{informer: factory.Core().V1().Secrets().Informer()}
+	stopCh := make(chan struct{})
+	factory.Start(stopCh)
+	if !cache.WaitForCacheSync(stopCh, provider.informer.HasSynced) {
+		t.Fatal("informer did not sync")
+	}
+
+	previousProvider, previousTransports := k8sProvider, tlsTransports
+	k8sProvider = func() k8s.K8sProvider { return provider }
+	tlsTransports = newTLSTransportCache()
+	t.Cleanup(func() {
+		close(stopCh)
+		k8sProvider, tlsTransports = previousProvider, previousTransports
+	})
+	return clientset
+}
+
+func testSecret(name string, data map[string][]byte) *corev1.Secret {
+	return &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "monitoring", Name: name}, Data: data}
+}
+
+// updateSecret changes a Secret through the API and waits for the informer to deliver it
+func updateSecret(t *testing.T, clientset *fake.Clientset, secret *corev1.Secret, delivered func() bool) {
+	t.Helper()
+	if _, err := clientset.CoreV1().Secrets(secret.Namespace).Update(context.Background(), secret, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("update secret: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for !delivered() {
+		if time.Now().After(deadline) {
+			t.Fatalf("secret update was not delivered")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSecretBasicAuth_NextScrapeUsesRotatedPassword(t *testing.T) {
+	clientset := useFakeSecrets(t, testSecret("exporter-auth", map[string][]byte{"password": []byte("old-pass")}))
+
+	var lastPassword atomic.Value
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, password, _ := r.BasicAuth()
+		lastPassword.Store(password)
+		_, _ = w.Write([]byte("up 1\n"))
+	}))
+	defer srv.Close()
+
+	basicAuth := &configPkg.BasicAuthConfig{
+		Username: &configPkg.SecretKeySelector{Value: "scraper"},
+		Password: &configPkg.SecretKeySelector{Namespace: "monitoring", Name: "exporter-auth", Key: "password"},
+	}
+	scrape := func() string {
+		if _, err := GetInstance().ExecuteGetWithAuth(srv.URL+"/metrics", nil, basicAuth, 5*time.Second); err != nil {
+			t.Fatalf("scrape: %v", err)
+		}
+		return lastPassword.Load().(string)
+	}
+
+	if got := scrape(); got != "old-pass" {
+		t.Fatalf("expected the password from the Secret, got %q", got)
+	}
+	updateSecret(t, clientset, testSecret("exporter-auth", map[string][]byte{"password": []byte("new-pass")}), func() bool {
+		secret, err := k8sProvider().GetSecret("monitoring", "exporter-auth")
+		return err == nil && string(secret.Data["password"]) == "new-pass"
+	})
+	if got := scrape(); got != "new-pass" {
+		t.Errorf("expected the next scrape to use the rotated password, got %q", got)
+	}
+}
+
+func TestSecretTLSTransport_RebuiltWhenSecretChanges(t *testing.T) {
+	oldCA, oldKey := mustGenCA(t)
+	newCA, newKey := mustGenCA(t)
+	oldServer := mustGenLeaf(t, oldCA, oldKey, "localhost", true)
+	newServer := mustGenLeaf(t, newCA, newKey, "localhost", true)
+	caSecret := func(ca []byte) *corev1.Secret {
+		return testSecret("exporter-ca", map[string][]byte{"ca.crt": ca})
+	}
+	oldPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: oldCA.Raw})
+	newPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: newCA.Raw})
+	clientset := useFakeSecrets(t, caSecret(oldPEM))
+
+	var serving atomic.Value
+	serving.Store(&oldServer.cert)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("up 1\n"))
+	}))
+	srv.TLS = &tls.Config{
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return serving.Load().(*tls.Certificate), nil
+		},
+	}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	tlsConfig := &TLSConfig{ServerName: "localhost", CASecret: &configPkg.SecretKeySelector{Namespace: "monitoring", Name: "exporter-ca", Key: "ca.crt"}}
+	timeouts := Timeouts{Overall: 5 * time.Second}.withDefaults()
+	scrape := func() error {
+		_, err := GetInstance().ExecuteGetWithAuth(srv.URL+"/metrics", tlsConfig, nil, 5*time.Second)
+		return err
+	}
+
+	if err := scrape(); err != nil {
+		t.Fatalf("scrape before rotation: %v", err)
+	}
+	// The transport is reused across scrapes instead of being built for every request
+	transport := tlsTransports.get(tlsConfig, timeouts)
+	if err := scrape(); err != nil || tlsTransports.get(tlsConfig, timeouts) != transport {
+		t.Fatalf("expected the Secret-backed transport to be reused, err=%v", err)
+	}
+
+	// Rotate the server certificate and the CA in the Secret
+	serving.Store(&newServer.cert)
+	srv.CloseClientConnections()
+	updateSecret(t, clientset, caSecret(newPEM), func() bool {
+		tlsTransports.mu.Lock()
+		defer tlsTransports.mu.Unlock()
+		return len(tlsTransports.entries) == 0
+	})
+
+	if err := scrape(); err != nil {
+		t.Fatalf("scrape after rotation: %v", err)
+	}
+	if tlsTransports.get(tlsConfig, timeouts) == transport {
+		t.Errorf("expected the transport to be rebuilt")
+	}
+}
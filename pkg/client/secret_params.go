@@ -0,0 +1,77 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	configPkg "open-agent/pkg/config"
+)
+
+type secretParamsKey struct{}
+
+// WithSecretParams returns a context whose request sets the given query params to the current value
+// of their Secret key. The URL passed to the client holds a placeholder for them, so the value never
+// appears in the target URL, and it is read on every request, so a rotated Secret is used by the next
+// scrape.
+func WithSecretParams(ctx context.Context, params map[string]configPkg.SecretKeySelector) context.Context {
+	if len(params) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, secretParamsKey{}, params)
+}
+
+func secretParams(ctx context.Context) map[string]configPkg.SecretKeySelector {
+	params, _ := ctx.Value(secretParamsKey{}).(map[string]configPkg.SecretKeySelector)
+	return params
+}
+
+// setSecretParams puts the Secret values of the request's secret params into its query. A param whose
+// Secret cannot be read fails the request rather than sending the placeholder.
+func setSecretParams(req *http.Request) error {
+	params := secretParams(req.Context())
+	if len(params) == 0 {
+		return nil
+	}
+	query := req.URL.Query()
+	for name, selector := range params {
+		selector := selector
+		value, err := resolveSecretString(&selector)
+		if err != nil {
+			return fmt.Errorf("failed to resolve param %s: %v", name, err)
+		}
+		query.Set(name, value)
+	}
+	req.URL.RawQuery = query.Encode()
+	return nil
+}
+
+// redactedURL returns the request URL with the values of its secret params replaced by the placeholder,
+// for logs and errors
+func redactedURL(req *http.Request) string {
+	params := secretParams(req.Context())
+	if len(params) == 0 {
+		return req.URL.String()
+	}
+	u := *req.URL
+	query := u.Query()
+	for name := range params {
+		if _, ok := query[name]; ok {
+			query.Set(name, configPkg.RedactedParam)
+		}
+	}
+	u.RawQuery = strings.ReplaceAll(query.Encode(), "=%2A%2A%2A", "="+configPkg.RedactedParam)
+	return u.String()
+}
+
+// redactRequestError replaces the URL in an error of http.Client.Do, which holds the secret param values
+func redactRequestError(err error, req *http.Request) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		urlErr.URL = redactedURL(req)
+	}
+	return err
+}
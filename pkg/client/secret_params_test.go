@@ -0,0 +1,95 @@
+package client
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	configPkg "open-agent/pkg/config"
+)
+
+func TestSecretParams_NextScrapeUsesRotatedKey(t *testing.T) {
+	clientset := useFakeSecrets(t, testSecret("exporter-key", map[string][]byte{"api-key": []byte("key-monday\n")}))
+
+	var lastQuery atomic.Value
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lastQuery.Store(r.URL.Query())
+		_, _ = w.Write([]byte("up 1\n"))
+	}))
+	defer srv.Close()
+
+	ctx := WithSecretParams(context.Background(), map[string]configPkg.SecretKeySelector{
+		"api_key": {Namespace: "monitoring", Name: "exporter-key", Key: "api-key"},
+	})
+	scrape := func() (string, string) {
+		if _, _, _, err := GetInstance().ExecuteGetWithStatsContext(ctx, srv.URL+"/metrics?api_key=***&module=http_2xx", nil, nil, nil, Timeouts{}); err != nil {
+			t.Fatalf("scrape: %v", err)
+		}
+		query := lastQuery.Load().(url.Values)
+		return query.Get("api_key"), query.Get("module")
+	}
+
+	if key, module := scrape(); key != "key-monday" || module != "http_2xx" {
+		t.Fatalf("expected the key from the Secret and the literal param, got api_key=%q module=%q", key, module)
+	}
+	updateSecret(t, clientset, testSecret("exporter-key", map[string][]byte{"api-key": []byte("key-tuesday")}), func() bool {
+		secret, err := k8sProvider().GetSecret("monitoring", "exporter-key")
+		return err == nil && string(secret.Data["api-key"]) == "key-tuesday"
+	})
+	if key, _ := scrape(); key != "key-tuesday" {
+		t.Errorf("expected the next scrape to use the rotated key, got %q", key)
+	}
+}
+
+func TestSecretParams_ErrorsDoNotContainKey(t *testing.T) {
+	useFakeSecrets(t, testSecret("exporter-key", map[string][]byte{"api-key": []byte("s3cr3t-key")}))
+
+	// Nothing listens on the port once the listener is closed
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	ctx := WithSecretParams(context.Background(), map[string]configPkg.SecretKeySelector{
+		"api_key": {Namespace: "monitoring", Name: "exporter-key", Key: "api-key"},
+	})
+	_, _, _, err = GetInstance().ExecuteGetWithStatsContext(ctx, "http://"+addr+"/metrics?api_key=***", nil, nil, nil, Timeouts{Overall: time.Second})
+	if err == nil {
+		t.Fatal("expected a connection error")
+	}
+	if strings.Contains(err.Error(), "s3cr3t-key") {
+		t.Errorf("error contains the secret param value: %v", err)
+	}
+	if !strings.Contains(err.Error(), "api_key=***") {
+		t.Errorf("expected the redacted URL in the error, got %v", err)
+	}
+}
+
+func TestSecretParams_MissingSecretFailsScrape(t *testing.T) {
+	useFakeSecrets(t)
+
+	var requests atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+	}))
+	defer srv.Close()
+
+	ctx := WithSecretParams(context.Background(), map[string]configPkg.SecretKeySelector{
+		"api_key": {Namespace: "monitoring", Name: "exporter-key", Key: "api-key"},
+	})
+	_, _, _, err := GetInstance().ExecuteGetWithStatsContext(ctx, srv.URL+"/metrics?api_key=***", nil, nil, nil, Timeouts{})
+	if err == nil || !strings.Contains(err.Error(), "failed to resolve param api_key") {
+		t.Errorf("expected a resolve error, got %v", err)
+	}
+	if requests.Load() != 0 {
+		t.Errorf("the placeholder must not be sent, got %d requests", requests.Load())
+	}
+}
@@ -0,0 +1,123 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultConnectTimeout bounds dialing and the TLS handshake, so dead IPs fail fast
+	DefaultConnectTimeout = 5 * time.Second
+
+	// DefaultScrapeTimeout bounds the whole request including reading the body
+	DefaultScrapeTimeout = 10 * time.Second
+)
+
+// Request phases reported by TimeoutError
+const (
+	PhaseConnect        = "connect"
+	PhaseTLSHandshake   = "tls handshake"
+	PhaseResponseHeader = "response header"
+	PhaseBody           = "body read"
+)
+
+// Timeouts splits a scrape into phases that time out independently
+type Timeouts struct {
+	Connect time.Duration // dial and TLS handshake (default DefaultConnectTimeout)
+	Read    time.Duration // waiting for response headers once the request is sent (0: bounded by Overall only)
+	Overall time.Duration // the whole request including the body (default DefaultScrapeTimeout)
+}
+
+func (t Timeouts) withDefaults() Timeouts {
+	if t.Connect <= 0 {
+		t.Connect = DefaultConnectTimeout
+	}
+	if t.Overall <= 0 {
+		t.Overall = DefaultScrapeTimeout
+	}
+	return t
+}
+
+// TimeoutError reports which phase of a request timed out
+type TimeoutError struct {
+	Phase   string
+	Timeout time.Duration
+	Err     error
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("%s timeout after %v: %v", e.Phase, e.Timeout, e.Err)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// applyTimeouts sets the connect and read phase timeouts on a transport, which resolves host names
+// through the scrape DNS cache
+func applyTimeouts(transport *http.Transport, t Timeouts) {
+	dialer := &net.Dialer{Timeout: t.Connect, KeepAlive: 30 * time.Second}
+	transport.DialContext = scrapeDNS.dialContext(dialer.DialContext)
+	transport.TLSHandshakeTimeout = t.Connect
+	transport.ResponseHeaderTimeout = t.Read
+}
+
+// transports caches transports without custom TLS per connect/read timeout pair,
+// so idle connections are reused across scrapes
+var transports sync.Map
+
+// transportFor returns a shared transport based on the client's default transport
+func (c *HTTPClient) transportFor(t Timeouts) *http.Transport {
+	key := fmt.Sprintf("%d/%d", t.Connect, t.Read)
+	if cached, ok := transports.Load(key); ok {
+		return cached.(*http.Transport)
+	}
+
+	var transport *http.Transport
+	if base, ok := c.client.Transport.(*http.Transport); ok {
+		transport = base.Clone()
+	} else {
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	}
+	applyTimeouts(transport, t)
+
+	actual, _ := transports.LoadOrStore(key, transport)
+	return actual.(*http.Transport)
+}
+
+// classifyRequestError wraps a timeout returned by http.Client.Do in a TimeoutError naming the phase
+func classifyRequestError(err error, t Timeouts) error {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "TLS handshake timeout"):
+		return &TimeoutError{Phase: PhaseTLSHandshake, Timeout: t.Connect, Err: err}
+	case strings.Contains(msg, "timeout awaiting response headers"):
+		return &TimeoutError{Phase: PhaseResponseHeader, Timeout: t.Read, Err: err}
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" && opErr.Timeout() {
+		return &TimeoutError{Phase: PhaseConnect, Timeout: t.Connect, Err: err}
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		// The overall timeout fired before the response headers arrived
+		return &TimeoutError{Phase: PhaseResponseHeader, Timeout: t.Overall, Err: err}
+	}
+	return err
+}
+
+// classifyBodyError wraps a timeout while reading the response body
+func classifyBodyError(err error, t Timeouts) error {
+	var netErr net.Error
+	if (errors.As(err, &netErr) && netErr.Timeout()) || strings.Contains(err.Error(), "Client.Timeout") {
+		return &TimeoutError{Phase: PhaseBody, Timeout: t.Overall, Err: err}
+	}
+	return err
+}
@@ -0,0 +1,108 @@
+package client
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func expectTimeoutPhase(t *testing.T, err error, phase string) {
+	t.Helper()
+	var timeoutErr *TimeoutError
+	if !errors.As(err, &timeoutErr) {
+		t.Fatalf("expected a TimeoutError for phase %q, got %v", phase, err)
+	}
+	if timeoutErr.Phase != phase {
+		t.Fatalf("expected phase %q, got %q (%v)", phase, timeoutErr.Phase, err)
+	}
+}
+
+// TestTimeouts_HandshakeNeverCompletes uses a listener that accepts TCP but never answers the TLS handshake
+func TestTimeouts_HandshakeNeverCompletes(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	accepted := make(chan net.Conn, 4)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- conn
+		}
+	}()
+	defer func() {
+		ln.Close()
+		for {
+			select {
+			case conn := <-accepted:
+				conn.Close()
+			default:
+				return
+			}
+		}
+	}()
+
+	c := &HTTPClient{client: &http.Client{}}
+	start := time.Now()
+	_, _, err = c.ExecuteGetWithTimeoutsResponse("https://"+ln.Addr().String()+"/metrics",
+		&TLSConfig{InsecureSkipVerify: true}, nil, nil, Timeouts{Connect: 200 * time.Millisecond, Overall: 5 * time.Second})
+	expectTimeoutPhase(t, err, PhaseTLSHandshake)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("connect timeout should fail fast, took %v", elapsed)
+	}
+}
+
+func TestTimeouts_SlowHeadersVsSlowBody(t *testing.T) {
+	slowHeaders := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Second)
+		w.Write([]byte("up 1\n"))
+	}))
+	defer slowHeaders.Close()
+
+	slowBody := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		time.Sleep(700 * time.Millisecond)
+		w.Write([]byte("up 1\n"))
+	}))
+	defer slowBody.Close()
+
+	c := &HTTPClient{client: &http.Client{}}
+
+	_, _, err := c.ExecuteGetWithTimeoutsResponse(slowHeaders.URL, nil, nil, nil,
+		Timeouts{Connect: time.Second, Read: 200 * time.Millisecond, Overall: 5 * time.Second})
+	expectTimeoutPhase(t, err, PhaseResponseHeader)
+
+	_, _, err = c.ExecuteGetWithTimeoutsResponse(slowBody.URL, nil, nil, nil,
+		Timeouts{Connect: time.Second, Overall: 300 * time.Millisecond})
+	expectTimeoutPhase(t, err, PhaseBody)
+
+	// A tight connect timeout does not cut off an exporter that is slow to stream its body
+	body, _, err := c.ExecuteGetWithTimeoutsResponse(slowBody.URL, nil, nil, nil,
+		Timeouts{Connect: 200 * time.Millisecond, Read: time.Second, Overall: 3 * time.Second})
+	if err != nil || string(body) != "up 1\n" {
+		t.Fatalf("expected slow body to be read, got %q, %v", body, err)
+	}
+}
+
+type timeoutNetError struct{}
+
+func (timeoutNetError) Error() string   { return "i/o timeout" }
+func (timeoutNetError) Timeout() bool   { return true }
+func (timeoutNetError) Temporary() bool { return true }
+
+func TestClassifyRequestError_Dial(t *testing.T) {
+	err := &net.OpError{Op: "dial", Net: "tcp", Err: timeoutNetError{}}
+	expectTimeoutPhase(t, classifyRequestError(err, Timeouts{Connect: time.Second}), PhaseConnect)
+
+	other := errors.New("connection refused")
+	if classifyRequestError(other, Timeouts{}) != other {
+		t.Error("non-timeout errors must be returned unchanged")
+	}
+}
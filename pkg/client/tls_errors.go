@@ -0,0 +1,62 @@
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+)
+
+// TLSError is a failed TLS handshake with the address that was dialed and the server name (SNI) that the
+// certificate was verified against, which differ when tlsConfig.serverName is set
+type TLSError struct {
+	Address    string
+	ServerName string
+	Err        error
+}
+
+func (e *TLSError) Error() string {
+	return fmt.Sprintf("TLS handshake with %s (server name %q) failed: %v", e.Address, e.ServerName, e.Err)
+}
+
+func (e *TLSError) Unwrap() error {
+	return e.Err
+}
+
+// annotateTLSError wraps a TLS handshake or certificate verification error in a TLSError, other errors are
+// returned as is. serverName is the configured server name; without one the URL host is verified.
+func annotateTLSError(err error, u *url.URL, serverName string) error {
+	if !isTLSError(err) {
+		return err
+	}
+	address := u.Host
+	if u.Port() == "" {
+		address = net.JoinHostPort(u.Hostname(), "443")
+	}
+	if serverName == "" {
+		serverName = u.Hostname()
+	}
+	return &TLSError{Address: address, ServerName: serverName, Err: err}
+}
+
+func isTLSError(err error) bool {
+	var verifyErr *tls.CertificateVerificationError
+	var hostnameErr x509.HostnameError
+	var authorityErr x509.UnknownAuthorityError
+	var invalidErr x509.CertificateInvalidError
+	var alertErr tls.AlertError
+	var recordErr tls.RecordHeaderError
+	return errors.As(err, &verifyErr) || errors.As(err, &hostnameErr) || errors.As(err, &authorityErr) ||
+		errors.As(err, &invalidErr) || errors.As(err, &alertErr) || errors.As(err, &recordErr)
+}
+
+// tlsServerName returns the server name configured on the client's transport
+func tlsServerName(client *http.Client) string {
+	if transport, ok := client.Transport.(*http.Transport); ok && transport.TLSClientConfig != nil {
+		return transport.TLSClientConfig.ServerName
+	}
+	return ""
+}
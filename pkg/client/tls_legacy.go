@@ -0,0 +1,38 @@
+package client
+
+import (
+	"crypto/tls"
+	"strings"
+	"sync"
+
+	configPkg "open-agent/pkg/config"
+	"open-agent/tools/util/logutil"
+)
+
+// legacyTLSWarned holds the host and TLS settings pairs whose legacy TLS warning was logged
+var legacyTLSWarned sync.Map
+
+// warnLegacyTLS logs a WARN when tlsConfig enables a TLS version below 1.2 or renegotiation for host,
+// once per host and settings. These are only meant for old appliance exporters that support nothing newer.
+func warnLegacyTLS(host string, tlsConfig *TLSConfig) {
+	minVersion, _, renegotiation, err := configPkg.ValidateTLSVersions(tlsConfig.MinVersion, tlsConfig.MaxVersion, tlsConfig.Renegotiation)
+	if err != nil {
+		return
+	}
+	var enabled []string
+	if minVersion != 0 && minVersion < tls.VersionTLS12 {
+		enabled = append(enabled, "minVersion "+tlsConfig.MinVersion)
+	}
+	if renegotiation != tls.RenegotiateNever {
+		enabled = append(enabled, "renegotiation "+tlsConfig.Renegotiation)
+	}
+	if len(enabled) == 0 {
+		return
+	}
+	settings := strings.Join(enabled, ", ")
+	if _, warned := legacyTLSWarned.LoadOrStore(host+"|"+settings, true); warned {
+		return
+	}
+	logutil.Printf("WARN", "[HTTP_CLIENT] Insecure legacy TLS enabled for %s: %s. These protocols have known weaknesses, "+
+		"set openagent_tls_forbid_legacy=true to forbid them", host, settings)
+}
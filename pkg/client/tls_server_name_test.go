@@ -0,0 +1,114 @@
+package client
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startNamedTLSServer starts an HTTPS server whose certificate only carries dnsName, like an exporter with a
+// service DNS SAN that is scraped by pod IP. It returns the server and the CA file that signed the certificate.
+func startNamedTLSServer(t *testing.T, dnsName string) (*httptest.Server, string) {
+	t.Helper()
+	ca, caKey := mustGenCA(t)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("leaf key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: dnsName},
+		NotBefore:    time.Unix(0, 0),
+		NotAfter:     time.Unix(1<<31-1, 0),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     []string{dnsName},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("leaf cert: %v", err)
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("exporter_up 1\n"))
+	}))
+	srv.TLS = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	caFile := writeFile(t, t.TempDir(), "ca.crt", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw}))
+	return srv, caFile
+}
+
+func TestServerName_VerifiesConfiguredNameWhileDialingIP(t *testing.T) {
+	srv, caFile := startNamedTLSServer(t, "exporter.monitoring.svc")
+
+	body, err := GetInstance().ExecuteGetWithAuth(srv.URL+"/metrics", &TLSConfig{
+		ServerName: "exporter.monitoring.svc",
+		CAFile:     caFile,
+	}, nil, 5*time.Second)
+	if err != nil {
+		t.Fatalf("expected verification against the configured server name to succeed, got %v", err)
+	}
+	if !strings.Contains(body, "exporter_up") {
+		t.Errorf("unexpected body %q", body)
+	}
+}
+
+func TestServerName_FailureNamesAddressAndSNI(t *testing.T) {
+	srv, caFile := startNamedTLSServer(t, "exporter.monitoring.svc")
+	address := strings.TrimPrefix(srv.URL, "https://")
+
+	tests := []struct {
+		name       string
+		serverName string
+		wantSNI    string
+	}{
+		{"no server name verifies the IP", "", "127.0.0.1"},
+		{"wrong server name", "other.monitoring.svc", "other.monitoring.svc"},
+	}
+	for _, tt := range tests {
+		_, err := GetInstance().ExecuteGetWithAuth(srv.URL+"/metrics", &TLSConfig{
+			ServerName: tt.serverName,
+			CAFile:     caFile,
+		}, nil, 5*time.Second)
+		if err == nil {
+			t.Fatalf("%s: expected certificate verification to fail", tt.name)
+		}
+		var tlsErr *TLSError
+		if !errors.As(err, &tlsErr) {
+			t.Fatalf("%s: expected a TLSError, got %v", tt.name, err)
+		}
+		if tlsErr.Address != address || tlsErr.ServerName != tt.wantSNI {
+			t.Errorf("%s: got address %q server name %q, want %q and %q", tt.name, tlsErr.Address, tlsErr.ServerName, address, tt.wantSNI)
+		}
+		if msg := err.Error(); !strings.Contains(msg, address) || !strings.Contains(msg, tt.wantSNI) {
+			t.Errorf("%s: error should name the dialed address and the server name: %v", tt.name, msg)
+		}
+	}
+}
+
+func TestAnnotateTLSError_LeavesOtherErrors(t *testing.T) {
+	u, _ := url.Parse("https://10.0.0.1/metrics")
+	plain := errors.New("connection refused")
+	if got := annotateTLSError(plain, u, "exporter.monitoring.svc"); got != plain {
+		t.Errorf("expected a non-TLS error to be returned as is, got %v", got)
+	}
+
+	err := annotateTLSError(x509.HostnameError{Host: "10.0.0.1"}, u, "")
+	var tlsErr *TLSError
+	if !errors.As(err, &tlsErr) || tlsErr.Address != "10.0.0.1:443" || tlsErr.ServerName != "10.0.0.1" {
+		t.Errorf("expected the default port and the URL host as server name, got %v", err)
+	}
+}
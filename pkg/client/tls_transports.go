@@ -0,0 +1,226 @@
+package client
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	configPkg "open-agent/pkg/config"
+	"open-agent/tools/util/logutil"
+)
+
+var (
+	// tlsFileCheckInterval is how often the CA, certificate and key files of cached transports are re-hashed
+	tlsFileCheckInterval = 30 * time.Second
+
+	// tlsTransportIdleTTL drops cached transports whose TLS config has not been scraped for this long
+	tlsTransportIdleTTL = time.Hour
+)
+
+// tlsTransports caches transports with a custom TLS config, so idle connections are reused across scrapes
+var tlsTransports = newTLSTransportCache()
+
+type tlsTransportEntry struct {
+	transport *http.Transport
+	files     []string
+	secrets   []string // namespace/name of the Secrets the certificates were read from
+	lastUsed  time.Time
+}
+
+// secretNotifier is implemented by the Kubernetes client, which reports Secret changes from its informer
+type secretNotifier interface {
+	RegisterSecretHandler(handler func(*corev1.Secret))
+}
+
+// tlsTransportCache keeps one transport per TLS config and connect/read timeout pair. The referenced
+// files are watched by content hash, and a change (e.g. a rotated ConfigMap-mounted CA bundle) rebuilds
+// only the transports that use the changed file. Transports built from Secrets are rebuilt when the
+// informer reports a change of one of their Secrets.
+type tlsTransportCache struct {
+	mu         sync.Mutex
+	entries    map[string]*tlsTransportEntry
+	hashes     map[string][sha256.Size]byte // file path -> content hash at the time the transports were built
+	once       sync.Once
+	secretOnce sync.Once
+}
+
+func newTLSTransportCache() *tlsTransportCache {
+	return &tlsTransportCache{
+		entries: make(map[string]*tlsTransportEntry),
+		hashes:  make(map[string][sha256.Size]byte),
+	}
+}
+
+// get returns the transport for tlsConfig
+func (c *tlsTransportCache) get(tlsConfig *TLSConfig, timeouts Timeouts) *http.Transport {
+	var secrets, secretRefs []string
+	for _, selector := range []*configPkg.SecretKeySelector{tlsConfig.CASecret, tlsConfig.CertSecret, tlsConfig.KeySecret} {
+		if selector == nil {
+			secretRefs = append(secretRefs, "")
+			continue
+		}
+		secrets = append(secrets, secretKey(selector))
+		secretRefs = append(secretRefs, secretKey(selector)+"/"+selector.Key)
+	}
+	if len(secrets) > 0 {
+		c.secretOnce.Do(func() {
+			if notifier, ok := k8sProvider().(secretNotifier); ok {
+				notifier.RegisterSecretHandler(func(secret *corev1.Secret) {
+					c.invalidateSecret(secret.Namespace + "/" + secret.Name)
+				})
+			}
+		})
+	}
+
+	key := fmt.Sprintf("%t|%s|%s|%s|%s|%v|%d/%d|%s-%s|%s", tlsConfig.InsecureSkipVerify, tlsConfig.ServerName,
+		tlsConfig.CAFile, tlsConfig.CertFile, tlsConfig.KeyFile, secretRefs, timeouts.Connect, timeouts.Read,
+		tlsConfig.MinVersion, tlsConfig.MaxVersion, tlsConfig.Renegotiation)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if entry, ok := c.entries[key]; ok {
+		entry.lastUsed = time.Now()
+		return entry.transport
+	}
+
+	var files []string
+	for _, path := range []string{tlsConfig.CAFile, tlsConfig.CertFile, tlsConfig.KeyFile} {
+		if path == "" {
+			continue
+		}
+		files = append(files, path)
+		if _, ok := c.hashes[path]; !ok {
+			c.hashes[path] = hashFile(path)
+		}
+	}
+	entry := &tlsTransportEntry{transport: newTLSTransport(tlsConfig, timeouts), files: files, secrets: secrets, lastUsed: time.Now()}
+	c.entries[key] = entry
+
+	c.once.Do(func() { go c.watch() })
+	return entry.transport
+}
+
+func newTLSTransport(tlsConfig *TLSConfig, timeouts Timeouts) *http.Transport {
+	transport := &http.Transport{
+		TLSClientConfig: buildTLSClientConfig(tlsConfig),
+	}
+	applyTimeouts(transport, timeouts)
+	return transport
+}
+
+func (c *tlsTransportCache) watch() {
+	ticker := time.NewTicker(tlsFileCheckInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		c.checkFiles()
+	}
+}
+
+// checkFiles re-hashes the watched files and drops the transports that use a changed file, so the next
+// scrape loads the new content. It also drops transports that have been idle for tlsTransportIdleTTL.
+func (c *tlsTransportCache) checkFiles() {
+	c.mu.Lock()
+	paths := make([]string, 0, len(c.hashes))
+	for path := range c.hashes {
+		paths = append(paths, path)
+	}
+	c.mu.Unlock()
+
+	// Hash outside the lock, scrapes keep using the cached transports meanwhile
+	current := make(map[string][sha256.Size]byte, len(paths))
+	for _, path := range paths {
+		current[path] = hashFile(path)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for path, hash := range current {
+		old, ok := c.hashes[path]
+		if !ok || old == hash {
+			continue
+		}
+		c.hashes[path] = hash
+		rebuilt := 0
+		for key, entry := range c.entries {
+			if entry.uses(path) {
+				entry.transport.CloseIdleConnections()
+				delete(c.entries, key)
+				rebuilt++
+			}
+		}
+		logutil.Printf("INFO", "[HTTP_CLIENT] TLS file %s changed, rebuilding %d scrape transport(s)", path, rebuilt)
+	}
+
+	now := time.Now()
+	for key, entry := range c.entries {
+		if now.Sub(entry.lastUsed) > tlsTransportIdleTTL {
+			entry.transport.CloseIdleConnections()
+			delete(c.entries, key)
+		}
+	}
+
+	// Stop watching files no cached transport refers to
+	for path := range c.hashes {
+		used := false
+		for _, entry := range c.entries {
+			if entry.uses(path) {
+				used = true
+				break
+			}
+		}
+		if !used {
+			delete(c.hashes, path)
+		}
+	}
+}
+
+// invalidateSecret drops the transports whose certificates were read from the Secret namespace/name,
+// so the next scrape loads its new content
+func (c *tlsTransportCache) invalidateSecret(secret string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	rebuilt := 0
+	for key, entry := range c.entries {
+		if entry.usesSecret(secret) {
+			entry.transport.CloseIdleConnections()
+			delete(c.entries, key)
+			rebuilt++
+		}
+	}
+	if rebuilt > 0 {
+		logutil.Printf("INFO", "[HTTP_CLIENT] Secret %s changed, rebuilding %d scrape transport(s)", secret, rebuilt)
+	}
+}
+
+func (e *tlsTransportEntry) usesSecret(secret string) bool {
+	for _, s := range e.secrets {
+		if s == secret {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *tlsTransportEntry) uses(path string) bool {
+	for _, f := range e.files {
+		if f == path {
+			return true
+		}
+	}
+	return false
+}
+
+// hashFile returns the content hash of path, or the zero hash when it cannot be read, so a file that
+// appears later is detected as a change
+func hashFile(path string) [sha256.Size]byte {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return [sha256.Size]byte{}
+	}
+	return sha256.Sum256(data)
+}
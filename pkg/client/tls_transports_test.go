@@ -0,0 +1,91 @@
+package client
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTLSTransportRebuiltWhenCAFileRotates(t *testing.T) {
+	oldCA, oldKey := mustGenCA(t)
+	newCA, newKey := mustGenCA(t)
+	oldServer := mustGenLeaf(t, oldCA, oldKey, "localhost", true)
+	newServer := mustGenLeaf(t, newCA, newKey, "localhost", true)
+
+	var serving atomic.Value
+	serving.Store(&oldServer.cert)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("up 1\n"))
+	}))
+	srv.TLS = &tls.Config{
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return serving.Load().(*tls.Certificate), nil
+		},
+	}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	dir := t.TempDir()
+	caFile := writeFile(t, dir, "ca.crt", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: oldCA.Raw}))
+	tlsConfig := &TLSConfig{ServerName: "localhost", CAFile: caFile}
+	scrape := func() error {
+		_, err := GetInstance().ExecuteGetWithAuth(srv.URL+"/metrics", tlsConfig, nil, 5*time.Second)
+		return err
+	}
+
+	if err := scrape(); err != nil {
+		t.Fatalf("scrape before rotation: %v", err)
+	}
+	transport := tlsTransports.get(tlsConfig, Timeouts{Overall: 5 * time.Second}.withDefaults())
+
+	// Rotate the server certificate and the mounted CA bundle
+	serving.Store(&newServer.cert)
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: newCA.Raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	srv.CloseClientConnections()
+
+	if err := scrape(); err == nil {
+		t.Fatalf("expected the cached transport to still trust only the old CA")
+	}
+
+	tlsTransports.checkFiles()
+	if err := scrape(); err != nil {
+		t.Fatalf("scrape after rotation: %v", err)
+	}
+	if tlsTransports.get(tlsConfig, Timeouts{Overall: 5 * time.Second}.withDefaults()) == transport {
+		t.Errorf("expected the transport to be rebuilt")
+	}
+}
+
+func TestTLSTransportInvalidationIsTargeted(t *testing.T) {
+	dir := t.TempDir()
+	ca, _ := mustGenCA(t)
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw})
+	rotating := writeFile(t, dir, "rotating.crt", caPEM)
+	stable := writeFile(t, dir, "stable.crt", caPEM)
+
+	cache := newTLSTransportCache()
+	timeouts := Timeouts{}.withDefaults()
+	a := cache.get(&TLSConfig{CAFile: rotating}, timeouts)
+	b := cache.get(&TLSConfig{CAFile: stable}, timeouts)
+	if cache.get(&TLSConfig{CAFile: rotating}, timeouts) != a {
+		t.Fatalf("expected the transport to be reused")
+	}
+
+	other, _ := mustGenCA(t)
+	writeFile(t, dir, "rotating.crt", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: other.Raw}))
+	cache.checkFiles()
+
+	if cache.get(&TLSConfig{CAFile: rotating}, timeouts) == a {
+		t.Errorf("expected the transport using the rotated CA to be rebuilt")
+	}
+	if cache.get(&TLSConfig{CAFile: stable}, timeouts) != b {
+		t.Errorf("expected the transport using the unchanged CA to be kept")
+	}
+}
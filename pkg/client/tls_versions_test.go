@@ -0,0 +1,83 @@
+package client
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startVersionedTLSServer starts an HTTPS server that only speaks the TLS versions from minVersion to maxVersion
+func startVersionedTLSServer(t *testing.T, minVersion, maxVersion uint16) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("appliance_up 1\n"))
+	}))
+	srv.TLS = &tls.Config{MinVersion: minVersion, MaxVersion: maxVersion}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestTLSVersions_ClientLimitedToTLS13FailsAgainstTLS12Server(t *testing.T) {
+	srv := startVersionedTLSServer(t, tls.VersionTLS12, tls.VersionTLS12)
+
+	_, err := GetInstance().ExecuteGetWithAuth(srv.URL+"/metrics", &TLSConfig{
+		InsecureSkipVerify: true,
+		MinVersion:         "TLS13",
+	}, nil, 5*time.Second)
+	if err == nil || !strings.Contains(err.Error(), "protocol version") {
+		t.Fatalf("expected a protocol version error from a client limited to TLS13, got %v", err)
+	}
+
+	// Allowing TLS12 through the configuration fixes the scrape
+	body, err := GetInstance().ExecuteGetWithAuth(srv.URL+"/metrics", &TLSConfig{
+		InsecureSkipVerify: true,
+		MinVersion:         "TLS12",
+		MaxVersion:         "TLS13",
+	}, nil, 5*time.Second)
+	if err != nil {
+		t.Fatalf("expected the scrape to succeed with minVersion TLS12, got %v", err)
+	}
+	if !strings.Contains(body, "appliance_up") {
+		t.Errorf("unexpected body %q", body)
+	}
+}
+
+func TestTLSVersions_LegacyServerNeedsOptIn(t *testing.T) {
+	srv := startVersionedTLSServer(t, tls.VersionTLS10, tls.VersionTLS11)
+
+	if _, err := GetInstance().ExecuteGetWithAuth(srv.URL+"/metrics", &TLSConfig{InsecureSkipVerify: true}, nil, 5*time.Second); err == nil {
+		t.Fatalf("expected the Go default minimum TLS12 to refuse a TLS11 server")
+	}
+
+	if _, err := GetInstance().ExecuteGetWithAuth(srv.URL+"/metrics", &TLSConfig{
+		InsecureSkipVerify: true,
+		MinVersion:         "TLS11",
+	}, nil, 5*time.Second); err != nil {
+		t.Fatalf("expected minVersion TLS11 to reach the legacy server, got %v", err)
+	}
+}
+
+func TestTLSVersions_InvalidOrForbiddenConfigIsRejected(t *testing.T) {
+	srv := startVersionedTLSServer(t, tls.VersionTLS10, tls.VersionTLS11)
+
+	_, err := GetInstance().ExecuteGetWithAuth(srv.URL+"/metrics", &TLSConfig{
+		InsecureSkipVerify: true,
+		MinVersion:         "TLS1.1",
+	}, nil, 5*time.Second)
+	if err == nil || !strings.Contains(err.Error(), `unknown TLS version "TLS1.1"`) {
+		t.Fatalf("expected an unknown version to be rejected, got %v", err)
+	}
+
+	t.Setenv("openagent_tls_forbid_legacy", "true")
+	_, err = GetInstance().ExecuteGetWithAuth(srv.URL+"/metrics", &TLSConfig{
+		InsecureSkipVerify: true,
+		MinVersion:         "TLS11",
+	}, nil, 5*time.Second)
+	if err == nil || !strings.Contains(err.Error(), "forbidden by openagent_tls_forbid_legacy") {
+		t.Fatalf("expected the legacy version to be forbidden by policy, got %v", err)
+	}
+}
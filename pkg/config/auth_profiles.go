@@ -0,0 +1,132 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ActiveProfileKey selects the profiles section whose credentials authProfile references use (env var
+// or whatap.conf), so the same scrape configuration can be deployed to every environment
+const ActiveProfileKey = "openagent_active_profile"
+
+// DefaultProfile is used when no active profile is set
+const DefaultProfile = "default"
+
+// AuthProfile is one named set of credentials of a profile. Endpoint settings take precedence over it.
+type AuthProfile struct {
+	BasicAuth *BasicAuthConfig `yaml:"basicAuth,omitempty"`
+	// BearerToken is sent in the Authorization header unless the endpoint sets that header itself
+	BearerToken string                 `yaml:"bearerToken,omitempty"`
+	TLSConfig   map[string]interface{} `yaml:"tlsConfig,omitempty"`
+}
+
+// ActiveProfile returns the name of the active profile
+func ActiveProfile() string {
+	return strings.TrimSpace(GetWithDefault(ActiveProfileKey, DefaultProfile))
+}
+
+// authProfiles returns the raw and decoded auth profiles of features.openAgent.profiles.<active>.
+// A profile that fails to decode is left out and reported in warnings.
+func authProfiles(config map[string]interface{}, active string) (map[string]interface{}, map[string]AuthProfile, []string) {
+	features, _ := config["features"].(map[interface{}]interface{})
+	openAgent, _ := features["openAgent"].(map[interface{}]interface{})
+	profiles, _ := openAgent["profiles"].(map[interface{}]interface{})
+	section, _ := profiles[active].(map[interface{}]interface{})
+
+	raw := make(map[string]interface{}, len(section))
+	decoded := make(map[string]AuthProfile, len(section))
+	var warnings []string
+	for name, value := range section {
+		path := fmt.Sprintf("profiles.%s.%v", active, name)
+		value = convertToStringMap(value)
+		var profile AuthProfile
+		if err := decodeValue(value, path, &profile); err != nil {
+			warnings = append(warnings, fmt.Sprintf("ignoring auth profile: %v", err))
+			continue
+		}
+		warnings = append(warnings, unknownFields(value, reflect.TypeOf(AuthProfile{}), path)...)
+		raw[fmt.Sprint(name)] = value
+		decoded[fmt.Sprint(name)] = profile
+	}
+	sort.Strings(warnings)
+	return raw, decoded, warnings
+}
+
+// resolveAuthProfiles applies the auth profiles of the active profile to the endpoints referencing them.
+// An endpoint referencing a profile that is not defined is dropped with a warning, like an endpoint
+// that fails to decode. Targets using a profile get the active profile and its credentials in Raw, so
+// switching profiles is seen as a configuration change and rediscovered right away.
+func resolveAuthProfiles(targets []TargetConfig, config map[string]interface{}, active string) ([]TargetConfig, []string) {
+	var rawProfiles map[string]interface{}
+	var profiles map[string]AuthProfile
+	var warnings []string
+	loaded := false
+
+	for i := range targets {
+		target := &targets[i]
+		used := make(map[string]interface{})
+		endpoints := make([]EndpointConfig, 0, len(target.Endpoints))
+		for j, endpoint := range target.Endpoints {
+			if endpoint.AuthProfile == "" {
+				endpoints = append(endpoints, endpoint)
+				continue
+			}
+			if !loaded {
+				rawProfiles, profiles, warnings = authProfiles(config, active)
+				loaded = true
+			}
+			profile, ok := profiles[endpoint.AuthProfile]
+			if !ok {
+				warnings = append(warnings, fmt.Sprintf("target %s: ignoring endpoint: endpoints[%d].authProfile: %q is not defined in profile %q",
+					target.TargetName, j, endpoint.AuthProfile, active))
+				continue
+			}
+			endpoints = append(endpoints, endpoint.withAuthProfile(profile))
+			used[endpoint.AuthProfile] = rawProfiles[endpoint.AuthProfile]
+		}
+		target.Endpoints = endpoints
+
+		if len(used) > 0 {
+			raw := make(map[string]interface{}, len(target.Raw)+2)
+			for key, value := range target.Raw {
+				raw[key] = value
+			}
+			raw["activeProfile"] = active
+			raw["authProfiles"] = used
+			target.Raw = raw
+		}
+	}
+	return targets, warnings
+}
+
+// withAuthProfile returns the endpoint with the credentials of profile filled in where it sets none
+func (e EndpointConfig) withAuthProfile(profile AuthProfile) EndpointConfig {
+	if e.BasicAuth == nil {
+		e.BasicAuth = profile.BasicAuth
+	}
+	if len(profile.TLSConfig) > 0 {
+		tlsConfig := make(map[string]interface{}, len(profile.TLSConfig)+len(e.TLSConfig))
+		for key, value := range profile.TLSConfig {
+			tlsConfig[key] = value
+		}
+		for key, value := range e.TLSConfig {
+			tlsConfig[key] = value
+		}
+		e.TLSConfig = tlsConfig
+	}
+	if profile.BearerToken != "" && e.BasicAuth == nil {
+		headers := make(map[string]string, len(e.Headers)+1)
+		hasAuthorization := false
+		for name, value := range e.Headers {
+			headers[name] = value
+			hasAuthorization = hasAuthorization || strings.EqualFold(name, "Authorization")
+		}
+		if !hasAuthorization {
+			headers["Authorization"] = "Bearer " + profile.BearerToken
+		}
+		e.Headers = headers
+	}
+	return e
+}
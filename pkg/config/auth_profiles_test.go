@@ -0,0 +1,112 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+const profilesConfig = `
+features:
+  openAgent:
+    enabled: true
+    profiles:
+      dev:
+        readonly:
+          basicAuth:
+            username: dev-user
+            password: dev-pass
+      prod:
+        readonly:
+          bearerToken: prod-token
+          tlsConfig:
+            caFile: /etc/prod/ca.crt
+            insecureSkipVerify: false
+    targets:
+      - targetName: app
+        type: StaticEndpoints
+        endpoints:
+          - address: "10.0.0.1:9100"
+            authProfile: readonly
+            tlsConfig:
+              serverName: app.internal
+          - address: "10.0.0.2:9100"
+`
+
+func TestResolveAuthProfiles_SwitchingActiveProfile(t *testing.T) {
+	cm := &ConfigManager{}
+	if err := cm.applyConfigData([]byte(profilesConfig), time.Now()); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	t.Setenv(ActiveProfileKey, "dev")
+	dev := cm.GetTargetConfigs()
+	endpoint := dev[0].Endpoints[0]
+	if endpoint.BasicAuth == nil || endpoint.BasicAuth.Username.Value != "dev-user" || endpoint.BasicAuth.Password.Value != "dev-pass" {
+		t.Errorf("expected the dev credentials, got %+v", endpoint.BasicAuth)
+	}
+	if endpoint.Headers["Authorization"] != "" || endpoint.TLSConfig["caFile"] != nil {
+		t.Errorf("expected no prod material with the dev profile, got %v and %v", endpoint.Headers, endpoint.TLSConfig)
+	}
+	if other := dev[0].Endpoints[1]; other.BasicAuth != nil || other.Headers != nil {
+		t.Errorf("expected the endpoint without authProfile unchanged, got %+v", other)
+	}
+
+	// The same targets take the prod credentials once the active profile is switched
+	t.Setenv(ActiveProfileKey, "prod")
+	prod := cm.GetTargetConfigs()
+	endpoint = prod[0].Endpoints[0]
+	if endpoint.BasicAuth != nil || endpoint.Headers["Authorization"] != "Bearer prod-token" {
+		t.Errorf("expected the prod bearer token, got %+v and %v", endpoint.BasicAuth, endpoint.Headers)
+	}
+	if endpoint.TLSConfig["caFile"] != "/etc/prod/ca.crt" || endpoint.TLSConfig["serverName"] != "app.internal" {
+		t.Errorf("expected the profile's tlsConfig merged with the endpoint's, got %v", endpoint.TLSConfig)
+	}
+
+	// Discovery fingerprints Raw, so the switch is seen as a configuration change
+	if fmt.Sprint(dev[0].Raw) == fmt.Sprint(prod[0].Raw) {
+		t.Errorf("expected the raw target to change with the active profile")
+	}
+}
+
+func TestResolveAuthProfiles_ProfileChangedAtReload(t *testing.T) {
+	t.Setenv(ActiveProfileKey, "prod")
+	cm := &ConfigManager{}
+	if err := cm.applyConfigData([]byte(profilesConfig), time.Now()); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	before := cm.GetTargetConfigs()
+
+	rotated := strings.Replace(profilesConfig, "prod-token", "rotated-token", 1)
+	if err := cm.applyConfigData([]byte(rotated), time.Now()); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	after := cm.GetTargetConfigs()
+	if got := after[0].Endpoints[0].Headers["Authorization"]; got != "Bearer rotated-token" {
+		t.Errorf("expected the reloaded token, got %q", got)
+	}
+	if fmt.Sprint(before[0].Raw) == fmt.Sprint(after[0].Raw) {
+		t.Errorf("expected the raw target to change with the profile's credentials")
+	}
+}
+
+func TestResolveAuthProfiles_MissingProfile(t *testing.T) {
+	t.Setenv(ActiveProfileKey, "stage")
+	cm := &ConfigManager{}
+	if err := cm.applyConfigData([]byte(profilesConfig), time.Now()); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	// The endpoint referencing the missing profile is left out, the target's other endpoint still works
+	targets := cm.GetTargetConfigs()
+	if len(targets) != 1 || len(targets[0].Endpoints) != 1 || targets[0].Endpoints[0].Address != "10.0.0.2:9100" {
+		t.Errorf("expected only the endpoint without authProfile, got %+v", targets)
+	}
+
+	problems := ValidateScrapeConfig(cm.GetConfig())
+	want := `target app: ignoring endpoint: endpoints[0].authProfile: "readonly" is not defined in profile "stage"`
+	if len(problems) != 1 || problems[0] != want {
+		t.Errorf("expected the missing profile reported, got %q", problems)
+	}
+}
@@ -0,0 +1,25 @@
+package config
+
+import "fmt"
+
+// AutoClusterLabel reports whether features.openAgent.autoClusterLabel: true adds the cluster_id label,
+// the kube-system namespace UID, to every target
+func (cm *ConfigManager) AutoClusterLabel() bool {
+	features, _ := cm.GetConfig()["features"].(map[interface{}]interface{})
+	openAgent, _ := features["openAgent"].(map[interface{}]interface{})
+	enabled, _ := openAgent["autoClusterLabel"].(bool)
+	return enabled
+}
+
+// ClusterNames returns features.openAgent.clusterNames, the cluster label values by kube-system namespace UID
+func (cm *ConfigManager) ClusterNames() map[string]string {
+	names := make(map[string]string)
+	features, _ := cm.GetConfig()["features"].(map[interface{}]interface{})
+	openAgent, _ := features["openAgent"].(map[interface{}]interface{})
+	if table, ok := openAgent["clusterNames"].(map[interface{}]interface{}); ok {
+		for uid, name := range table {
+			names[fmt.Sprint(uid)] = fmt.Sprint(name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,33 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClusterLabelSettings(t *testing.T) {
+	cm := &ConfigManager{}
+	if cm.AutoClusterLabel() || len(cm.ClusterNames()) != 0 {
+		t.Fatal("expected autoClusterLabel to be off by default")
+	}
+
+	data := `
+features:
+  openAgent:
+    autoClusterLabel: true
+    clusterNames:
+      5a3c1e0e-9d1f-4b7a-8c55-0f1e2d3c4b5a: prod-seoul
+      0f0e0d0c-0000-0000-0000-000000000001: staging
+    targets: []
+`
+	if err := cm.applyConfigData([]byte(data), time.Now()); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if !cm.AutoClusterLabel() {
+		t.Error("expected autoClusterLabel: true")
+	}
+	names := cm.ClusterNames()
+	if len(names) != 2 || names["5a3c1e0e-9d1f-4b7a-8c55-0f1e2d3c4b5a"] != "prod-seoul" {
+		t.Errorf("ClusterNames = %v", names)
+	}
+}
@@ -0,0 +1,210 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
+
+	"open-agent/tools/util/logutil"
+)
+
+// cachedConfigLogInterval is how often the warning about running on cached configuration is repeated
+const cachedConfigLogInterval = 10 * time.Minute
+
+// configMapSource reads the scrape config ConfigMap; implemented by k8s.K8sClient
+type configMapSource interface {
+	IsInitialized() bool
+	GetConfigMap(namespace, name string) (*corev1.ConfigMap, error)
+}
+
+// lastConfigCacheFile is where the last configuration loaded from the ConfigMap is kept,
+// so a restarted worker can run while the ConfigMap is missing
+func lastConfigCacheFile() string {
+	homeDir := os.Getenv("WHATAP_OPEN_HOME")
+	if homeDir == "" {
+		homeDir = "."
+	}
+	return filepath.Join(homeDir, "cache", "scrape_config.last.yaml")
+}
+
+// applyConfigData parses and interpolates scrape_config.yaml contents and makes them the current configuration
+func (cm *ConfigManager) applyConfigData(data []byte, loadedAt time.Time) error {
+	var config map[string]interface{}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return fmt.Errorf("error parsing configuration: %v", err)
+	}
+
+	redacted, secrets, err := cm.interpolateConfig(config)
+	if err != nil {
+		return fmt.Errorf("error interpolating configuration: %v", err)
+	}
+	if err := checkStrictConfig(config); err != nil {
+		return err
+	}
+
+	cm.mu.Lock()
+	cm.snapshot.Store(&configSnapshot{config: config, redacted: redacted, secretValues: secrets})
+	cm.loadedAt = loadedAt
+	cm.recordApplied(data)
+	cm.mu.Unlock()
+	return nil
+}
+
+// persistLastConfig writes the raw ConfigMap data to the cache file when it changed.
+// The data is written before interpolation, so resolved credentials never reach the disk.
+func (cm *ConfigManager) persistLastConfig(data string) {
+	cm.mu.RLock()
+	unchanged := data == cm.lastPersisted
+	cm.mu.RUnlock()
+	if unchanged {
+		return
+	}
+
+	path := lastConfigCacheFile()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		logutil.Printf("WARN", "[CONFIG] Failed to create config cache directory: %v", err)
+		return
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte(data), 0600); err != nil {
+		logutil.Printf("WARN", "[CONFIG] Failed to write config cache %s: %v", tmp, err)
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		logutil.Printf("WARN", "[CONFIG] Failed to write config cache %s: %v", path, err)
+		return
+	}
+
+	cm.mu.Lock()
+	cm.lastPersisted = data
+	cm.mu.Unlock()
+}
+
+// loadCachedConfig loads the configuration saved by persistLastConfig
+func (cm *ConfigManager) loadCachedConfig() error {
+	path := lastConfigCacheFile()
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("no cached configuration: %v", err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading cached configuration: %v", err)
+	}
+	if err := cm.applyConfigData(data, info.ModTime()); err != nil {
+		return err
+	}
+
+	cm.mu.Lock()
+	cm.lastPersisted = string(data)
+	cm.mu.Unlock()
+	return nil
+}
+
+// initFromConfigMap loads the ConfigMap, falling back to the cached configuration when it is absent
+func (cm *ConfigManager) initFromConfigMap() error {
+	err := cm.LoadConfig()
+	if err == nil {
+		return nil
+	}
+	// A configuration rejected by strictConfig is fatal at startup, the cache is not a fallback for it
+	var strictErr *StrictConfigError
+	if errors.As(err, &strictErr) {
+		return err
+	}
+	if cacheErr := cm.loadCachedConfig(); cacheErr != nil {
+		return fmt.Errorf("%v (%v)", err, cacheErr)
+	}
+	cm.keepLastConfig(err, time.Now())
+	return nil
+}
+
+// invalidConfigError is returned when the ConfigMap exists but its scrape_config.yaml cannot be applied
+type invalidConfigError struct{ err error }
+
+func (e invalidConfigError) Error() string { return e.err.Error() }
+func (e invalidConfigError) Unwrap() error { return e.err }
+
+// keepLastConfig logs why loading the ConfigMap failed, for a ConfigMap that is invalid or unavailable
+func (cm *ConfigManager) keepLastConfig(err error, now time.Time) {
+	var invalid invalidConfigError
+	if errors.As(err, &invalid) {
+		cm.invalidConfig(err)
+		return
+	}
+	cm.configMapUnavailable(err, now)
+}
+
+// invalidConfig logs that the ConfigMap is invalid and the last good configuration is kept.
+// The warning is logged once per distinct error.
+func (cm *ConfigManager) invalidConfig(err error) {
+	cm.mu.Lock()
+	changed := err.Error() != cm.lastInvalidConfig
+	cm.lastInvalidConfig = err.Error()
+	loadedAt := cm.loadedAt
+	hasConfig := cm.GetConfig() != nil
+	cm.mu.Unlock()
+
+	if !changed {
+		return
+	}
+	if !hasConfig {
+		logutil.Printf("WARN", "[CONFIG] ConfigMap %s/%s has an invalid config (%v) and no last good configuration exists",
+			cm.configMapNamespace, cm.configMapName, err)
+		return
+	}
+	logutil.Printf("WARN", "[CONFIG] ConfigMap %s/%s has an invalid config, keeping last good configuration loaded %s: %v",
+		cm.configMapNamespace, cm.configMapName, loadedAt.Format(time.RFC3339), err)
+}
+
+// configMapUnavailable logs that the agent keeps running on the last-known configuration.
+// The warning is logged when the ConfigMap disappears and repeated every cachedConfigLogInterval.
+func (cm *ConfigManager) configMapUnavailable(err error, now time.Time) {
+	cm.mu.Lock()
+	logNow := !cm.usingCachedConfig || now.Sub(cm.lastCachedConfigLog) >= cachedConfigLogInterval
+	cm.usingCachedConfig = true
+	if logNow {
+		cm.lastCachedConfigLog = now
+	}
+	loadedAt := cm.loadedAt
+	hasConfig := cm.GetConfig() != nil
+	cm.mu.Unlock()
+
+	if !logNow {
+		return
+	}
+	if !hasConfig {
+		logutil.Printf("WARN", "[CONFIG] ConfigMap %s/%s unavailable (%v) and no cached configuration exists",
+			cm.configMapNamespace, cm.configMapName, err)
+		return
+	}
+	logutil.Printf("WARN", "[CONFIG] ConfigMap %s/%s unavailable (%v); running on cached configuration loaded %s (age %v, cache %s)",
+		cm.configMapNamespace, cm.configMapName, err, loadedAt.Format(time.RFC3339), now.Sub(loadedAt).Truncate(time.Second), lastConfigCacheFile())
+}
+
+// configMapAvailable logs the switch back from cached configuration once the ConfigMap exists again
+func (cm *ConfigManager) configMapAvailable() {
+	cm.mu.Lock()
+	wasCached := cm.usingCachedConfig
+	cm.usingCachedConfig = false
+	cm.lastInvalidConfig = ""
+	cm.mu.Unlock()
+
+	if wasCached {
+		logutil.Printf("INFO", "[CONFIG] ConfigMap %s/%s is available again, switched back from cached configuration",
+			cm.configMapNamespace, cm.configMapName)
+	}
+}
+
+// IsUsingCachedConfig reports whether the ConfigMap is missing and the last-known configuration is in use
+func (cm *ConfigManager) IsUsingCachedConfig() bool {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+	return cm.usingCachedConfig
+}
@@ -0,0 +1,193 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// fakeConfigMapSource reads ConfigMaps from a fake clientset
+type fakeConfigMapSource struct {
+	clientset *fake.Clientset
+}
+
+func (f *fakeConfigMapSource) IsInitialized() bool { return true }
+
+func (f *fakeConfigMapSource) GetConfigMap(namespace, name string) (*corev1.ConfigMap, error) {
+	return f.clientset.CoreV1().ConfigMaps(namespace).Get(context.Background(), name, metav1.GetOptions{})
+}
+
+func scrapeConfigMap(targetName string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "whatap-monitoring", Name: "whatap-open-agent-config"},
+		Data: map[string]string{"scrape_config.yaml": `
+features:
+  openAgent:
+    enabled: true
+    targets:
+      - targetName: ` + targetName + `
+        type: StaticEndpoints
+        endpoints:
+          - address: "10.0.0.1:9100"
+            basicAuth:
+              password: "${API_PASSWORD}"
+`},
+	}
+}
+
+func newTestConfigManager(source configMapSource) *ConfigManager {
+	return &ConfigManager{
+		k8sClient:          source,
+		configMapNamespace: "whatap-monitoring",
+		configMapName:      "whatap-open-agent-config",
+	}
+}
+
+func targetNames(cm *ConfigManager) []string {
+	var names []string
+	for _, target := range cm.GetScrapeConfigs() {
+		names = append(names, target["targetName"].(string))
+	}
+	return names
+}
+
+func TestConfigCache_DeleteRestartRecreate(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("WHATAP_OPEN_HOME", home)
+	t.Setenv("API_PASSWORD", "s3cret")
+
+	ctx := context.Background()
+	clientset := fake.NewSimpleClientset(scrapeConfigMap("node-exporter"))
+	source := &fakeConfigMapSource{clientset: clientset}
+
+	// Normal start persists the ConfigMap contents without resolved credentials
+	cm := newTestConfigManager(source)
+	if err := cm.initFromConfigMap(); err != nil {
+		t.Fatalf("initial load: %v", err)
+	}
+	cached, err := os.ReadFile(filepath.Join(home, "cache", "scrape_config.last.yaml"))
+	if err != nil {
+		t.Fatalf("expected cached config to be written: %v", err)
+	}
+	if !strings.Contains(string(cached), "${API_PASSWORD}") || strings.Contains(string(cached), "s3cret") {
+		t.Fatalf("cache must hold the raw ConfigMap data, got:\n%s", cached)
+	}
+
+	// ConfigMap deleted: the running worker keeps the last-known targets
+	if err := clientset.CoreV1().ConfigMaps("whatap-monitoring").Delete(ctx, "whatap-open-agent-config", metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if names := targetNames(cm); len(names) != 1 || names[0] != "node-exporter" {
+		t.Fatalf("expected last-known targets after deletion, got %v", names)
+	}
+	if !cm.IsUsingCachedConfig() {
+		t.Errorf("expected cached config flag after deletion")
+	}
+
+	// Worker restart while the ConfigMap is still missing starts from the cache
+	restarted := newTestConfigManager(source)
+	if err := restarted.initFromConfigMap(); err != nil {
+		t.Fatalf("restart without ConfigMap: %v", err)
+	}
+	if names := targetNames(restarted); len(names) != 1 || names[0] != "node-exporter" {
+		t.Fatalf("expected cached targets after restart, got %v", names)
+	}
+	if !restarted.IsUsingCachedConfig() {
+		t.Errorf("expected restarted manager to report cached config")
+	}
+	if got := restarted.GetRedactedConfig(); got == nil {
+		t.Errorf("expected cached config to be interpolated and redactable")
+	}
+
+	// ConfigMap re-created with different targets: switch back seamlessly
+	if _, err := clientset.CoreV1().ConfigMaps("whatap-monitoring").Create(ctx, scrapeConfigMap("kube-state-metrics"), metav1.CreateOptions{}); err != nil {
+		t.Fatalf("recreate: %v", err)
+	}
+	if names := targetNames(restarted); len(names) != 1 || names[0] != "kube-state-metrics" {
+		t.Fatalf("expected targets from the re-created ConfigMap, got %v", names)
+	}
+	if restarted.IsUsingCachedConfig() {
+		t.Errorf("expected cached config flag to clear once the ConfigMap is back")
+	}
+	cached, _ = os.ReadFile(filepath.Join(home, "cache", "scrape_config.last.yaml"))
+	if !strings.Contains(string(cached), "kube-state-metrics") {
+		t.Errorf("expected cache to follow the re-created ConfigMap")
+	}
+}
+
+func TestConfigCache_InvalidConfigMapKeepsLastGood(t *testing.T) {
+	t.Setenv("WHATAP_OPEN_HOME", t.TempDir())
+	t.Setenv("API_PASSWORD", "s3cret")
+
+	ctx := context.Background()
+	clientset := fake.NewSimpleClientset(scrapeConfigMap("node-exporter"))
+	cm := newTestConfigManager(&fakeConfigMapSource{clientset: clientset})
+	if err := cm.initFromConfigMap(); err != nil {
+		t.Fatalf("initial load: %v", err)
+	}
+
+	invalid := scrapeConfigMap("node-exporter")
+	invalid.Data["scrape_config.yaml"] = "features: [unclosed"
+	if _, err := clientset.CoreV1().ConfigMaps("whatap-monitoring").Update(ctx, invalid, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if names := targetNames(cm); len(names) != 1 || names[0] != "node-exporter" {
+		t.Fatalf("expected the last good targets, got %v", names)
+	}
+	// The ConfigMap exists, so the agent does not report running on the cached configuration
+	if cm.IsUsingCachedConfig() || cm.lastInvalidConfig == "" {
+		t.Errorf("expected an invalid config, not an unavailable ConfigMap (cached %v, invalid %q)", cm.IsUsingCachedConfig(), cm.lastInvalidConfig)
+	}
+
+	if _, err := clientset.CoreV1().ConfigMaps("whatap-monitoring").Update(ctx, scrapeConfigMap("kube-state-metrics"), metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if names := targetNames(cm); len(names) != 1 || names[0] != "kube-state-metrics" || cm.lastInvalidConfig != "" {
+		t.Errorf("expected the fixed ConfigMap to apply, got %v (invalid %q)", names, cm.lastInvalidConfig)
+	}
+}
+
+func TestConfigCache_NoConfigMapAndNoCache(t *testing.T) {
+	t.Setenv("WHATAP_OPEN_HOME", t.TempDir())
+	cm := newTestConfigManager(&fakeConfigMapSource{clientset: fake.NewSimpleClientset()})
+	if err := cm.initFromConfigMap(); err == nil {
+		t.Fatalf("expected an error without ConfigMap and cache")
+	}
+}
+
+func TestConfigGeneration_ChangesOnlyWithContent(t *testing.T) {
+	t.Setenv("WHATAP_OPEN_HOME", t.TempDir())
+	ctx := context.Background()
+	clientset := fake.NewSimpleClientset(scrapeConfigMap("node-exporter"))
+	cm := newTestConfigManager(&fakeConfigMapSource{clientset: clientset})
+	if cm.ConfigGeneration() != 0 {
+		t.Fatalf("expected generation 0 before the first load")
+	}
+	if err := cm.initFromConfigMap(); err != nil {
+		t.Fatalf("initial load: %v", err)
+	}
+	if got := cm.ConfigGeneration(); got != 1 {
+		t.Fatalf("generation = %d after the first load, want 1", got)
+	}
+
+	// Reloading unchanged content from the informer cache keeps the generation
+	targetNames(cm)
+	targetNames(cm)
+	if got := cm.ConfigGeneration(); got != 1 {
+		t.Errorf("generation = %d after unchanged reloads, want 1", got)
+	}
+
+	if _, err := clientset.CoreV1().ConfigMaps("whatap-monitoring").Update(ctx, scrapeConfigMap("kube-state-metrics"), metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	targetNames(cm)
+	if got := cm.ConfigGeneration(); got != 2 {
+		t.Errorf("generation = %d after a change, want 2", got)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"gopkg.in/yaml.v2"
@@ -29,17 +30,52 @@ func IsForceStandaloneMode() bool {
 	return forceStandaloneMode
 }
 
+// configSnapshot is one applied configuration. It is never modified once stored: a reload stores a
+// new snapshot, so readers holding the previous one are unaffected.
+type configSnapshot struct {
+	config map[string]interface{}
+	// redacted is config with <redacted> where interpolated credentials were expanded
+	redacted map[string]interface{}
+	// secretValues are the interpolated credential values, redacted from free text by Redact
+	secretValues []string
+}
+
+// emptySnapshot is the configuration before the first load
+var emptySnapshot = &configSnapshot{}
+
 // ConfigManager is responsible for loading and parsing the scrape configuration
 type ConfigManager struct {
-	config             map[string]interface{}
+	// snapshot is the applied configuration, swapped on reload and read without cm.mu
+	snapshot           atomic.Pointer[configSnapshot]
 	configFile         string
 	mu                 sync.RWMutex
-	k8sClient          *k8s.K8sClient
+	k8sClient          configMapSource
 	configMapNamespace string
 	configMapName      string
 	fileWatcherEnabled bool
 	fileWatcherStop    chan struct{}
 	lastModTime        time.Time
+	// lastMissingRefs avoids repeating the same unresolved reference warning on every reload
+	lastMissingRefs string
+	// lastTargetProblems avoids repeating the same target decoding errors and warnings on every reload
+	lastTargetProblems string
+	// lastStrictRejection avoids repeating the same strictConfig rejection on every reload
+	lastStrictRejection string
+	// internalTargets are scraped in addition to the configured targets, by name
+	internalTargets map[string]TargetConfig
+
+	// loadedAt is when the current configuration was loaded (the cache file time for cached configuration)
+	loadedAt time.Time
+	// lastPersisted is the ConfigMap data last written to the cache file
+	lastPersisted string
+	// generation is incremented whenever the applied configuration content changes
+	generation  int64
+	appliedData string
+	// usingCachedConfig is set while the ConfigMap is missing and the last-known configuration is used
+	usingCachedConfig   bool
+	lastCachedConfigLog time.Time
+	// lastInvalidConfig avoids repeating the same invalid ConfigMap warning on every reload
+	lastInvalidConfig string
 }
 
 // getPodNamespace returns the namespace of the current pod from the ServiceAccount mount
@@ -86,6 +122,7 @@ func NewConfigManager() *ConfigManager {
 		go cm.watchConfigFile()
 		// Initial file load
 		if err := cm.LoadConfig(); err != nil {
+			exitOnStrictConfigError(err)
 			logutil.Infof("CONFIG", "Failed to load configuration: %v", err)
 			return nil
 		}
@@ -97,8 +134,9 @@ func NewConfigManager() *ConfigManager {
 	if cm.k8sClient.IsInitialized() {
 		logutil.Infof("CONFIG", "Kubernetes environment detected, using ConfigMap informer cache")
 
-		// Initial configuration load
-		if err := cm.LoadConfig(); err != nil {
+		// Initial configuration load, from the cached configuration if the ConfigMap is absent
+		if err := cm.initFromConfigMap(); err != nil {
+			exitOnStrictConfigError(err)
 			logutil.Infof("CONFIG", "Failed to load initial configuration: %v", err)
 			return nil
 		}
@@ -112,6 +150,7 @@ func NewConfigManager() *ConfigManager {
 
 		// Initial file load
 		if err := cm.LoadConfig(); err != nil {
+			exitOnStrictConfigError(err)
 			logutil.Infof("CONFIG", "Failed to load configuration: %v", err)
 			return nil
 		}
@@ -131,18 +170,14 @@ func (cm *ConfigManager) LoadConfig() error {
 
 		configData, ok := configMap.Data["scrape_config.yaml"]
 		if !ok {
-			return fmt.Errorf("scrape_config.yaml not found in ConfigMap")
+			return invalidConfigError{fmt.Errorf("scrape_config.yaml not found in ConfigMap")}
 		}
 
-		var config map[string]interface{}
-		err = yaml.Unmarshal([]byte(configData), &config)
-		if err != nil {
-			return fmt.Errorf("error parsing ConfigMap data: %v", err)
+		if err := cm.applyConfigData([]byte(configData), time.Now()); err != nil {
+			return invalidConfigError{fmt.Errorf("ConfigMap data: %w", err)}
 		}
-
-		cm.mu.Lock()
-		cm.config = config
-		cm.mu.Unlock()
+		cm.persistLastConfig(configData)
+		cm.configMapAvailable()
 		if IsDebugEnabled() {
 			logutil.Debugf("CONFIG", "Configuration loaded from ConfigMap informer cache")
 		}
@@ -168,34 +203,144 @@ func (cm *ConfigManager) LoadConfig() error {
 		return fmt.Errorf("error parsing configuration file: %v", err)
 	}
 
-	// Update the config with a lock to ensure thread safety
+	redacted, secrets, err := cm.interpolateConfig(config)
+	if err != nil {
+		return fmt.Errorf("error interpolating configuration file: %v", err)
+	}
+	if err := checkStrictConfig(config); err != nil {
+		return err
+	}
+
 	cm.mu.Lock()
-	cm.config = config
+	cm.snapshot.Store(&configSnapshot{config: config, redacted: redacted, secretValues: secrets})
+	cm.recordApplied(data)
 	cm.mu.Unlock()
 
 	logutil.Infof("CONFIG", "Configuration loaded from local file %s", configFile)
+	if IsDebugEnabled() {
+		if dump, err := yaml.Marshal(cm.GetRedactedConfig()); err == nil {
+			logutil.Debugf("CONFIG", "Loaded configuration:\n%s", string(dump))
+		}
+	}
 	return nil
 }
 
-// GetConfig returns the entire configuration
-func (cm *ConfigManager) GetConfig() map[string]interface{} {
+// interpolateConfig expands ${ENV_VAR} and ${file:/path} references in place and returns the
+// configuration with the credentials redacted, for config dumps, and the credential values.
+// With openagent_config_interpolation_strict=true an unresolved reference fails the load,
+// otherwise the literal reference is kept and a warning is logged.
+func (cm *ConfigManager) interpolateConfig(config map[string]interface{}) (map[string]interface{}, []string, error) {
+	ip := newInterpolator(GetBoolWithDefault("openagent_config_interpolation_strict", false))
+	_, redacted, err := ip.interpolate(config)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	missing := strings.Join(ip.missing, ", ")
+	cm.mu.Lock()
+	changed := missing != cm.lastMissingRefs
+	cm.lastMissingRefs = missing
+	cm.mu.Unlock()
+	if missing != "" && changed {
+		logutil.Printf("WARN", "Unresolved references left as is in scrape configuration: %s", missing)
+	}
+
+	return redacted.(map[string]interface{}), sortSecrets(ip.secrets), nil
+}
+
+// recordApplied bumps the configuration generation when data differs from the last applied
+// configuration. The caller must hold cm.mu.
+func (cm *ConfigManager) recordApplied(data []byte) {
+	if string(data) != cm.appliedData || cm.generation == 0 {
+		cm.appliedData = string(data)
+		cm.generation++
+	}
+}
+
+// ConfigGeneration returns how many distinct configurations have been applied since startup,
+// 0 before the first one. Reloads of unchanged content keep the generation.
+func (cm *ConfigManager) ConfigGeneration() int64 {
 	cm.mu.RLock()
 	defer cm.mu.RUnlock()
-	return cm.config
+	return cm.generation
 }
 
-// GetScrapeInterval returns the global scrape interval
+// current returns the applied configuration snapshot
+func (cm *ConfigManager) current() *configSnapshot {
+	if snapshot := cm.snapshot.Load(); snapshot != nil {
+		return snapshot
+	}
+	return emptySnapshot
+}
+
+// GetConfig returns the entire configuration. The map is shared with every other reader and must
+// not be modified; use GetScrapeConfigs or GetTargetConfigs for a copy of the targets.
+func (cm *ConfigManager) GetConfig() map[string]interface{} {
+	return cm.current().config
+}
+
+// GetRedactedConfig returns a copy of the configuration with interpolated credentials replaced where
+// they were expanded. Use this for any config dump (admin endpoint, debug logs).
+func (cm *ConfigManager) GetRedactedConfig() map[string]interface{} {
+	snapshot := cm.current()
+	if snapshot.redacted == nil {
+		return nil
+	}
+	return copyValue(snapshot.redacted).(map[string]interface{})
+}
+
+// Redact replaces interpolated credential values occurring as whole words in s
+func (cm *ConfigManager) Redact(s string) string {
+	return redactString(s, cm.current().secretValues)
+}
+
+// GetScrapeInterval returns the global scrape interval, the interval of endpoints that set none
 func (cm *ConfigManager) GetScrapeInterval() string {
-	if cm.config != nil {
-		if global, ok := cm.config["global"].(map[interface{}]interface{}); ok {
-			if interval, ok := global["scrape_interval"].(string); ok {
-				return interval
-			}
+	return firstNonEmpty(cm.GetGlobalConfig().ScrapeInterval, DefaultScrapeInterval)
+}
+
+// GetTargetConfigs returns the scrape targets decoded into typed configs, with the global section and
+// built-in defaults filled into their endpoints. Targets that fail to decode are skipped with an error
+// naming the field; unknown fields are reported as warnings. Internal targets follow the configured ones.
+// It returns nil when no scrape configuration is available and there are no internal targets.
+func (cm *ConfigManager) GetTargetConfigs() []TargetConfig {
+	scrapeConfigs := cm.GetScrapeConfigs()
+	internal := cm.internalTargetConfigs()
+	if scrapeConfigs == nil && len(internal) == 0 {
+		return nil
+	}
+	config := cm.current().config
+	targets, warnings, errs := DecodeTargetConfigs(scrapeConfigs)
+	targets, profileWarnings := resolveAuthProfiles(targets, config, ActiveProfile())
+	warnings = append(warnings, profileWarnings...)
+	targets = append(targets, internal...)
+	global, rawGlobal, globalWarnings := globalConfig(config)
+	targets = resolveScrapeDefaults(targets, global, rawGlobal)
+	warnings = append(warnings, globalWarnings...)
+
+	problems := make([]string, 0, len(warnings)+len(errs))
+	for _, err := range errs {
+		problems = append(problems, err.Error())
+	}
+	problems = append(problems, warnings...)
+	key := strings.Join(problems, "\n")
+	cm.mu.Lock()
+	changed := key != cm.lastTargetProblems
+	cm.lastTargetProblems = key
+	cm.mu.Unlock()
+	if changed {
+		for _, err := range errs {
+			logutil.Printf("ERROR", "Skipping invalid scrape target: %v", err)
+		}
+		for _, warning := range warnings {
+			logutil.Printf("WARN", "Scrape configuration: %s", warning)
 		}
 	}
-	return "15s"
+	return targets
 }
 
+// GetScrapeConfigs returns a copy of the scrape targets that the caller may modify; it is built from the
+// applied configuration snapshot, so a concurrent reload never changes it.
 func (cm *ConfigManager) GetScrapeConfigs() []map[string]interface{} {
 	// Always reload configuration from Informer cache in Kubernetes environment
 	if IsDebugEnabled() {
@@ -211,23 +356,29 @@ func (cm *ConfigManager) GetScrapeConfigs() []map[string]interface{} {
 				logutil.Debugf("CONFIG", "GetScrapeConfigs: Failed to reload config from Informer cache: %v", err)
 			}
 			// Continue with existing config as fallback
+			if !cm.rejectedInStrictMode(err) {
+				cm.keepLastConfig(err, time.Now())
+			}
 		} else {
 			if IsDebugEnabled() {
 				logutil.Debugf("CONFIG", "GetScrapeConfigs: Successfully reloaded configuration from Informer cache")
 			}
 		}
 	}
-	cm.mu.RLock()
-	defer cm.mu.RUnlock()
-
 	if IsDebugEnabled() {
 		logutil.Debugf("CONFIG", "GetScrapeConfigs: Processing current configuration")
 	}
 
+	return scrapeTargets(cm.GetConfig())
+}
+
+// scrapeTargets returns the targets of the features.openAgent section, or nil if OpenAgent is disabled
+// or has no targets
+func scrapeTargets(config map[string]interface{}) []map[string]interface{} {
 	// First, try to get the openAgent section from the CR format
-	if cm.config != nil {
+	if config != nil {
 		// Check if we have a features section (CR format)
-		if features, ok := cm.config["features"].(map[interface{}]interface{}); ok {
+		if features, ok := config["features"].(map[interface{}]interface{}); ok {
 			// Check if we have an openAgent section
 			if openAgent, ok := features["openAgent"].(map[interface{}]interface{}); ok {
 				// Check if openAgent is enabled
@@ -317,7 +468,9 @@ func (cm *ConfigManager) watchConfigFile() {
 					cm.lastModTime = fileInfo.ModTime()
 
 					if err := cm.LoadConfig(); err != nil {
-						logutil.Infof("CONFIG", "Error reloading configuration: %v", err)
+						if !cm.rejectedInStrictMode(err) {
+							logutil.Infof("CONFIG", "Error reloading configuration: %v", err)
+						}
 						continue
 					}
 
@@ -340,8 +493,8 @@ func (cm *ConfigManager) Close() {
 
 // GetScrapingInterval returns the scraping loop interval from openAgent configuration
 func (cm *ConfigManager) GetScrapingInterval() string {
-	if cm.config != nil {
-		if features, ok := cm.config["features"].(map[interface{}]interface{}); ok {
+	if config := cm.GetConfig(); config != nil {
+		if features, ok := config["features"].(map[interface{}]interface{}); ok {
 			if openAgent, ok := features["openAgent"].(map[interface{}]interface{}); ok {
 				if scrapingInterval, ok := openAgent["scrapingInterval"].(string); ok {
 					return scrapingInterval
@@ -354,8 +507,8 @@ func (cm *ConfigManager) GetScrapingInterval() string {
 
 // GetMaxConcurrency returns the maximum concurrent scrapers from openAgent configuration
 func (cm *ConfigManager) GetMaxConcurrency() int {
-	if cm.config != nil {
-		if features, ok := cm.config["features"].(map[interface{}]interface{}); ok {
+	if config := cm.GetConfig(); config != nil {
+		if features, ok := config["features"].(map[interface{}]interface{}); ok {
 			if openAgent, ok := features["openAgent"].(map[interface{}]interface{}); ok {
 				if maxConcurrency, ok := openAgent["maxConcurrency"].(int); ok {
 					return maxConcurrency
@@ -368,8 +521,8 @@ func (cm *ConfigManager) GetMaxConcurrency() int {
 
 // GetMinimumInterval returns the minimum scraping interval from openAgent configuration
 func (cm *ConfigManager) GetMinimumInterval() string {
-	if cm.config != nil {
-		if features, ok := cm.config["features"].(map[interface{}]interface{}); ok {
+	if config := cm.GetConfig(); config != nil {
+		if features, ok := config["features"].(map[interface{}]interface{}); ok {
 			if openAgent, ok := features["openAgent"].(map[interface{}]interface{}); ok {
 				if minimumInterval, ok := openAgent["minimumInterval"].(string); ok {
 					return minimumInterval
@@ -381,6 +534,66 @@ func (cm *ConfigManager) GetMinimumInterval() string {
 	return "1s"
 }
 
+// GetDiscoveryInterval returns the target discovery interval from openAgent configuration, "" when unset
+func (cm *ConfigManager) GetDiscoveryInterval() string {
+	if features, ok := cm.GetConfig()["features"].(map[interface{}]interface{}); ok {
+		if openAgent, ok := features["openAgent"].(map[interface{}]interface{}); ok {
+			if discoveryInterval, ok := openAgent["discoveryInterval"].(string); ok {
+				return discoveryInterval
+			}
+		}
+	}
+	return ""
+}
+
+// GetDiscoveryIntervals returns the per target type discovery intervals from openAgent configuration
+func (cm *ConfigManager) GetDiscoveryIntervals() map[string]string {
+	intervals := make(map[string]string)
+	if features, ok := cm.GetConfig()["features"].(map[interface{}]interface{}); ok {
+		if openAgent, ok := features["openAgent"].(map[interface{}]interface{}); ok {
+			if perType, ok := openAgent["discoveryIntervals"].(map[interface{}]interface{}); ok {
+				for targetType, interval := range perType {
+					intervals[fmt.Sprint(targetType)] = fmt.Sprint(interval)
+				}
+			}
+		}
+	}
+	return intervals
+}
+
+// NamespaceBudget limits the scrape load of one namespace; zero fields are unlimited
+type NamespaceBudget struct {
+	// MaxTargets is the number of targets scheduled in the namespace, the lowest priority ones are left out
+	MaxTargets int
+	// MaxSamplesPerMinute truncates the scrapes of the namespace once it sent this many samples in a minute
+	MaxSamplesPerMinute int
+}
+
+// GetNamespaceBudgets returns the per-namespace budgets from openAgent configuration (namespaceBudgets),
+// keyed by namespace. Values that are not positive integers are unlimited.
+func (cm *ConfigManager) GetNamespaceBudgets() map[string]NamespaceBudget {
+	budgets := make(map[string]NamespaceBudget)
+	if features, ok := cm.GetConfig()["features"].(map[interface{}]interface{}); ok {
+		if openAgent, ok := features["openAgent"].(map[interface{}]interface{}); ok {
+			if perNamespace, ok := openAgent["namespaceBudgets"].(map[interface{}]interface{}); ok {
+				for namespace, value := range perNamespace {
+					settings, ok := value.(map[interface{}]interface{})
+					if !ok {
+						continue
+					}
+					maxTargets, _ := settings["maxTargets"].(int)
+					maxSamples, _ := settings["maxSamplesPerMinute"].(int)
+					budgets[fmt.Sprint(namespace)] = NamespaceBudget{
+						MaxTargets:          max(maxTargets, 0),
+						MaxSamplesPerMinute: max(maxSamples, 0),
+					}
+				}
+			}
+		}
+	}
+	return budgets
+}
+
 // ParseInterval parses an interval string (e.g., "15s", "1m") to seconds
 func (cm *ConfigManager) ParseInterval(intervalStr string) (int64, error) {
 	if intervalStr == "" {
@@ -408,7 +621,8 @@ func (cm *ConfigManager) ParseInterval(intervalStr string) (int64, error) {
 	}
 }
 
-// Helper function to convert interface{} values to string maps recursively
+// Helper function to convert interface{} values to string maps recursively. Maps and lists are
+// always copied, so the result shares nothing with the configuration snapshot.
 func convertToStringMap(value interface{}) interface{} {
 	switch v := value.(type) {
 	case map[interface{}]interface{}:
@@ -419,6 +633,12 @@ func convertToStringMap(value interface{}) interface{} {
 			}
 		}
 		return result
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, val := range v {
+			result[key] = convertToStringMap(val)
+		}
+		return result
 	case []interface{}:
 		result := make([]interface{}, len(v))
 		for i, val := range v {
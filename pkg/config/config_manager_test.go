@@ -0,0 +1,105 @@
+package config
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// TestConfigManager_ConcurrentReadsDuringReload reads the configuration from several goroutines, like
+// discovery and the scraper do, while ConfigMap updates are applied. Run with -race.
+func TestConfigManager_ConcurrentReadsDuringReload(t *testing.T) {
+	t.Setenv("API_PASSWORD", "s3cret")
+
+	// Updates are applied directly: reads through the fake clientset would synchronize the goroutines
+	cm := &ConfigManager{}
+	if err := cm.applyConfigData([]byte(scrapeConfigMap("target-0").Data["scrape_config.yaml"]), time.Now()); err != nil {
+		t.Fatalf("initial load: %v", err)
+	}
+
+	stop := make(chan struct{})
+	var readers sync.WaitGroup
+	read := func(reads func()) {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					reads()
+				}
+			}
+		}()
+	}
+	for i := 0; i < 2; i++ {
+		read(func() {
+			// Callers may modify the returned targets without affecting other readers
+			for _, target := range cm.GetScrapeConfigs() {
+				target["targetName"] = "modified"
+				for _, endpoint := range target["endpoints"].([]interface{}) {
+					endpoint.(map[string]interface{})["address"] = "modified"
+				}
+			}
+			cm.GetTargetConfigs()
+			cm.GetDiscoveryIntervals()
+			cm.GetRedactedConfig()
+			cm.Redact("s3cret")
+		})
+		// The scraper's interval lookups take no lock that would order them after a reload
+		read(func() {
+			cm.GetMaxConcurrency()
+			cm.GetMinimumInterval()
+			cm.GetScrapingInterval()
+		})
+	}
+
+	for i := 1; i <= 200; i++ {
+		data := scrapeConfigMap(fmt.Sprintf("target-%d", i)).Data["scrape_config.yaml"]
+		if err := cm.applyConfigData([]byte(data), time.Now()); err != nil {
+			t.Fatalf("reload %d: %v", i, err)
+		}
+	}
+	close(stop)
+	readers.Wait()
+
+	targets := cm.GetTargetConfigs()
+	if len(targets) != 1 || targets[0].TargetName != "target-200" || targets[0].Endpoints[0].Address != "10.0.0.1:9100" {
+		t.Errorf("expected the last update unmodified by readers, got %+v", targets)
+	}
+}
+
+func TestConfigManager_GetScrapeConfigsReturnsCopy(t *testing.T) {
+	t.Setenv("WHATAP_OPEN_HOME", t.TempDir())
+	clientset := fake.NewSimpleClientset(scrapeConfigMap("node-exporter"))
+	cm := newTestConfigManager(&fakeConfigMapSource{clientset: clientset})
+	if err := cm.initFromConfigMap(); err != nil {
+		t.Fatalf("initial load: %v", err)
+	}
+
+	first := cm.GetScrapeConfigs()
+	first[0]["targetName"] = "modified"
+	first[0]["endpoints"].([]interface{})[0].(map[string]interface{})["basicAuth"] = nil
+
+	second := cm.GetScrapeConfigs()
+	if second[0]["targetName"] != "node-exporter" {
+		t.Errorf("expected an unmodified target name, got %v", second[0]["targetName"])
+	}
+	if second[0]["endpoints"].([]interface{})[0].(map[string]interface{})["basicAuth"] == nil {
+		t.Errorf("expected the endpoint to keep its basicAuth")
+	}
+}
+
+func TestConfigManager_ZeroValueHasNoConfig(t *testing.T) {
+	cm := &ConfigManager{}
+	if cm.GetConfig() != nil || cm.GetScrapeConfigs() != nil || cm.GetRedactedConfig() != nil {
+		t.Errorf("expected no configuration before the first load")
+	}
+	if got := cm.GetMinimumInterval(); got != "1s" {
+		t.Errorf("expected the default minimum interval, got %q", got)
+	}
+}
@@ -0,0 +1,152 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// FederatePath is the scrape path of a federate endpoint that does not set path
+const FederatePath = "/federate"
+
+// FederateConfig is the federate section of an endpoint scraping a Prometheus /federate endpoint
+type FederateConfig struct {
+	// MatchSelectors are sent as repeated match[] params, e.g. {job="kafka"}
+	MatchSelectors []string `yaml:"matchSelectors,omitempty"`
+	// HonorLabels keeps the federated job, instance and other labels over the target's (default true)
+	HonorLabels *bool `yaml:"honorLabels,omitempty"`
+	// HonorTimestamps keeps the original sample timestamps federation carries (default true)
+	HonorTimestamps *bool `yaml:"honorTimestamps,omitempty"`
+}
+
+// HonorsLabels reports whether the federated labels win over the target labels
+func (f *FederateConfig) HonorsLabels() bool {
+	return f.HonorLabels == nil || *f.HonorLabels
+}
+
+// HonorsTimestamps reports whether the federated sample timestamps are kept
+func (f *FederateConfig) HonorsTimestamps() bool {
+	return f.HonorTimestamps == nil || *f.HonorTimestamps
+}
+
+// checkFederate rejects a federate section without selectors or with a selector Prometheus would reject,
+// which would otherwise fail on every scrape
+func checkFederate(federate *FederateConfig, params map[string]interface{}, path string) error {
+	if len(federate.MatchSelectors) == 0 {
+		return fmt.Errorf("%s.federate.matchSelectors: at least one selector is required", path)
+	}
+	if _, ok := params["match[]"]; ok {
+		return fmt.Errorf("%s: params.match[] and federate.matchSelectors are mutually exclusive", path)
+	}
+	for i, selector := range federate.MatchSelectors {
+		if err := ValidateSeriesSelector(selector); err != nil {
+			return fmt.Errorf("%s.federate.matchSelectors[%d]: %v", path, i, err)
+		}
+	}
+	return nil
+}
+
+var metricNamePattern = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*`)
+var labelNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*`)
+
+// ValidateSeriesSelector checks the syntax of a PromQL series selector such as up{job="kafka"} or
+// {__name__=~"node_cpu.*"}. Like Prometheus, it rejects selectors that match every series: a selector
+// needs a metric name or a matcher that does not match the empty string.
+func ValidateSeriesSelector(selector string) error {
+	s := strings.TrimSpace(selector)
+	name := metricNamePattern.FindString(s)
+	s = strings.TrimSpace(s[len(name):])
+	if s == "" {
+		if name == "" {
+			return fmt.Errorf("empty selector")
+		}
+		return nil
+	}
+	if s[0] != '{' || s[len(s)-1] != '}' {
+		return fmt.Errorf("invalid selector %q: expected metric{label=\"value\", ...}", selector)
+	}
+	s = strings.TrimSpace(s[1 : len(s)-1])
+
+	selective := name != ""
+	for s != "" {
+		label := labelNamePattern.FindString(s)
+		if label == "" {
+			return fmt.Errorf("invalid selector %q: expected a label name at %q", selector, s)
+		}
+		s = strings.TrimSpace(s[len(label):])
+
+		var op string
+		for _, candidate := range []string{"=~", "!~", "!=", "="} {
+			if strings.HasPrefix(s, candidate) {
+				op = candidate
+				break
+			}
+		}
+		if op == "" {
+			return fmt.Errorf("invalid selector %q: expected =, !=, =~ or !~ after %s", selector, label)
+		}
+		s = strings.TrimSpace(s[len(op):])
+
+		value, rest, err := unquoteLabelValue(s)
+		if err != nil {
+			return fmt.Errorf("invalid selector %q: %v", selector, err)
+		}
+		matchesEmpty := false
+		switch op {
+		case "=":
+			matchesEmpty = value == ""
+		case "!=":
+			matchesEmpty = value != ""
+		default:
+			re, err := regexp.Compile("^(?:" + value + ")$")
+			if err != nil {
+				return fmt.Errorf("invalid selector %q: label %s: %v", selector, label, err)
+			}
+			matchesEmpty = re.MatchString("") == (op == "=~")
+		}
+		if !matchesEmpty {
+			selective = true
+		}
+
+		s = strings.TrimSpace(rest)
+		if s == "" {
+			break
+		}
+		if s[0] != ',' {
+			return fmt.Errorf("invalid selector %q: expected , or } at %q", selector, s)
+		}
+		s = strings.TrimSpace(s[1:])
+	}
+	if !selective {
+		return fmt.Errorf("selector %q matches every series: it needs a metric name or a matcher that does not match the empty string", selector)
+	}
+	return nil
+}
+
+// unquoteLabelValue reads a double-, single- or back-quoted label value from the start of s
+func unquoteLabelValue(s string) (value, rest string, err error) {
+	if s == "" || !strings.ContainsRune("\"'`", rune(s[0])) {
+		return "", "", fmt.Errorf("expected a quoted label value at %q", s)
+	}
+	quote := s[0]
+	for i := 1; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && quote != '`':
+			i++
+		case s[i] == quote:
+			quoted := s[:i+1]
+			if quote == '\'' {
+				// strconv only unquotes single characters in single quotes, so requote with double quotes
+				inner := strings.ReplaceAll(s[1:i], `\'`, `'`)
+				quoted = `"` + strings.ReplaceAll(inner, `"`, `\"`) + `"`
+			}
+			value, err := strconv.Unquote(quoted)
+			if err != nil {
+				return "", "", fmt.Errorf("invalid label value %s", s[:i+1])
+			}
+			return value, s[i+1:], nil
+		}
+	}
+	return "", "", fmt.Errorf("unterminated label value %s", s)
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateSeriesSelector(t *testing.T) {
+	valid := []string{
+		`{job="kafka"}`,
+		`{__name__=~"node_cpu.*"}`,
+		`up`,
+		`up{job="kafka",instance!="a:9100"}`,
+		`{job='kafka', env!~"dev|test"}`,
+		"{job=`kafka`}",
+		`{job="a\"b"}`,
+		`{job="kafka",}`,
+	}
+	for _, selector := range valid {
+		if err := ValidateSeriesSelector(selector); err != nil {
+			t.Errorf("ValidateSeriesSelector(%s): unexpected error %v", selector, err)
+		}
+	}
+
+	invalid := map[string]string{
+		``:                      "empty selector",
+		`{}`:                    "matches every series",
+		`{job=~".*"}`:           "matches every series",
+		`{job!="kafka"}`:        "matches every series",
+		`{job="kafka"`:          "expected metric{",
+		`{job:"kafka"}`:         "expected =, !=, =~ or !~",
+		`{job=kafka}`:           "expected a quoted label value",
+		`{job="kafka}`:          "unterminated label value",
+		`{job="a" env="b"}`:     "expected , or }",
+		`{__name__=~"node_(("}`: "missing closing )",
+		`{1job="kafka"}`:        "expected a label name",
+	}
+	for selector, want := range invalid {
+		err := ValidateSeriesSelector(selector)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("ValidateSeriesSelector(%s) = %v, want an error containing %q", selector, err, want)
+		}
+	}
+}
+
+func TestDecodeTargetConfig_Federate(t *testing.T) {
+	target, warnings := decodeTarget(t, `
+targetName: prometheus
+type: StaticEndpoints
+endpoints:
+  - address: prometheus:9090
+    federate:
+      matchSelectors: ['{job="kafka"}', '{__name__=~"node_cpu.*"}']
+  - address: prometheus:9090
+    federate:
+      matchSelectors: ['{job="kafka"']
+  - address: prometheus:9090
+    federate:
+      honorLabels: false
+  - address: prometheus:9090
+    params:
+      match[]: ['{job="kafka"}']
+    federate:
+      matchSelectors: ['{job="kafka"}']
+      honorTimestamps: false
+`)
+	if len(target.Endpoints) != 1 {
+		t.Fatalf("expected only the valid endpoint, got %+v", target.Endpoints)
+	}
+	federate := target.Endpoints[0].Federate
+	if federate == nil || len(federate.MatchSelectors) != 2 || !federate.HonorsLabels() || !federate.HonorsTimestamps() {
+		t.Errorf("unexpected federate section %+v", federate)
+	}
+	if len(warnings) != 3 ||
+		!strings.Contains(warnings[0], `endpoints[1].federate.matchSelectors[0]: invalid selector`) ||
+		!strings.Contains(warnings[1], `endpoints[2].federate.matchSelectors: at least one selector is required`) ||
+		!strings.Contains(warnings[2], `endpoints[3]: params.match[] and federate.matchSelectors are mutually exclusive`) {
+		t.Errorf("unexpected warnings %q", warnings)
+	}
+}
@@ -0,0 +1,171 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"open-agent/pkg/model"
+)
+
+const (
+	// DefaultScrapeInterval is the interval of endpoints that set none, and whose target and global
+	// section set none either
+	DefaultScrapeInterval = "60s"
+	// DefaultScrapeTimeout is the timeout of endpoints that set none, and whose target and global
+	// section set none either
+	DefaultScrapeTimeout = "60s"
+)
+
+// GlobalConfig is the features.openAgent.global section: scrape defaults for the endpoints and targets
+// that leave them unset, with the field names of a Prometheus global block
+type GlobalConfig struct {
+	ScrapeInterval string            `yaml:"scrape_interval,omitempty"`
+	ScrapeTimeout  string            `yaml:"scrape_timeout,omitempty"`
+	ExternalLabels map[string]string `yaml:"external_labels,omitempty"`
+	// SampleLimit drops every sample of a scrape exposing more samples after metric relabeling, 0 is unlimited
+	SampleLimit int `yaml:"sample_limit,omitempty"`
+	// DefaultMetricRelabelConfigs are applied before the metric relabel configs of every target and endpoint
+	DefaultMetricRelabelConfigs model.RelabelConfigs `yaml:"default_metric_relabel_configs,omitempty"`
+}
+
+// GetGlobalConfig returns the global section of the applied configuration, without the values that
+// failed validation
+func (cm *ConfigManager) GetGlobalConfig() GlobalConfig {
+	global, _, _ := globalConfig(cm.GetConfig())
+	return global
+}
+
+// globalConfig returns the decoded and raw global section of config. A section that fails to decode is
+// ignored and invalid values are left out; both are reported in warnings.
+func globalConfig(config map[string]interface{}) (GlobalConfig, map[string]interface{}, []string) {
+	features, _ := config["features"].(map[interface{}]interface{})
+	openAgent, _ := features["openAgent"].(map[interface{}]interface{})
+	section, ok := openAgent["global"]
+	if !ok || section == nil {
+		return GlobalConfig{}, nil, nil
+	}
+	raw, ok := convertToStringMap(section).(map[string]interface{})
+	if !ok {
+		return GlobalConfig{}, nil, []string{fmt.Sprintf("ignoring global: expected a map, got %T", section)}
+	}
+
+	var global GlobalConfig
+	if err := decodeValue(raw, "global", &global); err != nil {
+		return GlobalConfig{}, nil, []string{fmt.Sprintf("ignoring global: %v", err)}
+	}
+	warnings := unknownFields(raw, reflect.TypeOf(GlobalConfig{}), "global")
+	if global.ScrapeInterval != "" && !validInterval(global.ScrapeInterval) {
+		warnings = append(warnings, fmt.Sprintf("ignoring global.scrape_interval: invalid interval %q", global.ScrapeInterval))
+		global.ScrapeInterval = ""
+	}
+	if global.ScrapeTimeout != "" && !validTimeout(global.ScrapeTimeout) {
+		warnings = append(warnings, fmt.Sprintf("ignoring global.scrape_timeout: invalid duration %q", global.ScrapeTimeout))
+		global.ScrapeTimeout = ""
+	}
+	if global.SampleLimit < 0 {
+		warnings = append(warnings, fmt.Sprintf("ignoring global.sample_limit %d: must not be negative", global.SampleLimit))
+		global.SampleLimit = 0
+	}
+	return global, raw, warnings
+}
+
+// validInterval reports whether an interval is accepted by ParseInterval and positive
+func validInterval(interval string) bool {
+	var cm ConfigManager
+	seconds, err := cm.ParseInterval(interval)
+	return err == nil && seconds > 0
+}
+
+// validTimeout reports whether a timeout is a positive duration
+func validTimeout(timeout string) bool {
+	d, err := time.ParseDuration(timeout)
+	return err == nil && d > 0
+}
+
+// resolveScrapeDefaults fills in the scrape settings the endpoints leave unset, so discovery and the
+// scraper only see merged values. Each setting is taken from the endpoint, else the target, else the
+// global section, else the built-in default; external labels are merged key by key with the same
+// precedence. Metric relabel configs are concatenated instead: the global default ones run before the
+// target's, which discovery puts before the endpoint's. Targets keep their own settings merged with the
+// global ones, for the endpoints discovery adds itself (WhatapAgents).
+//
+// Targets get the raw global section in Raw, so a reload that only changes it is seen as a change of
+// every target: they are rediscovered right away and an endpoint whose interval changed gets a new scheduler.
+func resolveScrapeDefaults(targets []TargetConfig, global GlobalConfig, rawGlobal map[string]interface{}) []TargetConfig {
+	for i := range targets {
+		target := &targets[i]
+		target.Interval = firstNonEmpty(target.Interval, global.ScrapeInterval)
+		target.Timeout = firstNonEmpty(target.Timeout, global.ScrapeTimeout)
+		target.ExternalLabels = mergeLabels(global.ExternalLabels, target.ExternalLabels)
+		if target.SampleLimit == 0 {
+			target.SampleLimit = global.SampleLimit
+		}
+		target.MetricRelabelConfigs = ConcatRelabelConfigs(global.DefaultMetricRelabelConfigs, target.MetricRelabelConfigs)
+
+		endpoints := make([]EndpointConfig, len(target.Endpoints))
+		for j, endpoint := range target.Endpoints {
+			endpoint.Interval = firstNonEmpty(endpoint.Interval, target.Interval, DefaultScrapeInterval)
+			endpoint.Timeout = firstNonEmpty(endpoint.Timeout, target.Timeout, DefaultScrapeTimeout)
+			endpoint.ExternalLabels = mergeLabels(target.ExternalLabels, endpoint.ExternalLabels)
+			if endpoint.SampleLimit == 0 {
+				endpoint.SampleLimit = target.SampleLimit
+			}
+			endpoints[j] = endpoint
+		}
+		if target.Endpoints != nil {
+			target.Endpoints = endpoints
+		}
+
+		if len(rawGlobal) > 0 {
+			raw := make(map[string]interface{}, len(target.Raw)+1)
+			for key, value := range target.Raw {
+				raw[key] = value
+			}
+			raw["global"] = rawGlobal
+			target.Raw = raw
+		}
+	}
+	return targets
+}
+
+// ConcatRelabelConfigs returns the rule lists in order as one list, nil when all are empty. The result
+// never shares its backing array with the arguments.
+func ConcatRelabelConfigs(lists ...model.RelabelConfigs) model.RelabelConfigs {
+	n := 0
+	for _, list := range lists {
+		n += len(list)
+	}
+	if n == 0 {
+		return nil
+	}
+	merged := make(model.RelabelConfigs, 0, n)
+	for _, list := range lists {
+		merged = append(merged, list...)
+	}
+	return merged
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// mergeLabels returns defaults overridden by labels, nil when both are empty
+func mergeLabels(defaults, labels map[string]string) map[string]string {
+	if len(defaults) == 0 && len(labels) == 0 {
+		return nil
+	}
+	merged := make(map[string]string, len(defaults)+len(labels))
+	for key, value := range defaults {
+		merged[key] = value
+	}
+	for key, value := range labels {
+		merged[key] = value
+	}
+	return merged
+}
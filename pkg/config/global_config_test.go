@@ -0,0 +1,214 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"open-agent/pkg/model"
+)
+
+const globalDefaultsConfig = `
+features:
+  openAgent:
+    enabled: true
+    global:
+      scrape_interval: 30s
+      scrape_timeout: 10s
+      sample_limit: 1000
+      external_labels:
+        cluster: prod
+        region: ap-northeast-2
+    targets:
+      - targetName: app
+        type: StaticEndpoints
+        interval: 20s
+        sampleLimit: 500
+        externalLabels:
+          region: ap-northeast-1
+        endpoints:
+          - address: "10.0.0.1:9100"
+            interval: 5s
+            timeout: 3s
+            sampleLimit: 100
+            externalLabels:
+              team: payments
+          - address: "10.0.0.2:9100"
+      - targetName: db
+        type: StaticEndpoints
+        endpoints:
+          - address: "10.0.0.3:9187"
+`
+
+func loadTargets(t *testing.T, cm *ConfigManager, data string) []TargetConfig {
+	t.Helper()
+	if err := cm.applyConfigData([]byte(data), time.Now()); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	targets := cm.GetTargetConfigs()
+	if len(targets) == 0 {
+		t.Fatalf("expected targets")
+	}
+	return targets
+}
+
+func TestResolveScrapeDefaults_Precedence(t *testing.T) {
+	targets := loadTargets(t, &ConfigManager{}, globalDefaultsConfig)
+	app, db := targets[0], targets[1]
+
+	tests := []struct {
+		name           string
+		endpoint       EndpointConfig
+		interval       string
+		timeout        string
+		sampleLimit    int
+		externalLabels map[string]string
+	}{
+		{"endpoint", app.Endpoints[0], "5s", "3s", 100,
+			map[string]string{"cluster": "prod", "region": "ap-northeast-1", "team": "payments"}},
+		{"target", app.Endpoints[1], "20s", "10s", 500,
+			map[string]string{"cluster": "prod", "region": "ap-northeast-1"}},
+		{"global", db.Endpoints[0], "30s", "10s", 1000,
+			map[string]string{"cluster": "prod", "region": "ap-northeast-2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ep := tt.endpoint
+			if ep.Interval != tt.interval || ep.Timeout != tt.timeout || ep.SampleLimit != tt.sampleLimit {
+				t.Errorf("got interval %q, timeout %q, sampleLimit %d; want %q, %q, %d",
+					ep.Interval, ep.Timeout, ep.SampleLimit, tt.interval, tt.timeout, tt.sampleLimit)
+			}
+			if !reflect.DeepEqual(ep.ExternalLabels, tt.externalLabels) {
+				t.Errorf("got external labels %v, want %v", ep.ExternalLabels, tt.externalLabels)
+			}
+		})
+	}
+}
+
+func TestResolveScrapeDefaults_BuiltInDefaults(t *testing.T) {
+	withoutGlobal := strings.Replace(globalDefaultsConfig, "    global:", "    unusedGlobal:", 1)
+	cm := &ConfigManager{}
+	targets := loadTargets(t, cm, withoutGlobal)
+	ep := targets[1].Endpoints[0]
+	if ep.Interval != DefaultScrapeInterval || ep.Timeout != DefaultScrapeTimeout || ep.SampleLimit != 0 || ep.ExternalLabels != nil {
+		t.Errorf("expected the built-in defaults, got %+v", ep)
+	}
+	if got := cm.GetScrapeInterval(); got != DefaultScrapeInterval {
+		t.Errorf("GetScrapeInterval() = %q, want %q", got, DefaultScrapeInterval)
+	}
+	// The target default still applies without a global section
+	if got := targets[0].Endpoints[1].Interval; got != "20s" {
+		t.Errorf("expected the target interval, got %q", got)
+	}
+}
+
+func TestResolveScrapeDefaults_InvalidValuesFallThrough(t *testing.T) {
+	invalid := strings.NewReplacer(
+		"scrape_interval: 30s", "scrape_interval: soon",
+		"sample_limit: 1000", "sample_limit: -1",
+		"interval: 20s", "interval: often",
+	).Replace(globalDefaultsConfig)
+	cm := &ConfigManager{}
+	targets := loadTargets(t, cm, invalid)
+	if got := targets[0].Endpoints[1].Interval; got != DefaultScrapeInterval {
+		t.Errorf("expected the invalid target and global intervals skipped, got %q", got)
+	}
+	if got := targets[1].Endpoints[0].SampleLimit; got != 0 {
+		t.Errorf("expected the negative global sample limit ignored, got %d", got)
+	}
+
+	problems := ValidateScrapeConfig(cm.GetConfig())
+	for _, want := range []string{
+		`target app: ignoring interval: invalid interval "often"`,
+		`ignoring global.scrape_interval: invalid interval "soon"`,
+		`ignoring global.sample_limit -1: must not be negative`,
+	} {
+		if !contains(problems, want) {
+			t.Errorf("expected problem %q, got %q", want, problems)
+		}
+	}
+}
+
+func TestResolveScrapeDefaults_GlobalReload(t *testing.T) {
+	cm := &ConfigManager{}
+	before := loadTargets(t, cm, globalDefaultsConfig)
+	after := loadTargets(t, cm, strings.Replace(globalDefaultsConfig, "scrape_interval: 30s", "scrape_interval: 45s", 1))
+
+	if got := after[1].Endpoints[0].Interval; got != "45s" {
+		t.Errorf("expected the reloaded global interval, got %q", got)
+	}
+	// Discovery fingerprints Raw, so a target whose own settings did not change is rediscovered too
+	if fmt.Sprint(before[1].Raw) == fmt.Sprint(after[1].Raw) {
+		t.Errorf("expected the raw target to change with the global section")
+	}
+}
+
+const metricRelabelLevelsConfig = `
+features:
+  openAgent:
+    enabled: true
+    global:
+      default_metric_relabel_configs:
+        - sourceLabels: [__name__]
+          regex: go_.*
+          action: drop
+    targets:
+      - targetName: app
+        type: StaticEndpoints
+        metricRelabelConfigs:
+          - sourceLabels: [__name__]
+            regex: process_.*
+            action: drop
+        endpoints:
+          - address: "10.0.0.1:9100"
+            metricRelabelConfigs:
+              - sourceLabels: [__name__]
+                regex: promhttp_.*
+                action: drop
+      - targetName: db
+        type: StaticEndpoints
+        endpoints:
+          - address: "10.0.0.3:9187"
+`
+
+func TestResolveScrapeDefaults_MetricRelabelConfigsConcatenated(t *testing.T) {
+	targets := loadTargets(t, &ConfigManager{}, metricRelabelLevelsConfig)
+	app, db := targets[0], targets[1]
+
+	regexes := func(target TargetConfig) []string {
+		var regexes []string
+		for _, rule := range target.MetricRelabelConfigs {
+			regexes = append(regexes, rule.Regex)
+		}
+		return regexes
+	}
+	if got, want := regexes(app), []string{"go_.*", "process_.*"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("app metricRelabelConfigs = %v, want the global rule before the target's %v", got, want)
+	}
+	if got, want := regexes(db), []string{"go_.*"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("db metricRelabelConfigs = %v, want the global rule %v", got, want)
+	}
+	// The endpoint keeps its own rules; discovery appends them after the target's
+	if got := app.Endpoints[0].MetricRelabelConfigs; len(got) != 1 || got[0].Regex != "promhttp_.*" {
+		t.Errorf("expected the endpoint to keep only its own rule, got %+v", got)
+	}
+}
+
+func TestConcatRelabelConfigs(t *testing.T) {
+	if got := ConcatRelabelConfigs(nil, model.RelabelConfigs{}); got != nil {
+		t.Errorf("expected nil for empty lists, got %+v", got)
+	}
+	first := make(model.RelabelConfigs, 1, 4)
+	first[0] = &model.RelabelConfig{Regex: "a"}
+	merged := ConcatRelabelConfigs(first, model.RelabelConfigs{{Regex: "b"}})
+	if len(merged) != 2 || merged[0].Regex != "a" || merged[1].Regex != "b" {
+		t.Fatalf("unexpected merged rules %+v", merged)
+	}
+	// Appending to the merged list must not write into the spare capacity of an argument
+	_ = append(merged[:1], &model.RelabelConfig{Regex: "c"})
+	if first[:2][1] != nil {
+		t.Errorf("expected the merged list not to share the first argument's backing array")
+	}
+}
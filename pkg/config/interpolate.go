@@ -0,0 +1,273 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+)
+
+const (
+	// filePrefix marks a ${file:/path} reference
+	filePrefix = "file:"
+
+	// redactedValue replaces credential values in config dumps
+	redactedValue = "<redacted>"
+
+	// publicEnvKey lists the environment variables whose values may appear in config dumps
+	publicEnvKey = "openagent_config_interpolation_public_env"
+)
+
+// interpolator resolves ${ENV_VAR} and ${file:/path} references in scrape config values.
+// References may be nested, e.g. ${file:${SECRET_DIR}/token}. "$${" is an escaped literal "${".
+// Every file value and every environment value not listed in publicEnv is a credential: alongside
+// the expanded config it builds a redacted copy with <redacted> where those values were expanded.
+type interpolator struct {
+	strict    bool
+	lookupEnv func(string) (string, bool)
+	readFile  func(string) ([]byte, error)
+	publicEnv map[string]bool
+
+	// missing holds unresolved references (non-strict mode only)
+	missing []string
+	// secrets holds resolved values that must not appear in config dumps
+	secrets []string
+}
+
+// newInterpolator creates an interpolator reading the process environment and local files
+func newInterpolator(strict bool) *interpolator {
+	publicEnv := make(map[string]bool)
+	for _, name := range instance.GetStringArray(publicEnvKey, "", ",") {
+		publicEnv[name] = true
+	}
+	return &interpolator{
+		strict:    strict,
+		lookupEnv: os.LookupEnv,
+		readFile:  ioutil.ReadFile,
+		publicEnv: publicEnv,
+	}
+}
+
+// interpolate walks the parsed YAML tree and expands references in every string value in place.
+// It returns the expanded value and a redacted copy of it for config dumps. Map keys are left untouched.
+func (ip *interpolator) interpolate(value interface{}) (interface{}, interface{}, error) {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		redacted := make(map[interface{}]interface{}, len(v))
+		for k, val := range v {
+			expanded, redactedVal, err := ip.interpolate(val)
+			if err != nil {
+				return nil, nil, err
+			}
+			v[k] = expanded
+			redacted[k] = redactedVal
+		}
+		return v, redacted, nil
+	case map[string]interface{}:
+		redacted := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			expanded, redactedVal, err := ip.interpolate(val)
+			if err != nil {
+				return nil, nil, err
+			}
+			v[k] = expanded
+			redacted[k] = redactedVal
+		}
+		return v, redacted, nil
+	case []interface{}:
+		redacted := make([]interface{}, len(v))
+		for i, val := range v {
+			expanded, redactedVal, err := ip.interpolate(val)
+			if err != nil {
+				return nil, nil, err
+			}
+			v[i] = expanded
+			redacted[i] = redactedVal
+		}
+		return v, redacted, nil
+	case string:
+		return ip.expand(v)
+	default:
+		return value, value, nil
+	}
+}
+
+// expand resolves all references in a single string value. The redacted string has <redacted>
+// at the position of every credential expanded into the value.
+func (ip *interpolator) expand(s string) (string, string, error) {
+	if !strings.Contains(s, "${") {
+		return s, s, nil
+	}
+
+	var sb, redacted strings.Builder
+	for i := 0; i < len(s); i++ {
+		if strings.HasPrefix(s[i:], "$${") {
+			sb.WriteString("${")
+			redacted.WriteString("${")
+			i += 2
+			continue
+		}
+		if !strings.HasPrefix(s[i:], "${") {
+			sb.WriteByte(s[i])
+			redacted.WriteByte(s[i])
+			continue
+		}
+
+		end := matchingBrace(s, i+2)
+		if end < 0 {
+			// Unterminated reference, keep the rest as is
+			sb.WriteString(s[i:])
+			redacted.WriteString(s[i:])
+			break
+		}
+
+		// Resolve nested references first; they only name the reference, so their redacted form is unused
+		ref, _, err := ip.expand(s[i+2 : end])
+		if err != nil {
+			return "", "", err
+		}
+		resolved, secret, err := ip.resolve(ref)
+		if err != nil {
+			return "", "", err
+		}
+		sb.WriteString(resolved)
+		if secret && resolved != "" {
+			redacted.WriteString(redactedValue)
+		} else {
+			redacted.WriteString(resolved)
+		}
+		i = end
+	}
+	return sb.String(), redacted.String(), nil
+}
+
+// resolve looks up a single reference and reports whether its value is a credential
+func (ip *interpolator) resolve(ref string) (string, bool, error) {
+	if strings.HasPrefix(ref, filePrefix) {
+		path := strings.TrimSpace(strings.TrimPrefix(ref, filePrefix))
+		data, err := ip.readFile(path)
+		if err != nil {
+			return ip.unresolved(ref, fmt.Sprintf("file %s could not be read: %v", path, err))
+		}
+		value := strings.TrimRight(string(data), "\r\n")
+		// File-sourced values are always treated as credentials
+		ip.addSecret(value)
+		return value, true, nil
+	}
+
+	name := strings.TrimSpace(ref)
+	value, ok := ip.lookupEnv(name)
+	if !ok {
+		return ip.unresolved(ref, fmt.Sprintf("environment variable %s is not set", name))
+	}
+	if ip.publicEnv[name] {
+		return value, false, nil
+	}
+	ip.addSecret(value)
+	return value, true, nil
+}
+
+// unresolved fails in strict mode and otherwise keeps the literal reference
+func (ip *interpolator) unresolved(ref string, reason string) (string, bool, error) {
+	if ip.strict {
+		return "", false, fmt.Errorf("unresolved reference ${%s}: %s", ref, reason)
+	}
+	ip.missing = append(ip.missing, ref)
+	return "${" + ref + "}", false, nil
+}
+
+func (ip *interpolator) addSecret(value string) {
+	if value != "" {
+		ip.secrets = append(ip.secrets, value)
+	}
+}
+
+// matchingBrace returns the index of the "}" closing a reference whose body starts at start
+func matchingBrace(s string, start int) int {
+	depth := 1
+	for j := start; j < len(s); j++ {
+		switch {
+		case strings.HasPrefix(s[j:], "${"):
+			depth++
+			j++
+		case s[j] == '}':
+			depth--
+			if depth == 0 {
+				return j
+			}
+		}
+	}
+	return -1
+}
+
+// redactString replaces the secrets occurring in free text such as captured samples, where the
+// expansion positions are unknown. secrets must be sorted longest first. Only whole words are
+// replaced, so a short secret does not mangle the longer words that happen to contain it.
+func redactString(s string, secrets []string) string {
+	if len(secrets) == 0 {
+		return s
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); {
+		if matched := secretAt(s, i, secrets); matched > 0 {
+			sb.WriteString(redactedValue)
+			i += matched
+			continue
+		}
+		sb.WriteByte(s[i])
+		i++
+	}
+	return sb.String()
+}
+
+// secretAt returns the length of the secret that forms a whole word starting at s[i], or 0
+func secretAt(s string, i int, secrets []string) int {
+	if i > 0 && isWordByte(s[i-1]) {
+		return 0
+	}
+	for _, secret := range secrets {
+		end := i + len(secret)
+		if strings.HasPrefix(s[i:], secret) && (end == len(s) || !isWordByte(s[end])) {
+			return len(secret)
+		}
+	}
+	return 0
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// copyValue returns a deep copy of a parsed YAML value
+func copyValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		result := make(map[interface{}]interface{}, len(v))
+		for k, val := range v {
+			result[k] = copyValue(val)
+		}
+		return result
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			result[k] = copyValue(val)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, val := range v {
+			result[i] = copyValue(val)
+		}
+		return result
+	default:
+		return value
+	}
+}
+
+// sortSecrets orders secrets longest first so overlapping values are fully redacted
+func sortSecrets(secrets []string) []string {
+	sorted := append([]string(nil), secrets...)
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+	return sorted
+}
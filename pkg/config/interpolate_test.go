@@ -0,0 +1,184 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+const interpolationYAML = `
+features:
+  openAgent:
+    enabled: true
+    targets:
+      - targetName: ${CLUSTER_NAME}-apiserver
+        type: StaticEndpoints
+        endpoints:
+          - address: "${TARGET_HOST}:443"
+            basicAuth:
+              username: admin
+              password: ${file:${SECRET_DIR}/password}
+            params:
+              cluster: [ "${CLUSTER_NAME}" ]
+              literal: "$${NOT_EXPANDED}"
+`
+
+func parseInterpolationYAML(t *testing.T) map[string]interface{} {
+	t.Helper()
+	var cfg map[string]interface{}
+	if err := yaml.Unmarshal([]byte(interpolationYAML), &cfg); err != nil {
+		t.Fatalf("yaml: %v", err)
+	}
+	return cfg
+}
+
+func firstEndpoint(cfg map[string]interface{}) (map[interface{}]interface{}, map[interface{}]interface{}) {
+	openAgent := cfg["features"].(map[interface{}]interface{})["openAgent"].(map[interface{}]interface{})
+	target := openAgent["targets"].([]interface{})[0].(map[interface{}]interface{})
+	endpoint := target["endpoints"].([]interface{})[0].(map[interface{}]interface{})
+	return target, endpoint
+}
+
+func TestInterpolate_NestedReferences(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "password"), []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	t.Setenv("CLUSTER_NAME", "prod-a")
+	t.Setenv("TARGET_HOST", "10.0.0.1")
+	t.Setenv("SECRET_DIR", dir)
+	t.Setenv(publicEnvKey, "CLUSTER_NAME, TARGET_HOST")
+
+	cfg := parseInterpolationYAML(t)
+	ip := newInterpolator(true)
+	_, redacted, err := ip.interpolate(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	target, endpoint := firstEndpoint(cfg)
+	if got := target["targetName"]; got != "prod-a-apiserver" {
+		t.Errorf("targetName = %v", got)
+	}
+	if got := endpoint["address"]; got != "10.0.0.1:443" {
+		t.Errorf("address = %v", got)
+	}
+	basicAuth := endpoint["basicAuth"].(map[interface{}]interface{})
+	if got := basicAuth["password"]; got != "s3cret" {
+		t.Errorf("password = %v", got)
+	}
+	params := endpoint["params"].(map[interface{}]interface{})
+	if got := params["cluster"].([]interface{})[0]; got != "prod-a" {
+		t.Errorf("params.cluster = %v", got)
+	}
+	if got := params["literal"]; got != "${NOT_EXPANDED}" {
+		t.Errorf("escaped literal = %v", got)
+	}
+
+	// File-sourced values are redacted from dumps, allow-listed environment values are not
+	_, redactedEndpoint := firstEndpoint(redacted.(map[string]interface{}))
+	if got := redactedEndpoint["basicAuth"].(map[interface{}]interface{})["password"]; got != redactedValue {
+		t.Errorf("expected password to be redacted, got %v", got)
+	}
+	if got := redactedEndpoint["address"]; got != "10.0.0.1:443" {
+		t.Errorf("expected address to stay visible, got %v", got)
+	}
+	// The original config keeps the real value
+	if got := basicAuth["password"]; got != "s3cret" {
+		t.Errorf("redaction must not modify the live config, got %v", got)
+	}
+}
+
+func TestInterpolate_MissingVariableStrict(t *testing.T) {
+	t.Setenv("CLUSTER_NAME", "prod-a")
+	os.Unsetenv("TARGET_HOST")
+	os.Unsetenv("SECRET_DIR")
+
+	ip := newInterpolator(true)
+	_, _, err := ip.interpolate(parseInterpolationYAML(t))
+	if err == nil {
+		t.Fatalf("expected strict mode to fail on a missing variable")
+	}
+	if !strings.Contains(err.Error(), "unresolved reference") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestInterpolate_MissingVariableNonStrict(t *testing.T) {
+	t.Setenv("CLUSTER_NAME", "prod-a")
+	os.Unsetenv("TARGET_HOST")
+	os.Unsetenv("SECRET_DIR")
+
+	cfg := parseInterpolationYAML(t)
+	ip := newInterpolator(false)
+	if _, _, err := ip.interpolate(cfg); err != nil {
+		t.Fatalf("unexpected error in non-strict mode: %v", err)
+	}
+
+	target, endpoint := firstEndpoint(cfg)
+	if got := target["targetName"]; got != "prod-a-apiserver" {
+		t.Errorf("targetName = %v", got)
+	}
+	if got := endpoint["address"]; got != "${TARGET_HOST}:443" {
+		t.Errorf("expected literal to be kept, got %v", got)
+	}
+	if len(ip.missing) == 0 {
+		t.Errorf("expected missing references to be recorded")
+	}
+}
+
+func TestInterpolate_EnvValuesRedactedUnlessPublic(t *testing.T) {
+	t.Setenv("DB_CREDS", "admin:hunter2")
+	t.Setenv("SHARD", "a")
+	t.Setenv("NAMESPACE", "monitoring")
+	t.Setenv(publicEnvKey, "NAMESPACE")
+
+	cfg := map[string]interface{}{
+		"dsn":       "postgres://${DB_CREDS}@db:5432/app",
+		"shard":     "${SHARD}-metrics",
+		"path":      "/metrics/a",
+		"namespace": "${NAMESPACE}",
+	}
+	ip := newInterpolator(true)
+	_, redacted, err := ip.interpolate(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg["dsn"] != "postgres://admin:hunter2@db:5432/app" {
+		t.Fatalf("dsn = %v", cfg["dsn"])
+	}
+
+	// Values are redacted where they were expanded; a short value leaves the rest of the dump intact
+	want := map[string]interface{}{
+		"dsn":       "postgres://<redacted>@db:5432/app",
+		"shard":     "<redacted>-metrics",
+		"path":      "/metrics/a",
+		"namespace": "monitoring",
+	}
+	for key, value := range want {
+		if got := redacted.(map[string]interface{})[key]; got != value {
+			t.Errorf("redacted %s = %v, want %v", key, got, value)
+		}
+	}
+}
+
+func TestRedactString_WholeWordsOnly(t *testing.T) {
+	t.Setenv("API_TOKEN", "tok-123")
+	t.Setenv("SHARD", "a")
+
+	cfg := map[string]interface{}{"bearerToken": "${API_TOKEN}", "shard": "${SHARD}"}
+	ip := newInterpolator(true)
+	if _, _, err := ip.interpolate(cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	secrets := sortSecrets(ip.secrets)
+	if got := redactString("Authorization: Bearer tok-123", secrets); got != "Authorization: Bearer <redacted>" {
+		t.Errorf("expected token to be redacted, got %q", got)
+	}
+	if got := redactString(`up{shard="a",job="api"} 1`, secrets); got != `up{shard="<redacted>",job="api"} 1` {
+		t.Errorf("expected only the whole value to be redacted, got %q", got)
+	}
+}
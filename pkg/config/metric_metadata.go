@@ -0,0 +1,7 @@
+package config
+
+// SendMetricMetadata reports whether metric HELP/TYPE metadata (OpenMxHelpPacks) is sent at all.
+// Read from whatap.conf on every use, so changes apply without a restart.
+func SendMetricMetadata() bool {
+	return GetBoolWithDefault("openagent_send_metric_metadata", true)
+}
@@ -0,0 +1,57 @@
+package config
+
+import "fmt"
+
+// RedactedParam stands in for the value of a param read from a Secret wherever the scrape URL is shown:
+// the target URL in /targets, logs and the __param_<name> label
+const RedactedParam = "***"
+
+// SecretParam returns the Secret reference of an endpoint param written as {secretKeyRef: {name, key}},
+// false for literal values
+func SecretParam(value interface{}) (SecretKeySelector, bool) {
+	ref, ok := convertToStringMap(value).(map[string]interface{})
+	if !ok {
+		return SecretKeySelector{}, false
+	}
+	selector, ok := convertToStringMap(ref["secretKeyRef"]).(map[string]interface{})
+	if !ok {
+		return SecretKeySelector{}, false
+	}
+	name, _ := selector["name"].(string)
+	key, _ := selector["key"].(string)
+	namespace, _ := selector["namespace"].(string)
+	return SecretKeySelector{Name: name, Key: key, Namespace: namespace}, true
+}
+
+// SecretParams returns the params of an endpoint read from Secrets, nil when it has none. They are
+// resolved on every scrape, so a rotated Secret takes effect without a discovery cycle.
+func SecretParams(params map[string]interface{}) map[string]SecretKeySelector {
+	var secrets map[string]SecretKeySelector
+	for name, value := range params {
+		if selector, ok := SecretParam(value); ok {
+			if secrets == nil {
+				secrets = make(map[string]SecretKeySelector)
+			}
+			secrets[name] = selector
+		}
+	}
+	return secrets
+}
+
+// checkParams rejects params that are maps but not a complete secretKeyRef, which would otherwise be
+// sent as their Go representation
+func checkParams(params map[string]interface{}, path string) error {
+	for name, value := range params {
+		if _, ok := convertToStringMap(value).(map[string]interface{}); !ok {
+			continue
+		}
+		selector, ok := SecretParam(value)
+		if !ok {
+			return fmt.Errorf("%s.params.%s: expected a string, a list or {secretKeyRef: {name, key}}", path, name)
+		}
+		if selector.Name == "" || selector.Key == "" {
+			return fmt.Errorf("%s.params.%s.secretKeyRef: name and key are required", path, name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"sort"
+	"time"
+)
+
+// DefaultSelfTestDuration is how long the self-test target is scraped at most when selfTestDuration is unset
+const DefaultSelfTestDuration = 2 * time.Minute
+
+// forceSelfTest is set by the --self-test flag and runs the self-test regardless of the selfTest option
+var forceSelfTest bool
+
+// SetSelfTest sets the --self-test flag
+func SetSelfTest(enabled bool) {
+	forceSelfTest = enabled
+}
+
+// SelfTestEnabled reports whether the startup self-test runs, by the --self-test flag or
+// features.openAgent.selfTest: true
+func (cm *ConfigManager) SelfTestEnabled() bool {
+	if forceSelfTest {
+		return true
+	}
+	features, _ := cm.GetConfig()["features"].(map[interface{}]interface{})
+	openAgent, _ := features["openAgent"].(map[interface{}]interface{})
+	enabled, _ := openAgent["selfTest"].(bool)
+	return enabled
+}
+
+// SelfTestDuration returns features.openAgent.selfTestDuration, DefaultSelfTestDuration when it is unset
+// or not a positive duration
+func (cm *ConfigManager) SelfTestDuration() time.Duration {
+	features, _ := cm.GetConfig()["features"].(map[interface{}]interface{})
+	openAgent, _ := features["openAgent"].(map[interface{}]interface{})
+	if value, ok := openAgent["selfTestDuration"].(string); ok {
+		if d, err := time.ParseDuration(value); err == nil && d > 0 {
+			return d
+		}
+	}
+	return DefaultSelfTestDuration
+}
+
+// AddInternalTarget adds a target the agent scrapes on its own, such as the self-test target, to the
+// configured targets until RemoveInternalTarget. It is not part of the configuration: reloads keep it
+// and it is not validated or shown in the configuration dump.
+func (cm *ConfigManager) AddInternalTarget(target TargetConfig) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	if cm.internalTargets == nil {
+		cm.internalTargets = make(map[string]TargetConfig)
+	}
+	cm.internalTargets[target.TargetName] = target
+}
+
+// RemoveInternalTarget removes a target added by AddInternalTarget; discovery drops it on its next cycle
+func (cm *ConfigManager) RemoveInternalTarget(name string) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	delete(cm.internalTargets, name)
+}
+
+// internalTargetConfigs returns the internal targets sorted by name
+func (cm *ConfigManager) internalTargetConfigs() []TargetConfig {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+	targets := make([]TargetConfig, 0, len(cm.internalTargets))
+	for _, target := range cm.internalTargets {
+		targets = append(targets, target)
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].TargetName < targets[j].TargetName })
+	return targets
+}
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInternalTargets(t *testing.T) {
+	cm := &ConfigManager{}
+	if targets := cm.GetTargetConfigs(); targets != nil {
+		t.Fatalf("expected no targets without configuration, got %d", len(targets))
+	}
+
+	internal, _, err := DecodeTargetConfig(map[string]interface{}{
+		"targetName": "internal",
+		"type":       "StaticEndpoints",
+		"endpoints":  []interface{}{map[string]interface{}{"address": "127.0.0.1:9100"}},
+	})
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	cm.AddInternalTarget(internal)
+	targets := cm.GetTargetConfigs()
+	if len(targets) != 1 || targets[0].TargetName != "internal" {
+		t.Fatalf("expected the internal target without configuration, got %+v", targets)
+	}
+
+	targets = loadTargets(t, cm, globalDefaultsConfig)
+	last := targets[len(targets)-1]
+	if last.TargetName != "internal" {
+		t.Fatalf("expected the internal target after the configured ones, got %s", last.TargetName)
+	}
+	// Internal targets get the global defaults like the configured ones
+	if last.Endpoints[0].Interval != "30s" || last.Endpoints[0].ExternalLabels["cluster"] != "prod" {
+		t.Errorf("expected the global defaults on the internal target, got %+v", last.Endpoints[0])
+	}
+
+	cm.RemoveInternalTarget("internal")
+	for _, target := range cm.GetTargetConfigs() {
+		if target.TargetName == "internal" {
+			t.Fatal("expected the internal target to be removed")
+		}
+	}
+}
+
+func TestSelfTestSettings(t *testing.T) {
+	defer SetSelfTest(false)
+
+	cm := &ConfigManager{}
+	if cm.SelfTestEnabled() || cm.SelfTestDuration() != DefaultSelfTestDuration {
+		t.Fatal("expected the self-test to be off by default")
+	}
+	SetSelfTest(true)
+	if !cm.SelfTestEnabled() {
+		t.Fatal("expected --self-test to enable the self-test")
+	}
+	SetSelfTest(false)
+
+	data := `
+features:
+  openAgent:
+    selfTest: true
+    selfTestDuration: "30s"
+    targets: []
+`
+	if err := cm.applyConfigData([]byte(data), time.Now()); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if !cm.SelfTestEnabled() || cm.SelfTestDuration() != 30*time.Second {
+		t.Errorf("expected selfTest: true for 30s, got %v for %v", cm.SelfTestEnabled(), cm.SelfTestDuration())
+	}
+}
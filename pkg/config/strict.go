@@ -0,0 +1,155 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"open-agent/tools/util/logutil"
+)
+
+// ExitCodeInvalidConfig is the worker exit code when the startup configuration fails strict validation
+const ExitCodeInvalidConfig = 2
+
+// forceStrictConfig is set by the --strict-config flag and enables strict mode regardless of the
+// strictConfig option
+var forceStrictConfig bool
+
+// exitProcess is replaced in tests
+var exitProcess = os.Exit
+
+// SetStrictConfig sets the --strict-config flag
+func SetStrictConfig(strict bool) {
+	forceStrictConfig = strict
+}
+
+// StrictConfigError is returned when strict mode rejects a configuration with validation problems
+type StrictConfigError struct {
+	Problems []string
+}
+
+func (e *StrictConfigError) Error() string {
+	return fmt.Sprintf("strictConfig: %d problem(s): %s", len(e.Problems), strings.Join(e.Problems, "; "))
+}
+
+// strictConfigEnabled reports whether config is validated strictly, by the --strict-config flag or
+// features.openAgent.strictConfig: true
+func strictConfigEnabled(config map[string]interface{}) bool {
+	if forceStrictConfig {
+		return true
+	}
+	features, _ := config["features"].(map[interface{}]interface{})
+	openAgent, _ := features["openAgent"].(map[interface{}]interface{})
+	strict, _ := openAgent["strictConfig"].(bool)
+	return strict
+}
+
+// checkStrictConfig returns a StrictConfigError if strict mode is enabled and config has problems
+func checkStrictConfig(config map[string]interface{}) error {
+	if !strictConfigEnabled(config) {
+		return nil
+	}
+	if problems := ValidateScrapeConfig(config); len(problems) > 0 {
+		return &StrictConfigError{Problems: problems}
+	}
+	return nil
+}
+
+// ValidateScrapeConfig returns every problem the lenient mode tolerates: targets that fail to decode,
+// unknown fields, ignored endpoints, unparseable intervals and timeouts, unknown or forbidden TLS
+// versions, and PodMonitor/ServiceMonitor targets without a selector
+func ValidateScrapeConfig(config map[string]interface{}) []string {
+	targets, warnings, errs := DecodeTargetConfigs(scrapeTargets(config))
+	targets, profileWarnings := resolveAuthProfiles(targets, config, ActiveProfile())
+	warnings = append(warnings, profileWarnings...)
+	_, _, globalWarnings := globalConfig(config)
+	warnings = append(warnings, globalWarnings...)
+	problems := make([]string, 0, len(errs)+len(warnings))
+	for _, err := range errs {
+		problems = append(problems, err.Error())
+	}
+	problems = append(problems, warnings...)
+
+	var cm ConfigManager
+	for _, target := range targets {
+		if (target.Type == "PodMonitor" || target.Type == "ServiceMonitor") && target.Selector.Empty() {
+			problems = append(problems, fmt.Sprintf("target %s: selector is empty and matches every %s",
+				target.TargetName, strings.TrimSuffix(strings.ToLower(target.Type), "monitor")))
+		}
+		for i, endpoint := range target.Endpoints {
+			if endpoint.Interval != "" {
+				if seconds, err := cm.ParseInterval(endpoint.Interval); err != nil || seconds <= 0 {
+					problems = append(problems, fmt.Sprintf("target %s: endpoints[%d].interval: invalid interval %q",
+						target.TargetName, i, endpoint.Interval))
+				}
+			}
+			if err := tlsVersionProblem(endpoint.TLSConfig); err != nil {
+				problems = append(problems, fmt.Sprintf("target %s: endpoints[%d].tlsConfig.%v", target.TargetName, i, err))
+			}
+			ports := make([]string, 0, len(endpoint.PortConfigs))
+			for port := range endpoint.PortConfigs {
+				ports = append(ports, port)
+			}
+			sort.Strings(ports)
+			for _, port := range ports {
+				portConfig := endpoint.PortConfigs[port]
+				if portConfig.Interval == "" {
+					continue
+				}
+				if seconds, err := cm.ParseInterval(portConfig.Interval); err != nil || seconds <= 0 {
+					problems = append(problems, fmt.Sprintf("target %s: endpoints[%d].portConfigs.%s.interval: invalid interval %q",
+						target.TargetName, i, port, portConfig.Interval))
+				}
+			}
+			for field, value := range map[string]string{
+				"timeout":        endpoint.Timeout,
+				"connectTimeout": endpoint.ConnectTimeout,
+				"readTimeout":    endpoint.ReadTimeout,
+			} {
+				if value == "" {
+					continue
+				}
+				if d, err := time.ParseDuration(value); err != nil || d <= 0 {
+					problems = append(problems, fmt.Sprintf("target %s: endpoints[%d].%s: invalid duration %q",
+						target.TargetName, i, field, value))
+				}
+			}
+		}
+	}
+	return problems
+}
+
+// rejectedInStrictMode logs a reload rejected by strict mode once per distinct problem set and reports
+// whether err is such a rejection
+func (cm *ConfigManager) rejectedInStrictMode(err error) bool {
+	var strictErr *StrictConfigError
+	if !errors.As(err, &strictErr) {
+		return false
+	}
+	key := strings.Join(strictErr.Problems, "\n")
+	cm.mu.Lock()
+	changed := key != cm.lastStrictRejection
+	cm.lastStrictRejection = key
+	cm.mu.Unlock()
+	if changed {
+		logutil.Printf("ERROR", "[CONFIG] Scrape configuration rejected by strictConfig, keeping the previous configuration:\n  %s",
+			strings.Join(strictErr.Problems, "\n  "))
+	}
+	return true
+}
+
+// exitOnStrictConfigError exits the worker when the startup configuration fails strict validation
+func exitOnStrictConfigError(err error) {
+	var strictErr *StrictConfigError
+	if !errors.As(err, &strictErr) {
+		return
+	}
+	for _, problem := range strictErr.Problems {
+		logutil.Printf("ERROR", "[CONFIG] strictConfig: %s", problem)
+	}
+	logutil.Printf("ERROR", "[CONFIG] Scrape configuration has %d problem(s) and strictConfig is set, exiting", len(strictErr.Problems))
+	exitProcess(ExitCodeInvalidConfig)
+}
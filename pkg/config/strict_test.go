@@ -0,0 +1,165 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func strictConfigMap(strict bool, target string) *corev1.ConfigMap {
+	strictLine := ""
+	if strict {
+		strictLine = "    strictConfig: true\n"
+	}
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "whatap-monitoring", Name: "whatap-open-agent-config"},
+		Data: map[string]string{"scrape_config.yaml": "features:\n  openAgent:\n    enabled: true\n" + strictLine +
+			"    targets:\n" + target},
+	}
+}
+
+const validTarget = `      - targetName: node-exporter
+        type: StaticEndpoints
+        endpoints:
+          - address: "10.0.0.1:9100"
+            interval: "30s"
+`
+
+const invalidTarget = `      - targetName: kube-state-metrics
+        type: StaticEndpoints
+        endpoints:
+          - address: "10.0.0.2:8080"
+            intervall: "30s"
+            interval: "often"
+`
+
+func TestValidateScrapeConfig(t *testing.T) {
+	cm := newTestConfigManager(&fakeConfigMapSource{clientset: fake.NewSimpleClientset(strictConfigMap(false, invalidTarget+`      - targetName: every-pod
+        type: PodMonitor
+        endpoints:
+          - port: metrics
+            timeout: "10"
+          - ports: [8080, 9091]
+            portConfigs:
+              "9091":
+                interval: "soon"
+          - port: metrics
+            ports: [9100]
+`))})
+	t.Setenv("WHATAP_OPEN_HOME", t.TempDir())
+	if err := cm.LoadConfig(); err != nil {
+		t.Fatalf("lenient load: %v", err)
+	}
+
+	problems := strings.Join(ValidateScrapeConfig(cm.GetConfig()), "\n")
+	for _, want := range []string{
+		"target kube-state-metrics: unknown field endpoints[0].intervall",
+		`target kube-state-metrics: endpoints[0].interval: invalid interval "often"`,
+		"target every-pod: selector is empty and matches every pod",
+		`target every-pod: endpoints[0].timeout: invalid duration "10"`,
+		`target every-pod: endpoints[1].portConfigs.9091.interval: invalid interval "soon"`,
+		"target every-pod: ignoring endpoint: endpoints[2]: port and ports are mutually exclusive",
+	} {
+		if !strings.Contains(problems, want) {
+			t.Errorf("expected problem %q, got:\n%s", want, problems)
+		}
+	}
+}
+
+func TestStrictConfig_FatalAtStartup(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("WHATAP_OPEN_HOME", home)
+
+	// A cached configuration is not a fallback for a configuration strict mode rejects
+	cacheDir := filepath.Join(home, "cache")
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	cached := strictConfigMap(true, validTarget).Data["scrape_config.yaml"]
+	if err := os.WriteFile(filepath.Join(cacheDir, "scrape_config.last.yaml"), []byte(cached), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cm := newTestConfigManager(&fakeConfigMapSource{clientset: fake.NewSimpleClientset(strictConfigMap(true, invalidTarget))})
+	err := cm.initFromConfigMap()
+	var strictErr *StrictConfigError
+	if !errors.As(err, &strictErr) {
+		t.Fatalf("expected a StrictConfigError, got %v", err)
+	}
+	if len(strictErr.Problems) != 2 {
+		t.Errorf("expected 2 problems, got %v", strictErr.Problems)
+	}
+	if cm.GetConfig() != nil {
+		t.Errorf("expected no configuration to be applied")
+	}
+
+	exitCode := -1
+	exitProcess = func(code int) { exitCode = code }
+	t.Cleanup(func() { exitProcess = os.Exit })
+	exitOnStrictConfigError(err)
+	if exitCode != ExitCodeInvalidConfig {
+		t.Errorf("exit code = %d, want %d", exitCode, ExitCodeInvalidConfig)
+	}
+}
+
+func TestStrictConfig_LenientByDefault(t *testing.T) {
+	t.Setenv("WHATAP_OPEN_HOME", t.TempDir())
+	cm := newTestConfigManager(&fakeConfigMapSource{clientset: fake.NewSimpleClientset(strictConfigMap(false, invalidTarget))})
+	if err := cm.initFromConfigMap(); err != nil {
+		t.Fatalf("expected the lenient mode to accept the configuration: %v", err)
+	}
+
+	// The --strict-config flag enables strict mode without the option
+	SetStrictConfig(true)
+	t.Cleanup(func() { SetStrictConfig(false) })
+	flagged := newTestConfigManager(&fakeConfigMapSource{clientset: fake.NewSimpleClientset(strictConfigMap(false, invalidTarget))})
+	var strictErr *StrictConfigError
+	if !errors.As(flagged.initFromConfigMap(), &strictErr) {
+		t.Errorf("expected --strict-config to reject the configuration")
+	}
+}
+
+func TestStrictConfig_RejectsBadReload(t *testing.T) {
+	t.Setenv("WHATAP_OPEN_HOME", t.TempDir())
+	ctx := context.Background()
+	clientset := fake.NewSimpleClientset(strictConfigMap(true, validTarget))
+	cm := newTestConfigManager(&fakeConfigMapSource{clientset: clientset})
+	if err := cm.initFromConfigMap(); err != nil {
+		t.Fatalf("initial load: %v", err)
+	}
+
+	if _, err := clientset.CoreV1().ConfigMaps("whatap-monitoring").Update(ctx, strictConfigMap(true, validTarget+invalidTarget), metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	// The reload is rejected as a whole: not even the valid target of the new configuration is applied
+	if names := targetNames(cm); len(names) != 1 || names[0] != "node-exporter" {
+		t.Fatalf("expected the previous configuration to be kept, got %v", names)
+	}
+	if got := cm.ConfigGeneration(); got != 1 {
+		t.Errorf("generation = %d, want 1", got)
+	}
+	if cm.IsUsingCachedConfig() {
+		t.Errorf("a rejected reload must not be reported as a missing ConfigMap")
+	}
+	cached, _ := os.ReadFile(lastConfigCacheFile())
+	if strings.Contains(string(cached), "kube-state-metrics") {
+		t.Errorf("a rejected configuration must not be cached")
+	}
+
+	// A fixed configuration is applied again
+	fixed := strings.Replace(invalidTarget, "            intervall: \"30s\"\n", "", 1)
+	fixed = strings.Replace(fixed, `"often"`, `"60s"`, 1)
+	if _, err := clientset.CoreV1().ConfigMaps("whatap-monitoring").Update(ctx, strictConfigMap(true, validTarget+fixed), metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if names := targetNames(cm); len(names) != 2 {
+		t.Errorf("expected the fixed configuration to be applied, got %v", names)
+	}
+}
@@ -0,0 +1,531 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"open-agent/pkg/model"
+	"open-agent/pkg/selector"
+)
+
+// TargetConfig is one entry of openAgent.targets
+type TargetConfig struct {
+	TargetName          string                      `yaml:"targetName"`
+	Type                string                      `yaml:"type"` // "PodMonitor", "ServiceMonitor", "StaticEndpoints", "WhatapAgents", "EtcdMonitor"
+	Enabled             *bool                       `yaml:"enabled,omitempty"`
+	NamespaceSelector   *selector.NamespaceSelector `yaml:"namespaceSelector,omitempty"`
+	Selector            *selector.Selector          `yaml:"selector,omitempty"`
+	ExcludeSelector     *selector.Selector          `yaml:"excludeSelector,omitempty"`
+	ExcludePodNames     []string                    `yaml:"excludePodNames,omitempty"`
+	ExcludeServiceNames []string                    `yaml:"excludeServiceNames,omitempty"`
+	ScrapeNotReadyPods  bool                        `yaml:"scrapeNotReadyPods,omitempty"`
+	ReadyGracePeriod    string                      `yaml:"readyGracePeriod,omitempty"`
+	ProxyViaApiserver   bool                        `yaml:"proxyViaApiserver,omitempty"`
+	PreferLocalZone     bool                        `yaml:"preferLocalZone,omitempty"`
+	AllowSelfScrape     bool                        `yaml:"allowSelfScrape,omitempty"`
+	AddWorkloadLabels   bool                        `yaml:"addWorkloadLabels,omitempty"`
+	AddGenerationLabel  bool                        `yaml:"addGenerationLabel,omitempty"`
+	TrackPendingTargets *bool                       `yaml:"trackPendingTargets,omitempty"`
+	Priority            int                         `yaml:"priority,omitempty"`
+	SampleRate          *float64                    `yaml:"sampleRate,omitempty"`
+	MaxPods             int                         `yaml:"maxPods,omitempty"`
+	LabelTemplates      map[string]string           `yaml:"labelTemplates,omitempty"`
+	RelabelConfigs      model.RelabelConfigs        `yaml:"relabelConfigs,omitempty"`
+	MetricPrefix        string                      `yaml:"metricPrefix,omitempty"`
+	// MetricRelabelConfigs are applied to every endpoint of the target, before the endpoint's own
+	MetricRelabelConfigs model.RelabelConfigs `yaml:"metricRelabelConfigs,omitempty"`
+	Endpoints            []EndpointConfig     `yaml:"endpoints,omitempty"`
+
+	// AllowPodAnnotationsOverride lets the annotations of a pod override the interval, path and port of its targets
+	AllowPodAnnotationsOverride bool `yaml:"allowPodAnnotationsOverride,omitempty"`
+
+	// Scrape defaults of the target's endpoints, over the global section
+	Interval       string            `yaml:"interval,omitempty"`
+	Timeout        string            `yaml:"timeout,omitempty"`
+	ExternalLabels map[string]string `yaml:"externalLabels,omitempty"`
+	SampleLimit    int               `yaml:"sampleLimit,omitempty"`
+
+	// Aggregations keeps the rules as written; they are parsed by discovery
+	Aggregations []interface{} `yaml:"aggregations,omitempty"`
+
+	// Etcd holds the members and client certificates of an EtcdMonitor target
+	Etcd *EtcdConfig `yaml:"etcd,omitempty"`
+
+	// Raw is the target as written, used to report what a configuration reload changed
+	Raw map[string]interface{} `yaml:"-"`
+}
+
+// IsEnabled reports whether the target is enabled; targets are enabled unless enabled: false is set
+func (t TargetConfig) IsEnabled() bool {
+	return t.Enabled == nil || *t.Enabled
+}
+
+// EndpointConfig is one entry of a target's endpoints
+type EndpointConfig struct {
+	Port    string     `yaml:"port,omitempty"`    // For PodMonitor/ServiceMonitor
+	Address string     `yaml:"address,omitempty"` // For StaticEndpoints
+	Path    StringList `yaml:"path,omitempty"`
+	// Ports scrapes several ports of the same pod or service with one endpoint, instead of port
+	Ports []string `yaml:"ports,omitempty"`
+	// PortConfigs overrides the path and interval for one port of the ports list
+	PortConfigs map[string]PortConfig `yaml:"portConfigs,omitempty"`
+	// PathMetricRelabelConfigs are applied after MetricRelabelConfigs for one path of a path list
+	PathMetricRelabelConfigs map[string]model.RelabelConfigs `yaml:"pathMetricRelabelConfigs,omitempty"`
+	Scheme                   string                          `yaml:"scheme,omitempty"`
+	Interval                 string                          `yaml:"interval,omitempty"`
+	Timeout                  string                          `yaml:"timeout,omitempty"`
+	ConnectTimeout           string                          `yaml:"connectTimeout,omitempty"`
+	ReadTimeout              string                          `yaml:"readTimeout,omitempty"`
+	AdaptiveTimeout          *AdaptiveTimeoutConfig          `yaml:"adaptiveTimeout,omitempty"`
+	BasicAuth                *BasicAuthConfig                `yaml:"basicAuth,omitempty"`
+	MetricRelabelConfigs     model.RelabelConfigs            `yaml:"metricRelabelConfigs,omitempty"`
+	Headers                  map[string]string               `yaml:"headers,omitempty"`
+	MetricPrefix             string                          `yaml:"metricPrefix,omitempty"`
+	Downsample               string                          `yaml:"downsample,omitempty"`
+	AddNodeLabel             bool                            `yaml:"addNodeLabel,omitempty"`
+	PreserveAgentNodeLabel   bool                            `yaml:"preserveAgentNodeLabel,omitempty"`
+	DNSCache                 *bool                           `yaml:"dnsCache,omitempty"`
+	LabelValueLengthLimit    int                             `yaml:"labelValueLengthLimit,omitempty"`
+	LabelValueLengthMode     string                          `yaml:"labelValueLengthMode,omitempty"`
+	TimestampAlignment       string                          `yaml:"timestampAlignment,omitempty"`
+	NonFiniteValues          string                          `yaml:"nonFiniteValues,omitempty"`
+	AcceptProtobuf           bool                            `yaml:"acceptProtobuf,omitempty"`
+	MinSamples               int                             `yaml:"minSamples,omitempty"`
+	// ExternalLabels are added to every sample that does not have the label already
+	ExternalLabels map[string]string `yaml:"externalLabels,omitempty"`
+	// SampleLimit drops every sample of a scrape exposing more samples after metric relabeling, 0 is unlimited
+	SampleLimit int `yaml:"sampleLimit,omitempty"`
+	// AuthProfile takes the credentials from profiles.<active profile>.<authProfile>
+	AuthProfile string `yaml:"authProfile,omitempty"`
+	// Federate scrapes a Prometheus /federate endpoint with match[] selectors
+	Federate *FederateConfig `yaml:"federate,omitempty"`
+
+	// Free-form sections keep the values as written; they are parsed by their consumers
+	TLSConfig       map[string]interface{} `yaml:"tlsConfig,omitempty"`
+	Params          map[string]interface{} `yaml:"params,omitempty"`
+	UnitConversions []interface{}          `yaml:"unitConversions,omitempty"`
+	ValueTransforms []interface{}          `yaml:"valueTransforms,omitempty"`
+	InfoJoin        []interface{}          `yaml:"infoJoin,omitempty"`
+}
+
+// PortConfig is the portConfigs entry of one port; empty fields keep the endpoint's settings
+type PortConfig struct {
+	Path     string `yaml:"path,omitempty"`
+	Interval string `yaml:"interval,omitempty"`
+}
+
+// AdaptiveTimeoutConfig is the adaptiveTimeout section of an endpoint; zero values mean the default
+type AdaptiveTimeoutConfig struct {
+	Enabled          *bool   `yaml:"enabled,omitempty"`
+	FailureThreshold int     `yaml:"failureThreshold,omitempty"`
+	Multiplier       float64 `yaml:"multiplier,omitempty"`
+}
+
+// EtcdConfig is the etcd section of an EtcdMonitor target
+type EtcdConfig struct {
+	// Addresses are static members as host or host:port; without them the members are discovered
+	// from the endpoints of the etcd service
+	Addresses []string `yaml:"addresses,omitempty"`
+	// TLSConfig is the endpoint tlsConfig (caFile, certFile, keyFile, ...) for the members' client port
+	TLSConfig map[string]interface{} `yaml:"tlsConfig,omitempty"`
+}
+
+// StringList is a value written either as a single string or as a list of strings
+type StringList struct {
+	Values []string
+	// IsList is set when the value was written as a list
+	IsList bool
+}
+
+// UnmarshalYAML accepts a string scalar or a sequence of string scalars
+func (s *StringList) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if node.ShortTag() == "!!null" {
+			*s = StringList{}
+			return nil
+		}
+		*s = StringList{Values: []string{node.Value}}
+		return nil
+	case yaml.SequenceNode:
+		list := StringList{Values: make([]string, 0, len(node.Content)), IsList: true}
+		for _, item := range node.Content {
+			if item.Kind != yaml.ScalarNode || item.ShortTag() != "!!str" {
+				return typeError(item, "expected a string in the list")
+			}
+			list.Values = append(list.Values, item.Value)
+		}
+		*s = list
+		return nil
+	}
+	return typeError(node, "expected a string or a list of strings")
+}
+
+// typeError reports a problem at a node the way yaml.v3 reports type mismatches, so it gets the field path
+func typeError(node *yaml.Node, msg string) error {
+	return &yaml.TypeError{Errors: []string{fmt.Sprintf("line %d: %s", node.Line, msg)}}
+}
+
+// DecodeTargetConfigs decodes the targets returned by GetScrapeConfigs. Invalid targets are left out
+// and reported in errs; unknown fields and ignored endpoints are reported in warnings.
+func DecodeTargetConfigs(rawTargets []map[string]interface{}) (targets []TargetConfig, warnings []string, errs []error) {
+	targets = make([]TargetConfig, 0, len(rawTargets))
+	for i, raw := range rawTargets {
+		name, _ := raw["targetName"].(string)
+		if name == "" {
+			name = fmt.Sprintf("targets[%d]", i)
+		}
+		target, targetWarnings, err := DecodeTargetConfig(raw)
+		for _, warning := range targetWarnings {
+			warnings = append(warnings, fmt.Sprintf("target %s: %s", name, warning))
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("target %s: %v", name, err))
+			continue
+		}
+		targets = append(targets, target)
+	}
+	return targets, warnings, errs
+}
+
+// CompileNamePatterns compiles excludePodNames/excludeServiceNames entries into anchored regular
+// expressions, so an entry matches a whole name; an exact name matches itself. Empty entries are skipped.
+func CompileNamePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for i, pattern := range patterns {
+		if pattern == "" {
+			continue
+		}
+		re, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("[%d]: %q is not a valid regex: %v", i, pattern, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// DecodeTargetConfig decodes one target. A wrong type or an invalid selector fails the target with an
+// error naming the field, e.g. "scrapeNotReadyPods: cannot unmarshal !!str `true` into bool".
+// An endpoint that fails to decode is dropped with a warning so the target's other endpoints still work.
+func DecodeTargetConfig(raw map[string]interface{}) (TargetConfig, []string, error) {
+	warnings := unknownFields(raw, reflect.TypeOf(TargetConfig{}), "")
+
+	// Endpoints are decoded one by one below
+	withoutEndpoints := make(map[string]interface{}, len(raw))
+	for key, value := range raw {
+		if key != "endpoints" {
+			withoutEndpoints[key] = value
+		}
+	}
+	target := TargetConfig{Raw: raw}
+	if err := decodeValue(withoutEndpoints, "", &target); err != nil {
+		return TargetConfig{}, warnings, err
+	}
+	if err := target.NamespaceSelector.Validate(); err != nil {
+		return TargetConfig{}, warnings, fmt.Errorf("namespaceSelector.%v", err)
+	}
+	if err := target.Selector.Validate(); err != nil {
+		return TargetConfig{}, warnings, fmt.Errorf("selector.%v", err)
+	}
+	if _, err := model.ParseAggregations(target.Aggregations); err != nil {
+		return TargetConfig{}, warnings, err
+	}
+	if _, err := CompileNamePatterns(target.ExcludePodNames); err != nil {
+		return TargetConfig{}, warnings, fmt.Errorf("excludePodNames%v", err)
+	}
+	if _, err := CompileNamePatterns(target.ExcludeServiceNames); err != nil {
+		return TargetConfig{}, warnings, fmt.Errorf("excludeServiceNames%v", err)
+	}
+	if err := target.ExcludeSelector.Validate(); err != nil {
+		warnings = append(warnings, fmt.Sprintf("ignoring invalid excludeSelector: excludeSelector.%v", err))
+		target.ExcludeSelector = nil
+	}
+	// An invalid sample size scrapes every pod rather than none
+	if target.SampleRate != nil && !(*target.SampleRate > 0 && *target.SampleRate <= 1) {
+		warnings = append(warnings, fmt.Sprintf("ignoring sampleRate %v: must be greater than 0 and at most 1", *target.SampleRate))
+		target.SampleRate = nil
+	}
+	if target.MaxPods < 0 {
+		warnings = append(warnings, fmt.Sprintf("ignoring maxPods %d: must not be negative", target.MaxPods))
+		target.MaxPods = 0
+	}
+	// Invalid scrape defaults fall through to the global section
+	if target.Interval != "" && !validInterval(target.Interval) {
+		warnings = append(warnings, fmt.Sprintf("ignoring interval: invalid interval %q", target.Interval))
+		target.Interval = ""
+	}
+	if target.Timeout != "" && !validTimeout(target.Timeout) {
+		warnings = append(warnings, fmt.Sprintf("ignoring timeout: invalid duration %q", target.Timeout))
+		target.Timeout = ""
+	}
+	if target.SampleLimit < 0 {
+		warnings = append(warnings, fmt.Sprintf("ignoring sampleLimit %d: must not be negative", target.SampleLimit))
+		target.SampleLimit = 0
+	}
+
+	switch endpoints := raw["endpoints"].(type) {
+	case nil:
+	case []interface{}:
+		target.Endpoints = make([]EndpointConfig, 0, len(endpoints))
+		for i, rawEndpoint := range endpoints {
+			path := fmt.Sprintf("endpoints[%d]", i)
+			endpoint, err := decodeEndpoint(rawEndpoint, path)
+			if err != nil {
+				warnings = append(warnings, fmt.Sprintf("ignoring endpoint: %v", err))
+				continue
+			}
+			if endpoint.SampleLimit < 0 {
+				warnings = append(warnings, fmt.Sprintf("ignoring %s.sampleLimit %d: must not be negative", path, endpoint.SampleLimit))
+				endpoint.SampleLimit = 0
+			}
+			target.Endpoints = append(target.Endpoints, endpoint)
+		}
+	default:
+		return TargetConfig{}, warnings, fmt.Errorf("endpoints: expected a list, got %T", endpoints)
+	}
+	return target, warnings, nil
+}
+
+func decodeEndpoint(raw interface{}, path string) (EndpointConfig, error) {
+	endpointMap, ok := raw.(map[string]interface{})
+	if !ok {
+		return EndpointConfig{}, fmt.Errorf("%s: expected a map, got %T", path, raw)
+	}
+	var endpoint EndpointConfig
+	if err := decodeValue(endpointMap, path, &endpoint); err != nil {
+		return EndpointConfig{}, err
+	}
+	endpoint.TLSConfig, _ = endpointMap["tlsConfig"].(map[string]interface{})
+	endpoint.Params, _ = endpointMap["params"].(map[string]interface{})
+	if err := checkParams(endpoint.Params, path); err != nil {
+		return EndpointConfig{}, err
+	}
+	if endpoint.Federate != nil {
+		if err := checkFederate(endpoint.Federate, endpoint.Params, path); err != nil {
+			return EndpointConfig{}, err
+		}
+	}
+	endpoint.UnitConversions, _ = endpointMap["unitConversions"].([]interface{})
+	if raw, ok := endpointMap["valueTransforms"]; ok && raw != nil {
+		if endpoint.ValueTransforms, ok = raw.([]interface{}); !ok {
+			return EndpointConfig{}, fmt.Errorf("%s.valueTransforms: expected a list, got %T", path, raw)
+		}
+		// Reject unknown ops and non-numeric args with the target instead of ignoring the rules
+		if _, err := model.ParseValueTransforms(endpoint.ValueTransforms); err != nil {
+			return EndpointConfig{}, fmt.Errorf("%s.%v", path, err)
+		}
+	}
+	endpoint.InfoJoin, _ = endpointMap["infoJoin"].([]interface{})
+
+	if endpoint.Path.IsList {
+		if len(endpoint.Path.Values) == 0 {
+			return EndpointConfig{}, fmt.Errorf("%s.path: path list is empty", path)
+		}
+		seen := make(map[string]bool, len(endpoint.Path.Values))
+		for _, p := range endpoint.Path.Values {
+			if p == "" || seen[p] {
+				return EndpointConfig{}, fmt.Errorf("%s.path: %q is empty or listed twice", path, p)
+			}
+			seen[p] = true
+		}
+	}
+	for p := range endpoint.PathMetricRelabelConfigs {
+		if !endpoint.Path.IsList || !contains(endpoint.Path.Values, p) {
+			return EndpointConfig{}, fmt.Errorf("%s.pathMetricRelabelConfigs: %q is not in the path list", path, p)
+		}
+	}
+
+	if _, ok := endpointMap["ports"]; ok {
+		if endpoint.Port != "" {
+			return EndpointConfig{}, fmt.Errorf("%s: port and ports are mutually exclusive", path)
+		}
+		if len(endpoint.Ports) == 0 {
+			return EndpointConfig{}, fmt.Errorf("%s.ports: port list is empty", path)
+		}
+		seen := make(map[string]bool, len(endpoint.Ports))
+		for _, p := range endpoint.Ports {
+			if p == "" || seen[p] {
+				return EndpointConfig{}, fmt.Errorf("%s.ports: %q is empty or listed twice", path, p)
+			}
+			seen[p] = true
+		}
+	}
+	for p := range endpoint.PortConfigs {
+		if !contains(endpoint.Ports, p) {
+			return EndpointConfig{}, fmt.Errorf("%s.portConfigs: %q is not in the port list", path, p)
+		}
+	}
+	return endpoint, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeValue decodes an untyped configuration value into out with yaml.v3. The value is turned into a
+// node tree first; each node's Line is an index into its field path, so type errors name the field.
+func decodeValue(value interface{}, path string, out interface{}) error {
+	b := &nodeBuilder{}
+	node := b.build(value, path)
+	err := node.Decode(out)
+	typeErr, ok := err.(*yaml.TypeError)
+	if !ok {
+		return err
+	}
+	messages := make([]string, 0, len(typeErr.Errors))
+	for _, msg := range typeErr.Errors {
+		messages = append(messages, b.withPath(msg))
+	}
+	return fmt.Errorf("%s", strings.Join(messages, "; "))
+}
+
+type nodeBuilder struct {
+	paths []string
+}
+
+func (b *nodeBuilder) build(value interface{}, path string) *yaml.Node {
+	b.paths = append(b.paths, path)
+	node := &yaml.Node{Line: len(b.paths), Column: 1}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		node.Kind, node.Tag = yaml.MappingNode, "!!map"
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			keyNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key, Line: node.Line, Column: 1}
+			node.Content = append(node.Content, keyNode, b.build(v[key], joinPath(path, key)))
+		}
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			converted[fmt.Sprint(key)] = item
+		}
+		b.paths = b.paths[:len(b.paths)-1]
+		return b.build(converted, path)
+	case []interface{}:
+		node.Kind, node.Tag = yaml.SequenceNode, "!!seq"
+		for i, item := range v {
+			node.Content = append(node.Content, b.build(item, fmt.Sprintf("%s[%d]", path, i)))
+		}
+	case nil:
+		node.Kind, node.Tag, node.Value = yaml.ScalarNode, "!!null", "null"
+	case string:
+		node.Kind, node.Tag, node.Value = yaml.ScalarNode, "!!str", v
+	case bool:
+		node.Kind, node.Tag, node.Value = yaml.ScalarNode, "!!bool", strconv.FormatBool(v)
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		node.Kind, node.Tag, node.Value = yaml.ScalarNode, "!!int", fmt.Sprint(v)
+	case float32, float64:
+		node.Kind, node.Tag, node.Value = yaml.ScalarNode, "!!float", fmt.Sprint(v)
+	default:
+		node.Kind, node.Tag, node.Value = yaml.ScalarNode, "!!str", fmt.Sprint(v)
+	}
+	return node
+}
+
+// withPath replaces the "line N:" prefix of a yaml.v3 type error with the field path of node N
+func (b *nodeBuilder) withPath(msg string) string {
+	rest := strings.TrimPrefix(msg, "line ")
+	colon := strings.Index(rest, ":")
+	if rest == msg || colon < 0 {
+		return msg
+	}
+	line, err := strconv.Atoi(rest[:colon])
+	if err != nil || line < 1 || line > len(b.paths) {
+		return msg
+	}
+	path := b.paths[line-1]
+	if path == "" {
+		return strings.TrimSpace(rest[colon+1:])
+	}
+	return path + rest[colon:]
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// unknownFields lists the keys of value that have no yaml field in t, recursing into known fields
+func unknownFields(value interface{}, t reflect.Type, path string) []string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	var warnings []string
+	switch v := value.(type) {
+	case map[string]interface{}:
+		switch t.Kind() {
+		case reflect.Struct:
+			fields := yamlFields(t)
+			keys := make([]string, 0, len(v))
+			for key := range v {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				fieldType, ok := fields[key]
+				if !ok {
+					warnings = append(warnings, fmt.Sprintf("unknown field %s", joinPath(path, key)))
+					continue
+				}
+				warnings = append(warnings, unknownFields(v[key], fieldType, joinPath(path, key))...)
+			}
+		case reflect.Map:
+			for key, item := range v {
+				warnings = append(warnings, unknownFields(item, t.Elem(), joinPath(path, key))...)
+			}
+		}
+	case []interface{}:
+		if t.Kind() == reflect.Slice {
+			for i, item := range v {
+				warnings = append(warnings, unknownFields(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	}
+	return warnings
+}
+
+// yamlFields maps the yaml keys of a struct to their field types, including inlined structs
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("yaml")
+		if tag == "-" || field.PkgPath != "" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if strings.Contains(options, "inline") {
+			for key, fieldType := range yamlFields(field.Type) {
+				fields[key] = fieldType
+			}
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
@@ -0,0 +1,334 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+// rawTarget parses one target the way GetScrapeConfigs does: yaml.v2 followed by convertToStringMap
+func rawTarget(t *testing.T, src string) map[string]interface{} {
+	t.Helper()
+	var parsed map[interface{}]interface{}
+	if err := yaml.Unmarshal([]byte(src), &parsed); err != nil {
+		t.Fatalf("invalid test YAML: %v", err)
+	}
+	raw, ok := convertToStringMap(parsed).(map[string]interface{})
+	if !ok {
+		t.Fatalf("unexpected test YAML %T", parsed)
+	}
+	return raw
+}
+
+func decodeTarget(t *testing.T, src string) (TargetConfig, []string) {
+	t.Helper()
+	target, warnings, err := DecodeTargetConfig(rawTarget(t, src))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return target, warnings
+}
+
+func TestDecodeTargetConfig_MessyYAML(t *testing.T) {
+	target, warnings := decodeTarget(t, `
+targetName: kube-state-metrics
+type: PodMonitor
+enabled: yes
+scrapeNotReadyPods: on
+namespaceSelector:
+  matchNames: [kube-system, monitoring]
+selector:
+  matchLabels:
+    app.kubernetes.io/name: kube-state-metrics
+    version: 2
+  matchExpressions:
+    - {key: tier, operator: In, values: [1, "2"]}
+relabelConfigs:
+  - source_labels: [__meta_pod_name]
+    target_label: pod
+endpoints:
+  - port: 8080
+    path: /metrics
+    interval: 30s
+    headers:
+      X-Scope-OrgID: 42
+      X-Debug: true
+    adaptiveTimeout:
+      enabled: false
+      multiplier: 3
+    basicAuth:
+      username: admin
+      password:
+        name: ksm-auth
+        key: password
+    metricRelabelConfigs:
+      - action: drop
+        regex: go_.*
+`)
+	if len(warnings) != 0 {
+		t.Errorf("unexpected warnings %v", warnings)
+	}
+	if !target.IsEnabled() || !target.ScrapeNotReadyPods {
+		t.Errorf("YAML 1.1 booleans not decoded: %+v", target)
+	}
+	if got := target.NamespaceSelector.MatchNames; len(got) != 2 || got[1] != "monitoring" {
+		t.Errorf("unexpected matchNames %v", got)
+	}
+	if target.Selector.MatchLabels["version"] != "2" || target.Selector.MatchExpressions[0].Values[0] != "1" {
+		t.Errorf("numbers in selectors should decode as strings: %+v", target.Selector)
+	}
+	if len(target.Endpoints) != 1 {
+		t.Fatalf("expected 1 endpoint, got %d", len(target.Endpoints))
+	}
+
+	ep := target.Endpoints[0]
+	if ep.Port != "8080" || ep.Path.Values[0] != "/metrics" || ep.Path.IsList {
+		t.Errorf("unexpected port/path %q %+v", ep.Port, ep.Path)
+	}
+	if ep.Headers["X-Scope-OrgID"] != "42" || ep.Headers["X-Debug"] != "true" {
+		t.Errorf("unexpected headers %v", ep.Headers)
+	}
+	if ep.AdaptiveTimeout == nil || *ep.AdaptiveTimeout.Enabled || ep.AdaptiveTimeout.Multiplier != 3 {
+		t.Errorf("unexpected adaptiveTimeout %+v", ep.AdaptiveTimeout)
+	}
+	if ep.BasicAuth.Username.Value != "admin" || ep.BasicAuth.Password.Name != "ksm-auth" {
+		t.Errorf("unexpected basicAuth %+v %+v", ep.BasicAuth.Username, ep.BasicAuth.Password)
+	}
+
+	// Omitted relabel fields get the same defaults as ParseRelabelConfigs
+	relabel := target.RelabelConfigs[0]
+	if relabel.Action != "replace" || relabel.Regex != "(.+)" || relabel.Replacement != "$1" || relabel.Separator != ";" {
+		t.Errorf("relabel defaults not applied: %+v", relabel)
+	}
+	if drop := ep.MetricRelabelConfigs[0]; drop.Action != "drop" || drop.Regex != "go_.*" {
+		t.Errorf("unexpected metricRelabelConfigs %+v", drop)
+	}
+}
+
+func TestDecodeTargetConfig_WrongTypesNameTheField(t *testing.T) {
+	for name, tc := range map[string]struct {
+		src   string
+		field string
+	}{
+		"quoted boolean": {`
+targetName: app
+enabled: "false"
+`, "enabled: cannot unmarshal !!str `false` into bool"},
+		"list for a string": {`
+targetName: app
+metricPrefix: [a, b]
+`, "metricPrefix: cannot unmarshal !!seq into string"},
+		"map for a list": {`
+targetName: app
+excludePodNames: {name: canary}
+`, "excludePodNames: cannot unmarshal !!map into []string"},
+		"nested relabel field": {`
+targetName: app
+relabelConfigs:
+  - source_labels: pod
+`, "relabelConfigs[0].source_labels: cannot unmarshal !!str `pod` into []string"},
+		"invalid selector operator": {`
+targetName: app
+selector:
+  matchExpressions:
+    - {key: app, operator: Equals, values: [api]}
+`, `selector.matchExpressions[0]: unsupported operator "Equals"`},
+		"endpoints not a list": {`
+targetName: app
+endpoints:
+  port: 8080
+`, "endpoints: expected a list"},
+	} {
+		_, _, err := DecodeTargetConfig(rawTarget(t, tc.src))
+		if err == nil || !strings.Contains(err.Error(), tc.field) {
+			t.Errorf("%s: expected error containing %q, got %v", name, tc.field, err)
+		}
+	}
+}
+
+func TestDecodeTargetConfig_UnknownFieldsWarn(t *testing.T) {
+	target, warnings := decodeTarget(t, `
+targetName: app
+type: StaticEndpoints
+namspaceSelector: {}
+selector:
+  matchLabel: {app: api}
+endpoints:
+  - address: 10.0.0.1:9100
+    intervall: 15s
+    tlsConfig:
+      anything: goes
+`)
+	want := []string{
+		"unknown field endpoints[0].intervall",
+		"unknown field namspaceSelector",
+		"unknown field selector.matchLabel",
+	}
+	if strings.Join(warnings, "\n") != strings.Join(want, "\n") {
+		t.Errorf("expected warnings %q, got %q", want, warnings)
+	}
+	if len(target.Endpoints) != 1 || target.Endpoints[0].TLSConfig["anything"] != "goes" {
+		t.Errorf("target with unknown fields should still decode: %+v", target)
+	}
+}
+
+func TestDecodeTargetConfig_InvalidEndpointIsDropped(t *testing.T) {
+	target, warnings := decodeTarget(t, `
+targetName: app
+type: StaticEndpoints
+endpoints:
+  - address: 10.0.0.1:9100
+    addNodeLabel: "true"
+  - address: 10.0.0.2:9100
+    path: [/metrics, 1]
+  - address: 10.0.0.3:9100
+    path: [/metrics, /metrics/cadvisor]
+    pathMetricRelabelConfigs:
+      /metrics/cadvisor:
+        - action: keep
+`)
+	if len(target.Endpoints) != 1 || target.Endpoints[0].Address != "10.0.0.3:9100" {
+		t.Fatalf("expected only the valid endpoint, got %+v", target.Endpoints)
+	}
+	if ep := target.Endpoints[0]; !ep.Path.IsList || len(ep.Path.Values) != 2 || len(ep.PathMetricRelabelConfigs["/metrics/cadvisor"]) != 1 {
+		t.Errorf("unexpected path list %+v", ep)
+	}
+	if len(warnings) != 2 ||
+		!strings.Contains(warnings[0], "endpoints[0].addNodeLabel: cannot unmarshal !!str `true` into bool") ||
+		!strings.Contains(warnings[1], "endpoints[1].path[1]: expected a string in the list") {
+		t.Errorf("unexpected warnings %q", warnings)
+	}
+}
+
+func TestDecodeTargetConfigs_SkipsInvalidTargets(t *testing.T) {
+	raw := []map[string]interface{}{
+		rawTarget(t, "targetName: good\ntype: StaticEndpoints\n"),
+		rawTarget(t, "targetName: bad\nscrapeNotReadyPods: 1\n"),
+		rawTarget(t, "type: PodMonitor\nreadyGracePeriod: [30s]\n"),
+	}
+	targets, _, errs := DecodeTargetConfigs(raw)
+	if len(targets) != 1 || targets[0].TargetName != "good" {
+		t.Fatalf("expected only target good, got %+v", targets)
+	}
+	if len(errs) != 2 ||
+		!strings.HasPrefix(errs[0].Error(), "target bad: scrapeNotReadyPods:") ||
+		!strings.HasPrefix(errs[1].Error(), "target targets[2]: readyGracePeriod:") {
+		t.Errorf("unexpected errors %v", errs)
+	}
+	if targets[0].Raw["targetName"] != "good" {
+		t.Errorf("raw target not kept")
+	}
+}
+
+func TestDecodeTargetConfig_InvalidValueTransformsDropEndpoint(t *testing.T) {
+	target, warnings := decodeTarget(t, `
+targetName: app
+type: StaticEndpoints
+endpoints:
+  - address: 10.0.0.1:9100
+    valueTransforms:
+      - metricRegex: node_temperature_celsius
+        op: clamp
+        arg: 0
+  - address: 10.0.0.2:9100
+    valueTransforms:
+      - metricRegex: node_temperature_celsius
+        op: clampMin
+        arg: zero
+  - address: 10.0.0.3:9100
+    valueTransforms:
+      - metricRegex: node_temperature_celsius
+        op: clampMin
+        arg: 0
+`)
+	if len(target.Endpoints) != 1 || len(target.Endpoints[0].ValueTransforms) != 1 {
+		t.Fatalf("expected only the valid endpoint, got %+v", target.Endpoints)
+	}
+	if len(warnings) != 2 ||
+		!strings.Contains(warnings[0], `endpoints[0].valueTransforms[0]: unknown op "clamp"`) ||
+		!strings.Contains(warnings[1], `endpoints[1].valueTransforms[0]: arg "zero" is not a number`) {
+		t.Errorf("unexpected warnings %q", warnings)
+	}
+}
+
+func TestDecodeTargetConfig_Aggregations(t *testing.T) {
+	target, warnings := decodeTarget(t, `
+targetName: app
+type: StaticEndpoints
+aggregations:
+  - sourceMetric: http_requests_total
+    by: [method]
+    op: sum
+    outputMetric: http_requests_by_method
+    dropSource: true
+endpoints:
+  - address: 10.0.0.1:9100
+`)
+	if len(warnings) != 0 || len(target.Aggregations) != 1 {
+		t.Fatalf("expected one aggregation rule without warnings, got %v / %q", target.Aggregations, warnings)
+	}
+
+	_, _, err := DecodeTargetConfig(rawTarget(t, `
+targetName: app
+type: StaticEndpoints
+aggregations:
+  - sourceMetric: http_requests_total
+    op: median
+    outputMetric: http_requests_median
+`))
+	if err == nil || !strings.Contains(err.Error(), `aggregations[0]: unknown op "median"`) {
+		t.Errorf("expected the invalid op to fail the target, got %v", err)
+	}
+}
+
+func TestDecodeTargetConfig_InvalidSampleSizeIsIgnored(t *testing.T) {
+	target, warnings := decodeTarget(t, "targetName: nginx\ntype: PodMonitor\nsampleRate: 1.5\nmaxPods: -1\n")
+	if target.SampleRate != nil || target.MaxPods != 0 {
+		t.Errorf("expected the sample size ignored, got sampleRate %v, maxPods %d", target.SampleRate, target.MaxPods)
+	}
+	if len(warnings) != 2 || !strings.Contains(warnings[0], "ignoring sampleRate 1.5") || !strings.Contains(warnings[1], "ignoring maxPods -1") {
+		t.Errorf("unexpected warnings %q", warnings)
+	}
+
+	target, warnings = decodeTarget(t, "targetName: nginx\ntype: PodMonitor\nsampleRate: 0.05\nmaxPods: 200\n")
+	if target.SampleRate == nil || *target.SampleRate != 0.05 || target.MaxPods != 200 || len(warnings) != 0 {
+		t.Errorf("unexpected sample size %v/%d, warnings %q", target.SampleRate, target.MaxPods, warnings)
+	}
+}
+
+func TestDecodeTargetConfig_SecretParams(t *testing.T) {
+	target, warnings := decodeTarget(t, `
+targetName: app
+type: StaticEndpoints
+endpoints:
+  - address: 10.0.0.1:9100
+    params:
+      module: [http_2xx]
+      api_key:
+        secretKeyRef:
+          name: exporter-key
+          key: api-key
+  - address: 10.0.0.2:9100
+    params:
+      api_key:
+        secretKeyRef:
+          name: exporter-key
+  - address: 10.0.0.3:9100
+    params:
+      api_key:
+        name: exporter-key
+`)
+	if len(target.Endpoints) != 1 || target.Endpoints[0].Address != "10.0.0.1:9100" {
+		t.Fatalf("expected only the valid endpoint, got %+v", target.Endpoints)
+	}
+	secrets := SecretParams(target.Endpoints[0].Params)
+	if want := (SecretKeySelector{Name: "exporter-key", Key: "api-key"}); len(secrets) != 1 || secrets["api_key"] != want {
+		t.Errorf("unexpected secret params %+v", secrets)
+	}
+	if len(warnings) != 2 ||
+		!strings.Contains(warnings[0], "endpoints[1].params.api_key.secretKeyRef: name and key are required") ||
+		!strings.Contains(warnings[1], "endpoints[2].params.api_key: expected a string, a list or {secretKeyRef: {name, key}}") {
+		t.Errorf("unexpected warnings %q", warnings)
+	}
+}
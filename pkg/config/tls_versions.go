@@ -0,0 +1,103 @@
+package config
+
+import (
+	"crypto/tls"
+	"fmt"
+)
+
+// tlsVersions maps the tlsConfig minVersion/maxVersion names to crypto/tls versions
+var tlsVersions = map[string]uint16{
+	"TLS10": tls.VersionTLS10,
+	"TLS11": tls.VersionTLS11,
+	"TLS12": tls.VersionTLS12,
+	"TLS13": tls.VersionTLS13,
+}
+
+// tlsRenegotiation maps the tlsConfig renegotiation names to crypto/tls renegotiation support
+var tlsRenegotiation = map[string]tls.RenegotiationSupport{
+	"never":  tls.RenegotiateNever,
+	"once":   tls.RenegotiateOnceAsClient,
+	"freely": tls.RenegotiateFreelyAsClient,
+}
+
+// ParseTLSVersion returns the crypto/tls version of TLS10, TLS11, TLS12 or TLS13, and 0 for "",
+// which keeps the Go default
+func ParseTLSVersion(name string) (uint16, error) {
+	if name == "" {
+		return 0, nil
+	}
+	version, ok := tlsVersions[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown TLS version %q, expected TLS10, TLS11, TLS12 or TLS13", name)
+	}
+	return version, nil
+}
+
+// ParseTLSRenegotiation returns the crypto/tls renegotiation support of never, once or freely,
+// and RenegotiateNever for ""
+func ParseTLSRenegotiation(name string) (tls.RenegotiationSupport, error) {
+	if name == "" {
+		return tls.RenegotiateNever, nil
+	}
+	renegotiation, ok := tlsRenegotiation[name]
+	if !ok {
+		return tls.RenegotiateNever, fmt.Errorf("unknown TLS renegotiation %q, expected never, once or freely", name)
+	}
+	return renegotiation, nil
+}
+
+// LegacyTLSForbidden reports whether openagent_tls_forbid_legacy rejects every tlsConfig that enables
+// a version below TLS 1.2 or renegotiation
+func LegacyTLSForbidden() bool {
+	return GetBoolWithDefault("openagent_tls_forbid_legacy", false)
+}
+
+// ValidateTLSVersions checks the minVersion, maxVersion and renegotiation of a tlsConfig, and whether
+// they are allowed by openagent_tls_forbid_legacy. It returns the parsed settings, or the first problem.
+func ValidateTLSVersions(minVersion, maxVersion, renegotiation string) (min, max uint16, reneg tls.RenegotiationSupport, err error) {
+	if min, err = ParseTLSVersion(minVersion); err != nil {
+		return 0, 0, 0, fmt.Errorf("minVersion: %v", err)
+	}
+	if max, err = ParseTLSVersion(maxVersion); err != nil {
+		return 0, 0, 0, fmt.Errorf("maxVersion: %v", err)
+	}
+	if reneg, err = ParseTLSRenegotiation(renegotiation); err != nil {
+		return 0, 0, 0, fmt.Errorf("renegotiation: %v", err)
+	}
+	if min != 0 && max != 0 && min > max {
+		return 0, 0, 0, fmt.Errorf("minVersion %s is above maxVersion %s", minVersion, maxVersion)
+	}
+	if min == 0 && max != 0 && max < tls.VersionTLS12 {
+		return 0, 0, 0, fmt.Errorf("maxVersion %s is below the default minimum TLS12, set minVersion as well", maxVersion)
+	}
+	if LegacyTLSForbidden() {
+		if min != 0 && min < tls.VersionTLS12 {
+			return 0, 0, 0, fmt.Errorf("minVersion %s is forbidden by openagent_tls_forbid_legacy", minVersion)
+		}
+		if reneg != tls.RenegotiateNever {
+			return 0, 0, 0, fmt.Errorf("renegotiation %s is forbidden by openagent_tls_forbid_legacy", renegotiation)
+		}
+	}
+	return min, max, reneg, nil
+}
+
+// tlsVersionProblem returns the problem of an endpoint tlsConfig's versions and renegotiation, if any
+func tlsVersionProblem(tlsConfig map[string]interface{}) error {
+	if tlsConfig == nil {
+		return nil
+	}
+	var names [3]string
+	for i, field := range []string{"minVersion", "maxVersion", "renegotiation"} {
+		value, ok := tlsConfig[field]
+		if !ok {
+			continue
+		}
+		name, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s: expected a string, got %v", field, value)
+		}
+		names[i] = name
+	}
+	_, _, _, err := ValidateTLSVersions(names[0], names[1], names[2])
+	return err
+}
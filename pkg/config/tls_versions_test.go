@@ -0,0 +1,85 @@
+package config
+
+import (
+	"crypto/tls"
+	"strings"
+	"testing"
+
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestValidateTLSVersions(t *testing.T) {
+	tests := []struct {
+		name                          string
+		minVersion, maxVersion, reneg string
+		wantMin, wantMax              uint16
+		wantReneg                     tls.RenegotiationSupport
+		wantErr                       string
+	}{
+		{name: "go defaults"},
+		{name: "legacy appliance", minVersion: "TLS10", maxVersion: "TLS11", reneg: "freely",
+			wantMin: tls.VersionTLS10, wantMax: tls.VersionTLS11, wantReneg: tls.RenegotiateFreelyAsClient},
+		{name: "renegotiate once", reneg: "once", wantReneg: tls.RenegotiateOnceAsClient},
+		{name: "unknown version", minVersion: "TLSv1.2", wantErr: `minVersion: unknown TLS version "TLSv1.2"`},
+		{name: "unknown max version", maxVersion: "SSL30", wantErr: `maxVersion: unknown TLS version "SSL30"`},
+		{name: "unknown renegotiation", reneg: "always", wantErr: `renegotiation: unknown TLS renegotiation "always"`},
+		{name: "min above max", minVersion: "TLS13", maxVersion: "TLS12", wantErr: "minVersion TLS13 is above maxVersion TLS12"},
+		{name: "legacy max without min", maxVersion: "TLS11", wantErr: "set minVersion as well"},
+	}
+	for _, tt := range tests {
+		min, max, reneg, err := ValidateTLSVersions(tt.minVersion, tt.maxVersion, tt.reneg)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.wantErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+		if min != tt.wantMin || max != tt.wantMax || reneg != tt.wantReneg {
+			t.Errorf("%s: got %x-%x renegotiation %d, want %x-%x renegotiation %d", tt.name, min, max, reneg, tt.wantMin, tt.wantMax, tt.wantReneg)
+		}
+	}
+}
+
+func TestValidateTLSVersions_ForbidLegacy(t *testing.T) {
+	t.Setenv("openagent_tls_forbid_legacy", "true")
+
+	if _, _, _, err := ValidateTLSVersions("TLS11", "", ""); err == nil || !strings.Contains(err.Error(), "forbidden by openagent_tls_forbid_legacy") {
+		t.Errorf("expected TLS11 to be forbidden, got %v", err)
+	}
+	if _, _, _, err := ValidateTLSVersions("", "", "once"); err == nil || !strings.Contains(err.Error(), "forbidden by openagent_tls_forbid_legacy") {
+		t.Errorf("expected renegotiation to be forbidden, got %v", err)
+	}
+	if _, _, _, err := ValidateTLSVersions("TLS12", "TLS13", "never"); err != nil {
+		t.Errorf("expected modern versions to stay allowed, got %v", err)
+	}
+}
+
+func TestValidateScrapeConfig_TLSVersions(t *testing.T) {
+	cm := newTestConfigManager(&fakeConfigMapSource{clientset: fake.NewSimpleClientset(strictConfigMap(false, `      - targetName: appliance
+        type: StaticEndpoints
+        endpoints:
+          - address: "10.0.0.3:8443"
+            scheme: https
+            tlsConfig:
+              minVersion: "TLS1.0"
+          - address: "10.0.0.4:8443"
+            scheme: https
+            tlsConfig:
+              minVersion: TLS10
+              renegotiation: freely
+`))})
+	t.Setenv("WHATAP_OPEN_HOME", t.TempDir())
+	if err := cm.LoadConfig(); err != nil {
+		t.Fatalf("lenient load: %v", err)
+	}
+
+	problems := ValidateScrapeConfig(cm.GetConfig())
+	want := `target appliance: endpoints[0].tlsConfig.minVersion: unknown TLS version "TLS1.0"`
+	if len(problems) != 1 || !strings.Contains(problems[0], want) {
+		t.Errorf("expected only the unknown version to be reported as %q, got %v", want, problems)
+	}
+}
@@ -1,10 +1,24 @@
 package config
 
+import "gopkg.in/yaml.v3"
+
 // SecretKeySelector defines a reference to a secret key
 type SecretKeySelector struct {
 	Name      string `json:"name" yaml:"name"`
 	Key       string `json:"key" yaml:"key"`
 	Namespace string `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	// Value is a literal value written in place of the reference, e.g. password: ${file:/etc/secrets/password}
+	Value string `json:"-" yaml:"-"`
+}
+
+// UnmarshalYAML accepts a secret reference or a literal string value
+func (s *SecretKeySelector) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*s = SecretKeySelector{Value: node.Value}
+		return nil
+	}
+	type plain SecretKeySelector
+	return node.Decode((*plain)(s))
 }
 
 // BasicAuthConfig represents HTTP Basic Auth configuration
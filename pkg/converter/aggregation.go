@@ -0,0 +1,144 @@
+package converter
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"open-agent/pkg/model"
+)
+
+// aggregationGroup accumulates the samples of one output series
+type aggregationGroup struct {
+	series *model.OpenMx
+	sum    float64
+	max    float64
+	min    float64
+	count  int
+}
+
+func (g *aggregationGroup) add(value float64) {
+	if g.count == 0 || value > g.max {
+		g.max = value
+	}
+	if g.count == 0 || value < g.min {
+		g.min = value
+	}
+	g.sum += value
+	g.count++
+}
+
+func (g *aggregationGroup) value(op string) float64 {
+	switch op {
+	case model.AggregationAvg:
+		return g.sum / float64(g.count)
+	case model.AggregationMax:
+		return g.max
+	case model.AggregationMin:
+		return g.min
+	}
+	return g.sum
+}
+
+// ApplyAggregations appends the aggregated series of every rule to the scrape and removes the source
+// series of rules with dropSource. Every rule aggregates the samples as exposed, so rules do not chain.
+// NaN samples are left out of the groups, and a group without samples emits no series. It returns the
+// number of series each rule emitted, indexed like rules.
+func ApplyAggregations(result *model.ConversionResult, rules model.Aggregations) []int {
+	if result == nil || len(rules) == 0 {
+		return nil
+	}
+	bySource := make(map[string][]int, len(rules))
+	dropSource := make(map[string]bool)
+	for i, rule := range rules {
+		bySource[rule.SourceMetric] = append(bySource[rule.SourceMetric], i)
+		if rule.DropSource {
+			dropSource[rule.SourceMetric] = true
+		}
+	}
+
+	groups := make([]map[string]*aggregationGroup, len(rules))
+	order := make([][]*aggregationGroup, len(rules))
+	kept := make([]*model.OpenMx, 0, len(result.OpenMxList))
+	var key strings.Builder
+	for _, om := range result.OpenMxList {
+		indexes, ok := bySource[om.Metric]
+		if !ok {
+			kept = append(kept, om)
+			continue
+		}
+		if !dropSource[om.Metric] {
+			kept = append(kept, om)
+		}
+		if math.IsNaN(om.Value) {
+			continue
+		}
+		for _, i := range indexes {
+			rule := rules[i]
+			key.Reset()
+			for _, label := range rule.By {
+				key.WriteString(seriesLabelValue(om, label))
+				key.WriteByte(0xff)
+			}
+			if groups[i] == nil {
+				groups[i] = make(map[string]*aggregationGroup)
+			}
+			group, ok := groups[i][key.String()]
+			if !ok {
+				group = &aggregationGroup{series: model.NewOpenMx(rule.OutputMetric, om.Timestamp, 0)}
+				// A label missing from the source series is missing from the output series too
+				for _, label := range rule.By {
+					if value := seriesLabelValue(om, label); value != "" {
+						group.series.AddLabel(label, value)
+					}
+				}
+				groups[i][key.String()] = group
+				order[i] = append(order[i], group)
+			}
+			group.add(om.Value)
+		}
+	}
+
+	emitted := make([]int, len(rules))
+	helps := make(map[string]*model.OpenMxHelp, len(result.OpenMxHelpList))
+	for _, help := range result.OpenMxHelpList {
+		helps[help.Metric] = help
+	}
+	for i, rule := range rules {
+		for _, group := range order[i] {
+			group.series.Value = group.value(rule.Op)
+			kept = append(kept, group.series)
+		}
+		emitted[i] = len(order[i])
+		if emitted[i] > 0 && helps[rule.OutputMetric] == nil {
+			help := aggregationHelp(rule, helps[rule.SourceMetric])
+			helps[rule.OutputMetric] = help
+			result.OpenMxHelpList = append(result.OpenMxHelpList, help)
+		}
+	}
+	result.OpenMxList = kept
+	return emitted
+}
+
+// aggregationHelp describes an output metric. A sum keeps the source type, so a sum of counters is
+// still a counter; the other operations are gauges.
+func aggregationHelp(rule *model.Aggregation, source *model.OpenMxHelp) *model.OpenMxHelp {
+	help := model.NewOpenMxHelp(rule.OutputMetric)
+	help.Put("help", fmt.Sprintf("%s of %s by (%s)", rule.Op, rule.SourceMetric, strings.Join(rule.By, ", ")))
+	metricType := "gauge"
+	if rule.Op == model.AggregationSum && source != nil && source.Get("type") != "" {
+		metricType = source.Get("type")
+	}
+	help.Put("type", metricType)
+	return help
+}
+
+// seriesLabelValue returns the value of a label of the series, or "" when it does not have the label
+func seriesLabelValue(om *model.OpenMx, name string) string {
+	for _, label := range om.Labels {
+		if label.Key == name {
+			return label.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,242 @@
+package converter
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"open-agent/pkg/model"
+)
+
+func parseAggregations(t *testing.T, configs ...map[string]interface{}) model.Aggregations {
+	t.Helper()
+	raw := make([]interface{}, 0, len(configs))
+	for _, c := range configs {
+		raw = append(raw, c)
+	}
+	rules, err := model.ParseAggregations(raw)
+	if err != nil {
+		t.Fatalf("ParseAggregations: %v", err)
+	}
+	return rules
+}
+
+func requestSample(value float64, labels ...string) *model.OpenMx {
+	om := model.NewOpenMx("http_requests_total", 1000, value)
+	for i := 0; i+1 < len(labels); i += 2 {
+		om.AddLabel(labels[i], labels[i+1])
+	}
+	return om
+}
+
+// aggregatedSeries returns the values of the output metric keyed by the series' labels, e.g. "method=GET"
+func aggregatedSeries(result *model.ConversionResult, metric string) map[string]float64 {
+	series := make(map[string]float64)
+	for _, om := range result.GetOpenMxList() {
+		if om.Metric != metric {
+			continue
+		}
+		pairs := make([]string, 0, len(om.Labels))
+		for _, l := range om.Labels {
+			pairs = append(pairs, l.Key+"="+l.Value)
+		}
+		series[strings.Join(pairs, ",")] = om.Value
+	}
+	return series
+}
+
+func TestApplyAggregations_Ops(t *testing.T) {
+	tests := []struct {
+		op   string
+		want map[string]float64
+	}{
+		{"sum", map[string]float64{"method=GET": 6, "method=POST": 10}},
+		{"avg", map[string]float64{"method=GET": 2, "method=POST": 10}},
+		{"max", map[string]float64{"method=GET": 3, "method=POST": 10}},
+		{"min", map[string]float64{"method=GET": 1, "method=POST": 10}},
+	}
+	for _, tt := range tests {
+		result := model.NewConversionResult([]*model.OpenMx{
+			requestSample(1, "method", "GET", "path", "/a"),
+			requestSample(10, "method", "POST", "path", "/a"),
+			requestSample(2, "method", "GET", "path", "/b"),
+			requestSample(3, "method", "GET", "path", "/c"),
+		}, nil)
+		emitted := ApplyAggregations(result, parseAggregations(t, map[string]interface{}{
+			"sourceMetric": "http_requests_total",
+			"by":           []interface{}{"method"},
+			"op":           tt.op,
+			"outputMetric": "http_requests_by_method",
+		}))
+
+		got := aggregatedSeries(result, "http_requests_by_method")
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got series %v, want %v", tt.op, got, tt.want)
+		}
+		for labels, want := range tt.want {
+			if got[labels] != want {
+				t.Errorf("%s by method {%s}: got %v, want %v", tt.op, labels, got[labels], want)
+			}
+		}
+		if len(emitted) != 1 || emitted[0] != 2 {
+			t.Errorf("%s: expected 2 emitted series, got %v", tt.op, emitted)
+		}
+		if n := len(aggregatedSeries(result, "http_requests_total")); n != 4 {
+			t.Errorf("%s: expected the 4 source series to be kept, got %d", tt.op, n)
+		}
+	}
+}
+
+func TestApplyAggregations_MissingLabelsGroupAsEmpty(t *testing.T) {
+	result := model.NewConversionResult([]*model.OpenMx{
+		requestSample(1, "method", "GET", "code", "200"),
+		requestSample(2, "method", "GET"),
+		requestSample(4, "code", "500"),
+		requestSample(8),
+		requestSample(16, "method", "", "code", ""),
+	}, nil)
+	ApplyAggregations(result, parseAggregations(t, map[string]interface{}{
+		"sourceMetric": "http_requests_total",
+		"by":           []interface{}{"method", "code"},
+		"op":           "sum",
+		"outputMetric": "http_requests_by_method_code",
+	}))
+
+	// A missing label and an empty label group together, and the output series leaves the label out
+	want := map[string]float64{
+		"method=GET,code=200": 1,
+		"method=GET":          2,
+		"code=500":            4,
+		"":                    24,
+	}
+	got := aggregatedSeries(result, "http_requests_by_method_code")
+	if len(got) != len(want) {
+		t.Fatalf("got series %v, want %v", got, want)
+	}
+	for labels, v := range want {
+		if got[labels] != v {
+			t.Errorf("{%s}: got %v, want %v", labels, got[labels], v)
+		}
+	}
+}
+
+func TestApplyAggregations_NoByAndEmptyGroups(t *testing.T) {
+	result := model.NewConversionResult([]*model.OpenMx{
+		requestSample(1, "method", "GET"),
+		requestSample(math.NaN(), "method", "PUT"),
+		requestSample(2, "method", "POST"),
+	}, nil)
+	emitted := ApplyAggregations(result, parseAggregations(t,
+		map[string]interface{}{"sourceMetric": "http_requests_total", "op": "avg", "outputMetric": "http_requests_avg"},
+		map[string]interface{}{"sourceMetric": "http_requests_total", "by": []interface{}{"method"}, "op": "max", "outputMetric": "http_requests_max"},
+		map[string]interface{}{"sourceMetric": "absent_metric", "op": "sum", "outputMetric": "absent_metric_sum"},
+	))
+
+	// Without by labels every sample is one group; NaN is left out of the average
+	if got := aggregatedSeries(result, "http_requests_avg"); len(got) != 1 || got[""] != 1.5 {
+		t.Errorf("expected a single avg series of 1.5, got %v", got)
+	}
+	// A group of only NaN samples emits no series
+	got := aggregatedSeries(result, "http_requests_max")
+	if _, ok := got["method=PUT"]; ok || len(got) != 2 {
+		t.Errorf("expected max series for GET and POST only, got %v", got)
+	}
+	// A rule whose source is not in the scrape emits nothing, not even metadata
+	if len(emitted) != 3 || emitted[0] != 1 || emitted[1] != 2 || emitted[2] != 0 {
+		t.Errorf("unexpected emitted counts %v", emitted)
+	}
+	for _, help := range result.GetOpenMxHelpList() {
+		if help.Metric == "absent_metric_sum" {
+			t.Errorf("expected no metadata for a rule without series")
+		}
+	}
+}
+
+func TestApplyAggregations_DropSource(t *testing.T) {
+	help := model.NewOpenMxHelp("http_requests_total")
+	help.Put("type", "counter")
+	result := model.NewConversionResult([]*model.OpenMx{
+		requestSample(1, "method", "GET", "path", "/a"),
+		model.NewOpenMx("up", 1000, 1),
+		requestSample(2, "method", "GET", "path", "/b"),
+		requestSample(math.NaN(), "method", "GET", "path", "/c"),
+	}, []*model.OpenMxHelp{help})
+	ApplyAggregations(result, parseAggregations(t,
+		map[string]interface{}{
+			"sourceMetric": "http_requests_total",
+			"by":           []interface{}{"method"},
+			"op":           "sum",
+			"outputMetric": "http_requests_by_method",
+			"dropSource":   true,
+		},
+		// A second rule over the same source still sees the samples the first one drops
+		map[string]interface{}{
+			"sourceMetric": "http_requests_total",
+			"op":           "max",
+			"outputMetric": "http_requests_max",
+		},
+	))
+
+	list := result.GetOpenMxList()
+	if len(list) != 3 || list[0].Metric != "up" {
+		t.Fatalf("expected up followed by the two aggregated series, got %d series", len(list))
+	}
+	if got := aggregatedSeries(result, "http_requests_by_method"); got["method=GET"] != 3 {
+		t.Errorf("expected sum 3, got %v", got)
+	}
+	if got := aggregatedSeries(result, "http_requests_max"); got[""] != 2 {
+		t.Errorf("expected max 2, got %v", got)
+	}
+	if list[1].Timestamp != 1000 {
+		t.Errorf("expected the source timestamp, got %d", list[1].Timestamp)
+	}
+
+	// A sum keeps the source type, other operations are gauges
+	types := make(map[string]string)
+	for _, h := range result.GetOpenMxHelpList() {
+		types[h.Metric] = h.Get("type")
+	}
+	if types["http_requests_by_method"] != "counter" || types["http_requests_max"] != "gauge" {
+		t.Errorf("unexpected metadata types %v", types)
+	}
+}
+
+func TestParseAggregations_Invalid(t *testing.T) {
+	valid := func() map[string]interface{} {
+		return map[string]interface{}{
+			"sourceMetric": "http_requests_total",
+			"by":           []interface{}{"method"},
+			"op":           "sum",
+			"outputMetric": "http_requests_by_method",
+		}
+	}
+	tests := []struct {
+		field string
+		value interface{}
+		want  string
+	}{
+		{"op", "count", `unknown op "count"`},
+		{"op", nil, `unknown op ""`},
+		{"sourceMetric", nil, `sourceMetric "" is not a valid metric name`},
+		{"outputMetric", "http-requests", `outputMetric "http-requests" is not a valid metric name`},
+		{"outputMetric", "http_requests_total", `outputMetric "http_requests_total" is the sourceMetric`},
+		{"by", "method", "by: expected a list of labels"},
+		{"by", []interface{}{"method", "method"}, "listed twice"},
+		{"by", []interface{}{"method", 1}, "not a string"},
+	}
+	for _, tt := range tests {
+		rule := valid()
+		rule[tt.field] = tt.value
+		_, err := model.ParseAggregations([]interface{}{rule})
+		if err == nil || !strings.Contains(err.Error(), "aggregations[0]: ") || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s=%v: expected an error containing %q, got %v", tt.field, tt.value, tt.want, err)
+		}
+	}
+
+	// Replacing the source under its own name is allowed with dropSource
+	rule := valid()
+	rule["outputMetric"], rule["dropSource"] = "http_requests_total", true
+	if _, err := model.ParseAggregations([]interface{}{rule}); err != nil {
+		t.Errorf("unexpected error replacing the source: %v", err)
+	}
+}
@@ -0,0 +1,144 @@
+package converter
+
+import (
+	"strings"
+
+	"open-agent/pkg/model"
+)
+
+// InfoJoinResult summarizes the info joins applied to one scrape
+type InfoJoinResult struct {
+	// Joined is the number of labels copied onto series
+	Joined int
+	// Conflicts is the number of labels not copied because the series already had a label with that name
+	Conflicts int
+}
+
+// infoLabels are the labels one info series contributes
+type infoLabels []model.Label
+
+// ApplyInfoJoins copies the labels of info series onto the other series of the scrape that share
+// the join labels. Labels a series already has are kept and counted as conflicts. Info series are
+// dropped from the result unless KeepInfo is set. Must run on one full scrape.
+func ApplyInfoJoins(result *model.ConversionResult, joins model.InfoJoins) InfoJoinResult {
+	var summary InfoJoinResult
+	if result == nil || len(joins) == 0 {
+		return summary
+	}
+
+	// Index info series by join key
+	byMetric := make(map[string]int, len(joins))
+	for i, join := range joins {
+		if _, exists := byMetric[join.Metric]; !exists {
+			byMetric[join.Metric] = i
+		}
+	}
+	index := make([]map[string]infoLabels, len(joins))
+	for i := range index {
+		index[i] = make(map[string]infoLabels)
+	}
+	kept := make([]*model.OpenMx, 0, len(result.OpenMxList))
+	var series []*model.OpenMx
+	for _, om := range result.OpenMxList {
+		i, ok := byMetric[om.Metric]
+		if !ok {
+			series = append(series, om)
+			kept = append(kept, om)
+			continue
+		}
+		join := joins[i]
+		if join.KeepInfo {
+			kept = append(kept, om)
+		}
+		key, ok := joinKey(om, join.JoinOn)
+		if !ok {
+			continue
+		}
+		// The first info series for a key wins
+		if _, exists := index[i][key]; !exists {
+			index[i][key] = selectInfoLabels(om, join)
+		}
+	}
+
+	for _, om := range series {
+		for i, join := range joins {
+			key, ok := joinKey(om, join.JoinOn)
+			if !ok {
+				continue
+			}
+			labels, ok := index[i][key]
+			if !ok {
+				continue
+			}
+			for _, label := range labels {
+				if hasSeriesLabel(om, label.Key) {
+					summary.Conflicts++
+					continue
+				}
+				om.AddLabel(label.Key, label.Value)
+				summary.Joined++
+			}
+		}
+	}
+
+	result.OpenMxList = kept
+	return summary
+}
+
+// joinKey returns the values of the join labels, or false if the series lacks one of them
+func joinKey(om *model.OpenMx, joinOn []string) (string, bool) {
+	if len(joinOn) == 0 {
+		return "", true
+	}
+	values := make([]string, 0, len(joinOn))
+	for _, name := range joinOn {
+		value, ok := seriesLabel(om, name)
+		if !ok {
+			return "", false
+		}
+		values = append(values, value)
+	}
+	return strings.Join(values, "\xff"), true
+}
+
+// selectInfoLabels returns the labels of an info series to copy
+func selectInfoLabels(om *model.OpenMx, join *model.InfoJoin) infoLabels {
+	var labels infoLabels
+	if len(join.Labels) > 0 {
+		for _, name := range join.Labels {
+			if value, ok := seriesLabel(om, name); ok {
+				labels = append(labels, model.Label{Key: name, Value: value})
+			}
+		}
+		return labels
+	}
+	for _, label := range om.Labels {
+		if !containsString(join.JoinOn, label.Key) {
+			labels = append(labels, label)
+		}
+	}
+	return labels
+}
+
+func seriesLabel(om *model.OpenMx, name string) (string, bool) {
+	for _, label := range om.Labels {
+		if label.Key == name {
+			return label.Value, true
+		}
+	}
+	return "", false
+}
+
+func hasSeriesLabel(om *model.OpenMx, name string) bool {
+	_, ok := seriesLabel(om, name)
+	return ok
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+package converter
+
+import (
+	"testing"
+
+	"open-agent/pkg/model"
+)
+
+func parseInfoJoins(t *testing.T, configs ...map[string]interface{}) model.InfoJoins {
+	t.Helper()
+	raw := make([]interface{}, 0, len(configs))
+	for _, c := range configs {
+		raw = append(raw, c)
+	}
+	joins, err := model.ParseInfoJoins(raw)
+	if err != nil {
+		t.Fatalf("ParseInfoJoins: %v", err)
+	}
+	return joins
+}
+
+func newSeries(metric string, value float64, labels ...string) *model.OpenMx {
+	om := model.NewOpenMx(metric, 0, value)
+	for i := 0; i+1 < len(labels); i += 2 {
+		om.AddLabel(labels[i], labels[i+1])
+	}
+	return om
+}
+
+func TestApplyInfoJoins_KubePodInfo(t *testing.T) {
+	joins := parseInfoJoins(t, map[string]interface{}{
+		"metric": "kube_pod_info",
+		"labels": []interface{}{"node", "host_ip"},
+		"joinOn": []interface{}{"namespace", "pod"},
+	})
+	result := model.NewConversionResult([]*model.OpenMx{
+		newSeries("kube_pod_info", 1, "namespace", "team-a", "pod", "api-0", "node", "worker-01", "host_ip", "10.0.1.1", "uid", "u0"),
+		newSeries("kube_pod_info", 1, "namespace", "team-b", "pod", "api-0", "node", "worker-02", "host_ip", "10.0.1.2", "uid", "u1"),
+		newSeries("kube_pod_status_ready", 1, "namespace", "team-a", "pod", "api-0", "condition", "true"),
+		newSeries("kube_pod_status_ready", 1, "namespace", "team-b", "pod", "api-0", "condition", "true"),
+		newSeries("kube_pod_status_ready", 1, "namespace", "team-c", "pod", "api-0", "condition", "true"),
+		newSeries("kube_node_info", 1, "node", "worker-01"),
+	}, nil)
+
+	summary := ApplyInfoJoins(result, joins)
+	if summary.Joined != 4 || summary.Conflicts != 0 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+	if len(result.GetOpenMxList()) != 4 {
+		t.Fatalf("expected kube_pod_info to be dropped, got %d series", len(result.GetOpenMxList()))
+	}
+
+	a := mustSingle(t, result.GetOpenMxList(), "kube_pod_status_ready", "namespace", "team-a")
+	if v, _ := labelValue(a, "node"); v != "worker-01" {
+		t.Errorf("team-a: expected node worker-01, got %v", a.Labels)
+	}
+	if hasSeriesLabel(a, "uid") {
+		t.Errorf("team-a: only the listed labels should be copied, got %v", a.Labels)
+	}
+	b := mustSingle(t, result.GetOpenMxList(), "kube_pod_status_ready", "namespace", "team-b")
+	if v, _ := labelValue(b, "host_ip"); v != "10.0.1.2" {
+		t.Errorf("team-b: expected host_ip 10.0.1.2, got %v", b.Labels)
+	}
+	c := mustSingle(t, result.GetOpenMxList(), "kube_pod_status_ready", "namespace", "team-c")
+	if hasSeriesLabel(c, "node") {
+		t.Errorf("team-c has no info series and must not be joined, got %v", c.Labels)
+	}
+	node := mustSingle(t, result.GetOpenMxList(), "kube_node_info", "node", "worker-01")
+	if hasSeriesLabel(node, "host_ip") {
+		t.Errorf("series without the join labels must not be joined, got %v", node.Labels)
+	}
+}
+
+func TestApplyInfoJoins_TargetWide(t *testing.T) {
+	joins := parseInfoJoins(t, map[string]interface{}{"metric": "node_uname_info", "keepInfo": true})
+	result := model.NewConversionResult([]*model.OpenMx{
+		newSeries("node_uname_info", 1, "release", "6.1.0", "machine", "x86_64"),
+		newSeries("node_load1", 0.5),
+		newSeries("node_cpu_seconds_total", 120, "cpu", "0", "mode", "idle"),
+	}, nil)
+
+	summary := ApplyInfoJoins(result, joins)
+	if summary.Joined != 4 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+	if len(result.GetOpenMxList()) != 3 {
+		t.Fatalf("keepInfo should keep the info series, got %d series", len(result.GetOpenMxList()))
+	}
+	for _, om := range result.GetOpenMxList()[1:] {
+		if v, _ := labelValue(om, "release"); v != "6.1.0" {
+			t.Errorf("%s: expected release 6.1.0, got %v", om.Metric, om.Labels)
+		}
+	}
+}
+
+func TestApplyInfoJoins_ExistingLabelWins(t *testing.T) {
+	joins := parseInfoJoins(t, map[string]interface{}{"metric": "kube_pod_info", "joinOn": "pod"})
+	result := model.NewConversionResult([]*model.OpenMx{
+		newSeries("kube_pod_info", 1, "pod", "api-0", "node", "worker-01", "created_by_kind", "ReplicaSet"),
+		newSeries("kube_pod_container_info", 1, "pod", "api-0", "node", "worker-09"),
+	}, nil)
+
+	summary := ApplyInfoJoins(result, joins)
+	if summary.Joined != 1 || summary.Conflicts != 1 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+	om := result.GetOpenMxList()[0]
+	if v, _ := labelValue(om, "node"); v != "worker-09" {
+		t.Errorf("existing label must win, got %v", om.Labels)
+	}
+	if v, _ := labelValue(om, "created_by_kind"); v != "ReplicaSet" {
+		t.Errorf("expected created_by_kind to be joined, got %v", om.Labels)
+	}
+}
+
+func TestParseInfoJoins_RequiresMetric(t *testing.T) {
+	if _, err := model.ParseInfoJoins([]interface{}{map[string]interface{}{"labels": []interface{}{"node"}}}); err == nil {
+		t.Fatal("expected an error for a missing metric")
+	}
+}
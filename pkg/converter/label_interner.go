@@ -0,0 +1,82 @@
+package converter
+
+import "strings"
+
+const (
+	// DefaultInternMaxStrings bounds the strings a LabelInterner keeps before it starts over
+	DefaultInternMaxStrings = 100000
+	// DefaultInternResetScrapes is how many scrapes a LabelInterner serves before it starts over,
+	// so values of deleted pods and rotated series do not stay in the pool
+	DefaultInternResetScrapes = 1000
+)
+
+// LabelInterner deduplicates metric names, label names and label values across scrapes.
+// Exporters such as kube-state-metrics repeat a few names and values on every series;
+// with interning all those series share one copy, and the parsed labels no longer keep
+// the whole scrape body alive.
+//
+// A LabelInterner is not safe for concurrent use; each processor owns one.
+type LabelInterner struct {
+	strings      map[string]string
+	maxStrings   int
+	resetScrapes int
+	scrapes      int
+}
+
+// NewLabelInterner creates a LabelInterner keeping at most maxStrings strings and
+// starting over every resetScrapes scrapes. Non-positive values select the defaults.
+func NewLabelInterner(maxStrings, resetScrapes int) *LabelInterner {
+	if maxStrings <= 0 {
+		maxStrings = DefaultInternMaxStrings
+	}
+	if resetScrapes <= 0 {
+		resetScrapes = DefaultInternResetScrapes
+	}
+	return &LabelInterner{
+		strings:      make(map[string]string),
+		maxStrings:   maxStrings,
+		resetScrapes: resetScrapes,
+	}
+}
+
+// Intern returns the pooled copy of s, adding a copy of s to the pool when it is new.
+// A nil LabelInterner returns s unchanged.
+func (in *LabelInterner) Intern(s string) string {
+	if in == nil || s == "" {
+		return s
+	}
+	if pooled, ok := in.strings[s]; ok {
+		return pooled
+	}
+	if len(in.strings) >= in.maxStrings {
+		in.reset()
+	}
+	// Copy so the pool does not pin the scrape body s points into
+	s = strings.Clone(s)
+	in.strings[s] = s
+	return s
+}
+
+// EndScrape marks the end of a scrape and empties the pool every resetScrapes scrapes
+func (in *LabelInterner) EndScrape() {
+	if in == nil {
+		return
+	}
+	in.scrapes++
+	if in.scrapes >= in.resetScrapes {
+		in.reset()
+	}
+}
+
+// Len returns the number of pooled strings
+func (in *LabelInterner) Len() int {
+	if in == nil {
+		return 0
+	}
+	return len(in.strings)
+}
+
+func (in *LabelInterner) reset() {
+	in.strings = make(map[string]string)
+	in.scrapes = 0
+}
@@ -0,0 +1,65 @@
+package converter
+
+import (
+	"fmt"
+	"hash/fnv"
+	"unicode/utf8"
+
+	"open-agent/pkg/model"
+)
+
+// LabelLengthResult counts what ApplyLabelLengthLimit did to one scrape
+type LabelLengthResult struct {
+	Truncated int // label values shortened
+	Dropped   int // samples dropped in strict mode
+}
+
+// ApplyLabelLengthLimit enforces the label value length limit of a target and returns the samples
+// to keep. In truncate mode a longer value is cut and ends with a hash of the whole value, so
+// distinct long values stay distinct; in strict mode the sample is dropped.
+func ApplyLabelLengthLimit(metrics []*model.OpenMx, limit *model.LabelLengthLimit) ([]*model.OpenMx, LabelLengthResult) {
+	var result LabelLengthResult
+	if limit == nil {
+		return metrics, result
+	}
+
+	kept := metrics[:0]
+	for _, om := range metrics {
+		keep := true
+		for i, label := range om.Labels {
+			if len(label.Value) <= limit.Limit {
+				continue
+			}
+			if limit.Mode == model.LabelLengthStrict {
+				keep = false
+				break
+			}
+			om.Labels[i].Value = truncateLabelValue(label.Value, limit.Limit)
+			result.Truncated++
+		}
+		if !keep {
+			result.Dropped++
+			continue
+		}
+		kept = append(kept, om)
+	}
+	// Release the dropped samples held past the end of the slice
+	for i := len(kept); i < len(metrics); i++ {
+		metrics[i] = nil
+	}
+	return kept, result
+}
+
+// truncateLabelValue cuts value to at most limit bytes, ending with model.LabelHashLength hex digits
+// of its FNV-1a hash. The cut does not split a UTF-8 character.
+func truncateLabelValue(value string, limit int) string {
+	h := fnv.New32a()
+	h.Write([]byte(value))
+	suffix := fmt.Sprintf("%08x", h.Sum32())[:model.LabelHashLength]
+
+	cut := limit - model.LabelHashLength
+	for cut > 0 && !utf8.RuneStart(value[cut]) {
+		cut--
+	}
+	return value[:cut] + suffix
+}
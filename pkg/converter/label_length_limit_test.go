@@ -0,0 +1,115 @@
+package converter
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"open-agent/pkg/model"
+)
+
+func parseLabelLengthLimit(t *testing.T, limit int, mode string) *model.LabelLengthLimit {
+	t.Helper()
+	parsed, err := model.ParseLabelLengthLimit(limit, mode)
+	if err != nil {
+		t.Fatalf("ParseLabelLengthLimit: %v", err)
+	}
+	return parsed
+}
+
+func queryMetric(query string) *model.OpenMx {
+	om := model.NewOpenMx("pg_stat_statements_calls", 0, 1)
+	om.AddLabel("datname", "orders")
+	om.AddLabel("query", query)
+	return om
+}
+
+func TestApplyLabelLengthLimit_Truncate(t *testing.T) {
+	limit := parseLabelLengthLimit(t, 32, "")
+	long1 := "SELECT * FROM orders WHERE customer_id = $1 AND status = 'open'"
+	long2 := "SELECT * FROM orders WHERE customer_id = $1 AND status = 'closed'"
+	metrics := []*model.OpenMx{queryMetric("SELECT 1"), queryMetric(long1), queryMetric(long2)}
+
+	kept, result := ApplyLabelLengthLimit(metrics, limit)
+	if len(kept) != 3 || result.Truncated != 2 || result.Dropped != 0 {
+		t.Fatalf("unexpected result %+v, kept %d", result, len(kept))
+	}
+	if kept[0].Labels[1].Value != "SELECT 1" {
+		t.Errorf("short value changed: %q", kept[0].Labels[1].Value)
+	}
+	v1, v2 := kept[1].Labels[1].Value, kept[2].Labels[1].Value
+	if len(v1) != 32 || len(v2) != 32 {
+		t.Errorf("expected values cut to 32 bytes, got %q and %q", v1, v2)
+	}
+	if !strings.HasPrefix(v1, long1[:32-model.LabelHashLength]) {
+		t.Errorf("truncated value should keep the start of the original, got %q", v1)
+	}
+	if v1 == v2 {
+		t.Errorf("distinct long values should stay distinct, both are %q", v1)
+	}
+}
+
+func TestApplyLabelLengthLimit_Strict(t *testing.T) {
+	limit := parseLabelLengthLimit(t, 32, "strict")
+	metrics := []*model.OpenMx{
+		queryMetric("SELECT 1"),
+		queryMetric(strings.Repeat("x", 33)),
+		queryMetric(strings.Repeat("y", 32)),
+	}
+
+	kept, result := ApplyLabelLengthLimit(metrics, limit)
+	if len(kept) != 2 || result.Dropped != 1 || result.Truncated != 0 {
+		t.Fatalf("unexpected result %+v, kept %d", result, len(kept))
+	}
+	if kept[1].Labels[1].Value != strings.Repeat("y", 32) {
+		t.Errorf("value at the limit should be kept as is, got %q", kept[1].Labels[1].Value)
+	}
+}
+
+func TestApplyLabelLengthLimit_Off(t *testing.T) {
+	if limit := parseLabelLengthLimit(t, 32, "off"); limit != nil {
+		t.Fatalf("off should disable the limit, got %+v", limit)
+	}
+	metrics := []*model.OpenMx{queryMetric(strings.Repeat("x", 100))}
+	kept, result := ApplyLabelLengthLimit(metrics, nil)
+	if len(kept) != 1 || result != (LabelLengthResult{}) || len(kept[0].Labels[1].Value) != 100 {
+		t.Errorf("no limit should leave samples untouched, got %+v", result)
+	}
+}
+
+func TestTruncateLabelValue_StableHash(t *testing.T) {
+	value := "https://example.com/search?q=" + strings.Repeat("a", 200)
+	first := truncateLabelValue(value, 40)
+	if second := truncateLabelValue(value, 40); first != second {
+		t.Errorf("truncation is not deterministic: %q and %q", first, second)
+	}
+	// Pinned so a change of the hash, which would split existing series, is noticed
+	if suffix := first[len(first)-model.LabelHashLength:]; suffix != "1062a8" {
+		t.Errorf("hash suffix changed: %q", suffix)
+	}
+
+	// The cut does not split a multi-byte character
+	korean := strings.Repeat("가", 20)
+	truncated := truncateLabelValue(korean, 16)
+	if !utf8.ValidString(truncated) || len(truncated) > 16 {
+		t.Errorf("invalid truncation %q", truncated)
+	}
+}
+
+func TestParseLabelLengthLimit_Invalid(t *testing.T) {
+	for _, tc := range []struct {
+		limit int
+		mode  string
+	}{
+		{-1, "truncate"},
+		{8, "truncate"},
+		{64, "cut"},
+	} {
+		if _, err := model.ParseLabelLengthLimit(tc.limit, tc.mode); err == nil {
+			t.Errorf("expected an error for limit %d mode %q", tc.limit, tc.mode)
+		}
+	}
+	if limit, err := model.ParseLabelLengthLimit(8, "strict"); err != nil || limit.Limit != 8 {
+		t.Errorf("strict accepts small limits, got %+v %v", limit, err)
+	}
+}
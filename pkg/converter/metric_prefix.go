@@ -0,0 +1,54 @@
+package converter
+
+import (
+	"sort"
+	"strings"
+
+	"open-agent/pkg/model"
+)
+
+// ApplyMetricPrefix prefixes the names of samples, native histograms and HELP/TYPE metadata
+// so they stay consistent. Names that already start with the prefix are left unchanged.
+// It returns the prefixed names that collide with a metric the target already exposes.
+func ApplyMetricPrefix(result *model.ConversionResult, prefix string) []string {
+	if prefix == "" || result == nil {
+		return nil
+	}
+
+	existing := make(map[string]bool)
+	for _, om := range result.OpenMxList {
+		existing[om.Metric] = true
+	}
+	for _, h := range result.OpenMxHistogramList {
+		existing[h.Metric] = true
+	}
+
+	collisions := make(map[string]bool)
+	rename := func(name string) string {
+		if strings.HasPrefix(name, prefix) {
+			return name
+		}
+		prefixed := prefix + name
+		if existing[prefixed] {
+			collisions[prefixed] = true
+		}
+		return prefixed
+	}
+
+	for _, om := range result.OpenMxList {
+		om.Metric = rename(om.Metric)
+	}
+	for _, h := range result.OpenMxHistogramList {
+		h.Metric = rename(h.Metric)
+	}
+	for _, help := range result.OpenMxHelpList {
+		help.Metric = rename(help.Metric)
+	}
+
+	names := make([]string, 0, len(collisions))
+	for name := range collisions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
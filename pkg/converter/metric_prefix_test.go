@@ -0,0 +1,64 @@
+package converter
+
+import (
+	"reflect"
+	"testing"
+)
+
+const vendorExposition = `# HELP temperature_celsius Chassis temperature
+# TYPE temperature_celsius gauge
+temperature_celsius{sensor="a"} 41
+# HELP vendor_uptime_seconds Appliance uptime
+# TYPE vendor_uptime_seconds counter
+vendor_uptime_seconds 1200
+# HELP fan_rpm Fan speed
+# TYPE fan_rpm gauge
+fan_rpm 3000
+`
+
+func TestApplyMetricPrefix_SamplesAndMetadata(t *testing.T) {
+	result, err := ConvertWithTimestamp(vendorExposition, 1000)
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+
+	if collisions := ApplyMetricPrefix(result, "vendor_"); len(collisions) != 0 {
+		t.Fatalf("expected no collisions, got %v", collisions)
+	}
+
+	names := make(map[string]bool)
+	for _, om := range result.GetOpenMxList() {
+		names[om.Metric] = true
+	}
+	want := map[string]bool{"vendor_temperature_celsius": true, "vendor_uptime_seconds": true, "vendor_fan_rpm": true}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("unexpected sample names %v", names)
+	}
+
+	// HELP/TYPE metadata must carry the same names as the samples
+	for _, help := range result.GetOpenMxHelpList() {
+		if !want[help.Metric] {
+			t.Errorf("metadata %s does not match any prefixed sample", help.Metric)
+		}
+	}
+}
+
+func TestApplyMetricPrefix_Collision(t *testing.T) {
+	result, err := ConvertWithTimestamp("fan_rpm 3000\nvendor_fan_rpm 2900\n", 1000)
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+
+	collisions := ApplyMetricPrefix(result, "vendor_")
+	if !reflect.DeepEqual(collisions, []string{"vendor_fan_rpm"}) {
+		t.Fatalf("expected collision on vendor_fan_rpm, got %v", collisions)
+	}
+}
+
+func TestApplyMetricPrefix_Empty(t *testing.T) {
+	result, _ := ConvertWithTimestamp("fan_rpm 3000\n", 1000)
+	ApplyMetricPrefix(result, "")
+	if result.GetOpenMxList()[0].Metric != "fan_rpm" {
+		t.Errorf("empty prefix must not rename metrics")
+	}
+}
@@ -18,6 +18,9 @@ const (
 	typeText = "# TYPE"
 )
 
+// ProgressLines is how many lines the text parser reads between ConvertOptions.Progress calls
+const ProgressLines = 100000
+
 // Convert converts Prometheus metrics to OpenMx format
 func Convert(prometheusData string) (*model.ConversionResult, error) {
 	return ConvertWithTimestamp(prometheusData, time.Now().UnixMilli())
@@ -25,11 +28,26 @@ func Convert(prometheusData string) (*model.ConversionResult, error) {
 
 // ConvertWithTimestamp converts Prometheus metrics to OpenMx format using the provided timestamp
 func ConvertWithTimestamp(prometheusData string, collectionTime int64) (*model.ConversionResult, error) {
-	openMxList := make([]*model.OpenMx, 0)
+	return convertText(prometheusData, collectionTime, ConvertOptions{})
+}
+
+// convertText parses the text exposition format
+func convertText(prometheusData string, collectionTime int64, opts ConvertOptions) (*model.ConversionResult, error) {
+	openMxList := make([]*model.OpenMx, 0, strings.Count(prometheusData, "\n")+1)
 	helpMap := make(map[string]*model.OpenMxHelp)
 
-	lines := strings.Split(prometheusData, "\n")
-	for _, line := range lines {
+	lines := 0
+	for rest := prometheusData; rest != ""; {
+		line := rest
+		if newline := strings.IndexByte(rest, '\n'); newline >= 0 {
+			line, rest = rest[:newline], rest[newline+1:]
+		} else {
+			rest = ""
+		}
+		lines++
+		if opts.Progress != nil && lines%ProgressLines == 0 {
+			opts.Progress(lines)
+		}
 		line = strings.TrimSpace(line)
 		if line == "" {
 			continue
@@ -60,7 +78,7 @@ func ConvertWithTimestamp(prometheusData string, collectionTime int64) (*model.C
 				omh.Put("type", typeText)
 			}
 		} else {
-			om, err := parseRecordLine(line, collectionTime)
+			om, err := parseRecordLine(line, collectionTime, opts)
 			if err != nil {
 				continue
 			}
@@ -347,10 +365,11 @@ func getReplacementValue(metric *model.OpenMx, config *model.RelabelConfig) stri
 }
 
 // parseRecordLine parses a single line of Prometheus metrics data
-func parseRecordLine(line string, timestamp int64) (*model.OpenMx, error) {
+func parseRecordLine(line string, timestamp int64, opts ConvertOptions) (*model.OpenMx, error) {
 	var metricName string
 	var value float64
 	var labels []model.Label
+	interner := opts.Interner
 
 	// Check if the line has labels
 	braceIndex := strings.Index(line, "{")
@@ -363,22 +382,28 @@ func parseRecordLine(line string, timestamp int64) (*model.OpenMx, error) {
 
 		// Parse labels
 		labelContent := line[braceIndex+1 : endBrace]
-		if labelContent != "" {
-			labelPairs := strings.Split(labelContent, ",")
-			for _, pair := range labelPairs {
-				kv := strings.SplitN(pair, "=", 2)
-				if len(kv) == 2 {
-					key := strings.TrimSpace(kv[0])
-					val := strings.TrimSpace(kv[1])
-
-					// Remove quotes if present
-					if strings.HasPrefix(val, "\"") && strings.HasSuffix(val, "\"") && len(val) >= 2 {
-						val = val[1 : len(val)-1]
-					}
+		// Size the labels once for the parsed pairs and the labels added after conversion
+		labels = make([]model.Label, 0, strings.Count(labelContent, ",")+1+opts.ExtraLabels)
+		for rest := labelContent; rest != ""; {
+			pair := rest
+			if comma := strings.IndexByte(rest, ','); comma >= 0 {
+				pair, rest = rest[:comma], rest[comma+1:]
+			} else {
+				rest = ""
+			}
+			eq := strings.IndexByte(pair, '=')
+			if eq < 0 {
+				continue
+			}
+			key := strings.TrimSpace(pair[:eq])
+			val := strings.TrimSpace(pair[eq+1:])
 
-					labels = append(labels, model.Label{Key: key, Value: val})
-				}
+			// Remove quotes if present
+			if strings.HasPrefix(val, "\"") && strings.HasSuffix(val, "\"") && len(val) >= 2 {
+				val = val[1 : len(val)-1]
 			}
+
+			labels = append(labels, model.Label{Key: interner.Intern(key), Value: interner.Intern(val)})
 		}
 
 		// Parse value and optional timestamp
@@ -439,10 +464,11 @@ func parseRecordLine(line string, timestamp int64) (*model.OpenMx, error) {
 		}
 	}
 
-	om := model.NewOpenMx(metricName, timestamp, value)
-	for _, label := range labels {
-		om.AddLabel(label.Key, label.Value)
+	om := model.NewOpenMx(interner.Intern(metricName), timestamp, value)
+	if labels == nil {
+		labels = make([]model.Label, 0, opts.ExtraLabels)
 	}
+	om.Labels = labels
 
 	return om, nil
 }
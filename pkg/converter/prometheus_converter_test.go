@@ -0,0 +1,140 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// kubeStateMetricsBody renders a kube-state-metrics style exposition for pods pods:
+// few label names and low-cardinality values repeated on every series
+func kubeStateMetricsBody(pods int) string {
+	var b strings.Builder
+	b.WriteString("# HELP kube_pod_info Information about pod.\n# TYPE kube_pod_info gauge\n")
+	for i := 0; i < pods; i++ {
+		fmt.Fprintf(&b, "kube_pod_info{namespace=\"team-%d\",pod=\"api-server-7d9f8b6c5-%05d\",uid=\"3f1c2a4e-%04d-4b7a-9c1d-0242ac120002\",host_ip=\"10.0.1.%d\",node=\"worker-%02d\",created_by_kind=\"ReplicaSet\"} 1\n",
+			i%12, i, i, i%250, i%20)
+	}
+	b.WriteString("# HELP kube_pod_status_phase The pods current phase.\n# TYPE kube_pod_status_phase gauge\n")
+	for i := 0; i < pods; i++ {
+		for _, phase := range []string{"Pending", "Running", "Succeeded", "Failed", "Unknown"} {
+			value := 0
+			if phase == "Running" {
+				value = 1
+			}
+			fmt.Fprintf(&b, "kube_pod_status_phase{namespace=\"team-%d\",pod=\"api-server-7d9f8b6c5-%05d\",uid=\"3f1c2a4e-%04d-4b7a-9c1d-0242ac120002\",phase=\"%s\"} %d\n",
+				i%12, i, i, phase, value)
+		}
+	}
+	b.WriteString("# HELP kube_pod_container_resource_requests The number of requested resource by a container.\n# TYPE kube_pod_container_resource_requests gauge\n")
+	for i := 0; i < pods; i++ {
+		for _, resource := range []string{"cpu", "memory"} {
+			fmt.Fprintf(&b, "kube_pod_container_resource_requests{namespace=\"team-%d\",pod=\"api-server-7d9f8b6c5-%05d\",uid=\"3f1c2a4e-%04d-4b7a-9c1d-0242ac120002\",container=\"api\",node=\"worker-%02d\",resource=\"%s\",unit=\"core\"} 0.25\n",
+				i%12, i, i, i%20, resource)
+		}
+	}
+	return b.String()
+}
+
+func TestConvertWithOptions_InternsLabels(t *testing.T) {
+	interner := NewLabelInterner(0, 0)
+	body := kubeStateMetricsBody(3)
+	result, err := ConvertWithOptions(body, "text/plain", 1700000000000, ConvertOptions{Interner: interner, ExtraLabels: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, _ := ConvertWithTimestamp(body, 1700000000000)
+	if len(result.GetOpenMxList()) != len(plain.GetOpenMxList()) {
+		t.Fatalf("expected %d series, got %d", len(plain.GetOpenMxList()), len(result.GetOpenMxList()))
+	}
+	for i, om := range result.GetOpenMxList() {
+		want := plain.GetOpenMxList()[i]
+		if om.Metric != want.Metric || om.Value != want.Value || len(om.Labels) != len(want.Labels) {
+			t.Fatalf("series %d differs: %+v, want %+v", i, om, want)
+		}
+		for j := range om.Labels {
+			if om.Labels[j] != want.Labels[j] {
+				t.Fatalf("series %d label %d differs: %+v, want %+v", i, j, om.Labels[j], want.Labels[j])
+			}
+		}
+		if cap(om.Labels)-len(om.Labels) < 3 {
+			t.Errorf("series %d: no capacity reserved for extra labels", i)
+		}
+	}
+
+	// 3 metric names, 10 label names and 25 distinct values across 24 series
+	if interner.Len() != 38 {
+		t.Errorf("expected 38 pooled strings, got %d", interner.Len())
+	}
+}
+
+func TestLabelInterner_Bounded(t *testing.T) {
+	interner := NewLabelInterner(2, 3)
+	interner.Intern("a")
+	interner.Intern("b")
+	interner.Intern("c")
+	if interner.Len() != 1 {
+		t.Errorf("full pool should start over, got %d strings", interner.Len())
+	}
+	interner.EndScrape()
+	interner.EndScrape()
+	interner.EndScrape()
+	if interner.Len() != 0 {
+		t.Errorf("pool should start over after 3 scrapes, got %d strings", interner.Len())
+	}
+	var none *LabelInterner
+	if none.Intern("x") != "x" {
+		t.Errorf("nil interner should return the string")
+	}
+}
+
+// BenchmarkConvertKubeStateMetrics parses like the processor, which appends the target labels
+// and pcode to every series. Compare with -bench=ConvertKubeStateMetrics -benchmem.
+func BenchmarkConvertKubeStateMetrics(b *testing.B) {
+	b.Run("plain", func(b *testing.B) {
+		benchmarkConvert(b, ConvertOptions{})
+	})
+	b.Run("interned", func(b *testing.B) {
+		benchmarkConvert(b, ConvertOptions{Interner: NewLabelInterner(0, 0), ExtraLabels: 3})
+	})
+}
+
+func benchmarkConvert(b *testing.B, opts ConvertOptions) {
+	body := kubeStateMetricsBody(500)
+	b.SetBytes(int64(len(body)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		result, err := ConvertWithOptions(body, "text/plain", 1700000000000, opts)
+		if err != nil {
+			b.Fatal(err)
+		}
+		// The processor appends the target labels and pcode to every series
+		for _, om := range result.GetOpenMxList() {
+			om.AddLabel("job", "kube-state-metrics")
+			om.AddLabel("instance", "10.0.3.17:8080")
+			om.AddLabel("pcode", "12345")
+		}
+	}
+}
+
+func TestConvertWithOptions_ReportsProgress(t *testing.T) {
+	var body strings.Builder
+	total := 2*ProgressLines + 10
+	for i := 0; i < total; i++ {
+		fmt.Fprintf(&body, "requests_total{id=\"%d\"} 1\n", i)
+	}
+
+	var reported []int
+	result, err := ConvertWithOptions(body.String(), "text/plain", 1000, ConvertOptions{
+		Progress: func(lines int) { reported = append(reported, lines) },
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result.GetOpenMxList()) != total {
+		t.Fatalf("expected %d series, got %d", total, len(result.GetOpenMxList()))
+	}
+	if len(reported) != 2 || reported[0] != ProgressLines || reported[1] != 2*ProgressLines {
+		t.Errorf("expected progress at %d and %d lines, got %v", ProgressLines, 2*ProgressLines, reported)
+	}
+}
@@ -44,6 +44,27 @@ func ConvertWithContentType(data []byte, contentType string, collectionTime int6
 	return ConvertWithTimestamp(string(data), collectionTime)
 }
 
+// ConvertOptions tunes how the text parser allocates the parsed series
+type ConvertOptions struct {
+	// Interner deduplicates metric names and label strings; nil disables interning
+	Interner *LabelInterner
+	// ExtraLabels is the label capacity reserved on every series for labels added after
+	// conversion, such as the target labels and pcode
+	ExtraLabels int
+	// Progress is called with the number of lines parsed every ProgressLines lines of a text body;
+	// nil disables progress reporting
+	Progress func(lines int)
+}
+
+// ConvertWithOptions is ConvertWithContentType for a body already held as a string.
+// The options apply to the text parser; protobuf payloads decode as in ConvertWithContentType.
+func ConvertWithOptions(data string, contentType string, collectionTime int64, opts ConvertOptions) (*model.ConversionResult, error) {
+	if IsProtobufContentType(contentType) {
+		return ConvertProtobufWithTimestamp([]byte(data), collectionTime)
+	}
+	return convertText(data, collectionTime, opts)
+}
+
 // ConvertProtobuf decodes a delimited Prometheus protobuf payload into OpenMx
 // using the current time as the collection timestamp.
 func ConvertProtobuf(data []byte) (*model.ConversionResult, error) {
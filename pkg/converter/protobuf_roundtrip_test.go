@@ -0,0 +1,121 @@
+package converter
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"google.golang.org/protobuf/proto"
+
+	"open-agent/pkg/model"
+)
+
+// encodeText encodes metric families in the text exposition format
+func encodeText(t *testing.T, families ...*dto.MetricFamily) string {
+	t.Helper()
+	var buf bytes.Buffer
+	enc := expfmt.NewEncoder(&buf, expfmt.NewFormat(expfmt.TypeTextPlain))
+	for _, mf := range families {
+		if err := enc.Encode(mf); err != nil {
+			t.Fatalf("failed to encode metric family %q: %v", mf.GetName(), err)
+		}
+	}
+	return buf.String()
+}
+
+// canonicalSeries renders each series as name{sorted labels} value@timestamp, sorted
+func canonicalSeries(list []*model.OpenMx) []string {
+	out := make([]string, 0, len(list))
+	for _, om := range list {
+		labels := make([]string, 0, len(om.Labels))
+		for _, l := range om.Labels {
+			labels = append(labels, l.Key+"="+l.Value)
+		}
+		sort.Strings(labels)
+		out = append(out, fmt.Sprintf("%s{%s} %v@%d", om.Metric, strings.Join(labels, ","), om.Value, om.Timestamp))
+	}
+	sort.Strings(out)
+	return out
+}
+
+// roundTripFamilies covers every metric type the text parser and the protobuf decoder share
+func roundTripFamilies() []*dto.MetricFamily {
+	return []*dto.MetricFamily{
+		{
+			Name: proto.String("http_requests_total"),
+			Help: proto.String("Requests served."),
+			Type: dto.MetricType_COUNTER.Enum(),
+			Metric: []*dto.Metric{
+				{Label: []*dto.LabelPair{labelPair("code", "200"), labelPair("method", "get")}, Counter: &dto.Counter{Value: proto.Float64(1027)}},
+				{Label: []*dto.LabelPair{labelPair("code", "500"), labelPair("method", "post")}, Counter: &dto.Counter{Value: proto.Float64(3)}},
+			},
+		},
+		{
+			Name:   proto.String("queue_depth"),
+			Type:   dto.MetricType_GAUGE.Enum(),
+			Metric: []*dto.Metric{{Gauge: &dto.Gauge{Value: proto.Float64(-2.5)}}},
+		},
+		{
+			Name: proto.String("request_duration_seconds"),
+			Type: dto.MetricType_HISTOGRAM.Enum(),
+			Metric: []*dto.Metric{{
+				Label: []*dto.LabelPair{labelPair("handler", "/api")},
+				Histogram: &dto.Histogram{
+					SampleCount: proto.Uint64(144),
+					SampleSum:   proto.Float64(53.4),
+					Bucket: []*dto.Bucket{
+						{UpperBound: proto.Float64(0.05), CumulativeCount: proto.Uint64(24)},
+						{UpperBound: proto.Float64(0.5), CumulativeCount: proto.Uint64(129)},
+						{UpperBound: proto.Float64(1), CumulativeCount: proto.Uint64(133)},
+					},
+				},
+			}},
+		},
+		{
+			Name: proto.String("rpc_duration_seconds"),
+			Type: dto.MetricType_SUMMARY.Enum(),
+			Metric: []*dto.Metric{{
+				Summary: &dto.Summary{
+					SampleCount: proto.Uint64(2693),
+					SampleSum:   proto.Float64(17560473),
+					Quantile: []*dto.Quantile{
+						{Quantile: proto.Float64(0.5), Value: proto.Float64(4773)},
+						{Quantile: proto.Float64(0.99), Value: proto.Float64(76656)},
+					},
+				},
+			}},
+		},
+		{
+			Name:   proto.String("build_info"),
+			Type:   dto.MetricType_UNTYPED.Enum(),
+			Metric: []*dto.Metric{{Label: []*dto.LabelPair{labelPair("version", "1.2.3")}, Untyped: &dto.Untyped{Value: proto.Float64(1)}}},
+		},
+	}
+}
+
+// TestConvert_ProtobufMatchesText decodes the same metric families from both exposition formats
+// and expects identical series, including the histogram and summary expansion
+func TestConvert_ProtobufMatchesText(t *testing.T) {
+	families := roundTripFamilies()
+
+	fromProto, err := ConvertWithContentType(encodeDelimited(t, families...), "application/vnd.google.protobuf;proto=io.prometheus.client.MetricFamily;encoding=delimited", testTS)
+	if err != nil {
+		t.Fatalf("protobuf: %v", err)
+	}
+	fromText, err := ConvertWithContentType([]byte(encodeText(t, families...)), "text/plain; version=0.0.4", testTS)
+	if err != nil {
+		t.Fatalf("text: %v", err)
+	}
+
+	got, want := canonicalSeries(fromProto.GetOpenMxList()), canonicalSeries(fromText.GetOpenMxList())
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("protobuf and text decoding differ\nprotobuf:\n%s\ntext:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if len(got) != 2+1+(3+1+2)+(2+2)+1 {
+		t.Errorf("unexpected series count %d:\n%s", len(got), strings.Join(got, "\n"))
+	}
+}
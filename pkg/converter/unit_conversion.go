@@ -0,0 +1,88 @@
+package converter
+
+import (
+	"sort"
+
+	"open-agent/pkg/model"
+)
+
+// UnitConversionResult summarizes the unit conversions applied to one scrape
+type UnitConversionResult struct {
+	// Converted is the number of samples whose value was scaled
+	Converted int
+	// Skipped is the number of samples left unconverted because of a collision
+	Skipped int
+	// Collisions are renamed metric names the target already exposes; those samples are left unconverted
+	Collisions []string
+}
+
+// ApplyUnitConversions scales and renames the samples and HELP/TYPE metadata of matching metrics.
+// Each metric is converted by the first matching rule only. A metric is left unchanged when its
+// renamed name already exists in the scrape, so exporters that expose both units are not converted twice.
+func ApplyUnitConversions(result *model.ConversionResult, rules model.UnitConversions) UnitConversionResult {
+	var summary UnitConversionResult
+	if result == nil || len(rules) == 0 {
+		return summary
+	}
+
+	existing := make(map[string]bool)
+	for _, om := range result.OpenMxList {
+		existing[om.Metric] = true
+	}
+
+	// Resolve each metric name once: the new name and multiplier, or skip
+	type conversion struct {
+		name       string
+		multiplier float64
+		ok         bool
+		collided   bool
+	}
+	resolved := make(map[string]conversion)
+	collisions := make(map[string]bool)
+	resolve := func(metric string) conversion {
+		if c, ok := resolved[metric]; ok {
+			return c
+		}
+		c := conversion{}
+		for _, rule := range rules {
+			name, ok := rule.Match(metric)
+			if !ok {
+				continue
+			}
+			if name != metric && existing[name] {
+				collisions[name] = true
+				c.collided = true
+			} else {
+				c = conversion{name: name, multiplier: rule.Multiplier, ok: true}
+			}
+			break
+		}
+		resolved[metric] = c
+		return c
+	}
+
+	for _, om := range result.OpenMxList {
+		c := resolve(om.Metric)
+		if !c.ok {
+			if c.collided {
+				summary.Skipped++
+			}
+			continue
+		}
+		om.Metric = c.name
+		om.Value *= c.multiplier
+		summary.Converted++
+	}
+	for _, help := range result.OpenMxHelpList {
+		// Only metadata of metrics present in the scrape is renamed
+		if c, ok := resolved[help.Metric]; ok && c.ok {
+			help.Metric = c.name
+		}
+	}
+
+	for name := range collisions {
+		summary.Collisions = append(summary.Collisions, name)
+	}
+	sort.Strings(summary.Collisions)
+	return summary
+}
@@ -0,0 +1,116 @@
+package converter
+
+import (
+	"testing"
+
+	"open-agent/pkg/model"
+)
+
+func parseUnitConversions(t *testing.T, configs ...map[string]interface{}) model.UnitConversions {
+	t.Helper()
+	raw := make([]interface{}, 0, len(configs))
+	for _, c := range configs {
+		raw = append(raw, c)
+	}
+	rules, err := model.ParseUnitConversions(raw)
+	if err != nil {
+		t.Fatalf("ParseUnitConversions: %v", err)
+	}
+	return rules
+}
+
+func TestApplyUnitConversions_ScaleAndRename(t *testing.T) {
+	rules := parseUnitConversions(t,
+		map[string]interface{}{"metricRegex": "(.+)_milliseconds", "multiplier": 0.001, "renameSuffix": "_seconds"},
+		map[string]interface{}{"metricRegex": "(.+)_bytes", "multiplier": "0.00000095367431640625", "renameSuffix": "_mebibytes"},
+		map[string]interface{}{"metricRegex": "temperature_fahrenheit_x10", "multiplier": 0.1},
+	)
+	result := model.NewConversionResult([]*model.OpenMx{
+		model.NewOpenMx("request_latency_milliseconds", 0, 250),
+		model.NewOpenMx("heap_bytes", 0, 2097152),
+		model.NewOpenMx("temperature_fahrenheit_x10", 0, 725),
+		model.NewOpenMx("up", 0, 1),
+	}, []*model.OpenMxHelp{model.NewOpenMxHelp("request_latency_milliseconds")})
+
+	summary := ApplyUnitConversions(result, rules)
+	if summary.Converted != 3 || summary.Skipped != 0 || len(summary.Collisions) != 0 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+
+	want := map[string]float64{
+		"request_latency_seconds":    0.25,
+		"heap_mebibytes":             2,
+		"temperature_fahrenheit_x10": 72.5,
+		"up":                         1,
+	}
+	for _, om := range result.GetOpenMxList() {
+		expected, ok := want[om.Metric]
+		if !ok {
+			t.Errorf("unexpected metric %s", om.Metric)
+			continue
+		}
+		if om.Value != expected {
+			t.Errorf("%s: expected %v, got %v", om.Metric, expected, om.Value)
+		}
+	}
+	if help := result.GetOpenMxHelpList()[0].Metric; help != "request_latency_seconds" {
+		t.Errorf("expected HELP to be renamed, got %s", help)
+	}
+}
+
+func TestApplyUnitConversions_FirstRuleWins(t *testing.T) {
+	rules := parseUnitConversions(t,
+		map[string]interface{}{"metricRegex": "(.+)_milliseconds", "multiplier": 0.001, "renameSuffix": "_seconds"},
+		map[string]interface{}{"metricRegex": ".+", "multiplier": 100},
+	)
+	result := model.NewConversionResult([]*model.OpenMx{model.NewOpenMx("gc_pause_milliseconds", 0, 20)}, nil)
+
+	ApplyUnitConversions(result, rules)
+	om := result.GetOpenMxList()[0]
+	if om.Metric != "gc_pause_seconds" || om.Value != 0.02 {
+		t.Fatalf("expected only the first rule to apply, got %s=%v", om.Metric, om.Value)
+	}
+}
+
+func TestApplyUnitConversions_RenameCollision(t *testing.T) {
+	rules := parseUnitConversions(t,
+		map[string]interface{}{"metricRegex": "(.+)_milliseconds", "multiplier": 0.001, "renameSuffix": "_seconds"},
+	)
+	// The exporter already exposes both units; converting would produce a second series with the same name
+	latencyMs := model.NewOpenMx("latency_milliseconds", 0, 1500)
+	latencyMs.AddLabel("path", "/a")
+	latencyMs2 := model.NewOpenMx("latency_milliseconds", 0, 500)
+	latencyMs2.AddLabel("path", "/b")
+	result := model.NewConversionResult([]*model.OpenMx{
+		latencyMs,
+		latencyMs2,
+		model.NewOpenMx("latency_seconds", 0, 1.5),
+		model.NewOpenMx("uptime_milliseconds", 0, 3000),
+	}, nil)
+
+	summary := ApplyUnitConversions(result, rules)
+	if summary.Converted != 1 || summary.Skipped != 2 {
+		t.Fatalf("expected 1 converted and 2 skipped, got %+v", summary)
+	}
+	if len(summary.Collisions) != 1 || summary.Collisions[0] != "latency_seconds" {
+		t.Fatalf("expected collision on latency_seconds, got %v", summary.Collisions)
+	}
+	if latencyMs.Metric != "latency_milliseconds" || latencyMs.Value != 1500 {
+		t.Errorf("colliding metric must be left unchanged, got %s=%v", latencyMs.Metric, latencyMs.Value)
+	}
+	if om := result.GetOpenMxList()[3]; om.Metric != "uptime_seconds" || om.Value != 3 {
+		t.Errorf("expected uptime_seconds=3, got %s=%v", om.Metric, om.Value)
+	}
+}
+
+func TestParseUnitConversions_Invalid(t *testing.T) {
+	for _, c := range []map[string]interface{}{
+		{"multiplier": 2},
+		{"metricRegex": "("},
+		{"metricRegex": "x", "multiplier": "ten"},
+	} {
+		if _, err := model.ParseUnitConversions([]interface{}{c}); err == nil {
+			t.Errorf("expected error for %v", c)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package converter
+
+import (
+	"math"
+
+	"open-agent/pkg/model"
+)
+
+// ApplyValueTransforms rewrites the sample values of matching metrics. Every matching rule is applied
+// in order, so scale 0.001 followed by clampMin 1 differs from the reverse. It returns the number of
+// samples each rule changed, indexed like rules.
+func ApplyValueTransforms(result *model.ConversionResult, rules model.ValueTransforms) []int {
+	if result == nil || len(rules) == 0 {
+		return nil
+	}
+	transformed := make([]int, len(rules))
+
+	// Resolve the matching rules of each metric name once
+	matching := make(map[string][]int)
+	for _, om := range result.OpenMxList {
+		// NaN is left to nonFiniteValues
+		if math.IsNaN(om.Value) {
+			continue
+		}
+		indexes, ok := matching[om.Metric]
+		if !ok {
+			for i, rule := range rules {
+				if rule.Matches(om.Metric) {
+					indexes = append(indexes, i)
+				}
+			}
+			matching[om.Metric] = indexes
+		}
+		for _, i := range indexes {
+			value := rules[i].Apply(om.Value)
+			if value != om.Value {
+				om.Value = value
+				transformed[i]++
+			}
+		}
+	}
+	return transformed
+}
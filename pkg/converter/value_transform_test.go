@@ -0,0 +1,119 @@
+package converter
+
+import (
+	"math"
+	"testing"
+
+	"open-agent/pkg/model"
+)
+
+func parseValueTransforms(t *testing.T, configs ...map[string]interface{}) model.ValueTransforms {
+	t.Helper()
+	raw := make([]interface{}, 0, len(configs))
+	for _, c := range configs {
+		raw = append(raw, c)
+	}
+	rules, err := model.ParseValueTransforms(raw)
+	if err != nil {
+		t.Fatalf("ParseValueTransforms: %v", err)
+	}
+	return rules
+}
+
+func TestApplyValueTransforms_Ops(t *testing.T) {
+	tests := []struct {
+		op    string
+		arg   interface{}
+		value float64
+		want  float64
+	}{
+		{"clampMin", 0, -3, 0},
+		{"clampMin", 0, 7, 7},
+		{"clampMax", "100", 120, 100},
+		{"clampMax", 100, 80, 80},
+		{"scale", 0.001, 2500, 2.5},
+		{"scale", -1, 4, -4},
+		{"abs", nil, -12.5, 12.5},
+		{"abs", nil, 3, 3},
+	}
+	for _, tt := range tests {
+		rule := map[string]interface{}{"metricRegex": "gauge", "op": tt.op}
+		if tt.arg != nil {
+			rule["arg"] = tt.arg
+		}
+		result := model.NewConversionResult([]*model.OpenMx{
+			model.NewOpenMx("gauge", 0, tt.value),
+			model.NewOpenMx("gauge_other", 0, tt.value),
+		}, nil)
+
+		transformed := ApplyValueTransforms(result, parseValueTransforms(t, rule))
+		list := result.GetOpenMxList()
+		if list[0].Value != tt.want {
+			t.Errorf("%s(%v) of %v: got %v, want %v", tt.op, tt.arg, tt.value, list[0].Value, tt.want)
+		}
+		if list[1].Value != tt.value {
+			t.Errorf("%s: metricRegex must match the whole name, gauge_other became %v", tt.op, list[1].Value)
+		}
+		wantCount := 0
+		if tt.want != tt.value {
+			wantCount = 1
+		}
+		if transformed[0] != wantCount {
+			t.Errorf("%s(%v) of %v: counted %d transformed samples, want %d", tt.op, tt.arg, tt.value, transformed[0], wantCount)
+		}
+	}
+}
+
+func TestApplyValueTransforms_RulesApplyInOrder(t *testing.T) {
+	values := func(rules model.ValueTransforms) (float64, []int) {
+		result := model.NewConversionResult([]*model.OpenMx{model.NewOpenMx("queue_depth", 0, -500)}, nil)
+		transformed := ApplyValueTransforms(result, rules)
+		return result.GetOpenMxList()[0].Value, transformed
+	}
+
+	scale := map[string]interface{}{"metricRegex": "queue_.*", "op": "scale", "arg": 0.01}
+	clamp := map[string]interface{}{"metricRegex": "queue_depth", "op": "clampMin", "arg": -1}
+	abs := map[string]interface{}{"metricRegex": ".*", "op": "abs"}
+
+	// -500 * 0.01 = -5, clamped to -1, then abs
+	value, transformed := values(parseValueTransforms(t, scale, clamp, abs))
+	if value != 1 || transformed[0] != 1 || transformed[1] != 1 || transformed[2] != 1 {
+		t.Errorf("scale, clampMin, abs: got %v (counts %v), want 1", value, transformed)
+	}
+	// -500 clamped to -1, then scaled to -0.01, then abs
+	value, _ = values(parseValueTransforms(t, clamp, scale, abs))
+	if value != 0.01 {
+		t.Errorf("clampMin, scale, abs: got %v, want 0.01", value)
+	}
+	// abs first leaves nothing for clampMin to do
+	value, transformed = values(parseValueTransforms(t, abs, clamp, scale))
+	if value != 5 || transformed[1] != 0 {
+		t.Errorf("abs, clampMin, scale: got %v (counts %v), want 5 with clampMin unused", value, transformed)
+	}
+}
+
+func TestApplyValueTransforms_LeavesNaN(t *testing.T) {
+	result := model.NewConversionResult([]*model.OpenMx{model.NewOpenMx("gauge", 0, math.NaN())}, nil)
+	transformed := ApplyValueTransforms(result, parseValueTransforms(t,
+		map[string]interface{}{"metricRegex": "gauge", "op": "clampMin", "arg": 0}))
+	if !math.IsNaN(result.GetOpenMxList()[0].Value) || transformed[0] != 0 {
+		t.Errorf("expected NaN to be left for nonFiniteValues, got %v (%d transformed)", result.GetOpenMxList()[0].Value, transformed[0])
+	}
+}
+
+func TestParseValueTransforms_Invalid(t *testing.T) {
+	tests := map[string]map[string]interface{}{
+		"unknown op":      {"metricRegex": "x", "op": "floor", "arg": 1},
+		"missing op":      {"metricRegex": "x", "arg": 1},
+		"non-numeric arg": {"metricRegex": "x", "op": "scale", "arg": "ten"},
+		"list arg":        {"metricRegex": "x", "op": "clampMax", "arg": []interface{}{1}},
+		"missing arg":     {"metricRegex": "x", "op": "clampMin"},
+		"missing regex":   {"op": "abs"},
+		"invalid regex":   {"metricRegex": "(", "op": "abs"},
+	}
+	for name, rule := range tests {
+		if _, err := model.ParseValueTransforms([]interface{}{rule}); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
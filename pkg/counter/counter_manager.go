@@ -12,8 +12,12 @@ import (
 	"github.com/whatap/golib/lang/value"
 	"github.com/whatap/golib/util/dateutil"
 
+	"open-agent/pkg/buildinfo"
+	"open-agent/pkg/diagnostics"
 	"open-agent/pkg/endpoint"
 	"open-agent/pkg/model"
+	"open-agent/pkg/scraper"
+	"open-agent/pkg/sender"
 	"open-agent/tools/util/logutil"
 )
 
@@ -189,6 +193,7 @@ func sendAgentBootInfo() {
 
 	// Agent version
 	p.PutString("whatap.version", os.Getenv("WHATAP_VERSION"))
+	p.PutString("whatap.commit", buildinfo.Commit())
 
 	// Agent start time
 	p.PutString("whatap.starttime", strconv.FormatInt(agentStartTime, 10))
@@ -269,6 +274,38 @@ func sendTagCountPack(now int64) {
 	}
 	p.Tags.Put("cpuCores", value.NewDecimalValue(int64(cpuCores)))
 
+	// Fields: agent build, to audit deployed versions
+	p.Put("version", buildinfo.Version())
+	p.Put("commit", buildinfo.Commit())
+	// Fields: send loop health (0 until the first pack is sent successfully)
+	p.Put("lastSendTime", sender.LastSuccessfulSendTime())
+	// Fields: packs sent by type
+	metricPacks, helpPacks := sender.PacksSent()
+	p.Put("metricPacksSent", metricPacks)
+	p.Put("helpPacksSent", helpPacks)
+	// Fields: packs the sender gave up on, retried sends, and the last send error ("" and 0 until a send fails)
+	permanentDeadLetters, exhaustedDeadLetters := sender.DeadLetterPacks()
+	p.Put("deadLetterPacks", permanentDeadLetters+exhaustedDeadLetters)
+	p.Put("sendRetries", sender.SendRetries())
+	lastSendError, lastSendErrorTime := sender.LastSendError()
+	p.Put("lastSendError", lastSendError)
+	p.Put("lastSendErrorTime", lastSendErrorTime)
+	// Fields: send phase offset within the cycle, -1 when the send phase is off
+	p.Put("sendPhaseOffset", sender.SendPhaseOffsetMillis())
+	// Fields: scrape response bytes, on the wire and decoded
+	wireBytes, bodyBytes := scraper.ScrapeBytesTotals()
+	p.Put("scrapeBytes", wireBytes)
+	p.Put("scrapeBodyBytes", bodyBytes)
+	// Fields: ready targets not scheduled because the agent reached openagent_max_schedulers
+	p.Put("unscheduledTargets", scraper.UnscheduledTargets())
+	// Fields: current parse duration and lines parsed (0 when idle), so a busy processor is told apart from a hung one
+	var busyMillis, parsedLines int64
+	if busy, lines, ok := diagnostics.Busy(diagnostics.ComponentProcessor, time.Now()); ok {
+		busyMillis, parsedLines = busy.Milliseconds(), lines
+	}
+	p.Put("processorBusyMillis", busyMillis)
+	p.Put("processorParsedLines", parsedLines)
+
 	//// Tags: name information (for server-side resolution)
 	//p.PutTag("oname", secu.ONAME)
 	//p.PutTag("okindName", secu.OKIND_NAME)
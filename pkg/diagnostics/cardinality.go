@@ -0,0 +1,328 @@
+package diagnostics
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"math/bits"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"open-agent/pkg/model"
+	"open-agent/tools/util/logutil"
+)
+
+const (
+	// CardinalityWindow is the period a cardinality summary covers
+	CardinalityWindow = time.Minute
+	// CardinalityTopMetrics is the number of metric names listed per target
+	CardinalityTopMetrics = 10
+	// CardinalityLogInterval is how often the heaviest targets are logged
+	CardinalityLogInterval = time.Hour
+	// CardinalityLogTargets is the number of targets logged every CardinalityLogInterval
+	CardinalityLogTargets = 5
+
+	// maxTrackedMetricNames bounds the metric names counted per target and window. Past it the
+	// least counted name is replaced (space-saving), which keeps the heavy hitters exact enough.
+	maxTrackedMetricNames = 1000
+	// cardinalityTargetTTL drops targets that have not been processed for this long
+	cardinalityTargetTTL = 10 * time.Minute
+
+	// hllPrecision sizes the distinct series estimator: 2^10 one-byte registers, about 3% error
+	hllPrecision = 10
+	hllRegisters = 1 << hllPrecision
+)
+
+// MetricCount is the number of samples of one metric name in a window
+type MetricCount struct {
+	Metric  string
+	Samples int64
+}
+
+// CardinalitySummary describes the samples a target produced in one window
+type CardinalitySummary struct {
+	TargetID string
+	Start    time.Time
+	// Partial is set when the window is still in progress because no full window was seen yet
+	Partial bool
+	Scrapes int64
+	Samples int64
+	// Series is an estimate of the distinct series (metric name and labels)
+	Series     int64
+	TopMetrics []MetricCount
+}
+
+// hyperLogLog estimates the number of distinct hashes with fixed memory
+type hyperLogLog [hllRegisters]uint8
+
+func (h *hyperLogLog) add(hash uint64) {
+	index := hash >> (64 - hllPrecision)
+	rank := uint8(bits.LeadingZeros64(hash<<hllPrecision|1<<(hllPrecision-1)) + 1)
+	if rank > h[index] {
+		h[index] = rank
+	}
+}
+
+func (h *hyperLogLog) estimate() int64 {
+	m := float64(hllRegisters)
+	sum, zeros := 0.0, 0
+	for _, r := range h {
+		sum += 1 / float64(uint64(1)<<r)
+		if r == 0 {
+			zeros++
+		}
+	}
+	estimate := 0.7213 / (1 + 1.079/m) * m * m / sum
+	if estimate <= 2.5*m && zeros > 0 {
+		// Linear counting is more accurate for small cardinalities
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return int64(math.Round(estimate))
+}
+
+// seriesHash hashes the metric name and labels of a sample
+func seriesHash(om *model.OpenMx) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(om.Metric))
+	for _, label := range om.Labels {
+		h.Write([]byte{0xff})
+		h.Write([]byte(label.Key))
+		h.Write([]byte{0xfe})
+		h.Write([]byte(label.Value))
+	}
+	// FNV's high bits are poorly mixed for short keys, and HyperLogLog indexes by them
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+// cardinalityWindow accumulates one window of a target
+type cardinalityWindow struct {
+	start   time.Time
+	scrapes int64
+	samples int64
+	series  hyperLogLog
+	metrics map[string]int64
+}
+
+func newCardinalityWindow(start time.Time) *cardinalityWindow {
+	return &cardinalityWindow{start: start, metrics: make(map[string]int64)}
+}
+
+// countMetric counts samples of a metric name, replacing the least counted name when the map is full
+func (w *cardinalityWindow) countMetric(name string, n int64) {
+	if _, ok := w.metrics[name]; !ok && len(w.metrics) >= maxTrackedMetricNames {
+		minName, minCount := "", int64(math.MaxInt64)
+		for tracked, count := range w.metrics {
+			if count < minCount || (count == minCount && tracked < minName) {
+				minName, minCount = tracked, count
+			}
+		}
+		delete(w.metrics, minName)
+		// The new name may have been counted under the evicted one
+		n += minCount
+	}
+	w.metrics[name] += n
+}
+
+func (w *cardinalityWindow) summary(targetID string, partial bool) CardinalitySummary {
+	top := make([]MetricCount, 0, len(w.metrics))
+	for name, count := range w.metrics {
+		top = append(top, MetricCount{Metric: name, Samples: count})
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Samples != top[j].Samples {
+			return top[i].Samples > top[j].Samples
+		}
+		return top[i].Metric < top[j].Metric
+	})
+	if len(top) > CardinalityTopMetrics {
+		top = top[:CardinalityTopMetrics]
+	}
+	return CardinalitySummary{
+		TargetID:   targetID,
+		Start:      w.start,
+		Partial:    partial,
+		Scrapes:    w.scrapes,
+		Samples:    w.samples,
+		Series:     w.series.estimate(),
+		TopMetrics: top,
+	}
+}
+
+// targetCardinality is the current window of a target and the summary of the last complete one
+type targetCardinality struct {
+	current  *cardinalityWindow
+	last     *CardinalitySummary
+	lastSeen time.Time
+}
+
+// CardinalityTracker summarizes the samples each target produces per minute: total samples,
+// an estimate of the distinct series and the metric names with the most samples, to help find
+// what to drop with metricRelabelConfigs.
+type CardinalityTracker struct {
+	mu      sync.Mutex
+	targets map[string]*targetCardinality
+	lastLog time.Time
+	now     func() time.Time
+	logf    func(format string, args ...interface{})
+}
+
+// NewCardinalityTracker creates an empty CardinalityTracker
+func NewCardinalityTracker() *CardinalityTracker {
+	return &CardinalityTracker{
+		targets: make(map[string]*targetCardinality),
+		now:     time.Now,
+		logf:    func(format string, args ...interface{}) { logutil.Printf("INFO", format, args...) },
+	}
+}
+
+// Cardinality is the tracker shared by the processor and the admin endpoint
+var Cardinality = NewCardinalityTracker()
+
+// Record counts the processed samples of one scrape of a target
+func (c *CardinalityTracker) Record(targetID string, metrics []*model.OpenMx) {
+	now := c.now()
+	start := now.Truncate(CardinalityWindow)
+
+	counts := make(map[string]int64)
+	hashes := make([]uint64, len(metrics))
+	for i, om := range metrics {
+		counts[om.Metric]++
+		hashes[i] = seriesHash(om)
+	}
+
+	c.mu.Lock()
+	tc, ok := c.targets[targetID]
+	if !ok {
+		tc = &targetCardinality{current: newCardinalityWindow(start)}
+		c.targets[targetID] = tc
+	}
+	if !tc.current.start.Equal(start) {
+		// Only a window directly before this one is a meaningful last minute
+		if tc.current.start.Add(CardinalityWindow).Equal(start) {
+			summary := tc.current.summary(targetID, false)
+			tc.last = &summary
+		} else {
+			tc.last = nil
+		}
+		tc.current = newCardinalityWindow(start)
+	}
+	tc.lastSeen = now
+	w := tc.current
+	w.scrapes++
+	w.samples += int64(len(metrics))
+	for _, hash := range hashes {
+		w.series.add(hash)
+	}
+	for name, n := range counts {
+		w.countMetric(name, n)
+	}
+
+	var heaviest []CardinalitySummary
+	if c.lastLog.IsZero() {
+		c.lastLog = now
+	} else if now.Sub(c.lastLog) >= CardinalityLogInterval {
+		c.lastLog = now
+		c.pruneLocked(now)
+		heaviest = c.heaviestLocked(CardinalityLogTargets)
+	}
+	c.mu.Unlock()
+
+	for i, s := range heaviest {
+		c.logf("[DIAGNOSTICS] Heaviest target #%d %s: %d samples/min, ~%d series, top metrics: %s",
+			i+1, s.TargetID, s.Samples, s.Series, formatTopMetrics(s.TopMetrics, CardinalityLogTargets))
+	}
+}
+
+// Summary returns the last complete minute of a target, or the current one while there is none yet,
+// and false if the target has not been processed recently
+func (c *CardinalityTracker) Summary(targetID string) (CardinalitySummary, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	tc, ok := c.targets[targetID]
+	if !ok || c.now().Sub(tc.lastSeen) > cardinalityTargetTTL {
+		return CardinalitySummary{}, false
+	}
+	return tc.summaryLocked(targetID, c.now()), true
+}
+
+func (tc *targetCardinality) summaryLocked(targetID string, now time.Time) CardinalitySummary {
+	if !tc.current.start.Equal(now.Truncate(CardinalityWindow)) {
+		// Nothing was processed in this minute yet, the current window is complete
+		return tc.current.summary(targetID, false)
+	}
+	if tc.last != nil {
+		return *tc.last
+	}
+	return tc.current.summary(targetID, true)
+}
+
+// Heaviest returns the n targets with the most samples per minute
+func (c *CardinalityTracker) Heaviest(n int) []CardinalitySummary {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pruneLocked(c.now())
+	return c.heaviestLocked(n)
+}
+
+func (c *CardinalityTracker) heaviestLocked(n int) []CardinalitySummary {
+	now := c.now()
+	summaries := make([]CardinalitySummary, 0, len(c.targets))
+	for targetID, tc := range c.targets {
+		summaries = append(summaries, tc.summaryLocked(targetID, now))
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].Samples != summaries[j].Samples {
+			return summaries[i].Samples > summaries[j].Samples
+		}
+		return summaries[i].TargetID < summaries[j].TargetID
+	})
+	if len(summaries) > n {
+		summaries = summaries[:n]
+	}
+	return summaries
+}
+
+// pruneLocked forgets targets that stopped being scraped
+func (c *CardinalityTracker) pruneLocked(now time.Time) {
+	for targetID, tc := range c.targets {
+		if now.Sub(tc.lastSeen) > cardinalityTargetTTL {
+			delete(c.targets, targetID)
+		}
+	}
+}
+
+func formatTopMetrics(top []MetricCount, n int) string {
+	if len(top) > n {
+		top = top[:n]
+	}
+	parts := make([]string, len(top))
+	for i, m := range top {
+		parts[i] = fmt.Sprintf("%s=%d", m.Metric, m.Samples)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// WriteText writes the summary in a plain text form, one metric name per line
+func (s CardinalitySummary) WriteText(w io.Writer) {
+	state := "complete"
+	if s.Partial {
+		state = "in progress"
+	}
+	fmt.Fprintf(w, "# target %s: minute starting %s (%s)\n", s.TargetID, s.Start.Format(time.RFC3339), state)
+	fmt.Fprintf(w, "scrapes %d\n", s.Scrapes)
+	fmt.Fprintf(w, "samples %d\n", s.Samples)
+	fmt.Fprintf(w, "series_estimate %d\n", s.Series)
+	fmt.Fprintf(w, "# top %d metric names by samples\n", CardinalityTopMetrics)
+	for _, m := range s.TopMetrics {
+		fmt.Fprintf(w, "%s %d\n", m.Metric, m.Samples)
+	}
+}
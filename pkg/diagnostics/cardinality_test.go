@@ -0,0 +1,149 @@
+package diagnostics
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"open-agent/pkg/model"
+)
+
+// cardinalityClock is a settable clock for the tracker
+type cardinalityClock struct{ now time.Time }
+
+func (c *cardinalityClock) Now() time.Time { return c.now }
+
+func newTestCardinality(start time.Time) (*CardinalityTracker, *cardinalityClock, *[]string) {
+	clock := &cardinalityClock{now: start}
+	var logs []string
+	c := NewCardinalityTracker()
+	c.now = clock.Now
+	c.logf = func(format string, args ...interface{}) { logs = append(logs, fmt.Sprintf(format, args...)) }
+	return c, clock, &logs
+}
+
+// skewedScrape returns heavy metric i with (10-i)*50 series, mixed with rare metrics that have one series each
+func skewedScrape(rng *rand.Rand, rare int) []*model.OpenMx {
+	var metrics []*model.OpenMx
+	for i := 0; i < 10; i++ {
+		for s := 0; s < (10-i)*50; s++ {
+			om := model.NewOpenMx(fmt.Sprintf("heavy_%d", i), 0, 1)
+			om.AddLabel("series", fmt.Sprint(s))
+			metrics = append(metrics, om)
+		}
+	}
+	for r := 0; r < rare; r++ {
+		metrics = append(metrics, model.NewOpenMx(fmt.Sprintf("rare_%d", r), 0, 1))
+	}
+	rng.Shuffle(len(metrics), func(i, j int) { metrics[i], metrics[j] = metrics[j], metrics[i] })
+	return metrics
+}
+
+func TestCardinality_TopMetricsOfSkewedTarget(t *testing.T) {
+	start := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	c, clock, _ := newTestCardinality(start)
+	rng := rand.New(rand.NewSource(1))
+
+	// Four scrapes in one minute, with more distinct names than the tracker keeps
+	for i := 0; i < 4; i++ {
+		clock.now = start.Add(time.Duration(i) * 15 * time.Second)
+		c.Record("app/a", skewedScrape(rng, 3*maxTrackedMetricNames))
+	}
+	if n := len(c.targets["app/a"].current.metrics); n > maxTrackedMetricNames {
+		t.Fatalf("expected at most %d tracked names, got %d", maxTrackedMetricNames, n)
+	}
+
+	clock.now = start.Add(CardinalityWindow + time.Second)
+	summary, ok := c.Summary("app/a")
+	if !ok || summary.Partial || !summary.Start.Equal(start) {
+		t.Fatalf("expected the complete first minute, got %+v", summary)
+	}
+	heavySamples := int64(0)
+	for i := 0; i < 10; i++ {
+		heavySamples += int64((10 - i) * 50 * 4)
+	}
+	if summary.Scrapes != 4 || summary.Samples != heavySamples+4*3*maxTrackedMetricNames {
+		t.Errorf("unexpected totals: %d scrapes, %d samples", summary.Scrapes, summary.Samples)
+	}
+
+	if len(summary.TopMetrics) != CardinalityTopMetrics {
+		t.Fatalf("expected %d top metrics, got %v", CardinalityTopMetrics, summary.TopMetrics)
+	}
+	for i, m := range summary.TopMetrics {
+		if m.Metric != fmt.Sprintf("heavy_%d", i) {
+			t.Fatalf("top metric #%d = %s, want heavy_%d (%v)", i, m.Metric, i, summary.TopMetrics)
+		}
+		// Space-saving may overcount a name by the samples of the names it replaced
+		want := int64((10 - i) * 50 * 4)
+		if m.Samples < want || m.Samples > want+int64(len(summary.TopMetrics))*4 {
+			t.Errorf("%s: %d samples, want about %d", m.Metric, m.Samples, want)
+		}
+	}
+
+	// Distinct series: 2750 heavy series plus 3000 rare names, the same every scrape
+	wantSeries := 2750 + 3*maxTrackedMetricNames
+	if diff := float64(summary.Series-int64(wantSeries)) / float64(wantSeries); diff > 0.06 || diff < -0.06 {
+		t.Errorf("series estimate %d, want about %d", summary.Series, wantSeries)
+	}
+}
+
+func TestCardinality_WindowsAndExpiry(t *testing.T) {
+	start := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	c, clock, _ := newTestCardinality(start)
+
+	c.Record("app/a", captureMetrics(5))
+	summary, _ := c.Summary("app/a")
+	if !summary.Partial || summary.Samples != 5 {
+		t.Fatalf("expected the first minute in progress, got %+v", summary)
+	}
+
+	// The next minute reports the previous one until it completes
+	clock.now = start.Add(CardinalityWindow)
+	c.Record("app/a", captureMetrics(8))
+	summary, _ = c.Summary("app/a")
+	if summary.Partial || summary.Samples != 5 || summary.Series != 5 {
+		t.Fatalf("expected the complete first minute, got %+v", summary)
+	}
+
+	clock.now = start.Add(CardinalityWindow + cardinalityTargetTTL + time.Second)
+	if _, ok := c.Summary("app/a"); ok {
+		t.Error("expected a target that stopped being scraped to expire")
+	}
+	if _, ok := c.Summary("app/unknown"); ok {
+		t.Error("expected no summary for an unknown target")
+	}
+}
+
+func TestCardinality_LogsHeaviestTargetsHourly(t *testing.T) {
+	start := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	c, clock, logs := newTestCardinality(start)
+
+	c.Record("app/gone", captureMetrics(1000))
+	clock.now = start.Add(CardinalityLogInterval - 5*time.Minute)
+	for i := 1; i <= 7; i++ {
+		c.Record(fmt.Sprintf("app/t%d", i), captureMetrics(i*10))
+	}
+	if len(*logs) != 0 {
+		t.Fatalf("expected nothing logged before an hour passed, got %v", *logs)
+	}
+
+	clock.now = start.Add(CardinalityLogInterval)
+	c.Record("app/t1", captureMetrics(10))
+	if len(*logs) != CardinalityLogTargets {
+		t.Fatalf("expected %d targets logged, got %v", CardinalityLogTargets, *logs)
+	}
+	// Heaviest first; app/gone stopped being scraped an hour ago and is not ranked
+	for i, log := range *logs {
+		want := fmt.Sprintf("#%d app/t%d: %d samples/min", i+1, 7-i, (7-i)*10)
+		if !strings.Contains(log, want) {
+			t.Errorf("log %d = %q, want %q", i, log, want)
+		}
+	}
+
+	c.Record("app/t1", captureMetrics(10))
+	if len(*logs) != CardinalityLogTargets {
+		t.Errorf("expected the next log only after another hour, got %d lines", len(*logs))
+	}
+}
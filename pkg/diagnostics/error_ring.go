@@ -0,0 +1,50 @@
+package diagnostics
+
+import (
+	"sync"
+	"time"
+)
+
+// ErrorRingSize is the number of scrape errors kept for crash dumps
+const ErrorRingSize = 100
+
+// ScrapeError is one failed scrape recorded in an ErrorRing
+type ScrapeError struct {
+	Time     time.Time
+	TargetID string
+	Err      string
+}
+
+// ErrorRing keeps the most recent scrape errors in a fixed-size array.
+// Snapshot copies into a caller-provided array, so a crash handler can read it without allocating.
+type ErrorRing struct {
+	mu      sync.Mutex
+	entries [ErrorRingSize]ScrapeError
+	next    int
+	count   int
+}
+
+// Add records a scrape error, overwriting the oldest one when the ring is full
+func (r *ErrorRing) Add(targetID string, err error) {
+	entry := ScrapeError{Time: time.Now(), TargetID: targetID, Err: err.Error()}
+
+	r.mu.Lock()
+	r.entries[r.next] = entry
+	r.next = (r.next + 1) % ErrorRingSize
+	if r.count < ErrorRingSize {
+		r.count++
+	}
+	r.mu.Unlock()
+}
+
+// Snapshot copies the recorded errors, oldest first, into dst and returns how many were copied
+func (r *ErrorRing) Snapshot(dst *[ErrorRingSize]ScrapeError) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	start := (r.next - r.count + ErrorRingSize) % ErrorRingSize
+	for i := 0; i < r.count; i++ {
+		dst[i] = r.entries[(start+i)%ErrorRingSize]
+	}
+	return r.count
+}
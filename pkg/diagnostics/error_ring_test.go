@@ -0,0 +1,40 @@
+package diagnostics
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestErrorRing_KeepsMostRecent(t *testing.T) {
+	var r ErrorRing
+	for i := 0; i < ErrorRingSize+5; i++ {
+		r.Add(fmt.Sprintf("target-%d", i), errors.New("connection refused"))
+	}
+
+	var dst [ErrorRingSize]ScrapeError
+	n := r.Snapshot(&dst)
+	if n != ErrorRingSize {
+		t.Fatalf("expected %d errors, got %d", ErrorRingSize, n)
+	}
+	if dst[0].TargetID != "target-5" || dst[n-1].TargetID != fmt.Sprintf("target-%d", ErrorRingSize+4) {
+		t.Fatalf("expected oldest-first order, got %s .. %s", dst[0].TargetID, dst[n-1].TargetID)
+	}
+
+	allocs := testing.AllocsPerRun(10, func() { r.Snapshot(&dst) })
+	if allocs != 0 {
+		t.Errorf("Snapshot must not allocate, got %v allocs", allocs)
+	}
+}
+
+func TestHeartbeatAge(t *testing.T) {
+	if _, ok := HeartbeatAge(ComponentSender, time.Now()); ok {
+		t.Fatalf("expected no heartbeat before the first Beat")
+	}
+	Beat(ComponentSender)
+	age, ok := HeartbeatAge(ComponentSender, time.Now().Add(time.Minute))
+	if !ok || age < time.Minute {
+		t.Fatalf("expected an age of at least 1m, got %v (%v)", age, ok)
+	}
+}
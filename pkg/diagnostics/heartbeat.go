@@ -0,0 +1,43 @@
+package diagnostics
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Component identifies a pipeline stage that reports heartbeats
+type Component int
+
+const (
+	ComponentDiscovery Component = iota // a discovery cycle finished
+	ComponentScraper                    // a scrape finished and its data was queued
+	ComponentProcessor                  // raw data was processed
+	ComponentSender                     // a result was packed or a pack was sent
+	numComponents
+)
+
+var componentNames = [numComponents]string{"discovery", "scraper", "processor", "sender"}
+
+func (c Component) String() string {
+	return componentNames[c]
+}
+
+// Components lists every component in report order
+var Components = [numComponents]Component{ComponentDiscovery, ComponentScraper, ComponentProcessor, ComponentSender}
+
+// heartbeats holds the last heartbeat of each component in Unix nanoseconds
+var heartbeats [numComponents]atomic.Int64
+
+// Beat records that the component made progress
+func Beat(c Component) {
+	heartbeats[c].Store(clock().UnixNano())
+}
+
+// HeartbeatAge returns how long ago the component last made progress, and false if it never has
+func HeartbeatAge(c Component, now time.Time) (time.Duration, bool) {
+	last := heartbeats[c].Load()
+	if last == 0 {
+		return 0, false
+	}
+	return now.Sub(time.Unix(0, last)), true
+}
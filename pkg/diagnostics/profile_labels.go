@@ -0,0 +1,23 @@
+package diagnostics
+
+import (
+	"context"
+	"runtime/pprof"
+)
+
+// Profile label keys, e.g. go tool pprof -tagfocus=target=app/default/pod-a/8080-metrics
+const (
+	LabelComponent = "component"
+	LabelTarget    = "target"
+)
+
+// Profile runs f with the pprof labels component=<c> and, when target is set, target=<target>, so CPU
+// and goroutine profiles group samples by pipeline stage and target. Goroutines started by f inherit
+// the labels.
+func Profile(c Component, target string, f func()) {
+	labels := pprof.Labels(LabelComponent, c.String())
+	if target != "" {
+		labels = pprof.Labels(LabelComponent, c.String(), LabelTarget, target)
+	}
+	pprof.Do(context.Background(), labels, func(context.Context) { f() })
+}
@@ -0,0 +1,44 @@
+package diagnostics
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// clock is the time source of heartbeats and work progress, replaced in tests
+var clock = time.Now
+
+// workStart holds when each component's current long-running operation started in Unix nanoseconds, 0 when idle
+var workStart [numComponents]atomic.Int64
+
+// workProgress holds the units (e.g. lines parsed) completed by the current operation
+var workProgress [numComponents]atomic.Int64
+
+// BeginWork records that the component started an operation that may run for a long time,
+// such as parsing a large scrape body
+func BeginWork(c Component) {
+	workProgress[c].Store(0)
+	workStart[c].Store(clock().UnixNano())
+}
+
+// Progress records that the current operation completed done units and counts as a heartbeat,
+// so a component busy with one large operation is not mistaken for a hung one
+func Progress(c Component, done int64) {
+	workProgress[c].Store(done)
+	Beat(c)
+}
+
+// EndWork records that the component's current operation finished
+func EndWork(c Component) {
+	workStart[c].Store(0)
+}
+
+// Busy returns how long the component's current operation has been running and its progress,
+// and false if the component is idle
+func Busy(c Component, now time.Time) (time.Duration, int64, bool) {
+	start := workStart[c].Load()
+	if start == 0 {
+		return 0, 0, false
+	}
+	return now.Sub(time.Unix(0, start)), workProgress[c].Load(), true
+}
@@ -0,0 +1,57 @@
+package diagnostics
+
+import (
+	"testing"
+	"time"
+)
+
+// keepAliveTimeout is the silence after which a watchdog would consider a component hung
+const keepAliveTimeout = 3 * time.Minute
+
+func TestProgress_LongParseStaysAlive(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+	clock = func() time.Time { return now }
+	defer func() { clock = time.Now }()
+
+	// A 4-minute parse reporting progress every 10 seconds
+	BeginWork(ComponentProcessor)
+	for lines := int64(100000); now.Sub(start) < 4*time.Minute; lines += 100000 {
+		now = now.Add(10 * time.Second)
+		Progress(ComponentProcessor, lines)
+
+		age, ok := HeartbeatAge(ComponentProcessor, now)
+		if !ok || age >= keepAliveTimeout {
+			t.Fatalf("at %v: heartbeat age %v exceeds the keep-alive timeout", now.Sub(start), age)
+		}
+		busy, done, ok := Busy(ComponentProcessor, now)
+		if !ok || busy != now.Sub(start) || done != lines {
+			t.Fatalf("at %v: Busy() = %v, %d, %v", now.Sub(start), busy, done, ok)
+		}
+	}
+
+	EndWork(ComponentProcessor)
+	if _, _, ok := Busy(ComponentProcessor, now); ok {
+		t.Error("expected the processor to be idle after EndWork")
+	}
+}
+
+func TestProgress_HungParseGoesSilent(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+	clock = func() time.Time { return now }
+	defer func() { clock = time.Now }()
+
+	// A parse that reports once and then makes no further progress
+	BeginWork(ComponentProcessor)
+	Progress(ComponentProcessor, 100000)
+	defer EndWork(ComponentProcessor)
+
+	now = now.Add(4 * time.Minute)
+	if age, _ := HeartbeatAge(ComponentProcessor, now); age < keepAliveTimeout {
+		t.Errorf("expected a stalled parse to exceed the keep-alive timeout, heartbeat age %v", age)
+	}
+	if _, done, ok := Busy(ComponentProcessor, now); !ok || done != 100000 {
+		t.Errorf("expected a busy processor stuck at 100000 lines, got %d, %v", done, ok)
+	}
+}
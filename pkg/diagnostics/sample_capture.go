@@ -0,0 +1,220 @@
+package diagnostics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"open-agent/pkg/model"
+	"open-agent/tools/util/logutil"
+)
+
+const (
+	// DefaultCaptureSamples is how many samples of each scrape are captured unless the request sets samples
+	DefaultCaptureSamples = 100
+	// MaxCaptureSamples bounds the samples captured per scrape
+	MaxCaptureSamples = 1000
+	// DefaultCaptureDuration is how long a capture runs unless the request sets duration
+	DefaultCaptureDuration = 5 * time.Minute
+	// MaxCaptureDuration bounds how long a capture runs
+	MaxCaptureDuration = time.Hour
+	// CapturedScrapes is the number of most recent scrapes kept per target
+	CapturedScrapes = 10
+)
+
+// CapturedScrape is the first samples of one processed scrape, in exposition format
+type CapturedScrape struct {
+	Time    time.Time
+	Total   int // samples the scrape produced after relabeling
+	Samples []string
+}
+
+// CaptureStatus describes the capture of one target
+type CaptureStatus struct {
+	TargetID string
+	Samples  int // samples captured per scrape
+	Until    time.Time
+	Active   bool
+	Scrapes  []CapturedScrape // oldest first
+}
+
+// targetCapture keeps the last CapturedScrapes scrapes of a target in a ring
+type targetCapture struct {
+	samples int
+	until   time.Time
+	active  bool
+	scrapes [CapturedScrapes]CapturedScrape
+	next    int
+	count   int
+}
+
+// SampleCapture records the first processed samples of each scrape for targets being debugged.
+// A capture stops by itself once its duration has passed; the captured scrapes stay readable
+// until the capture is restarted or deleted.
+type SampleCapture struct {
+	mu      sync.Mutex
+	targets map[string]*targetCapture
+	now     func() time.Time
+}
+
+// NewSampleCapture creates an empty SampleCapture
+func NewSampleCapture() *SampleCapture {
+	return &SampleCapture{targets: make(map[string]*targetCapture), now: time.Now}
+}
+
+// Samples is the capture shared by the processor and the admin endpoint
+var Samples = NewSampleCapture()
+
+// Enable starts capturing up to samples samples per scrape of a target for duration,
+// discarding earlier captures of the target. It returns when the capture stops.
+func (c *SampleCapture) Enable(targetID string, samples int, duration time.Duration) time.Time {
+	if samples <= 0 {
+		samples = DefaultCaptureSamples
+	}
+	if samples > MaxCaptureSamples {
+		samples = MaxCaptureSamples
+	}
+	if duration <= 0 {
+		duration = DefaultCaptureDuration
+	}
+	if duration > MaxCaptureDuration {
+		duration = MaxCaptureDuration
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	until := c.now().Add(duration)
+	c.targets[targetID] = &targetCapture{samples: samples, until: until, active: true}
+	return until
+}
+
+// Forget removes the capture of a target and reports whether there was one
+func (c *SampleCapture) Forget(targetID string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.targets[targetID]; !ok {
+		return false
+	}
+	delete(c.targets, targetID)
+	return true
+}
+
+// Active returns how many samples to capture for a target, and false when it is not being captured
+func (c *SampleCapture) Active(targetID string) (int, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	tc := c.activeLocked(targetID)
+	if tc == nil {
+		return 0, false
+	}
+	return tc.samples, true
+}
+
+// activeLocked returns the target's capture while it is running, stopping it once it has expired
+func (c *SampleCapture) activeLocked(targetID string) *targetCapture {
+	tc, ok := c.targets[targetID]
+	if !ok || !tc.active {
+		return nil
+	}
+	if !c.now().Before(tc.until) {
+		tc.active = false
+		logutil.Printf("INFO", "[DIAGNOSTICS] Sample capture of target %s expired", targetID)
+		return nil
+	}
+	return tc
+}
+
+// Record captures the first samples of a processed scrape. It does nothing when the target is not being captured.
+func (c *SampleCapture) Record(targetID string, metrics []*model.OpenMx) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	tc := c.activeLocked(targetID)
+	if tc == nil {
+		return
+	}
+
+	n := len(metrics)
+	if n > tc.samples {
+		n = tc.samples
+	}
+	scrape := CapturedScrape{Time: c.now(), Total: len(metrics), Samples: make([]string, 0, n)}
+	for _, om := range metrics[:n] {
+		scrape.Samples = append(scrape.Samples, formatSample(om))
+	}
+
+	tc.scrapes[tc.next] = scrape
+	tc.next = (tc.next + 1) % CapturedScrapes
+	if tc.count < CapturedScrapes {
+		tc.count++
+	}
+}
+
+// Status returns the capture of a target, and false if it has none
+func (c *SampleCapture) Status(targetID string) (CaptureStatus, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	tc, ok := c.targets[targetID]
+	if !ok {
+		return CaptureStatus{}, false
+	}
+	status := CaptureStatus{
+		TargetID: targetID,
+		Samples:  tc.samples,
+		Until:    tc.until,
+		Active:   c.activeLocked(targetID) != nil,
+		Scrapes:  make([]CapturedScrape, 0, tc.count),
+	}
+	start := (tc.next - tc.count + CapturedScrapes) % CapturedScrapes
+	for i := 0; i < tc.count; i++ {
+		status.Scrapes = append(status.Scrapes, tc.scrapes[(start+i)%CapturedScrapes])
+	}
+	return status, true
+}
+
+// WriteText writes the status in a plain text form, one sample per line
+func (s CaptureStatus) WriteText(w io.Writer) {
+	if s.Active {
+		fmt.Fprintf(w, "# target %s: capturing %d samples per scrape until %s\n", s.TargetID, s.Samples, s.Until.Format(time.RFC3339))
+	} else {
+		fmt.Fprintf(w, "# target %s: capture expired at %s\n", s.TargetID, s.Until.Format(time.RFC3339))
+	}
+	if len(s.Scrapes) == 0 {
+		io.WriteString(w, "# no scrapes captured yet\n")
+	}
+	for _, scrape := range s.Scrapes {
+		fmt.Fprintf(w, "# scrape at %s: %d of %d samples\n", scrape.Time.Format(time.RFC3339), len(scrape.Samples), scrape.Total)
+		for _, sample := range scrape.Samples {
+			io.WriteString(w, sample+"\n")
+		}
+	}
+}
+
+// formatSample formats a sample like the text exposition format, with labels sorted by name
+func formatSample(om *model.OpenMx) string {
+	labels := append([]model.Label(nil), om.Labels...)
+	sort.SliceStable(labels, func(i, j int) bool { return labels[i].Key < labels[j].Key })
+
+	var b strings.Builder
+	b.WriteString(om.Metric)
+	if len(labels) > 0 {
+		b.WriteByte('{')
+		for i, label := range labels {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(label.Key)
+			b.WriteByte('=')
+			b.WriteString(strconv.Quote(label.Value))
+		}
+		b.WriteByte('}')
+	}
+	b.WriteByte(' ')
+	b.WriteString(strconv.FormatFloat(om.Value, 'g', -1, 64))
+	b.WriteByte(' ')
+	b.WriteString(strconv.FormatInt(om.Timestamp, 10))
+	return b.String()
+}
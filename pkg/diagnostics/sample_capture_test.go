@@ -0,0 +1,112 @@
+package diagnostics
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"open-agent/pkg/model"
+)
+
+func captureMetrics(n int) []*model.OpenMx {
+	metrics := make([]*model.OpenMx, 0, n)
+	for i := 0; i < n; i++ {
+		om := model.NewOpenMx("http_requests_total", 1700000000000, float64(i))
+		om.AddLabel("pod", "api-0")
+		om.AddLabel("code", fmt.Sprint(200+i))
+		metrics = append(metrics, om)
+	}
+	return metrics
+}
+
+func TestSampleCapture_CapturesFirstSamples(t *testing.T) {
+	c := NewSampleCapture()
+	if _, ok := c.Active("app/a"); ok {
+		t.Fatal("capture must be off until enabled")
+	}
+	c.Record("app/a", captureMetrics(3))
+	if _, ok := c.Status("app/a"); ok {
+		t.Fatal("scrapes of targets without a capture must not be recorded")
+	}
+
+	c.Enable("app/a", 2, time.Minute)
+	c.Record("app/a", captureMetrics(5))
+
+	status, ok := c.Status("app/a")
+	if !ok || !status.Active || len(status.Scrapes) != 1 {
+		t.Fatalf("unexpected status %+v", status)
+	}
+	scrape := status.Scrapes[0]
+	if scrape.Total != 5 || len(scrape.Samples) != 2 {
+		t.Fatalf("expected the first 2 of 5 samples, got %d of %d", len(scrape.Samples), scrape.Total)
+	}
+	if want := `http_requests_total{code="200",pod="api-0"} 0 1700000000000`; scrape.Samples[0] != want {
+		t.Errorf("expected %q, got %q", want, scrape.Samples[0])
+	}
+}
+
+func TestSampleCapture_KeepsRecentScrapes(t *testing.T) {
+	c := NewSampleCapture()
+	c.Enable("app/a", 1, time.Minute)
+	for i := 0; i < CapturedScrapes+3; i++ {
+		c.Record("app/a", captureMetrics(i+1))
+	}
+	status, _ := c.Status("app/a")
+	if len(status.Scrapes) != CapturedScrapes {
+		t.Fatalf("expected %d scrapes, got %d", CapturedScrapes, len(status.Scrapes))
+	}
+	if status.Scrapes[0].Total != 4 || status.Scrapes[CapturedScrapes-1].Total != CapturedScrapes+3 {
+		t.Errorf("expected the most recent scrapes oldest first, got totals %d .. %d",
+			status.Scrapes[0].Total, status.Scrapes[CapturedScrapes-1].Total)
+	}
+}
+
+func TestSampleCapture_ExpiresAfterDuration(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewSampleCapture()
+	c.now = func() time.Time { return now }
+
+	until := c.Enable("app/a", 10, 5*time.Minute)
+	if !until.Equal(now.Add(5 * time.Minute)) {
+		t.Fatalf("unexpected end of capture %v", until)
+	}
+	c.Record("app/a", captureMetrics(1))
+
+	now = now.Add(5 * time.Minute)
+	if _, ok := c.Active("app/a"); ok {
+		t.Fatal("capture must stop once the duration has passed")
+	}
+	c.Record("app/a", captureMetrics(1))
+
+	status, ok := c.Status("app/a")
+	if !ok || status.Active || len(status.Scrapes) != 1 {
+		t.Fatalf("expected the earlier scrape to stay readable after expiry, got %+v", status)
+	}
+	var text strings.Builder
+	status.WriteText(&text)
+	if !strings.Contains(text.String(), "capture expired") {
+		t.Errorf("expected the text to report the expiry, got %q", text.String())
+	}
+
+	if !c.Forget("app/a") || c.Forget("app/a") {
+		t.Error("Forget should remove the capture once")
+	}
+}
+
+func TestSampleCapture_Limits(t *testing.T) {
+	now := time.Now()
+	c := NewSampleCapture()
+	c.now = func() time.Time { return now }
+
+	if until := c.Enable("app/a", MaxCaptureSamples+1, 24*time.Hour); !until.Equal(now.Add(MaxCaptureDuration)) {
+		t.Errorf("duration should be capped at %v, got %v", MaxCaptureDuration, until.Sub(now))
+	}
+	if samples, _ := c.Active("app/a"); samples != MaxCaptureSamples {
+		t.Errorf("samples should be capped at %d, got %d", MaxCaptureSamples, samples)
+	}
+	c.Enable("app/a", 0, 0)
+	if samples, _ := c.Active("app/a"); samples != DefaultCaptureSamples {
+		t.Errorf("expected default samples %d, got %d", DefaultCaptureSamples, samples)
+	}
+}
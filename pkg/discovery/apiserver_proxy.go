@@ -0,0 +1,47 @@
+package discovery
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ScrapeViaAPIServer is the scrape_via label value of targets scraped through the apiserver proxy
+const ScrapeViaAPIServer = "apiserver"
+
+// apiServerProxyURL rewrites a pod scrape URL to go through the kube-apiserver pod proxy:
+//
+//	http://10.0.3.17:8080/metrics -> https://<apiserver>/api/v1/namespaces/<ns>/pods/<pod>:8080/proxy/metrics
+//
+// The apiserver connects to the pod, so it only needs to be reachable from the control plane.
+// The agent's service account needs the get verb on pods/proxy in the scraped namespaces:
+//
+//	- apiGroups: [""]
+//	  resources: ["pods/proxy"]
+//	  verbs: ["get"]
+func apiServerProxyURL(apiServerHost, namespace, podName, podURL string) (string, error) {
+	if apiServerHost == "" {
+		return "", fmt.Errorf("apiserver host is unknown")
+	}
+	u, err := url.Parse(podURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid scrape URL %q: %v", podURL, err)
+	}
+	port := u.Port()
+	if port == "" {
+		return "", fmt.Errorf("scrape URL %q has no port", podURL)
+	}
+
+	// The proxy subresource takes [scheme:]name:port; http is the default scheme
+	pod := podName + ":" + port
+	if u.Scheme == "https" {
+		pod = "https:" + pod
+	}
+
+	proxy := strings.TrimSuffix(apiServerHost, "/") +
+		fmt.Sprintf("/api/v1/namespaces/%s/pods/%s/proxy", url.PathEscape(namespace), url.PathEscape(pod)) + u.EscapedPath()
+	if u.RawQuery != "" {
+		proxy += "?" + u.RawQuery
+	}
+	return proxy, nil
+}
@@ -0,0 +1,27 @@
+package discovery
+
+import "testing"
+
+func TestAPIServerProxyURL(t *testing.T) {
+	tests := []struct {
+		podURL string
+		want   string
+	}{
+		{"http://10.0.3.17:8080/metrics", "https://10.96.0.1:443/api/v1/namespaces/team-a/pods/api-0:8080/proxy/metrics"},
+		{"https://10.0.3.17:8443/metrics", "https://10.96.0.1:443/api/v1/namespaces/team-a/pods/https:api-0:8443/proxy/metrics"},
+		{"http://10.0.3.17:9115/probe?module=http_2xx", "https://10.96.0.1:443/api/v1/namespaces/team-a/pods/api-0:9115/proxy/probe?module=http_2xx"},
+	}
+	for _, tt := range tests {
+		got, err := apiServerProxyURL("https://10.96.0.1:443/", "team-a", "api-0", tt.podURL)
+		if err != nil || got != tt.want {
+			t.Errorf("%s: expected %s, got %s (%v)", tt.podURL, tt.want, got, err)
+		}
+	}
+
+	if _, err := apiServerProxyURL("", "team-a", "api-0", "http://10.0.3.17:8080/metrics"); err == nil {
+		t.Error("expected an error without an apiserver host")
+	}
+	if _, err := apiServerProxyURL("https://10.96.0.1:443", "team-a", "api-0", "http://10.0.3.17/metrics"); err == nil {
+		t.Error("expected an error for a URL without a port")
+	}
+}
@@ -0,0 +1,93 @@
+package discovery
+
+import (
+	"sync"
+	"time"
+
+	"open-agent/pkg/k8s"
+	"open-agent/tools/util/logutil"
+)
+
+const (
+	// ClusterIDLabel holds the kube-system namespace UID with autoClusterLabel
+	ClusterIDLabel = "cluster_id"
+	// ClusterLabel holds the name clusterNames maps the cluster's UID to
+	ClusterLabel = "cluster"
+
+	// clusterUIDRetryInterval is how long a failed cluster UID lookup is kept before it is tried again
+	clusterUIDRetryInterval = 30 * time.Second
+)
+
+// clusterLabels are the labels autoClusterLabel adds to every target. The cluster UID is read from the
+// namespace informer and, once read, cached for the agent's lifetime; the settings are reloaded with the config.
+type clusterLabels struct {
+	enabled bool
+	// names maps kube-system namespace UIDs to cluster names
+	names map[string]string
+
+	mu  sync.Mutex
+	uid string
+	// lastAttempt and lastErr are of the last failed lookup, retried after clusterUIDRetryInterval
+	lastAttempt time.Time
+	lastErr     string
+}
+
+// loadClusterLabels reads autoClusterLabel and clusterNames from the scrape configuration
+func (sd *ServiceDiscoveryImpl) loadClusterLabels() {
+	if sd.configManager == nil {
+		return
+	}
+	sd.cluster.enabled = sd.configManager.AutoClusterLabel()
+	sd.cluster.names = sd.configManager.ClusterNames()
+}
+
+// clusterUID returns the cached kube-system namespace UID, reading it until a read succeeds. It is "" in
+// standalone mode, which is not logged, and while the namespace cannot be read, e.g. before the informer
+// has synced; the lookup is then retried every clusterUIDRetryInterval and each new error is logged.
+func (sd *ServiceDiscoveryImpl) clusterUID() string {
+	cluster := &sd.cluster
+	cluster.mu.Lock()
+	defer cluster.mu.Unlock()
+	if cluster.uid != "" {
+		return cluster.uid
+	}
+	now := time.Now()
+	if !cluster.lastAttempt.IsZero() && now.Sub(cluster.lastAttempt) < clusterUIDRetryInterval {
+		return ""
+	}
+
+	uid, err := k8s.ClusterUID(sd.k8sClient)
+	if err != nil {
+		cluster.lastAttempt = now
+		if err.Error() != cluster.lastErr {
+			cluster.lastErr = err.Error()
+			logutil.Printf("WARN", "[DISCOVERY] autoClusterLabel: cannot read the cluster UID, targets get no %s label until it can be read: %v", ClusterIDLabel, err)
+		}
+		return ""
+	}
+	if uid != "" {
+		logutil.Printf("DISCOVERY", "autoClusterLabel: cluster UID %s", uid)
+	}
+	cluster.uid = uid
+	return uid
+}
+
+// applyClusterLabels adds cluster_id, and cluster when clusterNames maps the UID, to the labels of a
+// target. Labels set by relabeling or labelTemplates are kept.
+func (sd *ServiceDiscoveryImpl) applyClusterLabels(labels map[string]string) {
+	if !sd.cluster.enabled {
+		return
+	}
+	uid := sd.clusterUID()
+	if uid == "" {
+		return
+	}
+	if _, ok := labels[ClusterIDLabel]; !ok {
+		labels[ClusterIDLabel] = uid
+	}
+	if name := sd.cluster.names[uid]; name != "" {
+		if _, ok := labels[ClusterLabel]; !ok {
+			labels[ClusterLabel] = name
+		}
+	}
+}
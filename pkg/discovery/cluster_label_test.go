@@ -0,0 +1,111 @@
+package discovery
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"open-agent/pkg/k8s"
+)
+
+const testClusterUID = "5a3c1e0e-9d1f-4b7a-8c55-0f1e2d3c4b5a"
+
+func clusterProvider() *fakeProvider {
+	return &fakeProvider{
+		pods: map[string][]*corev1.Pod{
+			"default": {labeledPod("api-0", map[string]string{"app": "api"}), labeledPod("api-1", map[string]string{"app": "api"})},
+		},
+		namespaces: []*corev1.Namespace{
+			{ObjectMeta: metav1.ObjectMeta{Name: "default", UID: "0b0b0b0b"}},
+			{ObjectMeta: metav1.ObjectMeta{Name: k8s.ClusterUIDNamespace, UID: testClusterUID}},
+		},
+	}
+}
+
+func TestAutoClusterLabel_AddsCachedClusterID(t *testing.T) {
+	provider := clusterProvider()
+	sd := &ServiceDiscoveryImpl{k8sClient: provider, targets: make(map[string]*Target), cluster: clusterLabels{enabled: true}}
+
+	for i := 0; i < 3; i++ {
+		sd.discoverPodTargets(newSelectorPodConfig(), make(map[string]bool))
+	}
+	if len(sd.targets) != 2 {
+		t.Fatalf("expected 2 targets, got %d", len(sd.targets))
+	}
+	for _, target := range sd.targets {
+		if target.Labels[ClusterIDLabel] != testClusterUID {
+			t.Errorf("target %s: cluster_id = %q, want %q", target.ID, target.Labels[ClusterIDLabel], testClusterUID)
+		}
+		if _, ok := target.Labels[ClusterLabel]; ok {
+			t.Errorf("target %s: unexpected cluster label without a clusterNames entry", target.ID)
+		}
+	}
+	if provider.namespaceLookups != 1 {
+		t.Errorf("the cluster UID was read %d times, want once", provider.namespaceLookups)
+	}
+}
+
+func TestAutoClusterLabel_RetriesFailedLookup(t *testing.T) {
+	provider := clusterProvider()
+	kubeSystem := provider.namespaces[1]
+	// The namespace informer has not synced kube-system yet
+	provider.namespaces = provider.namespaces[:1]
+	sd := &ServiceDiscoveryImpl{k8sClient: provider, cluster: clusterLabels{enabled: true}}
+
+	labels := map[string]string{}
+	sd.applyClusterLabels(labels)
+	sd.applyClusterLabels(labels)
+	if len(labels) != 0 || provider.namespaceLookups != 1 {
+		t.Fatalf("labels = %v after %d lookups, want none after one lookup within the retry interval", labels, provider.namespaceLookups)
+	}
+
+	provider.namespaces = append(provider.namespaces, kubeSystem)
+	sd.cluster.lastAttempt = sd.cluster.lastAttempt.Add(-clusterUIDRetryInterval)
+	sd.applyClusterLabels(labels)
+	sd.applyClusterLabels(labels)
+	if labels[ClusterIDLabel] != testClusterUID || provider.namespaceLookups != 2 {
+		t.Errorf("labels = %v after %d lookups, want cluster_id from the retried lookup, then cached", labels, provider.namespaceLookups)
+	}
+}
+
+func TestAutoClusterLabel_MappedName(t *testing.T) {
+	sd := &ServiceDiscoveryImpl{k8sClient: clusterProvider(), targets: make(map[string]*Target), cluster: clusterLabels{
+		enabled: true,
+		names:   map[string]string{testClusterUID: "prod-seoul", "other-uid": "staging"},
+	}}
+	sd.discoverPodTargets(newSelectorPodConfig(), make(map[string]bool))
+	for _, target := range sd.targets {
+		if target.Labels[ClusterIDLabel] != testClusterUID || target.Labels[ClusterLabel] != "prod-seoul" {
+			t.Errorf("target %s: labels %v, want cluster_id %s and cluster prod-seoul", target.ID, target.Labels, testClusterUID)
+		}
+	}
+}
+
+func TestAutoClusterLabel_KeepsExistingLabels(t *testing.T) {
+	sd := &ServiceDiscoveryImpl{k8sClient: clusterProvider(), cluster: clusterLabels{
+		enabled: true,
+		names:   map[string]string{testClusterUID: "prod-seoul"},
+	}}
+	labels := map[string]string{ClusterLabel: "from-relabel"}
+	sd.applyClusterLabels(labels)
+	if labels[ClusterLabel] != "from-relabel" || labels[ClusterIDLabel] != testClusterUID {
+		t.Errorf("labels = %v, want the relabeled cluster kept and cluster_id added", labels)
+	}
+}
+
+func TestAutoClusterLabel_DisabledAndStandalone(t *testing.T) {
+	provider := clusterProvider()
+	disabled := &ServiceDiscoveryImpl{k8sClient: provider}
+	labels := map[string]string{}
+	disabled.applyClusterLabels(labels)
+	if len(labels) != 0 || provider.namespaceLookups != 0 {
+		t.Errorf("disabled: labels = %v after %d lookups, want none", labels, provider.namespaceLookups)
+	}
+
+	standalone := &ServiceDiscoveryImpl{k8sClient: k8s.NewProvider(true), cluster: clusterLabels{enabled: true}}
+	standalone.applyClusterLabels(labels)
+	if len(labels) != 0 {
+		t.Errorf("standalone: labels = %v, want none", labels)
+	}
+}
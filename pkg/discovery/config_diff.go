@@ -0,0 +1,134 @@
+package discovery
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"open-agent/tools/util/logutil"
+)
+
+// discoveryCycle is the effective configuration and discovered targets of one discovery cycle
+type discoveryCycle struct {
+	fingerprints map[string]string          // targetName -> raw config fingerprint
+	targets      map[string]map[string]bool // targetName -> discovered target IDs
+}
+
+func newDiscoveryCycle() *discoveryCycle {
+	return &discoveryCycle{
+		fingerprints: make(map[string]string),
+		targets:      make(map[string]map[string]bool),
+	}
+}
+
+// add records a target config and the target IDs it produced in this cycle
+func (c *discoveryCycle) add(targetName string, rawConfig map[string]interface{}, targetIDs map[string]bool) {
+	c.fingerprints[targetName] = configFingerprint(rawConfig)
+	c.targets[targetName] = targetIDs
+}
+
+// configFingerprint identifies a raw target config.
+// fmt prints maps with sorted keys, so equal configs give equal fingerprints.
+func configFingerprint(rawConfig map[string]interface{}) string {
+	return fmt.Sprintf("%v", rawConfig)
+}
+
+// configChanged reports whether the effective configuration differs from the previous cycle
+func (c *discoveryCycle) configChanged(prev *discoveryCycle) bool {
+	if len(c.fingerprints) != len(prev.fingerprints) {
+		return true
+	}
+	for name, fingerprint := range c.fingerprints {
+		if prev.fingerprints[name] != fingerprint {
+			return true
+		}
+	}
+	return false
+}
+
+// cycleChange is one line of the config reload diff
+type cycleChange struct {
+	Level   string
+	Message string
+}
+
+// diffCycles describes how the configuration and the resulting target set changed between two cycles.
+// Targets whose match count drops to zero are reported at WARN since that usually means a selector typo.
+func diffCycles(prev, curr *discoveryCycle) []cycleChange {
+	names := make(map[string]bool)
+	for name := range prev.fingerprints {
+		names[name] = true
+	}
+	for name := range curr.fingerprints {
+		names[name] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	var changes []cycleChange
+	for _, name := range sorted {
+		prevFingerprint, inPrev := prev.fingerprints[name]
+		currFingerprint, inCurr := curr.fingerprints[name]
+		prevIDs, currIDs := prev.targets[name], curr.targets[name]
+
+		switch {
+		case !inCurr:
+			changes = append(changes, cycleChange{"INFO", fmt.Sprintf("target %s: removed from config (-%d targets)", name, len(prevIDs))})
+		case !inPrev:
+			if len(currIDs) == 0 {
+				changes = append(changes, cycleChange{"WARN", fmt.Sprintf("target %s: added, selector matches 0 targets", name)})
+			} else {
+				changes = append(changes, cycleChange{"INFO", fmt.Sprintf("target %s: added (+%d targets)", name, len(currIDs))})
+			}
+		default:
+			added, removed := countSetDiff(currIDs, prevIDs), countSetDiff(prevIDs, currIDs)
+			modified := ""
+			if prevFingerprint != currFingerprint {
+				modified = "config modified, "
+			}
+			if len(currIDs) == 0 && len(prevIDs) > 0 {
+				changes = append(changes, cycleChange{"WARN", fmt.Sprintf("target %s: %sselector matches 0 targets (was %d)", name, modified, len(prevIDs))})
+			} else if modified != "" || added > 0 || removed > 0 {
+				changes = append(changes, cycleChange{"INFO", fmt.Sprintf("target %s: %s+%d targets, -%d (now %d)", name, modified, added, removed, len(currIDs))})
+			}
+		}
+	}
+	return changes
+}
+
+// logConfigDiff logs the diff against the previous cycle when the configuration was reloaded
+func (sd *ServiceDiscoveryImpl) logConfigDiff(curr *discoveryCycle) {
+	prev := sd.lastCycle
+	sd.lastCycle = curr
+	if prev == nil || !curr.configChanged(prev) {
+		return
+	}
+
+	changes := diffCycles(prev, curr)
+	summary := make([]string, 0, len(changes))
+	for _, change := range changes {
+		summary = append(summary, change.Message)
+		if change.Level == "WARN" {
+			logutil.Printf("WARN", "[DISCOVERY] %s", change.Message)
+		}
+	}
+	if len(summary) == 0 {
+		logutil.Printf("DISCOVERY", "Scrape config changed, discovered targets unchanged")
+		return
+	}
+	logutil.Printf("DISCOVERY", "Scrape config changed: %s", strings.Join(summary, "; "))
+}
+
+// countSetDiff returns the number of keys in a that are not in b
+func countSetDiff(a, b map[string]bool) int {
+	n := 0
+	for k := range a {
+		if !b[k] {
+			n++
+		}
+	}
+	return n
+}
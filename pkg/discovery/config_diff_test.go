@@ -0,0 +1,92 @@
+package discovery
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func targetIDs(prefix string, n int) map[string]bool {
+	ids := make(map[string]bool, n)
+	for i := 0; i < n; i++ {
+		ids[fmt.Sprintf("%s/default/pod-%d/8080", prefix, i)] = true
+	}
+	return ids
+}
+
+func podMonitorConfig(selector string) map[string]interface{} {
+	return map[string]interface{}{
+		"targetName": "app",
+		"type":       "PodMonitor",
+		"selector":   map[string]interface{}{"matchLabels": map[string]interface{}{"app": selector}},
+	}
+}
+
+func TestDiffCycles_AddAndRemoveTargets(t *testing.T) {
+	prev := newDiscoveryCycle()
+	prev.add("app", podMonitorConfig("web"), targetIDs("app", 8))
+	prev.add("old", map[string]interface{}{"targetName": "old"}, targetIDs("old", 3))
+
+	curr := newDiscoveryCycle()
+	curr.add("app", podMonitorConfig("web"), targetIDs("app", 20))
+	curr.add("new", map[string]interface{}{"targetName": "new"}, targetIDs("new", 2))
+
+	if !curr.configChanged(prev) {
+		t.Fatal("expected config change to be detected")
+	}
+	changes := diffCycles(prev, curr)
+	want := []string{
+		"target app: +12 targets, -0 (now 20)",
+		"target new: added (+2 targets)",
+		"target old: removed from config (-3 targets)",
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("expected %d changes, got %+v", len(want), changes)
+	}
+	for i, change := range changes {
+		if change.Message != want[i] || change.Level != "INFO" {
+			t.Errorf("change %d: expected INFO %q, got %s %q", i, want[i], change.Level, change.Message)
+		}
+	}
+}
+
+func TestDiffCycles_SelectorTypoWarns(t *testing.T) {
+	prev := newDiscoveryCycle()
+	prev.add("app", podMonitorConfig("web"), targetIDs("app", 8))
+
+	curr := newDiscoveryCycle()
+	curr.add("app", podMonitorConfig("wbe"), map[string]bool{})
+	curr.add("typo", podMonitorConfig("nothing"), map[string]bool{})
+
+	changes := diffCycles(prev, curr)
+	if len(changes) != 2 {
+		t.Fatalf("expected 2 changes, got %+v", changes)
+	}
+	for _, change := range changes {
+		if change.Level != "WARN" || !strings.Contains(change.Message, "selector matches 0 targets") {
+			t.Errorf("expected zero-match WARN, got %s %q", change.Level, change.Message)
+		}
+	}
+	if !strings.Contains(changes[0].Message, "config modified") || !strings.Contains(changes[0].Message, "(was 8)") {
+		t.Errorf("unexpected message %q", changes[0].Message)
+	}
+}
+
+func TestLogConfigDiff_OnlyOnReload(t *testing.T) {
+	sd := &ServiceDiscoveryImpl{}
+
+	first := newDiscoveryCycle()
+	first.add("app", podMonitorConfig("web"), targetIDs("app", 2))
+	sd.logConfigDiff(first)
+
+	// Same config with different pods is not a reload
+	same := newDiscoveryCycle()
+	same.add("app", podMonitorConfig("web"), targetIDs("app", 3))
+	if same.configChanged(sd.lastCycle) {
+		t.Error("pod churn must not count as a config change")
+	}
+	sd.logConfigDiff(same)
+	if sd.lastCycle != same {
+		t.Error("expected the previous cycle to be retained for the next diff")
+	}
+}
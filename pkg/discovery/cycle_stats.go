@@ -0,0 +1,67 @@
+package discovery
+
+import (
+	"fmt"
+	"sort"
+)
+
+// DiscoveryTraceKey enables per-pod and per-target discovery logs (env var or whatap.conf). They are
+// separate from debug logging, which only gets one summary line per config and discovery cycle.
+const DiscoveryTraceKey = "openagent_discovery_trace"
+
+// discoveryStats counts what one discovery of a config processed, for its summary line
+type discoveryStats struct {
+	targetName string
+	targetType string
+	namespaces int // matched namespaces
+	objects    int // pods, services or static endpoints
+	targets    int
+	added      int
+	updated    int
+	removed    int
+}
+
+// objectKind names what the config discovers
+func (s *discoveryStats) objectKind() string {
+	switch s.targetType {
+	case "ServiceMonitor":
+		return "services"
+	case "StaticEndpoints":
+		return "endpoints"
+	default:
+		return "pods"
+	}
+}
+
+// summary is the line logged for the config after each discovery cycle
+func (s *discoveryStats) summary() string {
+	objects := fmt.Sprintf("%d %s", s.objects, s.objectKind())
+	if s.targetType != "StaticEndpoints" {
+		objects = fmt.Sprintf("%d namespaces, %s", s.namespaces, objects)
+	}
+	return fmt.Sprintf("%s %s: %s, %d targets (added %d, updated %d, removed %d)",
+		s.targetType, s.targetName, objects, s.targets, s.added, s.updated, s.removed)
+}
+
+// cycleSummaries returns the summary lines of a discovery cycle, including a line for configs whose
+// targets were removed without them being discovered, e.g. a deleted or disabled config
+func cycleSummaries(stats []*discoveryStats, removed map[string]int) []string {
+	lines := make([]string, 0, len(stats))
+	discovered := make(map[string]bool, len(stats))
+	for _, s := range stats {
+		s.removed = removed[s.targetName]
+		discovered[s.targetName] = true
+		lines = append(lines, s.summary())
+	}
+	var gone []string
+	for targetName := range removed {
+		if !discovered[targetName] {
+			gone = append(gone, targetName)
+		}
+	}
+	sort.Strings(gone)
+	for _, targetName := range gone {
+		lines = append(lines, fmt.Sprintf("%s: removed %d targets", targetName, removed[targetName]))
+	}
+	return lines
+}
@@ -0,0 +1,82 @@
+package discovery
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"open-agent/pkg/selector"
+)
+
+func podIn(namespace, name, ip string, labels map[string]string) *corev1.Pod {
+	pod := newTestPod(name, ip, true)
+	pod.Namespace = namespace
+	pod.Labels = labels
+	return pod
+}
+
+func TestDiscoverConfig_SummaryCountsProcessedObjects(t *testing.T) {
+	api := map[string]string{"app": "api"}
+	provider := &fakeProvider{pods: map[string][]*corev1.Pod{
+		"team-a": {podIn("team-a", "api-0", "10.0.0.1", api), podIn("team-a", "api-1", "10.0.0.2", api)},
+		"team-b": {
+			podIn("team-b", "api-2", "10.0.0.3", api),
+			podIn("team-b", "web-0", "10.0.0.4", map[string]string{"app": "web"}),
+			podIn("team-b", "api-3", "", api), // no IP yet, found but no target
+		},
+	}}
+	sd := &ServiceDiscoveryImpl{k8sClient: provider, targets: map[string]*Target{
+		"old/default/app-0/8080-metrics": {ID: "old/default/app-0/8080-metrics", Metadata: map[string]interface{}{"targetName": "old"}},
+	}}
+	config := newTestPodConfig(false)
+	config.NamespaceSelector = &selector.NamespaceSelector{MatchNames: []string{"team-a", "team-b", "team-c"}}
+	config.Selector = &selector.Selector{MatchLabels: api}
+
+	cycle := func() []string {
+		active := make(map[string]bool)
+		stats := sd.discoverConfig(config, active)
+		if sd.stats != nil {
+			t.Fatalf("expected the counters detached after the config was discovered")
+		}
+		return cycleSummaries([]*discoveryStats{stats}, sd.cleanupStaleTargets(active))
+	}
+
+	// The target of a deleted config is removed on the first cycle
+	want := []string{
+		"PodMonitor app: 3 namespaces, 4 pods, 3 targets (added 3, updated 0, removed 0)",
+		"old: removed 1 targets",
+	}
+	if got := cycle(); !reflect.DeepEqual(got, want) {
+		t.Errorf("first cycle:\n got %q\nwant %q", got, want)
+	}
+
+	provider.pods["team-a"] = provider.pods["team-a"][:1]
+	want = []string{"PodMonitor app: 3 namespaces, 3 pods, 2 targets (added 0, updated 2, removed 1)"}
+	if got := cycle(); !reflect.DeepEqual(got, want) {
+		t.Errorf("second cycle:\n got %q\nwant %q", got, want)
+	}
+	if len(sd.targets) != 2 {
+		t.Errorf("expected 2 targets left, got %d", len(sd.targets))
+	}
+}
+
+func TestDiscoverConfig_StaticEndpointsSummary(t *testing.T) {
+	sd := &ServiceDiscoveryImpl{targets: make(map[string]*Target)}
+	config := DiscoveryConfig{TargetName: "exporters", Type: "StaticEndpoints", Enabled: true, Endpoints: []EndpointConfig{
+		{Address: "10.0.1.1:9100", Path: "/metrics"},
+		{Address: "10.0.1.2:9100", Path: "/metrics"},
+		{Path: "/metrics"}, // no address, skipped
+	}}
+
+	active := make(map[string]bool)
+	stats := sd.discoverConfig(config, active)
+	want := "StaticEndpoints exporters: 2 endpoints, 2 targets (added 2, updated 0, removed 0)"
+	if got := cycleSummaries([]*discoveryStats{stats}, nil); len(got) != 1 || got[0] != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if stats := sd.discoverConfig(DiscoveryConfig{TargetName: "x", Type: "Unknown"}, active); stats != nil {
+		t.Errorf("expected no summary for an unknown target type, got %+v", stats)
+	}
+}
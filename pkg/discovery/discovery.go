@@ -2,10 +2,12 @@ package discovery
 
 import (
 	"context"
+	"regexp"
 	"time"
 
 	"open-agent/pkg/config"
 	"open-agent/pkg/model"
+	"open-agent/pkg/selector"
 )
 
 // Target represents a discovered scrape target
@@ -19,6 +21,13 @@ type Target struct {
 	State      TargetState
 	LastSeen   time.Time
 	RetryCount int
+	// ReadySince is when the target was seen becoming ready; zero if it was ready before discovery started
+	ReadySince time.Time
+	// InvalidReason is why the scrape URL of a TargetStateInvalid target is invalid
+	InvalidReason string
+
+	// readyGracePeriod holds a newly ready target in TargetStateWarming before it is scraped
+	readyGracePeriod time.Duration
 }
 
 type TargetState string
@@ -26,14 +35,16 @@ type TargetState string
 const (
 	TargetStateReady   TargetState = "ready"
 	TargetStatePending TargetState = "pending"
+	TargetStateWarming TargetState = "warming" // ready, waiting for readyGracePeriod to pass
 	TargetStateError   TargetState = "error"
 	TargetStateRemoved TargetState = "removed"
+	TargetStateInvalid TargetState = "invalid" // the scrape URL is malformed, never scraped
 )
 
 // ServiceDiscovery interface for target discovery
 type ServiceDiscovery interface {
 	// Load targets from configuration
-	LoadTargets(targets []map[string]interface{}) error
+	LoadTargets(targets []config.TargetConfig) error
 
 	// Start target discovery
 	Start(ctx context.Context) error
@@ -41,6 +52,12 @@ type ServiceDiscovery interface {
 	// Get currently ready targets
 	GetReadyTargets() []*Target
 
+	// Get all known targets regardless of state
+	GetAllTargets() []*Target
+
+	// Get the current state of a target by ID, e.g. to pick up a pod's new IP between scheduler updates
+	GetTarget(id string) (*Target, bool)
+
 	// Stop discovery
 	Stop() error
 }
@@ -48,12 +65,49 @@ type ServiceDiscovery interface {
 // DiscoveryConfig represents configuration for a single target
 type DiscoveryConfig struct {
 	TargetName        string
-	Type              string // "PodMonitor", "ServiceMonitor", "StaticEndpoints"
+	Type              string // "PodMonitor", "ServiceMonitor", "StaticEndpoints", "WhatapAgents", "EtcdMonitor"
 	Enabled           bool
-	NamespaceSelector map[string]interface{}
-	Selector          map[string]interface{}
+	NamespaceSelector *selector.NamespaceSelector
+	Selector          *selector.Selector
 	Endpoints         []EndpointConfig
 	RelabelConfigs    model.RelabelConfigs
+	// ScrapeNotReadyPods scrapes pods (and not-ready service endpoints) even when they fail readiness
+	ScrapeNotReadyPods bool
+	// ExcludeSelector drops pods/services whose labels match (matchLabels/matchExpressions)
+	ExcludeSelector *selector.Selector
+	// ExcludePodNames drops pods by exact name or regex (PodMonitor), compiled with config.CompileNamePatterns
+	ExcludePodNames []*regexp.Regexp
+	// ExcludeServiceNames drops services by exact name or regex (ServiceMonitor)
+	ExcludeServiceNames []*regexp.Regexp
+	// MetricPrefix is prepended to every metric name of the target's endpoints unless they set their own
+	MetricPrefix string
+	// ReadyGracePeriod delays scraping pods and service endpoints until they have been ready this long
+	ReadyGracePeriod time.Duration
+	// ProxyViaApiserver scrapes pods through the kube-apiserver pod proxy instead of the pod IP (PodMonitor)
+	ProxyViaApiserver bool
+	// PreferLocalZone scrapes only the service endpoints in the agent's zone when there are any (ServiceMonitor)
+	PreferLocalZone bool
+	// AllowSelfScrape scrapes the agent's own pod and admin server when the target selects them
+	AllowSelfScrape bool
+	// AddWorkloadLabels adds workload_kind/workload_name from the pod's owner references (PodMonitor)
+	AddWorkloadLabels bool
+	// AddGenerationLabel adds the pod's container restart count as the generation label (PodMonitor)
+	AddGenerationLabel bool
+	// AllowPodAnnotationsOverride lets the openagent.whatap.io/interval, /path and /port annotations of a
+	// pod override the endpoint settings of its targets (PodMonitor)
+	AllowPodAnnotationsOverride bool
+	// IgnorePendingTargets does not keep targets for not-ready pods and endpoints (trackPendingTargets: false)
+	IgnorePendingTargets bool
+	// SampleRate scrapes only this fraction of the matching pods, 0 scrapes all (PodMonitor)
+	SampleRate float64
+	// MaxPods scrapes at most this many of the matching pods, 0 scrapes all (PodMonitor)
+	MaxPods int
+	// Priority orders targets for load shedding; lower priorities are paused first when the agent is overloaded
+	Priority int
+	// LabelTemplates sets target labels from Go templates over the discovered object's fields
+	LabelTemplates map[string]string
+	// Aggregations pre-aggregate metrics within each scrape of the target's endpoints
+	Aggregations model.Aggregations
 }
 
 // AdaptiveTimeoutConfig represents adaptive timeout configuration
@@ -71,10 +125,52 @@ type EndpointConfig struct {
 	Scheme               string
 	Interval             string
 	Timeout              string                 // HTTP request timeout (e.g., "10s", "1m")
+	ConnectTimeout       string                 // Dial and TLS handshake timeout (default 5s)
+	ReadTimeout          string                 // Time to wait for response headers once connected
 	AdaptiveTimeout      *AdaptiveTimeoutConfig // Adaptive timeout configuration
 	TLSConfig            map[string]interface{}
 	BasicAuth            *config.BasicAuthConfig
-	MetricRelabelConfigs []interface{}
-	Params               map[string]interface{} // HTTP URL parameters
+	MetricRelabelConfigs model.RelabelConfigs
+	Params               map[string]interface{}  // HTTP URL parameters
+	Headers              map[string]string       // Extra HTTP request headers (override User-Agent etc.)
+	Downsample           *model.DownsampleConfig // Per-series window aggregation (e.g., "5m:avg")
+	MetricPrefix         string                  // Prepended to metric names before metricRelabelConfigs
+	UnitConversions      model.UnitConversions   // Value scaling and renaming before metricRelabelConfigs
+	ValueTransforms      model.ValueTransforms   // Value clamping, scaling and abs after unitConversions
+	InfoJoins            model.InfoJoins         // Info metric labels copied onto other series before metricRelabelConfigs
+	Aggregations         model.Aggregations      // The target's within-scrape aggregation rules
 	AddNodeLabel         bool
+	// PreserveAgentNodeLabel keeps the added node label as agent_node when the exporter already emits node
+	PreserveAgentNodeLabel bool
+	// DisableDNSCache resolves the target host on every new connection instead of using the scrape DNS cache
+	DisableDNSCache bool
+	// LabelLengthLimit truncates or drops samples with long label values after metricRelabelConfigs
+	LabelLengthLimit *model.LabelLengthLimit
+	// TimestampAlignment is none or interval, which stamps samples with the scrape-cycle boundary
+	TimestampAlignment string
+	// NonFiniteValues is drop, zero or passthrough for NaN and ±Inf sample values
+	NonFiniteValues string
+	// AcceptProtobuf advertises the Prometheus protobuf format first in the Accept header, as
+	// openagent_enable_protobuf does for every target
+	AcceptProtobuf bool
+	// MinSamples reports a successful scrape exposing fewer samples as degraded, 0 disables the check
+	MinSamples int
+	// ExternalLabels are added to every sample that does not have the label already
+	ExternalLabels map[string]string
+	// SampleLimit drops every sample of a scrape exposing more samples after metricRelabelConfigs, 0 is unlimited
+	SampleLimit int
+	// SecretParams are the params read from Secrets, resolved by the scrape client on every request
+	SecretParams map[string]config.SecretKeySelector
+	// MatchSelectors are sent as repeated match[] params to a federate endpoint
+	MatchSelectors []string
+	// HonorLabels keeps exposed labels that collide with target labels instead of the target's
+	HonorLabels bool
+	// IgnoreTimestamps stamps every sample with the scrape time instead of the exposition's timestamp
+	IgnoreTimestamps bool
+}
+
+// CanonicalParams returns Params as the encoded query the scrape URL is built with: keys sorted, array
+// values joined in their YAML order
+func (e EndpointConfig) CanonicalParams() string {
+	return paramsToQuery(e.Params).Encode()
 }
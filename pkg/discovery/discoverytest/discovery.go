@@ -0,0 +1,117 @@
+// Package discoverytest provides an in-memory ServiceDiscovery and a fake exporter
+// for testing components that consume discovered targets, such as ScraperManager,
+// without the Kubernetes client or configuration stack.
+package discoverytest
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"open-agent/pkg/config"
+	"open-agent/pkg/discovery"
+)
+
+// Discovery is a discovery.ServiceDiscovery whose targets are set by the test.
+// LoadTargets, Start and Stop do nothing; targets change only through Add, Remove and SetState.
+type Discovery struct {
+	mu      sync.RWMutex
+	targets map[string]*discovery.Target
+}
+
+var _ discovery.ServiceDiscovery = (*Discovery)(nil)
+
+// New returns a Discovery holding the given targets
+func New(targets ...*discovery.Target) *Discovery {
+	d := &Discovery{targets: make(map[string]*discovery.Target)}
+	d.Add(targets...)
+	return d
+}
+
+// Add adds targets, replacing any with the same ID. A target without a state is added as ready.
+func (d *Discovery) Add(targets ...*discovery.Target) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, target := range targets {
+		if target.State == "" {
+			target.State = discovery.TargetStateReady
+		}
+		d.targets[target.ID] = target
+	}
+}
+
+// Remove removes the targets with the given IDs
+func (d *Discovery) Remove(ids ...string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, id := range ids {
+		delete(d.targets, id)
+	}
+}
+
+// SetState changes the state of a target, and reports false if the target does not exist
+func (d *Discovery) SetState(id string, state discovery.TargetState) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	target, ok := d.targets[id]
+	if ok {
+		target.State = state
+	}
+	return ok
+}
+
+// LoadTargets does nothing; targets are added with Add
+func (d *Discovery) LoadTargets(targets []config.TargetConfig) error { return nil }
+
+// Start does nothing
+func (d *Discovery) Start(ctx context.Context) error { return nil }
+
+// Stop does nothing
+func (d *Discovery) Stop() error { return nil }
+
+// GetReadyTargets returns the ready targets sorted by ID
+func (d *Discovery) GetReadyTargets() []*discovery.Target {
+	return d.list(func(target *discovery.Target) bool { return target.State == discovery.TargetStateReady })
+}
+
+// GetAllTargets returns every target sorted by ID
+func (d *Discovery) GetAllTargets() []*discovery.Target {
+	return d.list(func(*discovery.Target) bool { return true })
+}
+
+// GetTarget returns the target with the given ID
+func (d *Discovery) GetTarget(id string) (*discovery.Target, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	target, ok := d.targets[id]
+	return target, ok
+}
+
+func (d *Discovery) list(include func(*discovery.Target) bool) []*discovery.Target {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	targets := make([]*discovery.Target, 0, len(d.targets))
+	for _, target := range d.targets {
+		if include(target) {
+			targets = append(targets, target)
+		}
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].ID < targets[j].ID })
+	return targets
+}
+
+// Target returns a ready static target scraping url with the given endpoint configuration,
+// shaped like the targets ServiceDiscoveryImpl creates
+func Target(id, url string, endpoint discovery.EndpointConfig) *discovery.Target {
+	return &discovery.Target{
+		ID:     id,
+		URL:    url,
+		Labels: map[string]string{"job": id, "instance": id},
+		Metadata: map[string]interface{}{
+			"targetName": id,
+			"type":       "StaticEndpoints",
+			"endpoint":   endpoint,
+		},
+		State: discovery.TargetStateReady,
+	}
+}
@@ -0,0 +1,97 @@
+package discoverytest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Exporter is a fake Prometheus exporter serving a configurable text exposition body,
+// with injectable latency and failures
+type Exporter struct {
+	server   *httptest.Server
+	requests atomic.Int64
+
+	mu       sync.Mutex
+	body     string
+	latency  time.Duration
+	status   int
+	failures int
+}
+
+// NewExporter starts an exporter serving body with status 200. Close it when the test ends.
+func NewExporter(body string) *Exporter {
+	e := &Exporter{body: body, status: http.StatusOK}
+	e.server = httptest.NewServer(http.HandlerFunc(e.serve))
+	return e
+}
+
+func (e *Exporter) serve(w http.ResponseWriter, r *http.Request) {
+	e.requests.Add(1)
+
+	e.mu.Lock()
+	body, latency, status := e.body, e.latency, e.status
+	if e.failures > 0 {
+		e.failures--
+		status = http.StatusInternalServerError
+	}
+	e.mu.Unlock()
+
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.WriteHeader(status)
+	if status == http.StatusOK {
+		w.Write([]byte(body))
+	}
+}
+
+// URL returns the exporter's metrics URL
+func (e *Exporter) URL() string {
+	return e.server.URL + "/metrics"
+}
+
+// SetBody changes the exposition served from the next request on
+func (e *Exporter) SetBody(body string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.body = body
+}
+
+// SetLatency delays every response by d
+func (e *Exporter) SetLatency(d time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.latency = d
+}
+
+// SetStatus makes every response use the given status code; non-200 responses have no body
+func (e *Exporter) SetStatus(status int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.status = status
+}
+
+// FailNext makes the next n requests fail with 500
+func (e *Exporter) FailNext(n int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.failures = n
+}
+
+// Requests returns the number of requests served so far
+func (e *Exporter) Requests() int64 {
+	return e.requests.Load()
+}
+
+// Close shuts the exporter down
+func (e *Exporter) Close() {
+	e.server.Close()
+}
@@ -0,0 +1,28 @@
+package discovery
+
+import (
+	configPkg "open-agent/pkg/config"
+	"open-agent/pkg/model"
+)
+
+// expandEndpointPaths returns one endpoint per path when path is a list, e.g. [/metrics, /metrics/cadvisor],
+// each inheriting the endpoint's other settings. pathMetricRelabelConfigs optionally maps a path to
+// metricRelabelConfigs applied after the endpoint's own for that path only. The list itself is
+// validated when the target is decoded.
+func expandEndpointPaths(ep configPkg.EndpointConfig, endpointConfig EndpointConfig) []EndpointConfig {
+	if !ep.Path.IsList {
+		return []EndpointConfig{endpointConfig}
+	}
+
+	expanded := make([]EndpointConfig, 0, len(ep.Path.Values))
+	for _, path := range ep.Path.Values {
+		expandedEndpoint := endpointConfig
+		expandedEndpoint.Path = path
+		if extra, ok := ep.PathMetricRelabelConfigs[path]; ok {
+			relabels := make(model.RelabelConfigs, 0, len(endpointConfig.MetricRelabelConfigs)+len(extra))
+			expandedEndpoint.MetricRelabelConfigs = append(append(relabels, endpointConfig.MetricRelabelConfigs...), extra...)
+		}
+		expanded = append(expanded, expandedEndpoint)
+	}
+	return expanded
+}
@@ -0,0 +1,82 @@
+package discovery
+
+import (
+	"testing"
+)
+
+func parseMultiPathConfig(t *testing.T, endpoint map[string]interface{}) DiscoveryConfig {
+	t.Helper()
+	sd := &ServiceDiscoveryImpl{}
+	cfg, err := sd.parseDiscoveryConfig(map[string]interface{}{
+		"targetName": "kubelet",
+		"type":       "PodMonitor",
+		"endpoints":  []interface{}{endpoint},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return cfg
+}
+
+func TestParseEndpointPaths_ExpandsList(t *testing.T) {
+	cfg := parseMultiPathConfig(t, map[string]interface{}{
+		"port":                 "10250",
+		"path":                 []interface{}{"/metrics", "/metrics/cadvisor"},
+		"interval":             "15s",
+		"scheme":               "https",
+		"metricRelabelConfigs": []interface{}{map[string]interface{}{"action": "drop"}},
+		"pathMetricRelabelConfigs": map[string]interface{}{
+			"/metrics/cadvisor": []interface{}{map[string]interface{}{"action": "keep"}},
+		},
+	})
+	if len(cfg.Endpoints) != 2 {
+		t.Fatalf("expected 2 endpoints, got %d", len(cfg.Endpoints))
+	}
+	for i, path := range []string{"/metrics", "/metrics/cadvisor"} {
+		ep := cfg.Endpoints[i]
+		if ep.Path != path || ep.Port != "10250" || ep.Interval != "15s" || ep.Scheme != "https" {
+			t.Errorf("endpoint %d did not inherit settings: %+v", i, ep)
+		}
+	}
+	if len(cfg.Endpoints[0].MetricRelabelConfigs) != 1 || len(cfg.Endpoints[1].MetricRelabelConfigs) != 2 {
+		t.Errorf("expected per-path relabel configs after the endpoint's own, got %d and %d",
+			len(cfg.Endpoints[0].MetricRelabelConfigs), len(cfg.Endpoints[1].MetricRelabelConfigs))
+	}
+
+	// Each path becomes its own target with the path in the target ID
+	sd := &ServiceDiscoveryImpl{targets: make(map[string]*Target)}
+	sd.processPodTarget(newTestPod("node-a", "10.0.0.1", true), cfg, make(map[string]bool))
+	for _, id := range []string{"kubelet/default/node-a/10250-metrics", "kubelet/default/node-a/10250-metrics-cadvisor"} {
+		target, ok := sd.targets[id]
+		if !ok {
+			t.Fatalf("missing target %s, have %d targets", id, len(sd.targets))
+		}
+		if endpoint := target.Metadata["endpoint"].(EndpointConfig); endpoint.Interval != "15s" {
+			t.Errorf("%s: expected inherited interval 15s, got %q", id, endpoint.Interval)
+		}
+	}
+}
+
+func TestParseEndpointPaths_StringPathUnchanged(t *testing.T) {
+	cfg := parseMultiPathConfig(t, map[string]interface{}{"port": "8080", "path": "/metrics"})
+	if len(cfg.Endpoints) != 1 || cfg.Endpoints[0].Path != "/metrics" {
+		t.Fatalf("unexpected endpoints %+v", cfg.Endpoints)
+	}
+}
+
+func TestParseEndpointPaths_RejectsInvalidLists(t *testing.T) {
+	for name, endpoint := range map[string]map[string]interface{}{
+		"empty":      {"port": "8080", "path": []interface{}{}},
+		"non-string": {"port": "8080", "path": []interface{}{"/metrics", 1}},
+		"duplicate":  {"port": "8080", "path": []interface{}{"/metrics", "/metrics"}},
+		"unknown relabel path": {
+			"port":                     "8080",
+			"path":                     []interface{}{"/metrics"},
+			"pathMetricRelabelConfigs": map[string]interface{}{"/other": []interface{}{}},
+		},
+	} {
+		if cfg := parseMultiPathConfig(t, endpoint); len(cfg.Endpoints) != 0 {
+			t.Errorf("%s: expected the endpoint to be rejected, got %+v", name, cfg.Endpoints)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package discovery
+
+import (
+	configPkg "open-agent/pkg/config"
+)
+
+// expandEndpoint returns the endpoints an endpoint entry is scraped as: one per port of a ports list,
+// e.g. [8080, 9091] for an app and its sidecar, each expanded per path of a path list. Every expanded
+// endpoint inherits the entry's other settings; portConfigs overrides the path and interval of one
+// port, and an overridden path replaces the path list for that port. The target ID includes the port
+// and path, so each becomes its own target.
+func expandEndpoint(ep configPkg.EndpointConfig, endpointConfig EndpointConfig) []EndpointConfig {
+	if len(ep.Ports) == 0 {
+		return expandEndpointPaths(ep, endpointConfig)
+	}
+
+	expanded := make([]EndpointConfig, 0, len(ep.Ports)*max(len(ep.Path.Values), 1))
+	for _, port := range ep.Ports {
+		portEndpoint := endpointConfig
+		portEndpoint.Port = port
+		override := ep.PortConfigs[port]
+		if override.Interval != "" {
+			portEndpoint.Interval = override.Interval
+		}
+		if override.Path != "" {
+			portEndpoint.Path = override.Path
+			expanded = append(expanded, portEndpoint)
+			continue
+		}
+		expanded = append(expanded, expandEndpointPaths(ep, portEndpoint)...)
+	}
+	return expanded
+}
@@ -0,0 +1,87 @@
+package discovery
+
+import (
+	"testing"
+)
+
+func TestParseEndpointPorts_ExpandsList(t *testing.T) {
+	cfg := parseMultiPathConfig(t, map[string]interface{}{
+		"ports":    []interface{}{8080, "9091"},
+		"path":     "/metrics",
+		"interval": "30s",
+		"scheme":   "http",
+	})
+	if len(cfg.Endpoints) != 2 {
+		t.Fatalf("expected 2 endpoints, got %d", len(cfg.Endpoints))
+	}
+	for i, port := range []string{"8080", "9091"} {
+		ep := cfg.Endpoints[i]
+		if ep.Port != port || ep.Path != "/metrics" || ep.Interval != "30s" || ep.Scheme != "http" {
+			t.Errorf("endpoint %d did not inherit settings: %+v", i, ep)
+		}
+	}
+
+	// Each port becomes its own target with the port in the target ID
+	sd := &ServiceDiscoveryImpl{targets: make(map[string]*Target)}
+	sd.processPodTarget(newTestPod("app-0", "10.0.0.1", true), cfg, make(map[string]bool))
+	for id, url := range map[string]string{
+		"kubelet/default/app-0/8080-metrics": "http://10.0.0.1:8080/metrics",
+		"kubelet/default/app-0/9091-metrics": "http://10.0.0.1:9091/metrics",
+	} {
+		target, ok := sd.targets[id]
+		if !ok {
+			t.Fatalf("missing target %s, have %d targets", id, len(sd.targets))
+		}
+		if target.URL != url {
+			t.Errorf("%s: URL = %q, want %q", id, target.URL, url)
+		}
+	}
+}
+
+func TestParseEndpointPorts_PortConfigsOverride(t *testing.T) {
+	cfg := parseMultiPathConfig(t, map[string]interface{}{
+		"ports":    []interface{}{"8080", "9091", "9100"},
+		"path":     []interface{}{"/metrics", "/metrics/extra"},
+		"interval": "30s",
+		"portConfigs": map[string]interface{}{
+			// The sidecar has its own path and interval; the overridden path replaces the path list
+			"9091": map[string]interface{}{"path": "/stats/prometheus", "interval": "15s"},
+			// Only the interval: the port keeps the path list
+			"9100": map[string]interface{}{"interval": "60s"},
+		},
+	})
+
+	type endpoint struct{ port, path, interval string }
+	want := []endpoint{
+		{"8080", "/metrics", "30s"},
+		{"8080", "/metrics/extra", "30s"},
+		{"9091", "/stats/prometheus", "15s"},
+		{"9100", "/metrics", "60s"},
+		{"9100", "/metrics/extra", "60s"},
+	}
+	if len(cfg.Endpoints) != len(want) {
+		t.Fatalf("expected %d endpoints, got %+v", len(want), cfg.Endpoints)
+	}
+	for i, w := range want {
+		got := endpoint{cfg.Endpoints[i].Port, cfg.Endpoints[i].Path, cfg.Endpoints[i].Interval}
+		if got != w {
+			t.Errorf("endpoint %d = %+v, want %+v", i, got, w)
+		}
+	}
+}
+
+func TestParseEndpointPorts_RejectsInvalidLists(t *testing.T) {
+	for name, endpoint := range map[string]map[string]interface{}{
+		"port and ports": {"port": "8080", "ports": []interface{}{"9091"}},
+		"empty":          {"ports": []interface{}{}},
+		"duplicate":      {"ports": []interface{}{"8080", 8080}},
+		"unknown port config": {
+			"ports":       []interface{}{"8080"},
+			"portConfigs": map[string]interface{}{"9091": map[string]interface{}{"path": "/stats"}},
+		},
+	} {
+		if cfg := parseMultiPathConfig(t, endpoint); len(cfg.Endpoints) != 0 {
+			t.Errorf("%s: expected the endpoint to be rejected, got %+v", name, cfg.Endpoints)
+		}
+	}
+}
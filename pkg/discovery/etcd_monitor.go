@@ -0,0 +1,116 @@
+package discovery
+
+import (
+	"net"
+
+	configPkg "open-agent/pkg/config"
+	"open-agent/pkg/model"
+	"open-agent/pkg/selector"
+)
+
+// EtcdMonitorType is a preset for the etcd members of a self-hosted control plane. Members are the
+// static etcd.addresses when set, otherwise the endpoints of the etcd service in kube-system, which is
+// then discovered like a ServiceMonitor. Fields written in the target replace the defaults below.
+const EtcdMonitorType = "EtcdMonitor"
+
+const (
+	// etcdNamespace is where kubeadm runs etcd
+	etcdNamespace = "kube-system"
+	// etcdMetricsPort serves plain-HTTP metrics with --listen-metrics-urls (kubeadm's default)
+	etcdMetricsPort = "2381"
+	// etcdClientPort serves metrics on the client port, which requires a client certificate
+	etcdClientPort = "2379"
+)
+
+// etcdServiceLabels select the etcd service, labeled like the kubeadm etcd static pods
+var etcdServiceLabels = map[string]string{"component": "etcd"}
+
+// etcdMetricRelabelConfigs is the default metric relabel set of EtcdMonitor endpoints: the
+// etcd_debugging_* metrics are dropped, as they are many series of little use outside etcd development
+var etcdMetricRelabelConfigs = model.RelabelConfigs{
+	{
+		SourceLabels: []string{"__name__"},
+		Separator:    ";",
+		Regex:        "etcd_debugging_.*",
+		Action:       "drop",
+	},
+}
+
+// withEtcdMonitorDefaults fills in the namespace, selector, endpoint and metric relabel defaults of an
+// EtcdMonitor target. With etcd.tlsConfig the members are scraped on the client port over https,
+// otherwise on the plain-HTTP metrics port.
+func withEtcdMonitorDefaults(target configPkg.TargetConfig) configPkg.TargetConfig {
+	var etcd configPkg.EtcdConfig
+	if target.Etcd != nil {
+		etcd = *target.Etcd
+	}
+	port, scheme := etcdMetricsPort, "http"
+	if len(etcd.TLSConfig) > 0 {
+		port, scheme = etcdClientPort, "https"
+	}
+
+	if len(etcd.Addresses) == 0 {
+		if target.NamespaceSelector == nil {
+			target.NamespaceSelector = &selector.NamespaceSelector{MatchNames: []string{etcdNamespace}}
+		}
+		if target.Selector == nil {
+			target.Selector = &selector.Selector{MatchLabels: etcdServiceLabels}
+		}
+	}
+
+	if len(target.Endpoints) == 0 {
+		// The default endpoints take the target's scrape defaults, already merged with the global section
+		endpoint := configPkg.EndpointConfig{
+			Path:           configPkg.StringList{Values: []string{"/metrics"}},
+			Interval:       target.Interval,
+			Timeout:        target.Timeout,
+			ExternalLabels: target.ExternalLabels,
+			SampleLimit:    target.SampleLimit,
+		}
+		if len(etcd.Addresses) == 0 {
+			endpoint.Port = port
+			target.Endpoints = []configPkg.EndpointConfig{endpoint}
+		} else {
+			for _, address := range etcd.Addresses {
+				member := endpoint
+				member.Address = etcdMemberAddress(address, port)
+				target.Endpoints = append(target.Endpoints, member)
+			}
+		}
+	}
+
+	endpoints := make([]configPkg.EndpointConfig, len(target.Endpoints))
+	for i, endpoint := range target.Endpoints {
+		if endpoint.TLSConfig == nil && len(etcd.TLSConfig) > 0 {
+			endpoint.TLSConfig = etcd.TLSConfig
+		}
+		if endpoint.Scheme == "" {
+			endpoint.Scheme = scheme
+		}
+		if endpoint.MetricRelabelConfigs == nil {
+			endpoint.MetricRelabelConfigs = etcdMetricRelabelConfigs
+		}
+		endpoints[i] = endpoint
+	}
+	target.Endpoints = endpoints
+	return target
+}
+
+// etcdMemberAddress adds the default port to a member address written without one
+func etcdMemberAddress(address, port string) string {
+	if _, _, err := net.SplitHostPort(address); err == nil {
+		return address
+	}
+	return net.JoinHostPort(address, port)
+}
+
+// isStaticEtcd reports whether an EtcdMonitor config scrapes static member addresses instead of the
+// endpoints of the etcd service
+func isStaticEtcd(config DiscoveryConfig) bool {
+	for _, endpoint := range config.Endpoints {
+		if endpoint.Address != "" {
+			return true
+		}
+	}
+	return false
+}
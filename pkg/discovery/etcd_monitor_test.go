@@ -0,0 +1,136 @@
+package discovery
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+var etcdTLSConfig = map[string]interface{}{
+	"caFile":   "/etc/kubernetes/pki/etcd/ca.crt",
+	"certFile": "/etc/kubernetes/pki/etcd/healthcheck-client.crt",
+	"keyFile":  "/etc/kubernetes/pki/etcd/healthcheck-client.key",
+}
+
+// etcdProvider serves a kube-system etcd service exposing both ports, with three members
+func etcdProvider() *fakeProvider {
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "etcd", Namespace: "kube-system", Labels: map[string]string{"component": "etcd"}},
+		Spec: corev1.ServiceSpec{Ports: []corev1.ServicePort{
+			{Name: "client", Port: 2379, TargetPort: intstr.FromInt(2379)},
+			{Name: "metrics", Port: 2381, TargetPort: intstr.FromInt(2381)},
+		}},
+	}
+	return &fakeProvider{
+		services: map[string][]*corev1.Service{"kube-system": {service}},
+		endpoints: map[string]*corev1.Endpoints{
+			"kube-system/etcd": {
+				Subsets: []corev1.EndpointSubset{{
+					Addresses: []corev1.EndpointAddress{{IP: "10.0.0.11"}, {IP: "10.0.0.12"}, {IP: "10.0.0.13"}},
+					Ports:     []corev1.EndpointPort{{Name: "client", Port: 2379}, {Name: "metrics", Port: 2381}},
+				}},
+			},
+		},
+	}
+}
+
+func discoverEtcd(t *testing.T, sd *ServiceDiscoveryImpl, raw map[string]interface{}) map[string]*Target {
+	t.Helper()
+	cfg, err := sd.parseDiscoveryConfig(raw)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sd.discoverConfig(cfg, make(map[string]bool)) == nil {
+		t.Fatalf("EtcdMonitor was not discovered")
+	}
+	urls := make(map[string]*Target, len(sd.targets))
+	for _, target := range sd.targets {
+		urls[target.URL] = target
+	}
+	return urls
+}
+
+func TestEtcdMonitor_ServiceDiscoveredMembers(t *testing.T) {
+	sd := &ServiceDiscoveryImpl{k8sClient: etcdProvider(), targets: make(map[string]*Target)}
+	targets := discoverEtcd(t, sd, map[string]interface{}{
+		"targetName": "etcd",
+		"type":       "EtcdMonitor",
+		"etcd":       map[string]interface{}{"tlsConfig": etcdTLSConfig},
+	})
+
+	for _, url := range []string{"https://10.0.0.11:2379/metrics", "https://10.0.0.12:2379/metrics", "https://10.0.0.13:2379/metrics"} {
+		target, ok := targets[url]
+		if !ok {
+			t.Errorf("expected a target %s, got %v", url, targets)
+			continue
+		}
+		endpoint := target.Metadata["endpoint"].(EndpointConfig)
+		if endpoint.TLSConfig["certFile"] != etcdTLSConfig["certFile"] {
+			t.Errorf("expected the etcd client certificate on %s, got %v", url, endpoint.TLSConfig)
+		}
+		if len(endpoint.MetricRelabelConfigs) != 1 || endpoint.MetricRelabelConfigs[0].Regex != "etcd_debugging_.*" {
+			t.Errorf("expected the etcd_debugging_* drop rule on %s, got %+v", url, endpoint.MetricRelabelConfigs)
+		}
+	}
+	if len(targets) != 3 {
+		t.Errorf("expected one target per member, got %d", len(targets))
+	}
+
+	// Without certificates the members are scraped on the plain-HTTP metrics port
+	sd = &ServiceDiscoveryImpl{k8sClient: etcdProvider(), targets: make(map[string]*Target)}
+	targets = discoverEtcd(t, sd, map[string]interface{}{"targetName": "etcd", "type": "EtcdMonitor"})
+	if _, ok := targets["http://10.0.0.11:2381/metrics"]; !ok || len(targets) != 3 {
+		t.Errorf("expected the members on the metrics port, got %v", targets)
+	}
+}
+
+func TestEtcdMonitor_StaticMembers(t *testing.T) {
+	sd := &ServiceDiscoveryImpl{targets: make(map[string]*Target)}
+	targets := discoverEtcd(t, sd, map[string]interface{}{
+		"targetName": "etcd",
+		"type":       "EtcdMonitor",
+		"interval":   "15s",
+		"etcd": map[string]interface{}{
+			"addresses": []interface{}{"10.0.0.11", "etcd-2.example.com:12379", "fd00::13"},
+			"tlsConfig": etcdTLSConfig,
+		},
+	})
+
+	for _, url := range []string{"https://10.0.0.11:2379/metrics", "https://etcd-2.example.com:12379/metrics", "https://[fd00::13]:2379/metrics"} {
+		target, ok := targets[url]
+		if !ok {
+			t.Errorf("expected a target %s, got %v", url, targets)
+			continue
+		}
+		endpoint := target.Metadata["endpoint"].(EndpointConfig)
+		if endpoint.Interval != "15s" || endpoint.TLSConfig["keyFile"] != etcdTLSConfig["keyFile"] {
+			t.Errorf("expected the target interval and client key on %s, got %+v", url, endpoint)
+		}
+	}
+	if len(targets) != 3 {
+		t.Errorf("expected one target per address, got %d", len(targets))
+	}
+}
+
+func TestEtcdMonitor_MetricRelabelConfigsOverrideDefault(t *testing.T) {
+	sd := &ServiceDiscoveryImpl{}
+	cfg, err := sd.parseDiscoveryConfig(map[string]interface{}{
+		"targetName": "etcd",
+		"type":       "EtcdMonitor",
+		"endpoints": []interface{}{map[string]interface{}{
+			"address":              "10.0.0.11:2381",
+			"metricRelabelConfigs": []interface{}{},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !isStaticEtcd(cfg) {
+		t.Errorf("expected an endpoint with an address to scrape static members")
+	}
+	if len(cfg.Endpoints) != 1 || cfg.Endpoints[0].Scheme != "http" || len(cfg.Endpoints[0].MetricRelabelConfigs) != 0 {
+		t.Errorf("expected the written endpoint to keep its empty metricRelabelConfigs, got %+v", cfg.Endpoints)
+	}
+}
@@ -0,0 +1,93 @@
+package discovery
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	configPkg "open-agent/pkg/config"
+	"open-agent/pkg/selector"
+	"open-agent/tools/util/logutil"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// podExclusionReason returns why a pod selected by the target is excluded, or "" if it is kept
+func podExclusionReason(pod *corev1.Pod, config DiscoveryConfig) string {
+	if pattern, ok := matchNamePatterns(pod.Name, config.ExcludePodNames); ok {
+		return fmt.Sprintf("name matches excludePodNames entry %q", pattern)
+	}
+	if matchesLabelSelector(pod.Labels, config.ExcludeSelector) {
+		return "labels match excludeSelector"
+	}
+	return ""
+}
+
+// serviceExclusionReason returns why a service selected by the target is excluded, or "" if it is kept
+func serviceExclusionReason(service *corev1.Service, config DiscoveryConfig) string {
+	if pattern, ok := matchNamePatterns(service.Name, config.ExcludeServiceNames); ok {
+		return fmt.Sprintf("name matches excludeServiceNames entry %q", pattern)
+	}
+	if matchesLabelSelector(service.Labels, config.ExcludeSelector) {
+		return "labels match excludeSelector"
+	}
+	return ""
+}
+
+// filterExcludedPods removes pods matching the target's exclusions
+func filterExcludedPods(pods []*corev1.Pod, config DiscoveryConfig) []*corev1.Pod {
+	if len(config.ExcludePodNames) == 0 && config.ExcludeSelector == nil {
+		return pods
+	}
+	kept := make([]*corev1.Pod, 0, len(pods))
+	for _, pod := range pods {
+		if reason := podExclusionReason(pod, config); reason != "" {
+			if configPkg.IsDebugEnabled() {
+				logutil.Debugf("DISCOVERY", "PodMonitor %s - Excluding pod %s/%s: %s", config.TargetName, pod.Namespace, pod.Name, reason)
+			}
+			continue
+		}
+		kept = append(kept, pod)
+	}
+	return kept
+}
+
+// filterExcludedServices removes services matching the target's exclusions
+func filterExcludedServices(services []*corev1.Service, config DiscoveryConfig) []*corev1.Service {
+	if len(config.ExcludeServiceNames) == 0 && config.ExcludeSelector == nil {
+		return services
+	}
+	kept := make([]*corev1.Service, 0, len(services))
+	for _, service := range services {
+		if reason := serviceExclusionReason(service, config); reason != "" {
+			if configPkg.IsDebugEnabled() {
+				logutil.Debugf("DISCOVERY", "ServiceMonitor %s - Excluding service %s/%s: %s", config.TargetName, service.Namespace, service.Name, reason)
+			}
+			continue
+		}
+		kept = append(kept, service)
+	}
+	return kept
+}
+
+// matchNamePatterns returns the first compiled excludePodNames/excludeServiceNames entry matching name
+func matchNamePatterns(name string, patterns []*regexp.Regexp) (string, bool) {
+	for _, re := range patterns {
+		if re.MatchString(name) {
+			return namePattern(re), true
+		}
+	}
+	return "", false
+}
+
+// namePattern returns the entry a pattern was compiled from, without the anchors CompileNamePatterns adds
+func namePattern(re *regexp.Regexp) string {
+	return strings.TrimSuffix(strings.TrimPrefix(re.String(), "^(?:"), ")$")
+}
+
+// matchesLabelSelector reports whether labels satisfy every matchLabels entry and matchExpressions
+// requirement of the selector. An empty selector matches nothing; invalid selectors are dropped
+// with a warning when the target is decoded.
+func matchesLabelSelector(labels map[string]string, labelSelector *selector.Selector) bool {
+	return labelSelector != nil && !labelSelector.Empty() && labelSelector.Matches(labels)
+}
@@ -0,0 +1,141 @@
+package discovery
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	configPkg "open-agent/pkg/config"
+	"open-agent/pkg/selector"
+)
+
+func newLabeledPod(name string, labels map[string]string) *corev1.Pod {
+	pod := newTestPod(name, "10.0.0.10", true)
+	pod.Labels = labels
+	return pod
+}
+
+func mustCompileNamePatterns(t *testing.T, patterns ...string) []*regexp.Regexp {
+	t.Helper()
+	compiled, err := configPkg.CompileNamePatterns(patterns)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return compiled
+}
+
+// discoverFromPods runs the same filtering and processing as discoverPodTargets for pods
+// already matched by the positive selector
+func discoverFromPods(pods []*corev1.Pod, config DiscoveryConfig) map[string]*Target {
+	sd := &ServiceDiscoveryImpl{targets: make(map[string]*Target)}
+	for _, pod := range filterExcludedPods(pods, config) {
+		sd.processPodTarget(pod, config, make(map[string]bool))
+	}
+	return sd.targets
+}
+
+func TestExcludePodNames_ExactAndRegex(t *testing.T) {
+	labels := map[string]string{"app": "web"}
+	pods := []*corev1.Pod{
+		newLabeledPod("web-0", labels),
+		newLabeledPod("web-canary", labels),
+		newLabeledPod("web-test-7f9c", labels),
+	}
+
+	config := newTestPodConfig(false)
+	config.ExcludePodNames = mustCompileNamePatterns(t, "web-canary", "web-test-.*")
+
+	targets := discoverFromPods(pods, config)
+	if len(targets) != 1 {
+		t.Fatalf("expected 1 target, got %d", len(targets))
+	}
+	for _, target := range targets {
+		if target.ID != "app/default/web-0/8080-metrics" {
+			t.Errorf("unexpected target %s", target.ID)
+		}
+	}
+	if reason := podExclusionReason(pods[2], config); reason != `name matches excludePodNames entry "web-test-.*"` {
+		t.Errorf("unexpected exclusion reason %q", reason)
+	}
+}
+
+func TestExcludeSelector_MatchLabelsAndExpressions(t *testing.T) {
+	pods := []*corev1.Pod{
+		newLabeledPod("web-0", map[string]string{"app": "web", "track": "stable"}),
+		newLabeledPod("web-1", map[string]string{"app": "web", "track": "canary"}),
+		newLabeledPod("web-2", map[string]string{"app": "web", "track": "stable", "test-data": "true"}),
+	}
+
+	config := newTestPodConfig(false)
+	config.ExcludeSelector = &selector.Selector{MatchLabels: map[string]string{"track": "canary"}}
+	if targets := discoverFromPods(pods, config); len(targets) != 2 {
+		t.Fatalf("matchLabels: expected 2 targets, got %d", len(targets))
+	}
+
+	config.ExcludeSelector = &selector.Selector{
+		MatchExpressions: []selector.Requirement{{Key: "test-data", Operator: selector.OpExists}},
+	}
+	targets := discoverFromPods(pods, config)
+	if len(targets) != 2 {
+		t.Fatalf("matchExpressions: expected 2 targets, got %d", len(targets))
+	}
+	for id := range targets {
+		if id == "app/default/web-2/8080-metrics" {
+			t.Errorf("excluded pod web-2 became a target")
+		}
+	}
+}
+
+func TestExcludeSelector_EmptyMatchesNothing(t *testing.T) {
+	pod := newLabeledPod("web-0", map[string]string{"app": "web"})
+	if reason := podExclusionReason(pod, newTestPodConfig(false)); reason != "" {
+		t.Fatalf("expected no exclusion without exclude config, got %q", reason)
+	}
+}
+
+func TestExcludeServiceNames(t *testing.T) {
+	services := []*corev1.Service{
+		{ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "default"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "api-canary", Namespace: "default"}},
+	}
+	config := DiscoveryConfig{TargetName: "api", ExcludeServiceNames: mustCompileNamePatterns(t, ".*-canary")}
+
+	kept := filterExcludedServices(services, config)
+	if len(kept) != 1 || kept[0].Name != "api" {
+		t.Fatalf("expected only service api to be kept, got %d", len(kept))
+	}
+}
+
+func TestParseDiscoveryConfig_Exclusions(t *testing.T) {
+	sd := &ServiceDiscoveryImpl{}
+	cfg, err := sd.parseDiscoveryConfig(map[string]interface{}{
+		"targetName":          "app",
+		"type":                "PodMonitor",
+		"excludePodNames":     []interface{}{"canary-0", "test-.*"},
+		"excludeServiceNames": []interface{}{"svc-canary"},
+		"excludeSelector": map[string]interface{}{
+			"matchLabels": map[string]interface{}{"track": "canary"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cfg.ExcludePodNames) != 2 || len(cfg.ExcludeServiceNames) != 1 || cfg.ExcludeSelector == nil {
+		t.Fatalf("exclusions not parsed: %+v", cfg)
+	}
+}
+
+func TestParseDiscoveryConfig_InvalidExcludePatternFailsTarget(t *testing.T) {
+	sd := &ServiceDiscoveryImpl{}
+	_, err := sd.parseDiscoveryConfig(map[string]interface{}{
+		"targetName":      "app",
+		"type":            "PodMonitor",
+		"excludePodNames": []interface{}{"canary-0", "test-(["},
+	})
+	if err == nil || !strings.Contains(err.Error(), `excludePodNames[1]: "test-([" is not a valid regex`) {
+		t.Fatalf("expected the invalid pattern to fail the target, got %v", err)
+	}
+}
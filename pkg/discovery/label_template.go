@@ -0,0 +1,93 @@
+package discovery
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"text/template"
+
+	"open-agent/tools/util/logutil"
+)
+
+// labelTemplateFields maps the fields a labelTemplates value can use to discovery meta labels.
+// Address and Port come from __address__ and are added by labelTemplateData.
+var labelTemplateFields = map[string][]string{
+	"Namespace":   {"__meta_kubernetes_namespace"},
+	"PodName":     {"__meta_kubernetes_pod_name"},
+	"ServiceName": {"__meta_kubernetes_service_name"},
+	"NodeName":    {"__meta_kubernetes_pod_node_name", "__meta_kubernetes_endpoint_node_name"},
+	"TargetName":  {"job"},
+}
+
+// labelTemplateData returns the fields of the discovered object for a target's meta labels.
+// Fields the object does not have are left out, so templates using them fail instead of rendering empty.
+func labelTemplateData(metaLabels map[string]string) map[string]string {
+	data := make(map[string]string, len(labelTemplateFields)+2)
+	for field, labels := range labelTemplateFields {
+		for _, label := range labels {
+			if value := metaLabels[label]; value != "" {
+				data[field] = value
+				break
+			}
+		}
+	}
+	if address := metaLabels["__address__"]; address != "" {
+		if host, port, err := net.SplitHostPort(address); err == nil {
+			data["Address"] = host
+			data["Port"] = port
+		} else {
+			data["Address"] = address
+		}
+	}
+	return data
+}
+
+// renderLabelTemplate renders one labelTemplates value such as {{.PodName}}.{{.Namespace}}
+func renderLabelTemplate(name, text string, data map[string]string) (string, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid labelTemplates.%s template %q: %v", name, text, err)
+	}
+	var rendered strings.Builder
+	if err := tmpl.Execute(&rendered, data); err != nil {
+		return "", fmt.Errorf("cannot resolve labelTemplates.%s template %q: %v", name, text, err)
+	}
+	return rendered.String(), nil
+}
+
+// applyLabelTemplates sets the target's labelTemplates labels, rendered with the discovered object's fields.
+// Templates apply after relabeling; a label whose template fails keeps its default value, and the
+// error is logged once per target config.
+func (sd *ServiceDiscoveryImpl) applyLabelTemplates(config DiscoveryConfig, labels, metaLabels map[string]string) {
+	if len(config.LabelTemplates) == 0 {
+		return
+	}
+	names := make([]string, 0, len(config.LabelTemplates))
+	for name := range config.LabelTemplates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	data := labelTemplateData(metaLabels)
+	var errs []string
+	for _, name := range names {
+		value, err := renderLabelTemplate(name, config.LabelTemplates[name], data)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		labels[name] = value
+	}
+	if len(errs) == 0 {
+		return
+	}
+	message := strings.Join(errs, "; ")
+	if sd.labelTemplateErrors[config.TargetName] != message {
+		if sd.labelTemplateErrors == nil {
+			sd.labelTemplateErrors = make(map[string]string)
+		}
+		sd.labelTemplateErrors[config.TargetName] = message
+		logutil.Printf("WARN", "[DISCOVERY] %s %s: %s, using the default label values", config.Type, config.TargetName, message)
+	}
+}
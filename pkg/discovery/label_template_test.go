@@ -0,0 +1,113 @@
+package discovery
+
+import (
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestLabelTemplates_Pod(t *testing.T) {
+	pod := newTestPod("api-0", "10.0.0.1", true)
+	pod.Spec.NodeName = "node-a"
+	config := newTestPodConfig(false)
+	config.LabelTemplates = map[string]string{
+		"instance": "{{.PodName}}.{{.Namespace}}",
+		"endpoint": "{{.Address}}/{{.Port}}@{{.NodeName}}",
+	}
+
+	target := processSinglePod(pod, config)
+	if target == nil {
+		t.Fatal("expected a target")
+	}
+	if got := target.Labels["instance"]; got != "api-0.default" {
+		t.Errorf("instance = %q, want api-0.default", got)
+	}
+	if got := target.Labels["endpoint"]; got != "10.0.0.1/8080@node-a" {
+		t.Errorf("endpoint = %q, want 10.0.0.1/8080@node-a", got)
+	}
+	if target.URL != "http://10.0.0.1:8080/metrics" {
+		t.Errorf("templates must not change the scrape URL, got %s", target.URL)
+	}
+}
+
+func TestLabelTemplates_Service(t *testing.T) {
+	nodeName := "node-b"
+	provider := &fakeProvider{endpoints: map[string]*corev1.Endpoints{
+		"monitoring/exporter": {
+			Subsets: []corev1.EndpointSubset{{
+				Addresses: []corev1.EndpointAddress{{
+					IP:        "10.0.1.5",
+					NodeName:  &nodeName,
+					TargetRef: &corev1.ObjectReference{Kind: "Pod", Name: "exporter-7d9f"},
+				}},
+				Ports: []corev1.EndpointPort{{Name: "metrics", Port: 9100}},
+			}},
+		},
+	}}
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "exporter", Namespace: "monitoring"},
+		Spec: corev1.ServiceSpec{Ports: []corev1.ServicePort{
+			{Name: "metrics", Port: 9100, TargetPort: intstr.FromInt(9100)},
+		}},
+	}
+	config := DiscoveryConfig{
+		TargetName: "exporters",
+		Type:       "ServiceMonitor",
+		Enabled:    true,
+		Endpoints:  []EndpointConfig{{Port: "metrics", Path: "/metrics"}},
+		LabelTemplates: map[string]string{
+			"instance": "{{.ServiceName}}.{{.Namespace}}:{{.Port}}",
+			"pod":      "{{.PodName}}",
+			"node":     "{{.NodeName}}",
+		},
+	}
+	sd := &ServiceDiscoveryImpl{k8sClient: provider, targets: make(map[string]*Target)}
+	sd.processServiceTarget(service, config, make(map[string]bool))
+
+	if len(sd.targets) != 1 {
+		t.Fatalf("expected 1 target, got %d", len(sd.targets))
+	}
+	for _, target := range sd.targets {
+		want := map[string]string{"instance": "exporter.monitoring:9100", "pod": "exporter-7d9f", "node": "node-b", "job": "exporters"}
+		for name, value := range want {
+			if target.Labels[name] != value {
+				t.Errorf("%s = %q, want %q", name, target.Labels[name], value)
+			}
+		}
+	}
+}
+
+func TestLabelTemplates_InvalidTemplateKeepsDefaults(t *testing.T) {
+	config := newTestPodConfig(false)
+	config.LabelTemplates = map[string]string{
+		"instance": "{{.PodName",       // does not parse
+		"service":  "{{.ServiceName}}", // a PodMonitor has no service
+		"pod":      "{{.PodName}}",
+	}
+	sd := &ServiceDiscoveryImpl{targets: make(map[string]*Target)}
+	for i := 0; i < 2; i++ {
+		sd.processPodTarget(newTestPod("api-0", "10.0.0.1", true), config, make(map[string]bool))
+	}
+
+	if len(sd.targets) != 1 {
+		t.Fatalf("a template error must not drop the target, got %d targets", len(sd.targets))
+	}
+	for _, target := range sd.targets {
+		if got := target.Labels["instance"]; got != "10.0.0.1:8080" {
+			t.Errorf("instance = %q, want the default address", got)
+		}
+		if _, ok := target.Labels["service"]; ok {
+			t.Errorf("expected no service label, got %q", target.Labels["service"])
+		}
+		if got := target.Labels["pod"]; got != "api-0" {
+			t.Errorf("valid templates must still apply, pod = %q", got)
+		}
+	}
+	message := sd.labelTemplateErrors["app"]
+	if len(sd.labelTemplateErrors) != 1 || !strings.Contains(message, "labelTemplates.instance") || !strings.Contains(message, "ServiceName") {
+		t.Errorf("expected both template errors recorded once for the target config, got %v", sd.labelTemplateErrors)
+	}
+}
@@ -0,0 +1,147 @@
+package discovery
+
+import (
+	"sync"
+	"time"
+
+	configPkg "open-agent/pkg/config"
+	"open-agent/tools/util/logutil"
+)
+
+const (
+	// DefaultMaxPendingTargets is the default number of pending targets kept per target config
+	DefaultMaxPendingTargets = 1000
+	// DefaultPendingTargetTTL is how long a target may stay pending before it is no longer kept
+	DefaultPendingTargetTTL = 10 * time.Minute
+)
+
+// maxPendingTargets returns the per target config pending target cap (whatap.conf openagent_max_pending_targets)
+func maxPendingTargets() int {
+	limit := configPkg.GetIntWithDefault("openagent_max_pending_targets", DefaultMaxPendingTargets)
+	if limit < 0 {
+		return DefaultMaxPendingTargets
+	}
+	return limit
+}
+
+// pendingTargetTTL returns how long a target may stay pending (whatap.conf openagent_pending_target_ttl_minutes)
+func pendingTargetTTL() time.Duration {
+	minutes := configPkg.GetIntWithDefault("openagent_pending_target_ttl_minutes", int(DefaultPendingTargetTTL/time.Minute))
+	if minutes <= 0 {
+		return DefaultPendingTargetTTL
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// pendingEntry tracks a target seen pending, so it can expire even though it is rediscovered every cycle
+type pendingEntry struct {
+	since    time.Time // first seen pending
+	lastSeen time.Time
+}
+
+// pendingState is the pending target bookkeeping of ServiceDiscoveryImpl
+type pendingState struct {
+	mu      sync.Mutex
+	entries map[string]pendingEntry
+	// kept and dropped are per target config counts for the current discovery of that config
+	kept    map[string]int
+	dropped map[string]int
+	// capped are target configs last logged as dropping pending targets
+	capped map[string]bool
+	// droppedTotal counts pending targets not kept, per target config
+	droppedTotal map[string]int64
+}
+
+// beginPending resets the per-cycle pending counts of a target config before it is discovered
+func (sd *ServiceDiscoveryImpl) beginPending(targetName string) {
+	sd.pending.mu.Lock()
+	defer sd.pending.mu.Unlock()
+	sd.pending.init()
+	sd.pending.kept[targetName] = 0
+	sd.pending.dropped[targetName] = 0
+}
+
+func (p *pendingState) init() {
+	if p.entries == nil {
+		p.entries = make(map[string]pendingEntry)
+		p.kept = make(map[string]int)
+		p.dropped = make(map[string]int)
+		p.capped = make(map[string]bool)
+		p.droppedTotal = make(map[string]int64)
+	}
+}
+
+// admitTarget reports whether a discovered target is kept. Ready targets always are; pending targets are
+// not kept when trackPendingTargets is false, once the config has maxPendingTargets of them, or once they
+// have been pending longer than the pending TTL. Targets that are not kept are left out of the active
+// target IDs, so a previously stored one is removed as stale.
+func (sd *ServiceDiscoveryImpl) admitTarget(target *Target, config DiscoveryConfig, now time.Time) bool {
+	sd.pending.mu.Lock()
+	defer sd.pending.mu.Unlock()
+	sd.pending.init()
+
+	if target.State != TargetStatePending {
+		delete(sd.pending.entries, target.ID)
+		return true
+	}
+	if config.IgnorePendingTargets {
+		return false
+	}
+
+	entry, seen := sd.pending.entries[target.ID]
+	if !seen {
+		entry.since = now
+	}
+	entry.lastSeen = now
+	sd.pending.entries[target.ID] = entry
+
+	if now.Sub(entry.since) > pendingTargetTTL() || sd.pending.kept[config.TargetName] >= maxPendingTargets() {
+		sd.pending.dropped[config.TargetName]++
+		sd.pending.droppedTotal[config.TargetName]++
+		return false
+	}
+	sd.pending.kept[config.TargetName]++
+	return true
+}
+
+// endPending logs when a target config starts or stops dropping pending targets
+func (sd *ServiceDiscoveryImpl) endPending(config DiscoveryConfig) {
+	sd.pending.mu.Lock()
+	defer sd.pending.mu.Unlock()
+	if config.IgnorePendingTargets || sd.pending.entries == nil {
+		return
+	}
+	dropped := sd.pending.dropped[config.TargetName]
+	switch {
+	case dropped > 0 && !sd.pending.capped[config.TargetName]:
+		sd.pending.capped[config.TargetName] = true
+		logutil.Printf("WARN", "[DISCOVERY] Target %s: not keeping %d pending targets (limit %d per target, pending for more than %v expire)",
+			config.TargetName, dropped, maxPendingTargets(), pendingTargetTTL())
+	case dropped == 0 && sd.pending.capped[config.TargetName]:
+		delete(sd.pending.capped, config.TargetName)
+		logutil.Printf("INFO", "[DISCOVERY] Target %s: all pending targets are kept again", config.TargetName)
+	}
+}
+
+// prunePending forgets pending targets that have not been seen for a TTL, e.g. deleted pods
+func (sd *ServiceDiscoveryImpl) prunePending(now time.Time) {
+	sd.pending.mu.Lock()
+	defer sd.pending.mu.Unlock()
+	ttl := pendingTargetTTL()
+	for id, entry := range sd.pending.entries {
+		if now.Sub(entry.lastSeen) > ttl {
+			delete(sd.pending.entries, id)
+		}
+	}
+}
+
+// PendingTargetsDropped returns the number of pending targets not kept so far, per target config
+func (sd *ServiceDiscoveryImpl) PendingTargetsDropped() map[string]int64 {
+	sd.pending.mu.Lock()
+	defer sd.pending.mu.Unlock()
+	dropped := make(map[string]int64, len(sd.pending.droppedTotal))
+	for name, n := range sd.pending.droppedTotal {
+		dropped[name] = n
+	}
+	return dropped
+}
@@ -0,0 +1,126 @@
+package discovery
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// crashloopingPods returns n not-ready pods selected by newSelectorPodConfig, plus one ready pod
+func crashloopingPods(n int) map[string][]*corev1.Pod {
+	var pods []*corev1.Pod
+	for i := 0; i < n; i++ {
+		pod := newTestPod(fmt.Sprintf("api-crash-%d", i), fmt.Sprintf("10.0.1.%d", i), false)
+		pod.Labels = map[string]string{"app": "api"}
+		pods = append(pods, pod)
+	}
+	ready := newTestPod("api-ok", "10.0.2.1", true)
+	ready.Labels = map[string]string{"app": "api"}
+	return map[string][]*corev1.Pod{"default": append(pods, ready)}
+}
+
+// discoverPending runs one discovery of config and returns the stored targets by state
+func discoverPending(sd *ServiceDiscoveryImpl, config DiscoveryConfig) (ready, pending int) {
+	active := make(map[string]bool)
+	sd.beginPending(config.TargetName)
+	sd.discoverPodTargets(config, active)
+	sd.endPending(config)
+	sd.cleanupStaleTargets(active)
+	for _, target := range sd.targets {
+		switch target.State {
+		case TargetStateReady:
+			ready++
+		case TargetStatePending:
+			pending++
+		}
+	}
+	return ready, pending
+}
+
+func TestPendingTargets_Capped(t *testing.T) {
+	t.Setenv("openagent_max_pending_targets", "3")
+	sd := &ServiceDiscoveryImpl{k8sClient: &fakeProvider{pods: crashloopingPods(10)}, targets: make(map[string]*Target)}
+
+	ready, pending := discoverPending(sd, newSelectorPodConfig())
+	if ready != 1 || pending != 3 {
+		t.Fatalf("expected 1 ready and 3 pending targets, got %d and %d", ready, pending)
+	}
+	if dropped := sd.PendingTargetsDropped()["app"]; dropped != 7 {
+		t.Errorf("expected 7 dropped pending targets, got %d", dropped)
+	}
+
+	// The cap applies per cycle; the counter keeps growing
+	discoverPending(sd, newSelectorPodConfig())
+	if dropped := sd.PendingTargetsDropped()["app"]; dropped != 14 {
+		t.Errorf("expected 14 dropped pending targets after two cycles, got %d", dropped)
+	}
+}
+
+func TestPendingTargets_TrackingDisabled(t *testing.T) {
+	sd := &ServiceDiscoveryImpl{k8sClient: &fakeProvider{pods: crashloopingPods(10)}, targets: make(map[string]*Target)}
+	config := newSelectorPodConfig()
+	config.IgnorePendingTargets = true
+
+	ready, pending := discoverPending(sd, config)
+	if ready != 1 || pending != 0 {
+		t.Fatalf("expected only the ready target, got %d ready and %d pending", ready, pending)
+	}
+	if ready := sd.GetReadyTargets(); len(ready) != 1 || ready[0].ID != "app/default/api-ok/8080-metrics" {
+		t.Errorf("unexpected ready targets %v", ready)
+	}
+}
+
+func TestPendingTargets_Expire(t *testing.T) {
+	t.Setenv("openagent_pending_target_ttl_minutes", "10")
+	sd := &ServiceDiscoveryImpl{}
+	config := newTestPodConfig(false)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	pending := func() *Target { return &Target{ID: "app/default/crash/8080-metrics", State: TargetStatePending} }
+
+	if !sd.admitTarget(pending(), config, start) {
+		t.Fatal("expected a new pending target to be kept")
+	}
+	if !sd.admitTarget(pending(), config, start.Add(9*time.Minute)) {
+		t.Fatal("expected the pending target to be kept within the TTL")
+	}
+	if sd.admitTarget(pending(), config, start.Add(11*time.Minute)) {
+		t.Fatal("expected the pending target to expire after the TTL")
+	}
+
+	// Becoming ready resets the pending time
+	if !sd.admitTarget(&Target{ID: "app/default/crash/8080-metrics", State: TargetStateReady}, config, start.Add(12*time.Minute)) {
+		t.Fatal("expected a ready target to be kept")
+	}
+	if !sd.admitTarget(pending(), config, start.Add(13*time.Minute)) {
+		t.Fatal("expected a target pending again to be kept")
+	}
+
+	// Targets no longer seen are forgotten after the TTL
+	sd.prunePending(start.Add(24 * time.Minute))
+	if len(sd.pending.entries) != 0 {
+		t.Errorf("expected pruned pending entries, got %v", sd.pending.entries)
+	}
+}
+
+func TestParseDiscoveryConfig_TrackPendingTargets(t *testing.T) {
+	sd := &ServiceDiscoveryImpl{}
+	for value, ignore := range map[interface{}]bool{nil: false, true: false, false: true} {
+		raw := map[string]interface{}{
+			"targetName": "app",
+			"type":       "PodMonitor",
+			"endpoints":  []interface{}{map[string]interface{}{"port": "8080"}},
+		}
+		if value != nil {
+			raw["trackPendingTargets"] = value
+		}
+		cfg, err := sd.parseDiscoveryConfig(raw)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cfg.IgnorePendingTargets != ignore {
+			t.Errorf("trackPendingTargets %v: IgnorePendingTargets = %v, want %v", value, cfg.IgnorePendingTargets, ignore)
+		}
+	}
+}
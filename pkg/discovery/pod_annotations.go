@@ -0,0 +1,137 @@
+package discovery
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	configPkg "open-agent/pkg/config"
+	"open-agent/tools/util/logutil"
+)
+
+// Pod annotations that override the endpoint settings of a pod's targets when the PodMonitor sets
+// allowPodAnnotationsOverride: true
+const (
+	IntervalAnnotation = "openagent.whatap.io/interval"
+	PathAnnotation     = "openagent.whatap.io/path"
+	PortAnnotation     = "openagent.whatap.io/port"
+)
+
+// Sources of an effective endpoint setting, recorded in the settingSources target metadata
+const (
+	SettingSourceConfig     = "config"
+	SettingSourceAnnotation = "annotation"
+)
+
+// applyPodAnnotations returns the endpoint with the interval, path and port annotations of the pod
+// applied over it, and where each of the three settings comes from. An invalid annotation is ignored
+// in favor of the configured value; the problems of a pod are logged once, and again when they change.
+func (sd *ServiceDiscoveryImpl) applyPodAnnotations(pod *corev1.Pod, endpoint EndpointConfig, config DiscoveryConfig) (EndpointConfig, map[string]string) {
+	sources := map[string]string{
+		"interval": SettingSourceConfig,
+		"path":     SettingSourceConfig,
+		"port":     SettingSourceConfig,
+	}
+	var problems []string
+	if value, ok := pod.Annotations[IntervalAnnotation]; ok {
+		if err := checkIntervalAnnotation(value); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", IntervalAnnotation, err))
+		} else {
+			endpoint.Interval = value
+			sources["interval"] = SettingSourceAnnotation
+		}
+	}
+	if value, ok := pod.Annotations[PathAnnotation]; ok {
+		if err := checkPathAnnotation(value); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", PathAnnotation, err))
+		} else {
+			endpoint.Path = value
+			sources["path"] = SettingSourceAnnotation
+		}
+	}
+	if value, ok := pod.Annotations[PortAnnotation]; ok {
+		if n, err := strconv.Atoi(value); err != nil || n < 1 || n > 65535 {
+			problems = append(problems, fmt.Sprintf("%s: invalid port %q", PortAnnotation, value))
+		} else {
+			endpoint.Port = value
+			sources["port"] = SettingSourceAnnotation
+		}
+	}
+	sd.logAnnotationProblems(pod, config, problems)
+	return endpoint, sources
+}
+
+func checkIntervalAnnotation(value string) error {
+	var cm configPkg.ConfigManager
+	if seconds, err := cm.ParseInterval(value); err != nil || seconds <= 0 || value == "" {
+		return fmt.Errorf("invalid interval %q", value)
+	}
+	return nil
+}
+
+func checkPathAnnotation(value string) error {
+	if !strings.HasPrefix(value, "/") {
+		return fmt.Errorf("path %q does not start with /", value)
+	}
+	if strings.ContainsAny(value, "?#") || strings.IndexFunc(value, isSpace) >= 0 {
+		return fmt.Errorf("invalid path %q", value)
+	}
+	return nil
+}
+
+// logAnnotationProblems logs the invalid annotations of a pod when they differ from the last ones logged
+func (sd *ServiceDiscoveryImpl) logAnnotationProblems(pod *corev1.Pod, config DiscoveryConfig, problems []string) {
+	key := pod.Namespace + "/" + pod.Name
+	message := strings.Join(problems, "; ")
+	logged := sd.annotationErrors[config.TargetName]
+	if logged[key] == message {
+		return
+	}
+	if message == "" {
+		delete(logged, key)
+		return
+	}
+	if logged == nil {
+		if sd.annotationErrors == nil {
+			sd.annotationErrors = make(map[string]map[string]string)
+		}
+		logged = make(map[string]string)
+		sd.annotationErrors[config.TargetName] = logged
+	}
+	logged[key] = message
+	logutil.Printf("WARN", "[DISCOVERY] %s %s: ignoring annotations of pod %s: %s", config.Type, config.TargetName, key, message)
+}
+
+// pruneAnnotationProblems forgets the logged annotation problems of the pods a target no longer scrapes
+func (sd *ServiceDiscoveryImpl) pruneAnnotationProblems(config DiscoveryConfig, pods []*corev1.Pod) {
+	logged := sd.annotationErrors[config.TargetName]
+	if len(logged) == 0 {
+		return
+	}
+	current := make(map[string]bool, len(pods))
+	for _, pod := range pods {
+		current[pod.Namespace+"/"+pod.Name] = true
+	}
+	for key := range logged {
+		if !current[key] {
+			delete(logged, key)
+		}
+	}
+}
+
+// FormatSettingSources formats the settingSources metadata of a target, "-" when it has none
+func FormatSettingSources(target *Target) string {
+	sources, _ := target.Metadata["settingSources"].(map[string]string)
+	if len(sources) == 0 {
+		return "-"
+	}
+	parts := make([]string, 0, len(sources))
+	for setting, source := range sources {
+		parts = append(parts, setting+"="+source)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,141 @@
+package discovery
+
+import (
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func annotatedPod(annotations map[string]string) *corev1.Pod {
+	pod := newTestPod("app-0", "10.0.0.1", true)
+	pod.Annotations = annotations
+	return pod
+}
+
+func newOverrideConfig() DiscoveryConfig {
+	config := newTestPodConfig(false)
+	config.Endpoints[0].Interval = "60s"
+	config.AllowPodAnnotationsOverride = true
+	return config
+}
+
+func TestProcessPodTarget_AnnotationOverrides(t *testing.T) {
+	const configuredID = "app/default/app-0/8080-metrics"
+	tests := []struct {
+		name       string
+		annotation string
+		value      string
+		url        string
+		interval   string
+		overridden string
+	}{
+		{"interval", IntervalAnnotation, "15s", "http://10.0.0.1:8080/metrics", "15s", "interval"},
+		{"path", PathAnnotation, "/actuator/prometheus", "http://10.0.0.1:8080/actuator/prometheus", "60s", "path"},
+		{"port", PortAnnotation, "9090", "http://10.0.0.1:9090/metrics", "60s", "port"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := processSinglePod(annotatedPod(map[string]string{tt.annotation: tt.value}), newOverrideConfig())
+			if target == nil {
+				t.Fatal("expected a target")
+			}
+			if target.ID != configuredID {
+				t.Errorf("expected the ID of the configured endpoint, got %s", target.ID)
+			}
+			if target.URL != tt.url {
+				t.Errorf("expected URL %s, got %s", tt.url, target.URL)
+			}
+			if endpoint := target.Metadata["endpoint"].(EndpointConfig); endpoint.Interval != tt.interval {
+				t.Errorf("expected interval %s, got %s", tt.interval, endpoint.Interval)
+			}
+			sources := target.Metadata["settingSources"].(map[string]string)
+			for _, setting := range []string{"interval", "path", "port"} {
+				want := SettingSourceConfig
+				if setting == tt.overridden {
+					want = SettingSourceAnnotation
+				}
+				if sources[setting] != want {
+					t.Errorf("expected %s from %s, got %v", setting, want, sources)
+				}
+			}
+		})
+	}
+}
+
+func TestProcessPodTarget_AnnotationsIgnoredByDefault(t *testing.T) {
+	pod := annotatedPod(map[string]string{IntervalAnnotation: "15s", PathAnnotation: "/other", PortAnnotation: "9090"})
+	config := newOverrideConfig()
+	config.AllowPodAnnotationsOverride = false
+
+	target := processSinglePod(pod, config)
+	if target.URL != "http://10.0.0.1:8080/metrics" {
+		t.Errorf("expected the configured URL, got %s", target.URL)
+	}
+	if endpoint := target.Metadata["endpoint"].(EndpointConfig); endpoint.Interval != "60s" {
+		t.Errorf("expected the configured interval, got %s", endpoint.Interval)
+	}
+	if _, ok := target.Metadata["settingSources"]; ok {
+		t.Errorf("expected no setting sources without allowPodAnnotationsOverride")
+	}
+	if FormatSettingSources(target) != "-" {
+		t.Errorf("expected no setting sources to format, got %s", FormatSettingSources(target))
+	}
+}
+
+func TestProcessPodTarget_InvalidAnnotationsFallBack(t *testing.T) {
+	sd := &ServiceDiscoveryImpl{targets: make(map[string]*Target)}
+	config := newOverrideConfig()
+	pod := annotatedPod(map[string]string{IntervalAnnotation: "often", PathAnnotation: "metrics", PortAnnotation: "http"})
+
+	for i := 0; i < 2; i++ {
+		sd.processPodTarget(pod, config, make(map[string]bool))
+	}
+	target := sd.targets["app/default/app-0/8080-metrics"]
+	if target == nil || target.URL != "http://10.0.0.1:8080/metrics" {
+		t.Fatalf("expected the configured URL, got %+v", target)
+	}
+	if endpoint := target.Metadata["endpoint"].(EndpointConfig); endpoint.Interval != "60s" {
+		t.Errorf("expected the configured interval, got %s", endpoint.Interval)
+	}
+	if got := FormatSettingSources(target); got != "interval=config,path=config,port=config" {
+		t.Errorf("unexpected setting sources %s", got)
+	}
+
+	// The problems are remembered per pod so they are logged once
+	logged := sd.annotationErrors["app"]["default/app-0"]
+	for _, annotation := range []string{IntervalAnnotation, PathAnnotation, PortAnnotation} {
+		if !strings.Contains(logged, annotation) {
+			t.Errorf("expected %s in the logged problems, got %q", annotation, logged)
+		}
+	}
+
+	// Fixed annotations clear the problems, as do pods that are gone
+	sd.processPodTarget(annotatedPod(map[string]string{IntervalAnnotation: "15s"}), config, make(map[string]bool))
+	if _, ok := sd.annotationErrors["app"]["default/app-0"]; ok {
+		t.Errorf("expected the problems to be cleared once the annotations are valid")
+	}
+	sd.processPodTarget(pod, config, make(map[string]bool))
+	sd.pruneAnnotationProblems(config, nil)
+	if len(sd.annotationErrors["app"]) != 0 {
+		t.Errorf("expected the problems of removed pods to be pruned, got %v", sd.annotationErrors["app"])
+	}
+}
+
+func TestParseDiscoveryConfig_AllowPodAnnotationsOverridePodMonitorOnly(t *testing.T) {
+	sd := &ServiceDiscoveryImpl{}
+	for typ, want := range map[string]bool{"PodMonitor": true, "ServiceMonitor": false} {
+		cfg, err := sd.parseDiscoveryConfig(map[string]interface{}{
+			"targetName":                  "app",
+			"type":                        typ,
+			"allowPodAnnotationsOverride": true,
+			"endpoints":                   []interface{}{map[string]interface{}{"port": "8080"}},
+		})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", typ, err)
+		}
+		if cfg.AllowPodAnnotationsOverride != want {
+			t.Errorf("%s: AllowPodAnnotationsOverride = %v, want %v", typ, cfg.AllowPodAnnotationsOverride, want)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package discovery
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// GenerationLabel is the target label addGenerationLabel sets to the pod's container restart count
+const GenerationLabel = "generation"
+
+// PodGeneration identifies one run of a pod target's containers. It is kept in the target's
+// "podGeneration" metadata and changes when a container restarts or the pod is recreated under the
+// same name, both of which reset the exporter's counters.
+type PodGeneration struct {
+	UID          types.UID
+	RestartCount int32
+}
+
+// podGeneration returns the pod's UID and the sum of its container restart counts
+func podGeneration(pod *corev1.Pod) PodGeneration {
+	generation := PodGeneration{UID: pod.UID}
+	for _, status := range pod.Status.ContainerStatuses {
+		generation.RestartCount += status.RestartCount
+	}
+	return generation
+}
+
+// PodGeneration returns the generation of a pod target's pod, or false for other targets
+func (t *Target) PodGeneration() (PodGeneration, bool) {
+	generation, ok := t.Metadata["podGeneration"].(PodGeneration)
+	return generation, ok
+}
+
+// RestartedSince reports whether the pod restarted after the old generation
+func (g PodGeneration) RestartedSince(old PodGeneration) bool {
+	return g.UID != old.UID || g.RestartCount > old.RestartCount
+}
@@ -0,0 +1,68 @@
+package discovery
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"open-agent/pkg/k8s"
+)
+
+func TestProcessPodTarget_PodGeneration(t *testing.T) {
+	pod := newTestPod("app-0", "10.0.0.1", true)
+	pod.UID = "uid-1"
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{{Name: "app", RestartCount: 2}, {Name: "sidecar", RestartCount: 1}}
+	// Discovery reads pods from the informer cache
+	pod = k8s.CachedPod(pod)
+
+	target := processSinglePod(pod, newTestPodConfig(false))
+	generation, ok := target.PodGeneration()
+	if !ok || generation != (PodGeneration{UID: "uid-1", RestartCount: 3}) {
+		t.Fatalf("unexpected pod generation %+v (%v)", generation, ok)
+	}
+	if _, ok := target.Labels[GenerationLabel]; ok {
+		t.Errorf("expected no generation label without addGenerationLabel")
+	}
+
+	config := newTestPodConfig(false)
+	config.AddGenerationLabel = true
+	if target := processSinglePod(pod, config); target.Labels[GenerationLabel] != "3" {
+		t.Errorf("expected generation=3, got labels %v", target.Labels)
+	}
+}
+
+func TestPodGeneration_RestartedSince(t *testing.T) {
+	old := PodGeneration{UID: "uid-1", RestartCount: 3}
+	tests := []struct {
+		name    string
+		current PodGeneration
+		want    bool
+	}{
+		{"unchanged", PodGeneration{UID: "uid-1", RestartCount: 3}, false},
+		{"container restarted", PodGeneration{UID: "uid-1", RestartCount: 4}, true},
+		{"pod recreated", PodGeneration{UID: "uid-2", RestartCount: 0}, true},
+	}
+	for _, tt := range tests {
+		if got := tt.current.RestartedSince(old); got != tt.want {
+			t.Errorf("%s: RestartedSince = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseDiscoveryConfig_AddGenerationLabelPodOnly(t *testing.T) {
+	sd := &ServiceDiscoveryImpl{}
+	for targetType, want := range map[string]bool{"PodMonitor": true, "ServiceMonitor": false} {
+		cfg, err := sd.parseDiscoveryConfig(map[string]interface{}{
+			"targetName":         "app",
+			"type":               targetType,
+			"addGenerationLabel": true,
+			"selector":           map[string]interface{}{"matchLabels": map[string]interface{}{"app": "app"}},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cfg.AddGenerationLabel != want {
+			t.Errorf("%s: AddGenerationLabel = %v, want %v", targetType, cfg.AddGenerationLabel, want)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package discovery
+
+import (
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"open-agent/pkg/k8s"
+	"open-agent/tools/util/logutil"
+)
+
+// resolvePodPort returns the port a PodMonitor endpoint scrapes on the pod: a number as is, and a name
+// as the container port declared under it, by a container or a sidecar init container. A name the pod
+// does not declare is returned as is, so the target is marked invalid with the name in the error.
+func resolvePodPort(pod *corev1.Pod, port string) (string, *k8s.PodPort) {
+	if _, err := strconv.Atoi(port); err == nil {
+		return port, nil
+	}
+	p, ok := k8s.FindPodPort(pod, port)
+	if !ok {
+		return port, nil
+	}
+	if p.Sidecar {
+		logutil.Debugf("DISCOVERY", "Resolved port %s of pod %s/%s to %d on sidecar init container %s", port, pod.Namespace, pod.Name, p.Port, p.Container)
+	} else {
+		logutil.Debugf("DISCOVERY", "Resolved port %s of pod %s/%s to %d on container %s", port, pod.Namespace, pod.Name, p.Port, p.Container)
+	}
+	return strconv.Itoa(int(p.Port)), &p
+}
@@ -0,0 +1,67 @@
+package discovery
+
+import (
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"open-agent/pkg/model"
+)
+
+// sidecarMetricsPod exposes its metrics port only on an istio-proxy sidecar init container
+func sidecarMetricsPod() *corev1.Pod {
+	always := corev1.ContainerRestartPolicyAlways
+	pod := newTestPod("api-0", "10.0.0.1", true)
+	pod.Spec.Containers = []corev1.Container{{Name: "api", Ports: []corev1.ContainerPort{{Name: "http", ContainerPort: 8080}}}}
+	pod.Spec.InitContainers = []corev1.Container{{Name: "istio-proxy", RestartPolicy: &always,
+		Ports: []corev1.ContainerPort{{Name: "http-envoy-prom", ContainerPort: 15090}}}}
+	return pod
+}
+
+func TestProcessPodTarget_NamedPortOnSidecar(t *testing.T) {
+	config := newTestPodConfig(false)
+	config.Endpoints = []EndpointConfig{{Port: "http-envoy-prom", Path: "/stats/prometheus"}}
+
+	target := processSinglePod(sidecarMetricsPod(), config)
+	if target == nil {
+		t.Fatal("expected a target")
+	}
+	if target.State != TargetStateReady || target.URL != "http://10.0.0.1:15090/stats/prometheus" {
+		t.Errorf("got %s target %s (%s), want the sidecar port 15090", target.State, target.URL, target.InvalidReason)
+	}
+	// The ID keeps the configured port name
+	if !strings.Contains(target.ID, "/http-envoy-prom") {
+		t.Errorf("expected the port name in the target ID, got %s", target.ID)
+	}
+}
+
+func TestProcessPodTarget_NamedPortOnContainer(t *testing.T) {
+	config := newTestPodConfig(false)
+	config.Endpoints = []EndpointConfig{{Port: "http", Path: "/metrics"}}
+	config.RelabelConfigs = model.RelabelConfigs{{
+		SourceLabels: []string{"__meta_kubernetes_pod_container_name", "__meta_kubernetes_pod_container_init"},
+		Separator:    "/",
+		Regex:        "(.+)",
+		TargetLabel:  "container",
+		Action:       "replace",
+	}}
+
+	target := processSinglePod(sidecarMetricsPod(), config)
+	if target == nil || target.URL != "http://10.0.0.1:8080/metrics" {
+		t.Fatalf("expected the container port 8080, got %+v", target)
+	}
+	if target.Labels["container"] != "api/false" {
+		t.Errorf("container meta labels = %q, want api/false", target.Labels["container"])
+	}
+}
+
+func TestProcessPodTarget_UnknownNamedPortIsInvalid(t *testing.T) {
+	config := newTestPodConfig(false)
+	config.Endpoints = []EndpointConfig{{Port: "metrics", Path: "/metrics"}}
+
+	target := processSinglePod(sidecarMetricsPod(), config)
+	if target == nil || target.State != TargetStateInvalid || !strings.Contains(target.InvalidReason, `invalid port "metrics"`) {
+		t.Fatalf("expected an invalid target naming the port, got %+v", target)
+	}
+}
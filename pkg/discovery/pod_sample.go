@@ -0,0 +1,69 @@
+package discovery
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// SampledLabel is set to "true" on the targets of a PodMonitor that scrapes a sample of its pods
+// (sampleRate or maxPods)
+const SampledLabel = "sampled"
+
+// samplesPods reports whether the target scrapes only a sample of the pods it matches
+func (c DiscoveryConfig) samplesPods() bool {
+	return c.SampleRate > 0 || c.MaxPods > 0
+}
+
+// samplePods returns the pods a sampling PodMonitor scrapes: those whose hash falls below sampleRate,
+// then at most maxPods of them with the lowest hashes.
+//
+// The hash of a pod depends only on the target name and the pod UID, so the subset is the same on every
+// discovery cycle and only changes when pods come and go: raising the rate adds pods to the subset and
+// lowering it removes pods, without replacing the others. A new pod displaces a sampled pod under
+// maxPods only when its hash is lower.
+//
+// The sample is meant for large deployments of identical pods. Per-pod series of the sampled pods are
+// exact, but sums and counts over the target cover only the sample: scale them by the sampled fraction
+// (about sampleRate, or maxPods divided by the matching pods) to estimate the whole deployment. That
+// estimate is only as good as the pods are alike, since the hash picks pods regardless of their node,
+// zone or load; with maxPods the sampled fraction also shrinks as the deployment grows.
+func samplePods(pods []*corev1.Pod, config DiscoveryConfig) []*corev1.Pod {
+	if !config.samplesPods() {
+		return pods
+	}
+	type hashedPod struct {
+		pod  *corev1.Pod
+		hash float64
+	}
+	sampled := make([]hashedPod, 0, len(pods))
+	for _, pod := range pods {
+		hash := podSampleHash(config.TargetName, pod)
+		if config.SampleRate > 0 && hash >= config.SampleRate {
+			continue
+		}
+		sampled = append(sampled, hashedPod{pod: pod, hash: hash})
+	}
+	if config.MaxPods > 0 && len(sampled) > config.MaxPods {
+		sort.Slice(sampled, func(i, j int) bool { return sampled[i].hash < sampled[j].hash })
+		sampled = sampled[:config.MaxPods]
+	}
+
+	kept := make([]*corev1.Pod, 0, len(sampled))
+	for _, s := range sampled {
+		kept = append(kept, s.pod)
+	}
+	return kept
+}
+
+// podSampleHash maps a pod to [0, 1). Pods without a UID, which the API server always sets, hash by name.
+func podSampleHash(targetName string, pod *corev1.Pod) float64 {
+	key := string(pod.UID)
+	if key == "" {
+		key = pod.Namespace + "/" + pod.Name
+	}
+	sum := sha256.Sum256([]byte(targetName + "/" + key))
+	return float64(binary.BigEndian.Uint64(sum[:8])>>11) / (1 << 53)
+}
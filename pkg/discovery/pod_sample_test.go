@@ -0,0 +1,149 @@
+package discovery
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"open-agent/pkg/selector"
+)
+
+// nginxPods returns n ready pods with unique UIDs, starting at index from
+func nginxPods(from, n int) []*corev1.Pod {
+	pods := make([]*corev1.Pod, 0, n)
+	for i := from; i < from+n; i++ {
+		pod := labeledPod(fmt.Sprintf("nginx-%d", i), map[string]string{"app": "nginx"})
+		pod.UID = types.UID(fmt.Sprintf("6f1c0d2e-%08d", i))
+		pods = append(pods, pod)
+	}
+	return pods
+}
+
+func podNames(pods []*corev1.Pod) map[string]bool {
+	names := make(map[string]bool, len(pods))
+	for _, pod := range pods {
+		names[pod.Name] = true
+	}
+	return names
+}
+
+func TestSamplePods_SampleRate(t *testing.T) {
+	pods := nginxPods(0, 5000)
+	config := DiscoveryConfig{TargetName: "nginx", SampleRate: 0.1}
+
+	sampled := podNames(samplePods(pods, config))
+	if len(sampled) < 400 || len(sampled) > 600 {
+		t.Fatalf("expected about 500 of 5000 pods at a 0.1 rate, got %d", len(sampled))
+	}
+
+	// The subset does not depend on the order pods are listed in
+	shuffled := append([]*corev1.Pod(nil), pods...)
+	rand.New(rand.NewSource(1)).Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+	again := podNames(samplePods(shuffled, config))
+	if len(again) != len(sampled) {
+		t.Fatalf("expected the same subset, got %d pods instead of %d", len(again), len(sampled))
+	}
+	for name := range again {
+		if !sampled[name] {
+			t.Fatalf("pod %s joined the subset on the next cycle", name)
+		}
+	}
+
+	// Raising the rate keeps the sampled pods and adds others
+	config.SampleRate = 0.2
+	raised := podNames(samplePods(pods, config))
+	for name := range sampled {
+		if !raised[name] {
+			t.Errorf("pod %s left the subset when the rate was raised", name)
+		}
+	}
+	if len(raised) < 2*len(sampled)*8/10 {
+		t.Errorf("expected about twice the pods at a 0.2 rate, got %d (was %d)", len(raised), len(sampled))
+	}
+
+	// Another target samples its pods independently
+	other := podNames(samplePods(pods, DiscoveryConfig{TargetName: "nginx-canary", SampleRate: 0.1}))
+	overlap := 0
+	for name := range other {
+		if sampled[name] {
+			overlap++
+		}
+	}
+	if overlap > len(other)/2 {
+		t.Errorf("expected independent subsets, %d of %d pods overlap", overlap, len(other))
+	}
+}
+
+func TestSamplePods_MaxPods(t *testing.T) {
+	pods := nginxPods(0, 1000)
+	config := DiscoveryConfig{TargetName: "nginx", MaxPods: 25}
+
+	sampled := podNames(samplePods(pods, config))
+	if len(sampled) != 25 {
+		t.Fatalf("expected 25 pods, got %d", len(sampled))
+	}
+
+	// Scaling up only replaces sampled pods by new pods, never by pods that were already there
+	scaled := podNames(samplePods(append(pods, nginxPods(1000, 1000)...), config))
+	if len(scaled) != 25 {
+		t.Fatalf("expected 25 pods after scaling up, got %d", len(scaled))
+	}
+	replaced := 0
+	for name := range scaled {
+		if !sampled[name] {
+			var index int
+			fmt.Sscanf(name, "nginx-%d", &index)
+			if index < 1000 {
+				t.Errorf("existing pod %s replaced a sampled pod", name)
+			}
+			replaced++
+		}
+	}
+	if replaced == 25 {
+		t.Errorf("expected some of the sampled pods kept after scaling up")
+	}
+
+	// Fewer matching pods than maxPods are all scraped
+	if got := samplePods(pods[:10], config); len(got) != 10 {
+		t.Errorf("expected all 10 pods, got %d", len(got))
+	}
+	// Without sampleRate or maxPods every pod is scraped
+	if got := samplePods(pods, DiscoveryConfig{TargetName: "nginx"}); len(got) != len(pods) {
+		t.Errorf("expected every pod, got %d", len(got))
+	}
+}
+
+func TestDiscoverPodTargets_SampledAcrossNamespaces(t *testing.T) {
+	provider := &fakeProvider{pods: map[string][]*corev1.Pod{}}
+	for i, pod := range nginxPods(0, 300) {
+		pod.Namespace = fmt.Sprintf("web-%d", i%3)
+		provider.pods[pod.Namespace] = append(provider.pods[pod.Namespace], pod)
+	}
+	sd := &ServiceDiscoveryImpl{k8sClient: provider, targets: make(map[string]*Target)}
+	config := newTestPodConfig(false)
+	config.NamespaceSelector = &selector.NamespaceSelector{MatchNames: []string{"web-0", "web-1", "web-2"}}
+	config.Selector = &selector.Selector{MatchLabels: map[string]string{"app": "nginx"}}
+	config.MaxPods = 20
+
+	first := make(map[string]bool)
+	sd.discoverPodTargets(config, first)
+	if len(first) != 20 {
+		t.Fatalf("expected 20 targets across the namespaces, got %d", len(first))
+	}
+	for id := range first {
+		if sd.targets[id].Labels[SampledLabel] != "true" {
+			t.Errorf("target %s has no sampled label", id)
+		}
+	}
+
+	second := make(map[string]bool)
+	sd.discoverPodTargets(config, second)
+	for id := range second {
+		if !first[id] {
+			t.Errorf("target %s joined the sample on the next cycle", id)
+		}
+	}
+}
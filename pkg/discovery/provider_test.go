@@ -0,0 +1,182 @@
+package discovery
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"open-agent/pkg/k8s"
+	"open-agent/pkg/selector"
+)
+
+// fakeProvider serves pods and namespaces without the K8sClient singleton
+type fakeProvider struct {
+	k8s.NoopK8sProvider
+	pods       map[string][]*corev1.Pod
+	services   map[string][]*corev1.Service // by namespace
+	namespaces []*corev1.Namespace
+	endpoints  map[string]*corev1.Endpoints           // by namespace/name
+	zones      map[string]map[string]k8s.EndpointZone // by namespace/name, then address
+	// namespaceLookups counts GetNamespacesByNames calls
+	namespaceLookups int
+}
+
+func (f *fakeProvider) IsInitialized() bool { return true }
+
+func (f *fakeProvider) GetPodsByLabels(namespace string, labelSelector map[string]string) ([]*corev1.Pod, error) {
+	var pods []*corev1.Pod
+	for _, pod := range f.pods[namespace] {
+		if hasAllLabels(pod.Labels, labelSelector) {
+			pods = append(pods, pod)
+		}
+	}
+	return pods, nil
+}
+
+func (f *fakeProvider) GetServicesByLabels(namespace string, labelSelector map[string]string) ([]*corev1.Service, error) {
+	var services []*corev1.Service
+	for _, service := range f.services[namespace] {
+		if hasAllLabels(service.Labels, labelSelector) {
+			services = append(services, service)
+		}
+	}
+	return services, nil
+}
+
+func (f *fakeProvider) GetNamespacesByLabels(labelSelector map[string]string) ([]*corev1.Namespace, error) {
+	var namespaces []*corev1.Namespace
+	for _, ns := range f.namespaces {
+		if hasAllLabels(ns.Labels, labelSelector) {
+			namespaces = append(namespaces, ns)
+		}
+	}
+	return namespaces, nil
+}
+
+func (f *fakeProvider) GetNamespacesByNames(names []string) ([]*corev1.Namespace, error) {
+	f.namespaceLookups++
+	var namespaces []*corev1.Namespace
+	for _, ns := range f.namespaces {
+		for _, name := range names {
+			if ns.Name == name {
+				namespaces = append(namespaces, ns)
+			}
+		}
+	}
+	return namespaces, nil
+}
+
+func (f *fakeProvider) GetEndpointsForService(namespace, serviceName string) (*corev1.Endpoints, error) {
+	return f.endpoints[namespace+"/"+serviceName], nil
+}
+
+func (f *fakeProvider) GetEndpointZones(namespace, serviceName string) (map[string]k8s.EndpointZone, error) {
+	return f.zones[namespace+"/"+serviceName], nil
+}
+
+func hasAllLabels(labels, want map[string]string) bool {
+	for key, value := range want {
+		if labels[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+func newSelectorPodConfig() DiscoveryConfig {
+	config := newTestPodConfig(false)
+	config.NamespaceSelector = &selector.NamespaceSelector{MatchNames: []string{"default"}}
+	config.Selector = &selector.Selector{MatchLabels: map[string]string{"app": "api"}}
+	return config
+}
+
+func TestDiscoverPodTargets_WithProvider(t *testing.T) {
+	provider := &fakeProvider{pods: map[string][]*corev1.Pod{
+		"default": {labeledPod("api-0", map[string]string{"app": "api"}), labeledPod("api-1", map[string]string{"app": "api"})},
+	}}
+	sd := &ServiceDiscoveryImpl{k8sClient: provider, targets: make(map[string]*Target)}
+
+	active := make(map[string]bool)
+	sd.discoverPodTargets(newSelectorPodConfig(), active)
+	if len(sd.targets) != 2 || len(active) != 2 {
+		t.Fatalf("expected 2 targets, got %d (%d active)", len(sd.targets), len(active))
+	}
+}
+
+func TestDiscoverPodTargets_StandaloneSkipsOnce(t *testing.T) {
+	sd := &ServiceDiscoveryImpl{k8sClient: k8s.NewProvider(true), targets: make(map[string]*Target)}
+
+	for i := 0; i < 3; i++ {
+		sd.discoverPodTargets(newSelectorPodConfig(), make(map[string]bool))
+	}
+	if len(sd.targets) != 0 {
+		t.Fatalf("expected no targets in standalone mode, got %d", len(sd.targets))
+	}
+	if !sd.standaloneSkipped["app"] || len(sd.standaloneSkipped) != 1 {
+		t.Errorf("expected the skipped target to be recorded once, got %v", sd.standaloneSkipped)
+	}
+}
+
+func labeledPod(name string, labels map[string]string) *corev1.Pod {
+	pod := newTestPod(name, "10.0.0.1", true)
+	pod.Labels = labels
+	return pod
+}
+
+func TestGetMatchingPods_MatchExpressions(t *testing.T) {
+	provider := &fakeProvider{pods: map[string][]*corev1.Pod{"default": {
+		labeledPod("api-0", map[string]string{"app": "api", "track": "stable"}),
+		labeledPod("api-canary-0", map[string]string{"app": "api", "track": "canary"}),
+		labeledPod("web-0", map[string]string{"app": "web"}),
+	}}}
+	sd := &ServiceDiscoveryImpl{k8sClient: provider}
+
+	pods, err := sd.getMatchingPods("default", &selector.Selector{
+		MatchExpressions: []selector.Requirement{
+			{Key: "app", Operator: selector.OpIn, Values: []string{"api"}},
+			{Key: "track", Operator: selector.OpNotIn, Values: []string{"canary"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("getMatchingPods: %v", err)
+	}
+	if len(pods) != 1 || pods[0].Name != "api-0" {
+		t.Fatalf("expected only api-0, got %d pods", len(pods))
+	}
+
+	if _, err := sd.getMatchingPods("default", &selector.Selector{}); err == nil {
+		t.Error("an empty selector must not select every pod")
+	}
+}
+
+func TestGetMatchingNamespaces_LabelSelector(t *testing.T) {
+	namespace := func(name string, labels map[string]string) *corev1.Namespace {
+		return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+	}
+	provider := &fakeProvider{namespaces: []*corev1.Namespace{
+		namespace("team-b", map[string]string{"monitoring": "enabled", "env": "prod"}),
+		namespace("team-a", map[string]string{"monitoring": "enabled", "env": "prod"}),
+		namespace("team-dev", map[string]string{"monitoring": "enabled", "env": "dev"}),
+		namespace("legacy", nil),
+	}}
+	sd := &ServiceDiscoveryImpl{k8sClient: provider}
+
+	namespaces, err := sd.getMatchingNamespaces(&selector.NamespaceSelector{Labels: selector.Selector{
+		MatchLabels:      map[string]string{"monitoring": "enabled"},
+		MatchExpressions: []selector.Requirement{{Key: "env", Operator: selector.OpNotIn, Values: []string{"dev"}}},
+	}})
+	if err != nil {
+		t.Fatalf("getMatchingNamespaces: %v", err)
+	}
+	if want := []string{"team-a", "team-b"}; !reflect.DeepEqual(namespaces, want) {
+		t.Errorf("expected %v, got %v", want, namespaces)
+	}
+
+	// matchNames alone is used as is, without a namespace lookup
+	namespaces, _ = sd.getMatchingNamespaces(&selector.NamespaceSelector{MatchNames: []string{"prod"}})
+	if !reflect.DeepEqual(namespaces, []string{"prod"}) {
+		t.Errorf("expected [prod], got %v", namespaces)
+	}
+}
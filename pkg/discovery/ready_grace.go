@@ -0,0 +1,55 @@
+package discovery
+
+import (
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// podReadySince returns when the pod's Ready condition last turned true, or zero if unknown
+func podReadySince(pod *corev1.Pod) time.Time {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady && condition.Status == corev1.ConditionTrue {
+			return condition.LastTransitionTime.Time
+		}
+	}
+	return time.Time{}
+}
+
+// applyReadyGrace sets ReadySince on a target about to replace prev and holds it in the warming state
+// until its grace period has passed. Must be called with targetsMutex held.
+//
+// ReadySince set by the caller (the pod's Ready transition time) is used as a hint. Without a hint the
+// transition is dated to the cycle it is observed in, except for targets that were already ready when
+// discovery started, whose ReadySince stays zero so that restarting the agent does not delay them.
+func (sd *ServiceDiscoveryImpl) applyReadyGrace(newTarget, prev *Target) {
+	if newTarget.State != TargetStateReady {
+		newTarget.ReadySince = time.Time{}
+		return
+	}
+
+	now := newTarget.LastSeen
+	since := newTarget.ReadySince
+	switch {
+	case prev != nil && (prev.State == TargetStateReady || prev.State == TargetStateWarming):
+		// Still ready; a newer hint means the pod flapped between two discovery cycles
+		if prev.ReadySince.After(since) {
+			since = prev.ReadySince
+		}
+	case prev != nil || sd.lastCycle != nil:
+		// Became ready since the previous cycle
+		if since.IsZero() {
+			since = now
+		}
+	}
+	newTarget.ReadySince = since
+
+	if newTarget.warming(now) {
+		newTarget.State = TargetStateWarming
+	}
+}
+
+// warming reports whether a ready target is still inside its grace period at now
+func (t *Target) warming(now time.Time) bool {
+	return t.readyGracePeriod > 0 && !t.ReadySince.IsZero() && now.Sub(t.ReadySince) < t.readyGracePeriod
+}
@@ -0,0 +1,120 @@
+package discovery
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// observe simulates one discovery cycle seeing the target in the given readiness at now
+func observe(sd *ServiceDiscoveryImpl, ready bool, now time.Time) *Target {
+	state := TargetStatePending
+	if ready {
+		state = TargetStateReady
+	}
+	sd.updateTarget(&Target{ID: "app/default/pod-a/8080", State: state, LastSeen: now, readyGracePeriod: 30 * time.Second})
+	sd.lastCycle = newDiscoveryCycle()
+	return sd.targets["app/default/pod-a/8080"]
+}
+
+func TestReadyGrace_PendingToReady(t *testing.T) {
+	sd := &ServiceDiscoveryImpl{targets: make(map[string]*Target), lastCycle: newDiscoveryCycle()}
+	start := time.Now()
+
+	if target := observe(sd, false, start); target.State != TargetStatePending || !target.ReadySince.IsZero() {
+		t.Fatalf("expected pending target without readySince, got %s %v", target.State, target.ReadySince)
+	}
+	if target := observe(sd, true, start.Add(15*time.Second)); target.State != TargetStateWarming {
+		t.Fatalf("expected warming right after becoming ready, got %s", target.State)
+	}
+	if target := observe(sd, true, start.Add(30*time.Second)); target.State != TargetStateWarming {
+		t.Fatalf("expected warming 15s after becoming ready, got %s", target.State)
+	}
+	target := observe(sd, true, start.Add(45*time.Second))
+	if target.State != TargetStateReady || !target.ReadySince.Equal(start.Add(15*time.Second)) {
+		t.Fatalf("expected ready after the grace period with readySince kept, got %s %v", target.State, target.ReadySince)
+	}
+}
+
+func TestReadyGrace_FlappingRestartsGracePeriod(t *testing.T) {
+	sd := &ServiceDiscoveryImpl{targets: make(map[string]*Target), lastCycle: newDiscoveryCycle()}
+	start := time.Now()
+
+	observe(sd, true, start)
+	observe(sd, true, start.Add(15*time.Second))
+	if target := observe(sd, false, start.Add(30*time.Second)); target.State != TargetStatePending {
+		t.Fatalf("expected pending after readiness was lost, got %s", target.State)
+	}
+	// Ready again: the grace period restarts even though the first one would have ended by now
+	if target := observe(sd, true, start.Add(45*time.Second)); target.State != TargetStateWarming {
+		t.Fatalf("expected flapping target to warm again, got %s", target.State)
+	}
+	if target := observe(sd, true, start.Add(60*time.Second)); target.State != TargetStateWarming {
+		t.Fatalf("expected warming 15s after flapping back, got %s", target.State)
+	}
+	if target := observe(sd, true, start.Add(75*time.Second)); target.State != TargetStateReady {
+		t.Fatalf("expected ready 30s after flapping back, got %s", target.State)
+	}
+}
+
+func TestReadyGrace_FlapBetweenCyclesUsesPodTransitionTime(t *testing.T) {
+	sd := &ServiceDiscoveryImpl{targets: make(map[string]*Target), lastCycle: newDiscoveryCycle()}
+	config := newTestPodConfig(false)
+	config.ReadyGracePeriod = 30 * time.Second
+
+	// Ready for a minute: scraped immediately
+	pod := newTestPod("pod-a", "10.0.0.1", true)
+	pod.Status.Conditions[0].LastTransitionTime = metav1.NewTime(time.Now().Add(-time.Minute))
+	sd.processPodTarget(pod, config, make(map[string]bool))
+	if targets := sd.GetReadyTargets(); len(targets) != 1 {
+		t.Fatalf("expected a pod ready for a minute to be scraped, got %d targets", len(targets))
+	}
+
+	// The pod went not-ready and back within one discovery interval
+	pod.Status.Conditions[0].LastTransitionTime = metav1.NewTime(time.Now().Add(-5 * time.Second))
+	sd.processPodTarget(pod, config, make(map[string]bool))
+	for _, target := range sd.targets {
+		if target.State != TargetStateWarming {
+			t.Fatalf("expected the new transition time to restart the grace period, got %s", target.State)
+		}
+	}
+	if targets := sd.GetReadyTargets(); len(targets) != 0 {
+		t.Fatalf("warming targets must not be handed to the scraper, got %d", len(targets))
+	}
+}
+
+func TestReadyGrace_TargetsReadyAtStartupAreNotHeld(t *testing.T) {
+	sd := &ServiceDiscoveryImpl{targets: make(map[string]*Target)}
+	if target := observe(sd, true, time.Now()); target.State != TargetStateReady {
+		t.Fatalf("expected a target already ready on the first cycle to be scraped, got %s", target.State)
+	}
+	// A target appearing in a later cycle is new and warms up
+	sd.updateTarget(&Target{ID: "app/default/pod-b/8080", State: TargetStateReady, LastSeen: time.Now(), readyGracePeriod: 30 * time.Second})
+	if target := sd.targets["app/default/pod-b/8080"]; target.State != TargetStateWarming {
+		t.Fatalf("expected a target appearing later to warm, got %s", target.State)
+	}
+}
+
+func TestReadyGrace_WarmingPromotedByGetReadyTargets(t *testing.T) {
+	sd := &ServiceDiscoveryImpl{targets: make(map[string]*Target), lastCycle: newDiscoveryCycle()}
+	sd.targets["a"] = &Target{ID: "a", State: TargetStateWarming, ReadySince: time.Now().Add(-31 * time.Second), readyGracePeriod: 30 * time.Second}
+	sd.targets["b"] = &Target{ID: "b", State: TargetStateWarming, ReadySince: time.Now(), readyGracePeriod: 30 * time.Second}
+
+	targets := sd.GetReadyTargets()
+	if len(targets) != 1 || targets[0].ID != "a" {
+		t.Fatalf("expected only the target past its grace period, got %v", targets)
+	}
+}
+
+func TestParseDiscoveryConfig_ReadyGracePeriod(t *testing.T) {
+	sd := &ServiceDiscoveryImpl{}
+	config, _ := sd.parseDiscoveryConfig(map[string]interface{}{"targetName": "app", "readyGracePeriod": "30s"})
+	if config.ReadyGracePeriod != 30*time.Second {
+		t.Errorf("expected 30s, got %v", config.ReadyGracePeriod)
+	}
+	config, _ = sd.parseDiscoveryConfig(map[string]interface{}{"targetName": "app", "readyGracePeriod": "soon"})
+	if config.ReadyGracePeriod != 0 {
+		t.Errorf("expected invalid readyGracePeriod to be ignored, got %v", config.ReadyGracePeriod)
+	}
+}
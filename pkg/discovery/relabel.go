@@ -4,30 +4,110 @@ import (
 	"crypto/md5"
 	"encoding/binary"
 	"fmt"
+	"net/url"
 	"open-agent/pkg/model"
 	"open-agent/tools/util/logutil"
 	"regexp"
 	"strings"
 )
 
+// Internal labels the scrape URL is built from, as in Prometheus
+const (
+	addressLabel     = "__address__"
+	schemeLabel      = "__scheme__"
+	metricsPathLabel = "__metrics_path__"
+	paramLabelPrefix = "__param_"
+)
+
 // ProcessRelabelConfigs applies relabel configs to the given labels.
 // Returns the resulting labels and a boolean indicating whether the target should be kept.
 func ProcessRelabelConfigs(labels map[string]string, configs model.RelabelConfigs) (map[string]string, bool) {
-	// Make a copy of labels to work on
-	resultLabels := make(map[string]string)
+	resultLabels, keep := applyRelabelConfigs(labels, configs)
+	if !keep {
+		return nil, false
+	}
+	return dropMetaLabels(resultLabels), true
+}
+
+// RelabelTarget applies relabel configs to target labels that carry the internal URL labels
+// (__address__, __scheme__, __metrics_path__, __param_<name>) and rebuilds the scrape URL from
+// their post-relabel values, so rules can rewrite the address, scheme, path or query parameters.
+// Targets left without an address are dropped.
+func RelabelTarget(labels map[string]string, configs model.RelabelConfigs) (map[string]string, string, bool) {
+	finalLabels, scrapeURL, keep, _ := relabelTarget(labels, configs)
+	return finalLabels, scrapeURL, keep
+}
+
+// relabelTarget is RelabelTarget that also checks the scrape URL, returning why it is invalid
+func relabelTarget(labels map[string]string, configs model.RelabelConfigs) (map[string]string, string, bool, error) {
+	resultLabels, keep := applyRelabelConfigs(labels, configs)
+	if !keep {
+		return nil, "", false, nil
+	}
+	if resultLabels[addressLabel] == "" {
+		return nil, "", false, nil
+	}
+
+	// The default instance label follows a rewritten address unless a rule set it explicitly
+	if labels["instance"] == labels[addressLabel] && resultLabels["instance"] == labels["instance"] {
+		resultLabels["instance"] = resultLabels[addressLabel]
+	}
+
+	scrapeURL := buildURLFromLabels(resultLabels)
+	return dropMetaLabels(resultLabels), scrapeURL, true, checkURLLabels(resultLabels, scrapeURL)
+}
+
+// setURLLabels sets the internal labels a target's scrape URL is built from
+func setURLLabels(labels map[string]string, address, scheme, path string, params map[string]interface{}) {
+	labels[addressLabel] = address
+	labels[schemeLabel] = scheme
+	labels[metricsPathLabel] = path
+	for key, values := range paramsToQuery(params) {
+		if len(values) > 0 {
+			labels[paramLabelPrefix+key] = values[0]
+		}
+	}
+}
+
+// buildURLFromLabels builds the scrape URL from the internal URL labels
+func buildURLFromLabels(labels map[string]string) string {
+	scheme := labels[schemeLabel]
+	if scheme == "" {
+		scheme = "http"
+	}
+	u := &url.URL{
+		Scheme: scheme,
+		Host:   labels[addressLabel],
+		Path:   labels[metricsPathLabel],
+	}
+
+	query := url.Values{}
 	for k, v := range labels {
-		resultLabels[k] = v
+		if strings.HasPrefix(k, paramLabelPrefix) {
+			query.Set(strings.TrimPrefix(k, paramLabelPrefix), v)
+		}
 	}
+	u.RawQuery = encodeQuery(query)
+	return u.String()
+}
 
-	if len(configs) == 0 {
-		// Clean up meta labels (starting with __) and return
-		finalLabels := make(map[string]string)
-		for k, v := range resultLabels {
-			if !strings.HasPrefix(k, "__") {
-				finalLabels[k] = v
-			}
+// dropMetaLabels returns labels without the meta labels (starting with __)
+func dropMetaLabels(labels map[string]string) map[string]string {
+	finalLabels := make(map[string]string)
+	for k, v := range labels {
+		if !strings.HasPrefix(k, "__") {
+			finalLabels[k] = v
 		}
-		return finalLabels, true
+	}
+	return finalLabels
+}
+
+// applyRelabelConfigs applies relabel configs to a copy of labels, keeping meta labels
+func applyRelabelConfigs(labels map[string]string, configs model.RelabelConfigs) (map[string]string, bool) {
+	// Make a copy of labels to work on
+	resultLabels := make(map[string]string)
+	for k, v := range labels {
+		resultLabels[k] = v
 	}
 
 	for _, config := range configs {
@@ -114,13 +194,5 @@ func ProcessRelabelConfigs(labels map[string]string, configs model.RelabelConfig
 		}
 	}
 
-	// Clean up meta labels (starting with __)
-	finalLabels := make(map[string]string)
-	for k, v := range resultLabels {
-		if !strings.HasPrefix(k, "__") {
-			finalLabels[k] = v
-		}
-	}
-
-	return finalLabels, true
+	return resultLabels, true
 }
@@ -0,0 +1,107 @@
+package discovery
+
+import (
+	"testing"
+
+	"open-agent/pkg/model"
+)
+
+func TestRelabelTarget_MetricsPathFromPodAnnotation(t *testing.T) {
+	pod := newTestPod("web-0", "10.0.0.1", true)
+	pod.Annotations = map[string]string{"prometheus.io/path": "/custom/metrics"}
+
+	config := newTestPodConfig(false)
+	config.RelabelConfigs = model.RelabelConfigs{{
+		SourceLabels: []string{"__meta_kubernetes_pod_annotation_prometheus_io_path"},
+		Regex:        "(.+)",
+		TargetLabel:  "__metrics_path__",
+		Action:       "replace",
+	}}
+
+	target := processSinglePod(pod, config)
+	if target == nil {
+		t.Fatal("expected a target")
+	}
+	if target.URL != "http://10.0.0.1:8080/custom/metrics" {
+		t.Errorf("expected rewritten path, got %s", target.URL)
+	}
+	if _, ok := target.Labels["__metrics_path__"]; ok {
+		t.Errorf("internal labels must not be kept on the target: %v", target.Labels)
+	}
+}
+
+func TestRelabelTarget_SchemeAndParams(t *testing.T) {
+	config := newTestPodConfig(false)
+	config.Endpoints[0].Params = map[string]interface{}{"module": "http_2xx"}
+	config.RelabelConfigs = model.RelabelConfigs{
+		{TargetLabel: "__scheme__", Replacement: "https", Action: "replace"},
+		{SourceLabels: []string{"__meta_kubernetes_pod_name"}, TargetLabel: "__param_target", Action: "replace"},
+	}
+
+	target := processSinglePod(newTestPod("web-0", "10.0.0.1", true), config)
+	if target == nil {
+		t.Fatal("expected a target")
+	}
+	want := "https://10.0.0.1:8080/metrics?module=http_2xx&target=web-0"
+	if target.URL != want {
+		t.Errorf("expected %s, got %s", want, target.URL)
+	}
+}
+
+func TestRelabelTarget_AddressRewrite(t *testing.T) {
+	labels := map[string]string{"job": "app", "instance": "10.0.0.1:8080"}
+	setURLLabels(labels, "10.0.0.1:8080", "http", "/metrics", nil)
+
+	configs := model.RelabelConfigs{{
+		SourceLabels: []string{"__address__"},
+		Regex:        "([^:]+):\\d+",
+		Replacement:  "$1:9100",
+		TargetLabel:  "__address__",
+		Action:       "replace",
+	}}
+
+	finalLabels, url, keep := RelabelTarget(labels, configs)
+	if !keep {
+		t.Fatal("expected target to be kept")
+	}
+	if url != "http://10.0.0.1:9100/metrics" {
+		t.Errorf("expected rewritten address, got %s", url)
+	}
+	if finalLabels["instance"] != "10.0.0.1:9100" {
+		t.Errorf("expected default instance to follow the address, got %s", finalLabels["instance"])
+	}
+
+	// An empty address drops the target
+	configs = model.RelabelConfigs{{Regex: "__address__", Action: "labeldrop"}}
+	if _, _, keep := RelabelTarget(labels, configs); keep {
+		t.Error("expected target without address to be dropped")
+	}
+}
+
+func TestRelabelTarget_NoConfigsKeepsURL(t *testing.T) {
+	config := newTestPodConfig(false)
+	config.Endpoints[0].Params = map[string]interface{}{"format": []interface{}{"a", "b"}}
+
+	target := processSinglePod(newTestPod("web-0", "10.0.0.1", true), config)
+	want := buildURLWithParams("http://10.0.0.1:8080/metrics", config.Endpoints[0].Params)
+	if target == nil || target.URL != want {
+		t.Fatalf("expected %s, got %+v", want, target)
+	}
+}
+
+func TestRelabelTarget_SecretParamIsRedacted(t *testing.T) {
+	config := newTestPodConfig(false)
+	config.Endpoints[0].Params = map[string]interface{}{
+		"module":  "http_2xx",
+		"api_key": map[string]interface{}{"secretKeyRef": map[string]interface{}{"name": "exporter-key", "key": "api-key"}},
+	}
+
+	target := processSinglePod(newTestPod("web-0", "10.0.0.1", true), config)
+	if target == nil {
+		t.Fatal("expected a target")
+	}
+	want := "http://10.0.0.1:8080/metrics?api_key=***&module=http_2xx"
+	if target.URL != want {
+		t.Errorf("expected %s, got %s", want, target.URL)
+	}
+}
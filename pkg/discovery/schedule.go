@@ -0,0 +1,174 @@
+package discovery
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"open-agent/tools/util/logutil"
+)
+
+const (
+	// DefaultDiscoveryInterval is how often targets are rediscovered when discoveryInterval is not set (like Prometheus)
+	DefaultDiscoveryInterval = 15 * time.Second
+	// MinDiscoveryInterval is the shortest accepted discovery interval
+	MinDiscoveryInterval = 5 * time.Second
+	// scheduleCheckInterval is how often the discovery loop picks up discovery interval changes from a config reload
+	scheduleCheckInterval = 5 * time.Second
+)
+
+// discoveryTypes are the target types that accept a discovery interval override
+var discoveryTypes = []string{"PodMonitor", "ServiceMonitor", "StaticEndpoints", WhatapAgentsType, EtcdMonitorType}
+
+// discoverySchedule is the global discovery interval and its per target type overrides
+type discoverySchedule struct {
+	interval time.Duration
+	perType  map[string]time.Duration
+	// source is the configuration the schedule was parsed from, used to log changes once
+	source string
+}
+
+// parseDiscoverySchedule parses discoveryInterval and discoveryIntervals. Invalid values fall back to the
+// global interval (or the default) and values below MinDiscoveryInterval are raised to it; both are
+// returned as warnings.
+func parseDiscoverySchedule(global string, perType map[string]string) (discoverySchedule, []string) {
+	keys := make([]string, 0, len(perType))
+	for targetType := range perType {
+		keys = append(keys, targetType)
+	}
+	sort.Strings(keys)
+	source := make([]string, 0, len(keys)+1)
+	source = append(source, global)
+	for _, targetType := range keys {
+		source = append(source, targetType+"="+perType[targetType])
+	}
+
+	var warnings []string
+	schedule := discoverySchedule{interval: DefaultDiscoveryInterval, perType: make(map[string]time.Duration), source: strings.Join(source, " ")}
+	if global != "" {
+		interval, warning := parseDiscoveryInterval("discoveryInterval", global)
+		if warning != "" {
+			warnings = append(warnings, warning)
+		}
+		if interval > 0 {
+			schedule.interval = interval
+		}
+	}
+	for _, targetType := range keys {
+		if !isDiscoveryType(targetType) {
+			warnings = append(warnings, fmt.Sprintf("discoveryIntervals.%s: unknown target type, expected one of %s", targetType, strings.Join(discoveryTypes, ", ")))
+			continue
+		}
+		interval, warning := parseDiscoveryInterval("discoveryIntervals."+targetType, perType[targetType])
+		if warning != "" {
+			warnings = append(warnings, warning)
+		}
+		if interval > 0 {
+			schedule.perType[targetType] = interval
+		}
+	}
+	return schedule, warnings
+}
+
+// parseDiscoveryInterval parses one interval, returning 0 when it is unusable
+func parseDiscoveryInterval(field, value string) (time.Duration, string) {
+	interval, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Sprintf("%s: invalid interval %q, ignored", field, value)
+	}
+	if interval < MinDiscoveryInterval {
+		return MinDiscoveryInterval, fmt.Sprintf("%s: %v is below the minimum of %v, using %v", field, interval, MinDiscoveryInterval, MinDiscoveryInterval)
+	}
+	return interval, ""
+}
+
+func isDiscoveryType(targetType string) bool {
+	for _, known := range discoveryTypes {
+		if targetType == known {
+			return true
+		}
+	}
+	return false
+}
+
+// intervalFor returns the discovery interval of a target type
+func (s discoverySchedule) intervalFor(targetType string) time.Duration {
+	if interval, ok := s.perType[targetType]; ok {
+		return interval
+	}
+	return s.interval
+}
+
+// tick returns the discovery loop period, the shortest configured interval
+func (s discoverySchedule) tick() time.Duration {
+	tick := s.interval
+	for _, interval := range s.perType {
+		if interval < tick {
+			tick = interval
+		}
+	}
+	return tick
+}
+
+func (s discoverySchedule) String() string {
+	parts := []string{s.interval.String()}
+	for _, targetType := range discoveryTypes {
+		if interval, ok := s.perType[targetType]; ok {
+			parts = append(parts, fmt.Sprintf("%s=%v", targetType, interval))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// loadSchedule reads the discovery schedule from the scrape configuration
+func (sd *ServiceDiscoveryImpl) loadSchedule() (discoverySchedule, []string) {
+	if sd.configManager == nil {
+		return parseDiscoverySchedule("", nil)
+	}
+	return parseDiscoverySchedule(sd.configManager.GetDiscoveryInterval(), sd.configManager.GetDiscoveryIntervals())
+}
+
+// updateSchedule switches to a newly loaded schedule and reports whether the loop period changed
+func (sd *ServiceDiscoveryImpl) updateSchedule(next discoverySchedule, warnings []string) bool {
+	prev := sd.schedule
+	if prev.interval != 0 && next.source == prev.source {
+		return false
+	}
+	for _, warning := range warnings {
+		logutil.Printf("WARN", "[DISCOVERY] %s", warning)
+	}
+	sd.schedule = next
+	if prev.interval == 0 {
+		if next.tick() != DefaultDiscoveryInterval || len(next.perType) > 0 {
+			logutil.Printf("DISCOVERY", "Discovery interval: %s", next)
+		}
+		return false
+	}
+	if prev.String() != next.String() {
+		logutil.Printf("DISCOVERY", "Discovery interval changed: %s -> %s", prev, next)
+	}
+	return prev.tick() != next.tick()
+}
+
+// discoveryDue reports whether a target config must be discovered in this cycle. A config is due when its
+// type's interval has elapsed since that type was last discovered, or when it is new or changed.
+func (sd *ServiceDiscoveryImpl) discoveryDue(config DiscoveryConfig, fingerprint string, now time.Time) bool {
+	if sd.lastCycle == nil {
+		return true
+	}
+	if prev, ok := sd.lastCycle.fingerprints[config.TargetName]; !ok || prev != fingerprint {
+		return true
+	}
+	return sd.typeDue(config.Type, now)
+}
+
+// typeDue reports whether the discovery interval of a target type has elapsed
+func (sd *ServiceDiscoveryImpl) typeDue(targetType string, now time.Time) bool {
+	last, ok := sd.lastDiscovered[targetType]
+	if !ok {
+		return true
+	}
+	// Allow half a tick of slack so ticker jitter does not postpone a due type by a whole tick
+	return now.Sub(last) >= sd.schedule.intervalFor(targetType)-sd.schedule.tick()/2
+}
@@ -0,0 +1,120 @@
+package discovery
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDiscoverySchedule(t *testing.T) {
+	schedule, warnings := parseDiscoverySchedule("", nil)
+	if schedule.interval != DefaultDiscoveryInterval || schedule.tick() != DefaultDiscoveryInterval || len(warnings) != 0 {
+		t.Fatalf("expected the default interval, got %s (warnings %v)", schedule, warnings)
+	}
+
+	schedule, warnings = parseDiscoverySchedule("60s", map[string]string{"PodMonitor": "20s", "StaticEndpoints": "10m"})
+	if len(warnings) != 0 {
+		t.Fatalf("unexpected warnings: %v", warnings)
+	}
+	if got := schedule.intervalFor("ServiceMonitor"); got != time.Minute {
+		t.Errorf("ServiceMonitor: expected the global interval, got %v", got)
+	}
+	if got := schedule.intervalFor("PodMonitor"); got != 20*time.Second {
+		t.Errorf("PodMonitor: expected 20s, got %v", got)
+	}
+	if got := schedule.intervalFor("StaticEndpoints"); got != 10*time.Minute {
+		t.Errorf("StaticEndpoints: expected 10m, got %v", got)
+	}
+	if schedule.tick() != 20*time.Second {
+		t.Errorf("expected the loop to tick at the shortest interval, got %v", schedule.tick())
+	}
+}
+
+func TestParseDiscoverySchedule_Validation(t *testing.T) {
+	schedule, warnings := parseDiscoverySchedule("1s", map[string]string{"PodMonitor": "soon", "Probe": "30s"})
+	if schedule.interval != MinDiscoveryInterval {
+		t.Errorf("expected an interval below the minimum to be raised to %v, got %v", MinDiscoveryInterval, schedule.interval)
+	}
+	if got := schedule.intervalFor("PodMonitor"); got != MinDiscoveryInterval {
+		t.Errorf("expected an invalid override to fall back to the global interval, got %v", got)
+	}
+	if _, ok := schedule.perType["Probe"]; ok {
+		t.Errorf("expected an unknown target type to be ignored")
+	}
+	if len(warnings) != 3 {
+		t.Errorf("expected 3 warnings, got %v", warnings)
+	}
+
+	if schedule, _ := parseDiscoverySchedule("bogus", nil); schedule.interval != DefaultDiscoveryInterval {
+		t.Errorf("expected an invalid global interval to fall back to the default, got %v", schedule.interval)
+	}
+}
+
+func TestUpdateSchedule_RuntimeChange(t *testing.T) {
+	sd := &ServiceDiscoveryImpl{}
+
+	if sd.updateSchedule(parseDiscoverySchedule("15s", nil)) {
+		t.Fatalf("the initial schedule must not reset the ticker")
+	}
+	if sd.updateSchedule(parseDiscoverySchedule("15s", nil)) {
+		t.Errorf("an unchanged config must not reset the ticker")
+	}
+
+	if !sd.updateSchedule(parseDiscoverySchedule("60s", nil)) {
+		t.Errorf("changing the global interval must reset the ticker")
+	}
+	if sd.schedule.tick() != time.Minute {
+		t.Errorf("expected the new interval to be in effect, got %v", sd.schedule.tick())
+	}
+
+	if sd.updateSchedule(parseDiscoverySchedule("60s", map[string]string{"StaticEndpoints": "10m"})) {
+		t.Errorf("a longer per-type interval does not change the loop period")
+	}
+	if sd.schedule.intervalFor("StaticEndpoints") != 10*time.Minute {
+		t.Errorf("expected the StaticEndpoints override to be in effect, got %v", sd.schedule.intervalFor("StaticEndpoints"))
+	}
+
+	if !sd.updateSchedule(parseDiscoverySchedule("60s", map[string]string{"PodMonitor": "10s", "StaticEndpoints": "10m"})) {
+		t.Errorf("a shorter per-type interval must reset the ticker")
+	}
+	if sd.schedule.tick() != 10*time.Second {
+		t.Errorf("expected the loop to tick at 10s, got %v", sd.schedule.tick())
+	}
+}
+
+func TestDiscoveryDue_PerTypeCadence(t *testing.T) {
+	pod := DiscoveryConfig{TargetName: "pods", Type: "PodMonitor"}
+	static := DiscoveryConfig{TargetName: "static", Type: "StaticEndpoints"}
+	raw := map[string]interface{}{"targetName": "static"}
+
+	sd := &ServiceDiscoveryImpl{}
+	sd.schedule, _ = parseDiscoverySchedule("15s", map[string]string{"StaticEndpoints": "5m"})
+	start := time.Now()
+	if !sd.discoveryDue(pod, "", start) || !sd.discoveryDue(static, configFingerprint(raw), start) {
+		t.Fatalf("everything is due in the first cycle")
+	}
+
+	sd.lastCycle = newDiscoveryCycle()
+	sd.lastCycle.add(pod.TargetName, nil, nil)
+	sd.lastCycle.add(static.TargetName, raw, nil)
+	sd.lastDiscovered = map[string]time.Time{"PodMonitor": start, "StaticEndpoints": start}
+
+	// The ticker fires slightly early or late, half a tick of slack absorbs that
+	next := start.Add(15*time.Second - 50*time.Millisecond)
+	if !sd.discoveryDue(pod, configFingerprint(nil), next) {
+		t.Errorf("PodMonitor should be due after its 15s interval")
+	}
+	if sd.discoveryDue(static, configFingerprint(raw), next) {
+		t.Errorf("StaticEndpoints should not be due before its 5m interval")
+	}
+	if !sd.discoveryDue(static, configFingerprint(raw), start.Add(5*time.Minute)) {
+		t.Errorf("StaticEndpoints should be due after its 5m interval")
+	}
+
+	changed := map[string]interface{}{"targetName": "static", "endpoints": "changed"}
+	if !sd.discoveryDue(static, configFingerprint(changed), next) {
+		t.Errorf("a changed target config should be discovered right away")
+	}
+	if !sd.discoveryDue(DiscoveryConfig{TargetName: "new", Type: "StaticEndpoints"}, "", next) {
+		t.Errorf("a new target config should be discovered right away")
+	}
+}
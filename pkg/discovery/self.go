@@ -0,0 +1,129 @@
+package discovery
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"open-agent/pkg/admin"
+	"open-agent/tools/util/logutil"
+)
+
+// serviceAccountNamespaceFile holds the namespace of the pod the agent runs in
+const serviceAccountNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// selfIdentity identifies the agent's own pod and admin server, so broad selectors do not make
+// the agent scrape itself, and the node and zone preferLocalZone compares endpoints against
+type selfIdentity struct {
+	Namespace string
+	Name      string
+	UID       string
+	IP        string
+	Hostname  string
+	AdminPort string
+	NodeName  string
+	Zone      string
+	// HostNetwork is set when the agent's pod uses the host network, so its IP is the node's one
+	HostNetwork bool
+}
+
+// detectSelf reads the downward API variables POD_NAME, POD_NAMESPACE, POD_UID, POD_IP and NODE_NAME,
+// and NODE_ZONE. Without POD_NAME the host name is used, which is the pod name unless the pod uses the
+// host network. The agent is taken to use the host network when POD_IP equals NODE_IP.
+func detectSelf() selfIdentity {
+	hostname, _ := os.Hostname()
+	// Only the port is needed, which is set even when the rest of the admin settings is invalid
+	adminConfig, _ := admin.LoadServerConfig()
+	self := selfIdentity{
+		Namespace: os.Getenv("POD_NAMESPACE"),
+		Name:      os.Getenv("POD_NAME"),
+		UID:       os.Getenv("POD_UID"),
+		IP:        os.Getenv("POD_IP"),
+		Hostname:  hostname,
+		AdminPort: strconv.Itoa(adminConfig.Port),
+		NodeName:  os.Getenv("NODE_NAME"),
+		Zone:      os.Getenv("NODE_ZONE"),
+	}
+	self.HostNetwork = self.IP != "" && self.IP == os.Getenv("NODE_IP")
+	if self.Namespace == "" {
+		if data, err := os.ReadFile(serviceAccountNamespaceFile); err == nil {
+			self.Namespace = strings.TrimSpace(string(data))
+		}
+	}
+	if self.Name == "" && self.Namespace != "" {
+		self.Name = hostname
+	}
+	return self
+}
+
+// isPod reports whether the pod is the agent's own pod. The IP is only compared when neither the agent
+// nor the pod uses the host network, as every such pod on a node shares the node IP.
+func (s selfIdentity) isPod(pod *corev1.Pod) bool {
+	if s.UID != "" && string(pod.UID) == s.UID {
+		return true
+	}
+	if s.Name != "" && pod.Name == s.Name && (s.Namespace == "" || pod.Namespace == s.Namespace) {
+		return true
+	}
+	return s.IP != "" && !s.HostNetwork && pod.Status.PodIP == s.IP && !pod.Spec.HostNetwork
+}
+
+// isEndpointAddress reports whether a service endpoint address is the agent's own pod. An address
+// referencing a pod is matched by the pod's UID or name only; the IP is compared for addresses without
+// a reference, unless the agent uses the host network.
+func (s selfIdentity) isEndpointAddress(address corev1.EndpointAddress) bool {
+	if ref := address.TargetRef; ref != nil {
+		if ref.Kind != "Pod" {
+			return false
+		}
+		if s.UID != "" && string(ref.UID) == s.UID {
+			return true
+		}
+		return s.Name != "" && ref.Name == s.Name && (s.Namespace == "" || ref.Namespace == s.Namespace)
+	}
+	return s.IP != "" && !s.HostNetwork && address.IP == s.IP
+}
+
+// isStaticAddress reports whether a static host:port address is the agent's own admin server
+func (s selfIdentity) isStaticAddress(address string) bool {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil || s.AdminPort == "" || port != s.AdminPort {
+		return false
+	}
+	if strings.EqualFold(host, "localhost") || (s.Hostname != "" && strings.EqualFold(host, s.Hostname)) {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && (ip.IsLoopback() || ip.IsUnspecified() || (s.IP != "" && ip.Equal(net.ParseIP(s.IP))))
+}
+
+// skipSelf reports whether a target of config must be skipped because it is the agent itself,
+// logging each target once. allowSelfScrape turns the check off.
+func (sd *ServiceDiscoveryImpl) skipSelf(config DiscoveryConfig, isSelf bool, what string) bool {
+	if !isSelf || config.AllowSelfScrape {
+		return false
+	}
+	if !sd.selfSkipped[config.TargetName] {
+		if sd.selfSkipped == nil {
+			sd.selfSkipped = make(map[string]bool)
+		}
+		sd.selfSkipped[config.TargetName] = true
+		logutil.Printf("INFO", "[DISCOVERY] %s %s selects the agent itself (%s), skipping it; set allowSelfScrape: true to scrape it",
+			config.Type, config.TargetName, what)
+	}
+	return true
+}
+
+// filterSelfPod removes the agent's own pod from the pods selected by a PodMonitor
+func (sd *ServiceDiscoveryImpl) filterSelfPod(pods []*corev1.Pod, config DiscoveryConfig) []*corev1.Pod {
+	for i, pod := range pods {
+		if sd.skipSelf(config, sd.self.isPod(pod), fmt.Sprintf("pod %s/%s", pod.Namespace, pod.Name)) {
+			return append(pods[:i:i], pods[i+1:]...)
+		}
+	}
+	return pods
+}
@@ -0,0 +1,138 @@
+package discovery
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"open-agent/pkg/k8s"
+)
+
+func TestDiscoverPodTargets_SkipsSelf(t *testing.T) {
+	self := labeledPod("openagent-0", map[string]string{"app": "api"})
+	provider := &fakeProvider{pods: map[string][]*corev1.Pod{
+		"default": {labeledPod("api-0", map[string]string{"app": "api"}), self},
+	}}
+	sd := &ServiceDiscoveryImpl{k8sClient: provider, targets: make(map[string]*Target),
+		self: selfIdentity{Namespace: "default", Name: "openagent-0", AdminPort: "6060"}}
+
+	for i := 0; i < 2; i++ {
+		sd.discoverPodTargets(newSelectorPodConfig(), make(map[string]bool))
+	}
+	if len(sd.targets) != 1 {
+		t.Fatalf("expected only the other pod to be scraped, got %d targets", len(sd.targets))
+	}
+	for _, target := range sd.targets {
+		if ref, _ := target.Metadata["objectRef"].(k8s.ObjectRef); ref.Name == "openagent-0" {
+			t.Errorf("the agent's own pod was scraped: %v", target.Metadata)
+		}
+	}
+	if !sd.selfSkipped["app"] || len(sd.selfSkipped) != 1 {
+		t.Errorf("expected the skipped target to be recorded once, got %v", sd.selfSkipped)
+	}
+	if pods := provider.pods["default"]; pods[1] != self {
+		t.Errorf("filtering must not modify the provider's pod list")
+	}
+
+	config := newSelectorPodConfig()
+	config.AllowSelfScrape = true
+	sd.targets = make(map[string]*Target)
+	sd.discoverPodTargets(config, make(map[string]bool))
+	if len(sd.targets) != 2 {
+		t.Errorf("allowSelfScrape: expected 2 targets, got %d", len(sd.targets))
+	}
+}
+
+func TestSelfIdentity_IsPod(t *testing.T) {
+	self := selfIdentity{Namespace: "monitoring", Name: "openagent-0", IP: "10.0.0.9"}
+	tests := []struct {
+		name string
+		pod  *corev1.Pod
+		want bool
+	}{
+		{"same name and namespace", newTestPodIn("monitoring", "openagent-0", "10.0.0.1"), true},
+		{"same name in another namespace", newTestPodIn("default", "openagent-0", "10.0.0.1"), false},
+		{"same pod IP", newTestPodIn("default", "api-0", "10.0.0.9"), true},
+		{"other pod", newTestPodIn("monitoring", "api-0", "10.0.0.1"), false},
+	}
+	for _, tt := range tests {
+		if got := self.isPod(tt.pod); got != tt.want {
+			t.Errorf("%s: isPod = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// Discovery reads pods from the informer cache
+	hostNetwork := newTestPodIn("kube-system", "node-exporter", "10.0.0.9")
+	hostNetwork.Spec.HostNetwork = true
+	if self.isPod(k8s.CachedPod(hostNetwork)) {
+		t.Errorf("a host network pod sharing the node IP must not be taken for the agent")
+	}
+
+	self.HostNetwork = true
+	if self.isPod(newTestPodIn("default", "api-0", "10.0.0.9")) {
+		t.Errorf("a host network agent must not match other pods by the node IP")
+	}
+}
+
+func TestSelfIdentity_IsEndpointAddress(t *testing.T) {
+	self := selfIdentity{Namespace: "monitoring", Name: "openagent-0"}
+	ref := corev1.EndpointAddress{IP: "10.0.0.1", TargetRef: &corev1.ObjectReference{Kind: "Pod", Namespace: "monitoring", Name: "openagent-0"}}
+	if !self.isEndpointAddress(ref) {
+		t.Errorf("expected the address referencing the agent pod to match")
+	}
+	ref.TargetRef.Name = "api-0"
+	if self.isEndpointAddress(ref) {
+		t.Errorf("expected an address referencing another pod not to match")
+	}
+
+	// A host network agent shares the node IP with the node's host network exporters
+	self = selfIdentity{Namespace: "monitoring", Name: "openagent-0", IP: "10.0.1.1", HostNetwork: true}
+	nodeExporter := corev1.EndpointAddress{IP: "10.0.1.1", TargetRef: &corev1.ObjectReference{Kind: "Pod", Namespace: "monitoring", Name: "node-exporter-x2k9"}}
+	if self.isEndpointAddress(nodeExporter) {
+		t.Errorf("expected an exporter on the agent's node IP not to match")
+	}
+	nodeExporter.TargetRef = nil
+	if self.isEndpointAddress(nodeExporter) {
+		t.Errorf("expected an address without reference not to match a host network agent by IP")
+	}
+	self.HostNetwork = false
+	if !self.isEndpointAddress(nodeExporter) {
+		t.Errorf("expected an address without reference to match the agent's pod IP")
+	}
+}
+
+func TestDiscoverStaticTargets_SkipsAdminServer(t *testing.T) {
+	sd := &ServiceDiscoveryImpl{targets: make(map[string]*Target),
+		self: selfIdentity{Hostname: "openagent-0", IP: "10.0.0.9", AdminPort: "6060"}}
+	config := DiscoveryConfig{
+		TargetName: "static",
+		Type:       "StaticEndpoints",
+		Enabled:    true,
+		Endpoints: []EndpointConfig{
+			{Address: "localhost:6060", Path: "/metrics"},
+			{Address: "127.0.0.1:6060", Path: "/metrics"},
+			{Address: "10.0.0.9:6060", Path: "/metrics"},
+			{Address: "openagent-0:6060", Path: "/metrics"},
+			{Address: "localhost:9100", Path: "/metrics"},
+			{Address: "10.0.0.2:6060", Path: "/metrics"},
+		},
+	}
+
+	sd.discoverStaticTargets(config, make(map[string]bool))
+	if len(sd.targets) != 2 {
+		t.Fatalf("expected only the two non-agent addresses, got %d targets", len(sd.targets))
+	}
+
+	config.AllowSelfScrape = true
+	sd.targets = make(map[string]*Target)
+	sd.discoverStaticTargets(config, make(map[string]bool))
+	if len(sd.targets) != len(config.Endpoints) {
+		t.Errorf("allowSelfScrape: expected %d targets, got %d", len(config.Endpoints), len(sd.targets))
+	}
+}
+
+func newTestPodIn(namespace, name, ip string) *corev1.Pod {
+	pod := newTestPod(name, ip, true)
+	pod.Namespace = namespace
+	return pod
+}
@@ -0,0 +1,69 @@
+package discovery
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	"open-agent/tools/util/logutil"
+)
+
+// serverNameFields maps the fields a tlsConfig.serverName template can use to discovery meta labels
+var serverNameFields = map[string]string{
+	"Namespace":   "__meta_kubernetes_namespace",
+	"PodName":     "__meta_kubernetes_pod_name",
+	"ServiceName": "__meta_kubernetes_service_name",
+	"NodeName":    "__meta_kubernetes_pod_node_name",
+	"TargetName":  "job",
+}
+
+// renderServerName renders a tlsConfig.serverName template such as {{.ServiceName}}.{{.Namespace}}.svc
+// with the target's meta labels. A field the target does not have is an error rather than an empty string.
+func renderServerName(serverName string, metaLabels map[string]string) (string, error) {
+	tmpl, err := template.New("serverName").Option("missingkey=error").Parse(serverName)
+	if err != nil {
+		return "", fmt.Errorf("invalid tlsConfig.serverName template %q: %v", serverName, err)
+	}
+	fields := make(map[string]string, len(serverNameFields))
+	for field, label := range serverNameFields {
+		if value := metaLabels[label]; value != "" {
+			fields[field] = value
+		}
+	}
+	var rendered strings.Builder
+	if err := tmpl.Execute(&rendered, fields); err != nil {
+		return "", fmt.Errorf("cannot resolve tlsConfig.serverName template %q: %v", serverName, err)
+	}
+	return rendered.String(), nil
+}
+
+// withServerName returns the endpoint with its tlsConfig.serverName template resolved for one target.
+// The TLS config is copied, so the shared endpoint config keeps the template. A template that cannot be
+// resolved skips the target, since dialing the IP without the right server name fails verification;
+// the error is logged once per target config.
+func (sd *ServiceDiscoveryImpl) withServerName(endpoint EndpointConfig, config DiscoveryConfig, metaLabels map[string]string) (EndpointConfig, bool) {
+	serverName, _ := endpoint.TLSConfig["serverName"].(string)
+	if !strings.Contains(serverName, "{{") {
+		return endpoint, true
+	}
+
+	rendered, err := renderServerName(serverName, metaLabels)
+	if err != nil {
+		if sd.serverNameErrors[config.TargetName] != err.Error() {
+			if sd.serverNameErrors == nil {
+				sd.serverNameErrors = make(map[string]string)
+			}
+			sd.serverNameErrors[config.TargetName] = err.Error()
+			logutil.Printf("WARN", "[DISCOVERY] %s %s: %v, skipping the target", config.Type, config.TargetName, err)
+		}
+		return endpoint, false
+	}
+
+	tlsConfig := make(map[string]interface{}, len(endpoint.TLSConfig))
+	for k, v := range endpoint.TLSConfig {
+		tlsConfig[k] = v
+	}
+	tlsConfig["serverName"] = rendered
+	endpoint.TLSConfig = tlsConfig
+	return endpoint, true
+}
@@ -0,0 +1,78 @@
+package discovery
+
+import (
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestRenderServerName(t *testing.T) {
+	metaLabels := map[string]string{
+		"job":                            "exporter",
+		"__meta_kubernetes_namespace":    "monitoring",
+		"__meta_kubernetes_service_name": "exporter-svc",
+		"__meta_kubernetes_pod_name":     "exporter-0",
+	}
+	tests := []struct {
+		serverName string
+		want       string
+		wantErr    string
+	}{
+		{"{{.ServiceName}}.{{.Namespace}}.svc", "exporter-svc.monitoring.svc", ""},
+		{"{{.PodName}}.{{.ServiceName}}.{{.Namespace}}.svc.cluster.local", "exporter-0.exporter-svc.monitoring.svc.cluster.local", ""},
+		{"{{.NodeName}}.example.com", "", "NodeName"},
+		{"{{.ServiceName", "", "invalid"},
+	}
+	for _, tt := range tests {
+		got, err := renderServerName(tt.serverName, metaLabels)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: expected an error mentioning %q, got %q, %v", tt.serverName, tt.wantErr, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%s: got %q, %v, want %q", tt.serverName, got, err, tt.want)
+		}
+	}
+}
+
+func TestDiscoverPodTargets_ServerNameTemplate(t *testing.T) {
+	provider := &fakeProvider{pods: map[string][]*corev1.Pod{
+		"default": {labeledPod("api-0", map[string]string{"app": "api"})},
+	}}
+	sd := &ServiceDiscoveryImpl{k8sClient: provider, targets: make(map[string]*Target)}
+
+	config := newSelectorPodConfig()
+	config.Endpoints[0].TLSConfig = map[string]interface{}{"serverName": "{{.PodName}}.{{.Namespace}}.pod", "caFile": "/etc/ca.crt"}
+	sd.discoverPodTargets(config, make(map[string]bool))
+	if len(sd.targets) != 1 {
+		t.Fatalf("expected 1 target, got %d", len(sd.targets))
+	}
+	for _, target := range sd.targets {
+		endpoint := target.Metadata["endpoint"].(EndpointConfig)
+		if got := endpoint.TLSConfig["serverName"]; got != "api-0.default.pod" {
+			t.Errorf("expected the resolved server name, got %v", got)
+		}
+		if endpoint.TLSConfig["caFile"] != "/etc/ca.crt" {
+			t.Errorf("expected the other TLS settings to be kept, got %v", endpoint.TLSConfig)
+		}
+	}
+	if got := config.Endpoints[0].TLSConfig["serverName"]; got != "{{.PodName}}.{{.Namespace}}.pod" {
+		t.Errorf("the shared endpoint config must keep the template, got %v", got)
+	}
+
+	// A PodMonitor has no service, so the target is skipped rather than verified against a broken name
+	config.Endpoints[0].TLSConfig = map[string]interface{}{"serverName": "{{.ServiceName}}.{{.Namespace}}.svc"}
+	sd.targets = make(map[string]*Target)
+	for i := 0; i < 2; i++ {
+		sd.discoverPodTargets(config, make(map[string]bool))
+	}
+	if len(sd.targets) != 0 {
+		t.Errorf("expected the target to be skipped, got %d targets", len(sd.targets))
+	}
+	if len(sd.serverNameErrors) != 1 || !strings.Contains(sd.serverNameErrors["app"], "ServiceName") {
+		t.Errorf("expected the template error to be recorded once, got %v", sd.serverNameErrors)
+	}
+}
@@ -3,13 +3,17 @@ package discovery
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/url"
 	configPkg "open-agent/pkg/config"
+	"open-agent/pkg/diagnostics"
 	"open-agent/pkg/k8s"
 	"open-agent/pkg/model"
+	"open-agent/pkg/selector"
 	"open-agent/tools/util/logutil"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -20,25 +24,55 @@ import (
 // ServiceDiscoveryImpl implements service discovery for various target types including Kubernetes and static endpoints
 type ServiceDiscoveryImpl struct {
 	configManager   *configPkg.ConfigManager
-	k8sClient       *k8s.K8sClient
+	k8sClient       k8s.K8sProvider
 	configs         []DiscoveryConfig
 	targets         map[string]*Target
 	targetsMutex    sync.RWMutex
 	stopCh          chan struct{}
 	lastTargetNames []string
+	// lastDuplicateNames is the last logged set of duplicate targetNames
+	lastDuplicateNames string
+	// lastCycle is the previous discovery cycle, used to log what a config reload changed
+	lastCycle *discoveryCycle
+	// standaloneSkipped are PodMonitor/ServiceMonitor targets already logged as skipped in standalone mode
+	standaloneSkipped map[string]bool
+	// unknownZoneLogged is set once preferLocalZone was logged as ignored for an unknown agent zone
+	unknownZoneLogged bool
+	// self identifies the agent's own pod and admin server, which are not scraped unless allowSelfScrape is set
+	self selfIdentity
+	// selfSkipped are targets already logged as selecting the agent itself
+	selfSkipped map[string]bool
+	// schedule is the discovery interval and its per target type overrides
+	schedule discoverySchedule
+	// lastDiscovered is when each target type was last discovered
+	lastDiscovered map[string]time.Time
+	// serverNameErrors is the last logged tlsConfig.serverName template error of each target config
+	serverNameErrors map[string]string
+	// labelTemplateErrors is the last logged labelTemplates error of each target config
+	labelTemplateErrors map[string]string
+	// annotationErrors is the last logged invalid override annotations of each pod, by target config
+	annotationErrors map[string]map[string]string
+	// pending bounds the pending targets kept for not-ready pods and endpoints
+	pending pendingState
+	// stats counts what the config being discovered processed, nil outside of a discovery cycle
+	stats *discoveryStats
+	// cluster are the labels autoClusterLabel adds to every target
+	cluster clusterLabels
 }
 
 // NewServiceDiscovery creates a new ServiceDiscoveryImpl instance
 func NewServiceDiscovery(configManager *configPkg.ConfigManager) *ServiceDiscoveryImpl {
 	return &ServiceDiscoveryImpl{
 		configManager: configManager,
-		k8sClient:     k8s.GetInstance(),
+		k8sClient:     k8s.NewProvider(configPkg.IsForceStandaloneMode()),
 		targets:       make(map[string]*Target),
 		stopCh:        make(chan struct{}),
+		self:          detectSelf(),
 	}
 }
 
-// buildURLWithParams constructs a URL with query parameters
+// buildURLWithParams constructs a URL with query parameters. The query is encoded with sorted keys, so
+// the same params always build the same URL.
 func buildURLWithParams(baseURL string, params map[string]interface{}) string {
 	if len(params) == 0 {
 		return baseURL
@@ -51,7 +85,30 @@ func buildURLWithParams(baseURL string, params map[string]interface{}) string {
 	}
 
 	query := u.Query()
+	for key, values := range paramsToQuery(params) {
+		query[key] = values
+	}
+
+	u.RawQuery = encodeQuery(query)
+	return u.String()
+}
+
+// encodeQuery encodes a query with sorted keys, keeping the placeholder of params read from Secrets
+// readable (api_key=*** rather than api_key=%2A%2A%2A)
+func encodeQuery(query url.Values) string {
+	return strings.ReplaceAll(query.Encode(), "=%2A%2A%2A", "="+configPkg.RedactedParam)
+}
+
+// paramsToQuery converts configured URL parameters into query values. Array values keep their YAML
+// order, which is significant for the joined value. Params read from Secrets get a placeholder: the
+// scrape client puts in their current value on every request.
+func paramsToQuery(params map[string]interface{}) url.Values {
+	query := url.Values{}
 	for key, value := range params {
+		if _, ok := configPkg.SecretParam(value); ok {
+			query.Set(key, configPkg.RedactedParam)
+			continue
+		}
 		switch v := value.(type) {
 		case string:
 			query.Set(key, v)
@@ -77,20 +134,14 @@ func buildURLWithParams(baseURL string, params map[string]interface{}) string {
 			query.Set(key, fmt.Sprintf("%v", v))
 		}
 	}
-
-	u.RawQuery = query.Encode()
-	return u.String()
+	return query
 }
 
-func (sd *ServiceDiscoveryImpl) LoadTargets(targets []map[string]interface{}) error {
+func (sd *ServiceDiscoveryImpl) LoadTargets(targets []configPkg.TargetConfig) error {
 	sd.configs = make([]DiscoveryConfig, 0, len(targets))
 
-	for _, targetConfig := range targets {
-		parseDiscoveryConfig, err := sd.parseDiscoveryConfig(targetConfig)
-		if err != nil {
-			logutil.Infof("ERROR", "Failed to parse target parseDiscoveryConfig: %v", err)
-			continue
-		}
+	for _, targetConfig := range sd.dropDuplicateTargets(targets) {
+		parseDiscoveryConfig := sd.discoveryConfigFromTarget(targetConfig)
 
 		// Skip disabled targets
 		if !parseDiscoveryConfig.Enabled {
@@ -108,6 +159,67 @@ func (sd *ServiceDiscoveryImpl) LoadTargets(targets []map[string]interface{}) er
 	return nil
 }
 
+// FindDuplicateTargetNames returns the targetNames that appear more than once, in order of first duplicate
+func FindDuplicateTargetNames(targets []map[string]interface{}) []string {
+	names := make([]string, 0, len(targets))
+	for _, targetConfig := range targets {
+		name, _ := targetConfig["targetName"].(string)
+		names = append(names, name)
+	}
+	return duplicateNames(names)
+}
+
+// duplicateNames returns the non-empty names that appear more than once, in order of first duplicate
+func duplicateNames(names []string) []string {
+	seen := make(map[string]int)
+	var duplicates []string
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+		seen[name]++
+		if seen[name] == 2 {
+			duplicates = append(duplicates, name)
+		}
+	}
+	return duplicates
+}
+
+// dropDuplicateTargets keeps the first entry for each targetName and skips the rest.
+// Duplicate names would produce colliding target IDs whose schedulers overwrite each other.
+// The error is logged once per distinct set of duplicates, not on every discovery cycle.
+func (sd *ServiceDiscoveryImpl) dropDuplicateTargets(targets []configPkg.TargetConfig) []configPkg.TargetConfig {
+	names := make([]string, 0, len(targets))
+	for _, targetConfig := range targets {
+		names = append(names, targetConfig.TargetName)
+	}
+	duplicates := duplicateNames(names)
+	duplicateKey := strings.Join(duplicates, ",")
+	if duplicateKey != sd.lastDuplicateNames {
+		sd.lastDuplicateNames = duplicateKey
+		if len(duplicates) > 0 {
+			logutil.Printf("ERROR", "Duplicate targetName entries in scrape configuration, only the first occurrence is used: %s",
+				strings.Join(duplicates, ", "))
+		}
+	}
+	if len(duplicates) == 0 {
+		return targets
+	}
+
+	seen := make(map[string]bool)
+	result := make([]configPkg.TargetConfig, 0, len(targets))
+	for _, targetConfig := range targets {
+		if name := targetConfig.TargetName; name != "" {
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+		}
+		result = append(result, targetConfig)
+	}
+	return result
+}
+
 // Start begins target discovery
 func (sd *ServiceDiscoveryImpl) Start(ctx context.Context) error {
 	// Start periodic discovery
@@ -122,22 +234,74 @@ func (sd *ServiceDiscoveryImpl) GetReadyTargets() []*Target {
 	sd.targetsMutex.RLock()
 	defer sd.targetsMutex.RUnlock()
 
+	now := time.Now()
 	var readyTargets []*Target
+	var warmingTargets []string
 	for _, target := range sd.targets {
-		if target.State == TargetStateReady {
+		switch {
+		case target.State == TargetStateReady:
+			readyTargets = append(readyTargets, target)
+		case target.State == TargetStateWarming && !target.warming(now):
+			// Grace period ended between discovery cycles
 			readyTargets = append(readyTargets, target)
+		case target.State == TargetStateWarming:
+			remaining := target.readyGracePeriod - now.Sub(target.ReadySince)
+			warmingTargets = append(warmingTargets, fmt.Sprintf("%s (%v left)", target.ID, remaining.Truncate(time.Second)))
 		}
 	}
 
 	// Debug logging for returned targets
 	if configPkg.IsDebugEnabled() {
-		logutil.Debugf("DISCOVERY", "Found %d ready targets out of %d total",
-			len(readyTargets), len(sd.targets))
+		logutil.Debugf("DISCOVERY", "Found %d ready targets out of %d total (%d warming)",
+			len(readyTargets), len(sd.targets), len(warmingTargets))
+		if len(warmingTargets) > 0 {
+			sort.Strings(warmingTargets)
+			logutil.Debugf("DISCOVERY", "Warming targets: %s", strings.Join(warmingTargets, ", "))
+		}
 	}
 
 	return readyTargets
 }
 
+// GetAllTargets returns copies of all known targets regardless of state, sorted by ID
+func (sd *ServiceDiscoveryImpl) GetAllTargets() []*Target {
+	sd.targetsMutex.RLock()
+	defer sd.targetsMutex.RUnlock()
+
+	targets := make([]*Target, 0, len(sd.targets))
+	for _, target := range sd.targets {
+		copied := *target
+		targets = append(targets, &copied)
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].ID < targets[j].ID })
+	return targets
+}
+
+// GetTarget returns a copy of the target with the given ID
+func (sd *ServiceDiscoveryImpl) GetTarget(id string) (*Target, bool) {
+	sd.targetsMutex.RLock()
+	defer sd.targetsMutex.RUnlock()
+
+	target, ok := sd.targets[id]
+	if !ok {
+		return nil, false
+	}
+	copied := *target
+	return &copied, true
+}
+
+// TargetStateCounts returns the number of discovered targets by state
+func (sd *ServiceDiscoveryImpl) TargetStateCounts() map[TargetState]int {
+	sd.targetsMutex.RLock()
+	defer sd.targetsMutex.RUnlock()
+
+	counts := make(map[TargetState]int)
+	for _, target := range sd.targets {
+		counts[target.State]++
+	}
+	return counts
+}
+
 // Stop stops the discovery process
 func (sd *ServiceDiscoveryImpl) Stop() error {
 	close(sd.stopCh)
@@ -147,17 +311,26 @@ func (sd *ServiceDiscoveryImpl) Stop() error {
 
 // discoveryLoop runs the periodic target discovery
 func (sd *ServiceDiscoveryImpl) discoveryLoop() {
+	sd.updateSchedule(sd.loadSchedule())
+
 	// Initial discovery
 	sd.discoverTargets()
 
-	// Periodic discovery every 15 seconds (like Prometheus)
-	ticker := time.NewTicker(15 * time.Second)
+	// Periodic discovery at the shortest configured interval, each type when its own interval is due
+	ticker := time.NewTicker(sd.schedule.tick())
 	defer ticker.Stop()
+	// A config reload can change the intervals, pick that up without restarting the loop
+	scheduleCheck := time.NewTicker(scheduleCheckInterval)
+	defer scheduleCheck.Stop()
 
 	for {
 		select {
 		case <-ticker.C:
 			sd.discoverTargets()
+		case <-scheduleCheck.C:
+			if sd.updateSchedule(sd.loadSchedule()) {
+				ticker.Reset(sd.schedule.tick())
+			}
 		case <-sd.stopCh:
 			return
 		}
@@ -166,24 +339,25 @@ func (sd *ServiceDiscoveryImpl) discoveryLoop() {
 
 // discoverTargets discovers all configured targets
 func (sd *ServiceDiscoveryImpl) discoverTargets() {
+	// Per-object logs are switched on and off without a restart
+	logutil.SetTrace(configPkg.GetBoolWithDefault(DiscoveryTraceKey, false))
+	sd.loadClusterLabels()
+
 	// Get latest configuration from ConfigManager (uses Informer cache automatically)
-	scrapeConfigs := sd.configManager.GetScrapeConfigs()
+	targetConfigs := sd.configManager.GetTargetConfigs()
 	if configPkg.IsDebugEnabled() {
-		logutil.Printf("discoverTargets", "scrapeConfigs: %+v", scrapeConfigs)
+		logutil.Printf("discoverTargets", "targetConfigs: %+v", targetConfigs)
 	}
-	if scrapeConfigs == nil {
+	if targetConfigs == nil {
 		logutil.Printf("WARN", "No scrape configs available from ConfigManager")
 		return
 	}
 
-	// Parse latest configurations into discovery configs
+	// Convert latest configurations into discovery configs
 	currentConfigs := make([]DiscoveryConfig, 0)
-	for _, targetConfig := range scrapeConfigs {
-		parseDiscoveryConfig, err := sd.parseDiscoveryConfig(targetConfig)
-		if err != nil {
-			logutil.Printf("ERROR", "Failed to parse target config: %v", err)
-			continue
-		}
+	rawConfigs := make(map[string]map[string]interface{})
+	for _, targetConfig := range sd.dropDuplicateTargets(targetConfigs) {
+		parseDiscoveryConfig := sd.discoveryConfigFromTarget(targetConfig)
 
 		// Skip disabled targets
 		if !parseDiscoveryConfig.Enabled {
@@ -194,6 +368,7 @@ func (sd *ServiceDiscoveryImpl) discoverTargets() {
 		}
 
 		currentConfigs = append(currentConfigs, parseDiscoveryConfig)
+		rawConfigs[parseDiscoveryConfig.TargetName] = targetConfig.Raw
 	}
 
 	if configPkg.IsDebugEnabled() {
@@ -226,54 +401,116 @@ func (sd *ServiceDiscoveryImpl) discoverTargets() {
 	}
 
 	// Execute discovery with latest configurations
+	now := time.Now()
 	activeTargetIDs := make(map[string]bool)
+	cycle := newDiscoveryCycle()
+	discoveredTypes := make(map[string]bool)
+	var cycleStats []*discoveryStats
 	for _, discoveryConfig := range currentConfigs {
-		switch discoveryConfig.Type {
-		case "PodMonitor":
-			sd.discoverPodTargets(discoveryConfig, activeTargetIDs)
-		case "ServiceMonitor":
-			sd.discoverServiceTargets(discoveryConfig, activeTargetIDs)
-		case "StaticEndpoints":
-			sd.discoverStaticTargets(discoveryConfig, activeTargetIDs)
-		default:
-			logutil.Infof("WARN", "Unknown target type: %s", discoveryConfig.Type)
+		rawConfig := rawConfigs[discoveryConfig.TargetName]
+		if !sd.discoveryDue(discoveryConfig, configFingerprint(rawConfig), now) {
+			// Not due yet: keep the targets found last time
+			for id := range sd.lastCycle.targets[discoveryConfig.TargetName] {
+				activeTargetIDs[id] = true
+			}
+			cycle.add(discoveryConfig.TargetName, rawConfig, sd.lastCycle.targets[discoveryConfig.TargetName])
+			continue
+		}
+		if sd.typeDue(discoveryConfig.Type, now) {
+			discoveredTypes[discoveryConfig.Type] = true
+		}
+
+		configTargetIDs := make(map[string]bool)
+		sd.beginPending(discoveryConfig.TargetName)
+		if stats := sd.discoverConfig(discoveryConfig, configTargetIDs); stats != nil {
+			cycleStats = append(cycleStats, stats)
 		}
+		sd.endPending(discoveryConfig)
+		for id := range configTargetIDs {
+			activeTargetIDs[id] = true
+		}
+		cycle.add(discoveryConfig.TargetName, rawConfig, configTargetIDs)
+	}
+	if sd.lastDiscovered == nil {
+		sd.lastDiscovered = make(map[string]time.Time)
+	}
+	for targetType := range discoveredTypes {
+		sd.lastDiscovered[targetType] = now
 	}
 
+	// Report what a config reload changed
+	sd.logConfigDiff(cycle)
+
 	// Clean up stale targets
-	sd.cleanupStaleTargets(activeTargetIDs)
+	removed := sd.cleanupStaleTargets(activeTargetIDs)
+	for _, line := range cycleSummaries(cycleStats, removed) {
+		logutil.Infof("DISCOVERY", "%s", line)
+	}
+	sd.prunePending(now)
+	diagnostics.Beat(diagnostics.ComponentDiscovery)
+}
+
+// discoverConfig discovers the targets of one config and returns what it processed, nil for an
+// unknown target type
+func (sd *ServiceDiscoveryImpl) discoverConfig(config DiscoveryConfig, activeTargetIDs map[string]bool) *discoveryStats {
+	stats := &discoveryStats{targetName: config.TargetName, targetType: config.Type}
+	sd.stats = stats
+	defer func() { sd.stats = nil }()
+
+	switch config.Type {
+	case "PodMonitor", WhatapAgentsType:
+		sd.discoverPodTargets(config, activeTargetIDs)
+	case "ServiceMonitor":
+		sd.discoverServiceTargets(config, activeTargetIDs)
+	case "StaticEndpoints":
+		sd.discoverStaticTargets(config, activeTargetIDs)
+	case EtcdMonitorType:
+		if isStaticEtcd(config) {
+			sd.discoverStaticTargets(config, activeTargetIDs)
+		} else {
+			sd.discoverServiceTargets(config, activeTargetIDs)
+		}
+	default:
+		logutil.Infof("WARN", "Unknown target type: %s", config.Type)
+		return nil
+	}
+	stats.targets = len(activeTargetIDs)
+	return stats
 }
 
-// cleanupStaleTargets removes targets that were not found in the current discovery cycle
-func (sd *ServiceDiscoveryImpl) cleanupStaleTargets(activeTargetIDs map[string]bool) {
+// countObjects records the namespaces and objects the config being discovered matched
+func (sd *ServiceDiscoveryImpl) countObjects(namespaces, objects int) {
+	if sd.stats != nil {
+		sd.stats.namespaces += namespaces
+		sd.stats.objects += objects
+	}
+}
+
+// cleanupStaleTargets removes targets that were not found in the current discovery cycle and
+// returns how many were removed per targetName
+func (sd *ServiceDiscoveryImpl) cleanupStaleTargets(activeTargetIDs map[string]bool) map[string]int {
 	sd.targetsMutex.Lock()
 	defer sd.targetsMutex.Unlock()
 
-	var targetsToRemove []string
-	for targetID := range sd.targets {
-		if !activeTargetIDs[targetID] {
-			targetsToRemove = append(targetsToRemove, targetID)
+	removed := make(map[string]int)
+	for targetID, target := range sd.targets {
+		if activeTargetIDs[targetID] {
+			continue
 		}
-	}
-
-	for _, targetID := range targetsToRemove {
-		if configPkg.IsDebugEnabled() {
-			logutil.Debugf("DISCOVERY", "Removing stale target: %s", targetID)
-		} else {
-			logutil.Infof("DISCOVERY", "Removing stale target: %s", targetID)
+		logutil.Tracef("DISCOVERY", "Removing stale target: %s", targetID)
+		targetName, _ := target.Metadata["targetName"].(string)
+		if targetName == "" {
+			targetName, _, _ = strings.Cut(targetID, "/")
 		}
+		removed[targetName]++
 		delete(sd.targets, targetID)
 	}
+	return removed
 }
 
 // discoverPodTargets discovers Pod-based targets
 func (sd *ServiceDiscoveryImpl) discoverPodTargets(config DiscoveryConfig, activeTargetIDs map[string]bool) {
-	if configPkg.IsDebugEnabled() {
-		logutil.Debugf("DISCOVERY", "Discovering PodMonitor targets for %s", config.TargetName)
-	}
-
-	if !sd.k8sClient.IsInitialized() {
-		logutil.Printf("WARN", "Kubernetes client not initialized for PodMonitor: %s", config.TargetName)
+	if sd.k8sUnavailable(config) {
 		return
 	}
 
@@ -290,11 +527,10 @@ func (sd *ServiceDiscoveryImpl) discoverPodTargets(config DiscoveryConfig, activ
 		return
 	}
 
-	if configPkg.IsDebugEnabled() {
-		logutil.Debugf("DISCOVERY", "PodMonitor %s - Found %d matching namespaces: %v", config.TargetName, len(namespaces), namespaces)
-	}
+	logutil.Tracef("DISCOVERY", "PodMonitor %s - Found %d matching namespaces: %v", config.TargetName, len(namespaces), namespaces)
+	sd.countObjects(len(namespaces), 0)
 
-	totalPodsFound := 0
+	var matched []*corev1.Pod
 	for _, namespace := range namespaces {
 		// Get matching pods
 		pods, err := sd.getMatchingPods(namespace, config.Selector)
@@ -303,19 +539,43 @@ func (sd *ServiceDiscoveryImpl) discoverPodTargets(config DiscoveryConfig, activ
 			continue
 		}
 
-		if configPkg.IsDebugEnabled() {
-			logutil.Debugf("DISCOVERY", "PodMonitor %s - Found %d pods in namespace %s", config.TargetName, len(pods), namespace)
-		}
-		totalPodsFound += len(pods)
+		logutil.Tracef("DISCOVERY", "PodMonitor %s - Found %d pods in namespace %s", config.TargetName, len(pods), namespace)
+		pods = filterExcludedPods(pods, config)
+		pods = sd.filterSelfPod(pods, config)
+		sd.countObjects(0, len(pods))
+		matched = append(matched, pods...)
+	}
 
-		for _, pod := range pods {
-			if configPkg.IsDebugEnabled() {
-				logutil.Debugf("DISCOVERY", "PodMonitor %s - Processing pod %s/%s with labels: %+v", config.TargetName, pod.Namespace, pod.Name, pod.Labels)
+	// A sample is taken across all namespaces of the target
+	sampled := samplePods(matched, config)
+	if len(sampled) != len(matched) {
+		logutil.Tracef("DISCOVERY", "PodMonitor %s - Sampled %d of %d pods", config.TargetName, len(sampled), len(matched))
+	}
+	for _, pod := range sampled {
+		logutil.Tracef("DISCOVERY", "PodMonitor %s - Processing pod %s/%s with labels: %+v", config.TargetName, pod.Namespace, pod.Name, pod.Labels)
+		sd.processPodTarget(pod, config, activeTargetIDs)
+	}
+	sd.pruneAnnotationProblems(config, sampled)
+}
+
+// k8sUnavailable reports whether a PodMonitor/ServiceMonitor target cannot be discovered.
+// In standalone mode the target is logged once rather than on every discovery cycle.
+func (sd *ServiceDiscoveryImpl) k8sUnavailable(config DiscoveryConfig) bool {
+	if _, standalone := sd.k8sClient.(k8s.NoopK8sProvider); standalone {
+		if !sd.standaloneSkipped[config.TargetName] {
+			if sd.standaloneSkipped == nil {
+				sd.standaloneSkipped = make(map[string]bool)
 			}
-			sd.processPodTarget(pod, config, activeTargetIDs)
+			sd.standaloneSkipped[config.TargetName] = true
+			logutil.Printf("WARN", "[DISCOVERY] %s %s is skipped in standalone mode, only StaticEndpoints are scraped", config.Type, config.TargetName)
 		}
+		return true
+	}
+	if !sd.k8sClient.IsInitialized() {
+		logutil.Printf("WARN", "Kubernetes client not initialized for %s: %s", config.Type, config.TargetName)
+		return true
 	}
-	logutil.Infof("DISCOVERY", "PodMonitor %s - Total pods discovered: %d", config.TargetName, totalPodsFound)
+	return false
 }
 
 func (sd *ServiceDiscoveryImpl) processPodTarget(pod *corev1.Pod, config DiscoveryConfig, activeTargetIDs map[string]bool) {
@@ -328,30 +588,35 @@ func (sd *ServiceDiscoveryImpl) processPodTarget(pod *corev1.Pod, config Discove
 		pathSafe := strings.ReplaceAll(endpoint.Path, "/", "-")
 		targetID := fmt.Sprintf("%s/%s/%s/%s%s", config.TargetName, pod.Namespace, pod.Name, endpoint.Port, pathSafe)
 
+		// The ID keeps the configured port and path, so changing an annotation updates the target in place
+		var settingSources map[string]string
+		if config.AllowPodAnnotationsOverride {
+			endpoint, settingSources = sd.applyPodAnnotations(pod, endpoint, config)
+		}
+
 		// Get pod IP
 		podIP := pod.Status.PodIP
 		if podIP == "" {
-			if configPkg.IsDebugEnabled() {
-				logutil.Debugf("DISCOVERY", "Pod %s/%s has no IP yet", pod.Namespace, pod.Name)
-			}
+			logutil.Tracef("DISCOVERY", "Pod %s/%s has no IP yet", pod.Namespace, pod.Name)
 			continue
 		}
 
 		// Determine scheme
 		scheme := sd.determineScheme(endpoint.Scheme, endpoint.Port, endpoint.TLSConfig)
 
-		// Build target URL
-		path := endpoint.Path
-		baseURL := fmt.Sprintf("%s://%s:%s%s", scheme, podIP, endpoint.Port, path)
-		url := buildURLWithParams(baseURL, endpoint.Params)
+		// Named ports are resolved against the pod's containers and sidecar init containers
+		port, containerPort := resolvePodPort(pod, endpoint.Port)
 
 		// 1. Create initial meta labels
+		// The scrape URL is built from __scheme__, __address__, __metrics_path__ and __param_<name> after relabeling
 		metaLabels := make(map[string]string)
 		metaLabels["job"] = config.TargetName
-		metaLabels["__address__"] = fmt.Sprintf("%s:%s", podIP, endpoint.Port)
+		setURLLabels(metaLabels, fmt.Sprintf("%s:%s", podIP, port), scheme, endpoint.Path, endpoint.Params)
 		metaLabels["instance"] = metaLabels["__address__"] // Add default instance label
-		metaLabels["__scheme__"] = scheme
-		metaLabels["__metrics_path__"] = path
+		if containerPort != nil {
+			metaLabels["__meta_kubernetes_pod_container_name"] = containerPort.Container
+			metaLabels["__meta_kubernetes_pod_container_init"] = strconv.FormatBool(containerPort.Sidecar)
+		}
 
 		// Kubernetes Meta Labels
 		metaLabels["__meta_kubernetes_namespace"] = pod.Namespace
@@ -362,6 +627,18 @@ func (sd *ServiceDiscoveryImpl) processPodTarget(pod *corev1.Pod, config Discove
 		metaLabels["__meta_kubernetes_pod_node_name"] = pod.Spec.NodeName
 		metaLabels["__meta_kubernetes_pod_host_ip"] = pod.Status.HostIP
 		metaLabels["__meta_kubernetes_pod_uid"] = string(pod.UID)
+		if owner := controllerOf(pod.OwnerReferences); owner != nil {
+			metaLabels["__meta_kubernetes_pod_controller_kind"] = owner.Kind
+			metaLabels["__meta_kubernetes_pod_controller_name"] = owner.Name
+		}
+
+		// Workload labels are derived from owner references, so they stay the same across pod restarts and rollouts
+		if config.AddWorkloadLabels {
+			if kind, name := podWorkload(pod); kind != "" {
+				metaLabels["workload_kind"] = kind
+				metaLabels["workload_name"] = name
+			}
+		}
 
 		// Pod Labels
 		for k, v := range pod.Labels {
@@ -376,11 +653,19 @@ func (sd *ServiceDiscoveryImpl) processPodTarget(pod *corev1.Pod, config Discove
 		}
 
 		// 2. Apply Relabeling
-		finalLabels, keep := ProcessRelabelConfigs(metaLabels, config.RelabelConfigs)
+		finalLabels, url, keep, invalid := relabelTarget(metaLabels, config.RelabelConfigs)
 		if !keep {
-			if configPkg.IsDebugEnabled() {
-				logutil.Debugf("DISCOVERY", "Target dropped by relabel configuration: %s", targetID)
-			}
+			logutil.Tracef("DISCOVERY", "Target dropped by relabel configuration: %s", targetID)
+			continue
+		}
+
+		// Labels from labelTemplates replace the defaults and relabeling results
+		sd.applyLabelTemplates(config, finalLabels, metaLabels)
+		sd.applyClusterLabels(finalLabels)
+
+		// Resolve a templated tlsConfig.serverName for this target
+		endpoint, ok := sd.withServerName(endpoint, config, metaLabels)
+		if !ok {
 			continue
 		}
 
@@ -392,12 +677,34 @@ func (sd *ServiceDiscoveryImpl) processPodTarget(pod *corev1.Pod, config Discove
 			Metadata: map[string]interface{}{
 				"targetName":           config.TargetName,
 				"type":                 config.Type,
+				"priority":             config.Priority,
 				"endpoint":             endpoint,
 				"metricRelabelConfigs": endpoint.MetricRelabelConfigs,
 				"addNodeLabel":         endpoint.AddNodeLabel,
+				"objectRef":            k8s.ObjectRef{Kind: "Pod", Namespace: pod.Namespace, Name: pod.Name, UID: pod.UID},
+				"podGeneration":        podGeneration(pod),
 			},
 			LastSeen: time.Now(),
 		}
+		if settingSources != nil {
+			target.Metadata["settingSources"] = settingSources
+		}
+		// Scrape through the apiserver when the agent cannot reach pod IPs; instance keeps the pod address
+		if config.ProxyViaApiserver {
+			var host string
+			if restConfig := sd.k8sClient.RestConfig(); restConfig != nil {
+				host = restConfig.Host
+			}
+			proxyURL, err := apiServerProxyURL(host, pod.Namespace, pod.Name, url)
+			if err != nil {
+				logutil.Printf("WARN", "[DISCOVERY] Cannot scrape %s through the apiserver: %v", targetID, err)
+				continue
+			}
+			target.URL = proxyURL
+			target.Labels["scrape_via"] = ScrapeViaAPIServer
+			target.Metadata["proxyViaApiserver"] = true
+		}
+
 		// dear junnie
 		// Add node label if requested
 		// endpoint.AddNodeLabel means pod belongs to this Node, so we add 'node' label to metric&label cardinality
@@ -408,16 +715,43 @@ func (sd *ServiceDiscoveryImpl) processPodTarget(pod *corev1.Pod, config Discove
 			target.Labels["node"] = pod.Spec.NodeName
 		}
 
+		// Segment the series before and after a container restart, whose counters start over
+		if config.AddGenerationLabel {
+			target.Labels[GenerationLabel] = strconv.FormatInt(int64(podGeneration(pod).RestartCount), 10)
+		}
+
+		// Sums over a sampled target cover only the sampled pods
+		if config.samplesPods() {
+			target.Labels[SampledLabel] = "true"
+		}
+
+		// Distinguish data from not-ready pods when they are scraped anyway
+		if config.ScrapeNotReadyPods {
+			target.Labels["pod_ready"] = strconv.FormatBool(isReady)
+		}
+
 		// Set target state based on pod readiness
-		if isReady {
+		if isReady && !config.ScrapeNotReadyPods {
+			target.readyGracePeriod = config.ReadyGracePeriod
+			target.ReadySince = podReadySince(pod)
+		}
+		if isReady || config.ScrapeNotReadyPods {
 			target.State = TargetStateReady
+			if !isReady {
+				logutil.Tracef("DISCOVERY", "Pod %s/%s is not ready, scraping anyway (scrapeNotReadyPods)", pod.Namespace, pod.Name)
+			}
 		} else {
 			target.State = TargetStatePending
-			if configPkg.IsDebugEnabled() {
-				logutil.Debugf("DISCOVERY", "Pod %s/%s is not ready yet", pod.Namespace, pod.Name)
-			}
+			logutil.Tracef("DISCOVERY", "Pod %s/%s is not ready yet", pod.Namespace, pod.Name)
+		}
+
+		if invalid != nil {
+			target.markInvalid(invalid)
 		}
 
+		if !sd.admitTarget(target, config, time.Now()) {
+			continue
+		}
 		sd.updateTarget(target)
 		activeTargetIDs[target.ID] = true
 	}
@@ -444,73 +778,93 @@ func (sd *ServiceDiscoveryImpl) updateTarget(newTarget *Target) {
 	sd.targetsMutex.Lock()
 	defer sd.targetsMutex.Unlock()
 
-	_, exists := sd.targets[newTarget.ID]
+	prev, exists := sd.targets[newTarget.ID]
+	sd.applyReadyGrace(newTarget, prev)
+	if newTarget.State == TargetStateInvalid {
+		logInvalidTarget(newTarget, prev)
+	}
 
 	if !exists {
 		// New target
 		sd.targets[newTarget.ID] = newTarget
-		logutil.Infof("DISCOVERY", "Added new target: %s (state: %s)", newTarget.ID, newTarget.State)
+		logutil.Tracef("DISCOVERY", "Added new target: %s (state: %s)", newTarget.ID, newTarget.State)
+		if sd.stats != nil {
+			sd.stats.added++
+		}
 	} else {
 		// Always update target to ensure metadata changes are reflected
 		// This includes metricRelabelConfigs changes from ConfigMap updates
 		sd.targets[newTarget.ID] = newTarget
-		if configPkg.IsDebugEnabled() {
-			logutil.Debugf("DISCOVERY", "Updated target: %s (forced update to ensure metadata sync)", newTarget.ID)
+		logutil.Tracef("DISCOVERY", "Updated target: %s (forced update to ensure metadata sync)", newTarget.ID)
+		if sd.stats != nil {
+			sd.stats.updated++
 		}
 	}
 }
 
 // Helper methods (simplified versions of existing ScraperManager methods)
 
-func (sd *ServiceDiscoveryImpl) getMatchingNamespaces(namespaceSelector map[string]interface{}) ([]string, error) {
-	if namespaceSelector == nil {
+func (sd *ServiceDiscoveryImpl) getMatchingNamespaces(nsSelector *selector.NamespaceSelector) ([]string, error) {
+	if nsSelector == nil {
 		return []string{"default"}, nil
 	}
 
-	// Handle matchNames
-	if matchNames, ok := namespaceSelector["matchNames"].([]interface{}); ok {
-		var namespaces []string
-		for _, ns := range matchNames {
-			if nsStr, ok := ns.(string); ok {
-				namespaces = append(namespaces, nsStr)
-			} else {
-				logutil.Printf("WARN", "[DISCOVERY] Invalid namespace name type: %T", ns)
-			}
+	// matchNames alone needs no namespace lookup
+	if nsSelector.Labels.Empty() {
+		if len(nsSelector.MatchNames) == 0 {
+			return []string{"default"}, nil
 		}
-		if configPkg.IsDebugEnabled() {
-			logutil.Debugf("DISCOVERY", "Found %d matching namespaces", len(namespaces))
+		return nsSelector.MatchNames, nil
+	}
+
+	// matchLabels are evaluated by the client, matchNames and matchExpressions on the result
+	candidates, err := sd.k8sClient.GetNamespacesByLabels(nsSelector.Labels.MatchLabels)
+	if err != nil {
+		return nil, err
+	}
+	var namespaces []string
+	for _, ns := range candidates {
+		if nsSelector.Matches(ns.Name, ns.Labels) {
+			namespaces = append(namespaces, ns.Name)
 		}
-		return namespaces, nil
 	}
+	sort.Strings(namespaces)
+	if configPkg.IsDebugEnabled() {
+		logutil.Debugf("DISCOVERY", "Found %d namespaces matching %s", len(namespaces), nsSelector.Labels)
+	}
+	return namespaces, nil
+}
 
-	// For now, return default namespace if no specific selector
-	return []string{"default"}, nil
+// checkTargetSelector rejects a missing or empty pod or service selector rather than selecting
+// every object in the namespace
+func checkTargetSelector(labelSelector *selector.Selector) error {
+	if labelSelector == nil {
+		return fmt.Errorf("no selector provided")
+	}
+	if labelSelector.Empty() {
+		return fmt.Errorf("selector has no matchLabels or matchExpressions")
+	}
+	return nil
 }
 
-func (sd *ServiceDiscoveryImpl) getMatchingPods(namespace string, selector map[string]interface{}) ([]*corev1.Pod, error) {
-	if selector == nil {
-		logutil.Printf("ERROR", "[DISCOVERY] No selector provided for pod matching")
-		return nil, fmt.Errorf("no selector provided")
+func (sd *ServiceDiscoveryImpl) getMatchingPods(namespace string, labelSelector *selector.Selector) ([]*corev1.Pod, error) {
+	if err := checkTargetSelector(labelSelector); err != nil {
+		return nil, err
 	}
+	logutil.Tracef("DISCOVERY", "Matching pods in namespace %s with selector %s", namespace, labelSelector)
 
-	// Handle matchLabels
-	if matchLabels, ok := selector["matchLabels"].(map[string]interface{}); ok {
-		labelSelector := make(map[string]string)
-		for k, v := range matchLabels {
-			if vStr, ok := v.(string); ok {
-				labelSelector[k] = vStr
-			} else {
-				logutil.Printf("WARN", "[DISCOVERY] Invalid label value type for key %s: %T", k, v)
-			}
-		}
-		if configPkg.IsDebugEnabled() {
-			logutil.Debugf("DISCOVERY", "Matching pods in namespace %s with %d labels", namespace, len(labelSelector))
+	// matchLabels are evaluated by the client, matchExpressions on the result
+	pods, err := sd.k8sClient.GetPodsByLabels(namespace, labelSelector.MatchLabels)
+	if err != nil || len(labelSelector.MatchExpressions) == 0 {
+		return pods, err
+	}
+	matched := make([]*corev1.Pod, 0, len(pods))
+	for _, pod := range pods {
+		if labelSelector.Matches(pod.Labels) {
+			matched = append(matched, pod)
 		}
-		return sd.k8sClient.GetPodsByLabels(namespace, labelSelector)
 	}
-
-	logutil.Printf("ERROR", "[DISCOVERY] Unsupported selector type, expected matchLabels")
-	return nil, fmt.Errorf("unsupported selector type")
+	return matched, nil
 }
 
 func (sd *ServiceDiscoveryImpl) determineScheme(endpointScheme, port string, tlsConfig map[string]interface{}) string {
@@ -534,12 +888,7 @@ func (sd *ServiceDiscoveryImpl) determineScheme(endpointScheme, port string, tls
 
 // ServiceMonitor and StaticEndpoints discovery implementations
 func (sd *ServiceDiscoveryImpl) discoverServiceTargets(config DiscoveryConfig, activeTargetIDs map[string]bool) {
-	if configPkg.IsDebugEnabled() {
-		logutil.Debugf("DISCOVERY", "Discovering ServiceMonitor targets for %s", config.TargetName)
-	}
-
-	if !sd.k8sClient.IsInitialized() {
-		logutil.Printf("WARN", "Kubernetes client not initialized for ServiceMonitor: %s", config.TargetName)
+	if sd.k8sUnavailable(config) {
 		return
 	}
 
@@ -549,6 +898,7 @@ func (sd *ServiceDiscoveryImpl) discoverServiceTargets(config DiscoveryConfig, a
 		logutil.Printf("ERROR", "Failed to get namespaces for %s: %v", config.TargetName, err)
 		return
 	}
+	sd.countObjects(len(namespaces), 0)
 
 	for _, namespace := range namespaces {
 		// Get matching services
@@ -558,30 +908,33 @@ func (sd *ServiceDiscoveryImpl) discoverServiceTargets(config DiscoveryConfig, a
 			continue
 		}
 
+		services = filterExcludedServices(services, config)
+		sd.countObjects(0, len(services))
 		for _, service := range services {
+			logutil.Tracef("DISCOVERY", "ServiceMonitor %s - Processing service %s/%s", config.TargetName, service.Namespace, service.Name)
 			sd.processServiceTarget(service, config, activeTargetIDs)
 		}
 	}
 }
 
 // getMatchingServices gets services matching the selector in the given namespace
-func (sd *ServiceDiscoveryImpl) getMatchingServices(namespace string, selector map[string]interface{}) ([]*corev1.Service, error) {
-	if selector == nil {
-		return nil, fmt.Errorf("no selector provided")
+func (sd *ServiceDiscoveryImpl) getMatchingServices(namespace string, labelSelector *selector.Selector) ([]*corev1.Service, error) {
+	if err := checkTargetSelector(labelSelector); err != nil {
+		return nil, err
 	}
 
-	// Handle matchLabels
-	if matchLabels, ok := selector["matchLabels"].(map[string]interface{}); ok {
-		labelSelector := make(map[string]string)
-		for k, v := range matchLabels {
-			if vStr, ok := v.(string); ok {
-				labelSelector[k] = vStr
-			}
+	// matchLabels are evaluated by the client, matchExpressions on the result
+	services, err := sd.k8sClient.GetServicesByLabels(namespace, labelSelector.MatchLabels)
+	if err != nil || len(labelSelector.MatchExpressions) == 0 {
+		return services, err
+	}
+	matched := make([]*corev1.Service, 0, len(services))
+	for _, service := range services {
+		if labelSelector.Matches(service.Labels) {
+			matched = append(matched, service)
 		}
-		return sd.k8sClient.GetServicesByLabels(namespace, labelSelector)
 	}
-
-	return nil, fmt.Errorf("unsupported selector type")
+	return matched, nil
 }
 
 // processServiceTarget processes a single service target
@@ -592,6 +945,10 @@ func (sd *ServiceDiscoveryImpl) processServiceTarget(service *corev1.Service, co
 		logutil.Printf("ERROR", "Failed to get endpoints for service %s/%s: %v", service.Namespace, service.Name, err)
 		return
 	}
+	var localAddresses map[string]bool
+	if config.PreferLocalZone {
+		localAddresses = sd.localZoneAddresses(service, endpoints)
+	}
 
 	// Process each configured endpoint
 	for _, endpointConfig := range config.Endpoints {
@@ -626,14 +983,18 @@ func (sd *ServiceDiscoveryImpl) processServiceTarget(service *corev1.Service, co
 				}
 
 				if endpointPort == 0 {
-					if configPkg.IsDebugEnabled() {
-						logutil.Debugf("DISCOVERY", "Port %s not found in endpoints for service %s/%s", endpointConfig.Port, service.Namespace, service.Name)
-					}
+					logutil.Tracef("DISCOVERY", "Port %s not found in endpoints for service %s/%s", endpointConfig.Port, service.Namespace, service.Name)
 					continue
 				}
 
 				// Process ready addresses
 				for addrIdx, address := range subset.Addresses {
+					if sd.skipSelf(config, sd.self.isEndpointAddress(address), "endpoint "+address.IP) {
+						continue
+					}
+					if localAddresses != nil && !localAddresses[address.IP] {
+						continue
+					}
 					// Include path in targetID to ensure uniqueness when multiple endpoints use the same port
 					// Use / as separator to distinguish from hyphens in service names
 					pathSafe := strings.ReplaceAll(endpointConfig.Path, "/", "-")
@@ -642,18 +1003,11 @@ func (sd *ServiceDiscoveryImpl) processServiceTarget(service *corev1.Service, co
 					// Determine scheme
 					scheme := sd.determineScheme(endpointConfig.Scheme, endpointConfig.Port, endpointConfig.TLSConfig)
 
-					// Build target URL
-					path := endpointConfig.Path
-					baseURL := fmt.Sprintf("%s://%s:%d%s", scheme, address.IP, endpointPort, path)
-					url := buildURLWithParams(baseURL, endpointConfig.Params)
-
 					// 1. Create initial meta labels
 					metaLabels := make(map[string]string)
 					metaLabels["job"] = config.TargetName
-					metaLabels["__address__"] = fmt.Sprintf("%s:%d", address.IP, endpointPort)
+					setURLLabels(metaLabels, endpointHostPort(address.IP, endpointPort), scheme, endpointConfig.Path, endpointConfig.Params)
 					metaLabels["instance"] = metaLabels["__address__"] // Add default instance label
-					metaLabels["__scheme__"] = scheme
-					metaLabels["__metrics_path__"] = path
 
 					metaLabels["__meta_kubernetes_namespace"] = service.Namespace
 					metaLabels["__meta_kubernetes_service_name"] = service.Name
@@ -674,13 +1028,24 @@ func (sd *ServiceDiscoveryImpl) processServiceTarget(service *corev1.Service, co
 						metaLabels["__meta_kubernetes_pod_name"] = address.TargetRef.Name
 						metaLabels["__meta_kubernetes_pod_kind"] = address.TargetRef.Kind
 					}
+					if address.NodeName != nil {
+						metaLabels["__meta_kubernetes_endpoint_node_name"] = *address.NodeName
+					}
 
 					// 2. Apply Relabeling
-					finalLabels, keep := ProcessRelabelConfigs(metaLabels, config.RelabelConfigs)
+					finalLabels, url, keep, invalid := relabelTarget(metaLabels, config.RelabelConfigs)
 					if !keep {
-						if configPkg.IsDebugEnabled() {
-							logutil.Debugf("DISCOVERY", "Service target dropped by relabel configuration: %s", targetID)
-						}
+						logutil.Tracef("DISCOVERY", "Service target dropped by relabel configuration: %s", targetID)
+						continue
+					}
+
+					// Labels from labelTemplates replace the defaults and relabeling results
+					sd.applyLabelTemplates(config, finalLabels, metaLabels)
+					sd.applyClusterLabels(finalLabels)
+
+					// Resolve a templated tlsConfig.serverName for this target
+					endpointConfig, ok := sd.withServerName(endpointConfig, config, metaLabels)
+					if !ok {
 						continue
 					}
 
@@ -692,22 +1057,36 @@ func (sd *ServiceDiscoveryImpl) processServiceTarget(service *corev1.Service, co
 						Metadata: map[string]interface{}{
 							"targetName":           config.TargetName,
 							"type":                 config.Type,
+							"priority":             config.Priority,
 							"endpoint":             endpointConfig,
 							"metricRelabelConfigs": endpointConfig.MetricRelabelConfigs,
+							"objectRef":            k8s.ObjectRef{Kind: "Service", Namespace: service.Namespace, Name: service.Name, UID: service.UID},
 						},
 						State:    TargetStateReady, // Service endpoints are ready if they're in the addresses list
 						LastSeen: time.Now(),
 					}
+					if config.ScrapeNotReadyPods {
+						target.Labels["pod_ready"] = "true"
+					} else {
+						target.readyGracePeriod = config.ReadyGracePeriod
+					}
+					if invalid != nil {
+						target.markInvalid(invalid)
+					}
 
 					sd.updateTarget(target)
 					activeTargetIDs[target.ID] = true
-					if configPkg.IsDebugEnabled() {
-						logutil.Debugf("DISCOVERY", "Added ServiceMonitor target: %s", targetID)
-					}
+					logutil.Tracef("DISCOVERY", "Added ServiceMonitor target: %s", targetID)
 				}
 
 				// Process not-ready addresses as pending
 				for addrIdx, address := range subset.NotReadyAddresses {
+					if sd.skipSelf(config, sd.self.isEndpointAddress(address), "endpoint "+address.IP) {
+						continue
+					}
+					if localAddresses != nil && !localAddresses[address.IP] {
+						continue
+					}
 					// Include path in targetID to ensure uniqueness when multiple endpoints use the same port
 					pathSafe := strings.ReplaceAll(endpointConfig.Path, "/", "-")
 					targetID := fmt.Sprintf("%s-%s-%s-%s-%d-nr-%d-%s", config.TargetName, service.Namespace, service.Name, endpointConfig.Port, subsetIdx, addrIdx, pathSafe)
@@ -715,18 +1094,11 @@ func (sd *ServiceDiscoveryImpl) processServiceTarget(service *corev1.Service, co
 					// Determine scheme
 					scheme := sd.determineScheme(endpointConfig.Scheme, endpointConfig.Port, endpointConfig.TLSConfig)
 
-					// Build target URL
-					path := endpointConfig.Path
-					baseURL := fmt.Sprintf("%s://%s:%d%s", scheme, address.IP, endpointPort, path)
-					url := buildURLWithParams(baseURL, endpointConfig.Params)
-
 					// 1. Create initial meta labels
 					metaLabels := make(map[string]string)
 					metaLabels["job"] = config.TargetName
-					metaLabels["__address__"] = fmt.Sprintf("%s:%d", address.IP, endpointPort)
+					setURLLabels(metaLabels, endpointHostPort(address.IP, endpointPort), scheme, endpointConfig.Path, endpointConfig.Params)
 					metaLabels["instance"] = metaLabels["__address__"] // Add default instance label
-					metaLabels["__scheme__"] = scheme
-					metaLabels["__metrics_path__"] = path
 
 					metaLabels["__meta_kubernetes_namespace"] = service.Namespace
 					metaLabels["__meta_kubernetes_service_name"] = service.Name
@@ -747,13 +1119,24 @@ func (sd *ServiceDiscoveryImpl) processServiceTarget(service *corev1.Service, co
 						metaLabels["__meta_kubernetes_pod_name"] = address.TargetRef.Name
 						metaLabels["__meta_kubernetes_pod_kind"] = address.TargetRef.Kind
 					}
+					if address.NodeName != nil {
+						metaLabels["__meta_kubernetes_endpoint_node_name"] = *address.NodeName
+					}
 
 					// 2. Apply Relabeling
-					finalLabels, keep := ProcessRelabelConfigs(metaLabels, config.RelabelConfigs)
+					finalLabels, url, keep, invalid := relabelTarget(metaLabels, config.RelabelConfigs)
 					if !keep {
-						if configPkg.IsDebugEnabled() {
-							logutil.Debugf("DISCOVERY", "Service target dropped by relabel configuration: %s", targetID)
-						}
+						logutil.Tracef("DISCOVERY", "Service target dropped by relabel configuration: %s", targetID)
+						continue
+					}
+
+					// Labels from labelTemplates replace the defaults and relabeling results
+					sd.applyLabelTemplates(config, finalLabels, metaLabels)
+					sd.applyClusterLabels(finalLabels)
+
+					// Resolve a templated tlsConfig.serverName for this target
+					endpointConfig, ok := sd.withServerName(endpointConfig, config, metaLabels)
+					if !ok {
 						continue
 					}
 
@@ -765,32 +1148,38 @@ func (sd *ServiceDiscoveryImpl) processServiceTarget(service *corev1.Service, co
 						Metadata: map[string]interface{}{
 							"targetName":           config.TargetName,
 							"type":                 config.Type,
+							"priority":             config.Priority,
 							"endpoint":             endpointConfig,
 							"metricRelabelConfigs": endpointConfig.MetricRelabelConfigs,
+							"objectRef":            k8s.ObjectRef{Kind: "Service", Namespace: service.Namespace, Name: service.Name, UID: service.UID},
 						},
 						State:    TargetStatePending, // Not ready endpoints are pending
 						LastSeen: time.Now(),
 					}
+					if config.ScrapeNotReadyPods {
+						// Scrape not-ready endpoints anyway, labeled so they can be told apart
+						target.State = TargetStateReady
+						target.Labels["pod_ready"] = "false"
+					}
+					if invalid != nil {
+						target.markInvalid(invalid)
+					}
 
+					if !sd.admitTarget(target, config, time.Now()) {
+						continue
+					}
 					sd.updateTarget(target)
 					activeTargetIDs[target.ID] = true
-					if configPkg.IsDebugEnabled() {
-						logutil.Debugf("DISCOVERY", "Added pending ServiceMonitor target: %s", targetID)
-					}
+					logutil.Tracef("DISCOVERY", "Added pending ServiceMonitor target: %s", targetID)
 				}
 			}
 		} else {
-			if configPkg.IsDebugEnabled() {
-				logutil.Debugf("DISCOVERY", "No endpoints found for service %s/%s", service.Namespace, service.Name)
-			}
+			logutil.Tracef("DISCOVERY", "No endpoints found for service %s/%s", service.Namespace, service.Name)
 		}
 	}
 }
 
 func (sd *ServiceDiscoveryImpl) discoverStaticTargets(config DiscoveryConfig, activeTargetIDs map[string]bool) {
-	if configPkg.IsDebugEnabled() {
-		logutil.Debugf("DISCOVERY", "Discovering StaticEndpoints targets for %s", config.TargetName)
-	}
 
 	// StaticEndpoints don't require Kubernetes API - just process the configured endpoints
 	if len(config.Endpoints) == 0 {
@@ -804,6 +1193,10 @@ func (sd *ServiceDiscoveryImpl) discoverStaticTargets(config DiscoveryConfig, ac
 			logutil.Printf("WARN", "Empty address in endpoint %d for StaticEndpoints target: %s", i, config.TargetName)
 			continue
 		}
+		sd.countObjects(0, 1)
+		if sd.skipSelf(config, sd.self.isStaticAddress(endpoint.Address), "admin server "+endpoint.Address) {
+			continue
+		}
 
 		// Determine scheme
 		scheme := endpoint.Scheme
@@ -826,21 +1219,37 @@ func (sd *ServiceDiscoveryImpl) discoverStaticTargets(config DiscoveryConfig, ac
 		pathSafe := strings.ReplaceAll(path, "/", "-")
 		targetID := fmt.Sprintf("%s-static-%d-%s", config.TargetName, i, pathSafe)
 
-		// Build target URL
-		baseURL := fmt.Sprintf("%s://%s%s", scheme, endpoint.Address, path)
-		url := buildURLWithParams(baseURL, endpoint.Params)
+		metaLabels := map[string]string{
+			"job": config.TargetName,
+		}
+		setURLLabels(metaLabels, endpoint.Address, scheme, path, endpoint.Params)
+		metaLabels["instance"] = endpoint.Address
+
+		finalLabels, url, keep, invalid := relabelTarget(metaLabels, config.RelabelConfigs)
+		if !keep {
+			logutil.Tracef("DISCOVERY", "Static target dropped by relabel configuration: %s", targetID)
+			continue
+		}
+
+		// Labels from labelTemplates replace the defaults and relabeling results
+		sd.applyLabelTemplates(config, finalLabels, metaLabels)
+		sd.applyClusterLabels(finalLabels)
+
+		// Resolve a templated tlsConfig.serverName for this target
+		endpoint, ok := sd.withServerName(endpoint, config, metaLabels)
+		if !ok {
+			continue
+		}
 
 		// Create target
 		target := &Target{
-			ID:  targetID,
-			URL: url,
-			Labels: map[string]string{
-				"job":      config.TargetName,
-				"instance": endpoint.Address,
-			},
+			ID:     targetID,
+			URL:    url,
+			Labels: finalLabels,
 			Metadata: map[string]interface{}{
 				"targetName":           config.TargetName,
 				"type":                 config.Type,
+				"priority":             config.Priority,
 				"endpoint":             endpoint,
 				"metricRelabelConfigs": endpoint.MetricRelabelConfigs,
 				"address":              endpoint.Address,
@@ -848,164 +1257,281 @@ func (sd *ServiceDiscoveryImpl) discoverStaticTargets(config DiscoveryConfig, ac
 			State:    TargetStateReady, // Static endpoints are always ready
 			LastSeen: time.Now(),
 		}
+		if invalid != nil {
+			target.markInvalid(invalid)
+		}
 
 		sd.updateTarget(target)
 		activeTargetIDs[target.ID] = true
-		if configPkg.IsDebugEnabled() {
-			logutil.Debugf("DISCOVERY", "Added StaticEndpoints target: %s (URL: %s)", targetID, url)
-		}
+		logutil.Tracef("DISCOVERY", "Added StaticEndpoints target: %s (URL: %s)", targetID, url)
 	}
 }
 
-// parseDiscoveryConfig parses target configuration into DiscoveryConfig
+// endpointHostPort joins an endpoint address and port. IPv6 addresses are bracketed, and host names of
+// FQDN EndpointSlices are kept as is and resolved by the scraper.
+func endpointHostPort(address string, port int32) string {
+	return net.JoinHostPort(address, strconv.Itoa(int(port)))
+}
+
+// parseDiscoveryConfig parses an untyped target configuration into DiscoveryConfig.
+// Compatibility shim for callers that still pass maps; targets are normally decoded by ConfigManager.
 func (sd *ServiceDiscoveryImpl) parseDiscoveryConfig(targetConfig map[string]interface{}) (DiscoveryConfig, error) {
-	discoveryConfig := DiscoveryConfig{
-		Enabled: true, // Default to enabled
+	target, warnings, err := configPkg.DecodeTargetConfig(targetConfig)
+	for _, warning := range warnings {
+		logutil.Printf("WARN", "[DISCOVERY] Target %v: %s", targetConfig["targetName"], warning)
 	}
-
-	// Parse basic fields
-	if targetName, ok := targetConfig["targetName"].(string); ok {
-		discoveryConfig.TargetName = targetName
+	if err != nil {
+		return DiscoveryConfig{}, err
 	}
+	return sd.discoveryConfigFromTarget(target), nil
+}
 
-	if targetType, ok := targetConfig["type"].(string); ok {
-		discoveryConfig.Type = targetType
+// discoveryConfigFromTarget converts a decoded target into DiscoveryConfig
+func (sd *ServiceDiscoveryImpl) discoveryConfigFromTarget(target configPkg.TargetConfig) DiscoveryConfig {
+	switch target.Type {
+	case WhatapAgentsType:
+		target = withWhatapAgentsDefaults(target)
+	case EtcdMonitorType:
+		target = withEtcdMonitorDefaults(target)
+	}
+	discoveryConfig := DiscoveryConfig{
+		TargetName:         target.TargetName,
+		Type:               target.Type,
+		Enabled:            target.IsEnabled(),
+		NamespaceSelector:  target.NamespaceSelector,
+		Selector:           target.Selector,
+		ExcludeSelector:    target.ExcludeSelector,
+		RelabelConfigs:     target.RelabelConfigs,
+		ScrapeNotReadyPods: target.ScrapeNotReadyPods,
+		MetricPrefix:       target.MetricPrefix,
+		AllowSelfScrape:    target.AllowSelfScrape,
+	}
+	discoveryConfig.IgnorePendingTargets = target.TrackPendingTargets != nil && !*target.TrackPendingTargets
+	discoveryConfig.Priority = target.Priority
+	discoveryConfig.LabelTemplates = target.LabelTemplates
+
+	if target.ProxyViaApiserver {
+		if !isPodTargetType(discoveryConfig.Type) {
+			logutil.Printf("WARN", "[DISCOVERY] proxyViaApiserver is only supported for PodMonitor targets, ignoring it for %s", discoveryConfig.TargetName)
+		} else {
+			discoveryConfig.ProxyViaApiserver = true
+		}
 	}
 
-	if enabled, ok := targetConfig["enabled"].(bool); ok {
-		discoveryConfig.Enabled = enabled
+	if target.PreferLocalZone {
+		if discoveryConfig.Type != "ServiceMonitor" {
+			logutil.Printf("WARN", "[DISCOVERY] preferLocalZone is only supported for ServiceMonitor targets, ignoring it for %s", discoveryConfig.TargetName)
+		} else {
+			discoveryConfig.PreferLocalZone = true
+		}
 	}
 
-	// Parse namespace selector
-	if namespaceSelector, ok := targetConfig["namespaceSelector"].(map[string]interface{}); ok {
-		discoveryConfig.NamespaceSelector = namespaceSelector
+	if target.AddWorkloadLabels {
+		if !isPodTargetType(discoveryConfig.Type) {
+			logutil.Printf("WARN", "[DISCOVERY] addWorkloadLabels is only supported for PodMonitor targets, ignoring it for %s", discoveryConfig.TargetName)
+		} else {
+			discoveryConfig.AddWorkloadLabels = true
+		}
 	}
 
-	// Parse selector
-	if selector, ok := targetConfig["selector"].(map[string]interface{}); ok {
-		discoveryConfig.Selector = selector
+	if target.AllowPodAnnotationsOverride {
+		if !isPodTargetType(discoveryConfig.Type) {
+			logutil.Printf("WARN", "[DISCOVERY] allowPodAnnotationsOverride is only supported for PodMonitor targets, ignoring it for %s", discoveryConfig.TargetName)
+		} else {
+			discoveryConfig.AllowPodAnnotationsOverride = true
+		}
 	}
 
-	// Parse relabelConfigs
-	if relabelConfigs, ok := targetConfig["relabelConfigs"].([]interface{}); ok {
-		discoveryConfig.RelabelConfigs = model.ParseRelabelConfigs(relabelConfigs)
+	if target.AddGenerationLabel {
+		if !isPodTargetType(discoveryConfig.Type) {
+			logutil.Printf("WARN", "[DISCOVERY] addGenerationLabel is only supported for PodMonitor targets, ignoring it for %s", discoveryConfig.TargetName)
+		} else {
+			discoveryConfig.AddGenerationLabel = true
+		}
 	}
 
-	// Parse endpoints
-	if endpoints, ok := targetConfig["endpoints"].([]interface{}); ok {
-		discoveryConfig.Endpoints = make([]EndpointConfig, 0, len(endpoints))
-		for _, ep := range endpoints {
-			if epMap, ok := ep.(map[string]interface{}); ok {
-				endpointConfig := sd.parseEndpointConfig(epMap)
-				discoveryConfig.Endpoints = append(discoveryConfig.Endpoints, endpointConfig)
+	if target.SampleRate != nil || target.MaxPods > 0 {
+		if !isPodTargetType(discoveryConfig.Type) {
+			logutil.Printf("WARN", "[DISCOVERY] sampleRate and maxPods are only supported for PodMonitor targets, ignoring them for %s", discoveryConfig.TargetName)
+		} else {
+			if target.SampleRate != nil && *target.SampleRate < 1 {
+				discoveryConfig.SampleRate = *target.SampleRate
 			}
+			discoveryConfig.MaxPods = target.MaxPods
 		}
 	}
 
-	return discoveryConfig, nil
-}
-
-func (sd *ServiceDiscoveryImpl) parseEndpointConfig(endpointMap map[string]interface{}) EndpointConfig {
-	endpointConfig := EndpointConfig{}
-
-	if port, ok := endpointMap["port"].(string); ok {
-		endpointConfig.Port = port
+	if target.ReadyGracePeriod != "" {
+		if d, err := time.ParseDuration(target.ReadyGracePeriod); err != nil || d < 0 {
+			logutil.Printf("WARN", "[DISCOVERY] Ignoring invalid readyGracePeriod %q for target %s", target.ReadyGracePeriod, discoveryConfig.TargetName)
+		} else {
+			discoveryConfig.ReadyGracePeriod = d
+		}
 	}
 
-	if address, ok := endpointMap["address"].(string); ok {
-		endpointConfig.Address = address
+	// Parse aggregation rules, validated when the target was decoded
+	if target.Aggregations != nil {
+		rules, err := model.ParseAggregations(target.Aggregations)
+		if err != nil {
+			logutil.Printf("WARN", "[DISCOVERY] Ignoring aggregations for target %s: %v", discoveryConfig.TargetName, err)
+		} else {
+			discoveryConfig.Aggregations = rules
+		}
 	}
 
-	if path, ok := endpointMap["path"].(string); ok {
-		endpointConfig.Path = path
-	}
+	// Parse exclusions applied after the positive selector match
+	// The patterns were validated when the target was decoded
+	discoveryConfig.ExcludePodNames, _ = configPkg.CompileNamePatterns(target.ExcludePodNames)
+	discoveryConfig.ExcludeServiceNames, _ = configPkg.CompileNamePatterns(target.ExcludeServiceNames)
 
-	if scheme, ok := endpointMap["scheme"].(string); ok {
-		endpointConfig.Scheme = scheme
+	// Parse endpoints
+	if target.Endpoints != nil {
+		discoveryConfig.Endpoints = make([]EndpointConfig, 0, len(target.Endpoints))
+		for _, ep := range target.Endpoints {
+			endpointConfig := sd.parseEndpointConfig(ep)
+			// Endpoints inherit the target-level metricPrefix
+			if endpointConfig.MetricPrefix == "" {
+				endpointConfig.MetricPrefix = discoveryConfig.MetricPrefix
+			}
+			endpointConfig.Aggregations = discoveryConfig.Aggregations
+			// The global and target metric relabel configs, already concatenated by the config, run first
+			endpointConfig.MetricRelabelConfigs = configPkg.ConcatRelabelConfigs(target.MetricRelabelConfigs, endpointConfig.MetricRelabelConfigs)
+			discoveryConfig.Endpoints = append(discoveryConfig.Endpoints, expandEndpoint(ep, endpointConfig)...)
+		}
 	}
 
-	if interval, ok := endpointMap["interval"].(string); ok {
-		endpointConfig.Interval = interval
-	}
+	return discoveryConfig
+}
 
-	if timeout, ok := endpointMap["timeout"].(string); ok {
-		endpointConfig.Timeout = timeout
+func (sd *ServiceDiscoveryImpl) parseEndpointConfig(ep configPkg.EndpointConfig) EndpointConfig {
+	endpointConfig := EndpointConfig{
+		Port:                 ep.Port,
+		Address:              ep.Address,
+		Scheme:               ep.Scheme,
+		Interval:             ep.Interval,
+		Timeout:              ep.Timeout,
+		ConnectTimeout:       ep.ConnectTimeout,
+		ReadTimeout:          ep.ReadTimeout,
+		TLSConfig:            ep.TLSConfig,
+		BasicAuth:            ep.BasicAuth,
+		MetricRelabelConfigs: ep.MetricRelabelConfigs,
+		AddNodeLabel:         ep.AddNodeLabel,
+		Params:               ep.Params,
+		SecretParams:         configPkg.SecretParams(ep.Params),
+		Headers:              ep.Headers,
+		MetricPrefix:         ep.MetricPrefix,
+	}
+	if !ep.Path.IsList && len(ep.Path.Values) == 1 {
+		endpointConfig.Path = ep.Path.Values[0]
+	}
+	endpointConfig.PreserveAgentNodeLabel = ep.PreserveAgentNodeLabel
+	endpointConfig.AcceptProtobuf = ep.AcceptProtobuf
+	endpointConfig.ExternalLabels = ep.ExternalLabels
+	endpointConfig.SampleLimit = ep.SampleLimit
+	endpointConfig.DisableDNSCache = ep.DNSCache != nil && !*ep.DNSCache
+
+	// Federation carries the original job, instance and timestamps, which are kept by default
+	if ep.Federate != nil {
+		if endpointConfig.Path == "" && !ep.Path.IsList {
+			endpointConfig.Path = configPkg.FederatePath
+		}
+		endpointConfig.MatchSelectors = ep.Federate.MatchSelectors
+		endpointConfig.HonorLabels = ep.Federate.HonorsLabels()
+		endpointConfig.IgnoreTimestamps = !ep.Federate.HonorsTimestamps()
 	}
 
-	if tlsConfig, ok := endpointMap["tlsConfig"].(map[string]interface{}); ok {
-		endpointConfig.TLSConfig = tlsConfig
+	// Parse unit conversion rules
+	if ep.UnitConversions != nil {
+		rules, err := model.ParseUnitConversions(ep.UnitConversions)
+		if err != nil {
+			logutil.Printf("WARN", "[DISCOVERY] Ignoring unitConversions: %v", err)
+		} else {
+			endpointConfig.UnitConversions = rules
+		}
 	}
 
-	if basicAuth, ok := endpointMap["basicAuth"].(map[string]interface{}); ok {
-		authConfig := &configPkg.BasicAuthConfig{}
-		if username, ok := basicAuth["username"].(map[string]interface{}); ok {
-			authConfig.Username = parseSecretKeySelector(username)
+	// Parse value transforms, validated when the target was decoded
+	if ep.ValueTransforms != nil {
+		rules, err := model.ParseValueTransforms(ep.ValueTransforms)
+		if err != nil {
+			logutil.Printf("WARN", "[DISCOVERY] Ignoring valueTransforms: %v", err)
+		} else {
+			endpointConfig.ValueTransforms = rules
 		}
-		if password, ok := basicAuth["password"].(map[string]interface{}); ok {
-			authConfig.Password = parseSecretKeySelector(password)
+	}
+
+	// Parse info metric label joins
+	if ep.InfoJoin != nil {
+		joins, err := model.ParseInfoJoins(ep.InfoJoin)
+		if err != nil {
+			logutil.Printf("WARN", "[DISCOVERY] Ignoring infoJoin: %v", err)
+		} else {
+			endpointConfig.InfoJoins = joins
 		}
-		endpointConfig.BasicAuth = authConfig
 	}
 
-	if metricRelabelConfigs, ok := endpointMap["metricRelabelConfigs"].([]interface{}); ok {
-		endpointConfig.MetricRelabelConfigs = metricRelabelConfigs
+	// Parse the label value length limit
+	labelLengthLimit, err := model.ParseLabelLengthLimit(ep.LabelValueLengthLimit, ep.LabelValueLengthMode)
+	if err != nil {
+		logutil.Printf("WARN", "[DISCOVERY] Ignoring label value length limit: %v", err)
+	} else {
+		endpointConfig.LabelLengthLimit = labelLengthLimit
 	}
 
-	if addNodeLabel, ok := endpointMap["addNodeLabel"].(bool); ok {
-		endpointConfig.AddNodeLabel = addNodeLabel
+	// Parse the timestamp alignment
+	timestampAlignment, err := model.ParseTimestampAlignment(ep.TimestampAlignment)
+	if err != nil {
+		logutil.Printf("WARN", "[DISCOVERY] Ignoring timestampAlignment: %v", err)
 	}
+	endpointConfig.TimestampAlignment = timestampAlignment
 
-	// Parse params for HTTP URL parameters
-	if params, ok := endpointMap["params"].(map[string]interface{}); ok {
-		endpointConfig.Params = params
+	// Parse the NaN/Inf value policy
+	nonFiniteValues, err := model.ParseNonFiniteValues(ep.NonFiniteValues)
+	if err != nil {
+		logutil.Printf("WARN", "[DISCOVERY] Ignoring nonFiniteValues: %v", err)
 	}
+	endpointConfig.NonFiniteValues = nonFiniteValues
 
-	// Parse adaptiveTimeout configuration with defaults
-	if adaptiveTimeoutMap, ok := endpointMap["adaptiveTimeout"].(map[string]interface{}); ok {
-		adaptiveTimeout := &AdaptiveTimeoutConfig{
-			Enabled:          true, // Default: enabled
-			FailureThreshold: 2,    // Default: 2 consecutive failures
-			Multiplier:       2.0,  // Default: 2x multiplier
+	// Check the expected sample count of successful scrapes
+	if ep.MinSamples < 0 {
+		logutil.Printf("WARN", "[DISCOVERY] Ignoring minSamples %d: must not be negative", ep.MinSamples)
+	} else {
+		endpointConfig.MinSamples = ep.MinSamples
+	}
+
+	// Parse downsample window aggregation
+	if ep.Downsample != "" {
+		downsampleConfig, err := model.ParseDownsampleConfig(ep.Downsample)
+		if err != nil {
+			logutil.Printf("WARN", "[DISCOVERY] Ignoring downsample setting: %v", err)
+		} else {
+			endpointConfig.Downsample = downsampleConfig
 		}
+	}
 
+	// Parse adaptiveTimeout configuration with defaults
+	adaptiveTimeout := &AdaptiveTimeoutConfig{
+		Enabled:          true, // Default: enabled
+		FailureThreshold: 2,    // Default: 2 consecutive failures
+		Multiplier:       2.0,  // Default: 2x multiplier
+	}
+	if ep.AdaptiveTimeout != nil {
 		// Override defaults if provided
-		if enabled, ok := adaptiveTimeoutMap["enabled"].(bool); ok {
-			adaptiveTimeout.Enabled = enabled
+		if ep.AdaptiveTimeout.Enabled != nil {
+			adaptiveTimeout.Enabled = *ep.AdaptiveTimeout.Enabled
 		}
-		if failureThreshold, ok := adaptiveTimeoutMap["failureThreshold"].(int); ok {
-			adaptiveTimeout.FailureThreshold = failureThreshold
+		if ep.AdaptiveTimeout.FailureThreshold > 0 {
+			adaptiveTimeout.FailureThreshold = ep.AdaptiveTimeout.FailureThreshold
 		}
-		if multiplier, ok := adaptiveTimeoutMap["multiplier"].(float64); ok {
-			adaptiveTimeout.Multiplier = multiplier
-		}
-
-		endpointConfig.AdaptiveTimeout = adaptiveTimeout
-	} else {
-		// If adaptiveTimeout is not specified, use defaults
-		endpointConfig.AdaptiveTimeout = &AdaptiveTimeoutConfig{
-			Enabled:          true, // Default: enabled
-			FailureThreshold: 2,    // Default: 2 consecutive failures
-			Multiplier:       2.0,  // Default: 2x multiplier
+		if ep.AdaptiveTimeout.Multiplier > 0 {
+			adaptiveTimeout.Multiplier = ep.AdaptiveTimeout.Multiplier
 		}
 	}
+	endpointConfig.AdaptiveTimeout = adaptiveTimeout
 
 	return endpointConfig
 }
 
-func parseSecretKeySelector(m map[string]interface{}) *configPkg.SecretKeySelector {
-	s := &configPkg.SecretKeySelector{}
-	if name, ok := m["name"].(string); ok {
-		s.Name = name
-	}
-	if key, ok := m["key"].(string); ok {
-		s.Key = key
-	}
-	if ns, ok := m["namespace"].(string); ok {
-		s.Namespace = ns
-	}
-	return s
-}
-
 // sanitizeLabelName replaces invalid characters in label names with underscores
 func sanitizeLabelName(name string) string {
 	reg := regexp.MustCompile("[^a-zA-Z0-9_]")
@@ -0,0 +1,171 @@
+package discovery
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func newTestPod(name, ip string, ready bool) *corev1.Pod {
+	status := corev1.ConditionFalse
+	if ready {
+		status = corev1.ConditionTrue
+	}
+	phase := corev1.PodRunning
+	if ip == "" {
+		phase = corev1.PodPending
+	}
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Status: corev1.PodStatus{
+			Phase: phase,
+			PodIP: ip,
+			Conditions: []corev1.PodCondition{
+				{Type: corev1.PodReady, Status: status},
+			},
+		},
+	}
+}
+
+func newTestPodConfig(scrapeNotReady bool) DiscoveryConfig {
+	return DiscoveryConfig{
+		TargetName:         "app",
+		Type:               "PodMonitor",
+		Enabled:            true,
+		Endpoints:          []EndpointConfig{{Port: "8080", Path: "/metrics"}},
+		ScrapeNotReadyPods: scrapeNotReady,
+	}
+}
+
+func processSinglePod(pod *corev1.Pod, config DiscoveryConfig) *Target {
+	sd := &ServiceDiscoveryImpl{targets: make(map[string]*Target)}
+	sd.processPodTarget(pod, config, make(map[string]bool))
+	for _, t := range sd.targets {
+		return t
+	}
+	return nil
+}
+
+func TestProcessPodTarget_ReadyPod(t *testing.T) {
+	for _, scrapeNotReady := range []bool{false, true} {
+		target := processSinglePod(newTestPod("ready", "10.0.0.1", true), newTestPodConfig(scrapeNotReady))
+		if target == nil {
+			t.Fatalf("scrapeNotReadyPods=%v: expected a target for a ready pod", scrapeNotReady)
+		}
+		if target.State != TargetStateReady {
+			t.Errorf("scrapeNotReadyPods=%v: expected state ready, got %s", scrapeNotReady, target.State)
+		}
+		if scrapeNotReady && target.Labels["pod_ready"] != "true" {
+			t.Errorf("expected pod_ready=true, got %q", target.Labels["pod_ready"])
+		}
+		if !scrapeNotReady {
+			if _, ok := target.Labels["pod_ready"]; ok {
+				t.Errorf("expected no pod_ready label when scrapeNotReadyPods is disabled")
+			}
+		}
+	}
+}
+
+func TestProcessPodTarget_NotReadyPodWithIP(t *testing.T) {
+	pod := newTestPod("warming", "10.0.0.2", false)
+
+	target := processSinglePod(pod, newTestPodConfig(false))
+	if target == nil || target.State != TargetStatePending {
+		t.Fatalf("expected a pending target by default, got %+v", target)
+	}
+
+	target = processSinglePod(pod, newTestPodConfig(true))
+	if target == nil {
+		t.Fatalf("expected a target with scrapeNotReadyPods enabled")
+	}
+	if target.State != TargetStateReady {
+		t.Errorf("expected state ready with scrapeNotReadyPods, got %s", target.State)
+	}
+	if target.Labels["pod_ready"] != "false" {
+		t.Errorf("expected pod_ready=false, got %q", target.Labels["pod_ready"])
+	}
+}
+
+func TestProcessPodTarget_NoIPPodSkipped(t *testing.T) {
+	for _, scrapeNotReady := range []bool{false, true} {
+		if target := processSinglePod(newTestPod("pending", "", false), newTestPodConfig(scrapeNotReady)); target != nil {
+			t.Errorf("scrapeNotReadyPods=%v: expected pod without IP to be skipped, got %s", scrapeNotReady, target.ID)
+		}
+	}
+}
+
+func TestParseDiscoveryConfig_ScrapeNotReadyPods(t *testing.T) {
+	sd := &ServiceDiscoveryImpl{}
+	cfg, err := sd.parseDiscoveryConfig(map[string]interface{}{
+		"targetName":         "app",
+		"type":               "PodMonitor",
+		"scrapeNotReadyPods": true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cfg.ScrapeNotReadyPods {
+		t.Errorf("expected ScrapeNotReadyPods to be parsed as true")
+	}
+}
+
+func TestParseDiscoveryConfig_MetricPrefixInheritance(t *testing.T) {
+	sd := &ServiceDiscoveryImpl{}
+	cfg, err := sd.parseDiscoveryConfig(map[string]interface{}{
+		"targetName":   "vendor-appliance",
+		"type":         "StaticEndpoints",
+		"metricPrefix": "vendor_",
+		"endpoints": []interface{}{
+			map[string]interface{}{"address": "10.0.0.1:9100"},
+			map[string]interface{}{"address": "10.0.0.2:9100", "metricPrefix": "other_"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Endpoints[0].MetricPrefix != "vendor_" || cfg.Endpoints[1].MetricPrefix != "other_" {
+		t.Errorf("unexpected prefixes %q, %q", cfg.Endpoints[0].MetricPrefix, cfg.Endpoints[1].MetricPrefix)
+	}
+}
+
+func TestProcessServiceTarget_AddressForms(t *testing.T) {
+	provider := &fakeProvider{endpoints: map[string]*corev1.Endpoints{
+		"monitoring/exporter": {
+			Subsets: []corev1.EndpointSubset{{
+				Addresses: []corev1.EndpointAddress{{IP: "10.0.1.5"}, {IP: "fd00::5"}, {IP: "metrics.example.com"}},
+				Ports:     []corev1.EndpointPort{{Name: "metrics", Port: 9100}},
+			}},
+		},
+	}}
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "exporter", Namespace: "monitoring"},
+		Spec: corev1.ServiceSpec{Ports: []corev1.ServicePort{
+			{Name: "metrics", Port: 9100, TargetPort: intstr.FromInt(9100)},
+		}},
+	}
+	config := DiscoveryConfig{
+		TargetName: "exporters",
+		Type:       "ServiceMonitor",
+		Enabled:    true,
+		Endpoints:  []EndpointConfig{{Port: "metrics", Path: "/metrics"}},
+	}
+	sd := &ServiceDiscoveryImpl{k8sClient: provider, targets: make(map[string]*Target)}
+	sd.processServiceTarget(service, config, make(map[string]bool))
+
+	want := map[string]string{
+		"10.0.1.5:9100":            "http://10.0.1.5:9100/metrics",
+		"[fd00::5]:9100":           "http://[fd00::5]:9100/metrics",
+		"metrics.example.com:9100": "http://metrics.example.com:9100/metrics",
+	}
+	if len(sd.targets) != len(want) {
+		t.Fatalf("expected %d targets, got %d", len(want), len(sd.targets))
+	}
+	for _, target := range sd.targets {
+		url, ok := want[target.Labels["instance"]]
+		if !ok || target.URL != url {
+			t.Errorf("unexpected target instance=%q URL=%q", target.Labels["instance"], target.URL)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package discovery
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"open-agent/tools/util/logutil"
+)
+
+// checkURLLabels checks the post-relabel labels a scrape URL is built from, and the URL built from
+// them. A bad address, scheme or path would otherwise only show up as a confusing transport error on
+// the first scrape, or be silently rewritten by the URL encoder.
+func checkURLLabels(labels map[string]string, scrapeURL string) error {
+	address := labels[addressLabel]
+	if strings.Contains(address, "://") {
+		return fmt.Errorf("address %q contains a scheme, set scheme instead", address)
+	}
+	if strings.ContainsAny(address, "/?#") {
+		return fmt.Errorf("address %q contains a path, set path instead", address)
+	}
+	if strings.IndexFunc(address, isSpace) >= 0 {
+		return fmt.Errorf("address %q contains whitespace", address)
+	}
+	host, port, err := net.SplitHostPort(address)
+	hasPort := err == nil
+	if err != nil {
+		// An address without a port uses the scheme's default port
+		if addrErr, ok := err.(*net.AddrError); !ok || addrErr.Err != "missing port in address" {
+			return fmt.Errorf("address %q: %v", address, err)
+		}
+		host = strings.Trim(address, "[]")
+	}
+	if host == "" {
+		return fmt.Errorf("address %q has no host", address)
+	}
+	if hasPort {
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return fmt.Errorf("address %q has an invalid port %q", address, port)
+		}
+	}
+
+	if scheme := labels[schemeLabel]; scheme != "" && scheme != "http" && scheme != "https" {
+		return fmt.Errorf("scheme %q is not http or https", scheme)
+	}
+
+	path := labels[metricsPathLabel]
+	if path != "" && !strings.HasPrefix(path, "/") {
+		return fmt.Errorf("path %q does not start with /", path)
+	}
+	if strings.ContainsAny(path, "?#") {
+		return fmt.Errorf("path %q contains a query or fragment, set params instead", path)
+	}
+
+	u, err := url.Parse(scrapeURL)
+	if err != nil {
+		return fmt.Errorf("invalid URL: %v", err)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("URL %q has no host", scrapeURL)
+	}
+	return nil
+}
+
+func isSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\n' || r == '\r'
+}
+
+// markInvalid puts a target whose scrape URL failed checkURLLabels in TargetStateInvalid. Invalid
+// targets stay listed with the error but are never scraped.
+func (t *Target) markInvalid(err error) {
+	t.State = TargetStateInvalid
+	t.InvalidReason = err.Error()
+}
+
+// logInvalidTarget logs an invalid target once, and again only when its error changes
+func logInvalidTarget(target, prev *Target) {
+	if prev != nil && prev.State == TargetStateInvalid && prev.InvalidReason == target.InvalidReason {
+		return
+	}
+	logutil.Printf("WARN", "[DISCOVERY] Not scraping target %s: %s", target.ID, target.InvalidReason)
+}
@@ -0,0 +1,86 @@
+package discovery
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckURLLabels(t *testing.T) {
+	tests := []struct {
+		name    string
+		address string
+		scheme  string
+		path    string
+		wantErr string
+	}{
+		{"ip and port", "10.0.0.1:9100", "http", "/metrics", ""},
+		{"ipv6", "[fd00::5]:9100", "https", "/metrics", ""},
+		{"host without port", "exporter.internal", "http", "/metrics", ""},
+		{"no path", "10.0.0.1:9100", "http", "", ""},
+		{"port with trailing space", "10.0.0.1:80 ", "http", "/metrics", "contains whitespace"},
+		{"named port", "10.0.0.1:metrics", "http", "/metrics", `invalid port "metrics"`},
+		{"port out of range", "10.0.0.1:70000", "http", "/metrics", `invalid port "70000"`},
+		{"empty port", "10.0.0.1:", "http", "/metrics", `invalid port ""`},
+		{"no host", ":9100", "http", "/metrics", "has no host"},
+		{"address with scheme", "http://10.0.0.1:9100", "http", "/metrics", "contains a scheme"},
+		{"address with path", "10.0.0.1:9100/metrics", "http", "/metrics", "contains a path"},
+		{"unbracketed ipv6", "fd00::5:9100", "http", "/metrics", "too many colons"},
+		{"path without leading slash", "10.0.0.1:9100", "http", "metrics", "does not start with /"},
+		{"path with query", "10.0.0.1:9100", "http", "/probe?module=http", "set params instead"},
+		{"unknown scheme", "10.0.0.1:9100", "tcp", "/metrics", `scheme "tcp"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			labels := map[string]string{addressLabel: tt.address, schemeLabel: tt.scheme, metricsPathLabel: tt.path}
+			err := checkURLLabels(labels, buildURLFromLabels(labels))
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("got error %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDiscoverStaticTargets_InvalidURL(t *testing.T) {
+	sd := &ServiceDiscoveryImpl{targets: make(map[string]*Target)}
+	config := DiscoveryConfig{TargetName: "exporters", Type: "StaticEndpoints", Enabled: true, Endpoints: []EndpointConfig{
+		{Address: "10.0.1.1:9100", Path: "/metrics"},
+		{Address: "http://10.0.1.2:9100", Path: "/metrics"},
+	}}
+	active := make(map[string]bool)
+	sd.discoverStaticTargets(config, active)
+
+	// The invalid target is listed with its error but never handed to the scraper
+	if len(active) != 2 {
+		t.Fatalf("expected both targets active, got %d", len(active))
+	}
+	ready := sd.GetReadyTargets()
+	if len(ready) != 1 || ready[0].URL != "http://10.0.1.1:9100/metrics" {
+		t.Fatalf("expected only the valid target ready, got %+v", ready)
+	}
+	var invalid *Target
+	for _, target := range sd.GetAllTargets() {
+		if target.State == TargetStateInvalid {
+			invalid = target
+		}
+	}
+	if invalid == nil || !strings.Contains(invalid.InvalidReason, `address "http://10.0.1.2:9100" contains a scheme`) {
+		t.Fatalf("expected the target with a scheme in its address invalid, got %+v", invalid)
+	}
+	if _, ok := sd.GetTarget(invalid.ID); !ok {
+		t.Errorf("expected the invalid target to be found by ID")
+	}
+}
+
+func TestProcessPodTarget_InvalidPort(t *testing.T) {
+	for _, scrapeNotReady := range []bool{false, true} {
+		config := newTestPodConfig(scrapeNotReady)
+		config.Endpoints[0].Port = "8080 "
+		target := processSinglePod(newTestPod("app-0", "10.0.0.1", true), config)
+		if target == nil || target.State != TargetStateInvalid || !strings.Contains(target.InvalidReason, "whitespace") {
+			t.Errorf("scrapeNotReadyPods=%v: expected an invalid target, got %+v", scrapeNotReady, target)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package discovery
+
+import (
+	configPkg "open-agent/pkg/config"
+	"open-agent/pkg/model"
+	"open-agent/pkg/selector"
+)
+
+// WhatapAgentsType is a PodMonitor preset for the WhaTap agents running next to OpenAgent. Fields written
+// in the target replace the defaults below, except relabelConfigs, which are applied after the defaults.
+const WhatapAgentsType = "WhatapAgents"
+
+const (
+	// whatapAgentsNamespace is where the WhaTap Kubernetes agents are installed
+	whatapAgentsNamespace = "whatap-monitoring"
+	// whatapAgentsPort is the metrics port scraped when the pod has no prometheus.io/port annotation
+	whatapAgentsPort = "6600"
+	// whatapAgentsMetricPrefix is prepended to the agents' metric names
+	whatapAgentsMetricPrefix = "whatap_agent_"
+)
+
+// whatapAgentNames are the values of the "name" pod label of the WhaTap agent workloads
+var whatapAgentNames = []string{"whatap-node-agent", "whatap-master-agent"}
+
+// whatapAgentsRelabelConfigs is the default relabel set of WhatapAgents targets:
+//   - the prometheus.io/port pod annotation overrides the scraped port
+//   - the agent's "name" pod label becomes the whatap_agent label
+//   - the pod's node becomes the node label
+var whatapAgentsRelabelConfigs = model.RelabelConfigs{
+	{
+		SourceLabels: []string{"__address__", "__meta_kubernetes_pod_annotation_prometheus_io_port"},
+		Separator:    ";",
+		Regex:        `([^:]+)(?::\d+)?;(\d+)`,
+		TargetLabel:  "__address__",
+		Replacement:  "$1:$2",
+		Action:       "replace",
+	},
+	{
+		SourceLabels: []string{"__meta_kubernetes_pod_label_name"},
+		Separator:    ";",
+		Regex:        "(.+)",
+		TargetLabel:  "whatap_agent",
+		Replacement:  "$1",
+		Action:       "replace",
+	},
+	{
+		SourceLabels: []string{"__meta_kubernetes_pod_node_name"},
+		Separator:    ";",
+		Regex:        "(.+)",
+		TargetLabel:  "node",
+		Replacement:  "$1",
+		Action:       "replace",
+	},
+}
+
+// withWhatapAgentsDefaults fills in the namespace, selector, endpoint, metric prefix and relabel
+// defaults of a WhatapAgents target
+func withWhatapAgentsDefaults(target configPkg.TargetConfig) configPkg.TargetConfig {
+	if target.NamespaceSelector == nil {
+		target.NamespaceSelector = &selector.NamespaceSelector{MatchNames: []string{whatapAgentsNamespace}}
+	}
+	if target.Selector == nil {
+		target.Selector = &selector.Selector{MatchExpressions: []selector.Requirement{
+			{Key: "name", Operator: selector.OpIn, Values: whatapAgentNames},
+		}}
+	}
+	if len(target.Endpoints) == 0 {
+		// The default endpoint takes the target's scrape defaults, already merged with the global section
+		interval := "30s"
+		if target.Interval != "" {
+			interval = target.Interval
+		}
+		target.Endpoints = []configPkg.EndpointConfig{{
+			Port:           whatapAgentsPort,
+			Path:           configPkg.StringList{Values: []string{"/metrics"}},
+			Interval:       interval,
+			Timeout:        target.Timeout,
+			ExternalLabels: target.ExternalLabels,
+			SampleLimit:    target.SampleLimit,
+		}}
+	}
+	if target.MetricPrefix == "" {
+		target.MetricPrefix = whatapAgentsMetricPrefix
+	}
+	relabelConfigs := make(model.RelabelConfigs, 0, len(whatapAgentsRelabelConfigs)+len(target.RelabelConfigs))
+	relabelConfigs = append(relabelConfigs, whatapAgentsRelabelConfigs...)
+	target.RelabelConfigs = append(relabelConfigs, target.RelabelConfigs...)
+	return target
+}
+
+// isPodTargetType reports whether targets of the type are discovered from pods
+func isPodTargetType(targetType string) bool {
+	return targetType == "PodMonitor" || targetType == WhatapAgentsType
+}
@@ -0,0 +1,123 @@
+package discovery
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseDiscoveryConfig_WhatapAgentsDefaults(t *testing.T) {
+	sd := &ServiceDiscoveryImpl{}
+	cfg, err := sd.parseDiscoveryConfig(map[string]interface{}{
+		"targetName": "whatap-agents",
+		"type":       "WhatapAgents",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Type != WhatapAgentsType {
+		t.Errorf("Type = %q, want %q", cfg.Type, WhatapAgentsType)
+	}
+	if cfg.NamespaceSelector == nil || len(cfg.NamespaceSelector.MatchNames) != 1 || cfg.NamespaceSelector.MatchNames[0] != "whatap-monitoring" {
+		t.Errorf("unexpected namespaceSelector %+v", cfg.NamespaceSelector)
+	}
+	for _, name := range []string{"whatap-node-agent", "whatap-master-agent"} {
+		if !cfg.Selector.Matches(map[string]string{"name": name}) {
+			t.Errorf("expected the selector to match name=%s", name)
+		}
+	}
+	if cfg.Selector.Matches(map[string]string{"name": "whatap-open-agent"}) {
+		t.Errorf("expected the selector not to match other pods")
+	}
+	if len(cfg.Endpoints) != 1 || cfg.Endpoints[0].Port != "6600" || cfg.Endpoints[0].Path != "/metrics" || cfg.Endpoints[0].Interval != "30s" {
+		t.Fatalf("unexpected default endpoints %+v", cfg.Endpoints)
+	}
+	if cfg.MetricPrefix != "whatap_agent_" || cfg.Endpoints[0].MetricPrefix != "whatap_agent_" {
+		t.Errorf("expected metric prefix whatap_agent_, got %q / %q", cfg.MetricPrefix, cfg.Endpoints[0].MetricPrefix)
+	}
+	if len(cfg.RelabelConfigs) != len(whatapAgentsRelabelConfigs) {
+		t.Errorf("expected the default relabel set, got %d rules", len(cfg.RelabelConfigs))
+	}
+}
+
+func TestParseDiscoveryConfig_WhatapAgentsOverrides(t *testing.T) {
+	sd := &ServiceDiscoveryImpl{}
+	cfg, err := sd.parseDiscoveryConfig(map[string]interface{}{
+		"targetName":        "whatap-agents",
+		"type":              "WhatapAgents",
+		"namespaceSelector": map[string]interface{}{"matchNames": []interface{}{"monitoring"}},
+		"metricPrefix":      "wa_",
+		"addWorkloadLabels": true,
+		"relabelConfigs": []interface{}{
+			map[string]interface{}{"target_label": "team", "replacement": "infra", "action": "replace"},
+		},
+		"endpoints": []interface{}{map[string]interface{}{"port": "7000", "path": "/stats"}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.NamespaceSelector.MatchNames[0] != "monitoring" {
+		t.Errorf("expected the namespaceSelector override, got %+v", cfg.NamespaceSelector)
+	}
+	if len(cfg.Endpoints) != 1 || cfg.Endpoints[0].Port != "7000" || cfg.Endpoints[0].MetricPrefix != "wa_" {
+		t.Errorf("expected the endpoint and prefix overrides, got %+v", cfg.Endpoints)
+	}
+	if !cfg.AddWorkloadLabels {
+		t.Errorf("expected addWorkloadLabels to be accepted for a pod based target")
+	}
+	// User relabelConfigs run after the defaults
+	n := len(whatapAgentsRelabelConfigs)
+	if len(cfg.RelabelConfigs) != n+1 || cfg.RelabelConfigs[n].TargetLabel != "team" {
+		t.Errorf("expected the user relabel rule after the defaults, got %d rules", len(cfg.RelabelConfigs))
+	}
+}
+
+func TestProcessPodTarget_WhatapAgents(t *testing.T) {
+	sd := &ServiceDiscoveryImpl{}
+	cfg, err := sd.parseDiscoveryConfig(map[string]interface{}{"targetName": "whatap-agents", "type": "WhatapAgents"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	pod := newTestPod("whatap-node-agent-x7k2p", "10.0.0.7", true)
+	pod.Namespace = "whatap-monitoring"
+	pod.Labels = map[string]string{"name": "whatap-node-agent"}
+	pod.Spec.NodeName = "worker-1"
+	target := processSinglePod(pod, cfg)
+	if target == nil {
+		t.Fatalf("expected a target")
+	}
+	if target.URL != "http://10.0.0.7:6600/metrics" {
+		t.Errorf("URL = %q", target.URL)
+	}
+	if target.Labels["whatap_agent"] != "whatap-node-agent" || target.Labels["node"] != "worker-1" {
+		t.Errorf("unexpected labels %v", target.Labels)
+	}
+	if target.Metadata["type"] != WhatapAgentsType {
+		t.Errorf("expected type metadata %q, got %v", WhatapAgentsType, target.Metadata["type"])
+	}
+
+	// The prometheus.io/port annotation overrides the default port
+	pod.Annotations = map[string]string{"prometheus.io/port": "9400"}
+	target = processSinglePod(pod, cfg)
+	if !strings.HasPrefix(target.URL, "http://10.0.0.7:9400/") {
+		t.Errorf("expected the annotated port, got URL %q", target.URL)
+	}
+}
+
+func TestParseDiscoveryConfig_WhatapAgentsTargetDefaults(t *testing.T) {
+	sd := &ServiceDiscoveryImpl{}
+	cfg, err := sd.parseDiscoveryConfig(map[string]interface{}{
+		"targetName":     "whatap-agents",
+		"type":           "WhatapAgents",
+		"interval":       "15s",
+		"sampleLimit":    5000,
+		"externalLabels": map[string]interface{}{"cluster": "prod"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	endpoint := cfg.Endpoints[0]
+	if endpoint.Interval != "15s" || endpoint.SampleLimit != 5000 || endpoint.ExternalLabels["cluster"] != "prod" {
+		t.Errorf("expected the default endpoint to take the target's scrape defaults, got %+v", endpoint)
+	}
+}
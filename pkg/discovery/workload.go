@@ -0,0 +1,47 @@
+package discovery
+
+import (
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// podTemplateHashLabel is set by the Deployment controller on its ReplicaSets and their pods
+const podTemplateHashLabel = "pod-template-hash"
+
+// podWorkload returns the kind and name of the workload that owns the pod, or empty strings for a bare pod.
+//
+// ReplicaSets are resolved to their Deployment without an API call: the Deployment controller names
+// a ReplicaSet "<deployment>-<pod-template-hash>" and copies the hash to the pod labels, so the
+// suffix is trimmed when it matches. A ReplicaSet created by another controller with a name ending in
+// the pod's template hash (e.g. an Argo Rollout) is reported as a Deployment of the trimmed name;
+// ReplicaSets without a matching hash are reported as ReplicaSet. Job, StatefulSet, DaemonSet and any
+// other controller are reported as-is.
+func podWorkload(pod *corev1.Pod) (kind, name string) {
+	owner := controllerOf(pod.OwnerReferences)
+	if owner == nil {
+		return "", ""
+	}
+	if owner.Kind == "ReplicaSet" {
+		if hash := pod.Labels[podTemplateHashLabel]; hash != "" {
+			if deployment, ok := strings.CutSuffix(owner.Name, "-"+hash); ok && deployment != "" {
+				return "Deployment", deployment
+			}
+		}
+	}
+	return owner.Kind, owner.Name
+}
+
+// controllerOf returns the managing owner reference, falling back to the first owner when none is marked as controller
+func controllerOf(refs []metav1.OwnerReference) *metav1.OwnerReference {
+	for i := range refs {
+		if refs[i].Controller != nil && *refs[i].Controller {
+			return &refs[i]
+		}
+	}
+	if len(refs) > 0 {
+		return &refs[0]
+	}
+	return nil
+}
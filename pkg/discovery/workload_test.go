@@ -0,0 +1,95 @@
+package discovery
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func ownedPod(name string, labels map[string]string, kind, owner string) *corev1.Pod {
+	pod := newTestPod(name, "10.0.0.1", true)
+	pod.Labels = labels
+	controller := true
+	pod.OwnerReferences = []metav1.OwnerReference{{Kind: kind, Name: owner, Controller: &controller}}
+	return pod
+}
+
+func TestPodWorkload(t *testing.T) {
+	tests := []struct {
+		name     string
+		pod      *corev1.Pod
+		wantKind string
+		wantName string
+	}{
+		{"deployment", ownedPod("api-7d9f8b6c5-x2x4z", map[string]string{"pod-template-hash": "7d9f8b6c5"}, "ReplicaSet", "api-7d9f8b6c5"), "Deployment", "api"},
+		{"hyphenated deployment", ownedPod("web-api-5c4b-abcde", map[string]string{"pod-template-hash": "5c4b"}, "ReplicaSet", "web-api-5c4b"), "Deployment", "web-api"},
+		{"bare replicaset", ownedPod("legacy-abcde", nil, "ReplicaSet", "legacy"), "ReplicaSet", "legacy"},
+		{"hash mismatch", ownedPod("rs-abcde", map[string]string{"pod-template-hash": "123"}, "ReplicaSet", "rs-456"), "ReplicaSet", "rs-456"},
+		{"statefulset", ownedPod("db-0", nil, "StatefulSet", "db"), "StatefulSet", "db"},
+		{"daemonset", ownedPod("agent-k8x2p", nil, "DaemonSet", "agent"), "DaemonSet", "agent"},
+		{"job", ownedPod("migrate-q7wz9", nil, "Job", "migrate"), "Job", "migrate"},
+		{"no owner", newTestPod("standalone", "10.0.0.1", true), "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kind, name := podWorkload(tt.pod)
+			if kind != tt.wantKind || name != tt.wantName {
+				t.Errorf("podWorkload() = %s/%s, want %s/%s", kind, name, tt.wantKind, tt.wantName)
+			}
+		})
+	}
+}
+
+func TestPodWorkload_PrefersControllerOwner(t *testing.T) {
+	pod := newTestPod("db-0", "10.0.0.1", true)
+	controller := true
+	pod.OwnerReferences = []metav1.OwnerReference{
+		{Kind: "ConfigMap", Name: "unrelated"},
+		{Kind: "StatefulSet", Name: "db", Controller: &controller},
+	}
+	if kind, name := podWorkload(pod); kind != "StatefulSet" || name != "db" {
+		t.Errorf("expected the controller owner, got %s/%s", kind, name)
+	}
+}
+
+func TestProcessPodTarget_WorkloadLabels(t *testing.T) {
+	config := newTestPodConfig(false)
+	config.AddWorkloadLabels = true
+
+	// Pods from two rollouts of the same Deployment carry the same workload labels
+	for _, hash := range []string{"7d9f8b6c5", "5f6c7d8e9"} {
+		pod := ownedPod("api-"+hash+"-abcde", map[string]string{"pod-template-hash": hash}, "ReplicaSet", "api-"+hash)
+		target := processSinglePod(pod, config)
+		if target == nil {
+			t.Fatalf("expected a target for hash %s", hash)
+		}
+		if target.Labels["workload_kind"] != "Deployment" || target.Labels["workload_name"] != "api" {
+			t.Errorf("hash %s: unexpected workload labels %v", hash, target.Labels)
+		}
+	}
+
+	// Disabled by default
+	target := processSinglePod(ownedPod("db-0", nil, "StatefulSet", "db"), newTestPodConfig(false))
+	if _, ok := target.Labels["workload_kind"]; ok {
+		t.Errorf("expected no workload labels when addWorkloadLabels is off, got %v", target.Labels)
+	}
+}
+
+func TestParseDiscoveryConfig_AddWorkloadLabelsPodMonitorOnly(t *testing.T) {
+	sd := &ServiceDiscoveryImpl{}
+	for typ, want := range map[string]bool{"PodMonitor": true, "ServiceMonitor": false} {
+		cfg, err := sd.parseDiscoveryConfig(map[string]interface{}{
+			"targetName":        "app",
+			"type":              typ,
+			"addWorkloadLabels": true,
+			"endpoints":         []interface{}{map[string]interface{}{"port": "8080"}},
+		})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", typ, err)
+		}
+		if cfg.AddWorkloadLabels != want {
+			t.Errorf("%s: AddWorkloadLabels = %v, want %v", typ, cfg.AddWorkloadLabels, want)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package discovery
+
+import (
+	corev1 "k8s.io/api/core/v1"
+
+	configPkg "open-agent/pkg/config"
+	"open-agent/pkg/k8s"
+	"open-agent/tools/util/logutil"
+)
+
+// localZoneAddresses returns the service's endpoint addresses that serve the agent's zone, or nil to
+// scrape every address when the agent's zone is unknown or none of the ready endpoints serves it
+func (sd *ServiceDiscoveryImpl) localZoneAddresses(service *corev1.Service, endpoints *corev1.Endpoints) map[string]bool {
+	if endpoints == nil {
+		return nil
+	}
+	zones, err := sd.k8sClient.GetEndpointZones(service.Namespace, service.Name)
+	if err != nil || len(zones) == 0 {
+		return nil
+	}
+
+	zone := sd.agentZone(zones)
+	if zone == "" {
+		if !sd.unknownZoneLogged {
+			sd.unknownZoneLogged = true
+			logutil.Printf("WARN", "[DISCOVERY] preferLocalZone is set but the agent's zone is unknown, scraping endpoints in every zone. Set NODE_ZONE, or NODE_NAME from the downward API")
+		}
+		return nil
+	}
+
+	local := make(map[string]bool)
+	for address, endpointZone := range zones {
+		if endpointZone.ServesZone(zone) {
+			local[address] = true
+		}
+	}
+	for _, subset := range endpoints.Subsets {
+		for _, address := range subset.Addresses {
+			if local[address.IP] {
+				return local
+			}
+		}
+	}
+	if configPkg.IsDebugEnabled() {
+		logutil.Debugf("DISCOVERY", "No ready endpoint of service %s/%s in zone %s, scraping every zone", service.Namespace, service.Name, zone)
+	}
+	return nil
+}
+
+// agentZone returns NODE_ZONE, or the zone of an endpoint on the agent's own node
+func (sd *ServiceDiscoveryImpl) agentZone(zones map[string]k8s.EndpointZone) string {
+	if sd.self.Zone != "" {
+		return sd.self.Zone
+	}
+	if sd.self.NodeName == "" {
+		return ""
+	}
+	for _, endpointZone := range zones {
+		if endpointZone.NodeName == sd.self.NodeName && endpointZone.Zone != "" {
+			return endpointZone.Zone
+		}
+	}
+	return ""
+}
@@ -0,0 +1,127 @@
+package discovery
+
+import (
+	"sort"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"open-agent/pkg/k8s"
+)
+
+// zonedProvider serves one service with a ready endpoint in zone-a, two in zone-b and a not-ready one in zone-a
+func zonedProvider() *fakeProvider {
+	return &fakeProvider{
+		endpoints: map[string]*corev1.Endpoints{
+			"monitoring/exporter": {Subsets: []corev1.EndpointSubset{{
+				Addresses:         []corev1.EndpointAddress{{IP: "10.0.1.1"}, {IP: "10.0.2.1"}, {IP: "10.0.2.2"}},
+				NotReadyAddresses: []corev1.EndpointAddress{{IP: "10.0.1.9"}},
+				Ports:             []corev1.EndpointPort{{Name: "metrics", Port: 9100}},
+			}}},
+		},
+		zones: map[string]map[string]k8s.EndpointZone{
+			"monitoring/exporter": {
+				"10.0.1.1": {Zone: "zone-a", NodeName: "node-a1"},
+				"10.0.2.1": {Zone: "zone-b", NodeName: "node-b1"},
+				"10.0.2.2": {Zone: "zone-b", NodeName: "node-b2"},
+				"10.0.1.9": {Zone: "zone-a", NodeName: "node-a2"},
+			},
+		},
+	}
+}
+
+func discoverZonedService(t *testing.T, provider *fakeProvider, self selfIdentity, preferLocalZone bool) []string {
+	t.Helper()
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "exporter", Namespace: "monitoring"},
+		Spec: corev1.ServiceSpec{Ports: []corev1.ServicePort{
+			{Name: "metrics", Port: 9100, TargetPort: intstr.FromInt(9100)},
+		}},
+	}
+	config := DiscoveryConfig{
+		TargetName:         "exporters",
+		Type:               "ServiceMonitor",
+		Enabled:            true,
+		ScrapeNotReadyPods: true,
+		PreferLocalZone:    preferLocalZone,
+		Endpoints:          []EndpointConfig{{Port: "metrics", Path: "/metrics"}},
+	}
+	sd := &ServiceDiscoveryImpl{k8sClient: provider, targets: make(map[string]*Target), self: self}
+	sd.processServiceTarget(service, config, make(map[string]bool))
+
+	var instances []string
+	for _, target := range sd.targets {
+		instances = append(instances, target.Labels["instance"])
+	}
+	sort.Strings(instances)
+	return instances
+}
+
+func assertInstances(t *testing.T, got []string, want ...string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("instances = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("instances = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestPreferLocalZone_ScrapesOnlyLocalEndpoints(t *testing.T) {
+	got := discoverZonedService(t, zonedProvider(), selfIdentity{Zone: "zone-b"}, true)
+	assertInstances(t, got, "10.0.2.1:9100", "10.0.2.2:9100")
+
+	// Not-ready endpoints are filtered the same way
+	got = discoverZonedService(t, zonedProvider(), selfIdentity{Zone: "zone-a"}, true)
+	assertInstances(t, got, "10.0.1.1:9100", "10.0.1.9:9100")
+}
+
+func TestPreferLocalZone_ZoneFromAgentNode(t *testing.T) {
+	// Without NODE_ZONE the zone of an endpoint on the agent's node is used
+	got := discoverZonedService(t, zonedProvider(), selfIdentity{NodeName: "node-b2"}, true)
+	assertInstances(t, got, "10.0.2.1:9100", "10.0.2.2:9100")
+}
+
+func TestPreferLocalZone_FallsBackToAllEndpoints(t *testing.T) {
+	all := []string{"10.0.1.1:9100", "10.0.1.9:9100", "10.0.2.1:9100", "10.0.2.2:9100"}
+
+	// No ready endpoint in the agent's zone
+	assertInstances(t, discoverZonedService(t, zonedProvider(), selfIdentity{Zone: "zone-c"}, true), all...)
+	// The agent's zone is unknown
+	assertInstances(t, discoverZonedService(t, zonedProvider(), selfIdentity{NodeName: "node-elsewhere"}, true), all...)
+	// preferLocalZone is not set
+	assertInstances(t, discoverZonedService(t, zonedProvider(), selfIdentity{Zone: "zone-b"}, false), all...)
+}
+
+func TestPreferLocalZone_TopologyHints(t *testing.T) {
+	provider := zonedProvider()
+	// Hints assign the zone-b endpoint 10.0.2.1 to zone-a consumers as well
+	provider.zones["monitoring/exporter"]["10.0.1.1"] = k8s.EndpointZone{Zone: "zone-a", ForZones: []string{"zone-a"}}
+	provider.zones["monitoring/exporter"]["10.0.2.1"] = k8s.EndpointZone{Zone: "zone-b", ForZones: []string{"zone-a"}}
+	provider.zones["monitoring/exporter"]["10.0.2.2"] = k8s.EndpointZone{Zone: "zone-b", ForZones: []string{"zone-b"}}
+
+	got := discoverZonedService(t, provider, selfIdentity{Zone: "zone-a"}, true)
+	assertInstances(t, got, "10.0.1.1:9100", "10.0.1.9:9100", "10.0.2.1:9100")
+}
+
+func TestParseDiscoveryConfig_PreferLocalZoneServiceMonitorOnly(t *testing.T) {
+	sd := &ServiceDiscoveryImpl{}
+	for targetType, want := range map[string]bool{"ServiceMonitor": true, "PodMonitor": false} {
+		cfg, err := sd.parseDiscoveryConfig(map[string]interface{}{
+			"targetName":      "exporters",
+			"type":            targetType,
+			"preferLocalZone": true,
+			"selector":        map[string]interface{}{"matchLabels": map[string]interface{}{"app": "exporter"}},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cfg.PreferLocalZone != want {
+			t.Errorf("%s: PreferLocalZone = %v, want %v", targetType, cfg.PreferLocalZone, want)
+		}
+	}
+}
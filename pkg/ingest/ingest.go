@@ -0,0 +1,215 @@
+// Package ingest serves the push endpoint of the pipeline-only mode: clients POST a Prometheus text
+// exposition, which is handed to the processing pipeline as if it had been scraped.
+package ingest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/whatap/golib/logger/logfile"
+	"open-agent/pkg/admin"
+	"open-agent/pkg/config"
+	"open-agent/pkg/model"
+)
+
+const (
+	// Path is where the ingest endpoint is served
+	Path = "/ingest"
+
+	// TargetHeader names the pushed target; it is the job label and the target of the self-metrics
+	TargetHeader = "X-Openagent-Target"
+	// LabelsHeader holds target labels added to every sample as comma-separated name=value pairs.
+	// The header may be repeated.
+	LabelsHeader = "X-Openagent-Labels"
+
+	// DefaultTarget is the target of requests without TargetHeader
+	DefaultTarget = "ingest"
+	// DefaultMaxBodyBytes bounds the size of one exposition
+	DefaultMaxBodyBytes = 16 << 20
+
+	defaultBindAddress = "127.0.0.1"
+	defaultPort        = 6061
+
+	// retryAfterSeconds is suggested to clients when the raw queue is full
+	retryAfterSeconds = 5
+)
+
+var labelNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// Config holds the ingest endpoint settings
+type Config struct {
+	BindAddress string
+	Port        int
+	// Token is the bearer token clients must send; the endpoint does not start without one
+	Token        string
+	MaxBodyBytes int64
+}
+
+// Addr returns the listen address
+func (c Config) Addr() string {
+	return net.JoinHostPort(c.BindAddress, strconv.Itoa(c.Port))
+}
+
+// LoadConfig reads the ingest endpoint settings from whatap.conf, falling back to environment variables
+func LoadConfig() Config {
+	port := config.GetIntWithDefault("openagent_ingest_port", defaultPort)
+	if port <= 0 || port > 65535 {
+		port = defaultPort
+	}
+	maxBodyBytes := int64(config.GetIntWithDefault("openagent_ingest_max_body_bytes", DefaultMaxBodyBytes))
+	if maxBodyBytes <= 0 {
+		maxBodyBytes = DefaultMaxBodyBytes
+	}
+	return Config{
+		BindAddress:  config.GetWithDefault("openagent_ingest_bind_address", defaultBindAddress),
+		Port:         port,
+		Token:        config.Get("openagent_ingest_token"),
+		MaxBodyBytes: maxBodyBytes,
+	}
+}
+
+// RelabelRules returns the metricRelabelConfigs applied to the samples pushed for a target, nil for none
+type RelabelRules func(target string) model.RelabelConfigs
+
+// TargetRelabelRules resolves the rules from the scrape configuration on every request, so reloads apply:
+// a pushed target gets the metricRelabelConfigs of the configured target with the same targetName,
+// which include the global default ones; other targets get only the global default ones.
+// Endpoint-level rules are not applied, as a pushed target has no endpoint.
+func TargetRelabelRules(cm *config.ConfigManager) RelabelRules {
+	return func(target string) model.RelabelConfigs {
+		for _, targetConfig := range cm.GetTargetConfigs() {
+			if targetConfig.TargetName == target {
+				return targetConfig.MetricRelabelConfigs
+			}
+		}
+		return cm.GetGlobalConfig().DefaultMetricRelabelConfigs
+	}
+}
+
+// AddFunc hands pushed raw data to the pipeline, e.g. ScraperManager.AddRawData. It returns false
+// when the data was dropped because the pipeline is full.
+type AddFunc func(data *model.ScrapeRawData) bool
+
+// Handler turns ingest requests into raw data for the pipeline
+type Handler struct {
+	add          AddFunc
+	rules        RelabelRules
+	maxBodyBytes int64
+	now          func() time.Time
+}
+
+// NewHandler returns the ingest handler behind bearer token auth. The token is required, as anyone
+// reaching the endpoint can otherwise write metrics under the agent's license. rules may be nil, then
+// pushed samples are not relabeled.
+func NewHandler(cfg Config, add AddFunc, rules RelabelRules) (http.Handler, error) {
+	if cfg.Token == "" {
+		return nil, errors.New("openagent_ingest_token is required for the ingest endpoint")
+	}
+	maxBodyBytes := cfg.MaxBodyBytes
+	if maxBodyBytes <= 0 {
+		maxBodyBytes = DefaultMaxBodyBytes
+	}
+	h := &Handler{add: add, rules: rules, maxBodyBytes: maxBodyBytes, now: time.Now}
+	return admin.AuthMiddleware(admin.AuthConfig{BearerToken: cfg.Token}, h), nil
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	target := strings.TrimSpace(r.Header.Get(TargetHeader))
+	if target == "" {
+		target = DefaultTarget
+	}
+	labels, err := parseLabels(r.Header.Values(LabelsHeader))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, ok := labels["job"]; !ok {
+		labels["job"] = target
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, h.maxBodyBytes))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, fmt.Sprintf("body exceeds %d bytes", h.maxBodyBytes), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(body) == 0 {
+		http.Error(w, "empty body", http.StatusBadRequest)
+		return
+	}
+
+	now := h.now().UnixMilli()
+	targetID := DefaultTarget + "/" + target
+	var metricRelabelConfigs model.RelabelConfigs
+	if h.rules != nil {
+		metricRelabelConfigs = h.rules(target)
+	}
+	data := model.NewScrapeRawData(targetID, string(body), metricRelabelConfigs, labels, now)
+	data.TargetID = targetID
+	data.ContentType = r.Header.Get("Content-Type")
+	data.ScrapeStart = now
+
+	if !h.add(data) {
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds))
+		http.Error(w, "pipeline is full", http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// parseLabels reads the name=value pairs of the labels headers
+func parseLabels(headers []string) (map[string]string, error) {
+	labels := make(map[string]string)
+	for _, header := range headers {
+		for _, pair := range strings.Split(header, ",") {
+			pair = strings.TrimSpace(pair)
+			if pair == "" {
+				continue
+			}
+			name, value, ok := strings.Cut(pair, "=")
+			name = strings.TrimSpace(name)
+			if !ok || !labelNamePattern.MatchString(name) {
+				return nil, fmt.Errorf("invalid %s pair %q: expected name=value", LabelsHeader, pair)
+			}
+			labels[name] = strings.TrimSpace(value)
+		}
+	}
+	return labels, nil
+}
+
+// Start serves the ingest endpoint in a background goroutine. Without a token it logs why and does not start.
+func Start(cfg Config, add AddFunc, rules RelabelRules, logger *logfile.FileLogger) {
+	handler, err := NewHandler(cfg, add, rules)
+	if err != nil {
+		logger.Println("ingest", fmt.Sprintf("Ingest endpoint not started: %v", err))
+		return
+	}
+	mux := http.NewServeMux()
+	mux.Handle(Path, handler)
+
+	addr := cfg.Addr()
+	go func() {
+		logger.Infoln("ingest", fmt.Sprintf("Starting ingest endpoint on http://%s%s", addr, Path))
+		server := &http.Server{Addr: addr, Handler: mux}
+		if err := server.ListenAndServe(); err != nil {
+			logger.Infoln("ingest", fmt.Sprintf("Failed to start ingest endpoint: %v", err))
+		}
+	}()
+}
@@ -0,0 +1,172 @@
+package ingest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"open-agent/pkg/model"
+	"open-agent/pkg/scraper"
+)
+
+const testToken = "s3cret"
+
+// startPipeline runs an ingest endpoint feeding a pipeline-only scraper manager, and returns the raw
+// queue the processor reads. The processed output is tested in the processor package.
+func startPipeline(t *testing.T, rules RelabelRules) (*httptest.Server, chan *model.ScrapeRawData) {
+	t.Helper()
+	rawQueue := make(chan *model.ScrapeRawData, 10)
+
+	sm := scraper.NewScraperManager(nil, nil, rawQueue, "test")
+	sm.StartScraping()
+	t.Cleanup(sm.Stop)
+
+	handler, err := NewHandler(Config{Token: testToken}, sm.AddRawData, rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server, rawQueue
+}
+
+func post(t *testing.T, url, token, body string, header http.Header) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+// receive returns the raw data queued for the processor
+func receive(t *testing.T, rawQueue chan *model.ScrapeRawData) *model.ScrapeRawData {
+	t.Helper()
+	select {
+	case data := <-rawQueue:
+		return data
+	case <-time.After(5 * time.Second):
+		t.Fatal("no raw data queued")
+		return nil
+	}
+}
+
+func TestIngestReachesPipeline(t *testing.T) {
+	rules := func(target string) model.RelabelConfigs {
+		if target != "batch" {
+			return nil
+		}
+		return model.RelabelConfigs{{SourceLabels: []string{"__name__"}, Regex: "debug_.*", Action: "drop"}}
+	}
+	server, rawQueue := startPipeline(t, rules)
+
+	body := "queue_depth{queue=\"mail\"} 7\n"
+	header := http.Header{}
+	header.Set(TargetHeader, "batch")
+	header.Add(LabelsHeader, "env=prod")
+	header.Add(LabelsHeader, "team=core, instance=batch-1")
+	resp := post(t, server.URL, testToken, body, header)
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202", resp.StatusCode)
+	}
+
+	data := receive(t, rawQueue)
+	if data.TargetID != "ingest/batch" || data.RawData != body {
+		t.Fatalf("raw data = %+v, want the body of ingest/batch", data)
+	}
+	want := map[string]string{"job": "batch", "env": "prod", "team": "core", "instance": "batch-1"}
+	for k, v := range want {
+		if data.Labels[k] != v {
+			t.Errorf("label %s = %q, want %q (labels %v)", k, data.Labels[k], v, data.Labels)
+		}
+	}
+	if len(data.MetricRelabelConfigs) != 1 || data.MetricRelabelConfigs[0].Regex != "debug_.*" {
+		t.Errorf("expected the target's relabel rules, got %+v", data.MetricRelabelConfigs)
+	}
+	if data.ScrapeStart == 0 {
+		t.Error("scrape start is not set, pipeline latency would not be measured")
+	}
+
+	// Other targets get their own rules
+	header.Set(TargetHeader, "web")
+	post(t, server.URL, testToken, body, header)
+	if data := receive(t, rawQueue); len(data.MetricRelabelConfigs) != 0 {
+		t.Errorf("expected no relabel rules for web, got %+v", data.MetricRelabelConfigs)
+	}
+}
+
+func TestIngestRejectsRequests(t *testing.T) {
+	server, _ := startPipeline(t, nil)
+
+	badLabels := http.Header{}
+	badLabels.Set(LabelsHeader, "1bad=x")
+	for _, tc := range []struct {
+		name   string
+		method string
+		token  string
+		body   string
+		header http.Header
+		want   int
+	}{
+		{"no token", http.MethodPost, "", "up 1\n", nil, http.StatusUnauthorized},
+		{"wrong token", http.MethodPost, "nope", "up 1\n", nil, http.StatusUnauthorized},
+		{"not POST", http.MethodPut, testToken, "up 1\n", nil, http.StatusMethodNotAllowed},
+		{"empty body", http.MethodPost, testToken, "", nil, http.StatusBadRequest},
+		{"invalid label", http.MethodPost, testToken, "up 1\n", badLabels, http.StatusBadRequest},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tc.method, server.URL, strings.NewReader(tc.body))
+			for k, v := range tc.header {
+				req.Header[k] = v
+			}
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tc.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tc.want)
+			}
+		})
+	}
+}
+
+func TestIngestBodyLimitAndFullPipeline(t *testing.T) {
+	full := func(*model.ScrapeRawData) bool { return false }
+	handler, err := NewHandler(Config{Token: testToken, MaxBodyBytes: 8}, full, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	if resp := post(t, server.URL, testToken, "some_metric 1\n", nil); resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized body: status = %d, want 413", resp.StatusCode)
+	}
+	resp := post(t, server.URL, testToken, "up 1\n", nil)
+	if resp.StatusCode != http.StatusServiceUnavailable || resp.Header.Get("Retry-After") == "" {
+		t.Errorf("full pipeline: status = %d, Retry-After = %q, want 503 with Retry-After", resp.StatusCode, resp.Header.Get("Retry-After"))
+	}
+}
+
+func TestNewHandlerRequiresToken(t *testing.T) {
+	if _, err := NewHandler(Config{}, func(*model.ScrapeRawData) bool { return true }, nil); err == nil {
+		t.Fatal("handler without a token was created")
+	}
+}
@@ -0,0 +1,62 @@
+// Package instancelock keeps two agents from running in the same home directory, where they would
+// overwrite each other's cache, checkpoint and dump files.
+//
+// The lock is an flock on FileName in the home directory. The kernel releases it when the process
+// exits, even on a crash, so a stale lock file never blocks the next start. The file holds the PID of
+// the agent that owns it, for the error a second agent logs. The agent already depends on Unix
+// signals (SIGUSR1) and does not build on platforms without flock.
+package instancelock
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// FileName is the lock file created in the home directory
+const FileName = "open-agent.lock"
+
+// ErrLocked is returned by Acquire when another agent holds the lock
+var ErrLocked = errors.New("another open agent is running in this home directory")
+
+// Lock is a held instance lock
+type Lock struct {
+	file *os.File
+}
+
+// Acquire takes the instance lock of the home directory without waiting. It returns an error wrapping
+// ErrLocked, naming the PID of the owner, when another agent holds it.
+func Acquire(home string) (*Lock, error) {
+	path := filepath.Join(home, FileName)
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open lock file: %w", err)
+	}
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		file.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			owner, _ := os.ReadFile(path)
+			return nil, fmt.Errorf("%w: %s is held by pid %s", ErrLocked, path, strings.TrimSpace(string(owner)))
+		}
+		return nil, fmt.Errorf("lock %s: %w", path, err)
+	}
+
+	// Only the owner writes the file, so a second agent reads a complete PID
+	if err := file.Truncate(0); err == nil {
+		file.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+	return &Lock{file: file}, nil
+}
+
+// Release gives the lock up; it is also released when the process exits
+func (l *Lock) Release() error {
+	if err := syscall.Flock(int(l.file.Fd()), syscall.LOCK_UN); err != nil {
+		l.file.Close()
+		return err
+	}
+	return l.file.Close()
+}
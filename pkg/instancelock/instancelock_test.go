@@ -0,0 +1,50 @@
+package instancelock
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestAcquire_SecondAgentIsRejected(t *testing.T) {
+	home := t.TempDir()
+	lock, err := Acquire(home)
+	if err != nil {
+		t.Fatalf("first acquire: %v", err)
+	}
+
+	// flock locks belong to the open file, so a second open in the same process conflicts too
+	_, err = Acquire(home)
+	if !errors.Is(err, ErrLocked) || !strings.Contains(err.Error(), strconv.Itoa(os.Getpid())) {
+		t.Fatalf("second acquire: err = %v, want ErrLocked naming pid %d", err, os.Getpid())
+	}
+
+	if err := lock.Release(); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	again, err := Acquire(home)
+	if err != nil {
+		t.Fatalf("acquire after release: %v", err)
+	}
+	again.Release()
+}
+
+func TestAcquire_StaleLockFile(t *testing.T) {
+	home := t.TempDir()
+	// A lock file left by a crashed agent is not locked, only its content is stale
+	if err := os.WriteFile(filepath.Join(home, FileName), []byte("99999999\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	lock, err := Acquire(home)
+	if err != nil {
+		t.Fatalf("acquire over a stale lock file: %v", err)
+	}
+	defer lock.Release()
+	content, _ := os.ReadFile(filepath.Join(home, FileName))
+	if strings.TrimSpace(string(content)) != strconv.Itoa(os.Getpid()) {
+		t.Errorf("lock file = %q, want our pid", content)
+	}
+}
@@ -24,6 +24,8 @@ import (
 // K8sClient is a wrapper around the Kubernetes client
 type K8sClient struct {
 	clientset             *kubernetes.Clientset
+	restConfig            *rest.Config // kept for scraping through the apiserver proxy
+	eventRecorder         *EventRecorder
 	podInformer           cache.SharedIndexInformer
 	endpointSliceInformer cache.SharedIndexInformer
 	serviceInformer       cache.SharedIndexInformer
@@ -40,6 +42,7 @@ type K8sClient struct {
 	initialized           bool
 	mu                    sync.RWMutex
 	configMapHandlers     []func(*corev1.ConfigMap)
+	secretHandlers        []func(*corev1.Secret)
 	useV1EndpointSlice    bool // true for v1 (k8s 1.21+), false for v1beta1 (k8s 1.17-1.20)
 }
 
@@ -147,6 +150,7 @@ func (c *K8sClient) initialize() {
 		return
 	}
 	logutil.Infof("K8S", "Kubernetes clientset created")
+	c.restConfig = config
 
 	// Detect Kubernetes version to determine EndpointSlice API version
 	c.useV1EndpointSlice = c.detectEndpointSliceVersion()
@@ -188,6 +192,11 @@ func (c *K8sClient) initialize() {
 	c.secretInformer = factory.Core().V1().Secrets().Informer()
 	c.secretStore = c.secretInformer.GetStore()
 
+	// Strip fields discovery never reads before they reach the caches
+	if err := c.setInformerTransforms(); err != nil {
+		logutil.Printf("WARN", "[K8S] Failed to set informer transforms, caching full objects: %v", err)
+	}
+
 	// Add event handler for ConfigMap changes
 	c.configMapInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
 		UpdateFunc: func(oldObj, newObj interface{}) {
@@ -201,6 +210,9 @@ func (c *K8sClient) initialize() {
 		},
 	})
 
+	// Add event handler for Secret changes, to reload credentials read from Secrets
+	c.addSecretEventHandler()
+
 	// Start the informers
 	logutil.Infof("K8S", "Starting informers...")
 	go c.podInformer.Run(c.stopCh)
@@ -231,6 +243,15 @@ func (c *K8sClient) initialize() {
 	logutil.Infof("K8S", "Kubernetes client initialized successfully")
 }
 
+// RestConfig returns a copy of the client configuration (apiserver host, bearer token and CA),
+// or nil if the client is not initialized
+func (c *K8sClient) RestConfig() *rest.Config {
+	if !c.IsInitialized() || c.restConfig == nil {
+		return nil
+	}
+	return rest.CopyConfig(c.restConfig)
+}
+
 // detectEndpointSliceVersion detects which EndpointSlice API version to use
 // Returns true for v1 (Kubernetes 1.21+), false for v1beta1 (Kubernetes 1.17-1.20)
 func (c *K8sClient) detectEndpointSliceVersion() bool {
@@ -321,22 +342,6 @@ func (c *K8sClient) GetConfigMap(namespace, name string) (*corev1.ConfigMap, err
 	return nil, fmt.Errorf("configmap %s/%s not found", namespace, name)
 }
 
-// GetSecret returns a Secret by name and namespace
-func (c *K8sClient) GetSecret(namespace, name string) (*corev1.Secret, error) {
-	if !c.IsInitialized() {
-		return nil, fmt.Errorf("kubernetes client not initialized")
-	}
-
-	for _, obj := range c.secretStore.List() {
-		secret := obj.(*corev1.Secret)
-		if secret.Namespace == namespace && secret.Name == name {
-			return secret, nil
-		}
-	}
-
-	return nil, fmt.Errorf("secret %s/%s not found", namespace, name)
-}
-
 // GetPodsInNamespace returns all pods in the specified namespace
 func (c *K8sClient) GetPodsInNamespace(namespace string) ([]*corev1.Pod, error) {
 	if !c.IsInitialized() {
@@ -462,7 +467,12 @@ func (c *K8sClient) getEndpointsFromV1(namespace, serviceName string) (*corev1.E
 				ready = *ep.Conditions.Ready
 			}
 			for _, addr := range ep.Addresses {
-				endpointAddr := corev1.EndpointAddress{IP: addr}
+				// IP holds the host name for FQDN slices, e.g. of ExternalName-backed services
+				addr, ok := endpointSliceAddress(es.Namespace, es.Name, string(es.AddressType), addr)
+				if !ok {
+					continue
+				}
+				endpointAddr := corev1.EndpointAddress{IP: addr, NodeName: ep.NodeName}
 				if ep.TargetRef != nil {
 					endpointAddr.TargetRef = ep.TargetRef.DeepCopy()
 				}
@@ -476,7 +486,7 @@ func (c *K8sClient) getEndpointsFromV1(namespace, serviceName string) (*corev1.E
 	}
 
 	// If nothing found, return nil without error
-	if len(addresses) == 0 {
+	if len(addresses) == 0 && len(notReady) == 0 {
 		return nil, nil
 	}
 
@@ -491,8 +501,9 @@ func (c *K8sClient) getEndpointsFromV1(namespace, serviceName string) (*corev1.E
 		ObjectMeta: metav1.ObjectMeta{Name: serviceName, Namespace: namespace},
 		Subsets: []corev1.EndpointSubset{
 			{
-				Addresses: addresses,
-				Ports:     ports,
+				Addresses:         addresses,
+				NotReadyAddresses: notReady,
+				Ports:             ports,
 			},
 		},
 	}
@@ -542,7 +553,12 @@ func (c *K8sClient) getEndpointsFromV1Beta1(namespace, serviceName string) (*cor
 				ready = *ep.Conditions.Ready
 			}
 			for _, addr := range ep.Addresses {
-				endpointAddr := corev1.EndpointAddress{IP: addr}
+				// IP holds the host name for FQDN slices, e.g. of ExternalName-backed services
+				addr, ok := endpointSliceAddress(es.Namespace, es.Name, string(es.AddressType), addr)
+				if !ok {
+					continue
+				}
+				endpointAddr := corev1.EndpointAddress{IP: addr, NodeName: ep.NodeName}
 				if ep.TargetRef != nil {
 					endpointAddr.TargetRef = ep.TargetRef.DeepCopy()
 				}
@@ -556,7 +572,7 @@ func (c *K8sClient) getEndpointsFromV1Beta1(namespace, serviceName string) (*cor
 	}
 
 	// If nothing found, return nil without error
-	if len(addresses) == 0 {
+	if len(addresses) == 0 && len(notReady) == 0 {
 		return nil, nil
 	}
 
@@ -571,8 +587,9 @@ func (c *K8sClient) getEndpointsFromV1Beta1(namespace, serviceName string) (*cor
 		ObjectMeta: metav1.ObjectMeta{Name: serviceName, Namespace: namespace},
 		Subsets: []corev1.EndpointSubset{
 			{
-				Addresses: addresses,
-				Ports:     ports,
+				Addresses:         addresses,
+				NotReadyAddresses: notReady,
+				Ports:             ports,
 			},
 		},
 	}
@@ -615,7 +632,8 @@ func (c *K8sClient) GetNamespacesByNames(names []string) ([]*corev1.Namespace, e
 	return namespaces, nil
 }
 
-// GetPodPort returns the container port for the specified port name or number
+// GetPodPort returns the container port for the specified port name or number. Named ports are
+// looked up in the containers, then in the sidecar init containers.
 func (c *K8sClient) GetPodPort(pod *corev1.Pod, portName string) (int32, error) {
 	// Try to parse the port as a number
 	var port int32
@@ -625,12 +643,13 @@ func (c *K8sClient) GetPodPort(pod *corev1.Pod, portName string) (int32, error)
 	}
 
 	// If it's not a number, look for the port by name
-	for _, container := range pod.Spec.Containers {
-		for _, p := range container.Ports {
-			if p.Name == portName {
-				return p.ContainerPort, nil
-			}
+	if p, ok := FindPodPort(pod, portName); ok {
+		if p.Sidecar {
+			logutil.Debugf("K8S", "GetPodPort - port %s of pod %s/%s is %d on sidecar init container %s", portName, pod.Namespace, pod.Name, p.Port, p.Container)
+		} else {
+			logutil.Debugf("K8S", "GetPodPort - port %s of pod %s/%s is %d on container %s", portName, pod.Namespace, pod.Name, p.Port, p.Container)
 		}
+		return p.Port, nil
 	}
 
 	return 0, fmt.Errorf("port %s not found in pod %s", portName, pod.Name)
@@ -0,0 +1,25 @@
+package k8s
+
+import "fmt"
+
+// ClusterUIDNamespace is the namespace whose UID identifies the cluster. It exists in every cluster and
+// keeps its UID for the cluster's lifetime.
+const ClusterUIDNamespace = "kube-system"
+
+// ClusterUID returns the UID of the kube-system namespace from the namespace informer cache, or "" without
+// an error when the provider is not connected to a cluster, as in standalone mode
+func ClusterUID(provider K8sProvider) (string, error) {
+	if provider == nil || !provider.IsInitialized() {
+		return "", nil
+	}
+	namespaces, err := provider.GetNamespacesByNames([]string{ClusterUIDNamespace})
+	if err != nil {
+		return "", err
+	}
+	for _, namespace := range namespaces {
+		if namespace.Name == ClusterUIDNamespace && namespace.UID != "" {
+			return string(namespace.UID), nil
+		}
+	}
+	return "", fmt.Errorf("namespace %s not found", ClusterUIDNamespace)
+}
@@ -0,0 +1,54 @@
+package k8s
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+// namespaceClient returns a client whose namespace informer is synced from a fake clientset
+func namespaceClient(t *testing.T, namespaces ...*corev1.Namespace) *K8sClient {
+	t.Helper()
+	objects := make([]runtime.Object, 0, len(namespaces))
+	for _, namespace := range namespaces {
+		objects = append(objects, namespace)
+	}
+	factory := informers.NewSharedInformerFactory(fake.NewSimpleClientset(objects...), 0)
+	informer := factory.Core().V1().Namespaces().Informer()
+	stopCh := make(chan struct{})
+	t.Cleanup(func() { close(stopCh) })
+	factory.Start(stopCh)
+	if !cache.WaitForCacheSync(stopCh, informer.HasSynced) {
+		t.Fatal("informer did not sync")
+	}
+	return &K8sClient{namespaceStore: informer.GetStore(), initialized: true}
+}
+
+func TestClusterUID(t *testing.T) {
+	c := namespaceClient(t,
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default", UID: "11111111-aaaa"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system", UID: "22222222-bbbb"}},
+	)
+	uid, err := ClusterUID(c)
+	if err != nil || uid != "22222222-bbbb" {
+		t.Errorf("ClusterUID = %q, %v, want the kube-system UID", uid, err)
+	}
+}
+
+func TestClusterUID_MissingNamespace(t *testing.T) {
+	c := namespaceClient(t, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default", UID: "11111111-aaaa"}})
+	if uid, err := ClusterUID(c); err == nil || uid != "" {
+		t.Errorf("ClusterUID = %q, %v, want an error", uid, err)
+	}
+}
+
+func TestClusterUID_Standalone(t *testing.T) {
+	if uid, err := ClusterUID(NoopK8sProvider{}); err != nil || uid != "" {
+		t.Errorf("ClusterUID = %q, %v, want no UID and no error", uid, err)
+	}
+}
@@ -0,0 +1,56 @@
+package k8s
+
+import (
+	"net"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"open-agent/tools/util/logutil"
+)
+
+// EndpointSlice address types, the same for discovery.k8s.io/v1 and v1beta1
+const (
+	addressTypeIPv4 = "IPv4"
+	addressTypeIPv6 = "IPv6"
+	addressTypeFQDN = "FQDN"
+)
+
+// skippedAddresses remembers the slices whose addresses were skipped, so each is logged once
+var skippedAddresses sync.Map
+
+// endpointSliceAddress checks an EndpointSlice address against the slice's addressType. IP addresses
+// must parse as that IP family, or as any IP when the type is not set; FQDN addresses are kept as host
+// names and resolved by the scraper. Addresses of unknown types and malformed addresses are skipped with
+// a warning.
+func endpointSliceAddress(namespace, slice, addressType, address string) (string, bool) {
+	var reason string
+	switch addressType {
+	case "", addressTypeIPv4, addressTypeIPv6:
+		ip := net.ParseIP(address)
+		switch {
+		case ip == nil:
+			reason = "is not an IP address"
+		case addressType != "" && (ip.To4() != nil) != (addressType == addressTypeIPv4):
+			reason = "is not an " + addressType + " address"
+		default:
+			return address, true
+		}
+	case addressTypeFQDN:
+		host := strings.TrimSuffix(address, ".")
+		if errs := validation.IsDNS1123Subdomain(strings.ToLower(host)); len(errs) > 0 {
+			reason = "is not a valid host name: " + strings.Join(errs, "; ")
+		} else {
+			return host, true
+		}
+	default:
+		reason = "has unsupported addressType " + addressType
+	}
+
+	key := namespace + "/" + slice + "/" + address
+	if _, logged := skippedAddresses.LoadOrStore(key, true); !logged {
+		logutil.Printf("WARN", "[K8S] Skipping address %q of EndpointSlice %s/%s: it %s", address, namespace, slice, reason)
+	}
+	return "", false
+}
@@ -0,0 +1,98 @@
+package k8s
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+func endpointSlice(name string, addressType discoveryv1.AddressType, addresses ...string) *discoveryv1.EndpointSlice {
+	ready := true
+	port := int32(9100)
+	node := "node-a"
+	return &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "monitoring",
+			Labels: map[string]string{discoveryv1.LabelServiceName: "exporter"}},
+		AddressType: addressType,
+		Endpoints: []discoveryv1.Endpoint{{Addresses: addresses, NodeName: &node,
+			Conditions: discoveryv1.EndpointConditions{Ready: &ready}}},
+		Ports: []discoveryv1.EndpointPort{{Port: &port}},
+	}
+}
+
+func TestGetEndpointsForService_MixedAddressTypes(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	slices := []*discoveryv1.EndpointSlice{
+		endpointSlice("exporter-v4", discoveryv1.AddressTypeIPv4, "10.0.0.5", "fd00::5"),
+		endpointSlice("exporter-v6", discoveryv1.AddressTypeIPv6, "fd00::6", "10.0.0.6"),
+		endpointSlice("exporter-fqdn", discoveryv1.AddressTypeFQDN, "metrics.example.com.", "not a host"),
+		endpointSlice("exporter-other", discoveryv1.AddressType("Unix"), "/var/run/exporter.sock"),
+	}
+	for _, es := range slices {
+		if _, err := client.DiscoveryV1().EndpointSlices("monitoring").Create(context.Background(), es, metav1.CreateOptions{}); err != nil {
+			t.Fatalf("create %s: %v", es.Name, err)
+		}
+	}
+
+	factory := informers.NewSharedInformerFactory(client, 0)
+	informer := factory.Discovery().V1().EndpointSlices().Informer()
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	factory.Start(stopCh)
+	if !cache.WaitForCacheSync(stopCh, informer.HasSynced) {
+		t.Fatal("informer did not sync")
+	}
+
+	c := &K8sClient{endpointSliceStore: informer.GetStore(), initialized: true, useV1EndpointSlice: true}
+	endpoints, err := c.GetEndpointsForService("monitoring", "exporter")
+	if err != nil || endpoints == nil {
+		t.Fatalf("GetEndpointsForService: %+v, %v", endpoints, err)
+	}
+
+	var got []string
+	for _, address := range endpoints.Subsets[0].Addresses {
+		got = append(got, address.IP)
+		if address.NodeName == nil || *address.NodeName != "node-a" {
+			t.Errorf("expected the node name of %s to be kept", address.IP)
+		}
+	}
+	sort.Strings(got)
+	want := []string{"10.0.0.5", "fd00::6", "metrics.example.com"}
+	if len(got) != len(want) {
+		t.Fatalf("addresses = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("addresses = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestEndpointSliceAddress(t *testing.T) {
+	tests := []struct {
+		addressType, address, want string
+		ok                         bool
+	}{
+		{"IPv4", "10.0.0.1", "10.0.0.1", true},
+		{"IPv4", "::1", "", false},
+		{"IPv6", "2001:db8::1", "2001:db8::1", true},
+		{"IPv6", "10.0.0.1", "", false},
+		{"FQDN", "db.example.com", "db.example.com", true},
+		{"FQDN", "db.example.com.", "db.example.com", true},
+		{"FQDN", "db_example", "", false},
+		{"", "10.0.0.1", "10.0.0.1", true},
+		{"", "db.example.com", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := endpointSliceAddress("ns", "slice", tt.addressType, tt.address)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("endpointSliceAddress(%s, %q) = %q, %v, want %q, %v", tt.addressType, tt.address, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package k8s
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	discoveryv1beta1 "k8s.io/api/discovery/v1beta1"
+)
+
+// EndpointZone is the topology of one EndpointSlice endpoint
+type EndpointZone struct {
+	// Zone is the zone of the endpoint's node
+	Zone string
+	// ForZones are the zones the endpoint's topology hints assign it to
+	ForZones []string
+	// NodeName is the endpoint's node
+	NodeName string
+}
+
+// ServesZone reports whether the endpoint is meant for consumers in zone: by its topology hints when
+// EndpointSlice controller set them, otherwise by the endpoint's own zone
+func (z EndpointZone) ServesZone(zone string) bool {
+	if len(z.ForZones) > 0 {
+		for _, forZone := range z.ForZones {
+			if forZone == zone {
+				return true
+			}
+		}
+		return false
+	}
+	return z.Zone != "" && z.Zone == zone
+}
+
+// GetEndpointZones returns the topology of the service's EndpointSlice endpoints, keyed by the address
+// used in the Endpoints returned by GetEndpointsForService
+func (c *K8sClient) GetEndpointZones(namespace, serviceName string) (map[string]EndpointZone, error) {
+	if !c.IsInitialized() {
+		return nil, nil
+	}
+
+	zones := make(map[string]EndpointZone)
+	for _, obj := range c.endpointSliceStore.List() {
+		switch es := obj.(type) {
+		case *discoveryv1.EndpointSlice:
+			if es.Namespace != namespace || es.Labels[discoveryv1.LabelServiceName] != serviceName {
+				continue
+			}
+			for _, ep := range es.Endpoints {
+				var zone EndpointZone
+				if ep.Zone != nil {
+					zone.Zone = *ep.Zone
+				}
+				if ep.Hints != nil {
+					for _, forZone := range ep.Hints.ForZones {
+						zone.ForZones = append(zone.ForZones, forZone.Name)
+					}
+				}
+				if ep.NodeName != nil {
+					zone.NodeName = *ep.NodeName
+				}
+				for _, addr := range ep.Addresses {
+					if addr, ok := endpointSliceAddress(es.Namespace, es.Name, string(es.AddressType), addr); ok {
+						zones[addr] = zone
+					}
+				}
+			}
+		case *discoveryv1beta1.EndpointSlice:
+			if es.Namespace != namespace || es.Labels[discoveryv1beta1.LabelServiceName] != serviceName {
+				continue
+			}
+			for _, ep := range es.Endpoints {
+				// v1beta1 has the zone in the deprecated topology map
+				zone := EndpointZone{Zone: ep.Topology[corev1.LabelTopologyZone]}
+				if ep.Hints != nil {
+					for _, forZone := range ep.Hints.ForZones {
+						zone.ForZones = append(zone.ForZones, forZone.Name)
+					}
+				}
+				if ep.NodeName != nil {
+					zone.NodeName = *ep.NodeName
+				}
+				for _, addr := range ep.Addresses {
+					if addr, ok := endpointSliceAddress(es.Namespace, es.Name, string(es.AddressType), addr); ok {
+						zones[addr] = zone
+					}
+				}
+			}
+		}
+	}
+	return zones, nil
+}
@@ -0,0 +1,54 @@
+package k8s
+
+import (
+	"reflect"
+	"testing"
+
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestGetEndpointZones(t *testing.T) {
+	zoneA, zoneB := "zone-a", "zone-b"
+	es := endpointSlice("exporter-zoned", discoveryv1.AddressTypeIPv4)
+	es.Endpoints = []discoveryv1.Endpoint{
+		{Addresses: []string{"10.0.1.1"}, Zone: &zoneA, NodeName: es.Endpoints[0].NodeName},
+		{Addresses: []string{"10.0.2.1"}, Zone: &zoneB,
+			Hints: &discoveryv1.EndpointHints{ForZones: []discoveryv1.ForZone{{Name: "zone-a"}}}},
+		{Addresses: []string{"10.0.3.1"}},
+	}
+	client := fake.NewSimpleClientset(es)
+
+	factory := informers.NewSharedInformerFactory(client, 0)
+	informer := factory.Discovery().V1().EndpointSlices().Informer()
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	factory.Start(stopCh)
+	if !cache.WaitForCacheSync(stopCh, informer.HasSynced) {
+		t.Fatal("informer did not sync")
+	}
+
+	c := &K8sClient{endpointSliceStore: informer.GetStore(), initialized: true, useV1EndpointSlice: true}
+	zones, err := c.GetEndpointZones("monitoring", "exporter")
+	if err != nil {
+		t.Fatalf("GetEndpointZones: %v", err)
+	}
+	want := map[string]EndpointZone{
+		"10.0.1.1": {Zone: "zone-a", NodeName: "node-a"},
+		"10.0.2.1": {Zone: "zone-b", ForZones: []string{"zone-a"}},
+		"10.0.3.1": {},
+	}
+	if !reflect.DeepEqual(zones, want) {
+		t.Errorf("zones = %+v, want %+v", zones, want)
+	}
+
+	// Hints take precedence over the endpoint's own zone
+	if !zones["10.0.2.1"].ServesZone("zone-a") || zones["10.0.2.1"].ServesZone("zone-b") {
+		t.Errorf("expected the hinted endpoint to serve zone-a only")
+	}
+	if !zones["10.0.1.1"].ServesZone("zone-a") || zones["10.0.3.1"].ServesZone("") {
+		t.Errorf("unexpected zone matches for endpoints without hints")
+	}
+}
@@ -0,0 +1,90 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// eventComponent is the source component of the Events the agent posts
+const eventComponent = "whatap-open-agent"
+
+// eventTimeout bounds posting one Event
+const eventTimeout = 5 * time.Second
+
+// ObjectRef identifies the Kubernetes object a scrape target was discovered from
+type ObjectRef struct {
+	Kind      string // "Pod" or "Service"
+	Namespace string
+	Name      string
+	UID       types.UID
+}
+
+func (r ObjectRef) String() string {
+	return fmt.Sprintf("%s %s/%s", r.Kind, r.Namespace, r.Name)
+}
+
+// EventRecorder posts Kubernetes Events about scraped objects.
+// The agent's service account needs the create verb on events in the scraped namespaces.
+type EventRecorder struct {
+	client kubernetes.Interface
+	host   string
+}
+
+// NewEventRecorder returns an EventRecorder posting through the given clientset
+func NewEventRecorder(client kubernetes.Interface) *EventRecorder {
+	host, _ := os.Hostname()
+	return &EventRecorder{client: client, host: host}
+}
+
+// EventRecorder returns a recorder using the client's clientset, or nil if the client is not initialized
+func (c *K8sClient) EventRecorder() *EventRecorder {
+	if !c.IsInitialized() || c.clientset == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.eventRecorder == nil {
+		c.eventRecorder = NewEventRecorder(c.clientset)
+	}
+	return c.eventRecorder
+}
+
+// Event posts an Event of the given type (corev1.EventTypeNormal or corev1.EventTypeWarning) on the object
+func (r *EventRecorder) Event(ref ObjectRef, eventType, reason, message string) error {
+	now := metav1.Now()
+	event := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			// Same naming scheme as client-go's event recorder
+			Name:      fmt.Sprintf("%s.%x", ref.Name, now.UnixNano()),
+			Namespace: ref.Namespace,
+		},
+		InvolvedObject: corev1.ObjectReference{
+			APIVersion: "v1",
+			Kind:       ref.Kind,
+			Namespace:  ref.Namespace,
+			Name:       ref.Name,
+			UID:        ref.UID,
+		},
+		Type:                eventType,
+		Reason:              reason,
+		Message:             message,
+		Source:              corev1.EventSource{Component: eventComponent, Host: r.host},
+		ReportingController: eventComponent,
+		ReportingInstance:   r.host,
+		FirstTimestamp:      now,
+		LastTimestamp:       now,
+		Count:               1,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), eventTimeout)
+	defer cancel()
+	_, err := r.client.CoreV1().Events(ref.Namespace).Create(ctx, event, metav1.CreateOptions{})
+	return err
+}
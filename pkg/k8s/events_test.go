@@ -0,0 +1,38 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestEventRecorder_Event(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	recorder := NewEventRecorder(client)
+
+	ref := ObjectRef{Kind: "Pod", Namespace: "team-a", Name: "api-7d9f8b6c5-x2k4p", UID: "3f1c2a4e-0001"}
+	if err := recorder.Event(ref, corev1.EventTypeWarning, "ScrapeFailing", "connection refused"); err != nil {
+		t.Fatalf("Event: %v", err)
+	}
+
+	events, err := client.CoreV1().Events("team-a").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("list events: %v", err)
+	}
+	if len(events.Items) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(events.Items))
+	}
+	event := events.Items[0]
+	if event.InvolvedObject.Kind != "Pod" || event.InvolvedObject.Name != ref.Name || event.InvolvedObject.UID != ref.UID {
+		t.Errorf("unexpected involved object %+v", event.InvolvedObject)
+	}
+	if event.Type != corev1.EventTypeWarning || event.Reason != "ScrapeFailing" || event.Message != "connection refused" {
+		t.Errorf("unexpected event %s/%s: %q", event.Type, event.Reason, event.Message)
+	}
+	if event.Source.Component != eventComponent {
+		t.Errorf("expected source component %q, got %q", eventComponent, event.Source.Component)
+	}
+}
@@ -0,0 +1,43 @@
+package k8s
+
+import (
+	corev1 "k8s.io/api/core/v1"
+)
+
+// PodPort is a named container port found in a pod spec
+type PodPort struct {
+	Port      int32
+	Container string
+	// Sidecar is set when the port is declared on a sidecar, an init container with restartPolicy: Always
+	Sidecar bool
+}
+
+// isSidecar reports whether an init container is a sidecar that keeps running next to the regular
+// containers (Kubernetes 1.29+), e.g. istio-proxy or vault-agent
+func isSidecar(container corev1.Container) bool {
+	return container.RestartPolicy != nil && *container.RestartPolicy == corev1.ContainerRestartPolicyAlways
+}
+
+// FindPodPort looks up the container port named name in the pod's containers, then in its sidecar init
+// containers. A regular container wins when both declare the name. Other init containers are skipped,
+// as they have exited before the pod is ready.
+func FindPodPort(pod *corev1.Pod, name string) (PodPort, bool) {
+	for _, container := range pod.Spec.Containers {
+		for _, p := range container.Ports {
+			if p.Name == name {
+				return PodPort{Port: p.ContainerPort, Container: container.Name}, true
+			}
+		}
+	}
+	for _, container := range pod.Spec.InitContainers {
+		if !isSidecar(container) {
+			continue
+		}
+		for _, p := range container.Ports {
+			if p.Name == name {
+				return PodPort{Port: p.ContainerPort, Container: container.Name, Sidecar: true}, true
+			}
+		}
+	}
+	return PodPort{}, false
+}
@@ -0,0 +1,71 @@
+package k8s
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// sidecarPod has an application container, an istio-proxy sidecar init container and a plain init container
+func sidecarPod() *corev1.Pod {
+	always := corev1.ContainerRestartPolicyAlways
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "api-0", Namespace: "default"},
+		Spec: corev1.PodSpec{
+			InitContainers: []corev1.Container{
+				{Name: "migrate", Ports: []corev1.ContainerPort{{Name: "migrate-metrics", ContainerPort: 9400}}},
+				{Name: "istio-proxy", RestartPolicy: &always, Ports: []corev1.ContainerPort{
+					{Name: "http-envoy-prom", ContainerPort: 15090},
+					{Name: "metrics", ContainerPort: 15020},
+				}},
+			},
+			Containers: []corev1.Container{
+				{Name: "api", Ports: []corev1.ContainerPort{{Name: "metrics", ContainerPort: 9090}}},
+			},
+		},
+	}
+}
+
+func TestFindPodPort(t *testing.T) {
+	pod := sidecarPod()
+	tests := []struct {
+		name  string
+		want  PodPort
+		found bool
+	}{
+		{"http-envoy-prom", PodPort{Port: 15090, Container: "istio-proxy", Sidecar: true}, true},
+		// The regular container wins over the sidecar declaring the same name
+		{"metrics", PodPort{Port: 9090, Container: "api"}, true},
+		// Plain init containers have exited by the time the pod is scraped
+		{"migrate-metrics", PodPort{}, false},
+		{"missing", PodPort{}, false},
+	}
+	for _, tt := range tests {
+		got, found := FindPodPort(pod, tt.name)
+		if found != tt.found || got != tt.want {
+			t.Errorf("FindPodPort(%s) = %+v, %v; want %+v, %v", tt.name, got, found, tt.want, tt.found)
+		}
+	}
+}
+
+func TestGetPodPort_SidecarInitContainer(t *testing.T) {
+	c := &K8sClient{}
+	port, err := c.GetPodPort(sidecarPod(), "http-envoy-prom")
+	if err != nil || port != 15090 {
+		t.Fatalf("expected the sidecar port 15090, got %d (%v)", port, err)
+	}
+	if _, err := c.GetPodPort(sidecarPod(), "migrate-metrics"); err == nil {
+		t.Errorf("expected the port of a plain init container not to be found")
+	}
+}
+
+func TestTransformPod_KeepsSidecarPorts(t *testing.T) {
+	pod := mustTransformPod(t, sidecarPod())
+	if len(pod.Spec.InitContainers) != 1 || pod.Spec.InitContainers[0].Name != "istio-proxy" {
+		t.Fatalf("expected only the sidecar init container to be kept, got %+v", pod.Spec.InitContainers)
+	}
+	if p, ok := FindPodPort(pod, "http-envoy-prom"); !ok || p.Port != 15090 || !p.Sidecar {
+		t.Errorf("expected the sidecar port to resolve on the transformed pod, got %+v, %v", p, ok)
+	}
+}
@@ -0,0 +1,80 @@
+package k8s
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/rest"
+)
+
+// K8sProvider is the part of the Kubernetes client used by discovery, scraping and TLS secret lookup.
+// It is implemented by K8sClient and, in standalone mode, by NoopK8sProvider.
+type K8sProvider interface {
+	IsInitialized() bool
+	RestConfig() *rest.Config
+	GetSecret(namespace, name string) (*corev1.Secret, error)
+	GetPodsByLabels(namespace string, labelSelector map[string]string) ([]*corev1.Pod, error)
+	GetServicesByLabels(namespace string, labelSelector map[string]string) ([]*corev1.Service, error)
+	GetEndpointsForService(namespace, serviceName string) (*corev1.Endpoints, error)
+	GetEndpointZones(namespace, serviceName string) (map[string]EndpointZone, error)
+	GetNamespacesByNames(names []string) ([]*corev1.Namespace, error)
+	GetNamespacesByLabels(labelSelector map[string]string) ([]*corev1.Namespace, error)
+	GetPodPort(pod *corev1.Pod, portName string) (int32, error)
+	GetServicePort(service *corev1.Service, portName string) (int32, error)
+}
+
+var _ K8sProvider = (*K8sClient)(nil)
+
+// errStandalone is returned by NoopK8sProvider lookups
+var errStandalone = fmt.Errorf("kubernetes is not available in standalone mode")
+
+// NoopK8sProvider is used in standalone mode, so StaticEndpoints-only deployments never
+// create the K8sClient singleton or its informers. It is never initialized and every lookup fails.
+type NoopK8sProvider struct{}
+
+func (NoopK8sProvider) IsInitialized() bool      { return false }
+func (NoopK8sProvider) RestConfig() *rest.Config { return nil }
+
+func (NoopK8sProvider) GetSecret(namespace, name string) (*corev1.Secret, error) {
+	return nil, errStandalone
+}
+
+func (NoopK8sProvider) GetPodsByLabels(namespace string, labelSelector map[string]string) ([]*corev1.Pod, error) {
+	return nil, errStandalone
+}
+
+func (NoopK8sProvider) GetServicesByLabels(namespace string, labelSelector map[string]string) ([]*corev1.Service, error) {
+	return nil, errStandalone
+}
+
+func (NoopK8sProvider) GetEndpointsForService(namespace, serviceName string) (*corev1.Endpoints, error) {
+	return nil, errStandalone
+}
+
+func (NoopK8sProvider) GetEndpointZones(namespace, serviceName string) (map[string]EndpointZone, error) {
+	return nil, errStandalone
+}
+
+func (NoopK8sProvider) GetNamespacesByNames(names []string) ([]*corev1.Namespace, error) {
+	return nil, errStandalone
+}
+
+func (NoopK8sProvider) GetNamespacesByLabels(labelSelector map[string]string) ([]*corev1.Namespace, error) {
+	return nil, errStandalone
+}
+
+func (NoopK8sProvider) GetPodPort(pod *corev1.Pod, portName string) (int32, error) {
+	return 0, errStandalone
+}
+
+func (NoopK8sProvider) GetServicePort(service *corev1.Service, portName string) (int32, error) {
+	return 0, errStandalone
+}
+
+// NewProvider returns NoopK8sProvider in standalone mode and the K8sClient singleton otherwise
+func NewProvider(standalone bool) K8sProvider {
+	if standalone {
+		return NoopK8sProvider{}
+	}
+	return GetInstance()
+}
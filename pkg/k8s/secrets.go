@@ -0,0 +1,86 @@
+package k8s
+
+import (
+	"bytes"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// addSecretEventHandler calls the registered Secret handlers when a Secret is created, its data changes
+// or it is deleted, so credentials built from it can be reloaded before the next scrape
+func (c *K8sClient) addSecretEventHandler() {
+	c.secretInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if secret, ok := obj.(*corev1.Secret); ok {
+				c.handleSecretChange(secret)
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldSecret, ok1 := oldObj.(*corev1.Secret)
+			newSecret, ok2 := newObj.(*corev1.Secret)
+			// Resyncs deliver unchanged Secrets every 10 minutes
+			if ok1 && ok2 && !secretsEqual(oldSecret, newSecret) {
+				c.handleSecretChange(newSecret)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if secret, ok := obj.(*corev1.Secret); ok {
+				c.handleSecretChange(secret)
+			}
+		},
+	})
+}
+
+// secretsEqual checks if two Secrets have the same data
+func secretsEqual(s1, s2 *corev1.Secret) bool {
+	if len(s1.Data) != len(s2.Data) {
+		return false
+	}
+	for k, v1 := range s1.Data {
+		if v2, ok := s2.Data[k]; !ok || !bytes.Equal(v1, v2) {
+			return false
+		}
+	}
+	return true
+}
+
+// handleSecretChange calls all registered handlers for Secret changes
+func (c *K8sClient) handleSecretChange(secret *corev1.Secret) {
+	c.mu.RLock()
+	handlers := c.secretHandlers
+	c.mu.RUnlock()
+
+	for _, handler := range handlers {
+		handler(secret)
+	}
+}
+
+// RegisterSecretHandler registers a handler function to be called when a Secret is created, changed or deleted
+func (c *K8sClient) RegisterSecretHandler(handler func(*corev1.Secret)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.secretHandlers = append(c.secretHandlers, handler)
+}
+
+// GetSecret returns a Secret by name and namespace from the informer cache. Credentials are resolved
+// on every scrape, so this never calls the API server.
+func (c *K8sClient) GetSecret(namespace, name string) (*corev1.Secret, error) {
+	if !c.IsInitialized() {
+		return nil, fmt.Errorf("kubernetes client not initialized")
+	}
+
+	obj, exists, err := c.secretStore.GetByKey(namespace + "/" + name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("secret %s/%s not found", namespace, name)
+	}
+	return obj.(*corev1.Secret), nil
+}
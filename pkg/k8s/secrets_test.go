@@ -0,0 +1,78 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestSecretHandlers_CalledOnDataChange(t *testing.T) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "monitoring", Name: "exporter-auth"},
+		Data:       map[string][]byte{"token": []byte("old-token")},
+	}
+	client := fake.NewSimpleClientset(secret)
+	factory := informers.NewSharedInformerFactory(client, 0)
+	informer := factory.Core().V1().Secrets().Informer()
+	c := &K8sClient{secretInformer: informer, secretStore: informer.GetStore(), initialized: true}
+	c.addSecretEventHandler()
+
+	changes := make(chan string, 10)
+	c.RegisterSecretHandler(func(s *corev1.Secret) {
+		changes <- s.Namespace + "/" + s.Name + "=" + string(s.Data["token"])
+	})
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	factory.Start(stopCh)
+	if !cache.WaitForCacheSync(stopCh, informer.HasSynced) {
+		t.Fatal("informer did not sync")
+	}
+	next := func() string {
+		select {
+		case change := <-changes:
+			return change
+		case <-time.After(5 * time.Second):
+			return "timeout"
+		}
+	}
+	if got := next(); got != "monitoring/exporter-auth=old-token" {
+		t.Fatalf("expected the initial Secret, got %s", got)
+	}
+
+	// A metadata-only update does not reload credentials
+	secret = secret.DeepCopy()
+	secret.Labels = map[string]string{"team": "a"}
+	secrets := client.CoreV1().Secrets("monitoring")
+	if _, err := secrets.Update(context.Background(), secret, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	secret = secret.DeepCopy()
+	secret.Data["token"] = []byte("new-token")
+	if _, err := secrets.Update(context.Background(), secret, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := next(); got != "monitoring/exporter-auth=new-token" {
+		t.Errorf("expected the data change only, got %s", got)
+	}
+	got, err := c.GetSecret("monitoring", "exporter-auth")
+	if err != nil || string(got.Data["token"]) != "new-token" {
+		t.Errorf("expected GetSecret to return the new data from the cache, got %v, %v", got, err)
+	}
+
+	if err := secrets.Delete(context.Background(), "exporter-auth", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := next(); got != "monitoring/exporter-auth=new-token" {
+		t.Errorf("expected the deletion reported, got %s", got)
+	}
+	if _, err := c.GetSecret("monitoring", "exporter-auth"); err == nil {
+		t.Errorf("expected the deleted Secret not found")
+	}
+}
@@ -0,0 +1,135 @@
+package k8s
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	discoveryv1beta1 "k8s.io/api/discovery/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// lastAppliedAnnotation holds a full copy of the object written by kubectl apply; discovery never reads it
+const lastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+// setInformerTransforms strips fields discovery never reads before objects are stored in the informer caches.
+// On large clusters the pod cache holds env vars, volumes and container specs of every pod otherwise.
+func (c *K8sClient) setInformerTransforms() error {
+	if err := c.podInformer.SetTransform(transformPod); err != nil {
+		return err
+	}
+	if err := c.serviceInformer.SetTransform(transformService); err != nil {
+		return err
+	}
+	return c.endpointSliceInformer.SetTransform(transformEndpointSlice)
+}
+
+// CachedPod returns pod as the informer cache stores it, with the fields discovery never reads stripped
+func CachedPod(pod *corev1.Pod) *corev1.Pod {
+	obj, _ := transformPod(pod)
+	return obj.(*corev1.Pod)
+}
+
+// stripObjectMeta keeps the identity, labels and annotations of an object.
+// ResourceVersion is kept because the informer relies on it.
+func stripObjectMeta(meta metav1.ObjectMeta) metav1.ObjectMeta {
+	annotations := meta.Annotations
+	if _, ok := annotations[lastAppliedAnnotation]; ok {
+		annotations = make(map[string]string, len(meta.Annotations)-1)
+		for k, v := range meta.Annotations {
+			if k != lastAppliedAnnotation {
+				annotations[k] = v
+			}
+		}
+	}
+	return metav1.ObjectMeta{
+		Name:              meta.Name,
+		Namespace:         meta.Namespace,
+		UID:               meta.UID,
+		ResourceVersion:   meta.ResourceVersion,
+		CreationTimestamp: meta.CreationTimestamp,
+		DeletionTimestamp: meta.DeletionTimestamp,
+		Labels:            meta.Labels,
+		Annotations:       annotations,
+		OwnerReferences:   meta.OwnerReferences,
+	}
+}
+
+// transformPod keeps metadata, node name, host network, container ports (also of sidecar init containers) and the status fields
+// used for readiness and addressing. The Ready condition's transition time is kept for readyGracePeriod, and the
+// container restart counts for the pod generation.
+func transformPod(obj interface{}) (interface{}, error) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		// cache.DeletedFinalStateUnknown and other wrappers are stored as-is
+		return obj, nil
+	}
+
+	containers := make([]corev1.Container, 0, len(pod.Spec.Containers))
+	for _, container := range pod.Spec.Containers {
+		containers = append(containers, corev1.Container{Name: container.Name, Ports: container.Ports})
+	}
+	var sidecars []corev1.Container
+	for _, container := range pod.Spec.InitContainers {
+		if isSidecar(container) {
+			sidecars = append(sidecars, corev1.Container{Name: container.Name, Ports: container.Ports, RestartPolicy: container.RestartPolicy})
+		}
+	}
+	statuses := make([]corev1.ContainerStatus, 0, len(pod.Status.ContainerStatuses))
+	for _, status := range pod.Status.ContainerStatuses {
+		statuses = append(statuses, corev1.ContainerStatus{Name: status.Name, RestartCount: status.RestartCount})
+	}
+	conditions := make([]corev1.PodCondition, 0, len(pod.Status.Conditions))
+	for _, condition := range pod.Status.Conditions {
+		conditions = append(conditions, corev1.PodCondition{
+			Type:               condition.Type,
+			Status:             condition.Status,
+			LastTransitionTime: condition.LastTransitionTime,
+		})
+	}
+
+	return &corev1.Pod{
+		TypeMeta:   pod.TypeMeta,
+		ObjectMeta: stripObjectMeta(pod.ObjectMeta),
+		Spec: corev1.PodSpec{
+			NodeName:       pod.Spec.NodeName,
+			HostNetwork:    pod.Spec.HostNetwork,
+			Containers:     containers,
+			InitContainers: sidecars,
+		},
+		Status: corev1.PodStatus{
+			Phase:             pod.Status.Phase,
+			Conditions:        conditions,
+			ContainerStatuses: statuses,
+			HostIP:            pod.Status.HostIP,
+			PodIP:             pod.Status.PodIP,
+			PodIPs:            pod.Status.PodIPs,
+		},
+	}, nil
+}
+
+// transformService keeps the spec, which is small, and drops managedFields and the last-applied annotation
+func transformService(obj interface{}) (interface{}, error) {
+	service, ok := obj.(*corev1.Service)
+	if !ok {
+		return obj, nil
+	}
+	return &corev1.Service{
+		TypeMeta:   service.TypeMeta,
+		ObjectMeta: stripObjectMeta(service.ObjectMeta),
+		Spec:       service.Spec,
+	}, nil
+}
+
+// transformEndpointSlice drops managedFields and the last-applied annotation of v1 and v1beta1 EndpointSlices
+func transformEndpointSlice(obj interface{}) (interface{}, error) {
+	switch es := obj.(type) {
+	case *discoveryv1.EndpointSlice:
+		stripped := *es
+		stripped.ObjectMeta = stripObjectMeta(es.ObjectMeta)
+		return &stripped, nil
+	case *discoveryv1beta1.EndpointSlice:
+		stripped := *es
+		stripped.ObjectMeta = stripObjectMeta(es.ObjectMeta)
+		return &stripped, nil
+	}
+	return obj, nil
+}
@@ -0,0 +1,185 @@
+package k8s
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+)
+
+// productionPod builds a pod shaped like a typical application deployment
+func productionPod(i int) *corev1.Pod {
+	env := make([]corev1.EnvVar, 0, 40)
+	for j := 0; j < 40; j++ {
+		env = append(env, corev1.EnvVar{Name: fmt.Sprintf("APP_SETTING_%d", j), Value: strings.Repeat("v", 48)})
+	}
+	volumes := make([]corev1.Volume, 0, 6)
+	mounts := make([]corev1.VolumeMount, 0, 6)
+	for j := 0; j < 6; j++ {
+		name := fmt.Sprintf("config-%d", j)
+		volumes = append(volumes, corev1.Volume{Name: name, VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: name}}}})
+		mounts = append(mounts, corev1.VolumeMount{Name: name, MountPath: "/etc/" + name})
+	}
+
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            fmt.Sprintf("api-7d9f8b6c5-%05d", i),
+			Namespace:       "default",
+			UID:             types.UID(fmt.Sprintf("3f1c2a4e-%04d-4b7a-9c1d-0242ac120002", i)),
+			ResourceVersion: "12345",
+			Labels:          map[string]string{"app": "api", "tier": "backend"},
+			Annotations: map[string]string{
+				"prometheus.io/scrape": "true",
+				lastAppliedAnnotation:  strings.Repeat("{\"apiVersion\":\"v1\"}", 80),
+			},
+			ManagedFields: []metav1.ManagedFieldsEntry{
+				{Manager: "kube-controller-manager", Operation: metav1.ManagedFieldsOperationUpdate,
+					FieldsV1: &metav1.FieldsV1{Raw: []byte(strings.Repeat("{\"f:metadata\":{}}", 60))}},
+			},
+		},
+		Spec: corev1.PodSpec{
+			NodeName: "worker-01",
+			Volumes:  volumes,
+			Tolerations: []corev1.Toleration{
+				{Key: "node.kubernetes.io/not-ready", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoExecute},
+				{Key: "node.kubernetes.io/unreachable", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoExecute},
+			},
+			Containers: []corev1.Container{{
+				Name:         "api",
+				Image:        "registry.example.com/api:1.2.3",
+				Env:          env,
+				VolumeMounts: mounts,
+				Ports:        []corev1.ContainerPort{{Name: "metrics", ContainerPort: 9090, Protocol: corev1.ProtocolTCP}},
+			}},
+		},
+		Status: corev1.PodStatus{
+			Phase:  corev1.PodRunning,
+			HostIP: "10.0.1.1",
+			PodIP:  fmt.Sprintf("10.244.%d.%d", i/250, i%250),
+			Conditions: []corev1.PodCondition{
+				{Type: corev1.PodReady, Status: corev1.ConditionTrue, Message: "all containers ready"},
+			},
+		},
+	}
+}
+
+func mustTransformPod(t testing.TB, pod *corev1.Pod) *corev1.Pod {
+	obj, err := transformPod(pod)
+	if err != nil {
+		t.Fatalf("transformPod: %v", err)
+	}
+	return obj.(*corev1.Pod)
+}
+
+func TestTransformPod_AccessorsStillWork(t *testing.T) {
+	store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	original := productionPod(1)
+	if err := store.Add(mustTransformPod(t, original)); err != nil {
+		t.Fatalf("store.Add: %v", err)
+	}
+	c := &K8sClient{podStore: store, initialized: true}
+
+	pods, err := c.GetPodsByLabels("default", map[string]string{"app": "api"})
+	if err != nil || len(pods) != 1 {
+		t.Fatalf("expected 1 pod from GetPodsByLabels, got %d (%v)", len(pods), err)
+	}
+	pod := pods[0]
+
+	port, err := c.GetPodPort(pod, "metrics")
+	if err != nil || port != 9090 {
+		t.Fatalf("expected named port 9090, got %d (%v)", port, err)
+	}
+
+	ready := false
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady && condition.Status == corev1.ConditionTrue {
+			ready = true
+		}
+	}
+	if !ready || pod.Status.Phase != corev1.PodRunning {
+		t.Errorf("readiness fields must be preserved, got phase %s conditions %v", pod.Status.Phase, pod.Status.Conditions)
+	}
+	if pod.Status.PodIP != original.Status.PodIP || pod.Status.HostIP != "10.0.1.1" || pod.Spec.NodeName != "worker-01" ||
+		pod.UID != original.UID || pod.ResourceVersion != "12345" || pod.Annotations["prometheus.io/scrape"] != "true" {
+		t.Errorf("metadata and addressing fields must be preserved: %+v", pod.ObjectMeta)
+	}
+
+	if len(pod.ManagedFields) != 0 || len(pod.Spec.Volumes) != 0 || len(pod.Spec.Tolerations) != 0 ||
+		len(pod.Spec.Containers[0].Env) != 0 || pod.Annotations[lastAppliedAnnotation] != "" {
+		t.Errorf("unused fields must be stripped")
+	}
+	if len(original.Spec.Containers[0].Env) == 0 || original.Annotations[lastAppliedAnnotation] == "" {
+		t.Errorf("the original object must not be modified")
+	}
+}
+
+func TestTransformEndpointSlice_KeepsEndpoints(t *testing.T) {
+	ready := true
+	port := int32(8080)
+	es := &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{Name: "api-abcde", Namespace: "default",
+			Labels:        map[string]string{discoveryv1.LabelServiceName: "api"},
+			ManagedFields: []metav1.ManagedFieldsEntry{{Manager: "endpointslice-controller"}}},
+		Endpoints: []discoveryv1.Endpoint{{Addresses: []string{"10.244.0.5"}, Conditions: discoveryv1.EndpointConditions{Ready: &ready},
+			TargetRef: &corev1.ObjectReference{Kind: "Pod", Name: "api-0"}}},
+		Ports: []discoveryv1.EndpointPort{{Port: &port}},
+	}
+	obj, err := transformEndpointSlice(es)
+	if err != nil {
+		t.Fatalf("transformEndpointSlice: %v", err)
+	}
+	stripped := obj.(*discoveryv1.EndpointSlice)
+
+	store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	store.Add(stripped)
+	c := &K8sClient{endpointSliceStore: store, initialized: true, useV1EndpointSlice: true}
+	endpoints, err := c.GetEndpointsForService("default", "api")
+	if err != nil || len(endpoints.Subsets) != 1 || endpoints.Subsets[0].Addresses[0].TargetRef.Name != "api-0" {
+		t.Fatalf("expected endpoints from transformed slice, got %+v (%v)", endpoints, err)
+	}
+	if len(stripped.ManagedFields) != 0 || len(es.ManagedFields) != 1 {
+		t.Errorf("managedFields must be stripped from the copy only")
+	}
+}
+
+// TestTransformPod_MemoryReduction measures the heap retained by cached pods with and without the transform
+func TestTransformPod_MemoryReduction(t *testing.T) {
+	const pods = 2000
+	retained := func(transform bool) uint64 {
+		runtime.GC()
+		var before runtime.MemStats
+		runtime.ReadMemStats(&before)
+
+		cached := make([]*corev1.Pod, 0, pods)
+		for i := 0; i < pods; i++ {
+			pod := productionPod(i)
+			if transform {
+				pod = mustTransformPod(t, pod)
+			}
+			cached = append(cached, pod)
+		}
+
+		runtime.GC()
+		var after runtime.MemStats
+		runtime.ReadMemStats(&after)
+		runtime.KeepAlive(cached)
+		if after.HeapAlloc < before.HeapAlloc {
+			return 0
+		}
+		return after.HeapAlloc - before.HeapAlloc
+	}
+
+	full, stripped := retained(false), retained(true)
+	t.Logf("%d pods: full %d KiB (%d B/pod), transformed %d KiB (%d B/pod)",
+		pods, full/1024, full/pods, stripped/1024, stripped/pods)
+	if stripped >= full {
+		t.Errorf("expected transformed pods to retain less memory, got %d >= %d", stripped, full)
+	}
+}
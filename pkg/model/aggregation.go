@@ -0,0 +1,101 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Aggregation operations
+const (
+	AggregationSum = "sum"
+	AggregationAvg = "avg"
+	AggregationMax = "max"
+	AggregationMin = "min"
+)
+
+// metricNameRegex matches valid Prometheus metric names
+var metricNameRegex = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+// Aggregation pre-aggregates the samples of one metric within a scrape, recording-rule style, e.g. the
+// sum of a per-path request counter by method. Only the samples of the same scrape are aggregated.
+type Aggregation struct {
+	// SourceMetric is the metric name aggregated, after metricPrefix
+	SourceMetric string
+	// By are the labels the output series are grouped by; a missing label groups as the empty value
+	By []string
+	// Op is sum, avg, max or min
+	Op string
+	// OutputMetric is the name of the aggregated series
+	OutputMetric string
+	// DropSource removes the source series once aggregated
+	DropSource bool
+}
+
+// Aggregations is a slice of Aggregation. Every rule aggregates the scrape's samples as exposed, so
+// the output of one rule is not the source of another.
+type Aggregations []*Aggregation
+
+// String describes the rule for logs, e.g. sum(http_requests_total) by (method) -> http_requests_by_method
+func (a *Aggregation) String() string {
+	return fmt.Sprintf("%s(%s) by (%s) -> %s", a.Op, a.SourceMetric, strings.Join(a.By, ", "), a.OutputMetric)
+}
+
+// ParseAggregations parses the aggregations entries of a target configuration. Unknown ops, invalid
+// metric names and an output that would overwrite its kept source are rejected.
+func ParseAggregations(configs []interface{}) (Aggregations, error) {
+	result := make(Aggregations, 0, len(configs))
+	for i, c := range configs {
+		configMap, ok := c.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("aggregations[%d]: expected a map", i)
+		}
+
+		sourceMetric, _ := configMap["sourceMetric"].(string)
+		if !metricNameRegex.MatchString(sourceMetric) {
+			return nil, fmt.Errorf("aggregations[%d]: sourceMetric %q is not a valid metric name", i, sourceMetric)
+		}
+		outputMetric, _ := configMap["outputMetric"].(string)
+		if !metricNameRegex.MatchString(outputMetric) {
+			return nil, fmt.Errorf("aggregations[%d]: outputMetric %q is not a valid metric name", i, outputMetric)
+		}
+
+		op, _ := configMap["op"].(string)
+		switch op {
+		case AggregationSum, AggregationAvg, AggregationMax, AggregationMin:
+		default:
+			return nil, fmt.Errorf("aggregations[%d]: unknown op %q (expected sum, avg, max or min)", i, op)
+		}
+
+		var by []string
+		switch v := configMap["by"].(type) {
+		case nil:
+		case []interface{}:
+			seen := make(map[string]bool, len(v))
+			for _, item := range v {
+				label, _ := item.(string)
+				if label == "" || seen[label] {
+					return nil, fmt.Errorf("aggregations[%d]: by label %v is empty, not a string or listed twice", i, item)
+				}
+				seen[label] = true
+				by = append(by, label)
+			}
+		default:
+			return nil, fmt.Errorf("aggregations[%d]: by: expected a list of labels, got %T", i, v)
+		}
+
+		dropSource, _ := configMap["dropSource"].(bool)
+		if outputMetric == sourceMetric && !dropSource {
+			return nil, fmt.Errorf("aggregations[%d]: outputMetric %q is the sourceMetric; set dropSource to replace it", i, outputMetric)
+		}
+
+		result = append(result, &Aggregation{
+			SourceMetric: sourceMetric,
+			By:           by,
+			Op:           op,
+			OutputMetric: outputMetric,
+			DropSource:   dropSource,
+		})
+	}
+	return result, nil
+}
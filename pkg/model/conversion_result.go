@@ -10,6 +10,9 @@ type ConversionResult struct {
 	OpenMxHistogramList []*OpenMxHistogram
 	Target              string
 	CollectionTime      int64
+	// ScrapeStart is the unix millis the scrape started, 0 for results not built from a scrape such as
+	// self-metrics. The sender measures the pipeline latency from it.
+	ScrapeStart int64
 }
 
 // NewConversionResult creates a new ConversionResult instance
@@ -59,3 +62,13 @@ func (cr *ConversionResult) GetCollectionTime() int64 {
 func (cr *ConversionResult) SetCollectionTime(time int64) {
 	cr.CollectionTime = time
 }
+
+// GetScrapeStart returns the unix millis the scrape started, or 0
+func (cr *ConversionResult) GetScrapeStart() int64 {
+	return cr.ScrapeStart
+}
+
+// SetScrapeStart sets the unix millis the scrape started
+func (cr *ConversionResult) SetScrapeStart(scrapeStart int64) {
+	cr.ScrapeStart = scrapeStart
+}
@@ -0,0 +1,51 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Supported downsample aggregations
+const (
+	DownsampleAvg = "avg"
+	DownsampleMax = "max"
+	DownsampleMin = "min"
+)
+
+// DownsampleConfig aggregates samples of each series over a fixed window
+// before they are sent, e.g. "5m:avg"
+type DownsampleConfig struct {
+	Window      time.Duration
+	Aggregation string
+}
+
+// ParseDownsampleConfig parses a "<window>:<aggregation>" setting such as "5m:avg" or "5m:max"
+func ParseDownsampleConfig(value string) (*DownsampleConfig, error) {
+	parts := strings.SplitN(strings.TrimSpace(value), ":", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid downsample %q, expected <window>:<aggregation>", value)
+	}
+
+	window, err := time.ParseDuration(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return nil, fmt.Errorf("invalid downsample window %q: %v", parts[0], err)
+	}
+	if window <= 0 {
+		return nil, fmt.Errorf("invalid downsample window %q: must be positive", parts[0])
+	}
+
+	aggregation := strings.ToLower(strings.TrimSpace(parts[1]))
+	switch aggregation {
+	case DownsampleAvg, DownsampleMax, DownsampleMin:
+	default:
+		return nil, fmt.Errorf("unsupported downsample aggregation %q (avg, max, min)", parts[1])
+	}
+
+	return &DownsampleConfig{Window: window, Aggregation: aggregation}, nil
+}
+
+// String returns the setting in its configured form
+func (dc *DownsampleConfig) String() string {
+	return fmt.Sprintf("%s:%s", dc.Window, dc.Aggregation)
+}
@@ -0,0 +1,66 @@
+package model
+
+import "fmt"
+
+// InfoJoin copies labels of an info-style metric (constant value 1, e.g. kube_pod_info) onto the
+// other series of the same scrape
+type InfoJoin struct {
+	// Metric is the info metric name
+	Metric string
+	// Labels are the info metric labels to copy; empty copies every label except JoinOn
+	Labels []string
+	// JoinOn are the labels an info series and a series must share; empty applies the info series target-wide
+	JoinOn []string
+	// KeepInfo keeps the info series itself; by default it is dropped once joined
+	KeepInfo bool
+}
+
+// InfoJoins is a slice of InfoJoin
+type InfoJoins []*InfoJoin
+
+// ParseInfoJoins parses infoJoin entries of an endpoint configuration
+func ParseInfoJoins(configs []interface{}) (InfoJoins, error) {
+	result := make(InfoJoins, 0, len(configs))
+	for i, c := range configs {
+		configMap, ok := c.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("infoJoin[%d]: expected a map", i)
+		}
+
+		metric, _ := configMap["metric"].(string)
+		if metric == "" {
+			return nil, fmt.Errorf("infoJoin[%d]: metric is required", i)
+		}
+		join := &InfoJoin{
+			Metric: metric,
+			Labels: stringList(configMap["labels"]),
+			JoinOn: stringList(configMap["joinOn"]),
+		}
+		if keepInfo, ok := configMap["keepInfo"].(bool); ok {
+			join.KeepInfo = keepInfo
+		}
+		result = append(result, join)
+	}
+	return result, nil
+}
+
+// stringList accepts a single string or a list of strings
+func stringList(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		if v != "" {
+			return []string{v}
+		}
+	case []interface{}:
+		list := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok && s != "" {
+				list = append(list, s)
+			}
+		}
+		return list
+	case []string:
+		return v
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Label value length modes
+const (
+	LabelLengthStrict   = "strict"   // drop samples with a label value over the limit
+	LabelLengthTruncate = "truncate" // shorten the value and end it with a hash of the original
+	LabelLengthOff      = "off"
+)
+
+// LabelHashLength is the length of the hash that ends a truncated label value
+const LabelHashLength = 6
+
+// minTruncateLimit keeps some of the original value in front of the hash
+const minTruncateLimit = 2 * LabelHashLength
+
+// LabelLengthLimit bounds the length of label values exposed by a target, in bytes
+type LabelLengthLimit struct {
+	Limit int
+	Mode  string
+}
+
+// ParseLabelLengthLimit parses labelValueLengthLimit and labelValueLengthMode of an endpoint.
+// The mode defaults to truncate; it returns nil when there is no limit or the mode is off.
+func ParseLabelLengthLimit(limit int, mode string) (*LabelLengthLimit, error) {
+	mode = strings.ToLower(strings.TrimSpace(mode))
+	if mode == "" {
+		mode = LabelLengthTruncate
+	}
+	switch mode {
+	case LabelLengthStrict, LabelLengthTruncate:
+	case LabelLengthOff:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unsupported labelValueLengthMode %q (strict, truncate, off)", mode)
+	}
+
+	if limit < 0 {
+		return nil, fmt.Errorf("invalid labelValueLengthLimit %d: must not be negative", limit)
+	}
+	if limit == 0 {
+		return nil, nil
+	}
+	if mode == LabelLengthTruncate && limit < minTruncateLimit {
+		return nil, fmt.Errorf("invalid labelValueLengthLimit %d: truncate needs at least %d", limit, minTruncateLimit)
+	}
+	return &LabelLengthLimit{Limit: limit, Mode: mode}, nil
+}
+
+// String returns the setting in a "<mode>:<limit>" form for logs
+func (l *LabelLengthLimit) String() string {
+	return fmt.Sprintf("%s:%d", l.Mode, l.Limit)
+}
@@ -0,0 +1,26 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Handling of NaN and ±Inf sample values
+const (
+	NonFiniteDrop        = "drop"        // drop the sample
+	NonFiniteZero        = "zero"        // send the sample with value 0
+	NonFinitePassthrough = "passthrough" // send the value as exposed
+)
+
+// ParseNonFiniteValues parses nonFiniteValues of an endpoint, defaulting to drop
+func ParseNonFiniteValues(policy string) (string, error) {
+	policy = strings.ToLower(strings.TrimSpace(policy))
+	switch policy {
+	case "":
+		return NonFiniteDrop, nil
+	case NonFiniteDrop, NonFiniteZero, NonFinitePassthrough:
+		return policy, nil
+	default:
+		return NonFiniteDrop, fmt.Errorf("unsupported nonFiniteValues %q (drop, zero, passthrough)", policy)
+	}
+}
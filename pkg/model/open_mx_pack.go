@@ -80,6 +80,11 @@ func (p *OpenMxPack) reset(items []*OpenMx) *OpenMxPack {
 	return p
 }
 
+// Size returns the encoded (and compressed) size of the records in bytes
+func (p *OpenMxPack) Size() int {
+	return len(p.bytes)
+}
+
 // GetRecords returns the records from the pack
 func (p *OpenMxPack) GetRecords() []*OpenMx {
 	if p.bytes == nil {
@@ -0,0 +1,15 @@
+package model
+
+import "gopkg.in/yaml.v3"
+
+// UnmarshalYAML decodes a relabel config on top of the defaults of NewRelabelConfig,
+// so omitted fields behave the same as with ParseRelabelConfigs
+func (c *RelabelConfig) UnmarshalYAML(node *yaml.Node) error {
+	type plain RelabelConfig
+	config := (*plain)(NewRelabelConfig())
+	if err := node.Decode(config); err != nil {
+		return err
+	}
+	*c = RelabelConfig(*config)
+	return nil
+}
@@ -1,7 +1,10 @@
 package model
 
+import "time"
+
 // ScrapeRawData represents raw metrics data scraped from a target
 type ScrapeRawData struct {
+	TargetID             string // Discovery target ID, used to capture samples for the debug endpoint
 	TargetURL            string
 	RawData              string
 	ContentType          string // Response Content-Type, used to select the protobuf vs. text decoder
@@ -9,7 +12,44 @@ type ScrapeRawData struct {
 	Labels               map[string]string // Target labels
 	NodeName             string
 	AddNodeLabel         bool
-	CollectionTime       int64 // Unix timestamp in milliseconds when data was collected
+	CollectionTime       int64             // Unix timestamp in milliseconds when data was collected
+	ScrapeStart          int64             // Unix timestamp in milliseconds when the scrape started, the origin of the pipeline latency
+	Downsample           *DownsampleConfig // Optional per-series window aggregation
+	MetricPrefix         string            // Prepended to metric names before metric relabeling
+	UnitConversions      UnitConversions   // Applied before the metric prefix and metric relabeling
+	ValueTransforms      ValueTransforms   // Applied after unit conversions, before metric relabeling
+	InfoJoins            InfoJoins         // Info metric labels joined onto other series before the metric prefix
+	Aggregations         Aggregations      // Within-scrape aggregation after the metric prefix, before metric relabeling
+
+	// PreserveAgentNodeLabel adds NodeName as agent_node when the exposition already has a node label
+	PreserveAgentNodeLabel bool
+	// LabelLengthLimit is applied after metric relabeling, so rules still see the original values
+	LabelLengthLimit *LabelLengthLimit
+	// AlignInterval stamps samples with the scrape-cycle boundary instead of CollectionTime when set
+	AlignInterval time.Duration
+	// NonFiniteValues is how NaN and ±Inf values are handled: drop (default), zero or passthrough
+	NonFiniteValues string
+	// CertNotAfter is the expiry of the target's leaf certificate for https scrapes, zero otherwise
+	CertNotAfter time.Time
+	// MinSamples marks a scrape exposing fewer samples as degraded, 0 disables the check
+	MinSamples int
+	// ExternalLabels are added to every sample that does not have the label already
+	ExternalLabels map[string]string
+	// SampleLimit drops every sample of a scrape exposing more samples after metric relabeling, 0 is unlimited
+	SampleLimit int
+	// SampleBudget is the samples per minute budget of the target's namespace, nil when unlimited
+	SampleBudget *NamespaceSampleBudget
+	// HonorLabels keeps exposed labels that collide with target labels, e.g. the job and instance of
+	// federated series, instead of adding the target's
+	HonorLabels bool
+	// IgnoreTimestamps stamps every sample with the scrape time instead of the exposition's timestamp
+	IgnoreTimestamps bool
+}
+
+// NamespaceSampleBudget is the samples per minute the targets of one namespace may send together
+type NamespaceSampleBudget struct {
+	Namespace           string
+	MaxSamplesPerMinute int
 }
 
 // NewScrapeRawData creates a new ScrapeRawData instance
@@ -0,0 +1,42 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Timestamp alignment modes
+const (
+	TimestampAlignmentNone     = "none"     // stamp samples with the scrape start time
+	TimestampAlignmentInterval = "interval" // stamp samples with the scrape-cycle boundary
+)
+
+// maxAlignmentTolerance bounds how early a scrape may start and still count for the next boundary
+const maxAlignmentTolerance = time.Second
+
+// ParseTimestampAlignment parses timestampAlignment of an endpoint, defaulting to none
+func ParseTimestampAlignment(alignment string) (string, error) {
+	alignment = strings.ToLower(strings.TrimSpace(alignment))
+	switch alignment {
+	case "", TimestampAlignmentNone:
+		return TimestampAlignmentNone, nil
+	case TimestampAlignmentInterval:
+		return alignment, nil
+	default:
+		return TimestampAlignmentNone, fmt.Errorf("unsupported timestampAlignment %q (none, interval)", alignment)
+	}
+}
+
+// AlignTimestamp returns the scrape-cycle boundary of a scrape started at startMillis,
+// floor(start / interval) * interval. A scrape started just short of a boundary, within a tenth of
+// the interval and at most a second, belongs to that boundary, so scheduling jitter does not put two
+// cycles on the same timestamp. The drift from the start time is thus within [-1s, interval).
+func AlignTimestamp(startMillis int64, interval time.Duration) int64 {
+	step := interval.Milliseconds()
+	if step <= 0 {
+		return startMillis
+	}
+	tolerance := min(interval/10, maxAlignmentTolerance).Milliseconds()
+	return (startMillis + tolerance) / step * step
+}
@@ -0,0 +1,81 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// UnitConversion scales the values of matching metrics and optionally renames them,
+// e.g. (.+)_milliseconds with multiplier 0.001 and renameSuffix _seconds
+type UnitConversion struct {
+	MetricRegex  string
+	Multiplier   float64
+	RenameSuffix string
+	regex        *regexp.Regexp
+}
+
+// UnitConversions is a slice of UnitConversion, applied in order; the first match wins
+type UnitConversions []*UnitConversion
+
+// Match reports whether the rule applies to the metric and returns the converted metric name.
+// metricRegex must match the whole name. With renameSuffix set, the name becomes the first capture
+// group (or the whole name if there is none) followed by renameSuffix.
+func (uc *UnitConversion) Match(metric string) (string, bool) {
+	m := uc.regex.FindStringSubmatch(metric)
+	if m == nil {
+		return "", false
+	}
+	if uc.RenameSuffix == "" {
+		return metric, true
+	}
+	base := metric
+	if len(m) > 1 {
+		base = m[1]
+	}
+	return base + uc.RenameSuffix, true
+}
+
+// ParseUnitConversions parses unitConversions entries of an endpoint configuration
+func ParseUnitConversions(configs []interface{}) (UnitConversions, error) {
+	result := make(UnitConversions, 0, len(configs))
+	for i, c := range configs {
+		configMap, ok := c.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("unitConversions[%d]: expected a map", i)
+		}
+
+		metricRegex, _ := configMap["metricRegex"].(string)
+		if metricRegex == "" {
+			return nil, fmt.Errorf("unitConversions[%d]: metricRegex is required", i)
+		}
+		regex, err := regexp.Compile("^(?:" + metricRegex + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("unitConversions[%d]: invalid metricRegex %q: %v", i, metricRegex, err)
+		}
+
+		multiplier := 1.0
+		switch v := configMap["multiplier"].(type) {
+		case nil:
+		case float64:
+			multiplier = v
+		case int:
+			multiplier = float64(v)
+		case string:
+			if multiplier, err = strconv.ParseFloat(v, 64); err != nil {
+				return nil, fmt.Errorf("unitConversions[%d]: invalid multiplier %q", i, v)
+			}
+		default:
+			return nil, fmt.Errorf("unitConversions[%d]: invalid multiplier %v", i, v)
+		}
+
+		renameSuffix, _ := configMap["renameSuffix"].(string)
+		result = append(result, &UnitConversion{
+			MetricRegex:  metricRegex,
+			Multiplier:   multiplier,
+			RenameSuffix: renameSuffix,
+			regex:        regex,
+		})
+	}
+	return result, nil
+}
@@ -0,0 +1,117 @@
+package model
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+)
+
+// Value transform operations
+const (
+	ValueTransformClampMin = "clampMin"
+	ValueTransformClampMax = "clampMax"
+	ValueTransformScale    = "scale"
+	ValueTransformAbs      = "abs"
+)
+
+// ValueTransform rewrites the sample values of matching metrics, e.g. clampMin 0 for a gauge a buggy
+// exporter sometimes reports as negative
+type ValueTransform struct {
+	MetricRegex string
+	Op          string
+	Arg         float64
+	regex       *regexp.Regexp
+}
+
+// ValueTransforms is a slice of ValueTransform. Every matching rule is applied, in order.
+type ValueTransforms []*ValueTransform
+
+// Matches reports whether the rule applies to the metric; metricRegex must match the whole name
+func (vt *ValueTransform) Matches(metric string) bool {
+	return vt.regex.MatchString(metric)
+}
+
+// Apply returns the transformed value
+func (vt *ValueTransform) Apply(value float64) float64 {
+	switch vt.Op {
+	case ValueTransformClampMin:
+		if value < vt.Arg {
+			return vt.Arg
+		}
+	case ValueTransformClampMax:
+		if value > vt.Arg {
+			return vt.Arg
+		}
+	case ValueTransformScale:
+		return value * vt.Arg
+	case ValueTransformAbs:
+		return math.Abs(value)
+	}
+	return value
+}
+
+// String describes the rule for logs and the state snapshot, e.g. clampMin(0) ~ node_.*
+func (vt *ValueTransform) String() string {
+	if vt.Op == ValueTransformAbs {
+		return fmt.Sprintf("%s ~ %s", vt.Op, vt.MetricRegex)
+	}
+	return fmt.Sprintf("%s(%s) ~ %s", vt.Op, strconv.FormatFloat(vt.Arg, 'g', -1, 64), vt.MetricRegex)
+}
+
+// ParseValueTransforms parses valueTransforms entries of an endpoint configuration. Unknown ops and
+// missing or non-numeric args are rejected.
+func ParseValueTransforms(configs []interface{}) (ValueTransforms, error) {
+	result := make(ValueTransforms, 0, len(configs))
+	for i, c := range configs {
+		configMap, ok := c.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("valueTransforms[%d]: expected a map", i)
+		}
+
+		metricRegex, _ := configMap["metricRegex"].(string)
+		if metricRegex == "" {
+			return nil, fmt.Errorf("valueTransforms[%d]: metricRegex is required", i)
+		}
+		regex, err := regexp.Compile("^(?:" + metricRegex + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("valueTransforms[%d]: invalid metricRegex %q: %v", i, metricRegex, err)
+		}
+
+		op, _ := configMap["op"].(string)
+		switch op {
+		case ValueTransformClampMin, ValueTransformClampMax, ValueTransformScale, ValueTransformAbs:
+		default:
+			return nil, fmt.Errorf("valueTransforms[%d]: unknown op %q (expected clampMin, clampMax, scale or abs)", i, op)
+		}
+
+		var arg float64
+		switch v := configMap["arg"].(type) {
+		case nil:
+			if op != ValueTransformAbs {
+				return nil, fmt.Errorf("valueTransforms[%d]: arg is required for %s", i, op)
+			}
+		case float64:
+			arg = v
+		case int:
+			arg = float64(v)
+		case string:
+			if arg, err = strconv.ParseFloat(v, 64); err != nil {
+				return nil, fmt.Errorf("valueTransforms[%d]: arg %q is not a number", i, v)
+			}
+		default:
+			return nil, fmt.Errorf("valueTransforms[%d]: arg %v is not a number", i, v)
+		}
+		if math.IsNaN(arg) || math.IsInf(arg, 0) {
+			return nil, fmt.Errorf("valueTransforms[%d]: arg must be a finite number", i)
+		}
+
+		result = append(result, &ValueTransform{
+			MetricRegex: metricRegex,
+			Op:          op,
+			Arg:         arg,
+			regex:       regex,
+		})
+	}
+	return result, nil
+}
@@ -0,0 +1,202 @@
+// Package netiface selects the local IP the agent derives its identity from and connects to the WhaTap
+// servers from. On a host with several interfaces the local IP of a connection depends on routing and
+// can change across restarts, which makes the server see a new agent.
+package netiface
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"open-agent/pkg/config"
+)
+
+// Config pins the local IP, by address or by interface. SourceAddress wins when both are set.
+type Config struct {
+	// BindInterface is the name of the interface whose address is used, e.g. eth1
+	BindInterface string
+	// SourceAddress is the local IP used, which must be an address of an up interface
+	SourceAddress string
+}
+
+// LoadConfig reads the settings from whatap.conf, falling back to environment variables
+func LoadConfig() Config {
+	return Config{
+		BindInterface: strings.TrimSpace(config.Get("openagent_bind_interface")),
+		SourceAddress: strings.TrimSpace(config.Get("openagent_source_address")),
+	}
+}
+
+// Interface is a network interface with its addresses in the order the system lists them
+type Interface struct {
+	Name     string
+	Index    int
+	Up       bool
+	Loopback bool
+	Addrs    []net.IP
+}
+
+// Selection is the chosen local IP
+type Selection struct {
+	IP        string
+	Interface string
+	// Reason tells how the address was chosen, for the startup log
+	Reason string
+	// Pinned is true when the address was configured; outbound connections are then bound to it.
+	// Otherwise it is only the identity IP, and connections use the source address routing picks.
+	Pinned bool
+}
+
+// Select chooses the local IP from interfaces. Without a pin it takes the interface of the default
+// route, then the up, non-loopback interface with the lowest index, so the choice does not depend on
+// which interface a connection happens to use. An error is returned when a pin matches no interface.
+func Select(cfg Config, interfaces []Interface, defaultRoute string) (Selection, error) {
+	if cfg.SourceAddress != "" {
+		ip := net.ParseIP(cfg.SourceAddress)
+		if ip == nil {
+			return Selection{}, fmt.Errorf("openagent_source_address %q is not an IP address", cfg.SourceAddress)
+		}
+		for _, iface := range interfaces {
+			if !iface.Up {
+				continue
+			}
+			for _, addr := range iface.Addrs {
+				if addr.Equal(ip) {
+					return Selection{IP: ip.String(), Interface: iface.Name, Reason: "openagent_source_address", Pinned: true}, nil
+				}
+			}
+		}
+		return Selection{}, fmt.Errorf("openagent_source_address %s is not an address of an up interface", cfg.SourceAddress)
+	}
+
+	if cfg.BindInterface != "" {
+		for _, iface := range interfaces {
+			if iface.Name != cfg.BindInterface {
+				continue
+			}
+			if !iface.Up {
+				return Selection{}, fmt.Errorf("openagent_bind_interface %s is down", cfg.BindInterface)
+			}
+			ip := preferredAddr(iface)
+			if ip == nil {
+				return Selection{}, fmt.Errorf("openagent_bind_interface %s has no global unicast address", cfg.BindInterface)
+			}
+			return Selection{IP: ip.String(), Interface: iface.Name, Reason: "openagent_bind_interface", Pinned: true}, nil
+		}
+		return Selection{}, fmt.Errorf("openagent_bind_interface %s does not exist", cfg.BindInterface)
+	}
+
+	candidates := make([]Interface, 0, len(interfaces))
+	for _, iface := range interfaces {
+		if iface.Up && !iface.Loopback && preferredAddr(iface) != nil {
+			candidates = append(candidates, iface)
+		}
+	}
+	for _, iface := range candidates {
+		if defaultRoute != "" && iface.Name == defaultRoute {
+			return Selection{IP: preferredAddr(iface).String(), Interface: iface.Name, Reason: "default route"}, nil
+		}
+	}
+	if len(candidates) == 0 {
+		return Selection{}, nil
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].Index != candidates[j].Index {
+			return candidates[i].Index < candidates[j].Index
+		}
+		return candidates[i].Name < candidates[j].Name
+	})
+	iface := candidates[0]
+	return Selection{IP: preferredAddr(iface).String(), Interface: iface.Name, Reason: "lowest interface index"}, nil
+}
+
+// preferredAddr returns the first global unicast IPv4 address of the interface, which the system lists
+// as the primary one, otherwise its first global unicast IPv6 address
+func preferredAddr(iface Interface) net.IP {
+	var v6 net.IP
+	for _, addr := range iface.Addrs {
+		if !addr.IsGlobalUnicast() {
+			continue
+		}
+		if v4 := addr.To4(); v4 != nil {
+			return v4
+		}
+		if v6 == nil {
+			v6 = addr
+		}
+	}
+	return v6
+}
+
+// Detect selects the local IP from the interfaces and the IPv4 default route of this host
+func Detect(cfg Config) (Selection, error) {
+	interfaces, err := systemInterfaces()
+	if err != nil {
+		return Selection{}, err
+	}
+	var defaultRoute string
+	if f, err := os.Open("/proc/net/route"); err == nil {
+		defaultRoute = parseDefaultRoute(f)
+		f.Close()
+	}
+	return Select(cfg, interfaces, defaultRoute)
+}
+
+func systemInterfaces() ([]Interface, error) {
+	netInterfaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	interfaces := make([]Interface, 0, len(netInterfaces))
+	for _, ni := range netInterfaces {
+		iface := Interface{
+			Name:     ni.Name,
+			Index:    ni.Index,
+			Up:       ni.Flags&net.FlagUp != 0,
+			Loopback: ni.Flags&net.FlagLoopback != 0,
+		}
+		addrs, err := ni.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok {
+				iface.Addrs = append(iface.Addrs, ipNet.IP)
+			}
+		}
+		interfaces = append(interfaces, iface)
+	}
+	return interfaces, nil
+}
+
+// parseDefaultRoute returns the interface of the up IPv4 default route with the lowest metric from the
+// /proc/net/route table, or "" without one
+func parseDefaultRoute(r io.Reader) string {
+	const rtfUp = 0x1
+	best, bestMetric := "", -1
+	scanner := bufio.NewScanner(r)
+	scanner.Scan() // header
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 8 || fields[1] != "00000000" || fields[7] != "00000000" {
+			continue
+		}
+		flags, err := strconv.ParseUint(fields[3], 16, 32)
+		if err != nil || flags&rtfUp == 0 {
+			continue
+		}
+		metric, err := strconv.Atoi(fields[6])
+		if err != nil {
+			continue
+		}
+		if bestMetric < 0 || metric < bestMetric {
+			best, bestMetric = fields[0], metric
+		}
+	}
+	return best
+}
@@ -0,0 +1,124 @@
+package netiface
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+func ips(addrs ...string) []net.IP {
+	out := make([]net.IP, len(addrs))
+	for i, addr := range addrs {
+		out[i] = net.ParseIP(addr)
+	}
+	return out
+}
+
+// multiHomed is a node with a loopback, a pod-network bridge listed first, the primary NIC and a storage NIC
+func multiHomed() []Interface {
+	return []Interface{
+		{Name: "lo", Index: 1, Up: true, Loopback: true, Addrs: ips("127.0.0.1", "::1")},
+		{Name: "cni0", Index: 2, Up: true, Addrs: ips("10.244.0.1", "fe80::1")},
+		{Name: "eth0", Index: 3, Up: true, Addrs: ips("fe80::2", "2001:db8::10", "192.168.10.5", "192.168.10.99")},
+		{Name: "eth1", Index: 4, Up: true, Addrs: ips("172.16.0.5")},
+		{Name: "eth2", Index: 5, Up: false, Addrs: ips("172.17.0.5")},
+	}
+}
+
+func TestSelect_DefaultRouteInterface(t *testing.T) {
+	got, err := Select(Config{}, multiHomed(), "eth0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The primary IPv4 address wins over the IPv6 and link-local ones listed before it
+	if got.IP != "192.168.10.5" || got.Interface != "eth0" || got.Pinned {
+		t.Errorf("Select = %+v, want unpinned 192.168.10.5 on eth0", got)
+	}
+
+	// The listing order does not matter
+	reversed := multiHomed()
+	for i, j := 0, len(reversed)-1; i < j; i, j = i+1, j-1 {
+		reversed[i], reversed[j] = reversed[j], reversed[i]
+	}
+	if again, _ := Select(Config{}, reversed, "eth0"); again != got {
+		t.Errorf("Select on reordered interfaces = %+v, want %+v", again, got)
+	}
+}
+
+func TestSelect_WithoutDefaultRouteTakesLowestIndex(t *testing.T) {
+	interfaces := multiHomed()
+	interfaces[0], interfaces[3] = interfaces[3], interfaces[0]
+	for _, defaultRoute := range []string{"", "tun0", "eth2"} {
+		got, err := Select(Config{}, interfaces, defaultRoute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.IP != "10.244.0.1" || got.Interface != "cni0" {
+			t.Errorf("default route %q: Select = %+v, want 10.244.0.1 on cni0", defaultRoute, got)
+		}
+	}
+}
+
+func TestSelect_IPv6OnlyAndNoCandidates(t *testing.T) {
+	v6 := []Interface{{Name: "eth0", Index: 2, Up: true, Addrs: ips("fe80::2", "2001:db8::10")}}
+	if got, _ := Select(Config{}, v6, ""); got.IP != "2001:db8::10" {
+		t.Errorf("IPv6-only Select = %+v, want 2001:db8::10", got)
+	}
+
+	loopbackOnly := []Interface{{Name: "lo", Index: 1, Up: true, Loopback: true, Addrs: ips("127.0.0.1")}}
+	got, err := Select(Config{}, loopbackOnly, "")
+	if err != nil || got.IP != "" {
+		t.Errorf("Select = %+v, %v, want an empty selection", got, err)
+	}
+}
+
+func TestSelect_Pinned(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		cfg     Config
+		wantIP  string
+		wantErr string
+	}{
+		{"source address", Config{SourceAddress: "192.168.10.99"}, "192.168.10.99", ""},
+		{"source address wins", Config{SourceAddress: "172.16.0.5", BindInterface: "eth0"}, "172.16.0.5", ""},
+		{"bind interface", Config{BindInterface: "eth1"}, "172.16.0.5", ""},
+		{"unknown address", Config{SourceAddress: "10.0.0.1"}, "", "not an address of an up interface"},
+		{"address of a down interface", Config{SourceAddress: "172.17.0.5"}, "", "not an address of an up interface"},
+		{"invalid address", Config{SourceAddress: "eth0"}, "", "not an IP address"},
+		{"unknown interface", Config{BindInterface: "bond0"}, "", "does not exist"},
+		{"down interface", Config{BindInterface: "eth2"}, "", "is down"},
+		{"interface without address", Config{BindInterface: "lo"}, "", "no global unicast address"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Select(tc.cfg, multiHomed(), "eth0")
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.IP != tc.wantIP || !got.Pinned {
+				t.Errorf("Select = %+v, want pinned %s", got, tc.wantIP)
+			}
+		})
+	}
+}
+
+func TestParseDefaultRoute(t *testing.T) {
+	table := `Iface	Destination	Gateway 	Flags	RefCnt	Use	Metric	Mask		MTU	Window	IRTT
+eth1	00000000	010010AC	0003	0	0	200	00000000	0	0	0
+eth0	00000000	010AA8C0	0003	0	0	100	00000000	0	0	0
+wg0	00000000	00000000	0002	0	0	0	00000000	0	0	0
+eth0	000AA8C0	00000000	0001	0	0	100	00FFFFFF	0	0	0
+`
+	if got := parseDefaultRoute(strings.NewReader(table)); got != "eth0" {
+		t.Errorf("parseDefaultRoute = %q, want eth0", got)
+	}
+	noDefault := "Iface\tDestination\tGateway\tFlags\tRefCnt\tUse\tMetric\tMask\neth0\t000AA8C0\t00000000\t0001\t0\t0\t0\t00FFFFFF\n"
+	if got := parseDefaultRoute(strings.NewReader(noDefault)); got != "" {
+		t.Errorf("parseDefaultRoute = %q, want none", got)
+	}
+}
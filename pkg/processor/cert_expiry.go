@@ -0,0 +1,26 @@
+package processor
+
+import (
+	"open-agent/pkg/model"
+)
+
+// CertExpiryMetric is the scrape meta metric sent with every https scrape: the expiry of the target's
+// leaf certificate as a Unix timestamp, so an alert can fire weeks before a self-signed certificate
+// expires and the scrapes start failing
+const CertExpiryMetric = "openagent_target_cert_expiry_timestamp_seconds"
+
+// appendCertExpiry appends the certificate expiry of an https scrape and its metadata. It is added
+// after relabeling so rules do not drop it; job, instance and the other target labels are appended
+// with the target's own samples.
+func appendCertExpiry(result *model.ConversionResult, rawData *model.ScrapeRawData, timestamp int64) {
+	if rawData.CertNotAfter.IsZero() {
+		return
+	}
+	result.OpenMxList = append(result.OpenMxList,
+		model.NewOpenMx(CertExpiryMetric, timestamp, float64(rawData.CertNotAfter.Unix())))
+
+	help := model.NewOpenMxHelp(CertExpiryMetric)
+	help.Put("help", "Expiry of the target's TLS leaf certificate in seconds since the epoch")
+	help.Put("type", "gauge")
+	result.OpenMxHelpList = append(result.OpenMxHelpList, help)
+}
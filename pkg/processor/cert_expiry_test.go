@@ -0,0 +1,45 @@
+package processor
+
+import (
+	"testing"
+	"time"
+
+	"open-agent/pkg/model"
+)
+
+func TestAppendCertExpiry(t *testing.T) {
+	labels := map[string]string{"job": "etcd", "instance": "10.0.0.1:2379"}
+	notAfter := time.Date(2026, 11, 30, 0, 0, 0, 0, time.UTC)
+	rawData := model.NewScrapeRawData("https://10.0.0.1:2379/metrics", "etcd_up 1\n", nil, labels, 1700000000000)
+	rawData.CertNotAfter = notAfter
+
+	result := model.NewConversionResult([]*model.OpenMx{model.NewOpenMx("etcd_up", 1700000000000, 1)}, nil)
+	appendCertExpiry(result, rawData, 1700000000000)
+	list := result.GetOpenMxList()
+	if len(list) != 2 || list[1].Metric != CertExpiryMetric {
+		t.Fatalf("expected %s after the target's samples, got %d samples", CertExpiryMetric, len(list))
+	}
+	expiry := list[1]
+	if expiry.Value != float64(notAfter.Unix()) || expiry.Timestamp != 1700000000000 {
+		t.Errorf("unexpected sample %+v", expiry)
+	}
+	if helps := result.GetOpenMxHelpList(); len(helps) != 1 || helps[0].Get("type") != "gauge" {
+		t.Errorf("expected gauge metadata, got %+v", helps)
+	}
+
+	// job and instance are appended with the target labels like for the target's own samples
+	appendTargetLabels(expiry, rawData, "")
+	for _, name := range []string{"job", "instance"} {
+		if !hasLabel(expiry, name) {
+			t.Errorf("expected the %s label, got %+v", name, expiry.Labels)
+		}
+	}
+
+	// Plain http scrapes have no certificate
+	plain := model.NewScrapeRawData("http://10.0.0.1:9100/metrics", "up 1\n", nil, labels, 1700000000000)
+	result = model.NewConversionResult(nil, nil)
+	appendCertExpiry(result, plain, 1700000000000)
+	if len(result.GetOpenMxList()) != 0 || len(result.GetOpenMxHelpList()) != 0 {
+		t.Errorf("expected nothing for an http scrape")
+	}
+}
@@ -0,0 +1,223 @@
+package processor
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"open-agent/pkg/config"
+	"open-agent/pkg/model"
+	"open-agent/tools/util/logutil"
+)
+
+// aggLabel is added to every downsampled sample with the aggregation that produced it
+const aggLabel = "agg"
+
+// cumulativeTypes are metric types whose samples must not be averaged
+var cumulativeTypes = map[string]bool{"counter": true, "histogram": true, "summary": true}
+
+// cumulativeSuffixes are sample name suffixes of counter, histogram and summary families
+var cumulativeSuffixes = []string{"", "_total", "_bucket", "_count", "_sum", "_created"}
+
+// seriesWindow buffers the samples of one series for the current window
+type seriesWindow struct {
+	metric      string
+	labels      []model.Label
+	aggregation string
+	windowStart int64
+	count       int
+	sum         float64
+	min         float64
+	max         float64
+}
+
+func (sw *seriesWindow) add(value float64) {
+	if sw.count == 0 {
+		sw.min, sw.max = value, value
+	} else {
+		sw.min = math.Min(sw.min, value)
+		sw.max = math.Max(sw.max, value)
+	}
+	sw.sum += value
+	sw.count++
+}
+
+// result returns the aggregated sample, timestamped at the start of the window
+func (sw *seriesWindow) result() *model.OpenMx {
+	var value float64
+	switch sw.aggregation {
+	case model.DownsampleMax:
+		value = sw.max
+	case model.DownsampleMin:
+		value = sw.min
+	default:
+		value = sw.sum / float64(sw.count)
+	}
+	om := model.NewOpenMx(sw.metric, sw.windowStart, value)
+	om.Labels = append(om.Labels, sw.labels...)
+	om.AddLabel(aggLabel, sw.aggregation)
+	return om
+}
+
+// targetWindows holds the buffered series of one target
+type targetWindows struct {
+	windowMs int64
+	lastSeen int64
+	series   map[string]*seriesWindow
+}
+
+// downsampler aggregates series of targets with a downsample setting.
+// Buffers are kept per target so that series missing from a scrape can be flushed.
+type downsampler struct {
+	mu      sync.Mutex
+	targets map[string]*targetWindows
+}
+
+func newDownsampler() *downsampler {
+	return &downsampler{targets: make(map[string]*targetWindows)}
+}
+
+// apply buffers the samples of one scrape and returns the aggregated samples of every
+// window that has closed, including windows of series that are no longer exposed
+func (d *downsampler) apply(target string, cfg *model.DownsampleConfig, samples []*model.OpenMx, helps []*model.OpenMxHelp) []*model.OpenMx {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	windowMs := cfg.Window.Milliseconds()
+	cumulative := cumulativeMetrics(helps)
+
+	tw, ok := d.targets[target]
+	if !ok {
+		tw = &targetWindows{series: make(map[string]*seriesWindow)}
+		d.targets[target] = tw
+	}
+	tw.windowMs = windowMs
+	buffers := tw.series
+
+	var out []*model.OpenMx
+	seen := make(map[string]bool, len(samples))
+	for _, om := range samples {
+		key := seriesKey(om)
+		seen[key] = true
+		windowStart := om.Timestamp - om.Timestamp%windowMs
+
+		sw, ok := buffers[key]
+		if ok && sw.windowStart != windowStart {
+			out = append(out, sw.result())
+			ok = false
+		}
+		if !ok {
+			aggregation := cfg.Aggregation
+			if aggregation != model.DownsampleMax && cumulative[om.Metric] {
+				// The average or minimum of a counter is meaningless, keep the latest (max) value instead
+				aggregation = model.DownsampleMax
+			}
+			sw = &seriesWindow{
+				metric:      om.Metric,
+				labels:      append([]model.Label(nil), om.Labels...),
+				aggregation: aggregation,
+				windowStart: windowStart,
+			}
+			buffers[key] = sw
+		}
+		sw.add(om.Value)
+		if om.Timestamp > tw.lastSeen {
+			tw.lastSeen = om.Timestamp
+		}
+	}
+
+	// Series that disappeared from the target are flushed with what they have
+	for key, sw := range buffers {
+		if !seen[key] {
+			out = append(out, sw.result())
+			delete(buffers, key)
+		}
+	}
+	if len(buffers) == 0 {
+		delete(d.targets, target)
+	}
+
+	if config.IsDebugEnabled() {
+		logutil.Debugf("PROCESSOR", "Downsample %s for %s: %d samples buffered, %d aggregated samples emitted",
+			cfg, target, len(samples), len(out))
+	}
+	return out
+}
+
+// drop flushes the buffered series of a target that no longer uses downsampling
+func (d *downsampler) drop(target string) []*model.OpenMx {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	tw, ok := d.targets[target]
+	if !ok {
+		return nil
+	}
+	delete(d.targets, target)
+	return windowResults(tw.series)
+}
+
+// expire flushes targets that have not been scraped for two windows, e.g. removed targets
+func (d *downsampler) expire(now int64) map[string][]*model.OpenMx {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var expired map[string][]*model.OpenMx
+	for target, tw := range d.targets {
+		if now-tw.lastSeen <= 2*tw.windowMs {
+			continue
+		}
+		if expired == nil {
+			expired = make(map[string][]*model.OpenMx)
+		}
+		expired[target] = windowResults(tw.series)
+		delete(d.targets, target)
+	}
+	return expired
+}
+
+// flush returns the buffered windows of all targets and clears the buffers
+func (d *downsampler) flush() map[string][]*model.OpenMx {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	flushed := make(map[string][]*model.OpenMx, len(d.targets))
+	for target, tw := range d.targets {
+		flushed[target] = windowResults(tw.series)
+	}
+	d.targets = make(map[string]*targetWindows)
+	return flushed
+}
+
+func windowResults(buffers map[string]*seriesWindow) []*model.OpenMx {
+	out := make([]*model.OpenMx, 0, len(buffers))
+	for _, sw := range buffers {
+		out = append(out, sw.result())
+	}
+	return out
+}
+
+// cumulativeMetrics returns the sample names belonging to counter, histogram and summary families
+func cumulativeMetrics(helps []*model.OpenMxHelp) map[string]bool {
+	names := make(map[string]bool)
+	for _, help := range helps {
+		if !cumulativeTypes[help.Get("type")] {
+			continue
+		}
+		for _, suffix := range cumulativeSuffixes {
+			names[help.Metric+suffix] = true
+		}
+	}
+	return names
+}
+
+// seriesKey identifies a series by metric name and sorted labels
+func seriesKey(om *model.OpenMx) string {
+	labels := make([]string, 0, len(om.Labels))
+	for _, l := range om.Labels {
+		labels = append(labels, l.Key+"="+l.Value)
+	}
+	sort.Strings(labels)
+	return om.Metric + "{" + strings.Join(labels, ",") + "}"
+}
@@ -0,0 +1,257 @@
+package processor
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/klauspost/compress/snappy"
+	"open-agent/pkg/config"
+	"open-agent/pkg/model"
+	"open-agent/tools/util/logutil"
+)
+
+const (
+	// downsampleSnapshotVersion is bumped when the checkpointed windows change shape
+	downsampleSnapshotVersion = 1
+	// downsampleStateFile holds the buffered downsample windows
+	downsampleStateFile = "downsample.snappy"
+
+	defaultCheckpointInterval = time.Minute
+	defaultCheckpointMaxAge   = 5 * time.Minute
+	defaultCheckpointMaxBytes = 32 * 1024 * 1024
+)
+
+// errStaleSnapshot is returned for a snapshot older than the configured max age
+var errStaleSnapshot = errors.New("stale snapshot")
+
+// downsampleSnapshot is the downsample window checkpoint. The windows are the only
+// per-series state the processor keeps between scrapes
+type downsampleSnapshot struct {
+	Version int
+	// SavedAt is the wall clock time of the checkpoint in unix milliseconds
+	SavedAt    int64
+	Downsample map[string]savedTargetWindows
+}
+
+// savedTargetWindows is the checkpointed form of targetWindows
+type savedTargetWindows struct {
+	WindowMs int64
+	LastSeen int64
+	Series   map[string]savedSeriesWindow
+}
+
+// savedSeriesWindow is the checkpointed form of seriesWindow
+type savedSeriesWindow struct {
+	Metric      string
+	Labels      []model.Label
+	Aggregation string
+	WindowStart int64
+	Count       int
+	Sum         float64
+	Min         float64
+	Max         float64
+}
+
+// downsampleCheckpointer periodically saves the downsample windows so that a restarted worker
+// continues the buffered windows instead of starting over
+type downsampleCheckpointer struct {
+	path     string
+	interval time.Duration
+	maxAge   time.Duration
+	maxBytes int
+	// saving is set while a checkpoint is written, a tick that finds it set is skipped
+	saving atomic.Bool
+	stopCh chan struct{}
+	doneCh chan struct{}
+}
+
+// stateDir returns the directory the downsample windows are checkpointed to
+func stateDir() string {
+	homeDir := os.Getenv("WHATAP_OPEN_HOME")
+	if homeDir == "" {
+		homeDir = "."
+	}
+	return filepath.Join(homeDir, "state")
+}
+
+// newDownsampleCheckpointer returns the checkpointer configured in whatap.conf, or nil when
+// openagent_downsample_checkpoint_enabled is off
+func newDownsampleCheckpointer() *downsampleCheckpointer {
+	if !config.GetBoolWithDefault("openagent_downsample_checkpoint_enabled", false) {
+		return nil
+	}
+	c := &downsampleCheckpointer{
+		path:     filepath.Join(stateDir(), downsampleStateFile),
+		interval: defaultCheckpointInterval,
+		maxAge:   defaultCheckpointMaxAge,
+		maxBytes: defaultCheckpointMaxBytes,
+		stopCh:   make(chan struct{}),
+		doneCh:   make(chan struct{}),
+	}
+	if seconds := config.GetIntWithDefault("openagent_downsample_checkpoint_interval_seconds", 0); seconds > 0 {
+		c.interval = time.Duration(seconds) * time.Second
+	}
+	if seconds := config.GetIntWithDefault("openagent_downsample_checkpoint_max_age_seconds", 0); seconds > 0 {
+		c.maxAge = time.Duration(seconds) * time.Second
+	}
+	if maxBytes := config.GetIntWithDefault("openagent_downsample_checkpoint_max_bytes", 0); maxBytes > 0 {
+		c.maxBytes = maxBytes
+	}
+	return c
+}
+
+// run saves the windows returned by snapshot every interval until stop is called.
+// snapshot copies the windows under its lock; encoding and writing happen here, off the processing path.
+func (c *downsampleCheckpointer) run(snapshot func() *downsampleSnapshot) {
+	defer close(c.doneCh)
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stopCh:
+			return
+		case <-ticker.C:
+			if err := c.save(snapshot()); err != nil {
+				logutil.Printf("WARN", "[PROCESSOR] Failed to checkpoint downsample windows to %s: %v", c.path, err)
+			}
+		}
+	}
+}
+
+// stop ends the periodic checkpoints and waits for a checkpoint in progress
+func (c *downsampleCheckpointer) stop() {
+	close(c.stopCh)
+	<-c.doneCh
+}
+
+// save writes the snapshot to the checkpoint file. The file is replaced with a rename so
+// that a worker killed during the write leaves the previous checkpoint intact.
+func (c *downsampleCheckpointer) save(snapshot *downsampleSnapshot) error {
+	if !c.saving.CompareAndSwap(false, true) {
+		return nil
+	}
+	defer c.saving.Store(false)
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(snapshot); err != nil {
+		return err
+	}
+	if buf.Len() > c.maxBytes {
+		return fmt.Errorf("checkpoint of %d bytes exceeds openagent_downsample_checkpoint_max_bytes (%d)", buf.Len(), c.maxBytes)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return err
+	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, snappy.Encode(nil, buf.Bytes()), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
+
+// load reads the checkpoint file. It returns nil without an error when there is none, and
+// errStaleSnapshot when it is older than maxAge.
+func (c *downsampleCheckpointer) load(now time.Time) (*downsampleSnapshot, error) {
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	size, err := snappy.DecodedLen(data)
+	if err != nil {
+		return nil, err
+	}
+	if size > c.maxBytes {
+		return nil, fmt.Errorf("checkpoint of %d bytes exceeds openagent_downsample_checkpoint_max_bytes (%d)", size, c.maxBytes)
+	}
+	decoded, err := snappy.Decode(nil, data)
+	if err != nil {
+		return nil, err
+	}
+	var snapshot downsampleSnapshot
+	if err := gob.NewDecoder(bytes.NewReader(decoded)).Decode(&snapshot); err != nil {
+		return nil, err
+	}
+	if snapshot.Version != downsampleSnapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %d", snapshot.Version)
+	}
+	if age := now.Sub(time.UnixMilli(snapshot.SavedAt)); age > c.maxAge || age < 0 {
+		return nil, fmt.Errorf("%w: saved %v ago, max age %v", errStaleSnapshot, age.Truncate(time.Second), c.maxAge)
+	}
+	return &snapshot, nil
+}
+
+// restore loads the checkpoint file into the downsampler. A stale or corrupt checkpoint is
+// removed so that it is not retried on the next start.
+func (c *downsampleCheckpointer) restore(d *downsampler, now time.Time) {
+	snapshot, err := c.load(now)
+	if err != nil {
+		if errors.Is(err, errStaleSnapshot) {
+			logutil.Printf("INFO", "[PROCESSOR] Discarding downsample checkpoint %s: %v", c.path, err)
+		} else {
+			logutil.Printf("WARN", "[PROCESSOR] Discarding corrupt downsample checkpoint %s: %v", c.path, err)
+		}
+		_ = os.Remove(c.path)
+		return
+	}
+	if snapshot == nil {
+		return
+	}
+	series := d.restore(snapshot.Downsample)
+	logutil.Printf("INFO", "[PROCESSOR] Restored %d downsample series of %d targets from %s",
+		series, len(snapshot.Downsample), c.path)
+}
+
+// snapshot copies the buffered windows of all targets
+func (d *downsampler) snapshot() map[string]savedTargetWindows {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	saved := make(map[string]savedTargetWindows, len(d.targets))
+	for target, tw := range d.targets {
+		series := make(map[string]savedSeriesWindow, len(tw.series))
+		for key, sw := range tw.series {
+			// labels are not modified after the window is created, sharing them is safe
+			series[key] = savedSeriesWindow{
+				Metric: sw.metric, Labels: sw.labels, Aggregation: sw.aggregation, WindowStart: sw.windowStart,
+				Count: sw.count, Sum: sw.sum, Min: sw.min, Max: sw.max,
+			}
+		}
+		saved[target] = savedTargetWindows{WindowMs: tw.windowMs, LastSeen: tw.lastSeen, Series: series}
+	}
+	return saved
+}
+
+// restore adds checkpointed windows of targets that have no buffered windows yet and
+// returns the number of series restored. Windows that closed while the worker was down
+// are emitted by the next scrape or expired with their target.
+func (d *downsampler) restore(saved map[string]savedTargetWindows) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	restored := 0
+	for target, st := range saved {
+		if _, ok := d.targets[target]; ok || len(st.Series) == 0 || st.WindowMs <= 0 {
+			continue
+		}
+		tw := &targetWindows{windowMs: st.WindowMs, lastSeen: st.LastSeen, series: make(map[string]*seriesWindow, len(st.Series))}
+		for key, sw := range st.Series {
+			tw.series[key] = &seriesWindow{
+				metric: sw.Metric, labels: sw.Labels, aggregation: sw.Aggregation, windowStart: sw.WindowStart,
+				count: sw.Count, sum: sw.Sum, min: sw.Min, max: sw.Max,
+			}
+		}
+		d.targets[target] = tw
+		restored += len(tw.series)
+	}
+	return restored
+}
@@ -0,0 +1,131 @@
+package processor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"open-agent/pkg/model"
+)
+
+func newTestCheckpointer(t *testing.T) *downsampleCheckpointer {
+	t.Helper()
+	return &downsampleCheckpointer{
+		path:     filepath.Join(t.TempDir(), "state", downsampleStateFile),
+		interval: time.Minute,
+		maxAge:   5 * time.Minute,
+		maxBytes: defaultCheckpointMaxBytes,
+		stopCh:   make(chan struct{}),
+		doneCh:   make(chan struct{}),
+	}
+}
+
+func snapshotOf(d *downsampler, savedAt time.Time) *downsampleSnapshot {
+	return &downsampleSnapshot{Version: downsampleSnapshotVersion, SavedAt: savedAt.UnixMilli(), Downsample: d.snapshot()}
+}
+
+func TestDownsampleCheckpoint_RoundTripContinuesWindow(t *testing.T) {
+	c := newTestCheckpointer(t)
+	cfg := mustDownsample(t, "1m:avg")
+	window := time.Minute.Milliseconds()
+	base := 100 * window
+
+	before := newDownsampler()
+	before.apply(testTarget, cfg, []*model.OpenMx{gpuSample(base, "0", 10), gpuSample(base, "1", 40)}, nil)
+	now := time.Now()
+	if err := c.save(snapshotOf(before, now)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The restarted worker continues the window instead of starting it over
+	after := newDownsampler()
+	c.restore(after, now.Add(time.Minute))
+	after.apply(testTarget, cfg, []*model.OpenMx{gpuSample(base+30000, "0", 30), gpuSample(base+30000, "1", 40)}, nil)
+	out := after.apply(testTarget, cfg, []*model.OpenMx{gpuSample(base+window, "0", 0), gpuSample(base+window, "1", 0)}, nil)
+	if len(out) != 2 {
+		t.Fatalf("expected 2 aggregated samples, got %d", len(out))
+	}
+	want := map[string]float64{"0": 20, "1": 40}
+	for _, om := range out {
+		gpu := labelValue(om, "gpu")
+		if om.Value != want[gpu] || om.Timestamp != base || labelValue(om, "agg") != "avg" {
+			t.Errorf("gpu %s: got %v at %d, want avg %v at %d", gpu, om.Value, om.Timestamp, want[gpu], base)
+		}
+	}
+	if _, err := os.Stat(c.path); err != nil {
+		t.Errorf("expected the checkpoint to be kept after a restore: %v", err)
+	}
+}
+
+func TestDownsampleCheckpoint_RejectsStaleSnapshot(t *testing.T) {
+	c := newTestCheckpointer(t)
+	d := newDownsampler()
+	d.apply(testTarget, mustDownsample(t, "1m:max"), []*model.OpenMx{gpuSample(6000000, "0", 10)}, nil)
+	savedAt := time.Now()
+	if err := c.save(snapshotOf(d, savedAt)); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := newDownsampler()
+	c.restore(restored, savedAt.Add(c.maxAge+time.Second))
+	if len(restored.targets) != 0 {
+		t.Errorf("expected a stale snapshot not to be restored, got %d targets", len(restored.targets))
+	}
+	if _, err := os.Stat(c.path); !os.IsNotExist(err) {
+		t.Errorf("expected the stale snapshot to be removed, got %v", err)
+	}
+}
+
+func TestDownsampleCheckpoint_DiscardsCorruptSnapshot(t *testing.T) {
+	c := newTestCheckpointer(t)
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(c.path, []byte("not a snapshot"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := newDownsampler()
+	c.restore(restored, time.Now())
+	if len(restored.targets) != 0 {
+		t.Errorf("expected nothing restored from a corrupt snapshot")
+	}
+	if _, err := os.Stat(c.path); !os.IsNotExist(err) {
+		t.Errorf("expected the corrupt snapshot to be removed, got %v", err)
+	}
+
+	// A missing checkpoint is a normal first start
+	if snapshot, err := c.load(time.Now()); snapshot != nil || err != nil {
+		t.Errorf("expected no snapshot and no error, got %v, %v", snapshot, err)
+	}
+}
+
+func TestDownsampleCheckpoint_CapsSnapshotSize(t *testing.T) {
+	c := newTestCheckpointer(t)
+	c.maxBytes = 64
+	d := newDownsampler()
+	d.apply(testTarget, mustDownsample(t, "1m:avg"), []*model.OpenMx{gpuSample(6000000, "0", 10), gpuSample(6000000, "1", 20)}, nil)
+	if err := c.save(snapshotOf(d, time.Now())); err == nil {
+		t.Fatalf("expected an error for a snapshot over %d bytes", c.maxBytes)
+	}
+	if _, err := os.Stat(c.path); !os.IsNotExist(err) {
+		t.Errorf("expected no checkpoint file, got %v", err)
+	}
+}
+
+func TestProcessorStop_CheckpointsInsteadOfFlushing(t *testing.T) {
+	processedQueue := make(chan *model.ConversionResult, 10)
+	p := NewProcessor(make(chan *model.ScrapeRawData), processedQueue)
+	p.checkpoint = newTestCheckpointer(t)
+	p.downsampler.apply(testTarget, mustDownsample(t, "1m:avg"), []*model.OpenMx{gpuSample(6000000, "0", 10)}, nil)
+
+	p.Stop()
+	if len(processedQueue) != 0 {
+		t.Errorf("expected the windows to be checkpointed, not flushed, got %d results", len(processedQueue))
+	}
+	snapshot, err := p.checkpoint.load(time.Now())
+	if err != nil || snapshot == nil || len(snapshot.Downsample[testTarget].Series) != 1 {
+		t.Errorf("expected the window in the checkpoint, got %+v (err %v)", snapshot, err)
+	}
+}
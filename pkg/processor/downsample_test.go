@@ -0,0 +1,174 @@
+package processor
+
+import (
+	"testing"
+	"time"
+
+	"open-agent/pkg/model"
+)
+
+const testTarget = "http://10.0.0.1:9400/metrics"
+
+func gpuSample(ts int64, gpu string, value float64) *model.OpenMx {
+	om := model.NewOpenMx("DCGM_FI_DEV_GPU_UTIL", ts, value)
+	om.AddLabel("gpu", gpu)
+	return om
+}
+
+func labelValue(om *model.OpenMx, key string) string {
+	for _, l := range om.Labels {
+		if l.Key == key {
+			return l.Value
+		}
+	}
+	return ""
+}
+
+func mustDownsample(t *testing.T, value string) *model.DownsampleConfig {
+	t.Helper()
+	cfg, err := model.ParseDownsampleConfig(value)
+	if err != nil {
+		t.Fatalf("parse %q: %v", value, err)
+	}
+	return cfg
+}
+
+func TestDownsample_GaugeAcrossWindowBoundary(t *testing.T) {
+	d := newDownsampler()
+	cfg := mustDownsample(t, "1m:avg")
+	window := time.Minute.Milliseconds()
+	base := 100 * window
+
+	// Two scrapes inside the first window emit nothing
+	for i, v := range []float64{10, 30} {
+		if out := d.apply(testTarget, cfg, []*model.OpenMx{gpuSample(base+int64(i)*30000, "0", v)}, nil); len(out) != 0 {
+			t.Fatalf("expected no output inside the window, got %d samples", len(out))
+		}
+	}
+
+	// The first scrape of the next window closes the previous one
+	out := d.apply(testTarget, cfg, []*model.OpenMx{gpuSample(base+window, "0", 50)}, nil)
+	if len(out) != 1 {
+		t.Fatalf("expected 1 aggregated sample, got %d", len(out))
+	}
+	if out[0].Value != 20 || out[0].Timestamp != base {
+		t.Errorf("expected avg 20 at %d, got %v at %d", base, out[0].Value, out[0].Timestamp)
+	}
+	if labelValue(out[0], "agg") != "avg" || labelValue(out[0], "gpu") != "0" {
+		t.Errorf("unexpected labels %v", out[0].Labels)
+	}
+
+	// Shutdown flushes the partially filled window
+	flushed := d.flush()[testTarget]
+	if len(flushed) != 1 || flushed[0].Value != 50 || flushed[0].Timestamp != base+window {
+		t.Fatalf("expected flushed sample 50 at %d, got %+v", base+window, flushed)
+	}
+}
+
+func TestDownsample_MaxAndCounter(t *testing.T) {
+	d := newDownsampler()
+	cfg := mustDownsample(t, "1m:avg")
+	window := time.Minute.Milliseconds()
+
+	help := model.NewOpenMxHelp("DCGM_FI_PROF_PCIE_TX_BYTES")
+	help.Put("type", "counter")
+	helps := []*model.OpenMxHelp{help}
+
+	for i, v := range []float64{100, 400} {
+		d.apply(testTarget, cfg, []*model.OpenMx{model.NewOpenMx("DCGM_FI_PROF_PCIE_TX_BYTES", int64(i)*1000, v)}, helps)
+	}
+	out := d.apply(testTarget, cfg, []*model.OpenMx{model.NewOpenMx("DCGM_FI_PROF_PCIE_TX_BYTES", window, 500)}, helps)
+	if len(out) != 1 || out[0].Value != 400 || labelValue(out[0], "agg") != "max" {
+		t.Fatalf("expected counter to be aggregated with max=400, got %+v", out)
+	}
+
+	d = newDownsampler()
+	cfg = mustDownsample(t, "1m:min")
+	for i, v := range []float64{100, 400} {
+		d.apply(testTarget, cfg, []*model.OpenMx{model.NewOpenMx("DCGM_FI_PROF_PCIE_TX_BYTES", int64(i)*1000, v)}, helps)
+	}
+	out = d.apply(testTarget, cfg, []*model.OpenMx{model.NewOpenMx("DCGM_FI_PROF_PCIE_TX_BYTES", window, 500)}, helps)
+	if len(out) != 1 || out[0].Value != 400 || labelValue(out[0], "agg") != "max" {
+		t.Fatalf("expected min on a counter to be aggregated with max=400, got %+v", out)
+	}
+
+	d = newDownsampler()
+	cfg = mustDownsample(t, "1m:max")
+	d.apply(testTarget, cfg, []*model.OpenMx{gpuSample(0, "0", 70)}, nil)
+	d.apply(testTarget, cfg, []*model.OpenMx{gpuSample(1000, "0", 90)}, nil)
+	out = d.apply(testTarget, cfg, []*model.OpenMx{gpuSample(window, "0", 10)}, nil)
+	if len(out) != 1 || out[0].Value != 90 {
+		t.Fatalf("expected max 90, got %+v", out)
+	}
+}
+
+func TestDownsample_DisappearedSeriesIsFlushed(t *testing.T) {
+	d := newDownsampler()
+	cfg := mustDownsample(t, "5m:avg")
+
+	d.apply(testTarget, cfg, []*model.OpenMx{gpuSample(0, "0", 10), gpuSample(0, "1", 20)}, nil)
+
+	// MIG instance 1 is gone in the next scrape
+	out := d.apply(testTarget, cfg, []*model.OpenMx{gpuSample(30000, "0", 30)}, nil)
+	if len(out) != 1 || labelValue(out[0], "gpu") != "1" || out[0].Value != 20 {
+		t.Fatalf("expected the disappeared series to be flushed, got %+v", out)
+	}
+
+	// A target that is no longer scraped is flushed after two windows
+	if expired := d.expire(30000 + time.Minute.Milliseconds()); len(expired) != 0 {
+		t.Fatalf("expected nothing to expire yet, got %d targets", len(expired))
+	}
+	expired := d.expire(30000 + 11*time.Minute.Milliseconds())
+	if len(expired[testTarget]) != 1 || expired[testTarget][0].Value != 20 {
+		t.Fatalf("expected target to expire with its buffered window, got %+v", expired)
+	}
+}
+
+func TestProcessorStop_FlushesToQueue(t *testing.T) {
+	processedQueue := make(chan *model.ConversionResult, 1)
+	p := NewProcessor(make(chan *model.ScrapeRawData), processedQueue)
+	p.downsampler.apply(testTarget, mustDownsample(t, "5m:avg"), []*model.OpenMx{gpuSample(0, "0", 10)}, nil)
+
+	p.Stop()
+	select {
+	case result := <-processedQueue:
+		if result.GetTarget() != testTarget || len(result.GetOpenMxList()) != 1 {
+			t.Fatalf("unexpected flushed result %+v", result)
+		}
+	default:
+		t.Fatal("expected buffered window to be flushed on stop")
+	}
+}
+
+func TestParseDownsampleConfig(t *testing.T) {
+	for _, invalid := range []string{"5m", "avg:5m", "0s:avg", "5m:sum"} {
+		if _, err := model.ParseDownsampleConfig(invalid); err == nil {
+			t.Errorf("expected %q to be rejected", invalid)
+		}
+	}
+	cfg := mustDownsample(t, "5m:MAX")
+	if cfg.Window != 5*time.Minute || cfg.Aggregation != model.DownsampleMax {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+}
+
+func TestProcessorEmitExpired_DropsWhenQueueFull(t *testing.T) {
+	processedQueue := make(chan *model.ConversionResult, 1)
+	processedQueue <- model.NewConversionResult(nil, nil)
+	p := NewProcessor(make(chan *model.ScrapeRawData), processedQueue)
+	p.downsampler.apply(testTarget, mustDownsample(t, "5m:avg"), []*model.OpenMx{gpuSample(0, "0", 10), gpuSample(0, "1", 20)}, nil)
+
+	done := make(chan struct{})
+	go func() {
+		p.emitExpired(time.Hour.Milliseconds())
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("emitExpired blocked on a full processed queue")
+	}
+	if dropped := p.downsampleDropped.Load(); dropped != 2 {
+		t.Errorf("expected 2 dropped samples, got %d", dropped)
+	}
+}
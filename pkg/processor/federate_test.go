@@ -0,0 +1,70 @@
+package processor
+
+import (
+	"testing"
+
+	"open-agent/pkg/converter"
+	"open-agent/pkg/model"
+)
+
+const federateBody = `federated_series{job="kafka",instance="broker-0:9308"} 1 1700000000000
+`
+
+// labelValues returns the values of every label named key on the series
+func labelValues(om *model.OpenMx, key string) []string {
+	var values []string
+	for _, label := range om.Labels {
+		if label.Key == key {
+			values = append(values, label.Value)
+		}
+	}
+	return values
+}
+
+func federatedSeries(t *testing.T, rawData *model.ScrapeRawData) *model.OpenMx {
+	t.Helper()
+	result, err := converter.ConvertWithOptions(rawData.RawData, "", rawData.CollectionTime, converter.ConvertOptions{})
+	if err != nil || len(result.GetOpenMxList()) != 1 {
+		t.Fatalf("convert: %v", err)
+	}
+	if rawData.IgnoreTimestamps {
+		stampScrapeTime(result.GetOpenMxList(), rawData.CollectionTime)
+	}
+	om := result.GetOpenMxList()[0]
+	appendTargetLabels(om, rawData, "")
+	return om
+}
+
+func TestFederate_HonorLabelsKeepsFederatedJobAndInstance(t *testing.T) {
+	labels := map[string]string{"job": "prometheus", "instance": "prometheus:9090", "namespace": "monitoring"}
+	rawData := model.NewScrapeRawData("http://prometheus:9090/federate", federateBody, nil, labels, 1700000060000)
+	rawData.HonorLabels = true
+
+	om := federatedSeries(t, rawData)
+	if job := labelValues(om, "job"); len(job) != 1 || job[0] != "kafka" {
+		t.Errorf("job = %v, want only the federated kafka", job)
+	}
+	if instance := labelValues(om, "instance"); len(instance) != 1 || instance[0] != "broker-0:9308" {
+		t.Errorf("instance = %v, want only the federated broker-0:9308", instance)
+	}
+	if namespace := labelValues(om, "namespace"); len(namespace) != 1 || namespace[0] != "monitoring" {
+		t.Errorf("namespace = %v, want the target's monitoring", namespace)
+	}
+	if om.Timestamp != 1700000000000 {
+		t.Errorf("timestamp = %d, want the federated 1700000000000", om.Timestamp)
+	}
+}
+
+func TestFederate_IgnoreTimestampsStampsScrapeTime(t *testing.T) {
+	rawData := model.NewScrapeRawData("http://prometheus:9090/federate", federateBody, nil, map[string]string{"job": "prometheus"}, 1700000060000)
+	rawData.IgnoreTimestamps = true
+
+	om := federatedSeries(t, rawData)
+	if om.Timestamp != 1700000060000 {
+		t.Errorf("timestamp = %d, want the scrape time 1700000060000", om.Timestamp)
+	}
+	// Without honorLabels the target's job is added as before
+	if job := labelValues(om, "job"); len(job) != 2 {
+		t.Errorf("job = %v, want the federated and the target's", job)
+	}
+}
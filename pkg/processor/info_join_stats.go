@@ -0,0 +1,52 @@
+package processor
+
+import (
+	"open-agent/pkg/converter"
+	"open-agent/pkg/model"
+	"open-agent/tools/util/logutil"
+)
+
+// MetricInfoJoinConflicts is the self-metric counting the info labels infoJoin did not copy because
+// the series already had a label with that name
+const MetricInfoJoinConflicts = "openagent_info_join_conflicts_total"
+
+// infoJoinState is the infoJoin conflict count of one target
+type infoJoinState struct {
+	last  int   // conflicts of the last scrape, logged when it changes
+	total int64 // cumulative conflicts
+}
+
+// recordInfoJoin adds one scrape's conflicts to the target's counter and logs the conflict count when
+// it changes instead of on every scrape
+func (p *Processor) recordInfoJoin(target string, join converter.InfoJoinResult) {
+	state, ok := p.infoJoinConflicts[target]
+	if !ok {
+		state = &infoJoinState{}
+		p.infoJoinConflicts[target] = state
+	}
+	state.total += int64(join.Conflicts)
+	if join.Conflicts != state.last {
+		state.last = join.Conflicts
+		if join.Conflicts > 0 {
+			logutil.Printf("WARN", "[PROCESSOR] infoJoin for target %s skipped %d labels the series already had",
+				target, join.Conflicts)
+		}
+	}
+}
+
+// appendInfoJoinConflicts appends the target's cumulative infoJoin conflict counter. It is added after
+// relabeling so rules do not drop it; the target labels are appended with the target's own samples.
+func (p *Processor) appendInfoJoinConflicts(result *model.ConversionResult, rawData *model.ScrapeRawData, timestamp int64) {
+	state, ok := p.infoJoinConflicts[rawData.TargetURL]
+	if len(rawData.InfoJoins) == 0 || !ok {
+		return
+	}
+	conflicts := model.NewOpenMx(MetricInfoJoinConflicts, timestamp, float64(state.total))
+	conflicts.AddLabel("target", rawData.TargetURL)
+	result.OpenMxList = append(result.OpenMxList, conflicts)
+
+	help := model.NewOpenMxHelp(MetricInfoJoinConflicts)
+	help.Put("help", "Info labels infoJoin did not copy because the series already had a label with that name")
+	help.Put("type", "counter")
+	result.OpenMxHelpList = append(result.OpenMxHelpList, help)
+}
@@ -0,0 +1,53 @@
+package processor
+
+import (
+	"testing"
+
+	"open-agent/pkg/converter"
+	"open-agent/pkg/model"
+)
+
+const infoJoinBody = `# TYPE kube_pod_info gauge
+kube_pod_info{pod="api-0",node="worker-1"} 1
+# TYPE container_restarts counter
+container_restarts{pod="api-0",node="worker-2"} 3
+`
+
+func infoJoinConflictCounter(t *testing.T, result *model.ConversionResult) float64 {
+	t.Helper()
+	for _, om := range result.GetOpenMxList() {
+		if om.Metric == MetricInfoJoinConflicts {
+			return om.Value
+		}
+	}
+	t.Fatalf("expected %s in the result", MetricInfoJoinConflicts)
+	return 0
+}
+
+func TestInfoJoinConflicts_CountedAsSelfMetric(t *testing.T) {
+	p := NewProcessor(nil, nil)
+	rawData := &model.ScrapeRawData{
+		TargetURL: "http://10.0.0.1:8080/metrics",
+		InfoJoins: model.InfoJoins{{Metric: "kube_pod_info", Labels: []string{"node"}, JoinOn: []string{"pod"}}},
+	}
+
+	for scrape := 1; scrape <= 2; scrape++ {
+		result, err := converter.ConvertWithOptions(infoJoinBody, "", 1700000000000, converter.ConvertOptions{})
+		if err != nil {
+			t.Fatalf("convert: %v", err)
+		}
+		p.recordInfoJoin(rawData.TargetURL, converter.ApplyInfoJoins(result, rawData.InfoJoins))
+		p.appendInfoJoinConflicts(result, rawData, 1700000000000)
+
+		// The series' own node label wins over kube_pod_info's
+		if got := infoJoinConflictCounter(t, result); got != float64(scrape) {
+			t.Errorf("scrape %d: %s = %v, want %d", scrape, MetricInfoJoinConflicts, got, scrape)
+		}
+	}
+
+	result := model.NewConversionResult(nil, nil)
+	p.appendInfoJoinConflicts(result, &model.ScrapeRawData{TargetURL: rawData.TargetURL}, 1700000000000)
+	if len(result.GetOpenMxList()) != 0 {
+		t.Errorf("expected no counter for an endpoint without infoJoin, got %v", result.GetOpenMxList())
+	}
+}
@@ -0,0 +1,70 @@
+package processor
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"open-agent/pkg/ingest"
+	"open-agent/pkg/model"
+	"open-agent/pkg/scraper"
+)
+
+// TestIngestReachesSender posts to the ingest endpoint of a pipeline-only scraper manager and reads the
+// processed queue as a mock sender would
+func TestIngestReachesSender(t *testing.T) {
+	rawQueue := make(chan *model.ScrapeRawData, 10)
+	processedQueue := make(chan *model.ConversionResult, 10)
+
+	sm := scraper.NewScraperManager(nil, nil, rawQueue, "test")
+	sm.StartScraping()
+	t.Cleanup(sm.Stop)
+	NewProcessor(rawQueue, processedQueue, WithPcode(func() int64 { return 42 })).Start()
+
+	rules := func(string) model.RelabelConfigs {
+		return model.RelabelConfigs{{SourceLabels: []string{"__name__"}, Regex: "debug_.*", Action: "drop"}}
+	}
+	handler, err := ingest.NewHandler(ingest.Config{Token: "s3cret"}, sm.AddRawData, rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	body := "# HELP queue_depth Jobs waiting\n# TYPE queue_depth gauge\nqueue_depth{queue=\"mail\"} 7\ndebug_allocs 3\n"
+	req, _ := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer s3cret")
+	req.Header.Set(ingest.TargetHeader, "batch")
+	req.Header.Set(ingest.LabelsHeader, "env=prod")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202", resp.StatusCode)
+	}
+
+	var result *model.ConversionResult
+	select {
+	case result = <-processedQueue:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no processed result")
+	}
+	var series []*model.OpenMx
+	for _, om := range result.GetOpenMxList() {
+		if om.Metric != MetricRelabelDroppedSamples && om.Metric != MetricRelabelKeptSamples {
+			series = append(series, om)
+		}
+	}
+	if len(series) != 1 || series[0].Metric != "queue_depth" || series[0].Value != 7 {
+		t.Fatalf("series = %+v, want queue_depth 7 with debug_allocs relabeled away", series)
+	}
+	for k, v := range map[string]string{"queue": "mail", "job": "batch", "env": "prod", "pcode": "42"} {
+		if got := labelValue(series[0], k); got != v {
+			t.Errorf("label %s = %q, want %q", k, got, v)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package processor
+
+import (
+	"sort"
+	"sync"
+
+	"open-agent/pkg/converter"
+	"open-agent/pkg/model"
+	"open-agent/tools/util/logutil"
+)
+
+// LabelLengthCount is the cumulative label value length limit result of one target
+type LabelLengthCount struct {
+	Target    string
+	Limit     string // the limit as "<mode>:<limit>"
+	Truncated int64  // label values shortened
+	Dropped   int64  // samples dropped in strict mode
+}
+
+var (
+	labelLengthMu     sync.Mutex
+	labelLengthCounts = make(map[string]*LabelLengthCount)
+)
+
+// recordLabelLengthLimit adds one scrape's result to the per-target counter and logs
+// the first truncated or dropped samples of a target
+func recordLabelLengthLimit(target string, limit *model.LabelLengthLimit, result converter.LabelLengthResult) {
+	labelLengthMu.Lock()
+	defer labelLengthMu.Unlock()
+
+	count, ok := labelLengthCounts[target]
+	if !ok {
+		count = &LabelLengthCount{Target: target}
+		labelLengthCounts[target] = count
+	}
+	if count.Truncated == 0 && count.Dropped == 0 && (result.Truncated > 0 || result.Dropped > 0) {
+		logutil.Printf("WARN", "[PROCESSOR] Label values of target %s exceed labelValueLengthLimit %d: %d truncated, %d samples dropped",
+			target, limit.Limit, result.Truncated, result.Dropped)
+	}
+	count.Limit = limit.String()
+	count.Truncated += int64(result.Truncated)
+	count.Dropped += int64(result.Dropped)
+}
+
+// pruneLabelLengthCounts drops the counters of targets keep does not report
+func pruneLabelLengthCounts(keep func(target string) bool) {
+	labelLengthMu.Lock()
+	defer labelLengthMu.Unlock()
+
+	for target := range labelLengthCounts {
+		if !keep(target) {
+			delete(labelLengthCounts, target)
+		}
+	}
+}
+
+// LabelLengthCounts returns the label value length limit counters of all targets sorted by target
+func LabelLengthCounts() []LabelLengthCount {
+	labelLengthMu.Lock()
+	defer labelLengthMu.Unlock()
+
+	counts := make([]LabelLengthCount, 0, len(labelLengthCounts))
+	for _, count := range labelLengthCounts {
+		counts = append(counts, *count)
+	}
+	sort.Slice(counts, func(i, j int) bool { return counts[i].Target < counts[j].Target })
+	return counts
+}
@@ -0,0 +1,39 @@
+package processor
+
+import (
+	"testing"
+
+	"open-agent/pkg/model"
+)
+
+// processedHelp runs one scrape through the processor and returns the metadata passed on to the sender
+func processedHelp(t *testing.T) []*model.OpenMxHelp {
+	t.Helper()
+	processedQueue := make(chan *model.ConversionResult, 4)
+	p := NewProcessor(nil, processedQueue, WithPcode(func() int64 { return 1 }))
+	p.processRawData(model.NewScrapeRawData("http://10.0.0.1:8080/metrics",
+		"# HELP http_requests_total Requests.\n# TYPE http_requests_total counter\nhttp_requests_total 3\n", nil, nil, 1700000000000))
+
+	result := <-processedQueue
+	if len(result.GetOpenMxList()) == 0 {
+		t.Fatalf("expected the samples to be passed on")
+	}
+	return result.GetOpenMxHelpList()
+}
+
+func TestProcessRawData_MetricMetadata(t *testing.T) {
+	if help := processedHelp(t); len(help) == 0 {
+		t.Fatalf("expected metadata by default")
+	}
+
+	t.Setenv("openagent_send_metric_metadata", "false")
+	if help := processedHelp(t); len(help) != 0 {
+		t.Fatalf("expected no metadata when disabled, got %d items", len(help))
+	}
+
+	// The OTLP exporter still needs the metric types
+	t.Setenv("openagent_otlp_enabled", "true")
+	if help := processedHelp(t); len(help) == 0 {
+		t.Fatalf("expected metadata to be kept while OTLP is enabled")
+	}
+}
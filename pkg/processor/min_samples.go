@@ -0,0 +1,66 @@
+package processor
+
+import (
+	"fmt"
+	"time"
+
+	"open-agent/pkg/model"
+	"open-agent/tools/util/logutil"
+)
+
+// ScrapeDegradedMetric is sent with every scrape of an endpoint with minSamples: 1 when the exporter
+// answered successfully but exposed fewer samples than expected, 0 otherwise, so an alert can catch
+// an exporter that silently degrades while its scrapes keep succeeding
+const ScrapeDegradedMetric = "openagent_scrape_degraded"
+
+// degradedLogInterval is how often a target that stays degraded is logged again
+const degradedLogInterval = 10 * time.Minute
+
+// degradedScrapeState is the degraded streak of one target
+type degradedScrapeState struct {
+	lastLogged time.Time // when a degraded scrape was last logged
+	suppressed int       // degraded scrapes not logged since lastLogged
+}
+
+// appendScrapeDegraded appends the degraded flag of a scrape that exposed the given number of samples
+// and logs degraded scrapes once per degradedLogInterval per target. It is added after relabeling so
+// rules do not drop it; job, instance and the other target labels are appended with the target's own samples.
+func (p *Processor) appendScrapeDegraded(result *model.ConversionResult, rawData *model.ScrapeRawData, exposed int, timestamp int64, now time.Time) {
+	if rawData.MinSamples <= 0 {
+		return
+	}
+	degraded := exposed < rawData.MinSamples
+
+	value := 0.0
+	if degraded {
+		value = 1
+		p.logDegradedScrape(rawData, exposed, now)
+	} else {
+		delete(p.degradedScrapes, rawData.TargetURL)
+	}
+	result.OpenMxList = append(result.OpenMxList, model.NewOpenMx(ScrapeDegradedMetric, timestamp, value))
+
+	help := model.NewOpenMxHelp(ScrapeDegradedMetric)
+	help.Put("help", "1 if the scrape succeeded with fewer samples than the endpoint's minSamples")
+	help.Put("type", "gauge")
+	result.OpenMxHelpList = append(result.OpenMxHelpList, help)
+}
+
+func (p *Processor) logDegradedScrape(rawData *model.ScrapeRawData, exposed int, now time.Time) {
+	message := fmt.Sprintf("[PROCESSOR] Target %s exposed %d samples, fewer than minSamples %d",
+		rawData.TargetURL, exposed, rawData.MinSamples)
+
+	state, ok := p.degradedScrapes[rawData.TargetURL]
+	switch {
+	case !ok:
+		p.degradedScrapes[rawData.TargetURL] = &degradedScrapeState{lastLogged: now}
+	case now.Sub(state.lastLogged) >= degradedLogInterval:
+		message = fmt.Sprintf("%s (degraded %d times in %v)", message, state.suppressed+1, now.Sub(state.lastLogged).Round(time.Second))
+		state.lastLogged = now
+		state.suppressed = 0
+	default:
+		state.suppressed++
+		return
+	}
+	logutil.Printf("WARN", "%s", message)
+}
@@ -0,0 +1,87 @@
+package processor
+
+import (
+	"testing"
+	"time"
+
+	"open-agent/pkg/model"
+)
+
+func degradedSample(t *testing.T, result *model.ConversionResult) *model.OpenMx {
+	t.Helper()
+	var found *model.OpenMx
+	for _, om := range result.GetOpenMxList() {
+		if om.Metric == ScrapeDegradedMetric {
+			if found != nil {
+				t.Fatalf("expected one %s sample", ScrapeDegradedMetric)
+			}
+			found = om
+		}
+	}
+	return found
+}
+
+func TestAppendScrapeDegraded_Threshold(t *testing.T) {
+	p := NewProcessor(nil, nil)
+	labels := map[string]string{"job": "node", "instance": "10.0.0.1:9100"}
+	rawData := model.NewScrapeRawData("http://10.0.0.1:9100/metrics", "", nil, labels, 1700000000000)
+	rawData.MinSamples = 100
+	now := time.Now()
+
+	for exposed, want := range map[int]float64{0: 1, 99: 1, 100: 0, 101: 0} {
+		result := model.NewConversionResult(nil, nil)
+		p.appendScrapeDegraded(result, rawData, exposed, 1700000000000, now)
+		degraded := degradedSample(t, result)
+		if degraded == nil {
+			t.Fatalf("%d samples: expected %s", exposed, ScrapeDegradedMetric)
+		}
+		if degraded.Value != want || degraded.Timestamp != 1700000000000 {
+			t.Errorf("%d samples: got %v at %d, want %v", exposed, degraded.Value, degraded.Timestamp, want)
+		}
+		if helps := result.GetOpenMxHelpList(); len(helps) != 1 || helps[0].Get("type") != "gauge" {
+			t.Errorf("%d samples: expected gauge metadata, got %+v", exposed, helps)
+		}
+
+		// job and instance are appended with the target labels like for the target's own samples
+		appendTargetLabels(degraded, rawData, "")
+		if labelValue(degraded, "job") != "node" || labelValue(degraded, "instance") != "10.0.0.1:9100" {
+			t.Errorf("%d samples: expected job and instance, got %+v", exposed, degraded.Labels)
+		}
+	}
+}
+
+func TestAppendScrapeDegraded_DisabledWithoutThreshold(t *testing.T) {
+	p := NewProcessor(nil, nil)
+	rawData := model.NewScrapeRawData("http://10.0.0.1:9100/metrics", "", nil, nil, 1700000000000)
+	result := model.NewConversionResult(nil, nil)
+	p.appendScrapeDegraded(result, rawData, 0, 1700000000000, time.Now())
+	if len(result.GetOpenMxList()) != 0 || len(result.GetOpenMxHelpList()) != 0 {
+		t.Errorf("expected nothing without minSamples")
+	}
+}
+
+func TestAppendScrapeDegraded_RateLimitsLog(t *testing.T) {
+	p := NewProcessor(nil, nil)
+	rawData := model.NewScrapeRawData("http://10.0.0.1:9100/metrics", "", nil, nil, 1700000000000)
+	rawData.MinSamples = 10
+	now := time.Now()
+
+	for i := 0; i < 5; i++ {
+		p.appendScrapeDegraded(model.NewConversionResult(nil, nil), rawData, 1, 1700000000000, now.Add(time.Duration(i)*time.Minute))
+	}
+	state := p.degradedScrapes[rawData.TargetURL]
+	if state == nil || !state.lastLogged.Equal(now) || state.suppressed != 4 {
+		t.Fatalf("expected the first degraded scrape logged and 4 suppressed, got %+v", state)
+	}
+
+	p.appendScrapeDegraded(model.NewConversionResult(nil, nil), rawData, 1, 1700000000000, now.Add(degradedLogInterval))
+	if !state.lastLogged.Equal(now.Add(degradedLogInterval)) || state.suppressed != 0 {
+		t.Errorf("expected a log after %v, got %+v", degradedLogInterval, state)
+	}
+
+	// A healthy scrape ends the streak, the next degraded scrape is logged right away
+	p.appendScrapeDegraded(model.NewConversionResult(nil, nil), rawData, 10, 1700000000000, now.Add(degradedLogInterval+time.Minute))
+	if _, ok := p.degradedScrapes[rawData.TargetURL]; ok {
+		t.Errorf("expected the streak to end with a healthy scrape")
+	}
+}
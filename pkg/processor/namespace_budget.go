@@ -0,0 +1,78 @@
+package processor
+
+import (
+	"sync"
+	"time"
+
+	"open-agent/pkg/model"
+	"open-agent/tools/util/logutil"
+)
+
+// NamespaceOverBudgetSamplesMetric counts the samples of a namespace dropped because it exceeded its
+// maxSamplesPerMinute budget; it is sent after every scrape that was truncated
+const NamespaceOverBudgetSamplesMetric = "openagent_namespace_over_budget_samples_total"
+
+// namespaceSampleWindow is the samples a namespace sent in the current minute
+type namespaceSampleWindow struct {
+	minute  int64
+	samples int
+	// over is set while the namespace is over its budget, to log only when that changes
+	over    bool
+	dropped int64 // samples dropped since the agent started
+}
+
+var (
+	namespaceBudgetMu      sync.Mutex
+	namespaceSampleWindows = make(map[string]*namespaceSampleWindow)
+)
+
+// applyNamespaceSampleBudget truncates the samples of a scrape to what is left of its namespace's budget
+// for the minute of timestamp, and returns the kept samples and the namespace's dropped samples total.
+// Going over the budget and back under it are logged once.
+func applyNamespaceSampleBudget(samples []*model.OpenMx, budget *model.NamespaceSampleBudget, timestamp int64) ([]*model.OpenMx, int64) {
+	namespaceBudgetMu.Lock()
+	defer namespaceBudgetMu.Unlock()
+
+	window, ok := namespaceSampleWindows[budget.Namespace]
+	if !ok {
+		window = &namespaceSampleWindow{}
+		namespaceSampleWindows[budget.Namespace] = window
+	}
+	minute := timestamp / time.Minute.Milliseconds()
+	if minute != window.minute {
+		if window.over && window.minute != 0 && window.samples <= budget.MaxSamplesPerMinute {
+			window.over = false
+			logutil.Printf("INFO", "[PROCESSOR] Namespace %s is within its budget of %d samples per minute again",
+				budget.Namespace, budget.MaxSamplesPerMinute)
+		}
+		window.minute = minute
+		window.samples = 0
+	}
+
+	remaining := max(budget.MaxSamplesPerMinute-window.samples, 0)
+	window.samples += len(samples)
+	if len(samples) <= remaining {
+		return samples, window.dropped
+	}
+
+	window.dropped += int64(len(samples) - remaining)
+	if !window.over {
+		window.over = true
+		logutil.Printf("WARN", "[PROCESSOR] Namespace %s is over its budget of %d samples per minute, truncating its scrapes",
+			budget.Namespace, budget.MaxSamplesPerMinute)
+	}
+	return samples[:remaining], window.dropped
+}
+
+// newNamespaceBudgetResult returns the dropped samples counter of a namespace
+func newNamespaceBudgetResult(namespace string, dropped int64, timestamp int64) *model.ConversionResult {
+	om := model.NewOpenMx(NamespaceOverBudgetSamplesMetric, timestamp, float64(dropped))
+	om.AddLabel("namespace", namespace)
+	help := model.NewOpenMxHelp(NamespaceOverBudgetSamplesMetric)
+	help.Put("help", "Samples of the namespace dropped because it exceeded its maxSamplesPerMinute budget")
+	help.Put("type", "counter")
+
+	result := model.NewConversionResult([]*model.OpenMx{om}, []*model.OpenMxHelp{help})
+	result.SetCollectionTime(timestamp)
+	return result
+}
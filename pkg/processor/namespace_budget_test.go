@@ -0,0 +1,55 @@
+package processor
+
+import (
+	"testing"
+	"time"
+
+	"open-agent/pkg/model"
+)
+
+func budgetSamples(n int) []*model.OpenMx {
+	samples := make([]*model.OpenMx, n)
+	for i := range samples {
+		samples[i] = model.NewOpenMx("requests_total", 0, float64(i))
+	}
+	return samples
+}
+
+func TestApplyNamespaceSampleBudget_TruncatesWithinMinute(t *testing.T) {
+	budget := &model.NamespaceSampleBudget{Namespace: "budget-test-truncate", MaxSamplesPerMinute: 100}
+	minute := time.Minute.Milliseconds()
+	start := 1000 * minute
+
+	// Two scrapes fit, the third is truncated to what is left and the fourth is dropped
+	for i, want := range []struct{ kept, dropped int }{{40, 0}, {40, 0}, {20, 20}, {0, 60}} {
+		kept, dropped := applyNamespaceSampleBudget(budgetSamples(40), budget, start+int64(i)*1000)
+		if len(kept) != want.kept || dropped != int64(want.dropped) {
+			t.Errorf("scrape %d: kept %d with %d dropped, want %d with %d", i, len(kept), dropped, want.kept, want.dropped)
+		}
+	}
+
+	// The next minute starts a new budget, the dropped counter keeps counting
+	kept, dropped := applyNamespaceSampleBudget(budgetSamples(120), budget, start+minute)
+	if len(kept) != 100 || dropped != 80 {
+		t.Errorf("expected 100 kept and 80 dropped in total, got %d and %d", len(kept), dropped)
+	}
+
+	result := newNamespaceBudgetResult(budget.Namespace, dropped, start+minute)
+	om := result.GetOpenMxList()[0]
+	if om.Metric != NamespaceOverBudgetSamplesMetric || om.Value != 80 || labelValue(om, "namespace") != budget.Namespace {
+		t.Errorf("unexpected counter %+v", om)
+	}
+}
+
+func TestApplyNamespaceSampleBudget_NamespacesAreIndependent(t *testing.T) {
+	heavy := &model.NamespaceSampleBudget{Namespace: "budget-test-heavy", MaxSamplesPerMinute: 10}
+	light := &model.NamespaceSampleBudget{Namespace: "budget-test-light", MaxSamplesPerMinute: 10}
+	timestamp := 2000 * time.Minute.Milliseconds()
+
+	if kept, _ := applyNamespaceSampleBudget(budgetSamples(50), heavy, timestamp); len(kept) != 10 {
+		t.Errorf("expected the heavy namespace truncated to 10, got %d", len(kept))
+	}
+	if kept, dropped := applyNamespaceSampleBudget(budgetSamples(10), light, timestamp); len(kept) != 10 || dropped != 0 {
+		t.Errorf("expected the light namespace unaffected, got %d kept and %d dropped", len(kept), dropped)
+	}
+}
@@ -0,0 +1,73 @@
+package processor
+
+import (
+	"testing"
+
+	"open-agent/pkg/model"
+)
+
+// nodeLabels returns the node and agent_node values of a series, and how many node labels it has
+func nodeLabels(om *model.OpenMx) (node, agentNode string, nodes int) {
+	for _, label := range om.Labels {
+		switch label.Key {
+		case "node":
+			node = label.Value
+			nodes++
+		case AgentNodeLabel:
+			agentNode = label.Value
+		}
+	}
+	return node, agentNode, nodes
+}
+
+func nodeLabelRawData(preserve bool) *model.ScrapeRawData {
+	// Discovery puts the pod's node into the target labels as well as NodeName
+	labels := map[string]string{"job": "node-exporter", "instance": "10.0.1.5:9100", "node": "worker-01"}
+	rawData := model.NewScrapeRawDataWithNodeName("http://10.0.1.5:9100/metrics", "", nil, labels, "worker-01", true, 0)
+	rawData.PreserveAgentNodeLabel = preserve
+	return rawData
+}
+
+func TestAppendTargetLabels_AddsNodeWhenExporterHasNone(t *testing.T) {
+	om := model.NewOpenMx("node_load1", 0, 1)
+	if !appendTargetLabels(om, nodeLabelRawData(false), "") {
+		t.Errorf("node label should be reported as added")
+	}
+	if node, agentNode, nodes := nodeLabels(om); node != "worker-01" || nodes != 1 || agentNode != "" {
+		t.Errorf("expected a single node=worker-01, got %+v", om.Labels)
+	}
+}
+
+func TestAppendTargetLabels_ExporterNodeWins(t *testing.T) {
+	om := model.NewOpenMx("kube_node_info", 0, 1)
+	om.AddLabel("node", "worker-07")
+	if appendTargetLabels(om, nodeLabelRawData(false), "") {
+		t.Errorf("node label should not be reported as added")
+	}
+	if node, agentNode, nodes := nodeLabels(om); node != "worker-07" || nodes != 1 || agentNode != "" {
+		t.Errorf("expected only the exporter's node=worker-07, got %+v", om.Labels)
+	}
+}
+
+func TestAppendTargetLabels_PreservesAgentNode(t *testing.T) {
+	om := model.NewOpenMx("kube_node_info", 0, 1)
+	om.AddLabel("node", "worker-07")
+	if !appendTargetLabels(om, nodeLabelRawData(true), "") {
+		t.Errorf("agent_node label should be reported as added")
+	}
+	if node, agentNode, nodes := nodeLabels(om); node != "worker-07" || nodes != 1 || agentNode != "worker-01" {
+		t.Errorf("expected node=worker-07 and agent_node=worker-01, got %+v", om.Labels)
+	}
+}
+
+func TestAppendTargetLabels_WithoutAddNodeLabel(t *testing.T) {
+	// A node target label set by relabelConfigs is a plain target label without addNodeLabel
+	rawData := model.NewScrapeRawData("http://10.0.1.5:9100/metrics", "", nil, map[string]string{"node": "worker-01"}, 0)
+	om := model.NewOpenMx("node_load1", 0, 1)
+	if appendTargetLabels(om, rawData, "12345") {
+		t.Errorf("node label should not be reported as added")
+	}
+	if node, _, nodes := nodeLabels(om); node != "worker-01" || nodes != 1 {
+		t.Errorf("expected the target's node label, got %+v", om.Labels)
+	}
+}
@@ -0,0 +1,119 @@
+package processor
+
+import (
+	"math"
+	"sort"
+	"sync"
+
+	"open-agent/pkg/model"
+	"open-agent/tools/util/logutil"
+)
+
+// NonFiniteCount is the cumulative nonFiniteValues result of one target
+type NonFiniteCount struct {
+	Target  string
+	Policy  string
+	Dropped int64 // NaN/Inf samples dropped
+	Zeroed  int64 // NaN/Inf values replaced with 0
+	Passed  int64 // NaN/Inf values sent as exposed
+}
+
+// nonFiniteResult counts one scrape's NaN/Inf samples by the action taken
+type nonFiniteResult struct {
+	Dropped, Zeroed, Passed int
+}
+
+var (
+	nonFiniteMu     sync.Mutex
+	nonFiniteCounts = make(map[string]*NonFiniteCount)
+)
+
+// applyNonFiniteValues handles the exposed NaN and ±Inf values depending on policy (drop when empty):
+// they are dropped, replaced with 0, or held for passthrough. Metric relabeling marks dropped samples
+// with NaN, so it runs before relabeling and held values are set to 0 until restoreNonFiniteValues.
+func applyNonFiniteValues(list []*model.OpenMx, policy string) ([]*model.OpenMx, nonFiniteResult, map[*model.OpenMx]float64) {
+	var result nonFiniteResult
+	var held map[*model.OpenMx]float64
+	kept := list[:0]
+	for _, openMx := range list {
+		if !math.IsNaN(openMx.Value) && !math.IsInf(openMx.Value, 0) {
+			kept = append(kept, openMx)
+			continue
+		}
+		switch policy {
+		case model.NonFiniteZero:
+			openMx.Value = 0
+			result.Zeroed++
+		case model.NonFinitePassthrough:
+			if held == nil {
+				held = make(map[*model.OpenMx]float64)
+			}
+			held[openMx] = openMx.Value
+			openMx.Value = 0
+			result.Passed++
+		default:
+			result.Dropped++
+			continue
+		}
+		kept = append(kept, openMx)
+	}
+	return kept, result, held
+}
+
+// restoreNonFiniteValues puts back the NaN and ±Inf values held by applyNonFiniteValues
+func restoreNonFiniteValues(held map[*model.OpenMx]float64) {
+	for openMx, value := range held {
+		openMx.Value = value
+	}
+}
+
+// recordNonFinite adds one scrape's result to the per-target counter and logs the first
+// NaN/Inf samples of a target
+func recordNonFinite(target, policy string, result nonFiniteResult) {
+	if result == (nonFiniteResult{}) {
+		return
+	}
+	if policy == "" {
+		policy = model.NonFiniteDrop
+	}
+
+	nonFiniteMu.Lock()
+	defer nonFiniteMu.Unlock()
+
+	count, ok := nonFiniteCounts[target]
+	if !ok {
+		count = &NonFiniteCount{Target: target}
+		nonFiniteCounts[target] = count
+		logutil.Printf("INFO", "[PROCESSOR] Target %s exposes NaN/Inf values, nonFiniteValues=%s: %d dropped, %d zeroed, %d passed",
+			target, policy, result.Dropped, result.Zeroed, result.Passed)
+	}
+	count.Policy = policy
+	count.Dropped += int64(result.Dropped)
+	count.Zeroed += int64(result.Zeroed)
+	count.Passed += int64(result.Passed)
+}
+
+// pruneNonFiniteCounts drops the counters of targets keep does not report
+func pruneNonFiniteCounts(keep func(target string) bool) {
+	nonFiniteMu.Lock()
+	defer nonFiniteMu.Unlock()
+
+	for target := range nonFiniteCounts {
+		if !keep(target) {
+			delete(nonFiniteCounts, target)
+		}
+	}
+}
+
+// NonFiniteCounts returns the NaN/Inf value counters of all targets sorted by target
+func NonFiniteCounts() []NonFiniteCount {
+	nonFiniteMu.Lock()
+	defer nonFiniteMu.Unlock()
+
+	counts := make([]NonFiniteCount, 0, len(nonFiniteCounts))
+	for _, count := range nonFiniteCounts {
+		counts = append(counts, *count)
+	}
+	sort.Slice(counts, func(i, j int) bool { return counts[i].Target < counts[j].Target })
+	return counts
+}
@@ -0,0 +1,115 @@
+package processor
+
+import (
+	"math"
+	"testing"
+
+	"open-agent/pkg/converter"
+	"open-agent/pkg/model"
+)
+
+const nonFiniteBody = `# TYPE temperature gauge
+temperature{sensor="ok"} 21.5
+temperature{sensor="nan"} NaN
+temperature{sensor="pinf"} +Inf
+temperature{sensor="ninf"} -Inf
+temperature{sensor="dropped"} NaN
+`
+
+// processNonFinite converts, relabels and filters one scrape the way processRawData does and
+// returns the values that would be packed by sensor
+func processNonFinite(t *testing.T, target, policy string) map[string]float64 {
+	t.Helper()
+	result, err := converter.ConvertWithOptions(nonFiniteBody, "", 1700000000000, converter.ConvertOptions{})
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+
+	list, nonFinite, held := applyNonFiniteValues(result.GetOpenMxList(), policy)
+	recordNonFinite(target, policy, nonFinite)
+	converter.ApplyRelabelConfigs(list, model.RelabelConfigs{
+		{SourceLabels: []string{"sensor"}, Separator: ";", Regex: "dropped", Action: "drop"},
+	})
+
+	values := make(map[string]float64)
+	var kept []*model.OpenMx
+	for _, om := range list {
+		if !math.IsNaN(om.Value) {
+			kept = append(kept, om)
+		}
+	}
+	restoreNonFiniteValues(held)
+	for _, om := range kept {
+		for _, label := range om.Labels {
+			if label.Key == "sensor" {
+				values[label.Value] = om.Value
+			}
+		}
+	}
+	return values
+}
+
+func nonFiniteCount(target string) NonFiniteCount {
+	for _, count := range NonFiniteCounts() {
+		if count.Target == target {
+			return count
+		}
+	}
+	return NonFiniteCount{}
+}
+
+func TestNonFiniteValues_Drop(t *testing.T) {
+	for _, policy := range []string{"", model.NonFiniteDrop} {
+		target := "http://drop" + policy + ":9100/metrics"
+		values := processNonFinite(t, target, policy)
+		if len(values) != 1 || values["ok"] != 21.5 {
+			t.Errorf("policy %q: expected only the finite sample, got %v", policy, values)
+		}
+		if count := nonFiniteCount(target); count.Dropped != 4 || count.Zeroed != 0 || count.Passed != 0 || count.Policy != model.NonFiniteDrop {
+			t.Errorf("policy %q: unexpected counters %+v", policy, count)
+		}
+	}
+}
+
+func TestNonFiniteValues_Zero(t *testing.T) {
+	target := "http://zero:9100/metrics"
+	values := processNonFinite(t, target, model.NonFiniteZero)
+	want := map[string]float64{"ok": 21.5, "nan": 0, "pinf": 0, "ninf": 0}
+	if len(values) != len(want) {
+		t.Fatalf("expected %v, got %v", want, values)
+	}
+	for sensor, value := range want {
+		if got, ok := values[sensor]; !ok || got != value {
+			t.Errorf("sensor %s: got %v, want %v", sensor, got, value)
+		}
+	}
+	// The relabel-dropped NaN is counted too, the policy runs before metricRelabelConfigs
+	if count := nonFiniteCount(target); count.Zeroed != 4 || count.Dropped != 0 {
+		t.Errorf("unexpected counters %+v", count)
+	}
+}
+
+func TestNonFiniteValues_Passthrough(t *testing.T) {
+	target := "http://passthrough:9100/metrics"
+	values := processNonFinite(t, target, model.NonFinitePassthrough)
+	if len(values) != 4 {
+		t.Fatalf("expected the relabel-dropped sample to stay dropped, got %v", values)
+	}
+	if values["ok"] != 21.5 || !math.IsNaN(values["nan"]) || !math.IsInf(values["pinf"], 1) || !math.IsInf(values["ninf"], -1) {
+		t.Errorf("expected the values as exposed, got %v", values)
+	}
+	if count := nonFiniteCount(target); count.Passed != 4 || count.Dropped != 0 {
+		t.Errorf("unexpected counters %+v", count)
+	}
+}
+
+func TestParseNonFiniteValues(t *testing.T) {
+	for in, want := range map[string]string{"": "drop", "Zero": "zero", " passthrough ": "passthrough"} {
+		if got, err := model.ParseNonFiniteValues(in); err != nil || got != want {
+			t.Errorf("ParseNonFiniteValues(%q) = %q, %v", in, got, err)
+		}
+	}
+	if got, err := model.ParseNonFiniteValues("keep"); err == nil || got != model.NonFiniteDrop {
+		t.Errorf("expected an error and the drop default, got %q, %v", got, err)
+	}
+}
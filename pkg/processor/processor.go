@@ -5,10 +5,14 @@ import (
 	"open-agent/tools/util/logutil"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/whatap/gointernal/net/secure"
 	"open-agent/pkg/config"
 	"open-agent/pkg/converter"
+	"open-agent/pkg/diagnostics"
 	"open-agent/pkg/model"
 )
 
@@ -19,27 +23,153 @@ import (
 type Processor struct {
 	rawQueue       chan *model.ScrapeRawData
 	processedQueue chan *model.ConversionResult
+	downsampler    *downsampler
+	// checkpoint saves the downsample windows across worker restarts, nil when disabled
+	checkpoint     *downsampleCheckpointer
+	checkpointOnce sync.Once
+	// prefixCollisions is the last logged metricPrefix collision set per target
+	prefixCollisions map[string]string
+	// infoJoinConflicts are the infoJoin conflict counters per target
+	infoJoinConflicts map[string]*infoJoinState
+	// relabelCounts are the cumulative metric relabeling counters per target
+	relabelCounts map[string]*relabelCount
+	// degradedScrapes are the targets whose last scrape exposed fewer samples than minSamples
+	degradedScrapes map[string]*degradedScrapeState
+	// sampleLimitExceeded are the targets whose last scrape was dropped for exceeding sampleLimit
+	sampleLimitExceeded map[string]bool
+	// targetURLs is the URL each target was last scraped at; liveTargets, set with WithLiveTargets,
+	// tells which of them are still scraped when the per-target stats are pruned
+	targetURLs     map[string]string
+	liveTargets    func(targetID string) bool
+	lastStatsPrune time.Time
+	// interner shares the metric names and label strings repeated across series and scrapes
+	interner *converter.LabelInterner
+	// sampleHooks enrich the samples of every scrape, registered with WithSampleHooks
+	sampleHooks []SampleHook
+	hookPanics  sampleHookPanics
+	// pcode returns the project code added to every sample, the SecurityMaster's outside tests
+	pcode func() int64
+
+	// samplesProcessed counts the samples kept after relabeling, reported in the agent status pack
+	samplesProcessed atomic.Int64
+	// conversionFailures counts the scrapes whose response could not be parsed
+	conversionFailures atomic.Int64
+	// downsampleDropped counts the downsampled samples dropped because the processed queue was full
+	downsampleDropped atomic.Int64
 }
 
 // NewProcessor creates a new Processor instance
-func NewProcessor(rawQueue chan *model.ScrapeRawData, processedQueue chan *model.ConversionResult) *Processor {
-	return &Processor{
-		rawQueue:       rawQueue,
-		processedQueue: processedQueue,
+func NewProcessor(rawQueue chan *model.ScrapeRawData, processedQueue chan *model.ConversionResult, opts ...Option) *Processor {
+	p := &Processor{
+		rawQueue:            rawQueue,
+		processedQueue:      processedQueue,
+		downsampler:         newDownsampler(),
+		prefixCollisions:    make(map[string]string),
+		infoJoinConflicts:   make(map[string]*infoJoinState),
+		relabelCounts:       make(map[string]*relabelCount),
+		degradedScrapes:     make(map[string]*degradedScrapeState),
+		sampleLimitExceeded: make(map[string]bool),
+		targetURLs:          make(map[string]string),
+		interner:            converter.NewLabelInterner(0, 0),
+		checkpoint:          newDownsampleCheckpointer(),
+		pcode:               func() int64 { return secure.GetSecurityMaster().PCODE },
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	if p.checkpoint != nil {
+		p.checkpoint.restore(p.downsampler, time.Now())
 	}
+	return p
+}
+
+// ProcessTotals returns the samples processed and the scrapes that failed to convert since the processor was created
+func (p *Processor) ProcessTotals() (samples, failures int64) {
+	return p.samplesProcessed.Load(), p.conversionFailures.Load()
 }
 
 func (p *Processor) Start() {
-	go p.processLoop()
+	if p.checkpoint != nil {
+		// Start is called again when the process loop recovers from a panic
+		p.checkpointOnce.Do(func() {
+			go p.checkpoint.run(p.checkpointSnapshot)
+		})
+	}
+	go diagnostics.Profile(diagnostics.ComponentProcessor, "", p.processLoop)
+}
+
+// checkpointSnapshot copies the downsample windows to checkpoint
+func (p *Processor) checkpointSnapshot() *downsampleSnapshot {
+	return &downsampleSnapshot{
+		Version:    downsampleSnapshotVersion,
+		SavedAt:    time.Now().UnixMilli(),
+		Downsample: p.downsampler.snapshot(),
+	}
+}
+
+// Stop flushes the partially filled downsample windows to the processed queue.
+// With the downsample checkpoint enabled the windows are checkpointed instead, to be continued by the next worker.
+func (p *Processor) Stop() {
+	if p.checkpoint != nil {
+		p.checkpointOnce.Do(func() { close(p.checkpoint.doneCh) })
+		p.checkpoint.stop()
+		if err := p.checkpoint.save(p.checkpointSnapshot()); err != nil {
+			logutil.Printf("WARN", "[PROCESSOR] Failed to checkpoint downsample windows to %s, flushing downsample windows: %v", p.checkpoint.path, err)
+		} else {
+			return
+		}
+	}
+	for target, samples := range p.downsampler.flush() {
+		p.sendDownsampled(target, samples, "on shutdown")
+	}
+}
+
+// emitExpired sends the buffered windows of targets that stopped being scraped
+func (p *Processor) emitExpired(now int64) {
+	for target, samples := range p.downsampler.expire(now) {
+		p.sendDownsampled(target, samples, "of an expired window")
+	}
+}
+
+// sendDownsampled queues flushed windows without blocking; when the processed queue is full
+// the samples are dropped and counted
+func (p *Processor) sendDownsampled(target string, samples []*model.OpenMx, reason string) {
+	if len(samples) == 0 {
+		return
+	}
+	select {
+	case p.processedQueue <- newDownsampledResult(target, samples):
+	default:
+		p.downsampleDropped.Add(int64(len(samples)))
+		logutil.Printf("WARN", "[PROCESSOR] Processed queue full, dropping %d downsampled samples of %s %s", len(samples), target, reason)
+	}
+}
+
+func newDownsampledResult(target string, samples []*model.OpenMx) *model.ConversionResult {
+	result := model.NewConversionResult(samples, make([]*model.OpenMxHelp, 0))
+	result.SetTarget(target)
+	result.SetCollectionTime(time.Now().UnixMilli())
+	return result
 }
 
 func (p *Processor) processLoop() {
 	for rawData := range p.rawQueue {
-		p.processRawData(rawData)
+		diagnostics.Profile(diagnostics.ComponentProcessor, rawData.TargetID, func() {
+			p.processRawData(rawData)
+		})
+		diagnostics.Beat(diagnostics.ComponentProcessor)
 	}
 }
 
+// reportParseProgress records the lines parsed so far as processor progress
+func reportParseProgress(lines int) {
+	diagnostics.Progress(diagnostics.ComponentProcessor, int64(lines))
+}
+
 func (p *Processor) processRawData(rawData *model.ScrapeRawData) {
+	p.trackTarget(rawData)
+	p.pruneTargetStats(time.Now())
+
 	if config.IsDebugEnabled() {
 		// Log only a preview of the raw metrics to avoid flooding logs
 		const maxLines = 20
@@ -81,66 +211,181 @@ func (p *Processor) processRawData(rawData *model.ScrapeRawData) {
 		}
 	}
 
-	// Convert the raw data to OpenMx format using the collection timestamp.
+	// Convert the raw data to OpenMx format using the collection timestamp, or the scrape-cycle
+	// boundary with timestamp alignment.
 	// The decoder (protobuf vs. text) is selected from the response Content-Type;
 	// non-protobuf payloads fall back to the existing text parser.
-	conversionResult, err := converter.ConvertWithContentType([]byte(rawData.RawData), rawData.ContentType, rawData.CollectionTime)
+	// Parsing a large body (e.g. a federate endpoint) can take minutes; report progress so the
+	// processor heartbeat stays fresh and diagnostics show it as busy rather than hung.
+	timestamp := scrapeTimestamp(rawData)
+	diagnostics.BeginWork(diagnostics.ComponentProcessor)
+	conversionResult, err := converter.ConvertWithOptions(rawData.RawData, rawData.ContentType, timestamp, converter.ConvertOptions{
+		Interner: p.interner,
+		// Target labels, pcode, the instance fallback and node are appended below
+		ExtraLabels: len(rawData.Labels) + 3,
+		Progress:    reportParseProgress,
+	})
+	diagnostics.EndWork(diagnostics.ComponentProcessor)
+	p.interner.EndScrape()
 	if err != nil {
 		logutil.Errorf("PROCESSOR", "Error converting raw data: %v", err)
+		p.conversionFailures.Add(1)
 		return
 	}
 
+	// The samples the exporter exposed, before any rule adds or drops series
+	exposed := len(conversionResult.GetOpenMxList())
+
+	// Replace the timestamps the exposition carries, e.g. for a federate endpoint with honorTimestamps: false
+	if rawData.IgnoreTimestamps {
+		stampScrapeTime(conversionResult.GetOpenMxList(), timestamp)
+	}
+
 	// Set target and timestamp info
 	conversionResult.SetTarget(rawData.TargetURL)
-	conversionResult.SetCollectionTime(rawData.CollectionTime)
+	conversionResult.SetCollectionTime(timestamp)
+	conversionResult.SetScrapeStart(rawData.ScrapeStart)
+
+	// Convert units on the exporter's original names, before prefixing and relabeling
+	if len(rawData.UnitConversions) > 0 {
+		conversion := converter.ApplyUnitConversions(conversionResult, rawData.UnitConversions)
+		recordUnitConversion(rawData.TargetURL, conversion)
+	}
 
-	// Apply metric relabeling if configured
-	if len(rawData.MetricRelabelConfigs) > 0 {
-		logutil.Infof("PROCESSOR", "Applying %d metric relabel configs", len(rawData.MetricRelabelConfigs))
-		converter.ApplyRelabelConfigs(conversionResult.GetOpenMxList(), rawData.MetricRelabelConfigs)
+	// Transform values after unit conversion, so rules see converted names and values, and before
+	// relabeling
+	if len(rawData.ValueTransforms) > 0 {
+		transformed := converter.ApplyValueTransforms(conversionResult, rawData.ValueTransforms)
+		recordValueTransforms(rawData.TargetURL, rawData.ValueTransforms, transformed)
 	}
 
-	// Filter out metrics with NaN and infinite values
+	// Join info metric labels on the exporter's original names, before prefixing and relabeling
+	if len(rawData.InfoJoins) > 0 {
+		p.recordInfoJoin(rawData.TargetURL, converter.ApplyInfoJoins(conversionResult, rawData.InfoJoins))
+	}
+
+	// Prefix metric names first, so metricRelabelConfigs match the prefixed names
+	if rawData.MetricPrefix != "" {
+		collisions := strings.Join(converter.ApplyMetricPrefix(conversionResult, rawData.MetricPrefix), ", ")
+		// Log each distinct collision set once per target instead of on every scrape
+		if collisions != p.prefixCollisions[rawData.TargetURL] {
+			p.prefixCollisions[rawData.TargetURL] = collisions
+			if collisions != "" {
+				logutil.Printf("WARN", "[PROCESSOR] metricPrefix %q for target %s collides with metrics it already exposes: %s",
+					rawData.MetricPrefix, rawData.TargetURL, collisions)
+			}
+		}
+	}
+
+	// Aggregate after prefixing, so rules name the prefixed metrics, and before relabeling, so
+	// metricRelabelConfigs apply to the aggregated series too
+	if len(rawData.Aggregations) > 0 {
+		emitted := converter.ApplyAggregations(conversionResult, rawData.Aggregations)
+		if config.IsDebugEnabled() {
+			logutil.Debugf("PROCESSOR", "Aggregations emitted %v series for target %s", emitted, rawData.TargetURL)
+		}
+	}
+
+	// Drop, zero or hold the exposed NaN and infinite values before relabeling marks dropped samples
+	// with NaN, so a bad value never reaches a pack unless passthrough is configured
+	var nonFinite nonFiniteResult
+	var heldNonFinite map[*model.OpenMx]float64
+	conversionResult.OpenMxList, nonFinite, heldNonFinite = applyNonFiniteValues(conversionResult.GetOpenMxList(), rawData.NonFiniteValues)
+	recordNonFinite(rawData.TargetURL, rawData.NonFiniteValues, nonFinite)
+
+	// Apply metric relabeling if configured, and count the dropped and kept samples
+	p.applyMetricRelabeling(rawData, conversionResult, timestamp)
+
+	// Enforce the label value length limit after relabeling, so rules match the original values
+	if rawData.LabelLengthLimit != nil {
+		var limited converter.LabelLengthResult
+		conversionResult.OpenMxList, limited = converter.ApplyLabelLengthLimit(conversionResult.GetOpenMxList(), rawData.LabelLengthLimit)
+		recordLabelLengthLimit(rawData.TargetURL, rawData.LabelLengthLimit, limited)
+	}
+
+	// Drop the whole scrape if it still has more samples than sampleLimit, before the meta metrics are added
+	p.applySampleLimit(conversionResult, rawData, timestamp)
+
+	// Record the alignment drift; added after relabeling so rules do not drop the meta metric
+	if drift := alignmentDriftSample(rawData, timestamp); drift != nil {
+		conversionResult.OpenMxList = append(conversionResult.OpenMxList, drift)
+	}
+	appendCertExpiry(conversionResult, rawData, timestamp)
+	p.appendInfoJoinConflicts(conversionResult, rawData, timestamp)
+	p.appendScrapeDegraded(conversionResult, rawData, exposed, timestamp, time.Now())
+
+	// Filter out metrics dropped by relabeling, which marks them with NaN
 	filteredOpenMxList := make([]*model.OpenMx, 0, len(conversionResult.GetOpenMxList()))
 	nodeLabelsAdded := 0
-	totalValidMetrics := 0
 
-	// Get PCODE from SecurityMaster
-	pcode := secure.GetSecurityMaster().PCODE
+	pcode := p.pcode()
 	var pcodeStr string
 	if pcode > 0 {
 		pcodeStr = strconv.FormatInt(pcode, 10)
 	}
 
 	for _, openMx := range conversionResult.GetOpenMxList() {
-		if !math.IsNaN(openMx.Value) && !math.IsInf(openMx.Value, 0) {
-			totalValidMetrics++
+		if math.IsNaN(openMx.Value) {
+			continue
+		}
 
-			// Add target labels (including job and instance)
-			for k, v := range rawData.Labels {
-				openMx.AddLabel(k, v)
-			}
+		// Add target labels (including job and instance), pcode and node
+		if appendTargetLabels(openMx, rawData, pcodeStr) {
+			nodeLabelsAdded++
+		}
 
-			// Add pcode label
-			if pcodeStr != "" {
-				openMx.AddLabel("pcode", pcodeStr)
-			}
+		filteredOpenMxList = append(filteredOpenMxList, openMx)
+	}
+	restoreNonFiniteValues(heldNonFinite)
 
-			// Add instance label if missing (fallback for backward compatibility)
-			if _, exists := rawData.Labels["instance"]; !exists {
-				openMx.AddLabel("instance", rawData.TargetURL)
+	// Run the registered sample hooks after the target labels, so they can match on them
+	if len(p.sampleHooks) > 0 {
+		var panicked map[string]int64
+		filteredOpenMxList, panicked = p.runSampleHooks(rawData, filteredOpenMxList)
+		if len(panicked) > 0 {
+			panics, help := sampleHookPanicSamples(panicked, timestamp)
+			for _, om := range panics {
+				appendTargetLabels(om, rawData, pcodeStr)
 			}
+			filteredOpenMxList = append(filteredOpenMxList, panics...)
+			conversionResult.OpenMxHelpList = append(conversionResult.OpenMxHelpList, help)
+		}
+	}
 
-			// Add node label if available and enabled
-			if rawData.NodeName != "" && rawData.AddNodeLabel {
-				openMx.AddLabel("node", rawData.NodeName)
-				nodeLabelsAdded++
-			}
+	// Truncate the scrape once its namespace has sent its samples per minute budget
+	var budgetResult *model.ConversionResult
+	if rawData.SampleBudget != nil {
+		before := len(filteredOpenMxList)
+		var dropped int64
+		filteredOpenMxList, dropped = applyNamespaceSampleBudget(filteredOpenMxList, rawData.SampleBudget, timestamp)
+		if len(filteredOpenMxList) < before {
+			budgetResult = newNamespaceBudgetResult(rawData.SampleBudget.Namespace, dropped, timestamp)
+		}
+	}
+	p.samplesProcessed.Add(int64(len(filteredOpenMxList)))
 
-			filteredOpenMxList = append(filteredOpenMxList, openMx)
+	// Capture the processed samples of targets being debugged through the admin endpoint
+	if rawData.TargetID != "" {
+		diagnostics.Samples.Record(rawData.TargetID, filteredOpenMxList)
+		// Per-minute samples, series and top metric names for GET /targets/{id}/cardinality
+		if config.GetBoolWithDefault("openagent_cardinality_enabled", true) {
+			diagnostics.Cardinality.Record(rawData.TargetID, filteredOpenMxList)
 		}
 	}
 
+	// Aggregate series over the configured window instead of sending every sample
+	if rawData.Downsample != nil {
+		filteredOpenMxList = p.downsampler.apply(rawData.TargetURL, rawData.Downsample, filteredOpenMxList, conversionResult.GetOpenMxHelpList())
+	} else if flushed := p.downsampler.drop(rawData.TargetURL); len(flushed) > 0 {
+		filteredOpenMxList = append(filteredOpenMxList, flushed...)
+	}
+
+	// Drop the metadata when it is not sent, once the downsampler has read the metric types.
+	// The OTLP exporter infers the types from it too, so it is kept while OTLP is enabled.
+	if !config.SendMetricMetadata() && !config.GetBoolWithDefault("openagent_otlp_enabled", false) {
+		conversionResult.OpenMxHelpList = nil
+	}
+
 	// Summary logging for node label addition
 	if config.IsDebugEnabled() && nodeLabelsAdded > 0 {
 		logutil.Debugf("PROCESSOR", "Added node labels to %d metrics", nodeLabelsAdded)
@@ -162,4 +407,75 @@ func (p *Processor) processRawData(rawData *model.ScrapeRawData) {
 
 	// Add the processed data to the queue
 	p.processedQueue <- conversionResult
+	if budgetResult != nil {
+		p.processedQueue <- budgetResult
+	}
+
+	p.emitExpired(rawData.CollectionTime)
+}
+
+// AgentNodeLabel carries the agent's node name when the exporter emits its own node label
+// and the endpoint sets preserveAgentNodeLabel
+const AgentNodeLabel = "agent_node"
+
+// appendTargetLabels appends the target labels, pcode, the instance fallback and, with addNodeLabel,
+// the node label to a series. The exporter's own node label takes precedence: the agent's one is
+// dropped, or added as agent_node with preserveAgentNodeLabel. With honorLabels, every exposed label
+// takes precedence over the target label of the same name. It reports whether a node label was added.
+func appendTargetLabels(openMx *model.OpenMx, rawData *model.ScrapeRawData, pcodeStr string) bool {
+	addNode := rawData.NodeName != "" && rawData.AddNodeLabel
+	// Only the exposition's labels are on the series before the target labels are appended
+	exposedNode := addNode && hasLabel(openMx, "node")
+	exposedInstance := rawData.HonorLabels && hasLabel(openMx, "instance")
+
+	for k, v := range rawData.Labels {
+		// Discovery puts the node name into the target labels too; it is added below instead
+		if addNode && k == "node" {
+			continue
+		}
+		// Target label keys are unique, so a label already on the series was exposed
+		if rawData.HonorLabels && hasLabel(openMx, k) {
+			continue
+		}
+		openMx.AddLabel(k, v)
+	}
+
+	// Add pcode label
+	if pcodeStr != "" {
+		openMx.AddLabel("pcode", pcodeStr)
+	}
+
+	// Add instance label if missing (fallback for backward compatibility)
+	if _, exists := rawData.Labels["instance"]; !exists && !exposedInstance {
+		openMx.AddLabel("instance", rawData.TargetURL)
+	}
+
+	// External labels never replace a label of the series or the target
+	for k, v := range rawData.ExternalLabels {
+		if !hasLabel(openMx, k) {
+			openMx.AddLabel(k, v)
+		}
+	}
+
+	switch {
+	case !addNode:
+		return false
+	case !exposedNode:
+		openMx.AddLabel("node", rawData.NodeName)
+	case rawData.PreserveAgentNodeLabel:
+		openMx.AddLabel(AgentNodeLabel, rawData.NodeName)
+	default:
+		return false
+	}
+	return true
+}
+
+// hasLabel reports whether the series has a label with the key
+func hasLabel(openMx *model.OpenMx, key string) bool {
+	for _, label := range openMx.Labels {
+		if label.Key == key {
+			return true
+		}
+	}
+	return false
 }
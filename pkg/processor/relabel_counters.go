@@ -0,0 +1,73 @@
+package processor
+
+import (
+	"math"
+
+	"open-agent/pkg/config"
+	"open-agent/pkg/converter"
+	"open-agent/pkg/model"
+	"open-agent/tools/util/logutil"
+)
+
+// Self-metric names for the metric relabeling counters
+const (
+	MetricRelabelDroppedSamples = "openagent_relabel_dropped_samples_total"
+	MetricRelabelKeptSamples    = "openagent_relabel_kept_samples_total"
+)
+
+// relabelCount is the cumulative metric relabeling outcome of one target
+type relabelCount struct {
+	dropped int64
+	kept    int64
+}
+
+// applyMetricRelabeling applies the target's metricRelabelConfigs and appends the target's cumulative
+// dropped and kept sample counters to the result. The counters are appended after relabeling, so the
+// rules that drop the target's data never drop them.
+func (p *Processor) applyMetricRelabeling(rawData *model.ScrapeRawData, result *model.ConversionResult, timestamp int64) {
+	if len(rawData.MetricRelabelConfigs) == 0 {
+		return
+	}
+	logutil.Infof("PROCESSOR", "Applying %d metric relabel configs", len(rawData.MetricRelabelConfigs))
+	converter.ApplyRelabelConfigs(result.GetOpenMxList(), rawData.MetricRelabelConfigs)
+
+	if !config.GetBoolWithDefault("openagent_relabel_counters_enabled", true) {
+		return
+	}
+	// Relabeling marks dropped samples with NaN; NaN exposed by the target was handled before relabeling
+	var dropped, kept int64
+	for _, openMx := range result.GetOpenMxList() {
+		if math.IsNaN(openMx.Value) {
+			dropped++
+		} else {
+			kept++
+		}
+	}
+
+	target := rawData.TargetID
+	if target == "" {
+		target = rawData.TargetURL
+	}
+	count, ok := p.relabelCounts[target]
+	if !ok {
+		count = &relabelCount{}
+		p.relabelCounts[target] = count
+	}
+	count.dropped += dropped
+	count.kept += kept
+
+	// The job label and the other target labels are appended with the target's own samples
+	droppedSample := model.NewOpenMx(MetricRelabelDroppedSamples, timestamp, float64(count.dropped))
+	droppedSample.AddLabel("target", target)
+	keptSample := model.NewOpenMx(MetricRelabelKeptSamples, timestamp, float64(count.kept))
+	keptSample.AddLabel("target", target)
+	result.OpenMxList = append(result.OpenMxList, droppedSample, keptSample)
+
+	droppedHelp := model.NewOpenMxHelp(MetricRelabelDroppedSamples)
+	droppedHelp.Put("help", "Samples of the target dropped by its metricRelabelConfigs")
+	droppedHelp.Put("type", "counter")
+	keptHelp := model.NewOpenMxHelp(MetricRelabelKeptSamples)
+	keptHelp.Put("help", "Samples of the target kept by its metricRelabelConfigs")
+	keptHelp.Put("type", "counter")
+	result.OpenMxHelpList = append(result.OpenMxHelpList, droppedHelp, keptHelp)
+}
@@ -0,0 +1,117 @@
+package processor
+
+import (
+	"math"
+	"testing"
+
+	"open-agent/pkg/converter"
+	"open-agent/pkg/model"
+)
+
+const relabelBody = `# TYPE http_requests_total counter
+http_requests_total{code="200"} 10
+http_requests_total{code="500"} 1
+# TYPE go_goroutines gauge
+go_goroutines 12
+`
+
+func relabelCounterValues(list []*model.OpenMx) map[string]float64 {
+	values := make(map[string]float64)
+	for _, openMx := range list {
+		if openMx.Metric == MetricRelabelDroppedSamples || openMx.Metric == MetricRelabelKeptSamples {
+			values[openMx.Metric] = openMx.Value
+		}
+	}
+	return values
+}
+
+func TestApplyMetricRelabeling_CountsDroppedAndKept(t *testing.T) {
+	p := NewProcessor(nil, nil)
+	rawData := &model.ScrapeRawData{
+		TargetID: "node-exporter/10.0.0.1:9100",
+		MetricRelabelConfigs: model.RelabelConfigs{
+			{SourceLabels: []string{"__name__"}, Separator: ";", Regex: "go_.*", Action: "drop"},
+		},
+	}
+
+	for scrape := 1; scrape <= 2; scrape++ {
+		result, err := converter.ConvertWithOptions(relabelBody, "", 1700000000000, converter.ConvertOptions{})
+		if err != nil {
+			t.Fatalf("convert: %v", err)
+		}
+		p.applyMetricRelabeling(rawData, result, 1700000000000)
+
+		values := relabelCounterValues(result.GetOpenMxList())
+		if values[MetricRelabelDroppedSamples] != float64(scrape) || values[MetricRelabelKeptSamples] != float64(2*scrape) {
+			t.Errorf("scrape %d: unexpected counters %v", scrape, values)
+		}
+	}
+}
+
+func TestApplyMetricRelabeling_CountersExemptFromRules(t *testing.T) {
+	p := NewProcessor(nil, nil)
+	rawData := &model.ScrapeRawData{
+		TargetID: "app",
+		MetricRelabelConfigs: model.RelabelConfigs{
+			// Rules that would drop the agent's own metrics if they were relabeled
+			{SourceLabels: []string{"__name__"}, Separator: ";", Regex: "openagent_.*", Action: "drop"},
+			{SourceLabels: []string{"__name__"}, Separator: ";", Regex: "http_.*", Action: "keep"},
+		},
+	}
+	result, err := converter.ConvertWithOptions(relabelBody, "", 1700000000000, converter.ConvertOptions{})
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	p.applyMetricRelabeling(rawData, result, 1700000000000)
+
+	var counters int
+	for _, openMx := range result.GetOpenMxList() {
+		if openMx.Metric != MetricRelabelDroppedSamples && openMx.Metric != MetricRelabelKeptSamples {
+			continue
+		}
+		counters++
+		if math.IsNaN(openMx.Value) {
+			t.Errorf("%s was dropped by the target's relabel rules", openMx.Metric)
+		}
+		if !hasLabel(openMx, "target") {
+			t.Errorf("%s has no target label", openMx.Metric)
+		}
+	}
+	if counters != 2 {
+		t.Fatalf("expected both counters, got %d", counters)
+	}
+	if values := relabelCounterValues(result.GetOpenMxList()); values[MetricRelabelDroppedSamples] != 1 || values[MetricRelabelKeptSamples] != 2 {
+		t.Errorf("unexpected counters %v", values)
+	}
+}
+
+func TestApplyMetricRelabeling_NoCountersWithoutRules(t *testing.T) {
+	p := NewProcessor(nil, nil)
+	result, err := converter.ConvertWithOptions(relabelBody, "", 1700000000000, converter.ConvertOptions{})
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	p.applyMetricRelabeling(&model.ScrapeRawData{TargetID: "plain"}, result, 1700000000000)
+	if values := relabelCounterValues(result.GetOpenMxList()); len(values) != 0 {
+		t.Errorf("expected no relabel counters for a target without metricRelabelConfigs, got %v", values)
+	}
+}
+
+func TestApplyMetricRelabeling_CountersDisabled(t *testing.T) {
+	t.Setenv("openagent_relabel_counters_enabled", "false")
+	p := NewProcessor(nil, nil)
+	result, err := converter.ConvertWithOptions(relabelBody, "", 1700000000000, converter.ConvertOptions{})
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	rawData := &model.ScrapeRawData{
+		TargetID: "app",
+		MetricRelabelConfigs: model.RelabelConfigs{
+			{SourceLabels: []string{"__name__"}, Separator: ";", Regex: "go_.*", Action: "drop"},
+		},
+	}
+	p.applyMetricRelabeling(rawData, result, 1700000000000)
+	if values := relabelCounterValues(result.GetOpenMxList()); len(values) != 0 {
+		t.Errorf("expected no relabel counters when disabled, got %v", values)
+	}
+}
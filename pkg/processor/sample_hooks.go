@@ -0,0 +1,120 @@
+package processor
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"open-agent/pkg/model"
+	"open-agent/tools/util/logutil"
+)
+
+// SampleHookPanicsMetric counts the panics of a sample hook; it is sent with the scrape a hook panicked on
+const SampleHookPanicsMetric = "openagent_sample_hook_panics_total"
+
+// sampleHookPanicLogInterval is how often the panics of the same hook are logged
+const sampleHookPanicLogInterval = time.Minute
+
+// ScrapeTarget is the target of a scrape as seen by sample hooks
+type ScrapeTarget struct {
+	ID  string // discovery target ID
+	URL string
+	// Labels are the target labels added to every sample, e.g. namespace, pod, job and instance
+	Labels map[string]string
+}
+
+// SampleHook enriches the samples of every scrape, e.g. with labels looked up in a CMDB. OnScrape runs
+// on the processor goroutine after metric relabeling and the target labels, before the samples are
+// queued for sending; it returns the samples to send and may modify, add or drop samples. When a hook
+// panics, the samples passed to it are sent and the next hook runs.
+type SampleHook interface {
+	OnScrape(target ScrapeTarget, samples []*model.OpenMx) []*model.OpenMx
+}
+
+// Option configures a Processor created by NewProcessor
+type Option func(*Processor)
+
+// WithSampleHooks registers hooks run in order on the samples of every scrape
+func WithSampleHooks(hooks ...SampleHook) Option {
+	return func(p *Processor) {
+		p.sampleHooks = append(p.sampleHooks, hooks...)
+	}
+}
+
+// sampleHookPanics counts the panics of each hook, by hook type
+type sampleHookPanics struct {
+	mu         sync.Mutex
+	counts     map[string]int64
+	lastLogged map[string]time.Time
+}
+
+// SampleHookPanics returns the panics of each sample hook since the processor was created
+func (p *Processor) SampleHookPanics() map[string]int64 {
+	p.hookPanics.mu.Lock()
+	defer p.hookPanics.mu.Unlock()
+	counts := make(map[string]int64, len(p.hookPanics.counts))
+	for hook, count := range p.hookPanics.counts {
+		counts[hook] = count
+	}
+	return counts
+}
+
+// runSampleHooks passes the samples of a scrape through the registered hooks and returns the
+// cumulative panic count of the hooks that panicked on it
+func (p *Processor) runSampleHooks(rawData *model.ScrapeRawData, samples []*model.OpenMx) ([]*model.OpenMx, map[string]int64) {
+	target := ScrapeTarget{ID: rawData.TargetID, URL: rawData.TargetURL, Labels: rawData.Labels}
+	var panicked map[string]int64
+	for _, hook := range p.sampleHooks {
+		result, recovered := runSampleHook(hook, target, samples)
+		if recovered == nil {
+			samples = result
+			continue
+		}
+		if panicked == nil {
+			panicked = make(map[string]int64)
+		}
+		name := fmt.Sprintf("%T", hook)
+		panicked[name] = p.recordHookPanic(name, rawData.TargetURL, recovered, time.Now())
+	}
+	return samples, panicked
+}
+
+// runSampleHook calls one hook and returns the value it panicked with, nil when it returned
+func runSampleHook(hook SampleHook, target ScrapeTarget, samples []*model.OpenMx) (result []*model.OpenMx, recovered interface{}) {
+	defer func() {
+		recovered = recover()
+	}()
+	return hook.OnScrape(target, samples), nil
+}
+
+// recordHookPanic counts a panic and logs it once per sampleHookPanicLogInterval per hook
+func (p *Processor) recordHookPanic(hook, targetURL string, recovered interface{}, now time.Time) int64 {
+	panics := &p.hookPanics
+	panics.mu.Lock()
+	defer panics.mu.Unlock()
+	if panics.counts == nil {
+		panics.counts = make(map[string]int64)
+		panics.lastLogged = make(map[string]time.Time)
+	}
+	panics.counts[hook]++
+	if now.Sub(panics.lastLogged[hook]) >= sampleHookPanicLogInterval {
+		panics.lastLogged[hook] = now
+		logutil.Printf("WARN", "[PROCESSOR] Sample hook %s panicked on a scrape of %s, sending its samples unchanged (%d panics in total): %v",
+			hook, targetURL, panics.counts[hook], recovered)
+	}
+	return panics.counts[hook]
+}
+
+// sampleHookPanicSamples returns the panic counters of the hooks that panicked on a scrape
+func sampleHookPanicSamples(panicked map[string]int64, timestamp int64) ([]*model.OpenMx, *model.OpenMxHelp) {
+	samples := make([]*model.OpenMx, 0, len(panicked))
+	for hook, count := range panicked {
+		om := model.NewOpenMx(SampleHookPanicsMetric, timestamp, float64(count))
+		om.AddLabel("hook", hook)
+		samples = append(samples, om)
+	}
+	help := model.NewOpenMxHelp(SampleHookPanicsMetric)
+	help.Put("help", "Panics of the sample hook, whose scrapes were sent without it")
+	help.Put("type", "counter")
+	return samples, help
+}
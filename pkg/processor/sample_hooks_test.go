@@ -0,0 +1,104 @@
+package processor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"open-agent/pkg/model"
+)
+
+// WithPcode sets where the project code added to every sample comes from, instead of the SecurityMaster
+func WithPcode(pcode func() int64) Option {
+	return func(p *Processor) {
+		p.pcode = pcode
+	}
+}
+
+type panickingHook struct{}
+
+func (panickingHook) OnScrape(ScrapeTarget, []*model.OpenMx) []*model.OpenMx {
+	panic("cmdb lookup failed")
+}
+
+// dropHook drops the samples of a metric
+type dropHook struct{ metric string }
+
+func (h dropHook) OnScrape(_ ScrapeTarget, samples []*model.OpenMx) []*model.OpenMx {
+	kept := samples[:0]
+	for _, om := range samples {
+		if om.Metric != h.metric {
+			kept = append(kept, om)
+		}
+	}
+	return kept
+}
+
+func writeStaticLabels(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "static_labels.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSampleHooks_StaticLabelsAndPanics(t *testing.T) {
+	static, err := LoadStaticLabelHook(writeStaticLabels(t, `{"rules": [
+		{"match": {"namespace": "payments"}, "labels": {"service_tier": "gold", "team": "billing"}},
+		{"labels": {"cmdb": "v1"}}
+	]}`))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	p := NewProcessor(nil, nil, WithSampleHooks(static, panickingHook{}, dropHook{metric: "go_goroutines"}))
+
+	rawData := &model.ScrapeRawData{TargetID: "payments/api-0", TargetURL: "http://10.0.0.1:8080/metrics",
+		Labels: map[string]string{"namespace": "payments"}}
+	exposed := model.NewOpenMx("requests_total", 0, 1)
+	exposed.AddLabel("team", "payments-api")
+	samples, panicked := p.runSampleHooks(rawData, []*model.OpenMx{exposed, model.NewOpenMx("go_goroutines", 0, 12)})
+
+	// The panicking hook is skipped, the hooks before and after it still apply
+	if len(samples) != 1 || samples[0].Metric != "requests_total" {
+		t.Fatalf("expected go_goroutines dropped by the last hook, got %v", samples)
+	}
+	for key, want := range map[string]string{"service_tier": "gold", "team": "payments-api", "cmdb": "v1"} {
+		if got := labelValue(samples[0], key); got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+	if panicked["processor.panickingHook"] != 1 {
+		t.Errorf("expected the panic counted, got %v", panicked)
+	}
+
+	// Other namespaces only get the rule without a match
+	other := model.NewOpenMx("requests_total", 0, 1)
+	p.runSampleHooks(&model.ScrapeRawData{Labels: map[string]string{"namespace": "web"}}, []*model.OpenMx{other})
+	if labelValue(other, "service_tier") != "" || labelValue(other, "cmdb") != "v1" {
+		t.Errorf("expected only the unmatched rule's labels, got %v", other.Labels)
+	}
+
+	if got := p.SampleHookPanics()["processor.panickingHook"]; got != 2 {
+		t.Errorf("expected 2 panics in total, got %d", got)
+	}
+	counters, help := sampleHookPanicSamples(map[string]int64{"processor.panickingHook": 2}, 1000)
+	if len(counters) != 1 || counters[0].Metric != SampleHookPanicsMetric || counters[0].Value != 2 ||
+		labelValue(counters[0], "hook") != "processor.panickingHook" || help.Get("type") != "counter" {
+		t.Errorf("unexpected panic counter %+v", counters)
+	}
+}
+
+func TestLoadStaticLabelHook_Invalid(t *testing.T) {
+	for name, content := range map[string]string{
+		"not json":     `rules: []`,
+		"empty labels": `{"rules": [{"match": {"namespace": "payments"}}]}`,
+	} {
+		if _, err := LoadStaticLabelHook(writeStaticLabels(t, content)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	if _, err := LoadStaticLabelHook(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Errorf("expected an error for a missing file")
+	}
+}
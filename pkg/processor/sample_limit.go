@@ -0,0 +1,53 @@
+package processor
+
+import (
+	"math"
+
+	"open-agent/pkg/model"
+	"open-agent/tools/util/logutil"
+)
+
+// SampleLimitExceededMetric is sent with every scrape of an endpoint with a sample limit: 1 when the
+// scrape exposed more samples than the limit after metric relabeling and all of them were dropped, 0 otherwise
+const SampleLimitExceededMetric = "openagent_scrape_sample_limit_exceeded"
+
+// applySampleLimit drops every sample of a scrape that keeps more samples than its sampleLimit after
+// metric relabeling, like Prometheus fails such a scrape instead of sending part of it, and appends the
+// exceeded flag. The flag is added after the check so it never counts against the limit.
+func (p *Processor) applySampleLimit(result *model.ConversionResult, rawData *model.ScrapeRawData, timestamp int64) {
+	if rawData.SampleLimit <= 0 {
+		return
+	}
+	// Relabeling marks dropped samples with NaN
+	kept := 0
+	for _, om := range result.GetOpenMxList() {
+		if !math.IsNaN(om.Value) {
+			kept++
+		}
+	}
+
+	exceeded := kept > rawData.SampleLimit
+	value := 0.0
+	if exceeded {
+		value = 1
+		result.OpenMxList = result.OpenMxList[:0]
+	}
+	// Log when a target goes over or back under its limit instead of on every scrape
+	if exceeded != p.sampleLimitExceeded[rawData.TargetURL] {
+		if exceeded {
+			p.sampleLimitExceeded[rawData.TargetURL] = true
+			logutil.Printf("WARN", "[PROCESSOR] Target %s exposed %d samples, more than sampleLimit %d, dropping its scrapes",
+				rawData.TargetURL, kept, rawData.SampleLimit)
+		} else {
+			delete(p.sampleLimitExceeded, rawData.TargetURL)
+			logutil.Printf("INFO", "[PROCESSOR] Target %s is back under sampleLimit %d with %d samples",
+				rawData.TargetURL, rawData.SampleLimit, kept)
+		}
+	}
+	result.OpenMxList = append(result.OpenMxList, model.NewOpenMx(SampleLimitExceededMetric, timestamp, value))
+
+	help := model.NewOpenMxHelp(SampleLimitExceededMetric)
+	help.Put("help", "1 if the scrape exposed more samples than the endpoint's sampleLimit and was dropped")
+	help.Put("type", "gauge")
+	result.OpenMxHelpList = append(result.OpenMxHelpList, help)
+}
@@ -0,0 +1,79 @@
+package processor
+
+import (
+	"math"
+	"testing"
+
+	"open-agent/pkg/model"
+)
+
+func TestApplySampleLimit(t *testing.T) {
+	p := NewProcessor(nil, nil)
+	rawData := model.NewScrapeRawData("http://10.0.0.1:9100/metrics", "", nil, nil, 1700000000000)
+	rawData.SampleLimit = 2
+
+	scrape := func(values ...float64) *model.ConversionResult {
+		list := make([]*model.OpenMx, 0, len(values))
+		for _, v := range values {
+			list = append(list, model.NewOpenMx("up", 1700000000000, v))
+		}
+		result := model.NewConversionResult(list, nil)
+		p.applySampleLimit(result, rawData, 1700000000000)
+		return result
+	}
+	flag := func(result *model.ConversionResult) float64 {
+		list := result.GetOpenMxList()
+		last := list[len(list)-1]
+		if last.Metric != SampleLimitExceededMetric {
+			t.Fatalf("expected %s last, got %s", SampleLimitExceededMetric, last.Metric)
+		}
+		return last.Value
+	}
+
+	// Samples dropped by relabeling (NaN) do not count against the limit
+	result := scrape(1, 1, math.NaN())
+	if len(result.GetOpenMxList()) != 4 || flag(result) != 0 {
+		t.Errorf("expected the scrape kept under the limit, got %d samples", len(result.GetOpenMxList()))
+	}
+	if p.sampleLimitExceeded[rawData.TargetURL] {
+		t.Errorf("expected the target under its limit")
+	}
+
+	result = scrape(1, 1, 1)
+	if len(result.GetOpenMxList()) != 1 || flag(result) != 1 {
+		t.Errorf("expected every sample dropped over the limit, got %d samples", len(result.GetOpenMxList()))
+	}
+	if !p.sampleLimitExceeded[rawData.TargetURL] {
+		t.Errorf("expected the target over its limit")
+	}
+
+	scrape(1)
+	if _, ok := p.sampleLimitExceeded[rawData.TargetURL]; ok {
+		t.Errorf("expected the target back under its limit")
+	}
+
+	rawData.SampleLimit = 0
+	if result := scrape(1, 1, 1); len(result.GetOpenMxList()) != 3 {
+		t.Errorf("expected no limit and no flag without sampleLimit, got %d samples", len(result.GetOpenMxList()))
+	}
+}
+
+func TestAppendTargetLabels_ExternalLabels(t *testing.T) {
+	labels := map[string]string{"job": "node", "instance": "10.0.0.1:9100", "region": "ap-northeast-1"}
+	rawData := model.NewScrapeRawData("http://10.0.0.1:9100/metrics", "", nil, labels, 1700000000000)
+	rawData.ExternalLabels = map[string]string{"cluster": "prod", "region": "ap-northeast-2", "team": "infra"}
+
+	om := model.NewOpenMx("up", 1700000000000, 1)
+	om.AddLabel("team", "payments")
+	appendTargetLabels(om, rawData, "")
+
+	// The series and target labels win over the external labels
+	for key, want := range map[string]string{"cluster": "prod", "region": "ap-northeast-1", "team": "payments"} {
+		if got := labelValue(om, key); got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+	if len(om.Labels) != 5 {
+		t.Errorf("expected each label once, got %+v", om.Labels)
+	}
+}
@@ -0,0 +1,82 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"open-agent/pkg/model"
+)
+
+// StaticLabelsFileKey names the JSON file of the built-in static label hook (env var or whatap.conf)
+const StaticLabelsFileKey = "openagent_static_labels_file"
+
+// StaticLabelRule adds fixed labels to the samples of the targets whose labels match
+type StaticLabelRule struct {
+	// Match maps target labels to their values; all must match, an empty match selects every target
+	Match  map[string]string `json:"match"`
+	Labels map[string]string `json:"labels"`
+}
+
+// StaticLabelHook is the built-in SampleHook: it adds labels exported from a CMDB, e.g. service_tier,
+// to the samples of matching targets. A label the sample already has is kept.
+type StaticLabelHook struct {
+	rules []StaticLabelRule
+}
+
+// NewStaticLabelHook returns a hook applying the rules in order
+func NewStaticLabelHook(rules []StaticLabelRule) (*StaticLabelHook, error) {
+	for i, rule := range rules {
+		if len(rule.Labels) == 0 {
+			return nil, fmt.Errorf("rule %d has no labels", i)
+		}
+	}
+	return &StaticLabelHook{rules: rules}, nil
+}
+
+// LoadStaticLabelHook reads the rules of a static label hook from a JSON file:
+//
+//	{"rules": [{"match": {"namespace": "payments"}, "labels": {"service_tier": "gold"}}]}
+func LoadStaticLabelHook(path string) (*StaticLabelHook, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Rules []StaticLabelRule `json:"rules"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", path, err)
+	}
+	hook, err := NewStaticLabelHook(file.Rules)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", path, err)
+	}
+	return hook, nil
+}
+
+// OnScrape adds the labels of every rule matching the target
+func (h *StaticLabelHook) OnScrape(target ScrapeTarget, samples []*model.OpenMx) []*model.OpenMx {
+	for _, rule := range h.rules {
+		if !rule.matches(target) {
+			continue
+		}
+		for _, om := range samples {
+			for key, value := range rule.Labels {
+				if !hasLabel(om, key) {
+					om.AddLabel(key, value)
+				}
+			}
+		}
+	}
+	return samples
+}
+
+func (r StaticLabelRule) matches(target ScrapeTarget) bool {
+	for key, value := range r.Match {
+		if target.Labels[key] != value {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,68 @@
+package processor
+
+import (
+	"time"
+
+	"open-agent/pkg/model"
+)
+
+// StatsPruneInterval is how often the per-target stats of targets no longer scraped are dropped
+const StatsPruneInterval = time.Minute
+
+// WithLiveTargets sets the function reporting whether a target ID is still scraped, e.g. the scraper
+// manager's HasTarget. Once per StatsPruneInterval the per-target stats of other targets are dropped;
+// without it they are kept for the life of the process.
+func WithLiveTargets(live func(targetID string) bool) Option {
+	return func(p *Processor) {
+		p.liveTargets = live
+	}
+}
+
+// trackTarget remembers the URL a target was last scraped at, as most per-target stats are keyed by URL
+func (p *Processor) trackTarget(rawData *model.ScrapeRawData) {
+	targetID := rawData.TargetID
+	if targetID == "" {
+		targetID = rawData.TargetURL
+	}
+	p.targetURLs[targetID] = rawData.TargetURL
+}
+
+// pruneTargetStats drops the per-target stats of targets that are no longer scraped, and of URLs a
+// live target moved away from, at most once per StatsPruneInterval
+func (p *Processor) pruneTargetStats(now time.Time) {
+	if p.liveTargets == nil || now.Sub(p.lastStatsPrune) < StatsPruneInterval {
+		return
+	}
+	p.lastStatsPrune = now
+
+	keep := make(map[string]bool, 2*len(p.targetURLs))
+	for targetID, url := range p.targetURLs {
+		if !p.liveTargets(targetID) {
+			delete(p.targetURLs, targetID)
+			continue
+		}
+		keep[targetID] = true
+		keep[url] = true
+	}
+
+	for target := range p.prefixCollisions {
+		if !keep[target] {
+			delete(p.prefixCollisions, target)
+		}
+	}
+	for target := range p.infoJoinConflicts {
+		if !keep[target] {
+			delete(p.infoJoinConflicts, target)
+		}
+	}
+	for target := range p.relabelCounts {
+		if !keep[target] {
+			delete(p.relabelCounts, target)
+		}
+	}
+	kept := func(target string) bool { return keep[target] }
+	pruneUnitConversionCounts(kept)
+	pruneLabelLengthCounts(kept)
+	pruneNonFiniteCounts(kept)
+	pruneValueTransformCounts(kept)
+}
@@ -0,0 +1,99 @@
+package processor
+
+import (
+	"testing"
+	"time"
+
+	"open-agent/pkg/converter"
+	"open-agent/pkg/model"
+)
+
+// newPruningProcessor returns a processor scraping api at http://10.0.0.1:8080/metrics and web at
+// http://10.0.0.2:8080/metrics, where live tells which targets are still scraped
+func newPruningProcessor(live map[string]bool) *Processor {
+	p := NewProcessor(nil, nil, WithLiveTargets(func(targetID string) bool { return live[targetID] }))
+	p.trackTarget(&model.ScrapeRawData{TargetID: "api", TargetURL: "http://10.0.0.1:8080/metrics"})
+	p.trackTarget(&model.ScrapeRawData{TargetID: "web", TargetURL: "http://10.0.0.2:8080/metrics"})
+	return p
+}
+
+func TestPruneTargetStats_DropsRemovedTargets(t *testing.T) {
+	live := map[string]bool{"api": true, "web": true}
+	p := newPruningProcessor(live)
+	p.prefixCollisions["http://10.0.0.1:8080/metrics"] = "app_up"
+	p.prefixCollisions["http://10.0.0.2:8080/metrics"] = "app_up"
+	p.recordInfoJoin("http://10.0.0.2:8080/metrics", converter.InfoJoinResult{Conflicts: 1})
+	p.relabelCounts["api"] = &relabelCount{kept: 1}
+	p.relabelCounts["web"] = &relabelCount{kept: 1}
+	recordUnitConversion("http://10.0.0.1:8080/metrics", converter.UnitConversionResult{Converted: 1})
+	recordUnitConversion("http://10.0.0.2:8080/metrics", converter.UnitConversionResult{Converted: 1})
+	limit := &model.LabelLengthLimit{Limit: 64}
+	recordLabelLengthLimit("http://10.0.0.1:8080/metrics", limit, converter.LabelLengthResult{})
+	recordLabelLengthLimit("http://10.0.0.2:8080/metrics", limit, converter.LabelLengthResult{})
+	recordNonFinite("http://10.0.0.1:8080/metrics", model.NonFiniteDrop, nonFiniteResult{Dropped: 1})
+	recordNonFinite("http://10.0.0.2:8080/metrics", model.NonFiniteDrop, nonFiniteResult{Dropped: 1})
+	transforms := model.ValueTransforms{{Op: model.ValueTransformAbs, MetricRegex: ".*"}}
+	recordValueTransforms("http://10.0.0.1:8080/metrics", transforms, []int{1})
+	recordValueTransforms("http://10.0.0.2:8080/metrics", transforms, []int{1})
+
+	now := time.Now()
+	p.pruneTargetStats(now)
+	if len(p.prefixCollisions) != 2 {
+		t.Fatalf("expected the stats of live targets to be kept, got %v", p.prefixCollisions)
+	}
+
+	// web is removed, and api moves to another pod IP
+	delete(live, "web")
+	p.trackTarget(&model.ScrapeRawData{TargetID: "api", TargetURL: "http://10.0.0.3:8080/metrics"})
+	p.prefixCollisions["http://10.0.0.3:8080/metrics"] = ""
+	p.pruneTargetStats(now.Add(StatsPruneInterval / 2))
+	if len(p.prefixCollisions) != 3 {
+		t.Fatalf("expected no pruning within StatsPruneInterval, got %v", p.prefixCollisions)
+	}
+
+	p.pruneTargetStats(now.Add(StatsPruneInterval))
+	removed := map[string]bool{"http://10.0.0.1:8080/metrics": true, "http://10.0.0.2:8080/metrics": true}
+	if _, ok := p.prefixCollisions["http://10.0.0.3:8080/metrics"]; !ok || len(p.prefixCollisions) != 1 {
+		t.Errorf("expected only api's current URL to be kept, got %v", p.prefixCollisions)
+	}
+	if _, ok := p.relabelCounts["api"]; !ok || len(p.relabelCounts) != 1 {
+		t.Errorf("expected only api's relabel counters to be kept, got %v", p.relabelCounts)
+	}
+	if len(p.infoJoinConflicts) != 0 {
+		t.Errorf("expected the infoJoin counters of removed targets to be dropped, got %v", p.infoJoinConflicts)
+	}
+	for _, count := range UnitConversionCounts() {
+		if removed[count.Target] {
+			t.Errorf("expected the unitConversions counters of %s to be dropped", count.Target)
+		}
+	}
+	for _, count := range LabelLengthCounts() {
+		if removed[count.Target] {
+			t.Errorf("expected the label value length counters of %s to be dropped", count.Target)
+		}
+	}
+	for _, count := range NonFiniteCounts() {
+		if removed[count.Target] {
+			t.Errorf("expected the NaN/Inf counters of %s to be dropped", count.Target)
+		}
+	}
+	for _, count := range ValueTransformCounts() {
+		if removed[count.Target] {
+			t.Errorf("expected the valueTransforms counters of %s to be dropped", count.Target)
+		}
+	}
+	if len(p.targetURLs) != 1 {
+		t.Errorf("expected the removed target to be forgotten, got %v", p.targetURLs)
+	}
+}
+
+func TestPruneTargetStats_KeptWithoutLiveTargets(t *testing.T) {
+	p := NewProcessor(nil, nil)
+	p.trackTarget(&model.ScrapeRawData{TargetID: "api", TargetURL: "http://10.0.0.1:8080/metrics"})
+	p.prefixCollisions["http://10.0.0.1:8080/metrics"] = "app_up"
+
+	p.pruneTargetStats(time.Now())
+	if len(p.prefixCollisions) != 1 {
+		t.Errorf("expected the stats to be kept without WithLiveTargets, got %v", p.prefixCollisions)
+	}
+}
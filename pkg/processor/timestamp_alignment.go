@@ -0,0 +1,37 @@
+package processor
+
+import (
+	"open-agent/pkg/model"
+)
+
+// AlignmentDriftMetric is the scrape meta metric sent with every aligned scrape: how far the aligned
+// sample timestamp is from the scrape start, in seconds (negative when the scrape started just early)
+const AlignmentDriftMetric = "scrape_timestamp_alignment_drift_seconds"
+
+// scrapeTimestamp returns the timestamp of the samples of a scrape: the scrape-cycle boundary with
+// timestampAlignment: interval, the collection time otherwise. Samples with their own timestamp in the
+// exposition keep it either way.
+func scrapeTimestamp(rawData *model.ScrapeRawData) int64 {
+	if rawData.AlignInterval <= 0 {
+		return rawData.CollectionTime
+	}
+	return model.AlignTimestamp(rawData.CollectionTime, rawData.AlignInterval)
+}
+
+// stampScrapeTime replaces the timestamps the exposition carries with the scrape timestamp
+func stampScrapeTime(openMxList []*model.OpenMx, timestamp int64) {
+	for _, openMx := range openMxList {
+		openMx.Timestamp = timestamp
+	}
+}
+
+// alignmentDriftSample returns the drift meta metric of an aligned scrape, nil without alignment.
+// It records the alignment as a label so the choice is visible next to the target's series.
+func alignmentDriftSample(rawData *model.ScrapeRawData, timestamp int64) *model.OpenMx {
+	if rawData.AlignInterval <= 0 {
+		return nil
+	}
+	drift := model.NewOpenMx(AlignmentDriftMetric, timestamp, float64(rawData.CollectionTime-timestamp)/1000)
+	drift.AddLabel("alignment", model.TimestampAlignmentInterval)
+	return drift
+}
@@ -0,0 +1,114 @@
+package processor
+
+import (
+	"testing"
+	"time"
+
+	"open-agent/pkg/converter"
+	"open-agent/pkg/model"
+)
+
+const alignmentBody = `# TYPE http_requests_total counter
+http_requests_total{code="200"} 10
+http_requests_total{code="500"} 1
+process_start_time_seconds 1.7e9
+exposed_with_timestamp 1 1700000000123
+`
+
+// scrapeCycle converts one scrape the way processRawData does and returns its samples by metric and code
+func scrapeCycle(t *testing.T, rawData *model.ScrapeRawData) map[string]int64 {
+	t.Helper()
+	timestamp := scrapeTimestamp(rawData)
+	result, err := converter.ConvertWithOptions(rawData.RawData, "", timestamp, converter.ConvertOptions{})
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	stamps := make(map[string]int64)
+	for _, om := range result.GetOpenMxList() {
+		key := om.Metric
+		for _, label := range om.Labels {
+			key += "," + label.Value
+		}
+		stamps[key] = om.Timestamp
+	}
+	if drift := alignmentDriftSample(rawData, timestamp); drift != nil {
+		stamps[drift.Metric] = drift.Timestamp
+	}
+	return stamps
+}
+
+func TestTimestampAlignment_AcrossCycles(t *testing.T) {
+	interval := 30 * time.Second
+	boundary := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC).UnixMilli()
+	// Ragged scrape starts: late by a few seconds, then early by a few milliseconds of scheduling jitter
+	offsets := []time.Duration{2300 * time.Millisecond, 1100 * time.Millisecond, -40 * time.Millisecond, 28 * time.Second, 5 * time.Millisecond}
+
+	var prev int64
+	for i, offset := range offsets {
+		cycleBoundary := boundary + int64(i)*interval.Milliseconds()
+		start := cycleBoundary + offset.Milliseconds()
+		want := cycleBoundary
+		rawData := &model.ScrapeRawData{RawData: alignmentBody, CollectionTime: start, AlignInterval: interval}
+
+		stamps := scrapeCycle(t, rawData)
+		for key, ts := range stamps {
+			if key == "exposed_with_timestamp" {
+				if ts != 1700000000123 {
+					t.Errorf("cycle %d: expected the exposition timestamp to be kept, got %d", i, ts)
+				}
+				continue
+			}
+			if ts != want {
+				t.Errorf("cycle %d: %s stamped %d, want the boundary %d (start %d)", i, key, ts, want, start)
+			}
+		}
+		if i > 0 && want-prev != interval.Milliseconds() {
+			t.Errorf("cycle %d: expected consecutive cycles %v apart, got %dms", i, interval, want-prev)
+		}
+		prev = want
+
+		drift := alignmentDriftSample(rawData, scrapeTimestamp(rawData))
+		if got, want := drift.Value, offset.Seconds(); got != want {
+			t.Errorf("cycle %d: drift = %v, want %v", i, got, want)
+		}
+		if drift.Value < -1 || drift.Value >= interval.Seconds() {
+			t.Errorf("cycle %d: drift %v is out of bounds", i, drift.Value)
+		}
+	}
+}
+
+func TestTimestampAlignment_Disabled(t *testing.T) {
+	start := time.Date(2026, 10, 16, 9, 0, 2, 300e6, time.UTC).UnixMilli()
+	rawData := &model.ScrapeRawData{RawData: alignmentBody, CollectionTime: start}
+
+	stamps := scrapeCycle(t, rawData)
+	if _, ok := stamps[AlignmentDriftMetric]; ok {
+		t.Errorf("expected no drift meta metric without alignment")
+	}
+	if ts := stamps["process_start_time_seconds"]; ts != start {
+		t.Errorf("expected the collection time %d, got %d", start, ts)
+	}
+}
+
+func TestAlignTimestamp_JitterTolerance(t *testing.T) {
+	boundary := time.Date(2026, 10, 16, 9, 0, 30, 0, time.UTC).UnixMilli()
+	tests := []struct {
+		name     string
+		start    int64
+		interval time.Duration
+		want     int64
+	}{
+		{"on the boundary", boundary, 30 * time.Second, boundary},
+		{"just after", boundary + 1, 30 * time.Second, boundary},
+		{"1s early", boundary - 1000, 30 * time.Second, boundary},
+		{"over 1s early", boundary - 1001, 30 * time.Second, boundary - 30000},
+		{"short interval tolerates a tenth", boundary - 499, 5 * time.Second, boundary},
+		{"short interval, too early", boundary - 501, 5 * time.Second, boundary - 5000},
+		{"no interval", boundary + 123, 0, boundary + 123},
+	}
+	for _, tt := range tests {
+		if got := model.AlignTimestamp(tt.start, tt.interval); got != tt.want {
+			t.Errorf("%s: AlignTimestamp = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package processor
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"open-agent/pkg/converter"
+	"open-agent/tools/util/logutil"
+)
+
+// UnitConversionCount is the cumulative unitConversions result of one target
+type UnitConversionCount struct {
+	Target     string
+	Converted  int64    // samples whose value was scaled
+	Skipped    int64    // samples left unconverted because the renamed metric already exists
+	Collisions []string // renamed metric names of the last scrape that collided
+}
+
+var (
+	unitConversionMu     sync.Mutex
+	unitConversionCounts = make(map[string]*UnitConversionCount)
+)
+
+// recordUnitConversion adds one scrape's conversion result to the per-target counter
+// and logs the collision set whenever it changes
+func recordUnitConversion(target string, result converter.UnitConversionResult) {
+	unitConversionMu.Lock()
+	defer unitConversionMu.Unlock()
+
+	count, ok := unitConversionCounts[target]
+	if !ok {
+		count = &UnitConversionCount{Target: target}
+		unitConversionCounts[target] = count
+	}
+	count.Converted += int64(result.Converted)
+	count.Skipped += int64(result.Skipped)
+
+	if strings.Join(result.Collisions, ",") != strings.Join(count.Collisions, ",") {
+		count.Collisions = result.Collisions
+		if len(result.Collisions) > 0 {
+			logutil.Printf("WARN", "[PROCESSOR] unitConversions for target %s skipped metrics whose renamed name already exists: %s",
+				target, strings.Join(result.Collisions, ","))
+		}
+	}
+}
+
+// pruneUnitConversionCounts drops the counters of targets keep does not report
+func pruneUnitConversionCounts(keep func(target string) bool) {
+	unitConversionMu.Lock()
+	defer unitConversionMu.Unlock()
+
+	for target := range unitConversionCounts {
+		if !keep(target) {
+			delete(unitConversionCounts, target)
+		}
+	}
+}
+
+// UnitConversionCounts returns the unitConversions counters of all targets sorted by target
+func UnitConversionCounts() []UnitConversionCount {
+	unitConversionMu.Lock()
+	defer unitConversionMu.Unlock()
+
+	counts := make([]UnitConversionCount, 0, len(unitConversionCounts))
+	for _, count := range unitConversionCounts {
+		counts = append(counts, *count)
+	}
+	sort.Slice(counts, func(i, j int) bool { return counts[i].Target < counts[j].Target })
+	return counts
+}
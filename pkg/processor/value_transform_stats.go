@@ -0,0 +1,72 @@
+package processor
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+
+	"open-agent/pkg/model"
+)
+
+// ValueTransformCount is the cumulative number of samples one valueTransforms rule of a target changed
+type ValueTransformCount struct {
+	Target      string
+	Index       int    // position of the rule in valueTransforms
+	Rule        string // e.g. clampMin(0) ~ node_.*
+	Transformed int64
+}
+
+var (
+	valueTransformMu     sync.Mutex
+	valueTransformCounts = make(map[string]*ValueTransformCount)
+)
+
+// recordValueTransforms adds one scrape's per-rule counts to the per-target counters
+func recordValueTransforms(target string, rules model.ValueTransforms, transformed []int) {
+	valueTransformMu.Lock()
+	defer valueTransformMu.Unlock()
+
+	for i, n := range transformed {
+		rule := rules[i].String()
+		key := target + "\x00" + strconv.Itoa(i) + "\x00" + rule
+		count, ok := valueTransformCounts[key]
+		if !ok {
+			count = &ValueTransformCount{Target: target, Index: i, Rule: rule}
+			valueTransformCounts[key] = count
+		}
+		count.Transformed += int64(n)
+	}
+}
+
+// pruneValueTransformCounts drops the counters of targets keep does not report
+func pruneValueTransformCounts(keep func(target string) bool) {
+	valueTransformMu.Lock()
+	defer valueTransformMu.Unlock()
+
+	for key, count := range valueTransformCounts {
+		if !keep(count.Target) {
+			delete(valueTransformCounts, key)
+		}
+	}
+}
+
+// ValueTransformCounts returns the valueTransforms counters of all targets sorted by target and rule
+func ValueTransformCounts() []ValueTransformCount {
+	valueTransformMu.Lock()
+	defer valueTransformMu.Unlock()
+
+	counts := make([]ValueTransformCount, 0, len(valueTransformCounts))
+	for _, count := range valueTransformCounts {
+		counts = append(counts, *count)
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Target != counts[j].Target {
+			return counts[i].Target < counts[j].Target
+		}
+		if counts[i].Index != counts[j].Index {
+			return counts[i].Index < counts[j].Index
+		}
+		return counts[i].Rule < counts[j].Rule
+	})
+	return counts
+}
@@ -0,0 +1,93 @@
+package samplegen
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"open-agent/pkg/ingest"
+	"open-agent/pkg/model"
+)
+
+// Exposition renders series in the Prometheus text format, grouped by metric name in order of first
+// appearance, with the HELP and TYPE of the known sample metrics. Timestamps are left out, so the
+// agent stamps the samples with the time they were ingested.
+func Exposition(metrics []*model.OpenMx) string {
+	var names []string
+	families := make(map[string][]*model.OpenMx)
+	for _, om := range metrics {
+		if _, ok := families[om.Metric]; !ok {
+			names = append(names, om.Metric)
+		}
+		families[om.Metric] = append(families[om.Metric], om)
+	}
+
+	var b strings.Builder
+	for _, name := range names {
+		help := model.GetMetricHelp(name)
+		if help == "" {
+			help = fmt.Sprintf("Help information for %s", name)
+		}
+		metricType := model.GetMetricType(name)
+		if metricType == "" {
+			metricType = "gauge"
+		}
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", name, escapeHelp(help), name, metricType)
+
+		for _, om := range families[name] {
+			b.WriteString(name)
+			if len(om.Labels) > 0 {
+				labels := make([]string, 0, len(om.Labels))
+				for _, label := range om.Labels {
+					labels = append(labels, label.Key+`="`+escapeLabelValue(label.Value)+`"`)
+				}
+				sort.Strings(labels)
+				b.WriteString("{" + strings.Join(labels, ",") + "}")
+			}
+			b.WriteString(" " + strconv.FormatFloat(om.Value, 'g', -1, 64) + "\n")
+		}
+	}
+	return b.String()
+}
+
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func escapeHelp(s string) string       { return helpEscaper.Replace(s) }
+func escapeLabelValue(s string) string { return labelValueEscaper.Replace(s) }
+
+// Post sends an exposition to the ingest endpoint at url with the bearer token, as target with the labels
+func Post(client *http.Client, url, token, target string, labels map[string]string, exposition string) error {
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(exposition))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+	req.Header.Set("Authorization", "Bearer "+token)
+	if target != "" {
+		req.Header.Set(ingest.TargetHeader, target)
+	}
+	if len(labels) > 0 {
+		pairs := make([]string, 0, len(labels))
+		for k, v := range labels {
+			pairs = append(pairs, k+"="+v)
+		}
+		sort.Strings(pairs)
+		req.Header.Set(ingest.LabelsHeader, strings.Join(pairs, ","))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("ingest returned %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package samplegen
+
+import (
+	"testing"
+
+	"open-agent/pkg/converter"
+	"open-agent/pkg/model"
+)
+
+func TestExpositionRoundTrips(t *testing.T) {
+	metrics := NewGenerator().Metrics(1700000000000)
+	labeled := model.NewOpenMx("sample_labeled", 1700000000000, 1.5)
+	labeled.AddLabel("path", "/api/v1/users")
+	metrics = append(metrics, labeled)
+
+	result, err := converter.ConvertWithTimestamp(Exposition(metrics), 1700000000000)
+	if err != nil {
+		t.Fatalf("exposition does not parse: %v", err)
+	}
+	if got := len(result.GetOpenMxList()); got != len(metrics) {
+		t.Fatalf("parsed %d series, want %d", got, len(metrics))
+	}
+
+	var found bool
+	for _, om := range result.GetOpenMxList() {
+		if om.Metric != "sample_labeled" {
+			continue
+		}
+		found = true
+		if om.Value != 1.5 {
+			t.Errorf("value = %v, want 1.5", om.Value)
+		}
+		if len(om.Labels) != 1 || om.Labels[0].Value != "/api/v1/users" {
+			t.Errorf("labels = %+v, want path=%q", om.Labels, "/api/v1/users")
+		}
+	}
+	if !found {
+		t.Fatal("sample_labeled was not parsed")
+	}
+	if len(result.GetOpenMxHelpList()) == 0 {
+		t.Error("exposition has no HELP or TYPE lines")
+	}
+}
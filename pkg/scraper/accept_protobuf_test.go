@@ -0,0 +1,35 @@
+package scraper
+
+import (
+	"testing"
+
+	"open-agent/pkg/client"
+	"open-agent/pkg/discovery"
+)
+
+func TestScraperTask_AcceptProtobuf(t *testing.T) {
+	srv, rs := startRecordingServer(t)
+	sm := &ScraperManager{}
+
+	tests := []struct {
+		name     string
+		endpoint discovery.EndpointConfig
+		want     string
+	}{
+		{"default", discovery.EndpointConfig{Path: "/metrics"}, "application/json"},
+		{"acceptProtobuf", discovery.EndpointConfig{Path: "/metrics", AcceptProtobuf: true}, client.ProtobufAcceptHeader},
+		{"explicit header wins", discovery.EndpointConfig{Path: "/metrics", AcceptProtobuf: true, Headers: map[string]string{"Accept": "text/plain"}}, "text/plain"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := newUserAgentTarget(srv.URL, nil)
+			target.Metadata["endpoint"] = tt.endpoint
+			if _, err := sm.createScraperTaskFromTarget(target).Run(); err != nil {
+				t.Fatalf("scrape failed: %v", err)
+			}
+			if got := rs.lastHeader().Get("Accept"); got != tt.want {
+				t.Errorf("Accept = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
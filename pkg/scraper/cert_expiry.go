@@ -0,0 +1,29 @@
+package scraper
+
+import "time"
+
+// certRefreshInterval is how often an https target's connection is closed after a scrape. A kept-alive
+// connection keeps reporting the certificate of its handshake, so a renewed certificate is seen on the
+// next connection, within about this long.
+const certRefreshInterval = 10 * time.Minute
+
+// certRefreshDue reports whether the scrape at now (on the scrape clock) should close its connection,
+// so the next scrape handshakes again and reports the target's current certificate
+func (ts *TargetScheduler) certRefreshDue(now time.Duration) bool {
+	ts.statusMu.Lock()
+	defer ts.statusMu.Unlock()
+	return ts.certSeen && now-ts.certHandshakeAt >= certRefreshInterval
+}
+
+// observeCert records the certificate expiry a scrape reported, zero for plain http or a failed scrape.
+// The first certificate and a scrape that closed its connection start a new refresh period.
+func (ts *TargetScheduler) observeCert(notAfter time.Time, closed bool, now time.Duration) {
+	ts.statusMu.Lock()
+	defer ts.statusMu.Unlock()
+	if (!ts.certSeen && !notAfter.IsZero()) || (ts.certSeen && closed) {
+		ts.certHandshakeAt = now
+	}
+	if !notAfter.IsZero() {
+		ts.certSeen = true
+	}
+}
@@ -0,0 +1,93 @@
+package scraper
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"open-agent/pkg/config"
+	"open-agent/pkg/discovery"
+	"open-agent/pkg/discovery/discoverytest"
+	"open-agent/pkg/model"
+)
+
+func TestRunScraperTask_RefreshesCertificateConnection(t *testing.T) {
+	var conns atomic.Int64
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("up 1\n"))
+	}))
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	srv.StartTLS()
+	defer srv.Close()
+
+	endpoint := discovery.EndpointConfig{
+		Path:      "/metrics",
+		Interval:  "60s",
+		TLSConfig: map[string]interface{}{"insecureSkipVerify": true},
+	}
+	sd := discoverytest.New(discoverytest.Target("etcd", srv.URL+"/metrics", endpoint))
+	sm := NewScraperManager(&config.ConfigManager{}, sd, make(chan *model.ScrapeRawData, 10), "")
+	clock := &fakeClock{wall: time.Now()}
+	sm.clock = clock
+	defer sm.Stop()
+	sm.updateTargetSchedulers()
+	defer sm.stopAllSchedulers()
+	scheduler := schedulerFor(sm, "etcd")
+
+	scrape := func() {
+		t.Helper()
+		rawData, err := sm.runScraperTask(scheduler, scheduler.getTarget(), 5*time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !rawData.CertNotAfter.Equal(srv.Certificate().NotAfter) {
+			t.Errorf("CertNotAfter = %v, want %v", rawData.CertNotAfter, srv.Certificate().NotAfter)
+		}
+	}
+
+	// Scrapes within the refresh interval reuse the connection
+	for i := 0; i < 10; i++ {
+		scrape()
+		clock.advance(time.Minute, 0)
+	}
+	if n := conns.Load(); n != 1 {
+		t.Fatalf("expected one connection in the first %v, got %d", certRefreshInterval, n)
+	}
+
+	// The scrape at the refresh interval closes its connection, the next one handshakes again
+	scrape()
+	clock.advance(time.Minute, 0)
+	scrape()
+	if n := conns.Load(); n != 2 {
+		t.Errorf("expected a new connection after %v, got %d connections", certRefreshInterval, n)
+	}
+}
+
+func TestCertRefreshDue_OnlyForHTTPSTargets(t *testing.T) {
+	scheduler := &TargetScheduler{}
+	scheduler.observeCert(time.Time{}, false, 0)
+	if scheduler.certRefreshDue(time.Hour) {
+		t.Errorf("expected no refresh for a target without a certificate")
+	}
+
+	scheduler.observeCert(time.Now(), false, time.Hour)
+	if scheduler.certRefreshDue(time.Hour + certRefreshInterval - time.Second) {
+		t.Errorf("expected no refresh before the interval")
+	}
+	// A failed scrape keeps the refresh period
+	scheduler.observeCert(time.Time{}, false, time.Hour+time.Minute)
+	if !scheduler.certRefreshDue(time.Hour + certRefreshInterval) {
+		t.Errorf("expected a refresh after the interval")
+	}
+	scheduler.observeCert(time.Now(), true, time.Hour+certRefreshInterval)
+	if scheduler.certRefreshDue(time.Hour + certRefreshInterval + time.Minute) {
+		t.Errorf("expected the closed connection to start a new refresh period")
+	}
+}
@@ -0,0 +1,31 @@
+package scraper
+
+import "time"
+
+// scrapeClock is the clock of the scrape loop. Intervals, deviations and skips are measured with
+// Elapsed, which follows the monotonic clock, so an NTP step of the node's wall clock neither shortens
+// nor stretches them. Wall time is only used for timestamps placed on samples and shown in status.
+type scrapeClock interface {
+	// Elapsed returns the monotonic time since the clock was created
+	Elapsed() time.Duration
+	// Now returns the wall-clock time
+	Now() time.Time
+}
+
+// systemClock reads the process clocks. Elapsed is a duration rather than a time.Time, so it cannot
+// lose its monotonic reading when it is copied, rounded or compared with a wall-clock time.
+type systemClock struct {
+	start time.Time
+}
+
+func newSystemClock() systemClock {
+	return systemClock{start: time.Now()}
+}
+
+func (c systemClock) Elapsed() time.Duration {
+	return time.Since(c.start)
+}
+
+func (c systemClock) Now() time.Time {
+	return time.Now()
+}
@@ -0,0 +1,91 @@
+package scraper
+
+import (
+	"testing"
+	"time"
+
+	"open-agent/pkg/config"
+	"open-agent/pkg/discovery"
+	"open-agent/pkg/discovery/discoverytest"
+	"open-agent/pkg/model"
+)
+
+// fakeClock advances the monotonic and wall clocks separately, so tests can step the wall clock
+type fakeClock struct {
+	elapsed time.Duration
+	wall    time.Time
+}
+
+func (c *fakeClock) Elapsed() time.Duration { return c.elapsed }
+func (c *fakeClock) Now() time.Time         { return c.wall }
+
+// advance moves both clocks forward by d, then steps the wall clock by step as an NTP correction would
+func (c *fakeClock) advance(d, step time.Duration) {
+	c.elapsed += d
+	c.wall = c.wall.Add(d + step)
+}
+
+func newClockTestManager(clock scrapeClock) (*ScraperManager, *discovery.Target) {
+	target := discoverytest.Target("static/app/10.0.0.1:9100", "http://10.0.0.1:9100/metrics",
+		discovery.EndpointConfig{Interval: "60s"})
+	sm := NewScraperManager(&config.ConfigManager{}, discoverytest.New(target), make(chan *model.ScrapeRawData, 1), "")
+	sm.clock = clock
+	return sm, target
+}
+
+func TestScrapeClock_WallClockStepBackwards(t *testing.T) {
+	clock := &fakeClock{wall: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+	sm, target := newClockTestManager(clock)
+	sm.updateLastScrapingTime(target)
+
+	// The wall clock steps back 30s halfway through the interval
+	clock.advance(30*time.Second, -30*time.Second)
+	if !sm.shouldSkipScraping(target, time.Minute) {
+		t.Errorf("expected the scrape to be skipped 30s into a 60s interval")
+	}
+
+	// One interval later on the monotonic clock the scrape is due, though the wall clock shows only 30s
+	clock.advance(30*time.Second, 0)
+	if sm.shouldSkipScraping(target, time.Minute) {
+		t.Errorf("expected the scrape to be due after one interval")
+	}
+	if deviation, ok := sm.intervalDeviation(target); !ok || deviation != 0 {
+		t.Errorf("expected no interval deviation, got %v (ok=%v)", deviation, ok)
+	}
+	sm.updateLastScrapingTime(target)
+
+	// The next interval is measured from the previous scrape, not from the stepped wall time
+	clock.advance(time.Minute, 0)
+	if deviation, _ := sm.intervalDeviation(target); deviation != 0 {
+		t.Errorf("expected no interval deviation after the step, got %v", deviation)
+	}
+}
+
+func TestScrapeClock_WallClockStepForward(t *testing.T) {
+	clock := &fakeClock{wall: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+	sm, target := newClockTestManager(clock)
+	sm.updateLastScrapingTime(target)
+
+	// A forward step of the wall clock does not make the next scrape due early
+	clock.advance(10*time.Second, time.Hour)
+	if !sm.shouldSkipScraping(target, time.Minute) {
+		t.Errorf("expected no double scrape after the wall clock jumped forward")
+	}
+	clock.advance(50*time.Second, 0)
+	if deviation, _ := sm.intervalDeviation(target); deviation != 0 {
+		t.Errorf("expected no interval deviation, got %v", deviation)
+	}
+
+	// Old entries are cleaned up by the monotonic clock too
+	sm.discovery = discoverytest.New()
+	clock.advance(30*time.Minute, -24*time.Hour)
+	sm.cleanupOldTargets()
+	if _, ok := sm.intervalDeviation(target); !ok {
+		t.Errorf("expected the entry to be kept until an hour has passed")
+	}
+	clock.advance(31*time.Minute, 0)
+	sm.cleanupOldTargets()
+	if _, ok := sm.intervalDeviation(target); ok {
+		t.Errorf("expected the entry to be removed an hour after the last scrape")
+	}
+}
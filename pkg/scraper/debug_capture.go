@@ -0,0 +1,142 @@
+package scraper
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"open-agent/pkg/diagnostics"
+	"open-agent/tools/util/logutil"
+)
+
+// TargetsHandler serves the per-target admin actions under /targets/{id}/:
+// pause and resume (see PauseHandler) and debug (see DebugHandler)
+func (sm *ScraperManager) TargetsHandler() http.Handler {
+	pause, debug, cardinality := sm.PauseHandler(), sm.DebugHandler(), sm.CardinalityHandler()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/debug") {
+			debug.ServeHTTP(w, r)
+			return
+		}
+		if strings.HasSuffix(r.URL.Path, "/cardinality") {
+			cardinality.ServeHTTP(w, r)
+			return
+		}
+		pause.ServeHTTP(w, r)
+	})
+}
+
+// DebugHandler captures the processed samples of a target for debugging:
+//
+//	POST   /targets/{id}/debug?samples=100&duration=5m  starts capturing the first samples of each scrape
+//	GET    /targets/{id}/debug                          returns the captured scrapes
+//	DELETE /targets/{id}/debug                          stops the capture and discards it
+//
+// Captured samples are the records the processor emits after relabeling; interpolated credentials are redacted.
+func (sm *ScraperManager) DebugHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		targetID := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/targets/"), "/debug")
+		if targetID == "" || targetID == r.URL.Path {
+			http.NotFound(w, r)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodPost:
+			samples, duration, err := parseCaptureParams(r)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if !sm.HasTarget(targetID) {
+				http.Error(w, fmt.Sprintf("%v: %s", ErrUnknownTarget, targetID), http.StatusNotFound)
+				return
+			}
+			until := diagnostics.Samples.Enable(targetID, samples, duration)
+			logutil.Printf("INFO", "[SCRAPER] Capturing samples of target %s until %s", targetID, until.Format(time.RFC3339))
+			fmt.Fprintf(w, "capturing samples of %s until %s\n", targetID, until.Format(time.RFC3339))
+		case http.MethodGet:
+			status, ok := diagnostics.Samples.Status(targetID)
+			if !ok {
+				http.Error(w, fmt.Sprintf("no sample capture for target: %s", targetID), http.StatusNotFound)
+				return
+			}
+			var text strings.Builder
+			status.WriteText(&text)
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			_, _ = w.Write([]byte(sm.redact(text.String())))
+		case http.MethodDelete:
+			if !diagnostics.Samples.Forget(targetID) {
+				http.Error(w, fmt.Sprintf("no sample capture for target: %s", targetID), http.StatusNotFound)
+				return
+			}
+			fmt.Fprintf(w, "stopped capturing samples of %s\n", targetID)
+		default:
+			w.Header().Set("Allow", "GET, POST, DELETE")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+// parseCaptureParams reads the optional samples and duration query parameters
+func parseCaptureParams(r *http.Request) (int, time.Duration, error) {
+	var samples int
+	if value := r.URL.Query().Get("samples"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			return 0, 0, fmt.Errorf("invalid samples %q", value)
+		}
+		samples = parsed
+	}
+	var duration time.Duration
+	if value := r.URL.Query().Get("duration"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			return 0, 0, fmt.Errorf("invalid duration %q", value)
+		}
+		duration = parsed
+	}
+	return samples, duration, nil
+}
+
+// HasTarget reports whether a scheduler runs for the target
+func (sm *ScraperManager) HasTarget(targetID string) bool {
+	sm.schedulerMutex.RLock()
+	defer sm.schedulerMutex.RUnlock()
+	_, exists := sm.targetSchedulers[targetID]
+	return exists
+}
+
+// redact replaces interpolated credentials, e.g. a token copied into a label by relabeling
+func (sm *ScraperManager) redact(s string) string {
+	if sm.configManager == nil {
+		return s
+	}
+	return sm.configManager.Redact(s)
+}
+
+// CardinalityHandler serves GET /targets/{id}/cardinality: the samples, estimated series and top
+// metric names of the target's last complete minute, to help write metricRelabelConfigs drop rules
+func (sm *ScraperManager) CardinalityHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		targetID := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/targets/"), "/cardinality")
+		if targetID == "" || targetID == r.URL.Path {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		summary, ok := diagnostics.Cardinality.Summary(targetID)
+		if !ok {
+			http.Error(w, fmt.Sprintf("no processed samples for target: %s", targetID), http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		summary.WriteText(w)
+	})
+}
@@ -0,0 +1,118 @@
+package scraper
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"open-agent/pkg/config"
+	"open-agent/pkg/diagnostics"
+	"open-agent/pkg/model"
+)
+
+func debugRequest(sm *ScraperManager, method, path string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	sm.TargetsHandler().ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+	return rec
+}
+
+func TestDebugHandler_CaptureAndRetrieve(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("WHATAP_OPEN_HOME", dir)
+	t.Setenv("DEBUG_CAPTURE_TOKEN", "s3cr3t-token")
+	scrapeConfig := `
+features:
+  openAgent:
+    enabled: true
+    targets:
+      - targetName: app
+        type: StaticEndpoints
+        endpoints:
+          - address: "127.0.0.1:1"
+            basicAuth:
+              password: "${DEBUG_CAPTURE_TOKEN}"
+`
+	if err := os.WriteFile(filepath.Join(dir, "scrape_config.yaml"), []byte(scrapeConfig), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	sm := newPauseTestManager(t)
+	sm.configManager = &config.ConfigManager{}
+	if err := sm.configManager.LoadConfig(); err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	defer diagnostics.Samples.Forget(pausedTargetID)
+
+	if rec := debugRequest(sm, http.MethodPost, "/targets/unknown/debug"); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown target: expected 404, got %d", rec.Code)
+	}
+	if rec := debugRequest(sm, http.MethodPost, "/targets/"+pausedTargetID+"/debug?samples=many"); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid samples: expected 400, got %d", rec.Code)
+	}
+	if rec := debugRequest(sm, http.MethodGet, "/targets/"+pausedTargetID+"/debug"); rec.Code != http.StatusNotFound {
+		t.Errorf("GET before enabling: expected 404, got %d", rec.Code)
+	}
+
+	rec := debugRequest(sm, http.MethodPost, "/targets/"+pausedTargetID+"/debug?samples=1&duration=1m")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("enable: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// A relabeled label carrying the interpolated password must not be shown
+	leaked := model.NewOpenMx("app_info", 1700000000000, 1)
+	leaked.AddLabel("auth", "s3cr3t-token")
+	diagnostics.Samples.Record(pausedTargetID, []*model.OpenMx{leaked, model.NewOpenMx("up", 1700000000000, 1)})
+
+	rec = debugRequest(sm, http.MethodGet, "/targets/"+pausedTargetID+"/debug")
+	body := rec.Body.String()
+	if rec.Code != http.StatusOK || !strings.Contains(body, "1 of 2 samples") || !strings.Contains(body, `app_info{auth="<redacted>"} 1`) {
+		t.Fatalf("unexpected capture (%d): %s", rec.Code, body)
+	}
+	if strings.Contains(body, "s3cr3t-token") {
+		t.Fatalf("captured samples leaked a credential: %s", body)
+	}
+
+	if rec := debugRequest(sm, http.MethodDelete, "/targets/"+pausedTargetID+"/debug"); rec.Code != http.StatusOK {
+		t.Errorf("delete: expected 200, got %d", rec.Code)
+	}
+	if rec := debugRequest(sm, http.MethodGet, "/targets/"+pausedTargetID+"/debug"); rec.Code != http.StatusNotFound {
+		t.Errorf("GET after delete: expected 404, got %d", rec.Code)
+	}
+
+	// Pause actions are still routed to the pause handler
+	if rec := debugRequest(sm, http.MethodPost, "/targets/"+pausedTargetID+"/pause"); rec.Code != http.StatusOK {
+		t.Errorf("pause: expected 200, got %d", rec.Code)
+	}
+}
+
+func TestCardinalityHandler(t *testing.T) {
+	sm := newPauseTestManager(t)
+	const targetID = "app/cardinality-test"
+
+	if rec := debugRequest(sm, http.MethodGet, "/targets/"+targetID+"/cardinality"); rec.Code != http.StatusNotFound {
+		t.Errorf("before any scrape: expected 404, got %d", rec.Code)
+	}
+
+	metrics := []*model.OpenMx{
+		model.NewOpenMx("http_requests_total", 0, 1),
+		model.NewOpenMx("http_requests_total", 0, 2),
+		model.NewOpenMx("up", 0, 1),
+	}
+	metrics[1].AddLabel("code", "500")
+	diagnostics.Cardinality.Record(targetID, metrics)
+
+	rec := debugRequest(sm, http.MethodGet, "/targets/"+targetID+"/cardinality")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	for _, want := range []string{"samples 3\n", "series_estimate 3\n", "http_requests_total 2\n", "up 1\n"} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("expected %q in:\n%s", want, rec.Body.String())
+		}
+	}
+	if rec := debugRequest(sm, http.MethodPost, "/targets/"+targetID+"/cardinality"); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST: expected 405, got %d", rec.Code)
+	}
+}
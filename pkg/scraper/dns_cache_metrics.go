@@ -0,0 +1,60 @@
+package scraper
+
+import (
+	"time"
+
+	"open-agent/pkg/client"
+	"open-agent/pkg/model"
+	"open-agent/tools/util/logutil"
+)
+
+// Self-metric names for the scrape DNS cache counters
+const (
+	MetricDNSCacheHits      = "openagent_dns_cache_hits_total"
+	MetricDNSCacheMisses    = "openagent_dns_cache_misses_total"
+	MetricDNSCacheEvictions = "openagent_dns_cache_evictions_total"
+)
+
+// dnsCacheResult returns the scrape DNS cache counters as self-metric series, or nil before the first lookup
+func dnsCacheResult(now int64, hits, misses, evictions int64) *model.ConversionResult {
+	if hits == 0 && misses == 0 {
+		return nil
+	}
+
+	counters := []struct {
+		name  string
+		value int64
+		help  string
+	}{
+		{MetricDNSCacheHits, hits, "Scrape host lookups answered from the DNS cache"},
+		{MetricDNSCacheMisses, misses, "Scrape host lookups sent to the resolver"},
+		{MetricDNSCacheEvictions, evictions, "DNS cache entries dropped because they expired or the cache was full"},
+	}
+	series := make([]*model.OpenMx, 0, len(counters))
+	helps := make([]*model.OpenMxHelp, 0, len(counters))
+	for _, counter := range counters {
+		series = append(series, model.NewOpenMx(counter.name, now, float64(counter.value)))
+		help := model.NewOpenMxHelp(counter.name)
+		help.Put("help", counter.help)
+		help.Put("type", "counter")
+		helps = append(helps, help)
+	}
+
+	result := model.NewConversionResult(series, helps)
+	result.SetCollectionTime(now)
+	return result
+}
+
+// sendDNSCacheStats queues the scrape DNS cache counters
+func (sm *ScraperManager) sendDNSCacheStats() {
+	hits, misses, evictions := client.DNSCacheStats()
+	result := dnsCacheResult(time.Now().UnixMilli(), hits, misses, evictions)
+	if result == nil {
+		return
+	}
+	select {
+	case sm.selfMetricsQueue <- result:
+	default:
+		logutil.Printf("WARN", "[SCRAPER] Processed queue is full, dropping DNS cache counters")
+	}
+}
@@ -0,0 +1,100 @@
+package scraper
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"open-agent/pkg/config"
+	"open-agent/pkg/discovery"
+	"open-agent/pkg/model"
+)
+
+// duplicateTargetsConfig has two entries sharing targetName "node-exporter"
+const duplicateTargetsConfig = `
+features:
+  openAgent:
+    enabled: true
+    targets:
+      - targetName: node-exporter
+        type: StaticEndpoints
+        endpoints:
+          - address: "127.0.0.1:1"
+            interval: "60s"
+      - targetName: kube-state-metrics
+        type: StaticEndpoints
+        endpoints:
+          - address: "127.0.0.1:2"
+            interval: "60s"
+      - targetName: node-exporter
+        type: StaticEndpoints
+        endpoints:
+          - address: "127.0.0.1:3"
+            interval: "60s"
+`
+
+// TestDuplicateTargetNames_OneSchedulerPerID is a regression test for duplicate targetName
+// entries producing colliding target IDs whose schedulers overwrite each other.
+func TestDuplicateTargetNames_OneSchedulerPerID(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "scrape_config.yaml"), []byte(duplicateTargetsConfig), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	t.Setenv("WHATAP_OPEN_HOME", dir)
+
+	cm := &config.ConfigManager{}
+	if err := cm.LoadConfig(); err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+
+	sd := discovery.NewServiceDiscovery(cm)
+	if err := sd.LoadTargets(cm.GetTargetConfigs()); err != nil {
+		t.Fatalf("load targets: %v", err)
+	}
+	if err := sd.Start(context.Background()); err != nil {
+		t.Fatalf("start discovery: %v", err)
+	}
+	defer sd.Stop()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(sd.GetReadyTargets()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	targets := sd.GetReadyTargets()
+	if len(targets) != 2 {
+		t.Fatalf("expected 2 targets (one per unique targetName), got %d", len(targets))
+	}
+
+	sm := NewScraperManager(cm, sd, make(chan *model.ScrapeRawData, 10), "")
+	sm.updateTargetSchedulers()
+	defer sm.Stop()
+
+	sm.schedulerMutex.RLock()
+	defer sm.schedulerMutex.RUnlock()
+	if len(sm.targetSchedulers) != len(targets) {
+		t.Fatalf("expected %d schedulers, got %d", len(targets), len(sm.targetSchedulers))
+	}
+	for id, scheduler := range sm.targetSchedulers {
+		target := scheduler.getTarget()
+		if target.ID != id {
+			t.Errorf("scheduler %s holds target %s", id, target.ID)
+		}
+		// The first occurrence wins
+		if target.Metadata["targetName"] == "node-exporter" && target.Metadata["address"] != "127.0.0.1:1" {
+			t.Errorf("expected first node-exporter entry to be kept, got address %v", target.Metadata["address"])
+		}
+	}
+}
+
+func TestFindDuplicateTargetNames(t *testing.T) {
+	targets := []map[string]interface{}{
+		{"targetName": "a"}, {"targetName": "b"}, {"targetName": "a"}, {"targetName": "a"}, {"targetName": "b"}, {"targetName": "c"},
+	}
+	got := discovery.FindDuplicateTargetNames(targets)
+	if len(got) != 2 || got[0] != "a" || got[1] != "b" {
+		t.Fatalf("expected [a b], got %v", got)
+	}
+}
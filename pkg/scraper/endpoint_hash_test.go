@@ -0,0 +1,107 @@
+package scraper
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"open-agent/pkg/config"
+	"open-agent/pkg/discovery"
+)
+
+// paramsTargetConfig has several params, including array values whose order matters
+const paramsTargetConfig = `
+features:
+  openAgent:
+    enabled: true
+    targets:
+      - targetName: azure-exporter
+        type: StaticEndpoints
+        endpoints:
+          - address: "127.0.0.1:1"
+            path: /probe/metrics/list
+            interval: "60s"
+            params:
+              name: ["azure-metric"]
+              template: ["{name}_{metric}_{aggregation}_{unit}"]
+              metric: ["TotalRequests", "SuccessE2ELatency", "Availability", "UsedCapacity"]
+              aggregation: ["average", "total"]
+              subscription: "00000000-0000-0000-0000-000000000000"
+              cache: 60
+            tlsConfig:
+              insecureSkipVerify: true
+`
+
+// discoverOnce loads the config file, runs one discovery and returns the ready target
+func discoverOnce(t *testing.T) *discovery.Target {
+	t.Helper()
+	cm := &config.ConfigManager{}
+	if err := cm.LoadConfig(); err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	sd := discovery.NewServiceDiscovery(cm)
+	if err := sd.LoadTargets(cm.GetTargetConfigs()); err != nil {
+		t.Fatalf("load targets: %v", err)
+	}
+	if err := sd.Start(context.Background()); err != nil {
+		t.Fatalf("start discovery: %v", err)
+	}
+	defer sd.Stop()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(sd.GetReadyTargets()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	targets := sd.GetReadyTargets()
+	if len(targets) != 1 {
+		t.Fatalf("expected 1 target, got %d", len(targets))
+	}
+	return targets[0]
+}
+
+// TestEndpointHashStableAcrossReparses is a regression test for identical configs producing different
+// endpoint hashes between discovery cycles, which replaced the target's scheduler on every cycle
+func TestEndpointHashStableAcrossReparses(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "scrape_config.yaml"), []byte(paramsTargetConfig), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	t.Setenv("WHATAP_OPEN_HOME", dir)
+
+	sm := &ScraperManager{}
+	first := discoverOnce(t)
+	hash := sm.calculateEndpointHash(first.Metadata["endpoint"])
+	// Array values are joined in their YAML order
+	want := "https://127.0.0.1:1/probe/metrics/list?aggregation=average%2Ctotal&cache=60" +
+		"&metric=TotalRequests%2CSuccessE2ELatency%2CAvailability%2CUsedCapacity&name=azure-metric" +
+		"&subscription=00000000-0000-0000-0000-000000000000&template=%7Bname%7D_%7Bmetric%7D_%7Baggregation%7D_%7Bunit%7D"
+	if first.URL != want {
+		t.Fatalf("URL = %s\nwant %s", first.URL, want)
+	}
+
+	changes := 0
+	for i := 0; i < 100; i++ {
+		target := discoverOnce(t)
+		if sm.hasEndpointChanged(first, target) || target.URL != first.URL {
+			changes++
+		}
+	}
+	if changes != 0 {
+		t.Errorf("expected no endpoint changes for an identical config, got %d of 100 (hash %s)", changes, hash[:8])
+	}
+}
+
+func TestEndpointHashDetectsParamChanges(t *testing.T) {
+	sm := &ScraperManager{}
+	old := discovery.EndpointConfig{Params: map[string]interface{}{"metric": []interface{}{"a", "b"}}}
+	reordered := discovery.EndpointConfig{Params: map[string]interface{}{"metric": []interface{}{"b", "a"}}}
+	same := discovery.EndpointConfig{Params: map[string]interface{}{"metric": []interface{}{"a", "b"}}}
+	if sm.calculateEndpointHash(old) != sm.calculateEndpointHash(same) {
+		t.Errorf("expected equal params to hash the same")
+	}
+	if sm.calculateEndpointHash(old) == sm.calculateEndpointHash(reordered) {
+		t.Errorf("expected reordered array values, which change the joined value, to change the hash")
+	}
+}
@@ -0,0 +1,59 @@
+package scraper
+
+import (
+	"time"
+
+	"open-agent/pkg/model"
+	"open-agent/tools/util/logutil"
+)
+
+const (
+	// rawQueueEnqueueTimeout bounds how long a scrape waits for room in the raw queue before its result is dropped
+	rawQueueEnqueueTimeout = 250 * time.Millisecond
+
+	// dropLogInterval rate-limits the WARN log for dropped scrape results
+	dropLogInterval = time.Minute
+)
+
+// enqueueRawData puts a scrape result on the raw queue. When the queue stays full for
+// rawQueueEnqueueTimeout the result is dropped and counted for the target, so a slow processor
+// does not hold up the target's next scrapes.
+func (sm *ScraperManager) enqueueRawData(scheduler *TargetScheduler, targetID string, rawData *model.ScrapeRawData) bool {
+	select {
+	case sm.rawQueue <- rawData:
+		return true
+	default:
+	}
+
+	timer := time.NewTimer(rawQueueEnqueueTimeout)
+	defer timer.Stop()
+	select {
+	case sm.rawQueue <- rawData:
+		return true
+	case <-timer.C:
+	case <-sm.stopCh:
+	case <-scheduler.reaped():
+	}
+
+	scheduler.droppedScrapes.Add(1)
+	sm.logDroppedScrape(targetID)
+	return false
+}
+
+// logDroppedScrape logs dropped scrape results at most once per dropLogInterval
+func (sm *ScraperManager) logDroppedScrape(targetID string) {
+	sm.dropMu.Lock()
+	sm.droppedSinceLog++
+	now := time.Now()
+	if !sm.lastDropLog.IsZero() && now.Sub(sm.lastDropLog) < dropLogInterval {
+		sm.dropMu.Unlock()
+		return
+	}
+	dropped := sm.droppedSinceLog
+	sm.droppedSinceLog = 0
+	sm.lastDropLog = now
+	sm.dropMu.Unlock()
+
+	logutil.Printf("WARN", "[SCRAPER] Raw queue is full (%d/%d): dropped %d scrape results in the last %v, latest from target %s",
+		len(sm.rawQueue), cap(sm.rawQueue), dropped, dropLogInterval, targetID)
+}
@@ -0,0 +1,41 @@
+package scraper
+
+import (
+	"testing"
+	"time"
+
+	"open-agent/pkg/config"
+	"open-agent/pkg/discovery"
+	"open-agent/pkg/discovery/discoverytest"
+	"open-agent/pkg/model"
+)
+
+// TestScheduler_KeepsTickingWithFullQueue verifies that a full raw queue drops scrape results
+// instead of blocking the scrape, so the target keeps its interval
+func TestScheduler_KeepsTickingWithFullQueue(t *testing.T) {
+	exporter := discoverytest.NewExporter("up 1\n")
+	defer exporter.Close()
+
+	// Nothing consumes the queue, and it is already full
+	rawQueue := make(chan *model.ScrapeRawData, 1)
+	rawQueue <- &model.ScrapeRawData{}
+
+	sd := discoverytest.New(discoverytest.Target("full-queue", exporter.URL(), discovery.EndpointConfig{Path: "/metrics", Interval: "1s"}))
+	sm := NewScraperManager(&config.ConfigManager{}, sd, rawQueue, "")
+	defer sm.Stop()
+	sm.updateTargetSchedulers()
+	defer sm.stopAllSchedulers()
+
+	time.Sleep(3500 * time.Millisecond)
+
+	if n := exporter.Requests(); n < 3 {
+		t.Fatalf("expected the scheduler to keep scraping every second, got %d scrapes", n)
+	}
+	states := sm.GetSchedulerStates()
+	if len(states) != 1 || states[0].Dropped < 3 {
+		t.Fatalf("expected at least 3 dropped scrape results, got %+v", states)
+	}
+	if len(rawQueue) != 1 {
+		t.Errorf("queue should still hold only the original item, got %d", len(rawQueue))
+	}
+}
@@ -0,0 +1,98 @@
+package scraper
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"open-agent/tools/util/logutil"
+)
+
+// scrapeFailureLogInterval is how often a target that keeps failing with the same error is logged again
+const scrapeFailureLogInterval = 10 * time.Minute
+
+// scrapeFailureState is the failure streak of one target
+type scrapeFailureState struct {
+	err        string    // error of the last logged failure
+	lastLogged time.Time // when a failure was last logged
+	suppressed int       // identical failures not logged since lastLogged
+	failures   int       // consecutive failures
+}
+
+// scrapeFailureLog logs scrape failures per target without flooding the log: the first failure is logged
+// at ERROR, identical failures after it once per scrapeFailureLogInterval with how often they repeated,
+// a different error right away, and recovery at INFO.
+// It is keyed by target ID, since logutil's own interval suppression is keyed by log id only.
+type scrapeFailureLog struct {
+	mu      sync.Mutex
+	targets map[string]*scrapeFailureState
+	now     func() time.Time
+	log     func(level, message string)
+}
+
+func newScrapeFailureLog() *scrapeFailureLog {
+	return &scrapeFailureLog{
+		targets: make(map[string]*scrapeFailureState),
+		now:     time.Now,
+		log:     printScrapeFailure,
+	}
+}
+
+func printScrapeFailure(level, message string) {
+	if level == "ERROR" {
+		logutil.Errorf("ERROR", "%s", message)
+		return
+	}
+	logutil.Printf(level, "%s", message)
+}
+
+// failure logs a failed scrape of a target unless an identical failure was logged recently.
+// err identifies the failure; message is the line to log.
+func (l *scrapeFailureLog) failure(targetID string, err error, message string) {
+	now := l.now()
+
+	l.mu.Lock()
+	state, exists := l.targets[targetID]
+	if !exists {
+		state = &scrapeFailureState{}
+		l.targets[targetID] = state
+	}
+	state.failures++
+	switch {
+	case !exists || state.err != err.Error():
+		state.err = err.Error()
+		state.lastLogged = now
+		state.suppressed = 0
+	case now.Sub(state.lastLogged) >= scrapeFailureLogInterval:
+		message = fmt.Sprintf("%s (repeated %d times in %v)", message, state.suppressed+1, now.Sub(state.lastLogged).Round(time.Second))
+		state.lastLogged = now
+		state.suppressed = 0
+	default:
+		state.suppressed++
+		message = ""
+	}
+	l.mu.Unlock()
+
+	if message != "" {
+		l.log("ERROR", message)
+	}
+}
+
+// success logs the recovery of a target that was failing
+func (l *scrapeFailureLog) success(targetID, url string) {
+	l.mu.Lock()
+	state, exists := l.targets[targetID]
+	delete(l.targets, targetID)
+	l.mu.Unlock()
+
+	if exists {
+		l.log("INFO", fmt.Sprintf("[SCRAPER] Target %s (%s) recovered after %d consecutive failures", targetID, url, state.failures))
+	}
+}
+
+// forget drops the failure streak of a target that is no longer scraped
+func (l *scrapeFailureLog) forget(targetID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.targets, targetID)
+}
@@ -0,0 +1,88 @@
+package scraper
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type loggedLine struct {
+	level, message string
+}
+
+// newTestFailureLog returns a scrapeFailureLog recording its lines, with a settable clock
+func newTestFailureLog() (*scrapeFailureLog, *[]loggedLine, *time.Time) {
+	var lines []loggedLine
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := newScrapeFailureLog()
+	l.now = func() time.Time { return now }
+	l.log = func(level, message string) { lines = append(lines, loggedLine{level, message}) }
+	return l, &lines, &now
+}
+
+func TestScrapeFailureLog_SuppressesRepeats(t *testing.T) {
+	l, lines, now := newTestFailureLog()
+	refused := errors.New("connection refused")
+
+	// A 30s target failing for 15 minutes: the first failure and one summary after 10 minutes are logged
+	for i := 0; i < 30; i++ {
+		l.failure("app/default/api-0/8080-metrics", refused, "Error scraping target app/default/api-0/8080-metrics: connection refused")
+		*now = now.Add(30 * time.Second)
+	}
+	if len(*lines) != 2 {
+		t.Fatalf("expected 2 logged lines, got %d: %v", len(*lines), *lines)
+	}
+	if (*lines)[0].level != "ERROR" || strings.Contains((*lines)[0].message, "repeated") {
+		t.Errorf("expected the first failure logged as is, got %+v", (*lines)[0])
+	}
+	if !strings.HasSuffix((*lines)[1].message, "(repeated 20 times in 10m0s)") {
+		t.Errorf("expected the repeat count of the last 10 minutes, got %q", (*lines)[1].message)
+	}
+
+	// Recovery is logged once at INFO with the streak length
+	l.success("app/default/api-0/8080-metrics", "http://10.0.0.1:8080/metrics")
+	l.success("app/default/api-0/8080-metrics", "http://10.0.0.1:8080/metrics")
+	if len(*lines) != 3 || (*lines)[2].level != "INFO" || !strings.Contains((*lines)[2].message, "recovered after 30 consecutive failures") {
+		t.Fatalf("expected one recovery line, got %v", *lines)
+	}
+
+	// A failure after recovery is logged right away
+	l.failure("app/default/api-0/8080-metrics", refused, "Error scraping target: connection refused")
+	if len(*lines) != 4 {
+		t.Errorf("expected a new streak to log immediately, got %v", *lines)
+	}
+}
+
+func TestScrapeFailureLog_DifferentErrorLogsImmediately(t *testing.T) {
+	l, lines, now := newTestFailureLog()
+
+	l.failure("a", errors.New("connection refused"), "refused")
+	l.failure("a", errors.New("connection refused"), "refused")
+	*now = now.Add(time.Minute)
+	l.failure("a", errors.New("server returned HTTP status 500"), "status 500")
+	if len(*lines) != 2 || (*lines)[1].message != "status 500" {
+		t.Errorf("expected a changed error to be logged, got %v", *lines)
+	}
+}
+
+func TestScrapeFailureLog_PerTarget(t *testing.T) {
+	l, lines, _ := newTestFailureLog()
+	refused := errors.New("connection refused")
+
+	// 50 down targets each log their first failure, then stay quiet
+	for round := 0; round < 3; round++ {
+		for i := 0; i < 50; i++ {
+			l.failure(string(rune('A'+i)), refused, "refused")
+		}
+	}
+	if len(*lines) != 50 {
+		t.Errorf("expected one line per target, got %d", len(*lines))
+	}
+
+	l.forget("A")
+	l.success("A", "")
+	if len(*lines) != 50 {
+		t.Errorf("expected no recovery line for a forgotten target, got %d lines", len(*lines))
+	}
+}
@@ -0,0 +1,59 @@
+package scraper
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"open-agent/pkg/discovery"
+)
+
+// startFederateServer starts a fake Prometheus /federate endpoint that answers with one series per
+// match[] param, echoing the selector in a label, with the original job, instance and timestamp
+func startFederateServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/federate" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		for i, selector := range r.URL.Query()["match[]"] {
+			fmt.Fprintf(w, "federated_series{job=\"kafka\",instance=\"broker-%d:9308\",selector=%s} 1 1700000000000\n", i, strconv.Quote(selector))
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestScraperTask_FederateMatchSelectors(t *testing.T) {
+	srv := startFederateServer(t)
+	selectors := []string{`{job="kafka"}`, `{__name__=~"node_cpu.*"}`}
+	target := &discovery.Target{
+		ID:     "federate-test",
+		URL:    srv.URL + "/federate",
+		Labels: map[string]string{"job": "prometheus", "instance": strings.TrimPrefix(srv.URL, "http://")},
+		Metadata: map[string]interface{}{
+			"targetName": "prometheus",
+			"endpoint":   discovery.EndpointConfig{Path: "/federate", MatchSelectors: selectors, HonorLabels: true},
+		},
+	}
+
+	sm := &ScraperManager{}
+	rawData, err := sm.createScraperTaskFromTarget(target).Run()
+	if err != nil {
+		t.Fatalf("scrape failed: %v", err)
+	}
+	for i, selector := range selectors {
+		want := fmt.Sprintf("instance=\"broker-%d:9308\",selector=%s}", i, strconv.Quote(selector))
+		if !strings.Contains(rawData.RawData, want) {
+			t.Errorf("expected the federate server to see match[]=%s, got body %q", selector, rawData.RawData)
+		}
+	}
+	if !rawData.HonorLabels || rawData.IgnoreTimestamps {
+		t.Errorf("expected honorLabels and honorTimestamps to reach the processor, got %v/%v", rawData.HonorLabels, !rawData.IgnoreTimestamps)
+	}
+}
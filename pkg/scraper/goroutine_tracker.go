@@ -0,0 +1,197 @@
+package scraper
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"open-agent/pkg/model"
+	"open-agent/tools/util/logutil"
+)
+
+const (
+	// MetricSchedulerGoroutines is the number of live scheduler and scrape goroutines
+	MetricSchedulerGoroutines = "openagent_scheduler_goroutines"
+	// MetricOrphanedGoroutines is the number of those goroutines whose scheduler was stopped more than
+	// orphanIntervals intervals ago
+	MetricOrphanedGoroutines = "openagent_scheduler_orphaned_goroutines"
+
+	// schedulerStopTimeout bounds how long stopTargetScheduler waits for the scheduler goroutine to exit
+	schedulerStopTimeout = time.Second
+	// orphanIntervals is how many scrape intervals a goroutine may outlive its scheduler's stop, e.g. to
+	// finish its last scrape, before it is reported and reaped
+	orphanIntervals = 2
+)
+
+// Goroutine kinds of a target scheduler
+const (
+	goroutineScheduler = "scheduler"
+	goroutineScrape    = "scrape"
+)
+
+// trackedGoroutine is a live goroutine of a target scheduler
+type trackedGoroutine struct {
+	scheduler *TargetScheduler
+	targetID  string
+	kind      string
+	started   time.Time
+	// reported is set once the goroutine was logged as an orphan
+	reported bool
+}
+
+// goroutineTracker keeps the set of live scheduler goroutines, so goroutines surviving
+// stopTargetScheduler show up in logs and self-metrics instead of only as a slowly climbing count
+type goroutineTracker struct {
+	mu      sync.Mutex
+	nextID  uint64
+	live    map[uint64]*trackedGoroutine
+	orphans int
+}
+
+// add registers a goroutine of the scheduler and returns the ID to pass to remove when it exits
+func (gt *goroutineTracker) add(scheduler *TargetScheduler, kind string) uint64 {
+	gt.mu.Lock()
+	defer gt.mu.Unlock()
+	if gt.live == nil {
+		gt.live = make(map[uint64]*trackedGoroutine)
+	}
+	gt.nextID++
+	gt.live[gt.nextID] = &trackedGoroutine{
+		scheduler: scheduler,
+		targetID:  scheduler.target.ID,
+		kind:      kind,
+		started:   time.Now(),
+	}
+	return gt.nextID
+}
+
+// remove deregisters an exiting goroutine
+func (gt *goroutineTracker) remove(id uint64) {
+	gt.mu.Lock()
+	defer gt.mu.Unlock()
+	delete(gt.live, id)
+}
+
+// counts returns the number of live and orphaned goroutines
+func (gt *goroutineTracker) counts() (live, orphans int) {
+	gt.mu.Lock()
+	defer gt.mu.Unlock()
+	return len(gt.live), gt.orphans
+}
+
+// newSchedulerLifecycle sets up the channels and context the scheduler's goroutines exit on
+func (ts *TargetScheduler) newSchedulerLifecycle() {
+	ts.done = make(chan struct{})
+	ts.reapCtx, ts.reap = context.WithCancel(context.Background())
+}
+
+// reaped returns a channel closed when the scheduler is reaped, nil for a scheduler without a lifecycle
+func (ts *TargetScheduler) reaped() <-chan struct{} {
+	if ts.reapCtx == nil {
+		return nil
+	}
+	return ts.reapCtx.Done()
+}
+
+// markStopped records when the scheduler was stopped
+func (ts *TargetScheduler) markStopped(now time.Time) {
+	ts.statusMu.Lock()
+	defer ts.statusMu.Unlock()
+	ts.stoppedAt = now
+}
+
+func (ts *TargetScheduler) stoppedTime() time.Time {
+	ts.statusMu.Lock()
+	defer ts.statusMu.Unlock()
+	return ts.stoppedAt
+}
+
+// waitStopped waits up to timeout for the scheduler goroutine to exit after its stopCh was closed
+func (ts *TargetScheduler) waitStopped(timeout time.Duration) bool {
+	if ts.done == nil {
+		return true
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-ts.done:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// reconcileGoroutines compares the tracked goroutines with the active schedulers. A goroutine whose
+// scheduler was stopped more than orphanIntervals intervals before now is an orphan: it is logged once
+// and reaped by cancelling its scheduler's context, which aborts its scrape request and raw queue send.
+// Returns the number of orphans.
+func (sm *ScraperManager) reconcileGoroutines(now time.Time) int {
+	sm.schedulerMutex.RLock()
+	active := make(map[*TargetScheduler]bool, len(sm.targetSchedulers))
+	for _, scheduler := range sm.targetSchedulers {
+		active[scheduler] = true
+	}
+	sm.schedulerMutex.RUnlock()
+
+	gt := &sm.goroutines
+	gt.mu.Lock()
+	var orphans []*trackedGoroutine
+	for _, g := range gt.live {
+		if active[g.scheduler] {
+			continue
+		}
+		stopped := g.scheduler.stoppedTime()
+		if stopped.IsZero() || now.Sub(stopped) <= orphanIntervals*g.scheduler.interval {
+			continue
+		}
+		orphans = append(orphans, g)
+	}
+	gt.orphans = len(orphans)
+	var report []trackedGoroutine
+	for _, g := range orphans {
+		if !g.reported {
+			g.reported = true
+			report = append(report, *g)
+		}
+	}
+	gt.mu.Unlock()
+
+	sort.Slice(report, func(i, j int) bool { return report[i].started.Before(report[j].started) })
+	for _, g := range report {
+		logutil.Printf("WARN", "[SCRAPER] Reaping orphaned %s goroutine of target %s: started %s, still running %v after its scheduler was stopped",
+			g.kind, g.targetID, g.started.Format(time.RFC3339), now.Sub(g.scheduler.stoppedTime()).Round(time.Second))
+	}
+	for _, g := range orphans {
+		if g.scheduler.reap != nil {
+			g.scheduler.reap()
+		}
+	}
+	return len(orphans)
+}
+
+// goroutineResult returns the live and orphaned scheduler goroutine counts
+func (gt *goroutineTracker) goroutineResult(now int64) *model.ConversionResult {
+	live, orphans := gt.counts()
+	liveHelp := model.NewOpenMxHelp(MetricSchedulerGoroutines)
+	liveHelp.Put("help", "Live target scheduler and scrape goroutines")
+	liveHelp.Put("type", "gauge")
+	orphanHelp := model.NewOpenMxHelp(MetricOrphanedGoroutines)
+	orphanHelp.Put("help", "Scheduler goroutines still running more than two intervals after their scheduler was stopped")
+	orphanHelp.Put("type", "gauge")
+	result := model.NewConversionResult([]*model.OpenMx{
+		model.NewOpenMx(MetricSchedulerGoroutines, now, float64(live)),
+		model.NewOpenMx(MetricOrphanedGoroutines, now, float64(orphans)),
+	}, []*model.OpenMxHelp{liveHelp, orphanHelp})
+	result.SetCollectionTime(now)
+	return result
+}
+
+// sendSchedulerGoroutines queues the scheduler goroutine counts
+func (sm *ScraperManager) sendSchedulerGoroutines() {
+	select {
+	case sm.selfMetricsQueue <- sm.goroutines.goroutineResult(time.Now().UnixMilli()):
+	default:
+		logutil.Printf("WARN", "[SCRAPER] Processed queue is full, dropping scheduler goroutine counts")
+	}
+}
@@ -0,0 +1,100 @@
+package scraper
+
+import (
+	"testing"
+	"time"
+
+	"open-agent/pkg/config"
+	"open-agent/pkg/discovery"
+	"open-agent/pkg/discovery/discoverytest"
+	"open-agent/pkg/model"
+)
+
+// waitForGoroutines polls the tracked goroutine count until it is want or the deadline passes
+func waitForGoroutines(t *testing.T, sm *ScraperManager, want int) int {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		live, _ := sm.goroutines.counts()
+		if live == want || time.Now().After(deadline) {
+			return live
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestGoroutineTracker_OrphanedScrapeIsReaped(t *testing.T) {
+	// The exporter never answers within the scrape timeout, so the scrape outlives its scheduler
+	exporter := discoverytest.NewExporter("up 1\n")
+	exporter.SetLatency(time.Minute)
+	defer exporter.Close()
+
+	sd := discoverytest.New(discoverytest.Target("stuck", exporter.URL(), discovery.EndpointConfig{Path: "/metrics", Interval: "1s", Timeout: "1m"}))
+	sm := NewScraperManager(&config.ConfigManager{}, sd, make(chan *model.ScrapeRawData, 10), "")
+	defer sm.Stop()
+	defer sm.stopAllSchedulers()
+
+	sm.updateTargetSchedulers()
+	scheduler := schedulerFor(sm, "stuck")
+	if scheduler == nil {
+		t.Fatal("expected a scheduler")
+	}
+	// The scheduler goroutine and its blocked scrape
+	if live := waitForGoroutines(t, sm, 2); live != 2 {
+		t.Fatalf("live goroutines = %d, want 2", live)
+	}
+
+	sd.Remove("stuck")
+	sm.updateTargetSchedulers()
+	select {
+	case <-scheduler.done:
+	default:
+		t.Fatal("stopTargetScheduler returned before the scheduler goroutine exited")
+	}
+	if live, _ := sm.goroutines.counts(); live != 1 {
+		t.Fatalf("live goroutines after stop = %d, want the blocked scrape", live)
+	}
+
+	// The scrape may finish within two intervals of the stop
+	stopped := scheduler.stoppedTime()
+	if n := sm.reconcileGoroutines(stopped.Add(2 * time.Second)); n != 0 {
+		t.Errorf("orphans within two intervals = %d, want 0", n)
+	}
+	if n := sm.reconcileGoroutines(stopped.Add(3 * time.Second)); n != 1 {
+		t.Fatalf("orphans after two intervals = %d, want 1", n)
+	}
+
+	result := sm.goroutines.goroutineResult(time.Now().UnixMilli())
+	for _, om := range result.GetOpenMxList() {
+		if om.Metric == MetricOrphanedGoroutines && om.Value != 1 {
+			t.Errorf("%s = %v, want 1", om.Metric, om.Value)
+		}
+	}
+
+	// Reaping aborts the scrape request
+	if live := waitForGoroutines(t, sm, 0); live != 0 {
+		t.Fatalf("live goroutines after reaping = %d, want 0", live)
+	}
+	if n := sm.reconcileGoroutines(stopped.Add(4 * time.Second)); n != 0 {
+		t.Errorf("orphans after reaping = %d, want 0", n)
+	}
+}
+
+func TestGoroutineTracker_ReapedSendDoesNotWait(t *testing.T) {
+	rawQueue := make(chan *model.ScrapeRawData, 1)
+	rawQueue <- &model.ScrapeRawData{}
+	sm := NewScraperManager(&config.ConfigManager{}, discoverytest.New(), rawQueue, "")
+	defer sm.Stop()
+
+	scheduler := &TargetScheduler{target: &discovery.Target{ID: "stuck"}}
+	scheduler.newSchedulerLifecycle()
+	scheduler.reap()
+
+	start := time.Now()
+	if sm.enqueueRawData(scheduler, "stuck", &model.ScrapeRawData{}) {
+		t.Fatal("expected the send to a full queue to be dropped")
+	}
+	if waited := time.Since(start); waited >= rawQueueEnqueueTimeout {
+		t.Errorf("reaped send waited %v for room in the raw queue", waited)
+	}
+}
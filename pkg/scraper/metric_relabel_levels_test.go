@@ -0,0 +1,90 @@
+package scraper
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// relabelLevelsConfig sets metric relabel configs at the global, target and endpoint level
+const relabelLevelsConfig = `
+features:
+  openAgent:
+    enabled: true
+    global:
+      default_metric_relabel_configs:
+        - sourceLabels: [__name__]
+          regex: go_.*
+          action: drop
+    targets:
+      - targetName: node-exporter
+        type: StaticEndpoints
+        metricRelabelConfigs:
+          - sourceLabels: [__name__]
+            regex: process_.*
+            action: drop
+        endpoints:
+          - address: "127.0.0.1:1"
+            path: [/metrics]
+            pathMetricRelabelConfigs:
+              /metrics:
+                - sourceLabels: [__name__]
+                  regex: node_scrape_.*
+                  action: drop
+            metricRelabelConfigs:
+              - sourceLabels: [__name__]
+                regex: promhttp_.*
+                action: drop
+`
+
+func writeScrapeConfig(t *testing.T, dir, data string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, "scrape_config.yaml"), []byte(data), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+}
+
+func TestMetricRelabelConfigs_GlobalTargetEndpointOrder(t *testing.T) {
+	dir := t.TempDir()
+	writeScrapeConfig(t, dir, relabelLevelsConfig)
+	t.Setenv("WHATAP_OPEN_HOME", dir)
+
+	sm := &ScraperManager{}
+	task := sm.createScraperTaskFromTarget(discoverOnce(t))
+	var regexes []string
+	for _, rule := range task.MetricRelabelConfigs {
+		regexes = append(regexes, rule.Regex)
+	}
+	want := []string{"go_.*", "process_.*", "promhttp_.*", "node_scrape_.*"}
+	if !reflect.DeepEqual(regexes, want) {
+		t.Errorf("metric relabel configs = %v, want global, target, endpoint and path rules in order %v", regexes, want)
+	}
+}
+
+func TestMetricRelabelConfigs_EditAtEachLevelChangesEndpoint(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("WHATAP_OPEN_HOME", dir)
+	sm := &ScraperManager{}
+
+	writeScrapeConfig(t, dir, relabelLevelsConfig)
+	base := discoverOnce(t)
+
+	for _, tt := range []struct{ level, from, to string }{
+		{"global", "regex: go_.*", "regex: go_gc_.*"},
+		{"target", "regex: process_.*", "regex: process_open_.*"},
+		{"endpoint", "regex: promhttp_.*", "regex: promhttp_metric_.*"},
+	} {
+		writeScrapeConfig(t, dir, strings.Replace(relabelLevelsConfig, tt.from, tt.to, 1))
+		edited := discoverOnce(t)
+		if !sm.hasEndpointChanged(base, edited) {
+			t.Errorf("expected an edit of the %s metric relabel configs to change the endpoint hash", tt.level)
+		}
+	}
+
+	writeScrapeConfig(t, dir, relabelLevelsConfig)
+	if sm.hasEndpointChanged(base, discoverOnce(t)) {
+		t.Errorf("expected the unchanged config to keep the endpoint hash")
+	}
+}
@@ -0,0 +1,85 @@
+package scraper
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"open-agent/pkg/config"
+	"open-agent/pkg/discovery"
+	"open-agent/pkg/discovery/discoverytest"
+	"open-agent/pkg/model"
+)
+
+// refusedURL returns a metrics URL on a port nothing listens on
+func refusedURL(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+	return "http://" + addr + "/metrics"
+}
+
+func TestScrapeTarget_RetriesAtMovedAddress(t *testing.T) {
+	exporter := discoverytest.NewExporter("up 1\n")
+	defer exporter.Close()
+	endpoint := discovery.EndpointConfig{Path: "/metrics", Interval: "60s"}
+	oldURL := refusedURL(t)
+
+	sd := discoverytest.New(discoverytest.Target("pod", oldURL, endpoint))
+	rawQueue := make(chan *model.ScrapeRawData, 10)
+	sm := NewScraperManager(&config.ConfigManager{}, sd, rawQueue, "")
+	defer sm.Stop()
+	sm.updateTargetSchedulers()
+	defer sm.stopAllSchedulers()
+
+	// The scrape hits the old address while discovery still has it
+	scheduler := schedulerFor(sm, "pod")
+	sm.scrapeTarget(scheduler.getTarget())
+	if states := sm.GetSchedulerStates(); len(states) != 1 || !strings.Contains(states[0].LastError, "connection refused") {
+		t.Fatalf("expected a refused scrape at the old address, got %+v", states)
+	}
+
+	// The pod is rescheduled: discovery sees the new IP before the scheduler is updated
+	sd.Add(discoverytest.Target("pod", exporter.URL(), endpoint))
+	sm.scrapeTarget(scheduler.getTarget())
+
+	select {
+	case rawData := <-rawQueue:
+		if rawData.TargetURL != exporter.URL() {
+			t.Errorf("expected the retry to scrape %s, got %s", exporter.URL(), rawData.TargetURL)
+		}
+	default:
+		t.Fatal("expected the retry at the new address to be queued")
+	}
+	if exporter.Requests() != 1 {
+		t.Errorf("expected 1 request at the new address, got %d", exporter.Requests())
+	}
+	if got := scheduler.getTarget().URL; got != exporter.URL() {
+		t.Errorf("expected the scheduler to keep the new address, got %s", got)
+	}
+	if st := sm.GetSchedulerStates()[0]; st.LastError != "" {
+		t.Errorf("expected the retried scrape to be recorded as a success, got %q", st.LastError)
+	}
+}
+
+func TestScrapeTarget_NoRetryWithoutAddressChange(t *testing.T) {
+	endpoint := discovery.EndpointConfig{Path: "/metrics", Interval: "60s"}
+	sd := discoverytest.New(discoverytest.Target("pod", refusedURL(t), endpoint))
+	sm := NewScraperManager(&config.ConfigManager{}, sd, make(chan *model.ScrapeRawData, 10), "")
+	defer sm.Stop()
+	sm.updateTargetSchedulers()
+	defer sm.stopAllSchedulers()
+	if moved := sm.movedTarget(schedulerFor(sm, "pod").getTarget()); moved != nil {
+		t.Errorf("expected no moved target while discovery has the same address, got %s", moved.URL)
+	}
+
+	// A target that is no longer ready is left to the next scheduler update
+	sd.Add(&discovery.Target{ID: "pod", URL: "http://10.0.0.9:9100/metrics", State: discovery.TargetStatePending})
+	if moved := sm.movedTarget(schedulerFor(sm, "pod").getTarget()); moved != nil {
+		t.Errorf("expected a pending target not to be retried, got %s", moved.URL)
+	}
+}
@@ -0,0 +1,182 @@
+package scraper
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"open-agent/pkg/config"
+	"open-agent/pkg/discovery"
+	"open-agent/pkg/k8s"
+	"open-agent/pkg/model"
+	"open-agent/tools/util/logutil"
+)
+
+// MetricNamespaceOverBudgetTargets is the number of targets of a namespace not scheduled because of its
+// maxTargets budget, sent for every namespace with one
+const MetricNamespaceOverBudgetTargets = "openagent_namespace_over_budget_targets"
+
+// maxLoggedOverBudgetTargets caps the target IDs listed in the over-budget log
+const maxLoggedOverBudgetTargets = 10
+
+// namespaceBudgets keeps a shared cluster's namespaces within their budgets (namespaceBudgets in the
+// scrape configuration). Budgets are read on every target update, so they apply without a restart.
+type namespaceBudgets struct {
+	mu      sync.Mutex
+	budgets map[string]config.NamespaceBudget
+	// excluded are the targets per namespace left out by the last update, to log only changes
+	excluded map[string][]string
+}
+
+// targetNamespace returns the namespace of a Kubernetes target, "" for static endpoints
+func targetNamespace(target *discovery.Target) string {
+	ref, _ := target.Metadata["objectRef"].(k8s.ObjectRef)
+	return ref.Namespace
+}
+
+// selectWithinBudgets returns the targets to schedule and, for each namespace over its maxTargets budget,
+// the IDs of the targets left out. The highest priorities are kept; among equal priorities targets that
+// already have a scheduler come first, then by ID, so the choice is stable across updates.
+func selectWithinBudgets(targets []*discovery.Target, budgets map[string]config.NamespaceBudget, scheduled func(string) bool) ([]*discovery.Target, map[string][]string) {
+	byNamespace := make(map[string][]*discovery.Target)
+	for _, target := range targets {
+		namespace := targetNamespace(target)
+		if budgets[namespace].MaxTargets > 0 {
+			byNamespace[namespace] = append(byNamespace[namespace], target)
+		}
+	}
+
+	dropped := make(map[string]bool)
+	excluded := make(map[string][]string)
+	for namespace, candidates := range byNamespace {
+		maxTargets := budgets[namespace].MaxTargets
+		if len(candidates) <= maxTargets {
+			continue
+		}
+		sort.Slice(candidates, func(i, j int) bool {
+			a, b := candidates[i], candidates[j]
+			if pa, pb := targetPriority(a), targetPriority(b); pa != pb {
+				return pa > pb
+			}
+			if sa, sb := scheduled(a.ID), scheduled(b.ID); sa != sb {
+				return sa
+			}
+			return a.ID < b.ID
+		})
+		for _, target := range candidates[maxTargets:] {
+			dropped[target.ID] = true
+			excluded[namespace] = append(excluded[namespace], target.ID)
+		}
+		sort.Strings(excluded[namespace])
+	}
+	if len(dropped) == 0 {
+		return targets, excluded
+	}
+
+	kept := make([]*discovery.Target, 0, len(targets)-len(dropped))
+	for _, target := range targets {
+		if !dropped[target.ID] {
+			kept = append(kept, target)
+		}
+	}
+	return kept, excluded
+}
+
+// applyNamespaceBudgets leaves the targets over their namespace's maxTargets budget out of the ready
+// targets and returns the others with the IDs left out. Changes are logged once, not on every update.
+func (sm *ScraperManager) applyNamespaceBudgets(targets []*discovery.Target) ([]*discovery.Target, map[string]bool) {
+	budgets := sm.configManager.GetNamespaceBudgets()
+	kept, excluded := selectWithinBudgets(targets, budgets, func(targetID string) bool {
+		sm.schedulerMutex.RLock()
+		defer sm.schedulerMutex.RUnlock()
+		_, exists := sm.targetSchedulers[targetID]
+		return exists
+	})
+
+	nb := &sm.namespaceBudgets
+	nb.mu.Lock()
+	previous := nb.excluded
+	nb.budgets = budgets
+	nb.excluded = excluded
+	nb.mu.Unlock()
+
+	for namespace, targetIDs := range excluded {
+		if strings.Join(targetIDs, ",") == strings.Join(previous[namespace], ",") {
+			continue
+		}
+		listed := targetIDs
+		if len(listed) > maxLoggedOverBudgetTargets {
+			listed = append(listed[:maxLoggedOverBudgetTargets:maxLoggedOverBudgetTargets], "...")
+		}
+		logutil.Printf("WARN", "[SCRAPER] Namespace %s is over its budget of %d targets, not scheduling %d lower-priority targets: %s",
+			namespace, budgets[namespace].MaxTargets, len(targetIDs), strings.Join(listed, ", "))
+	}
+	for namespace := range previous {
+		if _, ok := excluded[namespace]; !ok {
+			logutil.Printf("INFO", "[SCRAPER] Namespace %s is within its target budget again", namespace)
+		}
+	}
+
+	overBudget := make(map[string]bool)
+	for _, targetIDs := range excluded {
+		for _, targetID := range targetIDs {
+			overBudget[targetID] = true
+		}
+	}
+	return kept, overBudget
+}
+
+// sampleBudget returns the samples per minute budget of a target's namespace, nil when unlimited
+func (nb *namespaceBudgets) sampleBudget(target *discovery.Target) *model.NamespaceSampleBudget {
+	namespace := targetNamespace(target)
+	if namespace == "" {
+		return nil
+	}
+	nb.mu.Lock()
+	defer nb.mu.Unlock()
+	if maxSamples := nb.budgets[namespace].MaxSamplesPerMinute; maxSamples > 0 {
+		return &model.NamespaceSampleBudget{Namespace: namespace, MaxSamplesPerMinute: maxSamples}
+	}
+	return nil
+}
+
+// namespaceBudgetResult returns the over-budget target counts of the namespaces with a maxTargets
+// budget, or nil when none has one
+func (nb *namespaceBudgets) namespaceBudgetResult(now int64) *model.ConversionResult {
+	nb.mu.Lock()
+	defer nb.mu.Unlock()
+
+	var series []*model.OpenMx
+	for namespace, budget := range nb.budgets {
+		if budget.MaxTargets <= 0 {
+			continue
+		}
+		om := model.NewOpenMx(MetricNamespaceOverBudgetTargets, now, float64(len(nb.excluded[namespace])))
+		om.AddLabel("namespace", namespace)
+		series = append(series, om)
+	}
+	if len(series) == 0 {
+		return nil
+	}
+
+	help := model.NewOpenMxHelp(MetricNamespaceOverBudgetTargets)
+	help.Put("help", "Targets of the namespace not scheduled because it exceeds its maxTargets budget")
+	help.Put("type", "gauge")
+	result := model.NewConversionResult(series, []*model.OpenMxHelp{help})
+	result.SetCollectionTime(now)
+	return result
+}
+
+// sendNamespaceBudgets queues the over-budget target counts
+func (sm *ScraperManager) sendNamespaceBudgets() {
+	result := sm.namespaceBudgets.namespaceBudgetResult(time.Now().UnixMilli())
+	if result == nil {
+		return
+	}
+	select {
+	case sm.selfMetricsQueue <- result:
+	default:
+		logutil.Printf("WARN", "[SCRAPER] Processed queue is full, dropping namespace budget counts")
+	}
+}
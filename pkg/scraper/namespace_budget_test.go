@@ -0,0 +1,124 @@
+package scraper
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"open-agent/pkg/config"
+	"open-agent/pkg/discovery"
+	"open-agent/pkg/discovery/discoverytest"
+	"open-agent/pkg/k8s"
+	"open-agent/pkg/model"
+)
+
+// loadBudgetConfig writes a scrape configuration with the given namespaceBudgets section and loads it
+func loadBudgetConfig(t *testing.T, cm *config.ConfigManager, budgets string) {
+	t.Helper()
+	data := "features:\n  openAgent:\n    enabled: true\n    namespaceBudgets:\n" + budgets
+	if err := os.WriteFile(filepath.Join(os.Getenv("WHATAP_OPEN_HOME"), "scrape_config.yaml"), []byte(data), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := cm.LoadConfig(); err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+}
+
+func namespacedTarget(id, url, namespace string, priority int) *discovery.Target {
+	target := discoverytest.Target(id, url, discovery.EndpointConfig{Path: "/metrics", Interval: "60s"})
+	target.Metadata["objectRef"] = k8s.ObjectRef{Kind: "Pod", Namespace: namespace, Name: id}
+	target.Metadata["priority"] = priority
+	return target
+}
+
+func TestNamespaceBudgets_OneNamespaceOverTargetBudget(t *testing.T) {
+	t.Setenv("WHATAP_OPEN_HOME", t.TempDir())
+	exporter := discoverytest.NewExporter("up 1\n")
+	defer exporter.Close()
+
+	cm := &config.ConfigManager{}
+	loadBudgetConfig(t, cm, `
+      team-a:
+        maxTargets: 2
+      team-b:
+        maxTargets: 5
+        maxSamplesPerMinute: 1000
+`)
+	sd := discoverytest.New(
+		namespacedTarget("team-a/api-0", exporter.URL(), "team-a", 10),
+		namespacedTarget("team-a/batch-0", exporter.URL(), "team-a", 0),
+		namespacedTarget("team-a/batch-1", exporter.URL(), "team-a", 0),
+		namespacedTarget("team-a/web-0", exporter.URL(), "team-a", 5),
+		namespacedTarget("team-b/api-0", exporter.URL(), "team-b", 0),
+		namespacedTarget("team-b/api-1", exporter.URL(), "team-b", 0),
+	)
+	selfMetrics := make(chan *model.ConversionResult, 10)
+	sm := NewScraperManager(cm, sd, make(chan *model.ScrapeRawData, 100), "")
+	sm.SetSelfMetricsQueue(selfMetrics)
+	defer sm.Stop()
+	defer sm.stopAllSchedulers()
+
+	// team-a keeps its two highest-priority targets, team-b is within its budget
+	sm.updateTargetSchedulers()
+	for id, want := range map[string]bool{
+		"team-a/api-0": true, "team-a/web-0": true, "team-a/batch-0": false, "team-a/batch-1": false,
+		"team-b/api-0": true, "team-b/api-1": true,
+	} {
+		if got := schedulerFor(sm, id) != nil; got != want {
+			t.Errorf("%s: scheduled = %v, want %v", id, got, want)
+		}
+	}
+
+	sm.sendNamespaceBudgets()
+	result := <-selfMetrics
+	over := make(map[string]float64)
+	for _, om := range result.GetOpenMxList() {
+		for _, label := range om.Labels {
+			if label.Key == "namespace" {
+				over[label.Value] = om.Value
+			}
+		}
+	}
+	if len(over) != 2 || over["team-a"] != 2 || over["team-b"] != 0 {
+		t.Errorf("expected 2 targets over budget in team-a and none in team-b, got %v", over)
+	}
+
+	// The samples budget is passed to the processor with team-b's scrapes only
+	if budget := sm.namespaceBudgets.sampleBudget(schedulerFor(sm, "team-b/api-0").getTarget()); budget == nil || budget.MaxSamplesPerMinute != 1000 {
+		t.Errorf("expected team-b's samples budget, got %+v", budget)
+	}
+	if budget := sm.namespaceBudgets.sampleBudget(schedulerFor(sm, "team-a/api-0").getTarget()); budget != nil {
+		t.Errorf("expected no samples budget for team-a, got %+v", budget)
+	}
+
+	// A reloaded budget applies on the next update
+	loadBudgetConfig(t, cm, `
+      team-a:
+        maxTargets: 3
+`)
+	sm.updateTargetSchedulers()
+	if schedulerFor(sm, "team-a/batch-0") == nil || schedulerFor(sm, "team-a/batch-1") != nil {
+		t.Errorf("expected batch-0 scheduled with the raised budget, batch-1 still left out")
+	}
+	if budget := sm.namespaceBudgets.sampleBudget(schedulerFor(sm, "team-b/api-0").getTarget()); budget != nil {
+		t.Errorf("expected the removed samples budget to be gone, got %+v", budget)
+	}
+}
+
+func TestSelectWithinBudgets_PrefersScheduledTargets(t *testing.T) {
+	targets := []*discovery.Target{
+		namespacedTarget("a", "http://10.0.0.1/metrics", "team-a", 0),
+		namespacedTarget("b", "http://10.0.0.2/metrics", "team-a", 0),
+		namespacedTarget("static", "http://10.0.0.3/metrics", "", 0),
+	}
+	budgets := map[string]config.NamespaceBudget{"team-a": {MaxTargets: 1}}
+
+	// A target that already scrapes keeps its place over a new one of the same priority
+	kept, excluded := selectWithinBudgets(targets, budgets, func(id string) bool { return id == "b" })
+	if len(kept) != 2 || kept[0].ID != "b" || kept[1].ID != "static" {
+		t.Errorf("expected b and the static target kept, got %v", kept)
+	}
+	if ids := excluded["team-a"]; len(ids) != 1 || ids[0] != "a" {
+		t.Errorf("expected a left out, got %v", excluded)
+	}
+}
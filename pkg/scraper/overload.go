@@ -0,0 +1,295 @@
+package scraper
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"open-agent/pkg/config"
+	"open-agent/pkg/discovery"
+	"open-agent/pkg/model"
+	"open-agent/tools/util/logutil"
+)
+
+// Defaults of the overload breaker (whatap.conf openagent_overload_*)
+const (
+	DefaultOverloadHighPercent    = 90
+	DefaultOverloadLowPercent     = 50
+	DefaultOverloadHoldSeconds    = 60
+	DefaultOverloadIntervalFactor = 4
+)
+
+// overloadCheckInterval is how often queue utilization is sampled
+const overloadCheckInterval = 5 * time.Second
+
+// Self-metric names of the overload breaker
+const (
+	MetricOverloadOpen    = "openagent_overload_breaker_open"
+	MetricOverloadTrips   = "openagent_overload_breaker_trips_total"
+	MetricOverloadSkipped = "openagent_overload_skipped_scrapes_total"
+)
+
+// QueueGauge is a pipeline queue watched by the overload breaker
+type QueueGauge struct {
+	Name string
+	Len  func() int
+	Cap  int
+}
+
+// overloadSettings are the overload breaker knobs, read on every check so they apply without a restart
+type overloadSettings struct {
+	enabled bool
+	high    float64 // utilization that opens the breaker once held for hold
+	low     float64 // utilization that closes it
+	hold    time.Duration
+	factor  int // interval multiplier when every target has the same priority
+}
+
+func loadOverloadSettings() overloadSettings {
+	s := overloadSettings{
+		enabled: config.GetBoolWithDefault("openagent_overload_breaker_enabled", true),
+		high:    float64(config.GetIntWithDefault("openagent_overload_high_percent", DefaultOverloadHighPercent)) / 100,
+		low:     float64(config.GetIntWithDefault("openagent_overload_low_percent", DefaultOverloadLowPercent)) / 100,
+		hold:    time.Duration(config.GetIntWithDefault("openagent_overload_hold_seconds", DefaultOverloadHoldSeconds)) * time.Second,
+		factor:  config.GetIntWithDefault("openagent_overload_interval_factor", DefaultOverloadIntervalFactor),
+	}
+	if s.high <= 0 || s.high > 1 {
+		s.high = DefaultOverloadHighPercent / 100.0
+	}
+	if s.low < 0 || s.low >= s.high {
+		s.low = s.high * DefaultOverloadLowPercent / DefaultOverloadHighPercent
+	}
+	if s.factor < 2 {
+		s.factor = DefaultOverloadIntervalFactor
+	}
+	return s
+}
+
+// overloadBreaker sheds scrape load while the pipeline queues stay saturated, e.g. during a server outage.
+// It opens when the fullest queue stays at or above the high-water mark for the hold time and closes
+// when it drops to the low-water mark. While open, targets below the highest target priority are paused;
+// when every target has the same priority, all targets scrape only every factor-th interval instead.
+type overloadBreaker struct {
+	mu         sync.Mutex
+	queues     []QueueGauge
+	aboveSince time.Time // when utilization last rose above the high-water mark, zero while below
+	open       bool
+	openedAt   time.Time
+	// Load shedding chosen when the breaker opened
+	topPriority int
+	uniform     bool
+	factor      int
+
+	trips   atomic.Int64
+	skipped atomic.Int64
+}
+
+// utilization returns the fullest queue and its utilization
+func (b *overloadBreaker) utilization() (string, float64) {
+	var name string
+	var max float64
+	for _, q := range b.queues {
+		if q.Cap <= 0 {
+			continue
+		}
+		if u := float64(q.Len()) / float64(q.Cap); u >= max {
+			name, max = q.Name, u
+		}
+	}
+	return name, max
+}
+
+// update applies one utilization sample and reports whether the breaker opened or closed
+func (b *overloadBreaker) update(s overloadSettings, util float64, now time.Time) (opened, closed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !s.enabled {
+		b.aboveSince = time.Time{}
+		if b.open {
+			b.open = false
+			return false, true
+		}
+		return false, false
+	}
+
+	if b.open {
+		if util <= s.low {
+			b.open = false
+			b.aboveSince = time.Time{}
+			return false, true
+		}
+		return false, false
+	}
+
+	if util < s.high {
+		b.aboveSince = time.Time{}
+		return false, false
+	}
+	if b.aboveSince.IsZero() {
+		b.aboveSince = now
+	}
+	if now.Sub(b.aboveSince) < s.hold {
+		return false, false
+	}
+	b.open = true
+	b.openedAt = now
+	b.factor = s.factor
+	b.trips.Add(1)
+	return true, false
+}
+
+// shed sets how load is shed while the breaker is open from the priorities of the scraped targets
+func (b *overloadBreaker) shed(priorities []int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.uniform = true
+	for i, p := range priorities {
+		if i == 0 || p > b.topPriority {
+			b.topPriority = p
+		}
+		if p != priorities[0] {
+			b.uniform = false
+		}
+	}
+}
+
+// skip reports whether a scrape tick of a target with the given priority is skipped; tick counts the
+// target's ticks while the breaker is open
+func (b *overloadBreaker) skip(priority int, tick int64) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.open {
+		return false
+	}
+	var skip bool
+	if b.uniform {
+		skip = tick%int64(b.factor) != 0
+	} else {
+		skip = priority < b.topPriority
+	}
+	if skip {
+		b.skipped.Add(1)
+	}
+	return skip
+}
+
+// isOpen reports whether the breaker is open
+func (b *overloadBreaker) isOpen() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.open
+}
+
+// targetPriority returns the priority of a target's config (default 0)
+func targetPriority(target *discovery.Target) int {
+	priority, _ := target.Metadata["priority"].(int)
+	return priority
+}
+
+// SetOverloadQueues sets the queues the overload breaker watches. Must be called before StartScraping;
+// without queues the breaker is not started.
+func (sm *ScraperManager) SetOverloadQueues(queues ...QueueGauge) {
+	sm.overload.queues = queues
+}
+
+// overloadLoop samples queue utilization until the manager stops
+func (sm *ScraperManager) overloadLoop() {
+	ticker := time.NewTicker(overloadCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			sm.checkOverload(loadOverloadSettings(), time.Now())
+		case <-sm.stopCh:
+			return
+		}
+	}
+}
+
+// checkOverload updates the breaker from the current queue utilization and logs transitions
+func (sm *ScraperManager) checkOverload(s overloadSettings, now time.Time) {
+	queue, util := sm.overload.utilization()
+	opened, closed := sm.overload.update(s, util, now)
+	switch {
+	case opened:
+		sm.overload.shed(sm.schedulerPriorities())
+		sm.overload.mu.Lock()
+		action := fmt.Sprintf("pausing targets below priority %d", sm.overload.topPriority)
+		if sm.overload.uniform {
+			action = fmt.Sprintf("scraping every %d intervals", sm.overload.factor)
+		}
+		sm.overload.mu.Unlock()
+		logutil.Printf("WARN", "[SCRAPER] Overload breaker opened: %s at %.0f%% for %v, %s",
+			queue, util*100, s.hold, action)
+		sm.sendOverloadState()
+	case closed:
+		sm.overload.mu.Lock()
+		openFor := now.Sub(sm.overload.openedAt).Round(time.Second)
+		sm.overload.mu.Unlock()
+		logutil.Printf("INFO", "[SCRAPER] Overload breaker closed after %v: %s at %.0f%%, %d scrapes skipped so far",
+			openFor, queue, util*100, sm.overload.skipped.Load())
+		sm.sendOverloadState()
+	}
+}
+
+// schedulerPriorities returns the priorities of the scraped targets
+func (sm *ScraperManager) schedulerPriorities() []int {
+	sm.schedulerMutex.RLock()
+	defer sm.schedulerMutex.RUnlock()
+	priorities := make([]int, 0, len(sm.targetSchedulers))
+	for _, scheduler := range sm.targetSchedulers {
+		priorities = append(priorities, targetPriority(scheduler.getTarget()))
+	}
+	return priorities
+}
+
+// overloadSkip reports whether the breaker skips this tick of a scheduler
+func (sm *ScraperManager) overloadSkip(scheduler *TargetScheduler) bool {
+	if !sm.overload.isOpen() {
+		scheduler.overloadTicks = 0
+		return false
+	}
+	scheduler.overloadTicks++
+	return sm.overload.skip(targetPriority(scheduler.getTarget()), scheduler.overloadTicks)
+}
+
+// overloadState returns the breaker self-metrics
+func (sm *ScraperManager) overloadState(now int64) *model.ConversionResult {
+	var open float64
+	if sm.overload.isOpen() {
+		open = 1
+	}
+	series := []*model.OpenMx{
+		model.NewOpenMx(MetricOverloadOpen, now, open),
+		model.NewOpenMx(MetricOverloadTrips, now, float64(sm.overload.trips.Load())),
+		model.NewOpenMx(MetricOverloadSkipped, now, float64(sm.overload.skipped.Load())),
+	}
+
+	openHelp := model.NewOpenMxHelp(MetricOverloadOpen)
+	openHelp.Put("help", "1 while the overload breaker sheds scrapes because the pipeline queues are saturated")
+	openHelp.Put("type", "gauge")
+	tripsHelp := model.NewOpenMxHelp(MetricOverloadTrips)
+	tripsHelp.Put("help", "Times the overload breaker opened")
+	tripsHelp.Put("type", "counter")
+	skippedHelp := model.NewOpenMxHelp(MetricOverloadSkipped)
+	skippedHelp.Put("help", "Scrapes skipped by the overload breaker")
+	skippedHelp.Put("type", "counter")
+
+	result := model.NewConversionResult(series, []*model.OpenMxHelp{openHelp, tripsHelp, skippedHelp})
+	result.SetCollectionTime(now)
+	return result
+}
+
+// sendOverloadState queues the breaker self-metrics, dropping them when the queue is full
+func (sm *ScraperManager) sendOverloadState() {
+	if sm.selfMetricsQueue == nil || len(sm.overload.queues) == 0 {
+		return
+	}
+	select {
+	case sm.selfMetricsQueue <- sm.overloadState(time.Now().UnixMilli()):
+	default:
+	}
+}
@@ -0,0 +1,156 @@
+package scraper
+
+import (
+	"testing"
+	"time"
+
+	"open-agent/pkg/config"
+	"open-agent/pkg/discovery"
+	"open-agent/pkg/model"
+)
+
+var testOverloadSettings = overloadSettings{enabled: true, high: 0.9, low: 0.5, hold: 60 * time.Second, factor: 4}
+
+func TestOverloadBreaker_Hysteresis(t *testing.T) {
+	var b overloadBreaker
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Utilization sampled every 5s: a short spike, a sustained saturation, a partial drain, then recovery
+	levels := []struct {
+		at         time.Duration
+		util       float64
+		wantOpen   bool
+		transition string
+	}{
+		{0, 0.95, false, ""},
+		{30 * time.Second, 0.95, false, ""},
+		{35 * time.Second, 0.6, false, ""}, // spike ends before the hold time
+		{40 * time.Second, 0.92, false, ""},
+		{95 * time.Second, 0.99, false, ""},
+		{100 * time.Second, 0.9, true, "opened"}, // held for 60s
+		{105 * time.Second, 0.7, true, ""},       // between the marks: stays open
+		{110 * time.Second, 0.51, true, ""},
+		{115 * time.Second, 0.5, false, "closed"}, // low-water mark
+		{120 * time.Second, 0.95, false, ""},      // the hold time starts over
+	}
+	for _, level := range levels {
+		opened, closed := b.update(testOverloadSettings, level.util, start.Add(level.at))
+		var transition string
+		if opened {
+			transition = "opened"
+		} else if closed {
+			transition = "closed"
+		}
+		if transition != level.transition || b.isOpen() != level.wantOpen {
+			t.Fatalf("at %v (%.0f%%): transition %q open %v, want %q open %v",
+				level.at, level.util*100, transition, b.isOpen(), level.transition, level.wantOpen)
+		}
+	}
+	if trips := b.trips.Load(); trips != 1 {
+		t.Errorf("expected 1 trip, got %d", trips)
+	}
+}
+
+func TestOverloadBreaker_Disabled(t *testing.T) {
+	var b overloadBreaker
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	b.update(testOverloadSettings, 1, now)
+	if opened, _ := b.update(testOverloadSettings, 1, now.Add(time.Minute)); !opened {
+		t.Fatal("expected the breaker to open")
+	}
+
+	// Disabling the breaker closes it right away and keeps it closed
+	disabled := testOverloadSettings
+	disabled.enabled = false
+	if _, closed := b.update(disabled, 1, now.Add(65*time.Second)); !closed {
+		t.Fatal("expected disabling to close the breaker")
+	}
+	b.update(disabled, 1, now.Add(5*time.Minute))
+	if b.isOpen() {
+		t.Error("expected a disabled breaker to stay closed")
+	}
+}
+
+func TestOverloadBreaker_Shedding(t *testing.T) {
+	open := func(priorities ...int) *overloadBreaker {
+		b := &overloadBreaker{open: true, factor: 4}
+		b.shed(priorities)
+		return b
+	}
+
+	// Mixed priorities: lower priorities are paused, the highest keeps its interval
+	b := open(0, 0, 10, 5)
+	for tick := int64(1); tick <= 4; tick++ {
+		if !b.skip(0, tick) || !b.skip(5, tick) || b.skip(10, tick) {
+			t.Fatalf("tick %d: expected priorities 0 and 5 paused and 10 scraped", tick)
+		}
+	}
+
+	// Equal priorities: every target scrapes once per factor ticks
+	b = open(0, 0, 0)
+	var scraped int
+	for tick := int64(1); tick <= 12; tick++ {
+		if !b.skip(0, tick) {
+			scraped++
+		}
+	}
+	if scraped != 3 {
+		t.Errorf("expected 3 of 12 ticks scraped with factor 4, got %d", scraped)
+	}
+	if skipped := b.skipped.Load(); skipped != 9 {
+		t.Errorf("expected 9 skipped scrapes, got %d", skipped)
+	}
+
+	// A closed breaker skips nothing
+	b.open = false
+	if b.skip(0, 1) {
+		t.Error("expected a closed breaker not to skip")
+	}
+}
+
+func TestCheckOverload_SimulatedQueues(t *testing.T) {
+	rawLen, processedLen := 0, 0
+	selfMetrics := make(chan *model.ConversionResult, 10)
+	sm := NewScraperManager(&config.ConfigManager{}, nil, make(chan *model.ScrapeRawData, 1), "")
+	sm.SetSelfMetricsQueue(selfMetrics)
+	sm.SetOverloadQueues(
+		QueueGauge{Name: "rawQueue", Len: func() int { return rawLen }, Cap: 100},
+		QueueGauge{Name: "processedQueue", Len: func() int { return processedLen }, Cap: 1000},
+	)
+	sm.targetSchedulers["low"] = &TargetScheduler{target: &discovery.Target{ID: "low", Metadata: map[string]interface{}{"priority": 0}}}
+	sm.targetSchedulers["high"] = &TargetScheduler{target: &discovery.Target{ID: "high", Metadata: map[string]interface{}{"priority": 10}}}
+
+	// The processed queue saturates (server outage) for over a minute
+	processedLen = 950
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for at := time.Duration(0); at <= 60*time.Second; at += overloadCheckInterval {
+		sm.checkOverload(testOverloadSettings, start.Add(at))
+	}
+	if !sm.overload.isOpen() {
+		t.Fatal("expected the breaker to open on a saturated processed queue")
+	}
+	if !sm.overloadSkip(sm.targetSchedulers["low"]) || sm.overloadSkip(sm.targetSchedulers["high"]) {
+		t.Error("expected the low-priority target paused and the high-priority target scraped")
+	}
+
+	// The queue drains below the low-water mark
+	processedLen = 100
+	sm.checkOverload(testOverloadSettings, start.Add(65*time.Second))
+	if sm.overload.isOpen() || sm.overloadSkip(sm.targetSchedulers["low"]) {
+		t.Fatal("expected the breaker to close and scraping to resume")
+	}
+
+	// Both transitions were exported
+	if len(selfMetrics) != 2 {
+		t.Fatalf("expected 2 self-metric results, got %d", len(selfMetrics))
+	}
+	<-selfMetrics
+	closed := <-selfMetrics
+	values := make(map[string]float64)
+	for _, om := range closed.GetOpenMxList() {
+		values[om.Metric] = om.Value
+	}
+	if values[MetricOverloadOpen] != 0 || values[MetricOverloadTrips] != 1 || values[MetricOverloadSkipped] != 1 {
+		t.Errorf("unexpected breaker metrics %v", values)
+	}
+}
@@ -0,0 +1,226 @@
+package scraper
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"open-agent/pkg/config"
+	"open-agent/pkg/model"
+	"open-agent/tools/util/logutil"
+)
+
+// DefaultPauseTTL is how long a target stays paused unless whatap.conf or the request sets a TTL
+const DefaultPauseTTL = time.Hour
+
+// ErrUnknownTarget is returned when pausing a target that has no scheduler
+var ErrUnknownTarget = errors.New("unknown target")
+
+// pauseTTL returns the default pause TTL (whatap.conf openagent_target_pause_ttl_minutes)
+func pauseTTL() time.Duration {
+	minutes := config.GetIntWithDefault("openagent_target_pause_ttl_minutes", int(DefaultPauseTTL/time.Minute))
+	if minutes <= 0 {
+		return DefaultPauseTTL
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// pausedTargetsFile returns the file the paused targets are kept in across restarts
+func pausedTargetsFile() string {
+	homeDir := os.Getenv("WHATAP_OPEN_HOME")
+	if homeDir == "" {
+		homeDir = "."
+	}
+	return filepath.Join(homeDir, "cache", "paused_targets.json")
+}
+
+// pauseStore holds paused target IDs and when their pause expires
+type pauseStore struct {
+	mu    sync.Mutex
+	path  string
+	until map[string]time.Time
+}
+
+// loadPauseStore reads the paused targets saved at path, skipping expired pauses
+func loadPauseStore(path string) *pauseStore {
+	p := &pauseStore{path: path, until: make(map[string]time.Time)}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logutil.Printf("WARN", "[SCRAPER] Failed to read paused targets %s: %v", path, err)
+		}
+		return p
+	}
+	var saved map[string]time.Time
+	if err := json.Unmarshal(data, &saved); err != nil {
+		logutil.Printf("WARN", "[SCRAPER] Ignoring invalid paused targets file %s: %v", path, err)
+		return p
+	}
+	now := time.Now()
+	for targetID, until := range saved {
+		if until.After(now) {
+			p.until[targetID] = until
+			logutil.Printf("WARN", "[SCRAPER] Target %s stays paused until %s", targetID, until.Format(time.RFC3339))
+		}
+	}
+	return p
+}
+
+// pause pauses a target until the given time
+func (p *pauseStore) pause(targetID string, until time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.until[targetID] = until
+	p.save()
+}
+
+// resume removes a pause and reports whether the target was paused
+func (p *pauseStore) resume(targetID string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.until[targetID]; !ok {
+		return false
+	}
+	delete(p.until, targetID)
+	p.save()
+	return true
+}
+
+// pausedUntil reports whether a target is paused, removing the pause once it has expired
+func (p *pauseStore) pausedUntil(targetID string, now time.Time) (time.Time, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	until, ok := p.until[targetID]
+	if !ok {
+		return time.Time{}, false
+	}
+	if !until.After(now) {
+		delete(p.until, targetID)
+		p.save()
+		logutil.Printf("INFO", "[SCRAPER] Pause of target %s expired, resuming scrapes", targetID)
+		return time.Time{}, false
+	}
+	return until, true
+}
+
+// save writes the paused targets to the state file. Must be called with mu held.
+func (p *pauseStore) save() {
+	data, err := json.Marshal(p.until)
+	if err != nil {
+		logutil.Printf("WARN", "[SCRAPER] Failed to encode paused targets: %v", err)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(p.path), 0755); err != nil {
+		logutil.Printf("WARN", "[SCRAPER] Failed to create paused targets directory: %v", err)
+		return
+	}
+	tmp := p.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		logutil.Printf("WARN", "[SCRAPER] Failed to write paused targets %s: %v", tmp, err)
+		return
+	}
+	if err := os.Rename(tmp, p.path); err != nil {
+		logutil.Printf("WARN", "[SCRAPER] Failed to write paused targets %s: %v", p.path, err)
+	}
+}
+
+// PauseTarget stops scraping a target until the TTL expires or ResumeTarget is called.
+// A ttl of 0 uses the default TTL. The pause is kept across agent restarts.
+func (sm *ScraperManager) PauseTarget(targetID string, ttl time.Duration) (time.Time, error) {
+	if !sm.HasTarget(targetID) {
+		return time.Time{}, ErrUnknownTarget
+	}
+
+	if ttl <= 0 {
+		ttl = pauseTTL()
+	}
+	until := time.Now().Add(ttl)
+	sm.pauses.pause(targetID, until)
+	logutil.Printf("WARN", "[SCRAPER] Paused scraping target %s until %s", targetID, until.Format(time.RFC3339))
+	return until, nil
+}
+
+// ResumeTarget removes a pause and reports whether the target was paused
+func (sm *ScraperManager) ResumeTarget(targetID string) bool {
+	if !sm.pauses.resume(targetID) {
+		return false
+	}
+	logutil.Printf("INFO", "[SCRAPER] Resumed scraping target %s", targetID)
+	return true
+}
+
+// skipPausedScrape sends up=0 with reason="paused" for a scrape skipped because the target is paused
+func (sm *ScraperManager) skipPausedScrape(scheduler *TargetScheduler) {
+	scheduler.pausedScrapes.Add(1)
+	if sm.selfMetricsQueue == nil {
+		return
+	}
+
+	now := time.Now().UnixMilli()
+	up := model.NewOpenMx("up", now, 0)
+	for k, v := range scheduler.getTarget().Labels {
+		up.AddLabel(k, v)
+	}
+	up.AddLabel("reason", "paused")
+	result := model.NewConversionResult([]*model.OpenMx{up}, nil)
+	result.SetCollectionTime(now)
+
+	select {
+	case sm.selfMetricsQueue <- result:
+	default:
+	}
+}
+
+// PauseHandler serves POST /targets/{id}/pause[?ttl=30m] and POST /targets/{id}/resume.
+// Target IDs contain slashes, so the ID is everything between /targets/ and the action.
+func (sm *ScraperManager) PauseHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		path := strings.TrimPrefix(r.URL.Path, "/targets/")
+		slash := strings.LastIndex(path, "/")
+		if slash <= 0 {
+			http.NotFound(w, r)
+			return
+		}
+		targetID, action := path[:slash], path[slash+1:]
+
+		switch action {
+		case "pause":
+			var ttl time.Duration
+			if value := r.URL.Query().Get("ttl"); value != "" {
+				parsed, err := time.ParseDuration(value)
+				if err != nil || parsed <= 0 {
+					http.Error(w, fmt.Sprintf("invalid ttl %q", value), http.StatusBadRequest)
+					return
+				}
+				ttl = parsed
+			}
+			until, err := sm.PauseTarget(targetID, ttl)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("%v: %s", err, targetID), http.StatusNotFound)
+				return
+			}
+			fmt.Fprintf(w, "paused %s until %s\n", targetID, until.Format(time.RFC3339))
+		case "resume":
+			if !sm.ResumeTarget(targetID) {
+				http.Error(w, fmt.Sprintf("target is not paused: %s", targetID), http.StatusNotFound)
+				return
+			}
+			fmt.Fprintf(w, "resumed %s\n", targetID)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
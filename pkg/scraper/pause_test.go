@@ -0,0 +1,143 @@
+package scraper
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"open-agent/pkg/config"
+	"open-agent/pkg/discovery"
+	"open-agent/pkg/model"
+)
+
+const pausedTargetID = "app/default/pod-a/8080-metrics"
+
+// newPauseTestManager returns a manager with an idle scheduler for pausedTargetID,
+// keeping its state file under a temporary WHATAP_OPEN_HOME
+func newPauseTestManager(t *testing.T) *ScraperManager {
+	t.Helper()
+	sm := NewScraperManager(&config.ConfigManager{}, nil, make(chan *model.ScrapeRawData, 1), "")
+	sm.targetSchedulers[pausedTargetID] = &TargetScheduler{
+		target: &discovery.Target{ID: pausedTargetID, Labels: map[string]string{"job": "app", "instance": "10.0.3.17:8080"}},
+		sm:     sm,
+	}
+	return sm
+}
+
+func postPause(t *testing.T, sm *ScraperManager, path string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	sm.PauseHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, nil))
+	return rec
+}
+
+func TestPauseHandler_PauseAndResume(t *testing.T) {
+	t.Setenv("WHATAP_OPEN_HOME", t.TempDir())
+	sm := newPauseTestManager(t)
+
+	rec := postPause(t, sm, "/targets/"+pausedTargetID+"/pause?ttl=10m")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "paused "+pausedTargetID) {
+		t.Fatalf("pause: unexpected response %d %q", rec.Code, rec.Body.String())
+	}
+	until, paused := sm.pauses.pausedUntil(pausedTargetID, time.Now())
+	if !paused || time.Until(until) < 9*time.Minute {
+		t.Fatalf("expected a 10m pause, got %v (%v)", until, paused)
+	}
+	states := sm.GetSchedulerStates()
+	if len(states) != 1 || !states[0].PausedUntil.Equal(until) {
+		t.Errorf("expected the paused state in the scheduler state, got %+v", states)
+	}
+
+	rec = postPause(t, sm, "/targets/"+pausedTargetID+"/resume")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("resume: unexpected response %d %q", rec.Code, rec.Body.String())
+	}
+	if _, paused := sm.pauses.pausedUntil(pausedTargetID, time.Now()); paused {
+		t.Fatal("expected the target to be resumed")
+	}
+
+	if rec := postPause(t, sm, "/targets/"+pausedTargetID+"/resume"); rec.Code != http.StatusNotFound {
+		t.Errorf("resuming a running target: expected 404, got %d", rec.Code)
+	}
+	if rec := postPause(t, sm, "/targets/unknown/pod/pause"); rec.Code != http.StatusNotFound {
+		t.Errorf("pausing an unknown target: expected 404, got %d", rec.Code)
+	}
+	if rec := postPause(t, sm, "/targets/"+pausedTargetID+"/pause?ttl=soon"); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid ttl: expected 400, got %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	sm.PauseHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/targets/"+pausedTargetID+"/pause", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET: expected 405, got %d", rec.Code)
+	}
+}
+
+func TestPause_Expires(t *testing.T) {
+	t.Setenv("WHATAP_OPEN_HOME", t.TempDir())
+	sm := newPauseTestManager(t)
+
+	if _, err := sm.PauseTarget(pausedTargetID, time.Minute); err != nil {
+		t.Fatalf("pause: %v", err)
+	}
+	if _, paused := sm.pauses.pausedUntil(pausedTargetID, time.Now().Add(59*time.Second)); !paused {
+		t.Fatal("expected the target to be paused before the TTL")
+	}
+	if _, paused := sm.pauses.pausedUntil(pausedTargetID, time.Now().Add(61*time.Second)); paused {
+		t.Fatal("expected the pause to expire after the TTL")
+	}
+	// The expired pause is removed from the state file too
+	if restarted := loadPauseStore(pausedTargetsFile()); len(restarted.until) != 0 {
+		t.Errorf("expected no saved pauses, got %v", restarted.until)
+	}
+}
+
+func TestPause_DefaultTTL(t *testing.T) {
+	t.Setenv("WHATAP_OPEN_HOME", t.TempDir())
+	t.Setenv("openagent_target_pause_ttl_minutes", "5")
+	sm := newPauseTestManager(t)
+
+	until, err := sm.PauseTarget(pausedTargetID, 0)
+	if err != nil {
+		t.Fatalf("pause: %v", err)
+	}
+	if d := time.Until(until); d > 5*time.Minute || d < 4*time.Minute {
+		t.Errorf("expected the configured 5m TTL, got %v", d)
+	}
+}
+
+func TestPause_SurvivesRestart(t *testing.T) {
+	t.Setenv("WHATAP_OPEN_HOME", t.TempDir())
+	until, err := newPauseTestManager(t).PauseTarget(pausedTargetID, time.Hour)
+	if err != nil {
+		t.Fatalf("pause: %v", err)
+	}
+
+	restarted := newPauseTestManager(t)
+	got, paused := restarted.pauses.pausedUntil(pausedTargetID, time.Now())
+	if !paused || !got.Equal(until) {
+		t.Fatalf("expected the pause to survive a restart, got %v (%v), want %v", got, paused, until)
+	}
+}
+
+func TestPause_SkippedScrapeReportsUp0(t *testing.T) {
+	t.Setenv("WHATAP_OPEN_HOME", t.TempDir())
+	sm := newPauseTestManager(t)
+	queue := make(chan *model.ConversionResult, 1)
+	sm.SetSelfMetricsQueue(queue)
+
+	sm.skipPausedScrape(sm.targetSchedulers[pausedTargetID])
+	result := <-queue
+	up := result.OpenMxList[0]
+	labels := make(map[string]string)
+	for _, l := range up.Labels {
+		labels[l.Key] = l.Value
+	}
+	if up.Metric != "up" || up.Value != 0 || labels["reason"] != "paused" || labels["job"] != "app" {
+		t.Fatalf("unexpected series %s %v = %v", up.Metric, up.Labels, up.Value)
+	}
+	if n := sm.targetSchedulers[pausedTargetID].pausedScrapes.Load(); n != 1 {
+		t.Errorf("expected 1 skipped scrape, got %d", n)
+	}
+}
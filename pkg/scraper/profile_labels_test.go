@@ -0,0 +1,37 @@
+package scraper
+
+import (
+	"bytes"
+	"fmt"
+	"runtime/pprof"
+	"testing"
+	"time"
+
+	"open-agent/pkg/config"
+	"open-agent/pkg/discovery"
+	"open-agent/pkg/discovery/discoverytest"
+	"open-agent/pkg/model"
+)
+
+func TestSchedulerGoroutine_ProfileLabels(t *testing.T) {
+	endpoint := discovery.EndpointConfig{Path: "/metrics", Interval: "60s"}
+	sd := discoverytest.New(discoverytest.Target("gateway", "http://127.0.0.1:1/metrics", endpoint))
+	sm := NewScraperManager(&config.ConfigManager{}, sd, make(chan *model.ScrapeRawData, 10), "")
+	defer sm.Stop()
+	sm.updateTargetSchedulers()
+	defer sm.stopAllSchedulers()
+
+	// The scheduler goroutine sets its labels once it runs
+	want := []byte(fmt.Sprintf(`labels: {"component":"scraper", "target":%q}`, schedulerFor(sm, "gateway").getTarget().ID))
+	var profile bytes.Buffer
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		profile.Reset()
+		if err := pprof.Lookup("goroutine").WriteTo(&profile, 1); err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains(profile.Bytes(), want) {
+			return
+		}
+	}
+	t.Errorf("expected a goroutine labeled %s in the profile:\n%s", want, profile.String())
+}
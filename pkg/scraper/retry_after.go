@@ -0,0 +1,76 @@
+package scraper
+
+import (
+	"time"
+
+	"open-agent/pkg/client"
+	"open-agent/pkg/discovery"
+	"open-agent/pkg/model"
+)
+
+// MetricScrapeDeferrals counts the scrapes a target answered with 429, or 503 with Retry-After, after
+// which its next scrape was deferred
+const MetricScrapeDeferrals = "openagent_scrape_deferrals_total"
+
+// maxRetryAfterIntervals bounds a Retry-After deferral to this many scrape intervals
+const maxRetryAfterIntervals = 10
+
+// deferUntil postpones the scrapes of the target until retryAfter, at most maxRetryAfterIntervals
+// intervals from now, and returns the time the next scrape may run. A Retry-After in the past does
+// not defer the next scrape and returns zero.
+func (ts *TargetScheduler) deferUntil(retryAfter, now time.Time) time.Time {
+	if !retryAfter.After(now) {
+		return time.Time{}
+	}
+	if limit := now.Add(maxRetryAfterIntervals * ts.interval); retryAfter.After(limit) {
+		retryAfter = limit
+	}
+	ts.statusMu.Lock()
+	defer ts.statusMu.Unlock()
+	ts.deferredUntil = retryAfter
+	return retryAfter
+}
+
+// deferred reports whether a scrape tick falls before the time the target asked to be scraped again
+func (ts *TargetScheduler) deferred(now time.Time) bool {
+	ts.statusMu.Lock()
+	defer ts.statusMu.Unlock()
+	if ts.deferredUntil.IsZero() {
+		return false
+	}
+	if now.Before(ts.deferredUntil) {
+		ts.deferredScrapes.Add(1)
+		return true
+	}
+	ts.deferredUntil = time.Time{}
+	return false
+}
+
+// deferScrape defers the next scrapes of a rate-limited target and sends its deferral counter
+func (sm *ScraperManager) deferScrape(scheduler *TargetScheduler, target *discovery.Target, rateLimited *client.RetryAfterError, now time.Time) time.Time {
+	until := scheduler.deferUntil(rateLimited.RetryAfter, now)
+	if until.IsZero() {
+		return until
+	}
+	count := scheduler.deferrals.Add(1)
+	if sm.selfMetricsQueue == nil {
+		return until
+	}
+
+	timestamp := now.UnixMilli()
+	deferrals := model.NewOpenMx(MetricScrapeDeferrals, timestamp, float64(count))
+	for k, v := range target.Labels {
+		deferrals.AddLabel(k, v)
+	}
+	help := model.NewOpenMxHelp(MetricScrapeDeferrals)
+	help.Put("help", "Scrapes the target answered with 429 or 503 and Retry-After, deferring its next scrape")
+	help.Put("type", "counter")
+	result := model.NewConversionResult([]*model.OpenMx{deferrals}, []*model.OpenMxHelp{help})
+	result.SetCollectionTime(timestamp)
+
+	select {
+	case sm.selfMetricsQueue <- result:
+	default:
+	}
+	return until
+}
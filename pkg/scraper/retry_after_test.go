@@ -0,0 +1,78 @@
+package scraper
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"open-agent/pkg/config"
+	"open-agent/pkg/discovery"
+	"open-agent/pkg/discovery/discoverytest"
+	"open-agent/pkg/model"
+)
+
+func TestScrapeTarget_DefersRateLimitedTarget(t *testing.T) {
+	var retryAfter atomic.Value
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", retryAfter.Load().(string))
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	endpoint := discovery.EndpointConfig{Path: "/metrics", Interval: "10s"}
+	sd := discoverytest.New(discoverytest.Target("gateway", srv.URL+"/metrics", endpoint))
+	sm := NewScraperManager(&config.ConfigManager{}, sd, make(chan *model.ScrapeRawData, 10), "")
+	queue := make(chan *model.ConversionResult, 10)
+	sm.SetSelfMetricsQueue(queue)
+	defer sm.Stop()
+	sm.updateTargetSchedulers()
+	defer sm.stopAllSchedulers()
+	scheduler := schedulerFor(sm, "gateway")
+	interval := scheduler.interval
+
+	// Seconds format
+	retryAfter.Store("25")
+	before := time.Now()
+	sm.scrapeTarget(scheduler.getTarget())
+	state := scheduler.state()
+	if state.DeferredUntil.Before(before.Add(25*time.Second)) || state.DeferredUntil.After(time.Now().Add(25*time.Second)) {
+		t.Fatalf("expected the next scrape deferred by 25s, got %v", state.DeferredUntil)
+	}
+	if state.Deferrals != 1 || state.LastError == "" {
+		t.Errorf("expected the deferral and the failure recorded, got %+v", state)
+	}
+	if !scheduler.deferred(before.Add(20*time.Second)) || scheduler.deferred(state.DeferredUntil.Add(time.Millisecond)) {
+		t.Errorf("expected ticks skipped only before Retry-After")
+	}
+	if n := scheduler.deferredScrapes.Load(); n != 1 {
+		t.Errorf("expected 1 deferred scrape, got %d", n)
+	}
+	counter := (<-queue).OpenMxList[0]
+	if counter.Metric != MetricScrapeDeferrals || counter.Value != 1 || !hasLabelValue(counter, "job", "gateway") {
+		t.Errorf("unexpected deferral counter %s %v = %v", counter.Metric, counter.Labels, counter.Value)
+	}
+
+	// HTTP-date format, bounded to 10 intervals
+	retryAfter.Store(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	before = time.Now()
+	sm.scrapeTarget(scheduler.getTarget())
+	state = scheduler.state()
+	if limit := before.Add(maxRetryAfterIntervals * interval); state.DeferredUntil.Before(limit) || state.DeferredUntil.After(time.Now().Add(maxRetryAfterIntervals*interval)) {
+		t.Errorf("expected the deferral bounded to %v, got %v", maxRetryAfterIntervals*interval, state.DeferredUntil.Sub(before))
+	}
+	if state.Deferrals != 2 {
+		t.Errorf("expected 2 deferrals, got %d", state.Deferrals)
+	}
+
+	// A date in the past does not defer the next scrape
+	scheduler.statusMu.Lock()
+	scheduler.deferredUntil = time.Time{}
+	scheduler.statusMu.Unlock()
+	retryAfter.Store(time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat))
+	sm.scrapeTarget(scheduler.getTarget())
+	if state = scheduler.state(); !state.DeferredUntil.IsZero() || state.Deferrals != 2 {
+		t.Errorf("expected no deferral for a past Retry-After, got %+v", state)
+	}
+}
@@ -0,0 +1,191 @@
+package scraper
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"open-agent/pkg/config"
+	"open-agent/pkg/discovery"
+	"open-agent/pkg/model"
+	"open-agent/tools/util/logutil"
+)
+
+const (
+	// MetricUnscheduledTargets is the number of ready targets of a target config not scheduled because
+	// the agent reached openagent_max_schedulers, sent for every config with such targets
+	MetricUnscheduledTargets = "openagent_unscheduled_targets"
+
+	// DefaultMaxSchedulers caps the target schedulers when openagent_max_schedulers is not set
+	DefaultMaxSchedulers = 10000
+
+	// schedulerCapLogInterval rate-limits the ERROR log while the cap is exceeded
+	schedulerCapLogInterval = 5 * time.Minute
+
+	// maxLoggedCapConfigs caps the target configs listed in the cap log
+	maxLoggedCapConfigs = 5
+)
+
+// Agent-wide number of ready targets left unscheduled by the cap, reported in the keep-alive pack
+var unscheduledTargets atomic.Int64
+
+// UnscheduledTargets returns the ready targets not scheduled on the last update because the agent
+// reached openagent_max_schedulers, 0 when it is within the cap
+func UnscheduledTargets() int64 {
+	return unscheduledTargets.Load()
+}
+
+// maxSchedulers returns openagent_max_schedulers, read on every update so it applies without a restart.
+// 0 or less disables the cap.
+func maxSchedulers() int {
+	return config.GetIntWithDefault("openagent_max_schedulers", DefaultMaxSchedulers)
+}
+
+// schedulerCap keeps the number of target schedulers, one goroutine each, under openagent_max_schedulers.
+// A selector that matches far more than intended, like empty matchLabels, would otherwise start a
+// scheduler for every pod in the cluster and starve the agent.
+type schedulerCap struct {
+	mu sync.Mutex
+	// excluded are the unscheduled targets per target config on the last update
+	excluded map[string]int
+	// lastLog is when the ERROR log was last written, zero while within the cap
+	lastLog time.Time
+}
+
+// targetConfigName returns the name of the target config a target was discovered from
+func targetConfigName(target *discovery.Target) string {
+	name, _ := target.Metadata["targetName"].(string)
+	return name
+}
+
+// selectWithinCap returns at most maxSchedulers targets to schedule and the targets left out. The choice
+// follows selectWithinBudgets: highest priorities first, then targets that already have a scheduler,
+// then by ID, so running schedulers are not replaced by new targets.
+func selectWithinCap(targets []*discovery.Target, maxSchedulers int, scheduled func(string) bool) ([]*discovery.Target, []*discovery.Target) {
+	if maxSchedulers <= 0 || len(targets) <= maxSchedulers {
+		return targets, nil
+	}
+	sorted := make([]*discovery.Target, len(targets))
+	copy(sorted, targets)
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if pa, pb := targetPriority(a), targetPriority(b); pa != pb {
+			return pa > pb
+		}
+		if sa, sb := scheduled(a.ID), scheduled(b.ID); sa != sb {
+			return sa
+		}
+		return a.ID < b.ID
+	})
+	return sorted[:maxSchedulers], sorted[maxSchedulers:]
+}
+
+// applySchedulerCap leaves the targets beyond openagent_max_schedulers out of the ready targets and
+// returns the others with the IDs left out. Targets left out are picked up by a later update once the
+// ready targets drop below the cap.
+func (sm *ScraperManager) applySchedulerCap(targets []*discovery.Target) ([]*discovery.Target, map[string]bool) {
+	limit := maxSchedulers()
+	kept, dropped := selectWithinCap(targets, limit, func(targetID string) bool {
+		sm.schedulerMutex.RLock()
+		defer sm.schedulerMutex.RUnlock()
+		_, exists := sm.targetSchedulers[targetID]
+		return exists
+	})
+
+	overCap := make(map[string]bool, len(dropped))
+	excluded := make(map[string]int)
+	for _, target := range dropped {
+		overCap[target.ID] = true
+		excluded[targetConfigName(target)]++
+	}
+	unscheduledTargets.Store(int64(len(dropped)))
+
+	sc := &sm.schedulerCap
+	sc.mu.Lock()
+	wasOver := len(sc.excluded) > 0
+	sc.excluded = excluded
+	logNow := len(dropped) > 0 && time.Since(sc.lastLog) >= schedulerCapLogInterval
+	if logNow {
+		sc.lastLog = time.Now()
+	} else if len(dropped) == 0 {
+		sc.lastLog = time.Time{}
+	}
+	sc.mu.Unlock()
+
+	if logNow {
+		logutil.Printf("ERROR", "[SCRAPER] %d ready targets exceed openagent_max_schedulers %d, not scheduling %d of them; most targets from: %s",
+			len(targets), limit, len(dropped), topTargetConfigs(targets))
+	} else if wasOver && len(dropped) == 0 {
+		logutil.Printf("INFO", "[SCRAPER] %d ready targets are within openagent_max_schedulers %d again, scheduling all of them",
+			len(targets), limit)
+	}
+	return kept, overCap
+}
+
+// topTargetConfigs lists the target configs with the most ready targets, the likely cause of a burst
+func topTargetConfigs(targets []*discovery.Target) string {
+	counts := make(map[string]int)
+	for _, target := range targets {
+		counts[targetConfigName(target)]++
+	}
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] != counts[names[j]] {
+			return counts[names[i]] > counts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+
+	listed := make([]string, 0, maxLoggedCapConfigs+1)
+	for i, name := range names {
+		if i == maxLoggedCapConfigs {
+			listed = append(listed, "...")
+			break
+		}
+		listed = append(listed, fmt.Sprintf("%s (%d)", name, counts[name]))
+	}
+	return strings.Join(listed, ", ")
+}
+
+// unscheduledResult returns the unscheduled target counts per target config, or nil when the agent is
+// within the cap
+func (sc *schedulerCap) unscheduledResult(now int64) *model.ConversionResult {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if len(sc.excluded) == 0 {
+		return nil
+	}
+
+	series := make([]*model.OpenMx, 0, len(sc.excluded))
+	for name, count := range sc.excluded {
+		om := model.NewOpenMx(MetricUnscheduledTargets, now, float64(count))
+		om.AddLabel("targetName", name)
+		series = append(series, om)
+	}
+
+	help := model.NewOpenMxHelp(MetricUnscheduledTargets)
+	help.Put("help", "Ready targets of the target config not scheduled because the agent reached openagent_max_schedulers")
+	help.Put("type", "gauge")
+	result := model.NewConversionResult(series, []*model.OpenMxHelp{help})
+	result.SetCollectionTime(now)
+	return result
+}
+
+// sendUnscheduledTargets queues the unscheduled target counts
+func (sm *ScraperManager) sendUnscheduledTargets() {
+	result := sm.schedulerCap.unscheduledResult(time.Now().UnixMilli())
+	if result == nil {
+		return
+	}
+	select {
+	case sm.selfMetricsQueue <- result:
+	default:
+		logutil.Printf("WARN", "[SCRAPER] Processed queue is full, dropping unscheduled target counts")
+	}
+}
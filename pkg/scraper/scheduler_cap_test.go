@@ -0,0 +1,114 @@
+package scraper
+
+import (
+	"fmt"
+	"testing"
+
+	"open-agent/pkg/config"
+	"open-agent/pkg/discovery"
+	"open-agent/pkg/discovery/discoverytest"
+	"open-agent/pkg/model"
+)
+
+// burstTargets returns n targets of one target config, as a selector matching too many pods would
+func burstTargets(targetName, url string, n int) []*discovery.Target {
+	targets := make([]*discovery.Target, 0, n)
+	for i := 0; i < n; i++ {
+		target := discoverytest.Target(fmt.Sprintf("%s-%03d", targetName, i), url, discovery.EndpointConfig{Path: "/metrics", Interval: "60s"})
+		target.Metadata["targetName"] = targetName
+		targets = append(targets, target)
+	}
+	return targets
+}
+
+func TestSchedulerCap_BurstBeyondCap(t *testing.T) {
+	t.Setenv("WHATAP_OPEN_HOME", t.TempDir())
+	t.Setenv("openagent_max_schedulers", "20")
+	exporter := discoverytest.NewExporter("up 1\n")
+	defer exporter.Close()
+
+	sd := discoverytest.New(burstTargets("good", exporter.URL(), 5)...)
+	selfMetrics := make(chan *model.ConversionResult, 10)
+	sm := NewScraperManager(&config.ConfigManager{}, sd, make(chan *model.ScrapeRawData, 1000), "")
+	sm.SetSelfMetricsQueue(selfMetrics)
+	defer sm.Stop()
+	defer sm.stopAllSchedulers()
+
+	sm.updateTargetSchedulers()
+	if count, _ := sm.ActiveSchedulerCount(); count != 5 {
+		t.Fatalf("active schedulers = %d, want 5", count)
+	}
+
+	// A selector typo matches far more targets than the cap
+	sd.Add(burstTargets("typo", exporter.URL(), 40)...)
+	sm.updateTargetSchedulers()
+	if count, _ := sm.ActiveSchedulerCount(); count != 20 {
+		t.Fatalf("active schedulers = %d, want the cap of 20", count)
+	}
+	if got := UnscheduledTargets(); got != 25 {
+		t.Errorf("UnscheduledTargets() = %d, want 25", got)
+	}
+	// Targets that were already scheduled keep their schedulers
+	for i := 0; i < 5; i++ {
+		if id := fmt.Sprintf("good-%03d", i); schedulerFor(sm, id) == nil {
+			t.Errorf("%s lost its scheduler", id)
+		}
+	}
+
+	sm.sendUnscheduledTargets()
+	result := <-selfMetrics
+	unscheduled := make(map[string]float64)
+	for _, om := range result.GetOpenMxList() {
+		if om.Metric != MetricUnscheduledTargets {
+			t.Fatalf("unexpected metric %s", om.Metric)
+		}
+		for _, label := range om.Labels {
+			if label.Key == "targetName" {
+				unscheduled[label.Value] = om.Value
+			}
+		}
+	}
+	if len(unscheduled) != 1 || unscheduled["typo"] != 25 {
+		t.Errorf("unscheduled targets = %v, want typo=25", unscheduled)
+	}
+
+	// Fixing the selector drops the count below the cap and the remaining targets are picked up
+	var typoIDs []string
+	for i := 10; i < 40; i++ {
+		typoIDs = append(typoIDs, fmt.Sprintf("typo-%03d", i))
+	}
+	sd.Remove(typoIDs...)
+	sm.updateTargetSchedulers()
+	if count, _ := sm.ActiveSchedulerCount(); count != 15 {
+		t.Errorf("active schedulers = %d, want 15", count)
+	}
+	if got := UnscheduledTargets(); got != 0 {
+		t.Errorf("UnscheduledTargets() = %d, want 0", got)
+	}
+	sm.sendUnscheduledTargets()
+	select {
+	case result := <-selfMetrics:
+		t.Errorf("unexpected unscheduled counts within the cap: %v", result.GetOpenMxList())
+	default:
+	}
+}
+
+func TestSchedulerCap_Disabled(t *testing.T) {
+	t.Setenv("openagent_max_schedulers", "0")
+	targets := burstTargets("all", "http://127.0.0.1:1", DefaultMaxSchedulers+1)
+	kept, dropped := selectWithinCap(targets, maxSchedulers(), func(string) bool { return false })
+	if len(kept) != len(targets) || len(dropped) != 0 {
+		t.Errorf("kept %d and dropped %d targets with the cap disabled, want %d and 0", len(kept), len(dropped), len(targets))
+	}
+}
+
+func TestTopTargetConfigs(t *testing.T) {
+	var targets []*discovery.Target
+	for i, name := range []string{"a", "b", "c", "d", "e", "f"} {
+		targets = append(targets, burstTargets(name, "http://127.0.0.1:1", i+1)...)
+	}
+	want := "f (6), e (5), d (4), c (3), b (2), ..."
+	if got := topTargetConfigs(targets); got != want {
+		t.Errorf("topTargetConfigs() = %q, want %q", got, want)
+	}
+}
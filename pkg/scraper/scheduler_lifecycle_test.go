@@ -0,0 +1,132 @@
+package scraper
+
+import (
+	"testing"
+	"time"
+
+	"open-agent/pkg/config"
+	"open-agent/pkg/discovery"
+	"open-agent/pkg/discovery/discoverytest"
+	"open-agent/pkg/model"
+)
+
+// schedulerFor returns the scheduler of a target, or nil
+func schedulerFor(sm *ScraperManager, id string) *TargetScheduler {
+	sm.schedulerMutex.RLock()
+	defer sm.schedulerMutex.RUnlock()
+	return sm.targetSchedulers[id]
+}
+
+// waitForState polls the scheduler states until cond holds or the deadline passes
+func waitForState(t *testing.T, sm *ScraperManager, cond func([]SchedulerState) bool) []SchedulerState {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		states := sm.GetSchedulerStates()
+		if cond(states) || time.Now().After(deadline) {
+			return states
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestSchedulerLifecycle_FollowsDiscovery(t *testing.T) {
+	exporter := discoverytest.NewExporter("up 1\n")
+	defer exporter.Close()
+	endpoint := discovery.EndpointConfig{Path: "/metrics", Interval: "1s"}
+
+	sd := discoverytest.New(discoverytest.Target("a", exporter.URL(), endpoint))
+	rawQueue := make(chan *model.ScrapeRawData, 100)
+	sm := NewScraperManager(&config.ConfigManager{}, sd, rawQueue, "")
+	defer sm.Stop()
+	defer sm.stopAllSchedulers()
+
+	// A new target gets a scheduler that scrapes it
+	sm.updateTargetSchedulers()
+	select {
+	case rawData := <-rawQueue:
+		if rawData.TargetURL != exporter.URL() {
+			t.Errorf("unexpected target URL %q", rawData.TargetURL)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected a scrape of the new target")
+	}
+
+	// Adding a target starts a second scheduler
+	sd.Add(discoverytest.Target("b", exporter.URL(), endpoint))
+	sm.updateTargetSchedulers()
+	if states := sm.GetSchedulerStates(); len(states) != 2 || states[1].TargetID != "b" {
+		t.Fatalf("expected schedulers for a and b, got %+v", states)
+	}
+
+	// An endpoint change keeps the scheduler and applies the new target
+	original := schedulerFor(sm, "a")
+	sd.Add(discoverytest.Target("a", exporter.URL(), discovery.EndpointConfig{Path: "/metrics", Interval: "1s", Timeout: "5s"}))
+	sm.updateTargetSchedulers()
+	if updated := schedulerFor(sm, "a"); updated != original {
+		t.Error("expected an endpoint change to keep the scheduler")
+	} else if ep := updated.getTarget().Metadata["endpoint"].(discovery.EndpointConfig); ep.Timeout != "5s" {
+		t.Errorf("expected the updated endpoint, got %+v", ep)
+	}
+
+	// An interval change restarts the scheduler
+	sd.Add(discoverytest.Target("a", exporter.URL(), discovery.EndpointConfig{Path: "/metrics", Interval: "2s"}))
+	sm.updateTargetSchedulers()
+	if restarted := schedulerFor(sm, "a"); restarted == original || restarted.interval != 2*time.Second {
+		t.Errorf("expected a restarted scheduler with a 2s interval")
+	}
+
+	// Targets that stop being ready or disappear lose their scheduler
+	sd.SetState("b", discovery.TargetStatePending)
+	sm.updateTargetSchedulers()
+	if schedulerFor(sm, "b") != nil {
+		t.Error("expected the scheduler of a pending target to stop")
+	}
+	sd.Remove("a")
+	sm.updateTargetSchedulers()
+	if states := sm.GetSchedulerStates(); len(states) != 0 {
+		t.Errorf("expected no schedulers, got %+v", states)
+	}
+}
+
+func TestSchedulerLifecycle_RecordsExporterFailures(t *testing.T) {
+	exporter := discoverytest.NewExporter("up 1\n")
+	defer exporter.Close()
+	exporter.FailNext(1)
+
+	sd := discoverytest.New(discoverytest.Target("flaky", exporter.URL(), discovery.EndpointConfig{Path: "/metrics", Interval: "1s"}))
+	sm := NewScraperManager(&config.ConfigManager{}, sd, make(chan *model.ScrapeRawData, 100), "")
+	defer sm.Stop()
+	sm.updateTargetSchedulers()
+	defer sm.stopAllSchedulers()
+
+	states := waitForState(t, sm, func(states []SchedulerState) bool { return len(states) == 1 && states[0].LastError != "" })
+	if len(states) != 1 || states[0].LastError == "" {
+		t.Fatalf("expected the injected failure to be recorded, got %+v", states)
+	}
+
+	// The next scrape succeeds and clears the error
+	states = waitForState(t, sm, func(states []SchedulerState) bool { return len(states) == 1 && states[0].LastError == "" })
+	if len(states) != 1 || states[0].LastError != "" {
+		t.Fatalf("expected the error to clear after recovery, got %+v", states)
+	}
+}
+
+func TestSchedulerLifecycle_SlowExporterSkipsOverlappingScrapes(t *testing.T) {
+	exporter := discoverytest.NewExporter("up 1\n")
+	defer exporter.Close()
+	exporter.SetLatency(2500 * time.Millisecond)
+
+	sd := discoverytest.New(discoverytest.Target("slow", exporter.URL(), discovery.EndpointConfig{Path: "/metrics", Interval: "1s", Timeout: "10s"}))
+	sm := NewScraperManager(&config.ConfigManager{}, sd, make(chan *model.ScrapeRawData, 100), "")
+	defer sm.Stop()
+	sm.updateTargetSchedulers()
+	defer sm.stopAllSchedulers()
+
+	time.Sleep(3200 * time.Millisecond)
+
+	// Ticks at 1s and 2s find the first scrape still in progress, so only one request is made before 3s
+	if n := exporter.Requests(); n != 1 {
+		t.Errorf("expected overlapping scrapes to be skipped, got %d requests", n)
+	}
+}
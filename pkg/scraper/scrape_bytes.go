@@ -0,0 +1,121 @@
+package scraper
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"open-agent/pkg/model"
+	"open-agent/tools/util/logutil"
+)
+
+// Self-metric names for the scrape byte counters
+const (
+	MetricScrapeBytes     = "openagent_scrape_bytes_total"
+	MetricScrapeBodyBytes = "openagent_scrape_body_bytes_total"
+)
+
+// Agent-wide totals, never reset, reported in the keep-alive pack
+var scrapeWireTotal, scrapeBodyTotal atomic.Int64
+
+// ScrapeBytesTotals returns the response bytes scraped since the agent started,
+// on the wire and after decoding
+func ScrapeBytesTotals() (wire, body int64) {
+	return scrapeWireTotal.Load(), scrapeBodyTotal.Load()
+}
+
+// byteCount is the cumulative response size of one target
+type byteCount struct {
+	wire int64
+	body int64
+}
+
+// scrapeBytes holds the per-target byte counters
+type scrapeBytes struct {
+	mu      sync.Mutex
+	targets map[string]*byteCount
+}
+
+// add records the response size of one scrape
+func (b *scrapeBytes) add(target string, wire, body int64) {
+	if wire == 0 && body == 0 {
+		return
+	}
+	scrapeWireTotal.Add(wire)
+	scrapeBodyTotal.Add(body)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.targets == nil {
+		b.targets = make(map[string]*byteCount)
+	}
+	count, ok := b.targets[target]
+	if !ok {
+		count = &byteCount{}
+		b.targets[target] = count
+	}
+	count.wire += wire
+	count.body += body
+}
+
+// collect drops the counters of targets no longer scraped and returns the remaining
+// counters as self-metric series, or nil if there are none
+func (b *scrapeBytes) collect(now int64, active func(target string) bool) *model.ConversionResult {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	targets := make([]string, 0, len(b.targets))
+	for target := range b.targets {
+		if !active(target) {
+			delete(b.targets, target)
+			continue
+		}
+		targets = append(targets, target)
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+	sort.Strings(targets)
+
+	series := make([]*model.OpenMx, 0, 2*len(targets))
+	for _, target := range targets {
+		count := b.targets[target]
+		wire := model.NewOpenMx(MetricScrapeBytes, now, float64(count.wire))
+		wire.AddLabel("target", target)
+		body := model.NewOpenMx(MetricScrapeBodyBytes, now, float64(count.body))
+		body.AddLabel("target", target)
+		series = append(series, wire, body)
+	}
+
+	wireHelp := model.NewOpenMxHelp(MetricScrapeBytes)
+	wireHelp.Put("help", "Scrape response bytes received from the target, compressed if the target used gzip")
+	wireHelp.Put("type", "counter")
+	bodyHelp := model.NewOpenMxHelp(MetricScrapeBodyBytes)
+	bodyHelp.Put("help", "Scrape response bytes from the target after decoding")
+	bodyHelp.Put("type", "counter")
+
+	result := model.NewConversionResult(series, []*model.OpenMxHelp{wireHelp, bodyHelp})
+	result.SetCollectionTime(now)
+	return result
+}
+
+// sendScrapeBytes queues the scrape byte counters of the targets that still have a scheduler
+func (sm *ScraperManager) sendScrapeBytes() {
+	sm.schedulerMutex.RLock()
+	active := make(map[string]bool, len(sm.targetSchedulers))
+	for targetID := range sm.targetSchedulers {
+		active[targetID] = true
+	}
+	sm.schedulerMutex.RUnlock()
+
+	result := sm.scrapeBytes.collect(time.Now().UnixMilli(), func(target string) bool { return active[target] })
+	if result == nil {
+		return
+	}
+	select {
+	case sm.selfMetricsQueue <- result:
+	default:
+		logutil.Printf("WARN", "[SCRAPER] Processed queue is full, dropping scrape byte counters of %d targets", len(result.OpenMxList)/2)
+	}
+}
@@ -0,0 +1,45 @@
+package scraper
+
+import (
+	"testing"
+)
+
+func TestScrapeBytes_CollectDropsStaleTargets(t *testing.T) {
+	wireBefore, bodyBefore := ScrapeBytesTotals()
+
+	var b scrapeBytes
+	b.add("app/default/pod-a/8080", 1200, 9000)
+	b.add("app/default/pod-a/8080", 800, 6000)
+	b.add("app/default/pod-b/8080", 500, 500)
+	b.add("app/default/pod-c/8080", 0, 0)
+
+	active := map[string]bool{"app/default/pod-a/8080": true, "app/default/pod-b/8080": true}
+	result := b.collect(1700000000000, func(target string) bool { return active[target] })
+	if result == nil || len(result.OpenMxList) != 4 {
+		t.Fatalf("expected 4 series, got %+v", result)
+	}
+	want := map[string]float64{
+		MetricScrapeBytes + "/app/default/pod-a/8080":     2000,
+		MetricScrapeBodyBytes + "/app/default/pod-a/8080": 15000,
+		MetricScrapeBytes + "/app/default/pod-b/8080":     500,
+		MetricScrapeBodyBytes + "/app/default/pod-b/8080": 500,
+	}
+	for _, om := range result.OpenMxList {
+		key := om.Metric + "/" + om.Labels[0].Value
+		if om.Value != want[key] || om.Timestamp != 1700000000000 {
+			t.Errorf("%s: expected %v, got %v at %d", key, want[key], om.Value, om.Timestamp)
+		}
+	}
+
+	// pod-b disappeared: its series are no longer sent and its counters are released
+	delete(active, "app/default/pod-b/8080")
+	result = b.collect(1700000060000, func(target string) bool { return active[target] })
+	if len(result.OpenMxList) != 2 || len(b.targets) != 1 {
+		t.Fatalf("expected only pod-a to remain, got %d series, %d targets", len(result.OpenMxList), len(b.targets))
+	}
+
+	wireAfter, bodyAfter := ScrapeBytesTotals()
+	if wireAfter-wireBefore != 2500 || bodyAfter-bodyBefore != 15500 {
+		t.Errorf("unexpected totals delta: %d/%d", wireAfter-wireBefore, bodyAfter-bodyBefore)
+	}
+}
@@ -0,0 +1,165 @@
+package scraper
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"open-agent/pkg/config"
+	"open-agent/pkg/discovery"
+	"open-agent/pkg/k8s"
+	"open-agent/tools/util/logutil"
+)
+
+const (
+	// DefaultScrapeFailureEventThreshold is the number of consecutive failed scrapes before a ScrapeFailing Event
+	DefaultScrapeFailureEventThreshold = 10
+
+	// scrapeEventInterval rate-limits ScrapeFailing Events per target
+	scrapeEventInterval = 15 * time.Minute
+
+	// scrapeEventQueueSize bounds the Events waiting to be posted; Events beyond it are dropped
+	scrapeEventQueueSize = 64
+
+	// Event reasons posted on the scraped Pod or Service
+	ReasonScrapeFailing   = "ScrapeFailing"
+	ReasonScrapeRecovered = "ScrapeRecovered"
+)
+
+// scrapeFailureEventThreshold returns whatap.conf openagent_scrape_failure_event_threshold; 0 disables Events
+func scrapeFailureEventThreshold() int {
+	return config.GetIntWithDefault("openagent_scrape_failure_event_threshold", DefaultScrapeFailureEventThreshold)
+}
+
+// eventRecorder posts Kubernetes Events, implemented by k8s.EventRecorder
+type eventRecorder interface {
+	Event(ref k8s.ObjectRef, eventType, reason, message string) error
+}
+
+// defaultEventRecorder returns the Kubernetes client's recorder, or nil outside Kubernetes
+func defaultEventRecorder() eventRecorder {
+	if recorder := k8s.GetInstance().EventRecorder(); recorder != nil {
+		return recorder
+	}
+	return nil
+}
+
+// scrapeEventState tracks the failure streak of one target
+type scrapeEventState struct {
+	failures      int
+	failingPosted bool // a ScrapeFailing Event was posted for the current streak
+	lastEvent     time.Time
+}
+
+// pendingEvent is an Event queued for posting
+type pendingEvent struct {
+	recorder                   eventRecorder
+	ref                        k8s.ObjectRef
+	eventType, reason, message string
+}
+
+// scrapeEvents posts an Event on the scraped object when a target keeps failing, and another
+// when it recovers. ScrapeFailing Events are posted at most once per scrapeEventInterval per target;
+// a ScrapeRecovered Event is only posted after a ScrapeFailing Event. Events are posted by run, so a
+// slow apiserver never holds up the scrape that triggered them.
+type scrapeEvents struct {
+	mu       sync.Mutex
+	targets  map[string]*scrapeEventState
+	recorder func() eventRecorder
+	now      func() time.Time
+	queue    chan pendingEvent
+}
+
+func newScrapeEvents() *scrapeEvents {
+	return &scrapeEvents{
+		targets:  make(map[string]*scrapeEventState),
+		recorder: defaultEventRecorder,
+		now:      time.Now,
+		queue:    make(chan pendingEvent, scrapeEventQueueSize),
+	}
+}
+
+// run posts the queued Events until stopCh is closed
+func (e *scrapeEvents) run(stopCh <-chan struct{}) {
+	for {
+		select {
+		case event := <-e.queue:
+			e.post(event)
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// post posts one queued Event, logging a failure
+func (e *scrapeEvents) post(event pendingEvent) {
+	if err := event.recorder.Event(event.ref, event.eventType, event.reason, event.message); err != nil {
+		logutil.Printf("WARN", "[SCRAPER] Failed to post %s event on %s: %v", event.reason, event.ref, err)
+	}
+}
+
+// observe records the result of a scrape and posts an Event when the target starts or stops failing
+func (e *scrapeEvents) observe(target *discovery.Target, err error) {
+	ref, ok := target.Metadata["objectRef"].(k8s.ObjectRef)
+	if !ok {
+		return // static endpoints have no Kubernetes object
+	}
+	threshold := scrapeFailureEventThreshold()
+	if threshold <= 0 {
+		return
+	}
+
+	var eventType, reason, message string
+	now := e.now()
+
+	e.mu.Lock()
+	state, exists := e.targets[target.ID]
+	if err != nil {
+		if !exists {
+			state = &scrapeEventState{}
+			e.targets[target.ID] = state
+		}
+		state.failures++
+		if state.failures >= threshold && !state.failingPosted &&
+			(state.lastEvent.IsZero() || now.Sub(state.lastEvent) >= scrapeEventInterval) {
+			state.failingPosted = true
+			state.lastEvent = now
+			eventType, reason = corev1.EventTypeWarning, ReasonScrapeFailing
+			message = fmt.Sprintf("Scraping %s failed %d consecutive times: %v", target.URL, state.failures, err)
+		}
+	} else if exists {
+		if state.failingPosted {
+			eventType, reason = corev1.EventTypeNormal, ReasonScrapeRecovered
+			message = fmt.Sprintf("Scraping %s succeeded after %d consecutive failures", target.URL, state.failures)
+		}
+		state.failures = 0
+		state.failingPosted = false
+		// Keep the state only while it still rate-limits the next ScrapeFailing Event
+		if now.Sub(state.lastEvent) >= scrapeEventInterval {
+			delete(e.targets, target.ID)
+		}
+	}
+	e.mu.Unlock()
+
+	if reason == "" {
+		return
+	}
+	recorder := e.recorder()
+	if recorder == nil {
+		return
+	}
+	select {
+	case e.queue <- pendingEvent{recorder: recorder, ref: ref, eventType: eventType, reason: reason, message: message}:
+	default:
+		logutil.Printf("WARN", "[SCRAPER] Event queue full, dropping %s event on %s", reason, ref)
+	}
+}
+
+// forget drops the failure streak of a target that is no longer scraped
+func (e *scrapeEvents) forget(targetID string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.targets, targetID)
+}
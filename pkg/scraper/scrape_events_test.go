@@ -0,0 +1,179 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"open-agent/pkg/discovery"
+	"open-agent/pkg/k8s"
+)
+
+// newTestScrapeEvents returns scrapeEvents posting through a fake clientset with a settable clock
+func newTestScrapeEvents(t *testing.T) (*scrapeEvents, *fake.Clientset, *time.Time) {
+	t.Helper()
+	client := fake.NewSimpleClientset()
+	recorder := k8s.NewEventRecorder(client)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	e := newScrapeEvents()
+	e.recorder = func() eventRecorder { return recorder }
+	e.now = func() time.Time { return now }
+	return e, client, &now
+}
+
+// postQueued posts the queued Events, as run does in the background
+func postQueued(e *scrapeEvents) {
+	for {
+		select {
+		case event := <-e.queue:
+			e.post(event)
+		default:
+			return
+		}
+	}
+}
+
+// listEventReasons posts the queued Events and returns the reasons of the posted ones
+func listEventReasons(t *testing.T, e *scrapeEvents, client *fake.Clientset) []string {
+	t.Helper()
+	postQueued(e)
+	events, err := client.CoreV1().Events("team-a").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("list events: %v", err)
+	}
+	var reasons []string
+	for _, event := range events.Items {
+		reasons = append(reasons, event.Reason)
+	}
+	return reasons
+}
+
+func podTarget() *discovery.Target {
+	return &discovery.Target{
+		ID:  "app/team-a/api-0/8080-metrics",
+		URL: "http://10.0.3.17:8080/metrics",
+		Metadata: map[string]interface{}{
+			"objectRef": k8s.ObjectRef{Kind: "Pod", Namespace: "team-a", Name: "api-0", UID: "uid-api-0"},
+		},
+	}
+}
+
+func TestScrapeEvents_ThresholdAndRecovery(t *testing.T) {
+	t.Setenv("openagent_scrape_failure_event_threshold", "3")
+	e, client, _ := newTestScrapeEvents(t)
+	target := podTarget()
+	scrapeErr := errors.New("connection refused")
+
+	for i := 0; i < 2; i++ {
+		e.observe(target, scrapeErr)
+	}
+	if reasons := listEventReasons(t, e, client); len(reasons) != 0 {
+		t.Fatalf("expected no event below the threshold, got %v", reasons)
+	}
+
+	// Reaching the threshold posts one event, further failures of the same streak do not
+	for i := 0; i < 5; i++ {
+		e.observe(target, scrapeErr)
+	}
+	postQueued(e)
+	events, _ := client.CoreV1().Events("team-a").List(context.Background(), metav1.ListOptions{})
+	if len(events.Items) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(events.Items))
+	}
+	event := events.Items[0]
+	if event.Type != corev1.EventTypeWarning || event.Reason != ReasonScrapeFailing || event.InvolvedObject.Name != "api-0" {
+		t.Errorf("unexpected event %s/%s on %s", event.Type, event.Reason, event.InvolvedObject.Name)
+	}
+
+	e.observe(target, nil)
+	e.observe(target, nil)
+	reasons := listEventReasons(t, e, client)
+	if len(reasons) != 2 || reasons[1] != ReasonScrapeRecovered {
+		t.Fatalf("expected a single recovery event, got %v", reasons)
+	}
+}
+
+func TestScrapeEvents_RateLimited(t *testing.T) {
+	t.Setenv("openagent_scrape_failure_event_threshold", "2")
+	e, client, now := newTestScrapeEvents(t)
+	target := podTarget()
+	scrapeErr := errors.New("connection refused")
+
+	flap := func() {
+		for i := 0; i < 2; i++ {
+			e.observe(target, scrapeErr)
+		}
+		e.observe(target, nil)
+		*now = now.Add(time.Minute)
+	}
+
+	// A flapping target posts one failing event per 15 minutes
+	for i := 0; i < 5; i++ {
+		flap()
+	}
+	if reasons := listEventReasons(t, e, client); len(reasons) != 2 {
+		t.Fatalf("expected one failing and one recovery event, got %v", reasons)
+	}
+
+	*now = now.Add(scrapeEventInterval)
+	flap()
+	if reasons := listEventReasons(t, e, client); len(reasons) != 4 {
+		t.Fatalf("expected new events after the rate limit interval, got %v", reasons)
+	}
+}
+
+func TestScrapeEvents_Disabled(t *testing.T) {
+	t.Setenv("openagent_scrape_failure_event_threshold", "0")
+	e, client, _ := newTestScrapeEvents(t)
+	for i := 0; i < 20; i++ {
+		e.observe(podTarget(), errors.New("connection refused"))
+	}
+	// Static targets without an object reference never post events either
+	e.observe(&discovery.Target{ID: "static/0"}, errors.New("connection refused"))
+	if reasons := listEventReasons(t, e, client); len(reasons) != 0 {
+		t.Fatalf("expected no events, got %v", reasons)
+	}
+}
+
+func TestScrapeEvents_QueueFullDropsEvents(t *testing.T) {
+	t.Setenv("openagent_scrape_failure_event_threshold", "1")
+	e, client, _ := newTestScrapeEvents(t)
+	failPod := func(i int) {
+		name := fmt.Sprintf("api-%d", i)
+		e.observe(&discovery.Target{
+			ID:       "app/team-a/" + name + "/8080-metrics",
+			URL:      "http://10.0.3.17:8080/metrics",
+			Metadata: map[string]interface{}{"objectRef": k8s.ObjectRef{Kind: "Pod", Namespace: "team-a", Name: name}},
+		}, errors.New("connection refused"))
+	}
+
+	// Nothing posts while the queue fills up, observe must not block
+	for i := 0; i < scrapeEventQueueSize+10; i++ {
+		failPod(i)
+	}
+	if reasons := listEventReasons(t, e, client); len(reasons) != scrapeEventQueueSize {
+		t.Fatalf("expected %d events posted and the rest dropped, got %d", scrapeEventQueueSize, len(reasons))
+	}
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go e.run(stopCh)
+	failPod(scrapeEventQueueSize + 10)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		events, _ := client.CoreV1().Events("team-a").List(context.Background(), metav1.ListOptions{})
+		if len(events.Items) == scrapeEventQueueSize+1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected run to post the queued event, got %d events", len(events.Items))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
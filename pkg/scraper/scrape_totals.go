@@ -0,0 +1,13 @@
+package scraper
+
+import "sync/atomic"
+
+// Agent-wide scrape counts, never reset, reported in the agent status pack
+var scrapesTotal, scrapeFailuresTotal atomic.Int64
+
+// ScrapeTotals returns the scrapes completed and failed since the agent started, and the
+// response bytes received on the wire
+func (sm *ScraperManager) ScrapeTotals() (scrapes, failures, bytes int64) {
+	wire, _ := ScrapeBytesTotals()
+	return scrapesTotal.Load(), scrapeFailuresTotal.Load(), wire
+}
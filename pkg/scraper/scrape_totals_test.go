@@ -0,0 +1,21 @@
+package scraper
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestScrapeTotalsCountScrapesAndFailures(t *testing.T) {
+	sm := &ScraperManager{}
+	scrapesBefore, failuresBefore, _ := sm.ScrapeTotals()
+
+	ts := &TargetScheduler{}
+	ts.recordScrape(nil)
+	ts.recordScrape(errors.New("connection refused"))
+	ts.recordScrape(nil)
+
+	scrapes, failures, _ := sm.ScrapeTotals()
+	if scrapes-scrapesBefore != 3 || failures-failuresBefore != 1 {
+		t.Errorf("expected 3 scrapes and 1 failure, got %d and %d", scrapes-scrapesBefore, failures-failuresBefore)
+	}
+}
@@ -1,16 +1,23 @@
 package scraper
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"open-agent/pkg/client"
 	"open-agent/pkg/config"
+	"open-agent/pkg/diagnostics"
 	"open-agent/pkg/discovery"
 	"open-agent/pkg/k8s"
 	"open-agent/pkg/model"
@@ -42,6 +49,15 @@ func buildTLSConfig(m map[string]interface{}) *client.TLSConfig {
 	if v, ok := m["keyFile"].(string); ok {
 		tlsConfig.KeyFile = v
 	}
+	if v, ok := m["minVersion"].(string); ok {
+		tlsConfig.MinVersion = v
+	}
+	if v, ok := m["maxVersion"].(string); ok {
+		tlsConfig.MaxVersion = v
+	}
+	if v, ok := m["renegotiation"].(string); ok {
+		tlsConfig.Renegotiation = v
+	}
 	if config.IsDebugEnabled() {
 		logutil.Printf("DEBUG", "[SCRAPER] TLS config: insecureSkipVerify=%v, serverName=%s, caFile=%s, certFile=%s, keyFile=%s",
 			tlsConfig.InsecureSkipVerify, tlsConfig.ServerName, tlsConfig.CAFile, tlsConfig.CertFile, tlsConfig.KeyFile)
@@ -69,6 +85,102 @@ type TargetScheduler struct {
 	baseTimeout            time.Duration // 기본(초기) 타임아웃
 	maxTimeout             time.Duration // 최대 타임아웃 제한
 	timeoutMu              sync.Mutex    // 타임아웃 관련 필드 보호
+
+	// 상태 스냅샷을 위한 필드
+	lastScrapeTime time.Time  // 마지막 스크래핑 완료 시각
+	lastScrapeErr  string     // 마지막 스크래핑 오류 (성공 시 빈 문자열)
+	statusMu       sync.Mutex // 상태 필드 보호
+
+	// rawQueue가 가득 차서 버려진 스크래핑 결과 수
+	droppedScrapes atomic.Int64
+	// 일시 정지로 건너뛴 스크래핑 수
+	pausedScrapes atomic.Int64
+	// 타겟이 429/503 Retry-After로 요청한 다음 스크래핑 시각 (statusMu로 보호), 그 전의 tick은 건너뜀
+	deferredUntil time.Time
+	// Retry-After로 다음 스크래핑을 미룬 횟수와 그로 인해 건너뛴 스크래핑 수
+	deferrals       atomic.Int64
+	deferredScrapes atomic.Int64
+	// 과부하 차단기가 열린 뒤의 tick 수 (스케줄러 고루틴에서만 접근)
+	overloadTicks int64
+	// 파드 재시작이 감지되어 다음 스크래핑에 openagent_target_restarted를 보내야 하는지 (mutex로 보호)
+	restartPending bool
+	// https 타겟의 인증서를 확인했는지와 마지막으로 새 TLS 핸드셰이크를 유도한 시각 (statusMu로 보호)
+	certSeen        bool
+	certHandshakeAt time.Duration
+	// 스케줄러 고루틴이 종료되면 닫힘 (stopTargetScheduler가 종료를 기다림)
+	done chan struct{}
+	// 중지 후에도 남은 고루틴을 정리할 때 취소되는 컨텍스트 (스크래핑 요청과 rawQueue 전송에 사용)
+	reapCtx context.Context
+	reap    context.CancelFunc
+	// 스케줄러가 중지된 시각 (statusMu로 보호)
+	stoppedAt time.Time
+}
+
+// SchedulerState is a point-in-time view of a target scheduler, used for state snapshots
+type SchedulerState struct {
+	TargetID   string
+	URL        string
+	Interval   time.Duration
+	Timeout    time.Duration
+	LastScrape time.Time
+	LastError  string
+	InProgress bool
+	Dropped    int64 // scrape results dropped because the raw queue was full
+	// PausedUntil is when the target's pause expires, zero if it is not paused
+	PausedUntil   time.Time
+	PausedScrapes int64 // scrapes skipped while paused
+	// DeferredUntil is when a rate-limited target asked to be scraped again, zero if it is not deferred
+	DeferredUntil   time.Time
+	Deferrals       int64 // scrapes answered with 429 or 503 and Retry-After
+	DeferredScrapes int64 // scrapes skipped until Retry-After
+}
+
+// recordScrape stores the result of the last scrape
+func (ts *TargetScheduler) recordScrape(err error) {
+	scrapesTotal.Add(1)
+	if err != nil {
+		scrapeFailuresTotal.Add(1)
+	}
+
+	ts.statusMu.Lock()
+	defer ts.statusMu.Unlock()
+	ts.lastScrapeTime = time.Now()
+	if err != nil {
+		ts.lastScrapeErr = err.Error()
+	} else {
+		ts.lastScrapeErr = ""
+	}
+}
+
+// state returns a snapshot of the scheduler
+func (ts *TargetScheduler) state() SchedulerState {
+	target := ts.getTarget()
+	st := SchedulerState{
+		TargetID: target.ID,
+		URL:      target.URL,
+		Interval: ts.interval,
+		Timeout:  ts.getCurrentTimeout(),
+	}
+
+	ts.statusMu.Lock()
+	st.LastScrape = ts.lastScrapeTime
+	st.LastError = ts.lastScrapeErr
+	if time.Now().Before(ts.deferredUntil) {
+		st.DeferredUntil = ts.deferredUntil
+	}
+	ts.statusMu.Unlock()
+	st.Deferrals = ts.deferrals.Load()
+	st.DeferredScrapes = ts.deferredScrapes.Load()
+	st.Dropped = ts.droppedScrapes.Load()
+	if ts.sm != nil {
+		st.PausedUntil, _ = ts.sm.pauses.pausedUntil(target.ID, time.Now())
+	}
+	st.PausedScrapes = ts.pausedScrapes.Load()
+
+	ts.progressMu.Lock()
+	st.InProgress = ts.inProgress
+	ts.progressMu.Unlock()
+	return st
 }
 
 // updateTarget safely updates the target reference
@@ -169,381 +281,100 @@ type ScraperManager struct {
 	configManager *config.ConfigManager
 	discovery     discovery.ServiceDiscovery
 	rawQueue      chan *model.ScrapeRawData
+	userAgent     string
 
 	// Individual target schedulers
 	targetSchedulers map[string]*TargetScheduler
 	schedulerMutex   sync.RWMutex
 
-	// Track last scrape times to avoid over-scraping
-	lastScrapeTime  map[string]time.Time
+	// Track last scrape times to avoid over-scraping, as monotonic clock readings
+	clock           scrapeClock
+	lastScrapeTime  map[string]time.Duration
 	lastScrapeMutex sync.RWMutex
 
-	// Control channels
-	stopCh chan struct{}
-}
-
-// matchNamespaceSelector checks if a namespace matches the namespace selector
-func (sm *ScraperManager) matchNamespaceSelector(namespaceName string, namespaceLabels map[string]string, namespaceSelector map[string]interface{}) bool {
-	// If no namespace selector is provided, don't match any namespaces
-	if namespaceSelector == nil {
-		return false
-	}
-
-	// Get the K8s client
-	k8sClient := k8s.GetInstance()
-	if !k8sClient.IsInitialized() {
-		logutil.Printf("INFO", "Kubernetes client not initialized, falling back to direct matching")
-		return sm.matchNamespaceSelectorDirect(namespaceName, namespaceLabels, namespaceSelector)
-	}
-
-	// Check if matchNames is provided
-	if matchNames, ok := namespaceSelector["matchNames"].([]interface{}); ok {
-		// If matchNames is empty, don't match any namespaces
-		if len(matchNames) == 0 {
-			return false
-		}
-
-		// Convert matchNames to []string
-		matchNamesStr := make([]string, 0, len(matchNames))
-		for _, ns := range matchNames {
-			if nsStr, ok := ns.(string); ok {
-				matchNamesStr = append(matchNamesStr, nsStr)
-			}
-		}
-
-		// Get namespaces with the specified names
-		namespaces, err := k8sClient.GetNamespacesByNames(matchNamesStr)
-		if err != nil {
-			logutil.Printf("ERROR", "Error getting namespaces by names: %v", err)
-			return false
-		}
-
-		// Check if the namespace is in the list
-		found := false
-		for _, ns := range namespaces {
-			if ns.Name == namespaceName {
-				found = true
-				// If we found the namespace, we can use its labels for further checks
-				namespaceLabels = ns.Labels
-				break
-			}
-		}
-		if !found {
-			return false
-		}
-	}
-
-	// Check if matchLabels is provided
-	if matchLabels, ok := namespaceSelector["matchLabels"].(map[string]interface{}); ok {
-		// Convert matchLabels to map[string]string
-		matchLabelsStr := make(map[string]string)
-		for k, v := range matchLabels {
-			if vStr, ok := v.(string); ok {
-				matchLabelsStr[k] = vStr
-			}
-		}
-
-		// Get namespaces with the specified labels
-		namespaces, err := k8sClient.GetNamespacesByLabels(matchLabelsStr)
-		if err != nil {
-			logutil.Printf("ERROR", "Error getting namespaces by labels: %v", err)
-			return false
-		}
-
-		// Check if the namespace is in the list
-		found := false
-		for _, ns := range namespaces {
-			if ns.Name == namespaceName {
-				found = true
-				// If we found the namespace, we can use its labels for further checks
-				namespaceLabels = ns.Labels
-				break
-			}
-		}
-		if !found {
-			return false
-		}
-	}
-
-	// Check if matchExpressions is provided
-	if matchExpressions, ok := namespaceSelector["matchExpressions"].([]interface{}); ok {
-		for _, expr := range matchExpressions {
-			exprMap, ok := expr.(map[string]interface{})
-			if !ok {
-				continue
-			}
-
-			key, hasKey := exprMap["key"].(string)
-			operator, hasOperator := exprMap["operator"].(string)
-			values, hasValues := exprMap["values"].([]interface{})
-
-			if !hasKey || !hasOperator {
-				continue
-			}
-
-			switch operator {
-			case "In":
-				// The label must exist and its value must be in the specified values
-				value, exists := namespaceLabels[key]
-				if !exists {
-					return false
-				}
-				if hasValues {
-					found := false
-					for _, v := range values {
-						if vStr, ok := v.(string); ok && value == vStr {
-							found = true
-							break
-						}
-					}
-					if !found {
-						return false
-					}
-				}
-			case "NotIn":
-				// If the label exists, its value must not be in the specified values
-				value, exists := namespaceLabels[key]
-				if exists && hasValues {
-					for _, v := range values {
-						if vStr, ok := v.(string); ok && value == vStr {
-							return false
-						}
-					}
-				}
-			case "Exists":
-				// The label must exist
-				_, exists := namespaceLabels[key]
-				if !exists {
-					return false
-				}
-			case "DoesNotExist":
-				// The label must not exist
-				_, exists := namespaceLabels[key]
-				if exists {
-					return false
-				}
-			}
-		}
-	}
-
-	// If we've passed all checks, return true
-	return true
-}
-
-// matchNamespaceSelectorDirect is a fallback method that directly matches a namespace against a selector
-// without using the Kubernetes API
-func (sm *ScraperManager) matchNamespaceSelectorDirect(namespaceName string, namespaceLabels map[string]string, namespaceSelector map[string]interface{}) bool {
-	// Check if matchNames is provided
-	if matchNames, ok := namespaceSelector["matchNames"].([]interface{}); ok {
-		// If matchNames is empty, don't match any namespaces
-		if len(matchNames) == 0 {
-			return false
-		}
-
-		// Check if the namespace is in the matchNames list
-		found := false
-		for _, ns := range matchNames {
-			if nsStr, ok := ns.(string); ok && nsStr == namespaceName {
-				found = true
-				break
-			}
-		}
-		if !found {
-			return false
-		}
-	}
-
-	// Check if matchLabels is provided
-	if matchLabels, ok := namespaceSelector["matchLabels"].(map[string]interface{}); ok {
-		// Check if all matchLabels are present in the namespace labels
-		if !sm.hasLabels(namespaceLabels, matchLabels) {
-			return false
-		}
-	}
+	// Recent scrape errors, written to the crash dump
+	scrapeErrors diagnostics.ErrorRing
 
-	// Check if matchExpressions is provided
-	if matchExpressions, ok := namespaceSelector["matchExpressions"].([]interface{}); ok {
-		for _, expr := range matchExpressions {
-			exprMap, ok := expr.(map[string]interface{})
-			if !ok {
-				continue
-			}
+	// Per-target response bytes, sent with the other self-metrics to selfMetricsQueue
+	scrapeBytes      scrapeBytes
+	selfMetricsQueue chan<- *model.ConversionResult
 
-			key, hasKey := exprMap["key"].(string)
-			operator, hasOperator := exprMap["operator"].(string)
-			values, hasValues := exprMap["values"].([]interface{})
+	// Targets paused through the admin endpoint
+	pauses *pauseStore
 
-			if !hasKey || !hasOperator {
-				continue
-			}
+	// Kubernetes Events for targets that keep failing
+	scrapeEvents *scrapeEvents
 
-			switch operator {
-			case "In":
-				// The label must exist and its value must be in the specified values
-				value, exists := namespaceLabels[key]
-				if !exists {
-					return false
-				}
-				if hasValues {
-					found := false
-					for _, v := range values {
-						if vStr, ok := v.(string); ok && value == vStr {
-							found = true
-							break
-						}
-					}
-					if !found {
-						return false
-					}
-				}
-			case "NotIn":
-				// If the label exists, its value must not be in the specified values
-				value, exists := namespaceLabels[key]
-				if exists && hasValues {
-					for _, v := range values {
-						if vStr, ok := v.(string); ok && value == vStr {
-							return false
-						}
-					}
-				}
-			case "Exists":
-				// The label must exist
-				_, exists := namespaceLabels[key]
-				if !exists {
-					return false
-				}
-			case "DoesNotExist":
-				// The label must not exist
-				_, exists := namespaceLabels[key]
-				if exists {
-					return false
-				}
-			}
-		}
-	}
+	// Rate-limited scrape failure logging per target
+	failureLog *scrapeFailureLog
 
-	// If we've passed all checks, return true
-	return true
-}
+	// Sheds scrapes while the pipeline queues stay saturated
+	overload overloadBreaker
 
-// hasLabels checks if a set of labels contains all required labels
-func (sm *ScraperManager) hasLabels(labels map[string]string, matchLabels map[string]interface{}) bool {
-	// If matchLabels is empty, return false
-	if len(matchLabels) == 0 {
-		return false
-	}
+	// Last seen pod generation per target, to mark counter resets after a pod restart
+	podRestarts podRestarts
 
-	// Check if all matchLabels are present in the labels
-	for key, v := range matchLabels {
-		if val, ok := v.(string); ok {
-			// If the label doesn't exist or the value doesn't match, return false
-			if labelVal, exists := labels[key]; !exists || labelVal != val {
-				return false
-			}
-		}
-	}
-	return true
-}
+	// Per-namespace target and sample budgets
+	namespaceBudgets namespaceBudgets
 
-// matchPodSelector checks if pod labels match the pod selector
-func (sm *ScraperManager) matchPodSelector(podLabels map[string]string, podSelector map[string]interface{}) bool {
-	// If no pod selector is provided, don't match any pods
-	if podSelector == nil {
-		return false
-	}
+	// Cap on the number of target schedulers (openagent_max_schedulers)
+	schedulerCap schedulerCap
 
-	// Check if matchLabels is provided
-	matchLabels, hasMatchLabels := podSelector["matchLabels"].(map[string]interface{})
-	if hasMatchLabels {
-		// Check if all matchLabels are present in the pod labels
-		if !sm.hasLabels(podLabels, matchLabels) {
-			return false
-		}
-	}
+	// Live scheduler and scrape goroutines, to find those that outlive their scheduler
+	goroutines goroutineTracker
 
-	// Check if matchExpressions is provided
-	if matchExpressions, ok := podSelector["matchExpressions"].([]interface{}); ok {
-		for _, expr := range matchExpressions {
-			exprMap, ok := expr.(map[string]interface{})
-			if !ok {
-				continue
-			}
+	// Scrape results dropped on a full raw queue since the last WARN log
+	dropMu          sync.Mutex
+	droppedSinceLog int
+	lastDropLog     time.Time
 
-			key, hasKey := exprMap["key"].(string)
-			operator, hasOperator := exprMap["operator"].(string)
-			values, hasValues := exprMap["values"].([]interface{})
-
-			if !hasKey || !hasOperator {
-				continue
-			}
-
-			switch operator {
-			case "In":
-				// The label must exist and its value must be in the specified values
-				value, exists := podLabels[key]
-				if !exists {
-					return false
-				}
-				if hasValues {
-					found := false
-					for _, v := range values {
-						if vStr, ok := v.(string); ok && value == vStr {
-							found = true
-							break
-						}
-					}
-					if !found {
-						return false
-					}
-				}
-			case "NotIn":
-				// If the label exists, its value must not be in the specified values
-				value, exists := podLabels[key]
-				if exists && hasValues {
-					for _, v := range values {
-						if vStr, ok := v.(string); ok && value == vStr {
-							return false
-						}
-					}
-				}
-			case "Exists":
-				// The label must exist
-				_, exists := podLabels[key]
-				if !exists {
-					return false
-				}
-			case "DoesNotExist":
-				// The label must not exist
-				_, exists := podLabels[key]
-				if exists {
-					return false
-				}
-			}
-		}
-	}
-
-	// If we've passed all checks, return true
-	return true
+	// Control channels
+	stopCh chan struct{}
 }
 
-// NewScraperManager creates a new ScraperManager instance
-func NewScraperManager(configManager *config.ConfigManager, discovery discovery.ServiceDiscovery, rawQueue chan *model.ScrapeRawData) *ScraperManager {
+// NewScraperManager creates a new ScraperManager instance.
+// userAgent is sent with every scrape unless an endpoint overrides it via headers.
+func NewScraperManager(configManager *config.ConfigManager, discovery discovery.ServiceDiscovery, rawQueue chan *model.ScrapeRawData, userAgent string) *ScraperManager {
 	sm := &ScraperManager{
 		configManager:    configManager,
 		discovery:        discovery,
 		rawQueue:         rawQueue,
+		userAgent:        userAgent,
 		targetSchedulers: make(map[string]*TargetScheduler),
-		lastScrapeTime:   make(map[string]time.Time),
+		clock:            newSystemClock(),
+		lastScrapeTime:   make(map[string]time.Duration),
+		pauses:           loadPauseStore(pausedTargetsFile()),
+		scrapeEvents:     newScrapeEvents(),
+		failureLog:       newScrapeFailureLog(),
 		stopCh:           make(chan struct{}),
 	}
 
 	return sm
 }
 
-// StartScraping starts the scraping process with individual target schedulers
+// k8sProvider returns the Kubernetes client, or a no-op provider in standalone mode
+func k8sProvider() k8s.K8sProvider {
+	return k8s.NewProvider(config.IsForceStandaloneMode())
+}
+
+// StartScraping starts the scraping process with individual target schedulers. Without discovery the
+// manager runs pipeline only: it schedules no targets and AddRawData is the only source of raw data.
 func (sm *ScraperManager) StartScraping() {
+	if sm.discovery == nil {
+		logutil.Println("INFO", "No service discovery, running pipeline only: raw data is ingested through AddRawData")
+		return
+	}
+
+	// Scrape failure Events are posted off the scrape goroutines
+	go sm.scrapeEvents.run(sm.stopCh)
 	// Start target management loop
 	go sm.targetManagementLoop()
+	if sm.selfMetricsQueue != nil {
+		go sm.selfMetricsLoop()
+	}
+	if len(sm.overload.queues) > 0 {
+		go sm.overloadLoop()
+	}
 
 	logutil.Println("INFO", "Individual target scraping started")
 }
@@ -572,6 +403,7 @@ func (sm *ScraperManager) targetManagementLoop() {
 		select {
 		case <-ticker.C:
 			sm.updateTargetSchedulers()
+			sm.reconcileGoroutines(time.Now())
 		case <-sm.stopCh:
 			sm.stopAllSchedulers()
 			return
@@ -579,12 +411,22 @@ func (sm *ScraperManager) targetManagementLoop() {
 	}
 }
 
-// calculateEndpointHash calculates SHA256 hash of endpoint configuration
+// calculateEndpointHash calculates SHA256 hash of endpoint configuration. Params are hashed in their
+// canonical query form, so an unchanged config hashes the same on every discovery cycle.
 func (sm *ScraperManager) calculateEndpointHash(endpoint interface{}) string {
 	if endpoint == nil {
 		return "null"
 	}
 
+	if ep, ok := endpoint.(discovery.EndpointConfig); ok {
+		params := ep.CanonicalParams()
+		ep.Params = nil
+		endpoint = struct {
+			Endpoint discovery.EndpointConfig
+			Params   string
+		}{ep, params}
+	}
+
 	data, err := json.Marshal(endpoint)
 	if err != nil {
 		logutil.Printf("WARN", "Failed to marshal endpoint for hashing: %v", err)
@@ -613,8 +455,9 @@ func (sm *ScraperManager) hasEndpointChanged(oldTarget, newTarget *discovery.Tar
 
 // updateTargetSchedulers manages the lifecycle of individual target schedulers
 func (sm *ScraperManager) updateTargetSchedulers() {
-	// Get current ready targets
-	targets := sm.discovery.GetReadyTargets()
+	// Get current ready targets, without those over their namespace's budget or the scheduler cap
+	targets, overBudget := sm.applyNamespaceBudgets(sm.discovery.GetReadyTargets())
+	targets, overCap := sm.applySchedulerCap(targets)
 	currentTargetIDs := make(map[string]bool)
 
 	if config.IsDebugEnabled() {
@@ -624,6 +467,7 @@ func (sm *ScraperManager) updateTargetSchedulers() {
 	// Start schedulers for new targets and update existing ones
 	for _, target := range targets {
 		currentTargetIDs[target.ID] = true
+		restarted := sm.podRestarts.observe(target)
 
 		sm.schedulerMutex.RLock()
 		existingScheduler, exists := sm.targetSchedulers[target.ID]
@@ -632,6 +476,9 @@ func (sm *ScraperManager) updateTargetSchedulers() {
 		if !exists {
 			// Start new scheduler for this target
 			sm.startTargetScheduler(target)
+			if restarted {
+				sm.markRestart(target.ID)
+			}
 		} else {
 			// Check if interval has changed (requires scheduler restart)
 			newInterval := sm.getTargetInterval(target)
@@ -640,6 +487,10 @@ func (sm *ScraperManager) updateTargetSchedulers() {
 					target.ID, existingScheduler.interval, newInterval)
 				sm.stopTargetScheduler(target.ID)
 				sm.startTargetScheduler(target)
+			} else if restarted {
+				// Counters start over; pick up the new generation label and mark the reset with the next scrape
+				existingScheduler.updateTarget(target)
+				sm.markRestart(target.ID)
 			} else if sm.hasEndpointChanged(existingScheduler.getTarget(), target) {
 				// Endpoint changed but interval unchanged - graceful update without restart
 				logutil.Printf("INFO", "Target %s endpoint configuration changed, applying from next scrape cycle", target.ID)
@@ -664,9 +515,52 @@ func (sm *ScraperManager) updateTargetSchedulers() {
 	sm.schedulerMutex.RUnlock()
 
 	for _, targetID := range schedulersToStop {
-		logutil.Printf("INFO", "Stopping scheduler for target %s (no longer ready)", targetID)
+		if overBudget[targetID] {
+			logutil.Printf("INFO", "Stopping scheduler for target %s (over its namespace budget)", targetID)
+		} else if overCap[targetID] {
+			logutil.Printf("INFO", "Stopping scheduler for target %s (over openagent_max_schedulers)", targetID)
+		} else {
+			logutil.Printf("INFO", "Stopping scheduler for target %s (no longer ready)", targetID)
+		}
 		sm.stopTargetScheduler(targetID)
+		diagnostics.Samples.Forget(targetID)
+		// Pending targets keep their generation, so a restart seen when they are ready again is marked
+		if _, known := sm.discovery.GetTarget(targetID); !known {
+			sm.podRestarts.forget(targetID)
+		}
+	}
+}
+
+// GetSchedulerStates returns the state of every active target scheduler, sorted by target ID
+func (sm *ScraperManager) GetSchedulerStates() []SchedulerState {
+	sm.schedulerMutex.RLock()
+	schedulers := make([]*TargetScheduler, 0, len(sm.targetSchedulers))
+	for _, scheduler := range sm.targetSchedulers {
+		schedulers = append(schedulers, scheduler)
+	}
+	sm.schedulerMutex.RUnlock()
+
+	states := make([]SchedulerState, 0, len(schedulers))
+	for _, scheduler := range schedulers {
+		states = append(states, scheduler.state())
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].TargetID < states[j].TargetID })
+	return states
+}
+
+// ScrapeErrors returns the ring of recent scrape errors
+func (sm *ScraperManager) ScrapeErrors() *diagnostics.ErrorRing {
+	return &sm.scrapeErrors
+}
+
+// ActiveSchedulerCount returns the number of target schedulers.
+// It does not wait for the scheduler lock and returns false if the lock is held, e.g. by a hung update.
+func (sm *ScraperManager) ActiveSchedulerCount() (int, bool) {
+	if !sm.schedulerMutex.TryRLock() {
+		return 0, false
 	}
+	defer sm.schedulerMutex.RUnlock()
+	return len(sm.targetSchedulers), true
 }
 
 // startTargetScheduler starts an individual scheduler for a target
@@ -738,6 +632,7 @@ func (sm *ScraperManager) startTargetScheduler(target *discovery.Target) {
 		maxTimeout:             maxTimeout,
 		consecutiveTimeouts:    0,
 	}
+	scheduler.newSchedulerLifecycle()
 
 	sm.schedulerMutex.Lock()
 	// Double check if scheduler already exists to prevent race conditions
@@ -750,8 +645,11 @@ func (sm *ScraperManager) startTargetScheduler(target *discovery.Target) {
 	sm.targetSchedulers[target.ID] = scheduler
 	sm.schedulerMutex.Unlock()
 
-	// Start the scheduler goroutine
-	go func() {
+	// Start the scheduler goroutine, labeled with the target ID so profiles group its scrapes by target
+	goroutineID := sm.goroutines.add(scheduler, goroutineScheduler)
+	go diagnostics.Profile(diagnostics.ComponentScraper, target.ID, func() {
+		defer sm.goroutines.remove(goroutineID)
+		defer close(scheduler.done)
 		defer scheduler.ticker.Stop()
 
 		if adaptiveTimeoutEnabled {
@@ -765,6 +663,22 @@ func (sm *ScraperManager) startTargetScheduler(target *discovery.Target) {
 		for {
 			select {
 			case <-scheduler.ticker.C:
+				// The ticker keeps running while the target is paused, so resuming keeps the schedule
+				if _, paused := sm.pauses.pausedUntil(target.ID, time.Now()); paused {
+					sm.skipPausedScrape(scheduler)
+					continue
+				}
+
+				// A rate-limited target is not scraped before its Retry-After
+				if scheduler.deferred(time.Now()) {
+					continue
+				}
+
+				// Shed load while the pipeline queues stay saturated
+				if sm.overloadSkip(scheduler) {
+					continue
+				}
+
 				// Check if previous scrape is still in progress
 				if !scheduler.tryStartScraping() {
 					logutil.Printf("WARN", "[SCRAPER] Skipping scrape for target %s - previous request still in progress (possible slow endpoint or timeout too high)", target.ID)
@@ -775,7 +689,9 @@ func (sm *ScraperManager) startTargetScheduler(target *discovery.Target) {
 				currentTarget := scheduler.getTarget()
 
 				// Scrape in a goroutine to avoid blocking the scheduler
+				scrapeID := sm.goroutines.add(scheduler, goroutineScrape)
 				go func() {
+					defer sm.goroutines.remove(scrapeID)
 					defer scheduler.finishScraping()
 					sm.scrapeTarget(currentTarget)
 				}()
@@ -784,29 +700,39 @@ func (sm *ScraperManager) startTargetScheduler(target *discovery.Target) {
 				return
 			}
 		}
-	}()
+	})
 }
 
-// stopTargetScheduler stops an individual target scheduler
+// stopTargetScheduler stops an individual target scheduler and waits up to schedulerStopTimeout for
+// its goroutine to exit. An in-flight scrape may still finish; reconcileGoroutines reaps it if it does
+// not within orphanIntervals intervals.
 func (sm *ScraperManager) stopTargetScheduler(targetID string) {
 	sm.schedulerMutex.Lock()
-	defer sm.schedulerMutex.Unlock()
-
-	if scheduler, exists := sm.targetSchedulers[targetID]; exists {
+	scheduler, exists := sm.targetSchedulers[targetID]
+	if exists {
 		close(scheduler.stopCh)
+		scheduler.markStopped(time.Now())
 		delete(sm.targetSchedulers, targetID)
 	}
+	sm.schedulerMutex.Unlock()
+
+	sm.scrapeEvents.forget(targetID)
+	sm.failureLog.forget(targetID)
+	if exists && !scheduler.waitStopped(schedulerStopTimeout) {
+		logutil.Printf("WARN", "[SCRAPER] Scheduler goroutine for target %s did not exit within %v", targetID, schedulerStopTimeout)
+	}
 }
 
-// stopAllSchedulers stops all target schedulers
+// stopAllSchedulers stops all target schedulers and waits up to schedulerStopTimeout for their goroutines to exit
 func (sm *ScraperManager) stopAllSchedulers() {
 	sm.schedulerMutex.Lock()
-	defer sm.schedulerMutex.Unlock()
-
 	logutil.Printf("INFO", "Stopping all %d target schedulers", len(sm.targetSchedulers))
 
-	for targetID, scheduler := range sm.targetSchedulers {
+	now := time.Now()
+	stopped := sm.targetSchedulers
+	for targetID, scheduler := range stopped {
 		close(scheduler.stopCh)
+		scheduler.markStopped(now)
 		if config.IsDebugEnabled() {
 			logutil.Printf("DEBUG", "Stopped scheduler for target %s", targetID)
 		}
@@ -814,6 +740,14 @@ func (sm *ScraperManager) stopAllSchedulers() {
 
 	// Clear the map
 	sm.targetSchedulers = make(map[string]*TargetScheduler)
+	sm.schedulerMutex.Unlock()
+
+	deadline := now.Add(schedulerStopTimeout)
+	for targetID, scheduler := range stopped {
+		if !scheduler.waitStopped(time.Until(deadline)) {
+			logutil.Printf("WARN", "[SCRAPER] Scheduler goroutine for target %s did not exit within %v", targetID, schedulerStopTimeout)
+		}
+	}
 }
 
 // Stop gracefully stops the scraper manager
@@ -849,41 +783,76 @@ func (sm *ScraperManager) scrapeTarget(target *discovery.Target) {
 	// Get current adaptive timeout value
 	currentTimeout := scheduler.getCurrentTimeout()
 
-	// Create scraper task from target
-	scraperTask := sm.createScraperTaskFromTarget(target)
-	if scraperTask == nil {
+	rawData, err := sm.runScraperTask(scheduler, target, currentTimeout)
+	if errors.Is(err, errNoScraperTask) {
 		logutil.Errorf("ERROR", "Failed to create scraper task for target: %s\n", target.ID)
 		return
 	}
 
-	// Override timeout with adaptive value
-	scraperTask.Timeout = currentTimeout.String()
-
-	// Run the scraper task
-	rawData, err := scraperTask.Run()
+	// A rescheduled pod refuses connections on its old IP until the next scheduler update;
+	// retry once against the address discovery has now
+	if err != nil && errors.Is(err, syscall.ECONNREFUSED) {
+		if moved := sm.movedTarget(target); moved != nil {
+			logutil.Printf("INFO", "[SCRAPER] Target %s moved from %s to %s, retrying the scrape", target.ID, target.URL, moved.URL)
+			scheduler.updateTarget(moved)
+			target = moved
+			rawData, err = sm.runScraperTask(scheduler, target, currentTimeout)
+			if errors.Is(err, errNoScraperTask) {
+				logutil.Errorf("ERROR", "Failed to create scraper task for target: %s\n", target.ID)
+				return
+			}
+		}
+	}
 	if err != nil {
 		// Check if it's a timeout error
-		if strings.Contains(err.Error(), "context deadline exceeded") ||
-			strings.Contains(err.Error(), "Client.Timeout exceeded") {
+		var timeoutErr *client.TimeoutError
+		var rateLimited *client.RetryAfterError
+		var message string
+		if errors.As(err, &rateLimited) {
+			// Scraping again on the next tick would make the rate limiting worse
+			if until := sm.deferScrape(scheduler, target, rateLimited, time.Now()); !until.IsZero() {
+				message = fmt.Sprintf("Rate limited by target %s, deferring the next scrape until %s: %v",
+					target.ID, until.Format(time.RFC3339), err)
+			} else {
+				message = fmt.Sprintf("Rate limited by target %s: %v", target.ID, err)
+			}
+		} else if errors.As(err, &timeoutErr) && (timeoutErr.Phase == client.PhaseConnect || timeoutErr.Phase == client.PhaseTLSHandshake) {
+			// A longer scrape timeout does not help an unreachable target
+			message = fmt.Sprintf("Connect timeout scraping target %s: %v", target.ID, err)
+		} else if strings.Contains(err.Error(), "context deadline exceeded") ||
+			strings.Contains(err.Error(), "Client.Timeout exceeded") || timeoutErr != nil {
 			// Timeout occurred - increase timeout
 			newTimeout := scheduler.increaseTimeout()
-			logutil.Errorf("ERROR", "Timeout scraping target %s (timeout: %v, next timeout: %v): %v\n",
+			message = fmt.Sprintf("Timeout scraping target %s (timeout: %v, next timeout: %v): %v",
 				target.ID, currentTimeout, newTimeout, err)
 		} else {
 			// Other error - don't adjust timeout
-			logutil.Errorf("ERROR", "Error scraping target %s: %v\n", target.ID, err)
+			message = fmt.Sprintf("Error scraping target %s: %v", target.ID, err)
 		}
+		// Repeated identical failures are logged once per scrapeFailureLogInterval
+		sm.failureLog.failure(target.ID, err, message)
 
 		// Still update last scrape time for tracking
+		scheduler.recordScrape(err)
+		sm.scrapeEvents.observe(target, err)
+		sm.scrapeErrors.Add(target.ID, err)
 		sm.updateLastScrapingTime(target)
+		diagnostics.Beat(diagnostics.ComponentScraper)
 		return
 	}
 
 	// Success - reset timeout to base value
 	scheduler.resetTimeout()
+	scheduler.recordScrape(nil)
+	sm.scrapeEvents.observe(target, nil)
+	sm.failureLog.success(target.ID, target.URL)
 
-	// Add the raw data to the queue
-	sm.rawQueue <- rawData
+	// Add the raw data to the queue without holding up the target's schedule
+	sm.enqueueRawData(scheduler, target.ID, rawData)
+	if scheduler.takeRestart() {
+		sm.sendTargetRestarted(target)
+	}
+	diagnostics.Beat(diagnostics.ComponentScraper)
 
 	// Update last scrape time on success
 	sm.updateLastScrapingTime(target)
@@ -892,6 +861,46 @@ func (sm *ScraperManager) scrapeTarget(target *discovery.Target) {
 	}
 }
 
+// errNoScraperTask is returned by runScraperTask when no task can be created for the target
+var errNoScraperTask = errors.New("failed to create scraper task")
+
+// runScraperTask scrapes the target once with the given timeout and counts the response bytes
+func (sm *ScraperManager) runScraperTask(scheduler *TargetScheduler, target *discovery.Target, timeout time.Duration) (*model.ScrapeRawData, error) {
+	// Create scraper task from target
+	scraperTask := sm.createScraperTaskFromTarget(target)
+	if scraperTask == nil {
+		return nil, errNoScraperTask
+	}
+
+	// Override timeout with adaptive value
+	scraperTask.Timeout = timeout.String()
+
+	// Align sample timestamps to the effective scrape interval
+	if scraperTask.TimestampAlignment == model.TimestampAlignmentInterval {
+		scraperTask.AlignInterval = scheduler.interval
+	}
+
+	// Periodically make a new connection, so a certificate renewed behind keep-alive is reported
+	now := sm.clock.Elapsed()
+	scraperTask.CloseConnection = scheduler.certRefreshDue(now)
+	scraperTask.Context = scheduler.reapCtx
+
+	rawData, err := scraperTask.Run()
+	sm.scrapeBytes.add(target.ID, scraperTask.WireBytes, scraperTask.BodyBytes)
+	scheduler.observeCert(scraperTask.CertNotAfter, scraperTask.CloseConnection, now)
+	return rawData, err
+}
+
+// movedTarget returns the target's current discovery state if it is still ready and is now scraped
+// at a different URL, or nil
+func (sm *ScraperManager) movedTarget(target *discovery.Target) *discovery.Target {
+	current, ok := sm.discovery.GetTarget(target.ID)
+	if !ok || current.State != discovery.TargetStateReady || current.URL == target.URL {
+		return nil
+	}
+	return current
+}
+
 // getTargetInterval gets the scraping interval for a target
 func (sm *ScraperManager) getTargetInterval(target *discovery.Target) time.Duration {
 	// Check for endpoint-specific interval
@@ -924,40 +933,45 @@ func (sm *ScraperManager) shouldSkipScraping(target *discovery.Target, interval
 	}
 
 	// Skip if not enough time has passed since last scrape
-	return time.Since(lastScrape) < interval
+	return sm.clock.Elapsed()-lastScrape < interval
 }
 
 // updateLastScrapingTime updates the last scraping time for a target
 func (sm *ScraperManager) updateLastScrapingTime(target *discovery.Target) {
 	sm.lastScrapeMutex.Lock()
-	sm.lastScrapeTime[target.ID] = time.Now()
+	sm.lastScrapeTime[target.ID] = sm.clock.Elapsed()
 	sm.lastScrapeMutex.Unlock()
 }
 
-// logScrapingInterval logs the actual scraping interval for a target
-func (sm *ScraperManager) logScrapingInterval(target *discovery.Target) {
-	currentTime := time.Now()
-	configuredInterval := sm.getTargetInterval(target)
-
+// intervalDeviation returns how far the time since the target's last scrape is from its configured
+// interval, measured on the monotonic clock. ok is false before the first scrape.
+func (sm *ScraperManager) intervalDeviation(target *discovery.Target) (deviation time.Duration, ok bool) {
 	sm.lastScrapeMutex.RLock()
 	lastScrape, exists := sm.lastScrapeTime[target.ID]
 	sm.lastScrapeMutex.RUnlock()
 
-	if exists {
-		actualInterval := currentTime.Sub(lastScrape)
+	if !exists {
+		return 0, false
+	}
+	return sm.clock.Elapsed() - lastScrape - sm.getTargetInterval(target), true
+}
 
-		// Log the interval information (debug only)
-		if config.IsDebugEnabled() {
-			logutil.Printf("DEBUG", "[SCRAPER] Target %s: interval deviation %v",
-				target.ID, actualInterval-configuredInterval)
-		}
+// logScrapingInterval logs the actual scraping interval for a target
+func (sm *ScraperManager) logScrapingInterval(target *discovery.Target) {
+	deviation, ok := sm.intervalDeviation(target)
+	if !ok {
+		return
+	}
 
-		// Log warning if deviation is significant (more than 1 second)
-		deviation := actualInterval - configuredInterval
-		if deviation > time.Second || deviation < -time.Second {
-			logutil.Printf("WARN", "[SCRAPER] Target %s has significant interval deviation: %v",
-				target.ID, deviation)
-		}
+	// Log the interval information (debug only)
+	if config.IsDebugEnabled() {
+		logutil.Printf("DEBUG", "[SCRAPER] Target %s: interval deviation %v", target.ID, deviation)
+	}
+
+	// Log warning if deviation is significant (more than 1 second)
+	if deviation > time.Second || deviation < -time.Second {
+		logutil.Printf("WARN", "[SCRAPER] Target %s has significant interval deviation: %v",
+			target.ID, deviation)
 	}
 }
 
@@ -974,12 +988,12 @@ func (sm *ScraperManager) cleanupOldTargets() {
 	}
 
 	// Remove entries that are not in current targets and are older than 1 hour
-	cutoff := time.Now().Add(-1 * time.Hour)
+	cutoff := sm.clock.Elapsed() - time.Hour
 	removedCount := 0
 
 	for targetID, lastScrape := range sm.lastScrapeTime {
 		// Remove if target is not current and last scrape was more than 1 hour ago
-		if !currentTargetIDs[targetID] && lastScrape.Before(cutoff) {
+		if !currentTargetIDs[targetID] && lastScrape < cutoff {
 			delete(sm.lastScrapeTime, targetID)
 			removedCount++
 		}
@@ -994,19 +1008,13 @@ func (sm *ScraperManager) cleanupOldTargets() {
 func (sm *ScraperManager) createScraperTaskFromTarget(target *discovery.Target) *ScraperTask {
 	// Extract metadata
 	targetName, _ := target.Metadata["targetName"].(string)
-	metricRelabelConfigs, _ := target.Metadata["metricRelabelConfigs"].([]interface{})
+	relabelConfigs, _ := target.Metadata["metricRelabelConfigs"].(model.RelabelConfigs)
 
 	// Debug log for target information (debug only)
 	if config.IsDebugEnabled() {
 		logutil.Printf("DEBUG", "[SCRAPER] Creating scraper task for target: %s", targetName)
-	}
-
-	// Parse metric relabel configs
-	var relabelConfigs model.RelabelConfigs
-	if metricRelabelConfigs != nil {
-		relabelConfigs = model.ParseRelabelConfigs(metricRelabelConfigs)
-		if config.IsDebugEnabled() {
-			logutil.Printf("DEBUG", "[SCRAPER] Found %d metric relabel configs", len(metricRelabelConfigs))
+		if len(relabelConfigs) > 0 {
+			logutil.Printf("DEBUG", "[SCRAPER] Found %d metric relabel configs", len(relabelConfigs))
 		}
 	}
 
@@ -1042,9 +1050,18 @@ func (sm *ScraperManager) createScraperTaskFromTarget(target *discovery.Target)
 		tlsConfig,
 	)
 
+	scraperTask.TargetID = target.ID
+
 	// Set node information for proper node label handling
 	scraperTask.NodeName = nodeName
 	scraperTask.AddNodeLabel = addNodeLabel
+	scraperTask.ViaAPIServer, _ = target.Metadata["proxyViaApiserver"].(bool)
+
+	// Identify the agent to the exporter
+	scraperTask.Headers = make(map[string]string)
+	if sm.userAgent != "" {
+		scraperTask.Headers["User-Agent"] = sm.userAgent
+	}
 
 	// Extract params and timeout if present
 	if endpoint, ok := target.Metadata["endpoint"].(discovery.EndpointConfig); ok {
@@ -1064,6 +1081,38 @@ func (sm *ScraperManager) createScraperTaskFromTarget(target *discovery.Target)
 			}
 		}
 
+		// Prefer the protobuf exposition for this endpoint; an explicit Accept header still wins
+		if endpoint.AcceptProtobuf {
+			scraperTask.Headers["Accept"] = client.ProtobufAcceptHeader
+		}
+
+		// Per-endpoint headers override the default User-Agent
+		for name, value := range endpoint.Headers {
+			scraperTask.Headers[name] = value
+		}
+
+		scraperTask.Downsample = endpoint.Downsample
+		scraperTask.ConnectTimeout = endpoint.ConnectTimeout
+		scraperTask.ReadTimeout = endpoint.ReadTimeout
+		scraperTask.MetricPrefix = endpoint.MetricPrefix
+		scraperTask.PreserveAgentNodeLabel = endpoint.PreserveAgentNodeLabel
+		scraperTask.DisableDNSCache = endpoint.DisableDNSCache
+		scraperTask.LabelLengthLimit = endpoint.LabelLengthLimit
+		scraperTask.TimestampAlignment = endpoint.TimestampAlignment
+		scraperTask.NonFiniteValues = endpoint.NonFiniteValues
+		scraperTask.UnitConversions = endpoint.UnitConversions
+		scraperTask.ValueTransforms = endpoint.ValueTransforms
+		scraperTask.InfoJoins = endpoint.InfoJoins
+		scraperTask.Aggregations = endpoint.Aggregations
+		scraperTask.MinSamples = endpoint.MinSamples
+		scraperTask.ExternalLabels = endpoint.ExternalLabels
+		scraperTask.SampleLimit = endpoint.SampleLimit
+		scraperTask.SampleBudget = sm.namespaceBudgets.sampleBudget(target)
+		scraperTask.SecretParams = secretParamsFor(target, endpoint.SecretParams)
+		scraperTask.MatchSelectors = endpoint.MatchSelectors
+		scraperTask.HonorLabels = endpoint.HonorLabels
+		scraperTask.IgnoreTimestamps = endpoint.IgnoreTimestamps
+
 		if endpoint.Params != nil {
 			// Convert params from interface{} to map[string][]string
 			params := make(map[string][]string)
@@ -1126,652 +1175,25 @@ func extractPathFromURL(url string) string {
 	return hostPort[pathIndex:]
 }
 
-// AddRawData adds raw data to the queue
-func (sm *ScraperManager) AddRawData(data *model.ScrapeRawData) {
-	sm.rawQueue <- data
-}
-
-// Legacy methods - commented out as they're replaced by ServiceDiscovery
-/*
-// handlePodMonitorTarget handles a PodMonitor target
-func (sm *ScraperManager) handlePodMonitorTarget(targetName string, targetConfig map[string]interface{}, defaultInterval time.Duration) {
-	logutil.Printf("INFO", "Processing PodMonitor target: %s", targetName)
-
-	// Get the namespace selector
-	namespaceSelector, nsOk := targetConfig["namespaceSelector"].(map[string]interface{})
-	if !nsOk {
-		logutil.Printf("INFO", "No namespaceSelector found for PodMonitor target: %s", targetName)
-		return
-	}
-
-	// Get the pod selector (called 'selector' in the new format)
-	podSelector, podOk := targetConfig["selector"].(map[string]interface{})
-	if !podOk {
-		logutil.Printf("INFO", "No selector found for PodMonitor target: %s", targetName)
-		return
-	}
-
-	// Get the endpoints
-	endpoints, ok := targetConfig["endpoints"].([]interface{})
-	if !ok {
-		logutil.Printf("INFO", "No endpoints found for PodMonitor target: %s", targetName)
-		return
-	}
-
-	// Get the K8s client
-	k8sClient := k8s.GetInstance()
-	if !k8sClient.IsInitialized() {
-		logutil.Printf("INFO", "Kubernetes client not initialized, using dummy target for PodMonitor: %s", targetName)
-		// Fall back to dummy target
-		sm.handlePodMonitorTargetWithDummyTarget(targetName, targetConfig, defaultInterval)
-		return
-	}
-
-	// Get matching namespaces
-	var namespaces []string
-	if matchNames, ok := namespaceSelector["matchNames"].([]interface{}); ok {
-		for _, ns := range matchNames {
-			if nsStr, ok := ns.(string); ok {
-				namespaces = append(namespaces, nsStr)
-			}
-		}
-	}
-
-	// Convert podSelector to map[string]string
-	podLabels := make(map[string]string)
-	if matchLabels, ok := podSelector["matchLabels"].(map[string]interface{}); ok {
-		for key, v := range matchLabels {
-			if val, ok := v.(string); ok {
-				podLabels[key] = val
-			}
-		}
-	}
-
-	// Process each namespace
-	for _, namespace := range namespaces {
-		// Get pods matching the selector in this namespace
-		pods, err := k8sClient.GetPodsByLabels(namespace, podLabels)
-		if err != nil {
-			logutil.Printf("ERROR", "Error getting pods in namespace %s for PodMonitor %s: %v", namespace, targetName, err)
-			continue
-		}
-
-		if len(pods) == 0 {
-			logutil.Printf("INFO", "No pods found in namespace %s matching selector for PodMonitor %s", namespace, targetName)
-			continue
-		}
-
-		// Process each endpoint
-		for _, endpoint := range endpoints {
-			endpointMap, ok := endpoint.(map[string]interface{})
-			if !ok {
-				continue
-			}
-
-			// Get the port
-			portName, ok := endpointMap["port"].(string)
-			if !ok {
-				logutil.Printf("INFO", "No port found in endpoint for PodMonitor target: %s", targetName)
-				continue
-			}
-
-			// Get the path
-			path, ok := endpointMap["path"].(string)
-			if !ok {
-				// Check if path is defined at the target level
-				if targetPath, ok := targetConfig["path"].(string); ok {
-					path = targetPath
-				} else {
-					logutil.Printf("INFO", "No path found in endpoint for PodMonitor target: %s", targetName)
-					continue
-				}
-			}
-
-			// Get the interval
-			endpointInterval := defaultInterval
-			if intervalStr, ok := endpointMap["interval"].(string); ok {
-				if intervalSeconds, err := sm.configManager.ParseInterval(intervalStr); err == nil {
-					endpointInterval = time.Duration(intervalSeconds) * time.Second
-				}
-			} else if intervalStr, ok := targetConfig["interval"].(string); ok {
-				// Check if interval is defined at the target level
-				if intervalSeconds, err := sm.configManager.ParseInterval(intervalStr); err == nil {
-					endpointInterval = time.Duration(intervalSeconds) * time.Second
-				}
-			}
-
-			// Get the scheme
-			scheme := "http"
-			// Check if port name indicates HTTPS
-			if strings.ToLower(portName) == "https" {
-				scheme = "https"
-			}
-			// Override with explicit configuration if provided
-			if schemeStr, ok := endpointMap["scheme"].(string); ok {
-				scheme = schemeStr
-			} else if schemeStr, ok := targetConfig["scheme"].(string); ok {
-				scheme = schemeStr
-			}
-
-			// Get addNodeLabel configuration (default to false)
-			addNodeLabel := false
-			if addNodeLabelVal, ok := endpointMap["addNodeLabel"].(bool); ok {
-				addNodeLabel = addNodeLabelVal
-			} else if addNodeLabelVal, ok := targetConfig["addNodeLabel"].(bool); ok {
-				addNodeLabel = addNodeLabelVal
-			}
-
-			// Create a metric selector config
-			metricSelectorConfig := make(map[string]interface{})
-			metricSelectorConfig["enabled"] = true
-
-			// Add metricRelabelConfigs if present
-			if metricRelabelConfigs, ok := endpointMap["metricRelabelConfigs"].([]interface{}); ok && len(metricRelabelConfigs) > 0 {
-				metricSelectorConfig["metricRelabelConfigs"] = metricRelabelConfigs
-			} else if metricRelabelConfigs, ok := targetConfig["metricRelabelConfigs"].([]interface{}); ok && len(metricRelabelConfigs) > 0 {
-				// Check if metricRelabelConfigs is defined at the target level
-				metricSelectorConfig["metricRelabelConfigs"] = metricRelabelConfigs
-			}
-
-			// Process each pod
-			for _, pod := range pods {
-				// Skip pods that are not running
-				if pod.Status.Phase != "Running" {
-					continue
-				}
-
-				// Get the pod's IP
-				podIP := pod.Status.PodIP
-				if podIP == "" {
-					continue
-				}
-
-				// We don't need to get the port number here since it will be resolved dynamically
-				// when the scraper task runs
-
-				// Extract TLS configuration
-				var tlsConfig *client.TLSConfig
-				if tlsConfigMap, ok := endpointMap["tlsConfig"].(map[string]interface{}); ok {
-					tlsConfig = buildTLSConfig(tlsConfigMap)
-				}
-
-				// Extract metricRelabelConfigs from metricSelectorConfig
-				var metricRelabelConfigs model.RelabelConfigs
-				if metricSelectorConfig != nil {
-					if relabelConfigs, ok := metricSelectorConfig["metricRelabelConfigs"].([]interface{}); ok {
-						metricRelabelConfigs = model.ParseRelabelConfigs(relabelConfigs)
-					}
-				}
-
-				// Create a PodMonitor scraper task
-				scraperTask := NewPodMonitorScraperTask(
-					targetName,
-					namespace,
-					podLabels,
-					portName,
-					path,
-					scheme,
-					metricRelabelConfigs,
-					tlsConfig,
-					addNodeLabel,
-				)
-
-				// Schedule the scraper task
-				go sm.scheduleScraperTask(scraperTask, endpointInterval)
-			}
-		}
-	}
-}
-
-// handlePodMonitorTargetWithDummyTarget handles a PodMonitor target with a dummy target
-// This is used when the Kubernetes client is not initialized
-func (sm *ScraperManager) handlePodMonitorTargetWithDummyTarget(targetName string, targetConfig map[string]interface{}, defaultInterval time.Duration) {
-	logutil.Printf("INFO", "Using dummy target for PodMonitor: %s", targetName)
-
-	// Get the endpoints
-	endpoints, ok := targetConfig["endpoints"].([]interface{})
-	if !ok {
-		logutil.Printf("INFO", "No endpoints found for PodMonitor target: %s", targetName)
-		return
-	}
-
-	// Process each endpoint
-	for _, endpoint := range endpoints {
-		endpointMap, ok := endpoint.(map[string]interface{})
-		if !ok {
-			continue
-		}
-
-		// Get the port
-		port, ok := endpointMap["port"].(string)
-		if !ok {
-			logutil.Printf("INFO", "No port found in endpoint for PodMonitor target: %s", targetName)
-			continue
-		}
-
-		// Get the path
-		path, ok := endpointMap["path"].(string)
-		if !ok {
-			// Check if path is defined at the target level
-			if targetPath, ok := targetConfig["path"].(string); ok {
-				path = targetPath
-			} else {
-				logutil.Printf("INFO", "No path found in endpoint for PodMonitor target: %s", targetName)
-				continue
-			}
-		}
-
-		// Get the interval
-		endpointInterval := defaultInterval
-		if intervalStr, ok := endpointMap["interval"].(string); ok {
-			if intervalSeconds, err := sm.configManager.ParseInterval(intervalStr); err == nil {
-				endpointInterval = time.Duration(intervalSeconds) * time.Second
-			}
-		} else if intervalStr, ok := targetConfig["interval"].(string); ok {
-			// Check if interval is defined at the target level
-			if intervalSeconds, err := sm.configManager.ParseInterval(intervalStr); err == nil {
-				endpointInterval = time.Duration(intervalSeconds) * time.Second
-			}
-		}
-
-		// Get the scheme
-		scheme := "http"
-		// Check if port name indicates HTTPS
-		if strings.ToLower(port) == "https" {
-			scheme = "https"
-		}
-		// Override with explicit configuration if provided
-		if schemeStr, ok := endpointMap["scheme"].(string); ok {
-			scheme = schemeStr
-		} else if schemeStr, ok := targetConfig["scheme"].(string); ok {
-			scheme = schemeStr
-		}
-
-		// Extract TLS configuration
-		var tlsConfig *client.TLSConfig
-		if tlsConfigMap, ok := endpointMap["tlsConfig"].(map[string]interface{}); ok {
-			tlsConfig = buildTLSConfig(tlsConfigMap)
-		}
-
-		// Create a dummy target URL
-		target := fmt.Sprintf("%s://localhost:%s%s", scheme, port, path)
-
-		// Extract metricRelabelConfigs
-		var metricRelabelConfigs model.RelabelConfigs
-		if relabelConfigs, ok := endpointMap["metricRelabelConfigs"].([]interface{}); ok && len(relabelConfigs) > 0 {
-			metricRelabelConfigs = model.ParseRelabelConfigs(relabelConfigs)
-		} else if relabelConfigs, ok := targetConfig["metricRelabelConfigs"].([]interface{}); ok && len(relabelConfigs) > 0 {
-			// Check if metricRelabelConfigs is defined at the target level
-			metricRelabelConfigs = model.ParseRelabelConfigs(relabelConfigs)
-		}
-
-		// Create a scraper task for the target
-		scraperTask := NewScraperTask(targetName, target, metricRelabelConfigs, tlsConfig)
-
-		// Schedule the scraper task
-		go sm.scheduleScraperTask(scraperTask, endpointInterval)
-	}
-}
-
-// handleServiceMonitorTarget handles a ServiceMonitor target
-func (sm *ScraperManager) handleServiceMonitorTarget(targetName string, targetConfig map[string]interface{}, defaultInterval time.Duration) {
-	logutil.Printf("INFO", "Processing ServiceMonitor target: %s", targetName)
-
-	// Get the namespace selector
-	namespaceSelector, nsOk := targetConfig["namespaceSelector"].(map[string]interface{})
-	if !nsOk {
-		logutil.Printf("INFO", "No namespaceSelector found for ServiceMonitor target: %s", targetName)
-		return
-	}
-
-	// Get the service selector (called 'selector' in the new format)
-	serviceSelector, svcOk := targetConfig["selector"].(map[string]interface{})
-	if !svcOk {
-		logutil.Printf("INFO", "No selector found for ServiceMonitor target: %s", targetName)
-		return
-	}
-
-	// Get the endpoint configurations
-	endpointConfigs, ok := targetConfig["endpoints"].([]interface{})
-	if !ok {
-		logutil.Printf("INFO", "No endpoints found for ServiceMonitor target: %s", targetName)
-		return
-	}
-
-	// Get the K8s client
-	k8sClient := k8s.GetInstance()
-	if !k8sClient.IsInitialized() {
-		logutil.Printf("INFO", "Kubernetes client not initialized, using dummy target for ServiceMonitor: %s", targetName)
-		// Fall back to dummy target
-		sm.handleServiceMonitorTargetWithDummyTarget(targetName, targetConfig, defaultInterval)
-		return
-	}
-
-	// Get matching namespaces
-	var namespaces []string
-	if matchNames, ok := namespaceSelector["matchNames"].([]interface{}); ok {
-		for _, ns := range matchNames {
-			if nsStr, ok := ns.(string); ok {
-				namespaces = append(namespaces, nsStr)
-			}
-		}
-	}
-
-	// Convert serviceSelector to map[string]string
-	serviceLabels := make(map[string]string)
-	if matchLabels, ok := serviceSelector["matchLabels"].(map[string]interface{}); ok {
-		for key, v := range matchLabels {
-			if val, ok := v.(string); ok {
-				serviceLabels[key] = val
-			}
-		}
-	}
-
-	// Process each namespace
-	for _, namespace := range namespaces {
-		// Get services matching the selector in this namespace
-		services, err := k8sClient.GetServicesByLabels(namespace, serviceLabels)
-		if err != nil {
-			logutil.Printf("ERROR", "Error getting services in namespace %s for ServiceMonitor %s: %v", namespace, targetName, err)
-			continue
-		}
-
-		if len(services) == 0 {
-			logutil.Printf("INFO", "No services found in namespace %s matching selector for ServiceMonitor %s", namespace, targetName)
-			continue
-		}
-
-		// Process each service
-		for _, service := range services {
-			// Get endpoints for this service
-			k8sEndpoints, err := k8sClient.GetEndpointsForService(namespace, service.Name)
-			if err != nil {
-				logutil.Printf("ERROR", "Error getting endpoints for service %s in namespace %s: %v", service.Name, namespace, err)
-				continue
-			}
-
-			if k8sEndpoints == nil || len(k8sEndpoints.Subsets) == 0 {
-				logutil.Printf("INFO", "No endpoints found for service %s in namespace %s", service.Name, namespace)
-				continue
-			}
-
-			// Process each endpoint configuration from the ServiceMonitor
-			for _, endpointConfig := range endpointConfigs {
-				endpointMap, ok := endpointConfig.(map[string]interface{})
-				if !ok {
-					continue
-				}
-
-				// Get the port
-				portName, ok := endpointMap["port"].(string)
-				if !ok {
-					logutil.Printf("INFO", "No port found in endpoint for ServiceMonitor target: %s", targetName)
-					continue
-				}
-
-				// Get the path
-				path, ok := endpointMap["path"].(string)
-				if !ok {
-					// Check if path is defined at the target level
-					if targetPath, ok := targetConfig["path"].(string); ok {
-						path = targetPath
-					} else {
-						logutil.Printf("INFO", "No path found in endpoint for ServiceMonitor target: %s", targetName)
-						continue
-					}
-				}
-
-				// Get the interval
-				endpointInterval := defaultInterval
-				if intervalStr, ok := endpointMap["interval"].(string); ok {
-					if intervalSeconds, err := sm.configManager.ParseInterval(intervalStr); err == nil {
-						endpointInterval = time.Duration(intervalSeconds) * time.Second
-					}
-				} else if intervalStr, ok := targetConfig["interval"].(string); ok {
-					// Check if interval is defined at the target level
-					if intervalSeconds, err := sm.configManager.ParseInterval(intervalStr); err == nil {
-						endpointInterval = time.Duration(intervalSeconds) * time.Second
-					}
-				}
-
-				// Get the scheme
-				scheme := "http"
-				// Check if port name indicates HTTPS
-				if strings.ToLower(portName) == "https" {
-					scheme = "https"
-				}
-				// Override with explicit configuration if provided
-				if schemeStr, ok := endpointMap["scheme"].(string); ok {
-					scheme = schemeStr
-				} else if schemeStr, ok := targetConfig["scheme"].(string); ok {
-					scheme = schemeStr
-				}
-
-				// Create a metric selector config
-				metricSelectorConfig := make(map[string]interface{})
-				metricSelectorConfig["enabled"] = true
-
-				// Add metricRelabelConfigs if present
-				if metricRelabelConfigs, ok := endpointMap["metricRelabelConfigs"].([]interface{}); ok && len(metricRelabelConfigs) > 0 {
-					metricSelectorConfig["metricRelabelConfigs"] = metricRelabelConfigs
-				} else if metricRelabelConfigs, ok := targetConfig["metricRelabelConfigs"].([]interface{}); ok && len(metricRelabelConfigs) > 0 {
-					// Check if metricRelabelConfigs is defined at the target level
-					metricSelectorConfig["metricRelabelConfigs"] = metricRelabelConfigs
-				}
-
-				// Extract TLS configuration
-				var tlsConfig *client.TLSConfig
-				if tlsConfigMap, ok := endpointMap["tlsConfig"].(map[string]interface{}); ok {
-					tlsConfig = buildTLSConfig(tlsConfigMap)
-				}
-
-				// Extract metricRelabelConfigs from metricSelectorConfig
-				var metricRelabelConfigs model.RelabelConfigs
-				if metricSelectorConfig != nil {
-					if relabelConfigs, ok := metricSelectorConfig["metricRelabelConfigs"].([]interface{}); ok {
-						metricRelabelConfigs = model.ParseRelabelConfigs(relabelConfigs)
-					}
-				}
-
-				// Create a ServiceMonitor scraper task
-				scraperTask := NewServiceMonitorScraperTask(
-					targetName,
-					namespace,
-					serviceLabels,
-					portName,
-					path,
-					scheme,
-					metricRelabelConfigs,
-					tlsConfig,
-				)
-
-				// Schedule the scraper task
-				go sm.scheduleScraperTask(scraperTask, endpointInterval)
-			}
-		}
-	}
-}
-
-// handleServiceMonitorTargetWithDummyTarget handles a ServiceMonitor target with a dummy target
-// This is used when the Kubernetes client is not initialized
-func (sm *ScraperManager) handleServiceMonitorTargetWithDummyTarget(targetName string, targetConfig map[string]interface{}, defaultInterval time.Duration) {
-	logutil.Printf("INFO", "Using dummy target for ServiceMonitor: %s", targetName)
-
-	// Get the endpoints
-	endpoints, ok := targetConfig["endpoints"].([]interface{})
-	if !ok {
-		logutil.Printf("INFO", "No endpoints found for ServiceMonitor target: %s", targetName)
-		return
+// AddRawData adds raw data pushed from outside the scrape loop, such as the ingest endpoint, to the raw
+// queue. Like a scrape result, it is dropped when the queue stays full for rawQueueEnqueueTimeout, and
+// false is returned so the caller can ask the client to retry.
+func (sm *ScraperManager) AddRawData(data *model.ScrapeRawData) bool {
+	select {
+	case sm.rawQueue <- data:
+		return true
+	default:
 	}
 
-	// Process each endpoint
-	for _, endpoint := range endpoints {
-		endpointMap, ok := endpoint.(map[string]interface{})
-		if !ok {
-			continue
-		}
-
-		// Get the port
-		port, ok := endpointMap["port"].(string)
-		if !ok {
-			logutil.Printf("INFO", "No port found in endpoint for ServiceMonitor target: %s", targetName)
-			continue
-		}
-
-		// Get the path
-		path, ok := endpointMap["path"].(string)
-		if !ok {
-			// Check if path is defined at the target level
-			if targetPath, ok := targetConfig["path"].(string); ok {
-				path = targetPath
-			} else {
-				logutil.Printf("INFO", "No path found in endpoint for ServiceMonitor target: %s", targetName)
-				continue
-			}
-		}
-
-		// Get the interval
-		endpointInterval := defaultInterval
-		if intervalStr, ok := endpointMap["interval"].(string); ok {
-			if intervalSeconds, err := sm.configManager.ParseInterval(intervalStr); err == nil {
-				endpointInterval = time.Duration(intervalSeconds) * time.Second
-			}
-		} else if intervalStr, ok := targetConfig["interval"].(string); ok {
-			// Check if interval is defined at the target level
-			if intervalSeconds, err := sm.configManager.ParseInterval(intervalStr); err == nil {
-				endpointInterval = time.Duration(intervalSeconds) * time.Second
-			}
-		}
-
-		// Get the scheme
-		scheme := "http"
-		// Check if port name indicates HTTPS
-		if strings.ToLower(port) == "https" {
-			scheme = "https"
-		}
-		// Override with explicit configuration if provided
-		if schemeStr, ok := endpointMap["scheme"].(string); ok {
-			scheme = schemeStr
-		} else if schemeStr, ok := targetConfig["scheme"].(string); ok {
-			scheme = schemeStr
-		}
-
-		// Create a dummy target URL
-		target := fmt.Sprintf("%s://localhost:%s%s", scheme, port, path)
-
-		// Extract TLS configuration
-		var tlsConfig *client.TLSConfig
-		if tlsConfigMap, ok := endpointMap["tlsConfig"].(map[string]interface{}); ok {
-			tlsConfig = buildTLSConfig(tlsConfigMap)
-		}
-
-		// Extract metricRelabelConfigs
-		var metricRelabelConfigs model.RelabelConfigs
-		if relabelConfigs, ok := endpointMap["metricRelabelConfigs"].([]interface{}); ok && len(relabelConfigs) > 0 {
-			metricRelabelConfigs = model.ParseRelabelConfigs(relabelConfigs)
-		} else if relabelConfigs, ok := targetConfig["metricRelabelConfigs"].([]interface{}); ok && len(relabelConfigs) > 0 {
-			// Check if metricRelabelConfigs is defined at the target level
-			metricRelabelConfigs = model.ParseRelabelConfigs(relabelConfigs)
-		}
-
-		// Create a scraper task for the target
-		scraperTask := NewScraperTask(targetName, target, metricRelabelConfigs, tlsConfig)
-
-		// Schedule the scraper task
-		go sm.scheduleScraperTask(scraperTask, endpointInterval)
+	timer := time.NewTimer(rawQueueEnqueueTimeout)
+	defer timer.Stop()
+	select {
+	case sm.rawQueue <- data:
+		return true
+	case <-timer.C:
+	case <-sm.stopCh:
 	}
-}
-
-// handleStaticEndpointsTarget handles a StaticEndpoints target
-func (sm *ScraperManager) handleStaticEndpointsTarget(targetName string, targetConfig map[string]interface{}, defaultInterval time.Duration) {
-	logutil.Printf("INFO", "Processing StaticEndpoints target: %s", targetName)
 
-	// Get the addresses
-	addresses, ok := targetConfig["addresses"].([]interface{})
-	if !ok {
-		logutil.Printf("INFO", "No addresses found for StaticEndpoints target: %s", targetName)
-		return
-	}
-
-	// Get the path
-	path, ok := targetConfig["path"].(string)
-	if !ok {
-		logutil.Printf("INFO", "No path found for StaticEndpoints target: %s", targetName)
-		return
-	}
-
-	// Get the interval
-	interval := defaultInterval
-	if intervalStr, ok := targetConfig["interval"].(string); ok {
-		if intervalSeconds, err := sm.configManager.ParseInterval(intervalStr); err == nil {
-			interval = time.Duration(intervalSeconds) * time.Second
-		}
-	}
-
-	// Get the scheme
-	scheme := "http"
-	// For StaticEndpoints, we can't infer the scheme from a port name
-	// If TLS config is present, default to HTTPS
-	if _, ok := targetConfig["tlsConfig"].(map[string]interface{}); ok {
-		scheme = "https"
-	}
-	// Override with explicit configuration if provided
-	if schemeStr, ok := targetConfig["scheme"].(string); ok {
-		scheme = schemeStr
-	}
-
-	// Get the labels
-	labels := make(map[string]string)
-	if labelsMap, ok := targetConfig["labels"].(map[string]interface{}); ok {
-		for key, value := range labelsMap {
-			if strValue, ok := value.(string); ok {
-				labels[key] = strValue
-			}
-		}
-	}
-
-	// Extract TLS configuration
-	var tlsConfig *client.TLSConfig
-	if tlsConfigMap, ok := targetConfig["tlsConfig"].(map[string]interface{}); ok {
-		tlsConfig = buildTLSConfig(tlsConfigMap)
-	}
-
-	// Create a metric selector config
-	metricSelectorConfig := make(map[string]interface{})
-	metricSelectorConfig["enabled"] = true
-
-	// Add metricRelabelConfigs if present
-	if metricRelabelConfigs, ok := targetConfig["metricRelabelConfigs"].([]interface{}); ok && len(metricRelabelConfigs) > 0 {
-		metricSelectorConfig["metricRelabelConfigs"] = metricRelabelConfigs
-	}
-
-	// Process each address
-	for _, address := range addresses {
-		addressStr, ok := address.(string)
-		if !ok {
-			continue
-		}
-
-		// Extract metricRelabelConfigs from metricSelectorConfig
-		var metricRelabelConfigs model.RelabelConfigs
-		if metricSelectorConfig != nil {
-			if relabelConfigs, ok := metricSelectorConfig["metricRelabelConfigs"].([]interface{}); ok {
-				metricRelabelConfigs = model.ParseRelabelConfigs(relabelConfigs)
-			}
-		}
-
-		// Create a StaticEndpoints scraper task
-		scraperTask := NewStaticEndpointsScraperTask(
-			targetName,
-			addressStr,
-			path,
-			scheme,
-			metricRelabelConfigs,
-			tlsConfig,
-		)
-
-		// Schedule the scraper task
-		go sm.scheduleScraperTask(scraperTask, interval)
-	}
+	sm.logDroppedScrape(data.TargetID)
+	return false
 }
-*/
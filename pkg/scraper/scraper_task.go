@@ -1,6 +1,7 @@
 package scraper
 
 import (
+	"context"
 	"fmt"
 	"net/url"
 	"strings"
@@ -10,7 +11,6 @@ import (
 
 	"open-agent/pkg/client"
 	"open-agent/pkg/config"
-	"open-agent/pkg/k8s"
 	"open-agent/pkg/model"
 	"open-agent/tools/util/logutil"
 )
@@ -34,6 +34,7 @@ const (
 
 // ScraperTask represents a task to scrape metrics from a target
 type ScraperTask struct {
+	TargetID             string // Discovery target ID
 	TargetName           string
 	TargetType           TargetType
 	TargetURL            string            // Used for DirectURLType and as a fallback for other types
@@ -43,13 +44,60 @@ type ScraperTask struct {
 	Path                 string            // Used for all types
 	Scheme               string            // Used for all types
 	Timeout              string            // HTTP timeout for the scrape request (e.g., "10s", "1m")
+	ConnectTimeout       string            // Dial and TLS handshake timeout (e.g., "5s")
+	ReadTimeout          string            // Time to wait for response headers once connected (e.g., "30s")
 	MetricRelabelConfigs model.RelabelConfigs
 	Labels               map[string]string // Target labels
 	TLSConfig            *client.TLSConfig
 	BasicAuth            *config.BasicAuthConfig
-	Params               map[string][]string // HTTP URL parameters for the endpoint
-	NodeName             string              // Used to store the node name for PodMonitor targets
-	AddNodeLabel         bool                // Controls whether to add node label to metrics
+	Params               map[string][]string     // HTTP URL parameters for the endpoint
+	NodeName             string                  // Used to store the node name for PodMonitor targets
+	AddNodeLabel         bool                    // Controls whether to add node label to metrics
+	Headers              map[string]string       // HTTP request headers (User-Agent and per-endpoint overrides)
+	Downsample           *model.DownsampleConfig // Window aggregation applied by the processor
+	MetricPrefix         string                  // Prepended to metric names by the processor
+	UnitConversions      model.UnitConversions   // Value scaling and renaming applied by the processor
+	ValueTransforms      model.ValueTransforms   // Value clamping, scaling and abs applied by the processor
+	InfoJoins            model.InfoJoins         // Info metric label joins applied by the processor
+	Aggregations         model.Aggregations      // Within-scrape aggregation rules applied by the processor
+	ViaAPIServer         bool                    // TargetURL is a kube-apiserver pod proxy URL
+
+	// PreserveAgentNodeLabel adds the node name as agent_node when the exporter emits its own node label
+	PreserveAgentNodeLabel bool
+	// DisableDNSCache resolves the target host on every new connection
+	DisableDNSCache bool
+	// CloseConnection closes the connection after the scrape, so the next one makes a new TLS handshake
+	CloseConnection bool
+	// LabelLengthLimit is enforced by the processor after metric relabeling
+	LabelLengthLimit *model.LabelLengthLimit
+	// TimestampAlignment is the endpoint's timestampAlignment; AlignInterval is set for interval alignment
+	TimestampAlignment string
+	AlignInterval      time.Duration
+	// NonFiniteValues is how the processor handles NaN and ±Inf values
+	NonFiniteValues string
+	// MinSamples is the sample count below which the processor reports the scrape as degraded
+	MinSamples int
+	// ExternalLabels and SampleLimit are applied by the processor
+	ExternalLabels map[string]string
+	SampleLimit    int
+	// SampleBudget is the samples per minute budget of the target's namespace enforced by the processor
+	SampleBudget *model.NamespaceSampleBudget
+	// SecretParams are query params the HTTP client reads from Secrets on every request; the target URL
+	// holds a placeholder for them
+	SecretParams map[string]config.SecretKeySelector
+	// Context aborts the request when cancelled, e.g. when the scheduler is reaped; nil never aborts
+	Context context.Context
+	// MatchSelectors are sent as repeated match[] params to a federate endpoint
+	MatchSelectors []string
+	// HonorLabels and IgnoreTimestamps are applied by the processor
+	HonorLabels      bool
+	IgnoreTimestamps bool
+
+	// Response size of the last Run, also set when the target answered with an HTTP error
+	WireBytes int64 // body bytes on the wire (compressed for gzip responses)
+	BodyBytes int64 // decoded body bytes
+	// CertNotAfter is the expiry of the target's leaf certificate for https, zero otherwise
+	CertNotAfter time.Time
 }
 
 // NewStaticEndpointsScraperTask creates a new ScraperTask instance for a StaticEndpoints target
@@ -66,9 +114,9 @@ func NewStaticEndpointsScraperTask(targetName string, targetURL string, path str
 	}
 }
 
-// appendParams appends query parameters to a URL if params are present
+// appendParams appends query parameters and federate match[] selectors to a URL if present
 func (st *ScraperTask) appendParams(baseURL string) (string, error) {
-	if len(st.Params) == 0 {
+	if len(st.Params) == 0 && len(st.MatchSelectors) == 0 {
 		return baseURL, nil
 	}
 
@@ -83,6 +131,10 @@ func (st *ScraperTask) appendParams(baseURL string) (string, error) {
 			query.Add(key, value)
 		}
 	}
+	// Every selector is its own match[] param; federation returns the union of their series
+	for _, selector := range st.MatchSelectors {
+		query.Add("match[]", selector)
+	}
 	u.RawQuery = query.Encode()
 	return u.String(), nil
 }
@@ -102,7 +154,7 @@ func (st *ScraperTask) ResolveEndpoint() (string, error) {
 
 	// For PodMonitor and ServiceMonitor, we need to resolve the endpoint dynamically
 
-	k8sClient := k8s.GetInstance()
+	k8sClient := k8sProvider()
 	if !k8sClient.IsInitialized() {
 		return "", fmt.Errorf("kubernetes client not initialized")
 	}
@@ -272,20 +324,44 @@ func (st *ScraperTask) Run() (*model.ScrapeRawData, error) {
 		}
 	}
 
+	timeouts := client.Timeouts{
+		Connect: st.parsePhaseTimeout("connectTimeout", st.ConnectTimeout),
+		Read:    st.parsePhaseTimeout("readTimeout", st.ReadTimeout),
+		Overall: timeout,
+	}
+
 	// Execute the HTTP request
 	httpClient := client.GetInstance()
 	var responseBytes []byte
 	var contentType string
 	var httpErr error
 
-	responseBytes, contentType, httpErr = httpClient.ExecuteGetWithAuthResponse(formattedURL, st.TLSConfig, st.BasicAuth, timeout)
+	var stats client.ResponseStats
+	ctx := st.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx = client.WithSecretParams(ctx, st.SecretParams)
+	if st.ViaAPIServer {
+		responseBytes, contentType, stats, httpErr = httpClient.ExecuteGetViaAPIServerContext(ctx, formattedURL, st.Headers, timeouts)
+	} else {
+		if st.DisableDNSCache {
+			ctx = client.WithoutDNSCache(ctx)
+		}
+		if st.CloseConnection {
+			ctx = client.WithCloseConnection(ctx)
+		}
+		responseBytes, contentType, stats, httpErr = httpClient.ExecuteGetWithStatsContext(ctx, formattedURL, st.TLSConfig, st.BasicAuth, st.Headers, timeouts)
+	}
+	st.WireBytes, st.BodyBytes = stats.WireBytes, stats.BodyBytes
+	st.CertNotAfter = stats.CertNotAfter
 
 	if httpErr != nil {
 		logutil.Infof("SCRAPER", "Failed to collect from target [%s]: %v", st.TargetName, httpErr)
 		if config.IsDebugEnabled() {
 			logutil.Debugf("SCRAPER", "Error scraping target %s for target %s: %v", targetURL, st.TargetName, httpErr)
 		}
-		return nil, fmt.Errorf("error scraping target %s for target %s: %v", targetURL, st.TargetName, httpErr)
+		return nil, fmt.Errorf("error scraping target %s for target %s: %w", targetURL, st.TargetName, httpErr)
 	}
 
 	// The response body may be binary (protobuf) or text exposition. It is kept
@@ -299,7 +375,26 @@ func (st *ScraperTask) Run() (*model.ScrapeRawData, error) {
 	} else {
 		rawData = model.NewScrapeRawData(targetURL, response, st.MetricRelabelConfigs, st.Labels, collectionTime)
 	}
+	rawData.TargetID = st.TargetID
 	rawData.ContentType = contentType
+	rawData.Downsample = st.Downsample
+	rawData.MetricPrefix = st.MetricPrefix
+	rawData.PreserveAgentNodeLabel = st.PreserveAgentNodeLabel
+	rawData.LabelLengthLimit = st.LabelLengthLimit
+	rawData.AlignInterval = st.AlignInterval
+	rawData.NonFiniteValues = st.NonFiniteValues
+	rawData.UnitConversions = st.UnitConversions
+	rawData.ValueTransforms = st.ValueTransforms
+	rawData.InfoJoins = st.InfoJoins
+	rawData.Aggregations = st.Aggregations
+	rawData.CertNotAfter = st.CertNotAfter
+	rawData.MinSamples = st.MinSamples
+	rawData.ExternalLabels = st.ExternalLabels
+	rawData.SampleLimit = st.SampleLimit
+	rawData.SampleBudget = st.SampleBudget
+	rawData.HonorLabels = st.HonorLabels
+	rawData.IgnoreTimestamps = st.IgnoreTimestamps
+	rawData.ScrapeStart = startTime.UnixMilli()
 
 	// Log detailed information
 	duration := time.Since(startTime)
@@ -331,11 +426,11 @@ func (st *ScraperTask) Run() (*model.ScrapeRawData, error) {
 
 	// Log collection success with essential information at INFO level
 	if isProtobuf {
-		logutil.Infof("SCRAPER", "Successfully collected from target [%s]: protobuf payload, %d bytes, took %v",
-			st.TargetName, len(response), duration)
+		logutil.Infof("SCRAPER", "Successfully collected from target [%s]: protobuf payload, %d bytes (%d on the wire), took %v",
+			st.TargetName, len(response), st.WireBytes, duration)
 	} else {
-		logutil.Infof("SCRAPER", "Successfully collected from target [%s]: %d metrics, %d bytes, took %v",
-			st.TargetName, metricCount, len(response), duration)
+		logutil.Infof("SCRAPER", "Successfully collected from target [%s]: %d metrics, %d bytes (%d on the wire), took %v",
+			st.TargetName, metricCount, len(response), st.WireBytes, duration)
 	}
 
 	// Keep detailed debug information
@@ -345,3 +440,16 @@ func (st *ScraperTask) Run() (*model.ScrapeRawData, error) {
 
 	return rawData, nil
 }
+
+// parsePhaseTimeout parses a connect/read timeout; empty or invalid values use the client default
+func (st *ScraperTask) parsePhaseTimeout(name, value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		logutil.Infof("SCRAPER", "Invalid %s format '%s' for target [%s], using default: %v", name, value, st.TargetName, err)
+		return 0
+	}
+	return parsed
+}
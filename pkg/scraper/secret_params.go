@@ -0,0 +1,23 @@
+package scraper
+
+import (
+	"open-agent/pkg/config"
+	"open-agent/pkg/discovery"
+)
+
+// secretParamsFor returns the secret params of an endpoint with their Secret namespace filled in:
+// the target's namespace for Kubernetes targets, left to the client's default for static endpoints
+func secretParamsFor(target *discovery.Target, params map[string]config.SecretKeySelector) map[string]config.SecretKeySelector {
+	if len(params) == 0 {
+		return nil
+	}
+	namespace := targetNamespace(target)
+	resolved := make(map[string]config.SecretKeySelector, len(params))
+	for name, selector := range params {
+		if selector.Namespace == "" {
+			selector.Namespace = namespace
+		}
+		resolved[name] = selector
+	}
+	return resolved
+}
@@ -0,0 +1,44 @@
+package scraper
+
+import (
+	"time"
+
+	"open-agent/pkg/model"
+)
+
+// SelfMetricsInterval is how often the periodic self-metrics are sent
+const SelfMetricsInterval = time.Minute
+
+// selfMetricSenders are the self-metrics queued every SelfMetricsInterval, each sender
+// collects its own series and drops them if the queue is full
+var selfMetricSenders = []func(sm *ScraperManager){
+	(*ScraperManager).sendScrapeBytes,
+	(*ScraperManager).sendDNSCacheStats,
+	(*ScraperManager).sendOverloadState,
+	(*ScraperManager).sendNamespaceBudgets,
+	(*ScraperManager).sendUnscheduledTargets,
+	(*ScraperManager).sendSchedulerGoroutines,
+}
+
+// SetSelfMetricsQueue sets the queue the agent self-metrics are sent to.
+// Must be called before StartScraping; without a queue the self-metrics are not sent.
+func (sm *ScraperManager) SetSelfMetricsQueue(queue chan<- *model.ConversionResult) {
+	sm.selfMetricsQueue = queue
+}
+
+// selfMetricsLoop sends the periodic self-metrics until the manager stops
+func (sm *ScraperManager) selfMetricsLoop() {
+	ticker := time.NewTicker(SelfMetricsInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			for _, send := range selfMetricSenders {
+				send(sm)
+			}
+		case <-sm.stopCh:
+			return
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package scraper
+
+import (
+	"sync"
+	"time"
+
+	"open-agent/pkg/discovery"
+	"open-agent/pkg/k8s"
+	"open-agent/pkg/model"
+	"open-agent/tools/util/logutil"
+)
+
+// MetricTargetRestarted is sent once, with the first successful scrape after the scraped pod's
+// containers restarted or the pod was recreated, to mark the counter reset
+const MetricTargetRestarted = "openagent_target_restarted"
+
+// podRestarts remembers the pod generation of every pod target. It outlives the target's scheduler,
+// which is stopped while a restarting pod is not ready.
+type podRestarts struct {
+	mu          sync.Mutex
+	generations map[string]discovery.PodGeneration
+}
+
+// observe records the target's pod generation and reports whether the pod restarted since the
+// previous observation. The first observation of a target is not a restart.
+func (r *podRestarts) observe(target *discovery.Target) bool {
+	generation, ok := target.PodGeneration()
+	if !ok {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.generations == nil {
+		r.generations = make(map[string]discovery.PodGeneration)
+	}
+	previous, seen := r.generations[target.ID]
+	r.generations[target.ID] = generation
+	return seen && generation.RestartedSince(previous)
+}
+
+// forget drops a target that discovery no longer knows
+func (r *podRestarts) forget(targetID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.generations, targetID)
+}
+
+// markRestart makes the target's next successful scrape send openagent_target_restarted
+func (sm *ScraperManager) markRestart(targetID string) {
+	sm.schedulerMutex.RLock()
+	scheduler := sm.targetSchedulers[targetID]
+	sm.schedulerMutex.RUnlock()
+	if scheduler == nil {
+		return
+	}
+	logutil.Printf("INFO", "[SCRAPER] Target %s restarted, marking the counter reset with the next scrape", targetID)
+	scheduler.mutex.Lock()
+	scheduler.restartPending = true
+	scheduler.mutex.Unlock()
+}
+
+// takeRestart reports whether the target restarted since the last call
+func (ts *TargetScheduler) takeRestart() bool {
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+	restarted := ts.restartPending
+	ts.restartPending = false
+	return restarted
+}
+
+// sendTargetRestarted sends openagent_target_restarted{job,instance,pod}=1 for the target
+func (sm *ScraperManager) sendTargetRestarted(target *discovery.Target) {
+	if sm.selfMetricsQueue == nil {
+		return
+	}
+
+	now := time.Now().UnixMilli()
+	restarted := model.NewOpenMx(MetricTargetRestarted, now, 1)
+	restarted.AddLabel("job", target.Labels["job"])
+	restarted.AddLabel("instance", target.Labels["instance"])
+	if ref, ok := target.Metadata["objectRef"].(k8s.ObjectRef); ok {
+		restarted.AddLabel("pod", ref.Name)
+	}
+	help := model.NewOpenMxHelp(MetricTargetRestarted)
+	help.Put("help", "Set to 1 once when the scraped pod restarted and its counters were reset")
+	help.Put("type", "gauge")
+	result := model.NewConversionResult([]*model.OpenMx{restarted}, []*model.OpenMxHelp{help})
+	result.SetCollectionTime(now)
+
+	select {
+	case sm.selfMetricsQueue <- result:
+	default:
+	}
+}
@@ -0,0 +1,113 @@
+package scraper
+
+import (
+	"testing"
+
+	"open-agent/pkg/config"
+	"open-agent/pkg/discovery"
+	"open-agent/pkg/discovery/discoverytest"
+	"open-agent/pkg/k8s"
+	"open-agent/pkg/model"
+)
+
+// restartingPodTarget returns a ready pod target of generation restarts, as PodMonitor discovery creates it
+func restartingPodTarget(url string, restarts int32) *discovery.Target {
+	target := discoverytest.Target("app/default/app-0/8080-metrics", url, discovery.EndpointConfig{Path: "/metrics", Interval: "60s"})
+	target.Metadata["objectRef"] = k8s.ObjectRef{Kind: "Pod", Namespace: "default", Name: "app-0", UID: "uid-1"}
+	target.Metadata["podGeneration"] = discovery.PodGeneration{UID: "uid-1", RestartCount: restarts}
+	return target
+}
+
+// restartMarkers drains the self-metrics queue and returns the openagent_target_restarted samples
+func restartMarkers(queue chan *model.ConversionResult) []*model.OpenMx {
+	var markers []*model.OpenMx
+	for {
+		select {
+		case result := <-queue:
+			for _, openMx := range result.GetOpenMxList() {
+				if openMx.Metric == MetricTargetRestarted {
+					markers = append(markers, openMx)
+				}
+			}
+		default:
+			return markers
+		}
+	}
+}
+
+func TestTargetRestarted_SentOnceAfterRestartCountBump(t *testing.T) {
+	exporter := discoverytest.NewExporter("requests_total 5\n")
+	defer exporter.Close()
+
+	sd := discoverytest.New(restartingPodTarget(exporter.URL(), 0))
+	selfMetrics := make(chan *model.ConversionResult, 10)
+	sm := NewScraperManager(&config.ConfigManager{}, sd, make(chan *model.ScrapeRawData, 10), "")
+	sm.SetSelfMetricsQueue(selfMetrics)
+	defer sm.Stop()
+	sm.updateTargetSchedulers()
+	defer sm.stopAllSchedulers()
+
+	id := "app/default/app-0/8080-metrics"
+	sm.scrapeTarget(schedulerFor(sm, id).getTarget())
+	if markers := restartMarkers(selfMetrics); len(markers) != 0 {
+		t.Fatalf("expected no restart marker for a newly discovered pod, got %d", len(markers))
+	}
+
+	// A container restarts
+	sd.Add(restartingPodTarget(exporter.URL(), 1))
+	sm.updateTargetSchedulers()
+	sm.scrapeTarget(schedulerFor(sm, id).getTarget())
+	markers := restartMarkers(selfMetrics)
+	if len(markers) != 1 {
+		t.Fatalf("expected one restart marker, got %d", len(markers))
+	}
+	if markers[0].Value != 1 || !hasLabelValue(markers[0], "pod", "app-0") || !hasLabelValue(markers[0], "job", id) {
+		t.Errorf("unexpected restart marker %+v", markers[0])
+	}
+
+	// One shot: the next scrape carries no marker
+	sm.updateTargetSchedulers()
+	sm.scrapeTarget(schedulerFor(sm, id).getTarget())
+	if markers := restartMarkers(selfMetrics); len(markers) != 0 {
+		t.Errorf("expected the marker to be sent once, got %d more", len(markers))
+	}
+}
+
+func TestTargetRestarted_SurvivesNotReadyPeriod(t *testing.T) {
+	exporter := discoverytest.NewExporter("requests_total 5\n")
+	defer exporter.Close()
+
+	id := "app/default/app-0/8080-metrics"
+	sd := discoverytest.New(restartingPodTarget(exporter.URL(), 2))
+	selfMetrics := make(chan *model.ConversionResult, 10)
+	sm := NewScraperManager(&config.ConfigManager{}, sd, make(chan *model.ScrapeRawData, 10), "")
+	sm.SetSelfMetricsQueue(selfMetrics)
+	defer sm.Stop()
+	sm.updateTargetSchedulers()
+	defer sm.stopAllSchedulers()
+
+	// The restarting container fails readiness and the scheduler is stopped
+	sd.SetState(id, discovery.TargetStatePending)
+	sm.updateTargetSchedulers()
+	if schedulerFor(sm, id) != nil {
+		t.Fatalf("expected the scheduler to stop while the pod is not ready")
+	}
+
+	// Ready again with a higher restart count
+	restarted := restartingPodTarget(exporter.URL(), 3)
+	sd.Add(restarted)
+	sm.updateTargetSchedulers()
+	sm.scrapeTarget(schedulerFor(sm, id).getTarget())
+	if markers := restartMarkers(selfMetrics); len(markers) != 1 {
+		t.Fatalf("expected one restart marker after the pod became ready again, got %d", len(markers))
+	}
+}
+
+func hasLabelValue(openMx *model.OpenMx, name, value string) bool {
+	for _, label := range openMx.Labels {
+		if label.Key == name {
+			return label.Value == value
+		}
+	}
+	return false
+}
@@ -35,6 +35,17 @@ func TestBuildTLSConfig_ParsesAllFields(t *testing.T) {
 	}
 }
 
+func TestBuildTLSConfig_ParsesVersions(t *testing.T) {
+	got := buildTLSConfig(map[string]interface{}{
+		"minVersion":    "TLS10",
+		"maxVersion":    "TLS11",
+		"renegotiation": "once",
+	})
+	if got.MinVersion != "TLS10" || got.MaxVersion != "TLS11" || got.Renegotiation != "once" {
+		t.Errorf("versions: got %q-%q renegotiation %q, want TLS10-TLS11 renegotiation once", got.MinVersion, got.MaxVersion, got.Renegotiation)
+	}
+}
+
 func TestBuildTLSConfig_NilReturnsNil(t *testing.T) {
 	if got := buildTLSConfig(nil); got != nil {
 		t.Errorf("expected nil for nil map, got %+v", got)
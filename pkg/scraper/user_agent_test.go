@@ -0,0 +1,123 @@
+package scraper
+
+import (
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"open-agent/pkg/client"
+	"open-agent/pkg/discovery"
+)
+
+// recordingServer serves a gzip-compressed metric and records the last request headers
+type recordingServer struct {
+	mu     sync.Mutex
+	header http.Header
+}
+
+func (rs *recordingServer) lastHeader() http.Header {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	return rs.header
+}
+
+func startRecordingServer(t *testing.T) (*httptest.Server, *recordingServer) {
+	t.Helper()
+	rs := &recordingServer{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rs.mu.Lock()
+		rs.header = r.Header.Clone()
+		rs.mu.Unlock()
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			w.Header().Set("Content-Encoding", "gzip")
+			gz := gzip.NewWriter(w)
+			_, _ = gz.Write([]byte("up 1\n"))
+			_ = gz.Close()
+			return
+		}
+		_, _ = w.Write([]byte("up 1\n"))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, rs
+}
+
+func newUserAgentTarget(url string, headers map[string]string) *discovery.Target {
+	return &discovery.Target{
+		ID:     "ua-test",
+		URL:    url + "/metrics",
+		Labels: map[string]string{},
+		Metadata: map[string]interface{}{
+			"targetName": "ua-test",
+			"endpoint":   discovery.EndpointConfig{Path: "/metrics", Headers: headers},
+		},
+	}
+}
+
+func TestScraperTask_DefaultUserAgent(t *testing.T) {
+	srv, rs := startRecordingServer(t)
+
+	sm := &ScraperManager{userAgent: client.BuildUserAgent("1.2.3", "abc1234")}
+	task := sm.createScraperTaskFromTarget(newUserAgentTarget(srv.URL, nil))
+
+	rawData, err := task.Run()
+	if err != nil {
+		t.Fatalf("scrape failed: %v", err)
+	}
+
+	header := rs.lastHeader()
+	if got, want := header.Get("User-Agent"), "whatap-open-agent/1.2.3 (+abc1234)"; got != want {
+		t.Errorf("User-Agent = %q, want %q", got, want)
+	}
+	if got := header.Get("Accept-Encoding"); got != "gzip" {
+		t.Errorf("Accept-Encoding = %q, want gzip", got)
+	}
+	if !strings.Contains(rawData.RawData, "up 1") {
+		t.Errorf("expected gzip body to be decoded, got %q", rawData.RawData)
+	}
+}
+
+func TestScraperTask_UserAgentOverride(t *testing.T) {
+	srv, rs := startRecordingServer(t)
+
+	sm := &ScraperManager{userAgent: client.BuildUserAgent("1.2.3", "abc1234")}
+	task := sm.createScraperTaskFromTarget(newUserAgentTarget(srv.URL, map[string]string{
+		"User-Agent":      "waf-allowed-agent",
+		"Accept-Encoding": "identity",
+	}))
+
+	rawData, err := task.Run()
+	if err != nil {
+		t.Fatalf("scrape failed: %v", err)
+	}
+
+	header := rs.lastHeader()
+	if got := header.Get("User-Agent"); got != "waf-allowed-agent" {
+		t.Errorf("User-Agent = %q, want override", got)
+	}
+	if got := header.Get("Accept-Encoding"); got != "identity" {
+		t.Errorf("Accept-Encoding = %q, want identity", got)
+	}
+	if !strings.Contains(rawData.RawData, "up 1") {
+		t.Errorf("unexpected body %q", rawData.RawData)
+	}
+}
+
+func TestBuildUserAgent(t *testing.T) {
+	cases := []struct {
+		version, commit, want string
+	}{
+		{"1.2.3", "abc1234", "whatap-open-agent/1.2.3 (+abc1234)"},
+		{"1.2.3", "", "whatap-open-agent/1.2.3"},
+		{"", "", "whatap-open-agent/dev"},
+	}
+	for _, c := range cases {
+		if got := client.BuildUserAgent(c.version, c.commit); got != c.want {
+			t.Errorf("BuildUserAgent(%q, %q) = %q, want %q", c.version, c.commit, got, c.want)
+		}
+	}
+}
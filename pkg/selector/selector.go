@@ -0,0 +1,238 @@
+// Package selector evaluates Kubernetes label and namespace selectors written in the scrape configuration,
+// with the same semantics as metav1.LabelSelector (matchLabels and matchExpressions).
+package selector
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Operators supported in matchExpressions
+const (
+	OpIn           = "In"
+	OpNotIn        = "NotIn"
+	OpExists       = "Exists"
+	OpDoesNotExist = "DoesNotExist"
+)
+
+// Requirement is a single matchExpressions entry
+type Requirement struct {
+	Key      string   `yaml:"key"`
+	Operator string   `yaml:"operator"`
+	Values   []string `yaml:"values,omitempty"`
+}
+
+// Matches reports whether labels satisfy the requirement
+func (r Requirement) Matches(labels map[string]string) bool {
+	value, exists := labels[r.Key]
+	switch r.Operator {
+	case OpIn:
+		return exists && contains(r.Values, value)
+	case OpNotIn:
+		return !exists || !contains(r.Values, value)
+	case OpExists:
+		return exists
+	case OpDoesNotExist:
+		return !exists
+	}
+	return false
+}
+
+// Selector is a parsed label selector. All matchLabels entries and matchExpressions requirements must hold.
+type Selector struct {
+	MatchLabels      map[string]string `yaml:"matchLabels,omitempty"`
+	MatchExpressions []Requirement     `yaml:"matchExpressions,omitempty"`
+}
+
+// Parse parses a selector map with matchLabels and matchExpressions.
+// Values are compared as strings, so `version: 2` matches the label value "2".
+func Parse(raw map[string]interface{}) (*Selector, error) {
+	s := &Selector{}
+
+	if rawLabels, ok := raw["matchLabels"]; ok && rawLabels != nil {
+		matchLabels, ok := rawLabels.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("matchLabels: expected a map, got %T", rawLabels)
+		}
+		s.MatchLabels = make(map[string]string, len(matchLabels))
+		for key, value := range matchLabels {
+			s.MatchLabels[key] = fmt.Sprintf("%v", value)
+		}
+	}
+
+	if rawExpressions, ok := raw["matchExpressions"]; ok && rawExpressions != nil {
+		expressions, ok := rawExpressions.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("matchExpressions: expected a list, got %T", rawExpressions)
+		}
+		for i, expr := range expressions {
+			requirement, err := parseRequirement(expr)
+			if err != nil {
+				return nil, fmt.Errorf("matchExpressions[%d]: %v", i, err)
+			}
+			s.MatchExpressions = append(s.MatchExpressions, requirement)
+		}
+	}
+	return s, nil
+}
+
+func parseRequirement(expr interface{}) (Requirement, error) {
+	exprMap, ok := expr.(map[string]interface{})
+	if !ok {
+		return Requirement{}, fmt.Errorf("expected a map, got %T", expr)
+	}
+	key, _ := exprMap["key"].(string)
+	operator, _ := exprMap["operator"].(string)
+
+	var values []string
+	if rawValues, ok := exprMap["values"].([]interface{}); ok {
+		for _, v := range rawValues {
+			values = append(values, fmt.Sprintf("%v", v))
+		}
+	}
+
+	requirement := Requirement{Key: key, Operator: operator, Values: values}
+	return requirement, requirement.Validate()
+}
+
+// Validate checks the operator and that values are given exactly for In and NotIn
+func (r Requirement) Validate() error {
+	if r.Key == "" {
+		return fmt.Errorf("key is required")
+	}
+	switch r.Operator {
+	case OpIn, OpNotIn:
+		if len(r.Values) == 0 {
+			return fmt.Errorf("operator %s on %q requires values", r.Operator, r.Key)
+		}
+	case OpExists, OpDoesNotExist:
+		if len(r.Values) != 0 {
+			return fmt.Errorf("operator %s on %q does not take values", r.Operator, r.Key)
+		}
+	default:
+		return fmt.Errorf("unsupported operator %q on %q", r.Operator, r.Key)
+	}
+	return nil
+}
+
+// Validate checks the label part of a namespace selector decoded from YAML
+func (ns *NamespaceSelector) Validate() error {
+	if ns == nil {
+		return nil
+	}
+	return ns.Labels.Validate()
+}
+
+// Validate checks every matchExpressions requirement of a selector decoded from YAML
+func (s *Selector) Validate() error {
+	if s == nil {
+		return nil
+	}
+	for i, requirement := range s.MatchExpressions {
+		if err := requirement.Validate(); err != nil {
+			return fmt.Errorf("matchExpressions[%d]: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// Empty reports whether the selector has no requirements
+func (s *Selector) Empty() bool {
+	return s == nil || (len(s.MatchLabels) == 0 && len(s.MatchExpressions) == 0)
+}
+
+// Matches reports whether labels satisfy every requirement. An empty selector matches everything.
+func (s *Selector) Matches(labels map[string]string) bool {
+	if s == nil {
+		return true
+	}
+	for key, value := range s.MatchLabels {
+		if actual, exists := labels[key]; !exists || actual != value {
+			return false
+		}
+	}
+	for _, requirement := range s.MatchExpressions {
+		if !requirement.Matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// String renders the selector in kubectl -l syntax, for logs
+func (s *Selector) String() string {
+	if s.Empty() {
+		return "<everything>"
+	}
+	keys := make([]string, 0, len(s.MatchLabels))
+	for key := range s.MatchLabels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, key := range keys {
+		parts = append(parts, key+"="+s.MatchLabels[key])
+	}
+	for _, r := range s.MatchExpressions {
+		switch r.Operator {
+		case OpIn:
+			parts = append(parts, fmt.Sprintf("%s in (%s)", r.Key, strings.Join(r.Values, ",")))
+		case OpNotIn:
+			parts = append(parts, fmt.Sprintf("%s notin (%s)", r.Key, strings.Join(r.Values, ",")))
+		case OpExists:
+			parts = append(parts, r.Key)
+		case OpDoesNotExist:
+			parts = append(parts, "!"+r.Key)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// NamespaceSelector selects namespaces by name (matchNames) and/or by label selector
+type NamespaceSelector struct {
+	MatchNames []string `yaml:"matchNames,omitempty"`
+	Labels     Selector `yaml:",inline"`
+}
+
+// ParseNamespaceSelector parses a namespaceSelector map with matchNames, matchLabels and matchExpressions
+func ParseNamespaceSelector(raw map[string]interface{}) (*NamespaceSelector, error) {
+	labels, err := Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	ns := &NamespaceSelector{Labels: *labels}
+	if rawNames, ok := raw["matchNames"]; ok && rawNames != nil {
+		names, ok := rawNames.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("matchNames: expected a list, got %T", rawNames)
+		}
+		for i, name := range names {
+			nameStr, ok := name.(string)
+			if !ok || nameStr == "" {
+				return nil, fmt.Errorf("matchNames[%d]: expected a non-empty string", i)
+			}
+			ns.MatchNames = append(ns.MatchNames, nameStr)
+		}
+	}
+	return ns, nil
+}
+
+// Matches reports whether a namespace is selected: its name must be in matchNames (when set)
+// and its labels must satisfy the label selector
+func (ns *NamespaceSelector) Matches(name string, labels map[string]string) bool {
+	if len(ns.MatchNames) > 0 && !contains(ns.MatchNames, name) {
+		return false
+	}
+	return ns.Labels.Matches(labels)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,151 @@
+package selector
+
+import (
+	"testing"
+)
+
+func expr(key, operator string, values ...interface{}) map[string]interface{} {
+	m := map[string]interface{}{"key": key, "operator": operator}
+	if len(values) > 0 {
+		m["values"] = values
+	}
+	return m
+}
+
+func TestSelector_Matches(t *testing.T) {
+	labels := map[string]string{"app": "api", "tier": "backend", "version": "2"}
+
+	tests := []struct {
+		name     string
+		selector map[string]interface{}
+		want     bool
+	}{
+		{"empty matches everything", map[string]interface{}{}, true},
+		{"matchLabels equal", map[string]interface{}{"matchLabels": map[string]interface{}{"app": "api"}}, true},
+		{"matchLabels all must hold", map[string]interface{}{"matchLabels": map[string]interface{}{"app": "api", "tier": "frontend"}}, false},
+		{"matchLabels missing label", map[string]interface{}{"matchLabels": map[string]interface{}{"team": "core"}}, false},
+		{"matchLabels number value", map[string]interface{}{"matchLabels": map[string]interface{}{"version": 2}}, true},
+		{"In", map[string]interface{}{"matchExpressions": []interface{}{expr("tier", OpIn, "backend", "batch")}}, true},
+		{"In value not listed", map[string]interface{}{"matchExpressions": []interface{}{expr("tier", OpIn, "frontend")}}, false},
+		{"In label missing", map[string]interface{}{"matchExpressions": []interface{}{expr("team", OpIn, "core")}}, false},
+		{"NotIn", map[string]interface{}{"matchExpressions": []interface{}{expr("tier", OpNotIn, "frontend")}}, true},
+		{"NotIn value listed", map[string]interface{}{"matchExpressions": []interface{}{expr("tier", OpNotIn, "backend")}}, false},
+		{"NotIn label missing", map[string]interface{}{"matchExpressions": []interface{}{expr("team", OpNotIn, "core")}}, true},
+		{"Exists", map[string]interface{}{"matchExpressions": []interface{}{expr("app", OpExists)}}, true},
+		{"Exists label missing", map[string]interface{}{"matchExpressions": []interface{}{expr("team", OpExists)}}, false},
+		{"DoesNotExist", map[string]interface{}{"matchExpressions": []interface{}{expr("team", OpDoesNotExist)}}, true},
+		{"DoesNotExist label present", map[string]interface{}{"matchExpressions": []interface{}{expr("app", OpDoesNotExist)}}, false},
+		{"expressions are ANDed", map[string]interface{}{"matchExpressions": []interface{}{
+			expr("app", OpExists), expr("tier", OpIn, "frontend"),
+		}}, false},
+		{"matchLabels and expressions", map[string]interface{}{
+			"matchLabels":      map[string]interface{}{"app": "api"},
+			"matchExpressions": []interface{}{expr("version", OpIn, 1, 2)},
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.selector)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if got := s.Matches(labels); got != tt.want {
+				t.Errorf("Matches(%v) = %v, want %v (selector %s)", labels, got, tt.want, s)
+			}
+		})
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		selector map[string]interface{}
+	}{
+		{"matchLabels not a map", map[string]interface{}{"matchLabels": []interface{}{"app"}}},
+		{"matchExpressions not a list", map[string]interface{}{"matchExpressions": map[string]interface{}{}}},
+		{"expression not a map", map[string]interface{}{"matchExpressions": []interface{}{"app In api"}}},
+		{"missing key", map[string]interface{}{"matchExpressions": []interface{}{expr("", OpExists)}}},
+		{"unknown operator", map[string]interface{}{"matchExpressions": []interface{}{expr("app", "Equals", "api")}}},
+		{"In without values", map[string]interface{}{"matchExpressions": []interface{}{expr("app", OpIn)}}},
+		{"NotIn without values", map[string]interface{}{"matchExpressions": []interface{}{expr("app", OpNotIn)}}},
+		{"Exists with values", map[string]interface{}{"matchExpressions": []interface{}{expr("app", OpExists, "api")}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse(tt.selector); err == nil {
+				t.Errorf("expected an error for %v", tt.selector)
+			}
+		})
+	}
+}
+
+func TestSelector_Empty(t *testing.T) {
+	var nilSelector *Selector
+	if !nilSelector.Empty() || !nilSelector.Matches(map[string]string{"app": "api"}) {
+		t.Error("a nil selector is empty and matches everything")
+	}
+	s, _ := Parse(map[string]interface{}{"matchLabels": map[string]interface{}{}})
+	if !s.Empty() {
+		t.Error("empty matchLabels should leave the selector empty")
+	}
+}
+
+func TestSelector_String(t *testing.T) {
+	s, err := Parse(map[string]interface{}{
+		"matchLabels": map[string]interface{}{"tier": "backend", "app": "api"},
+		"matchExpressions": []interface{}{
+			expr("env", OpIn, "prod", "stage"), expr("canary", OpDoesNotExist), expr("team", OpExists),
+		},
+	})
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	want := "app=api,tier=backend,env in (prod,stage),!canary,team"
+	if got := s.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestNamespaceSelector_Matches(t *testing.T) {
+	tests := []struct {
+		name      string
+		selector  map[string]interface{}
+		namespace string
+		labels    map[string]string
+		want      bool
+	}{
+		{"matchNames", map[string]interface{}{"matchNames": []interface{}{"prod", "stage"}}, "prod", nil, true},
+		{"matchNames other namespace", map[string]interface{}{"matchNames": []interface{}{"prod"}}, "dev", nil, false},
+		{"matchLabels", map[string]interface{}{"matchLabels": map[string]interface{}{"monitoring": "enabled"}},
+			"team-a", map[string]string{"monitoring": "enabled"}, true},
+		{"matchExpressions", map[string]interface{}{"matchExpressions": []interface{}{expr("env", OpNotIn, "dev")}},
+			"team-a", map[string]string{"env": "prod"}, true},
+		{"matchNames and labels both required", map[string]interface{}{
+			"matchNames":  []interface{}{"team-a"},
+			"matchLabels": map[string]interface{}{"monitoring": "enabled"},
+		}, "team-a", map[string]string{"monitoring": "disabled"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ns, err := ParseNamespaceSelector(tt.selector)
+			if err != nil {
+				t.Fatalf("ParseNamespaceSelector: %v", err)
+			}
+			if got := ns.Matches(tt.namespace, tt.labels); got != tt.want {
+				t.Errorf("Matches(%s, %v) = %v, want %v", tt.namespace, tt.labels, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseNamespaceSelector_InvalidNames(t *testing.T) {
+	for _, raw := range []map[string]interface{}{
+		{"matchNames": "prod"},
+		{"matchNames": []interface{}{"prod", 1}},
+		{"matchNames": []interface{}{""}},
+	} {
+		if _, err := ParseNamespaceSelector(raw); err == nil {
+			t.Errorf("expected an error for %v", raw)
+		}
+	}
+}
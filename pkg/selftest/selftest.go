@@ -0,0 +1,281 @@
+// Package selftest checks at startup that the agent's pipeline works end to end: it serves a few known
+// metrics on an embedded HTTP server, scrapes them as a StaticEndpoints target and waits for the sender
+// to confirm that their samples were sent.
+package selftest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/whatap/golib/lang/pack"
+
+	"open-agent/pkg/config"
+	"open-agent/pkg/discovery"
+	"open-agent/pkg/model"
+	"open-agent/tools/util/logutil"
+)
+
+const (
+	// TargetName is the targetName of the self-test target
+	TargetName = "openagent-self-test"
+	// MetricPrefix starts the names of the metrics served to the self-test target
+	MetricPrefix = "openagent_selftest_"
+	// RunLabel is set on the self-test metrics to the ID of the run, so samples of an earlier agent
+	// process are never taken for this run's
+	RunLabel = "selftest_run"
+	// ScrapeInterval is the interval of the self-test target
+	ScrapeInterval = "5s"
+)
+
+// Stage is a step of the pipeline the self-test checks
+type Stage string
+
+const (
+	// StageServe is the embedded HTTP server serving the self-test metrics
+	StageServe Stage = "serve"
+	// StageDiscovery is the self-test target becoming ready in service discovery
+	StageDiscovery Stage = "discovery"
+	// StageScrape is the scraper requesting the self-test metrics
+	StageScrape Stage = "scrape"
+	// StageProcess is the processor handing the scraped samples to the sender
+	StageProcess Stage = "process"
+	// StageSend is the sender sending the samples to the server
+	StageSend Stage = "send"
+)
+
+// Targets adds and removes the self-test target; *config.ConfigManager implements it
+type Targets interface {
+	AddInternalTarget(target config.TargetConfig)
+	RemoveInternalTarget(name string)
+}
+
+// ReadyTargets lists the targets the scraper scrapes; *discovery.ServiceDiscoveryImpl implements it
+type ReadyTargets interface {
+	GetReadyTargets() []*discovery.Target
+}
+
+// Result is the outcome of a self-test
+type Result struct {
+	Passed bool
+	// Stage is the first stage that did not complete, empty when the self-test passed
+	Stage Stage
+	// Reason explains the failure
+	Reason string
+}
+
+func (r Result) String() string {
+	if r.Passed {
+		return "PASS"
+	}
+	return fmt.Sprintf("FAIL at stage %s: %s", r.Stage, r.Reason)
+}
+
+// SelfTest is one run of the startup self-test
+type SelfTest struct {
+	targets   Targets
+	discovery ReadyTargets
+	duration  time.Duration
+	run       string
+
+	listener net.Listener
+	server   *http.Server
+	address  string
+
+	// scrapes counts the requests for the self-test metrics
+	scrapes atomic.Int64
+	// ready is set once discovery listed the self-test target as ready
+	ready atomic.Bool
+	// reached is set once the sender reported a pack with self-test samples, sent or not
+	reached atomic.Bool
+	// sendErr is the last error of a pack with self-test samples that could not be sent
+	sendErr  atomic.Value
+	sent     chan struct{}
+	sentOnce sync.Once
+
+	// pollInterval is how often discovery is checked; replaced in tests
+	pollInterval time.Duration
+}
+
+// New creates a self-test that scrapes its target for at most duration
+func New(targets Targets, ready ReadyTargets, duration time.Duration) *SelfTest {
+	return &SelfTest{
+		targets:      targets,
+		discovery:    ready,
+		duration:     duration,
+		run:          newRunID(),
+		sent:         make(chan struct{}),
+		pollInterval: time.Second,
+	}
+}
+
+func newRunID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// Start serves the self-test metrics on a loopback port and adds the self-test target. An error fails
+// the self-test in StageServe and is logged as such.
+func (t *SelfTest) Start() error {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		logutil.Printf("ERROR", "[SELFTEST] %s", Result{Stage: StageServe, Reason: err.Error()})
+		return err
+	}
+	t.listener = listener
+	t.address = listener.Addr().String()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", t.serveMetrics)
+	t.server = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go t.server.Serve(listener)
+
+	target, _, err := config.DecodeTargetConfig(map[string]interface{}{
+		"targetName": TargetName,
+		"type":       "StaticEndpoints",
+		"endpoints": []interface{}{
+			map[string]interface{}{
+				"address":  t.address,
+				"path":     "/metrics",
+				"scheme":   "http",
+				"interval": ScrapeInterval,
+			},
+		},
+	})
+	if err != nil {
+		t.server.Close()
+		logutil.Printf("ERROR", "[SELFTEST] %s", Result{Stage: StageServe, Reason: err.Error()})
+		return err
+	}
+	t.targets.AddInternalTarget(target)
+	logutil.Printf("INFO", "[SELFTEST] Started self-test %s, scraping %s for at most %v", t.run, t.address, t.duration)
+	return nil
+}
+
+// serveMetrics serves the known self-test metrics
+func (t *SelfTest) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	scrapes := t.scrapes.Add(1)
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	fmt.Fprintf(w, "# HELP %sup 1 while the self-test target is served\n", MetricPrefix)
+	fmt.Fprintf(w, "# TYPE %sup gauge\n", MetricPrefix)
+	fmt.Fprintf(w, "%sup{%s=%q} 1\n", MetricPrefix, RunLabel, t.run)
+	fmt.Fprintf(w, "# HELP %sscrapes_total Requests for the self-test metrics\n", MetricPrefix)
+	fmt.Fprintf(w, "# TYPE %sscrapes_total counter\n", MetricPrefix)
+	fmt.Fprintf(w, "%sscrapes_total{%s=%q} %d\n", MetricPrefix, RunLabel, t.run, scrapes)
+	fmt.Fprintf(w, "# HELP %sinfo Labels of the self-test run\n", MetricPrefix)
+	fmt.Fprintf(w, "# TYPE %sinfo gauge\n", MetricPrefix)
+	fmt.Fprintf(w, "%sinfo{%s=%q,target=%q} 1\n", MetricPrefix, RunLabel, t.run, TargetName)
+}
+
+// Confirm is the sender's send callback: it records whether a pack carrying samples of this run was sent
+func (t *SelfTest) Confirm(p pack.Pack, err error) {
+	mxPack, ok := p.(*model.OpenMxPack)
+	if !ok || !t.carriesRun(mxPack) {
+		return
+	}
+	t.reached.Store(true)
+	if err != nil {
+		t.sendErr.Store(err.Error())
+		return
+	}
+	t.sentOnce.Do(func() { close(t.sent) })
+}
+
+// carriesRun reports whether a pack has a sample of this run
+func (t *SelfTest) carriesRun(p *model.OpenMxPack) bool {
+	for _, om := range p.GetRecords() {
+		if !strings.HasPrefix(om.Metric, MetricPrefix) {
+			continue
+		}
+		for _, label := range om.Labels {
+			if label.Key == RunLabel && label.Value == t.run {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Wait waits until the sender confirms a sample of the self-test was sent, the duration passes or stop is
+// closed, then removes the self-test target, stops the server and logs the result
+func (t *SelfTest) Wait(stop <-chan struct{}) Result {
+	defer t.cleanup()
+
+	deadline := time.NewTimer(t.duration)
+	defer deadline.Stop()
+	poll := time.NewTicker(t.pollInterval)
+	defer poll.Stop()
+
+	for {
+		select {
+		case <-t.sent:
+			result := Result{Passed: true}
+			logutil.Printf("INFO", "[SELFTEST] %s: %d scrape(s) of %s, samples sent to the server", result, t.scrapes.Load(), t.address)
+			return result
+		case <-deadline.C:
+			result := t.failure()
+			logutil.Printf("ERROR", "[SELFTEST] %s", result)
+			return result
+		case <-poll.C:
+			t.checkDiscovery()
+		case <-stop:
+			result := Result{Stage: t.failure().Stage, Reason: "agent stopped before the self-test finished"}
+			logutil.Printf("WARN", "[SELFTEST] %s", result)
+			return result
+		}
+	}
+}
+
+// Run starts the self-test and waits for its result
+func (t *SelfTest) Run(stop <-chan struct{}) Result {
+	if err := t.Start(); err != nil {
+		return Result{Stage: StageServe, Reason: err.Error()}
+	}
+	return t.Wait(stop)
+}
+
+// checkDiscovery records whether discovery lists the self-test target as ready
+func (t *SelfTest) checkDiscovery() {
+	if t.ready.Load() {
+		return
+	}
+	for _, target := range t.discovery.GetReadyTargets() {
+		if target.Labels["job"] == TargetName {
+			t.ready.Store(true)
+			return
+		}
+	}
+}
+
+// failure names the first stage the self-test did not see complete
+func (t *SelfTest) failure() Result {
+	t.checkDiscovery()
+	switch {
+	case t.scrapes.Load() == 0 && !t.ready.Load():
+		return Result{Stage: StageDiscovery, Reason: fmt.Sprintf("target %s was never ready within %v", TargetName, t.duration)}
+	case t.scrapes.Load() == 0:
+		return Result{Stage: StageScrape, Reason: fmt.Sprintf("%s was never scraped within %v", t.address, t.duration)}
+	case !t.reached.Load():
+		return Result{Stage: StageProcess, Reason: fmt.Sprintf("%d scrape(s), but the sender reported no pack with self-test samples within %v", t.scrapes.Load(), t.duration)}
+	default:
+		reason, _ := t.sendErr.Load().(string)
+		return Result{Stage: StageSend, Reason: fmt.Sprintf("self-test samples could not be sent: %s", reason)}
+	}
+}
+
+// cleanup removes the self-test target and stops serving its metrics
+func (t *SelfTest) cleanup() {
+	t.targets.RemoveInternalTarget(TargetName)
+	if t.server != nil {
+		t.server.Close()
+	}
+}
@@ -0,0 +1,211 @@
+package selftest
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/whatap/golib/lang/pack"
+
+	"open-agent/pkg/config"
+	"open-agent/pkg/converter"
+	"open-agent/pkg/discovery"
+	"open-agent/pkg/model"
+)
+
+// fakePipeline stands in for the configuration and discovery: added targets are listed as ready
+// unless notReady is set
+type fakePipeline struct {
+	mu       sync.Mutex
+	targets  map[string]config.TargetConfig
+	notReady bool
+}
+
+func (f *fakePipeline) AddInternalTarget(target config.TargetConfig) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.targets == nil {
+		f.targets = make(map[string]config.TargetConfig)
+	}
+	f.targets[target.TargetName] = target
+}
+
+func (f *fakePipeline) RemoveInternalTarget(name string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.targets, name)
+}
+
+func (f *fakePipeline) GetReadyTargets() []*discovery.Target {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.notReady {
+		return nil
+	}
+	var ready []*discovery.Target
+	for name, target := range f.targets {
+		ready = append(ready, &discovery.Target{
+			ID:     name,
+			URL:    "http://" + target.Endpoints[0].Address + "/metrics",
+			Labels: map[string]string{"job": name},
+			State:  discovery.TargetStateReady,
+		})
+	}
+	return ready
+}
+
+func (f *fakePipeline) hasTarget() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, ok := f.targets[TargetName]
+	return ok
+}
+
+// scrape fetches the self-test metrics like the scraper and converts them like the processor
+func scrape(t *testing.T, st *SelfTest) []*model.OpenMx {
+	t.Helper()
+	resp, err := http.Get("http://" + st.address + "/metrics")
+	if err != nil {
+		t.Fatalf("scrape: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("scrape: %v", err)
+	}
+	result, err := converter.Convert(string(body))
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	return result.GetOpenMxList()
+}
+
+// mockSender hands packs to the send callback with the outcome of send
+type mockSender struct {
+	send     func(p pack.Pack) error
+	callback func(p pack.Pack, err error)
+}
+
+func (m *mockSender) sendMetrics(metrics []*model.OpenMx) {
+	p := model.NewOpenMxPack()
+	p.SetRecords(metrics)
+	m.callback(p, m.send(p))
+}
+
+func newSelfTest(t *testing.T, pipeline *fakePipeline, duration time.Duration) *SelfTest {
+	t.Helper()
+	st := New(pipeline, pipeline, duration)
+	st.pollInterval = 5 * time.Millisecond
+	if err := st.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	return st
+}
+
+func TestSelfTest_Pass(t *testing.T) {
+	pipeline := &fakePipeline{}
+	st := newSelfTest(t, pipeline, 5*time.Second)
+	if !pipeline.hasTarget() {
+		t.Fatal("expected the self-test target to be added on Start")
+	}
+	sender := &mockSender{send: func(p pack.Pack) error { return nil }, callback: st.Confirm}
+
+	metrics := scrape(t, st)
+	if len(metrics) != 3 {
+		t.Fatalf("expected 3 self-test samples, got %d", len(metrics))
+	}
+	go sender.sendMetrics(metrics)
+
+	result := st.Wait(nil)
+	if !result.Passed || result.String() != "PASS" {
+		t.Fatalf("expected PASS, got %s", result)
+	}
+	if pipeline.hasTarget() {
+		t.Error("expected the self-test target to be removed")
+	}
+	if _, err := http.Get("http://" + st.address + "/metrics"); err == nil {
+		t.Error("expected the self-test server to be stopped")
+	}
+}
+
+func TestSelfTest_FailingStage(t *testing.T) {
+	sendErr := errors.New("no security master available")
+	tests := []struct {
+		name     string
+		notReady bool
+		scrape   bool
+		send     func(p pack.Pack) error
+		stage    Stage
+		reason   string
+	}{
+		{name: "never ready", notReady: true, stage: StageDiscovery, reason: "never ready"},
+		{name: "never scraped", stage: StageScrape, reason: "never scraped"},
+		{name: "never sent", scrape: true, stage: StageProcess, reason: "1 scrape(s)"},
+		{name: "send failed", scrape: true, send: func(p pack.Pack) error { return sendErr }, stage: StageSend, reason: sendErr.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pipeline := &fakePipeline{notReady: tt.notReady}
+			st := newSelfTest(t, pipeline, 100*time.Millisecond)
+			if tt.scrape {
+				metrics := scrape(t, st)
+				if tt.send != nil {
+					sender := &mockSender{send: tt.send, callback: st.Confirm}
+					sender.sendMetrics(metrics)
+				}
+			}
+
+			result := st.Wait(nil)
+			if result.Passed || result.Stage != tt.stage {
+				t.Fatalf("expected a failure at stage %s, got %s", tt.stage, result)
+			}
+			if !strings.Contains(result.Reason, tt.reason) {
+				t.Errorf("expected the reason to contain %q, got %q", tt.reason, result.Reason)
+			}
+			if pipeline.hasTarget() {
+				t.Error("expected the self-test target to be removed")
+			}
+		})
+	}
+}
+
+func TestSelfTest_ConfirmIgnoresOtherPacks(t *testing.T) {
+	st := New(&fakePipeline{}, &fakePipeline{}, time.Second)
+
+	otherRun := model.NewOpenMx(MetricPrefix+"up", 0, 1)
+	otherRun.AddLabel(RunLabel, "earlier-run")
+	unrelated := model.NewOpenMx("node_load1", 0, 1)
+	unrelated.AddLabel(RunLabel, st.run)
+	p := model.NewOpenMxPack()
+	p.SetRecords([]*model.OpenMx{otherRun, unrelated})
+	st.Confirm(p, nil)
+	st.Confirm(model.NewOpenMxHelpPack(), nil)
+
+	if st.reached.Load() {
+		t.Fatal("expected packs without samples of this run to be ignored")
+	}
+	select {
+	case <-st.sent:
+		t.Fatal("expected no confirmation")
+	default:
+	}
+}
+
+func TestSelfTest_Stop(t *testing.T) {
+	pipeline := &fakePipeline{}
+	st := newSelfTest(t, pipeline, time.Minute)
+	stop := make(chan struct{})
+	close(stop)
+
+	result := st.Wait(stop)
+	if result.Passed || !strings.Contains(result.Reason, "stopped") {
+		t.Fatalf("expected the self-test to stop, got %s", result)
+	}
+	if pipeline.hasTarget() {
+		t.Error("expected the self-test target to be removed")
+	}
+}
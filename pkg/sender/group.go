@@ -0,0 +1,60 @@
+package sender
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"open-agent/pkg/model"
+)
+
+// GroupStatsInterval is how often grouping is measured against the ungrouped scrape order
+const GroupStatsInterval = time.Minute
+
+// groupMetrics returns a copy of metrics stably sorted by metric name, then label signature.
+// Records of the same metric end up next to each other, which compresses better in the pack.
+func groupMetrics(metrics []*model.OpenMx) []*model.OpenMx {
+	type keyed struct {
+		om        *model.OpenMx
+		signature string
+	}
+	items := make([]keyed, len(metrics))
+	for i, om := range metrics {
+		items[i] = keyed{om: om, signature: labelSignature(om.Labels)}
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].om.Metric != items[j].om.Metric {
+			return items[i].om.Metric < items[j].om.Metric
+		}
+		return items[i].signature < items[j].signature
+	})
+
+	grouped := make([]*model.OpenMx, len(items))
+	for i, item := range items {
+		grouped[i] = item.om
+	}
+	return grouped
+}
+
+// labelSignature returns the labels as a sorted key=value string
+func labelSignature(labels []model.Label) string {
+	pairs := make([]string, len(labels))
+	for i, l := range labels {
+		pairs[i] = l.Key + "=" + l.Value
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// packedSize returns the total encoded size of metrics split into ChunkSize packs
+func packedSize(metrics []*model.OpenMx) int {
+	size := 0
+	for i := 0; i < len(metrics); i += ChunkSize {
+		end := i + ChunkSize
+		if end > len(metrics) {
+			end = len(metrics)
+		}
+		size += model.NewOpenMxPack().SetRecords(metrics[i:end]).Size()
+	}
+	return size
+}
@@ -0,0 +1,92 @@
+package sender
+
+import (
+	"fmt"
+	"testing"
+
+	"open-agent/pkg/model"
+)
+
+// kubeStateMetricsSample builds kube-state-metrics style pod series in the interleaved order
+// they arrive in when several pods' series are merged, one pod after another
+func kubeStateMetricsSample(pods int) []*model.OpenMx {
+	phases := []string{"Pending", "Running", "Succeeded", "Failed", "Unknown"}
+	var metrics []*model.OpenMx
+	for i := 0; i < pods; i++ {
+		namespace := fmt.Sprintf("team-%d", i%12)
+		pod := fmt.Sprintf("api-server-7d9f8b6c5-%05d", i)
+		base := []model.Label{
+			{Key: "namespace", Value: namespace},
+			{Key: "pod", Value: pod},
+			{Key: "uid", Value: fmt.Sprintf("3f1c2a4e-%04d-4b7a-9c1d-0242ac120002", i)},
+			{Key: "job", Value: "kube-state-metrics"},
+			{Key: "instance", Value: "10.0.3.17:8080"},
+		}
+		add := func(metric string, value float64, extra ...model.Label) {
+			om := model.NewOpenMx(metric, 1700000000000, value)
+			om.Labels = append(append([]model.Label{}, base...), extra...)
+			metrics = append(metrics, om)
+		}
+
+		add("kube_pod_info", 1,
+			model.Label{Key: "host_ip", Value: fmt.Sprintf("10.0.1.%d", i%250)},
+			model.Label{Key: "node", Value: fmt.Sprintf("worker-%02d", i%20)},
+			model.Label{Key: "created_by_kind", Value: "ReplicaSet"})
+		for _, phase := range phases {
+			value := 0.0
+			if phase == "Running" {
+				value = 1
+			}
+			add("kube_pod_status_phase", value, model.Label{Key: "phase", Value: phase})
+		}
+		add("kube_pod_status_ready", 1, model.Label{Key: "condition", Value: "true"})
+		add("kube_pod_container_status_restarts_total", float64(i%3), model.Label{Key: "container", Value: "api"})
+		add("kube_pod_container_resource_requests", 0.25,
+			model.Label{Key: "container", Value: "api"}, model.Label{Key: "resource", Value: "cpu"}, model.Label{Key: "unit", Value: "core"})
+		add("kube_pod_container_resource_limits", 536870912,
+			model.Label{Key: "container", Value: "api"}, model.Label{Key: "resource", Value: "memory"}, model.Label{Key: "unit", Value: "byte"})
+	}
+	return metrics
+}
+
+func TestGroupMetrics_SortsByNameThenLabels(t *testing.T) {
+	a := model.NewOpenMx("b_metric", 0, 1)
+	a.AddLabel("pod", "y")
+	b := model.NewOpenMx("a_metric", 0, 2)
+	c := model.NewOpenMx("b_metric", 0, 3)
+	c.AddLabel("pod", "x")
+
+	grouped := groupMetrics([]*model.OpenMx{a, b, c})
+	if grouped[0] != b || grouped[1] != c || grouped[2] != a {
+		t.Fatalf("unexpected order: %s %v, %s %v, %s %v", grouped[0].Metric, grouped[0].Labels,
+			grouped[1].Metric, grouped[1].Labels, grouped[2].Metric, grouped[2].Labels)
+	}
+}
+
+func TestGroupMetrics_ReducesPackSize(t *testing.T) {
+	metrics := kubeStateMetricsSample(500)
+	before, after := packedSize(metrics), packedSize(groupMetrics(metrics))
+	if after >= before {
+		t.Fatalf("expected grouping to reduce pack size, got %d -> %d bytes", before, after)
+	}
+	t.Logf("%d records: %d -> %d bytes", len(metrics), before, after)
+}
+
+func BenchmarkPackSize(b *testing.B) {
+	metrics := kubeStateMetricsSample(2000)
+
+	b.Run("scrape-order", func(b *testing.B) {
+		var size int
+		for i := 0; i < b.N; i++ {
+			size = packedSize(metrics)
+		}
+		b.ReportMetric(float64(size), "bytes/pack-set")
+	})
+	b.Run("grouped", func(b *testing.B) {
+		var size int
+		for i := 0; i < b.N; i++ {
+			size = packedSize(groupMetrics(metrics))
+		}
+		b.ReportMetric(float64(size), "bytes/pack-set")
+	})
+}
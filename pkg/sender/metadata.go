@@ -0,0 +1,83 @@
+package sender
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/whatap/golib/lang/pack"
+
+	"open-agent/pkg/config"
+	"open-agent/pkg/model"
+)
+
+// DefaultMetadataInterval is how often HELP/TYPE metadata of a target is sent
+const DefaultMetadataInterval = 60 * time.Second
+
+// MetadataInterval returns the minimum time between two metadata sends of the same target
+func MetadataInterval() time.Duration {
+	interval := time.Duration(config.GetIntWithDefault("openagent_metadata_interval_ms", int(DefaultMetadataInterval/time.Millisecond))) * time.Millisecond
+	if interval <= 0 {
+		return DefaultMetadataInterval
+	}
+	return interval
+}
+
+// metadataDue reports whether the target's metadata should be sent now, and records the send if so.
+// The processor already drops the metadata of scrapes when it is disabled; this also covers self-metrics.
+func (s *Sender) metadataDue(target string, now time.Time) bool {
+	if !config.SendMetricMetadata() {
+		return false
+	}
+
+	interval := MetadataInterval()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneMetadataTimes(now, interval)
+	if last, ok := s.lastMetadataTime[target]; ok && now.Sub(last) < interval {
+		return false
+	}
+	s.lastMetadataTime[target] = now
+	return true
+}
+
+// pruneMetadataTimes drops, once per interval, the send times older than the interval. Such a target's
+// metadata is due anyway, so this only forgets targets that are gone. Must be called with s.mu held.
+func (s *Sender) pruneMetadataTimes(now time.Time, interval time.Duration) {
+	if now.Sub(s.lastMetadataPrune) < interval {
+		return
+	}
+	s.lastMetadataPrune = now
+	for target, last := range s.lastMetadataTime {
+		if now.Sub(last) >= interval {
+			delete(s.lastMetadataTime, target)
+		}
+	}
+}
+
+// Packs sent successfully, by pack type
+var (
+	metricPacksSent int64
+	helpPacksSent   int64
+)
+
+// RecordPackSent counts a successfully sent pack by type
+func RecordPackSent(p pack.Pack) {
+	switch p.GetPackType() {
+	case model.OPEN_MX_PACK:
+		atomic.AddInt64(&metricPacksSent, 1)
+	case model.OPEN_MX_HELP_PACK:
+		atomic.AddInt64(&helpPacksSent, 1)
+	}
+}
+
+// PacksSent returns the number of OpenMxPacks and OpenMxHelpPacks sent since startup
+func PacksSent() (metrics, help int64) {
+	return atomic.LoadInt64(&metricPacksSent), atomic.LoadInt64(&helpPacksSent)
+}
+
+// SendTotals returns the OpenMxPacks and OpenMxHelpPacks sent since startup, and the packs the
+// sender gave up on
+func (s *Sender) SendTotals() (packs, failures int64) {
+	metrics, help := PacksSent()
+	return metrics + help, s.failedPacks.Load()
+}
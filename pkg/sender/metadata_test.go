@@ -0,0 +1,102 @@
+package sender
+
+import (
+	"testing"
+	"time"
+
+	"github.com/whatap/golib/lang/pack"
+
+	"open-agent/pkg/model"
+)
+
+func newMetadataResult(target string) *model.ConversionResult {
+	result := model.NewConversionResult(
+		[]*model.OpenMx{model.NewOpenMx("http_requests_total", 0, 1)},
+		[]*model.OpenMxHelp{model.NewOpenMxHelp("http_requests_total")},
+	)
+	result.SetTarget(target)
+	return result
+}
+
+// queuedPackTypes drains the in-flight buffer and counts packs by type
+func queuedPackTypes(s *Sender) map[int16]int {
+	counts := make(map[int16]int)
+	for {
+		select {
+		case p := <-s.packCh:
+			counts[p.GetPackType()]++
+		default:
+			return counts
+		}
+	}
+}
+
+func TestSendResult_MetadataDisabled(t *testing.T) {
+	t.Setenv("openagent_send_metric_metadata", "false")
+	s := newTestSender(func(p pack.Pack) error { return nil })
+
+	s.sendResult(newMetadataResult("http://10.0.0.1:8080/metrics"))
+	s.sendResult(newMetadataResult("http://10.0.0.2:8080/metrics"))
+
+	counts := queuedPackTypes(s)
+	if counts[model.OPEN_MX_HELP_PACK] != 0 {
+		t.Fatalf("expected no help packs when metadata is disabled, got %d", counts[model.OPEN_MX_HELP_PACK])
+	}
+	if counts[model.OPEN_MX_PACK] != 2 {
+		t.Fatalf("expected metrics to be sent, got %d metric packs", counts[model.OPEN_MX_PACK])
+	}
+}
+
+func TestSendResult_MetadataInterval(t *testing.T) {
+	t.Setenv("openagent_metadata_interval_ms", "3600000")
+	s := newTestSender(func(p pack.Pack) error { return nil })
+
+	s.sendResult(newMetadataResult("http://10.0.0.1:8080/metrics"))
+	s.sendResult(newMetadataResult("http://10.0.0.1:8080/metrics"))
+	s.sendResult(newMetadataResult("http://10.0.0.2:8080/metrics"))
+
+	// Once per target within the interval
+	if counts := queuedPackTypes(s); counts[model.OPEN_MX_HELP_PACK] != 2 || counts[model.OPEN_MX_PACK] != 3 {
+		t.Fatalf("expected 2 help packs and 3 metric packs, got %v", counts)
+	}
+
+	// Reloading with metadata disabled takes effect without a new sender
+	t.Setenv("openagent_send_metric_metadata", "false")
+	t.Setenv("openagent_metadata_interval_ms", "1")
+	s.sendResult(newMetadataResult("http://10.0.0.3:8080/metrics"))
+	if counts := queuedPackTypes(s); counts[model.OPEN_MX_HELP_PACK] != 0 {
+		t.Fatalf("expected no help packs after disabling metadata, got %v", counts)
+	}
+}
+
+func TestMetadataDue_PrunesGoneTargets(t *testing.T) {
+	t.Setenv("openagent_metadata_interval_ms", "60000")
+	s := newTestSender(func(p pack.Pack) error { return nil })
+	now := time.Unix(1700000000, 0)
+
+	s.metadataDue("http://10.0.0.1:8080/metrics", now)
+	s.metadataDue("http://10.0.0.2:8080/metrics", now)
+	// Only the first target is still scraped a minute later
+	later := now.Add(time.Minute)
+	if !s.metadataDue("http://10.0.0.1:8080/metrics", later) {
+		t.Fatalf("expected the metadata to be due again after the interval")
+	}
+
+	if _, ok := s.lastMetadataTime["http://10.0.0.2:8080/metrics"]; ok || len(s.lastMetadataTime) != 1 {
+		t.Fatalf("expected the gone target to be pruned, got %v", s.lastMetadataTime)
+	}
+}
+
+func TestRecordPackSent_ByType(t *testing.T) {
+	metricsBefore, helpBefore := PacksSent()
+	s := newTestSender(func(p pack.Pack) error { return nil })
+
+	s.sendToServerWithRetry(model.NewOpenMxPack())
+	s.sendToServerWithRetry(model.NewOpenMxPack())
+	s.sendToServerWithRetry(model.NewOpenMxHelpPack())
+
+	metrics, help := PacksSent()
+	if metrics-metricsBefore != 2 || help-helpBefore != 1 {
+		t.Fatalf("expected 2 metric and 1 help pack, got %d and %d", metrics-metricsBefore, help-helpBefore)
+	}
+}
@@ -0,0 +1,229 @@
+package sender
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/whatap/golib/logger/logfile"
+	collectorpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"open-agent/pkg/config"
+	"open-agent/pkg/model"
+)
+
+// Defaults of the OTLP sink (whatap.conf openagent_otlp_*)
+const (
+	DefaultOTLPTimeout    = 10 * time.Second
+	DefaultOTLPMaxRetries = 3
+	DefaultOTLPRetryDelay = time.Second
+
+	// otlpQueueSize is the number of results waiting for export; more are dropped so OTLP never
+	// holds up sending to the WhaTap server
+	otlpQueueSize = 100
+)
+
+// otlpSettings configure the OTLP/gRPC export
+type otlpSettings struct {
+	endpoint           string // host:port of the collector's OTLP/gRPC receiver
+	insecure           bool   // plaintext instead of TLS
+	caFile             string
+	insecureSkipVerify bool
+	headers            map[string]string
+	timeout            time.Duration
+	maxRetries         int
+	retryDelay         time.Duration
+}
+
+// loadOTLPSettings reads the OTLP sink settings, and returns false when the sink is disabled
+func loadOTLPSettings() (otlpSettings, bool) {
+	if !config.GetBoolWithDefault("openagent_otlp_enabled", false) {
+		return otlpSettings{}, false
+	}
+	s := otlpSettings{
+		endpoint:           config.GetWithDefault("openagent_otlp_endpoint", "localhost:4317"),
+		insecure:           config.GetBoolWithDefault("openagent_otlp_insecure", false),
+		caFile:             config.Get("openagent_otlp_ca_file"),
+		insecureSkipVerify: config.GetBoolWithDefault("openagent_otlp_insecure_skip_verify", false),
+		headers:            parseOTLPHeaders(config.Get("openagent_otlp_headers")),
+		timeout:            time.Duration(config.GetIntWithDefault("openagent_otlp_timeout_ms", int(DefaultOTLPTimeout/time.Millisecond))) * time.Millisecond,
+		maxRetries:         config.GetIntWithDefault("openagent_otlp_max_retries", DefaultOTLPMaxRetries),
+		retryDelay:         time.Duration(config.GetIntWithDefault("openagent_otlp_retry_delay_ms", int(DefaultOTLPRetryDelay/time.Millisecond))) * time.Millisecond,
+	}
+	if s.timeout <= 0 {
+		s.timeout = DefaultOTLPTimeout
+	}
+	if s.maxRetries < 0 {
+		s.maxRetries = DefaultOTLPMaxRetries
+	}
+	if s.retryDelay < 0 {
+		s.retryDelay = DefaultOTLPRetryDelay
+	}
+	return s, true
+}
+
+// parseOTLPHeaders parses "key=value,key2=value2" request headers
+func parseOTLPHeaders(value string) map[string]string {
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		key, val, ok := strings.Cut(pair, "=")
+		if key = strings.TrimSpace(key); ok && key != "" {
+			headers[strings.ToLower(key)] = strings.TrimSpace(val)
+		}
+	}
+	return headers
+}
+
+// transportCredentials returns plaintext or TLS credentials for the collector connection
+func (s otlpSettings) transportCredentials() (credentials.TransportCredentials, error) {
+	if s.insecure {
+		return insecure.NewCredentials(), nil
+	}
+	tlsConfig := &tls.Config{InsecureSkipVerify: s.insecureSkipVerify}
+	if s.caFile != "" {
+		pem, err := os.ReadFile(s.caFile)
+		if err != nil {
+			return nil, fmt.Errorf("read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in CA file %s", s.caFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return credentials.NewTLS(tlsConfig), nil
+}
+
+// otlpSink forwards results to an OpenTelemetry collector over OTLP/gRPC in addition to the WhaTap server
+type otlpSink struct {
+	settings otlpSettings
+	conn     *grpc.ClientConn
+	client   collectorpb.MetricsServiceClient
+	queue    chan *model.ConversionResult
+	types    *metricTypes
+	logger   *logfile.FileLogger
+
+	dropped      atomic.Int64
+	lastDropWarn time.Time
+	exported     atomic.Int64
+	failed       atomic.Int64
+}
+
+// newOTLPSink connects lazily to the collector; nothing is sent until run is started
+func newOTLPSink(settings otlpSettings, logger *logfile.FileLogger) (*otlpSink, error) {
+	creds, err := settings.transportCredentials()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := grpc.NewClient(settings.endpoint, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, err
+	}
+	return &otlpSink{
+		settings: settings,
+		conn:     conn,
+		client:   collectorpb.NewMetricsServiceClient(conn),
+		queue:    make(chan *model.ConversionResult, otlpQueueSize),
+		types:    newMetricTypes(),
+		logger:   logger,
+	}, nil
+}
+
+// offer queues a result for export without blocking, dropping it when the export falls behind
+func (o *otlpSink) offer(result *model.ConversionResult) {
+	select {
+	case o.queue <- result:
+	default:
+		o.dropped.Add(1)
+		if time.Since(o.lastDropWarn) >= time.Minute {
+			o.lastDropWarn = time.Now()
+			o.logger.Println("SenderOTLP", fmt.Sprintf("Export queue is full, %d results dropped so far", o.dropped.Load()))
+		}
+	}
+}
+
+// run exports queued results until shutdown
+func (o *otlpSink) run(shutdownCh <-chan struct{}, wg *sync.WaitGroup) {
+	defer wg.Done()
+	defer o.conn.Close()
+	for {
+		select {
+		case <-shutdownCh:
+			return
+		case result := <-o.queue:
+			o.types.observe(result.GetOpenMxHelpList())
+			resourceMetrics := o.types.toOTLP(result)
+			if resourceMetrics == nil {
+				continue
+			}
+			if err := o.export(shutdownCh, resourceMetrics); err != nil {
+				o.failed.Add(1)
+				o.logger.Println("SenderOTLP", fmt.Sprintf("Failed to export %d series of %s to %s: %v",
+					len(result.GetOpenMxList()), result.GetTarget(), o.settings.endpoint, err))
+				continue
+			}
+			o.exported.Add(1)
+		}
+	}
+}
+
+// export sends one request, retrying errors the collector reports as transient
+func (o *otlpSink) export(shutdownCh <-chan struct{}, resourceMetrics *metricspb.ResourceMetrics) error {
+	request := &collectorpb.ExportMetricsServiceRequest{ResourceMetrics: []*metricspb.ResourceMetrics{resourceMetrics}}
+	var err error
+	for attempt := 0; attempt <= o.settings.maxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(o.settings.retryDelay):
+			case <-shutdownCh:
+				return err
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), o.settings.timeout)
+		if len(o.settings.headers) > 0 {
+			ctx = metadata.NewOutgoingContext(ctx, metadata.New(o.settings.headers))
+		}
+		var response *collectorpb.ExportMetricsServiceResponse
+		response, err = o.client.Export(ctx, request)
+		cancel()
+		if err == nil {
+			if partial := response.GetPartialSuccess(); partial.GetRejectedDataPoints() > 0 {
+				o.logger.Println("SenderOTLP", fmt.Sprintf("Collector rejected %d data points: %s",
+					partial.GetRejectedDataPoints(), partial.GetErrorMessage()))
+			}
+			return nil
+		}
+		if !retryableOTLPError(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// retryableOTLPError reports whether an export error is transient, per the OTLP/gRPC specification
+func retryableOTLPError(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	switch status.Code(err) {
+	case codes.Canceled, codes.DeadlineExceeded, codes.Aborted, codes.OutOfRange,
+		codes.Unavailable, codes.DataLoss, codes.ResourceExhausted:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+package sender
+
+import (
+	"strings"
+
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+
+	"open-agent/pkg/model"
+)
+
+// maxCachedMetricTypes bounds the TYPE metadata cache; it is cleared when full and refills from the next scrapes
+const maxCachedMetricTypes = 100000
+
+// otlpServiceName is the service.name resource attribute of exported metrics
+const otlpServiceName = "whatap-open-agent"
+
+// metricTypes caches the exposition TYPE of metric families from the HELP/TYPE metadata of each result,
+// so series from results without metadata still keep the counter/gauge distinction.
+// Only used from the OTLP sink goroutine.
+type metricTypes struct {
+	types map[string]string
+}
+
+func newMetricTypes() *metricTypes {
+	return &metricTypes{types: make(map[string]string)}
+}
+
+// observe records the TYPE of every family in a metadata list
+func (m *metricTypes) observe(helpList []*model.OpenMxHelp) {
+	for _, help := range helpList {
+		typ := help.Get("type")
+		if typ == "" {
+			continue
+		}
+		if _, ok := m.types[help.Metric]; !ok && len(m.types) >= maxCachedMetricTypes {
+			m.types = make(map[string]string)
+		}
+		m.types[help.Metric] = typ
+	}
+}
+
+// cumulative reports whether a series is a monotonic cumulative sum: a counter, or the bucket, sum
+// and count series a histogram or summary family is expanded into. Summary quantiles, gauges and
+// series of unknown type are not.
+func (m *metricTypes) cumulative(name string) bool {
+	if m.types[name] == "counter" {
+		return true
+	}
+	for _, suffix := range []string{"_total", "_bucket", "_sum", "_count"} {
+		family, ok := strings.CutSuffix(name, suffix)
+		if !ok {
+			continue
+		}
+		switch m.types[family] {
+		case "counter":
+			return suffix == "_total"
+		case "histogram", "gaugehistogram":
+			return suffix != "_total"
+		case "summary":
+			return suffix == "_sum" || suffix == "_count"
+		}
+	}
+	return false
+}
+
+// toOTLP converts the series of a result into an OTLP export: one metric per series name, labels as
+// attributes, counters as cumulative monotonic sums and everything else as gauges
+func (m *metricTypes) toOTLP(result *model.ConversionResult) *metricspb.ResourceMetrics {
+	var metrics []*metricspb.Metric
+	byName := make(map[string]*metricspb.Metric)
+	for _, om := range result.GetOpenMxList() {
+		point := &metricspb.NumberDataPoint{
+			Attributes:   otlpAttributes(om.Labels),
+			TimeUnixNano: uint64(om.Timestamp) * 1e6,
+			Value:        &metricspb.NumberDataPoint_AsDouble{AsDouble: om.Value},
+		}
+
+		metric, ok := byName[om.Metric]
+		if !ok {
+			metric = &metricspb.Metric{Name: om.Metric}
+			if m.cumulative(om.Metric) {
+				metric.Data = &metricspb.Metric_Sum{Sum: &metricspb.Sum{
+					AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+					IsMonotonic:            true,
+				}}
+			} else {
+				metric.Data = &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{}}
+			}
+			byName[om.Metric] = metric
+			metrics = append(metrics, metric)
+		}
+		switch data := metric.Data.(type) {
+		case *metricspb.Metric_Sum:
+			data.Sum.DataPoints = append(data.Sum.DataPoints, point)
+		case *metricspb.Metric_Gauge:
+			data.Gauge.DataPoints = append(data.Gauge.DataPoints, point)
+		}
+	}
+	if len(metrics) == 0 {
+		return nil
+	}
+
+	return &metricspb.ResourceMetrics{
+		Resource: &resourcepb.Resource{Attributes: []*commonpb.KeyValue{stringAttribute("service.name", otlpServiceName)}},
+		ScopeMetrics: []*metricspb.ScopeMetrics{{
+			Scope:   &commonpb.InstrumentationScope{Name: otlpServiceName},
+			Metrics: metrics,
+		}},
+	}
+}
+
+func otlpAttributes(labels []model.Label) []*commonpb.KeyValue {
+	attributes := make([]*commonpb.KeyValue, len(labels))
+	for i, label := range labels {
+		attributes[i] = stringAttribute(label.Key, label.Value)
+	}
+	return attributes
+}
+
+func stringAttribute(key, value string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: key, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}}}
+}
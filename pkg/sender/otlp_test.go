@@ -0,0 +1,250 @@
+package sender
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/whatap/golib/lang/pack"
+	collectorpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"open-agent/pkg/model"
+)
+
+// otlpReceiver is an in-process OTLP/gRPC collector that records export requests
+type otlpReceiver struct {
+	collectorpb.UnimplementedMetricsServiceServer
+
+	mu        sync.Mutex
+	requests  []*collectorpb.ExportMetricsServiceRequest
+	headers   []metadata.MD
+	failFirst int // number of calls answered with Unavailable before accepting
+	received  chan struct{}
+}
+
+func (r *otlpReceiver) Export(ctx context.Context, req *collectorpb.ExportMetricsServiceRequest) (*collectorpb.ExportMetricsServiceResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	md, _ := metadata.FromIncomingContext(ctx)
+	r.headers = append(r.headers, md)
+	if r.failFirst > 0 {
+		r.failFirst--
+		return nil, status.Error(codes.Unavailable, "collector starting")
+	}
+	r.requests = append(r.requests, req)
+	r.received <- struct{}{}
+	return &collectorpb.ExportMetricsServiceResponse{}, nil
+}
+
+// startOTLPReceiver serves the receiver on a localhost port and points the sink settings at it
+func startOTLPReceiver(t *testing.T, failFirst int) *otlpReceiver {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	receiver := &otlpReceiver{failFirst: failFirst, received: make(chan struct{}, 10)}
+	server := grpc.NewServer()
+	collectorpb.RegisterMetricsServiceServer(server, receiver)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	t.Setenv("openagent_otlp_enabled", "true")
+	t.Setenv("openagent_otlp_endpoint", listener.Addr().String())
+	t.Setenv("openagent_otlp_insecure", "true")
+	t.Setenv("openagent_otlp_headers", "Authorization=Bearer secret, x-scope=team-a")
+	t.Setenv("openagent_otlp_retry_delay_ms", "10")
+	return receiver
+}
+
+// startOTLPSender returns a sender with the OTLP sink running and the WhaTap send stubbed out
+func startOTLPSender(t *testing.T) *Sender {
+	t.Helper()
+	s := newTestSender(func(p pack.Pack) error { return nil })
+	if s.otlp == nil {
+		t.Fatal("expected the OTLP sink to be enabled")
+	}
+	s.wg.Add(1)
+	go s.otlp.run(s.shutdownCh, &s.wg)
+	t.Cleanup(func() {
+		close(s.shutdownCh)
+		s.wg.Wait()
+	})
+	return s
+}
+
+func waitExport(t *testing.T, receiver *otlpReceiver) *collectorpb.ExportMetricsServiceRequest {
+	t.Helper()
+	select {
+	case <-receiver.received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for an OTLP export")
+	}
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+	return receiver.requests[len(receiver.requests)-1]
+}
+
+func helpWithType(metric, typ string) *model.OpenMxHelp {
+	help := model.NewOpenMxHelp(metric)
+	help.Put("type", typ)
+	return help
+}
+
+func exportedMetrics(req *collectorpb.ExportMetricsServiceRequest) map[string]*metricspb.Metric {
+	metrics := make(map[string]*metricspb.Metric)
+	for _, rm := range req.GetResourceMetrics() {
+		for _, sm := range rm.GetScopeMetrics() {
+			for _, m := range sm.GetMetrics() {
+				metrics[m.GetName()] = m
+			}
+		}
+	}
+	return metrics
+}
+
+func TestOTLPSink_ExportsSeriesWithTypesAndLabels(t *testing.T) {
+	receiver := startOTLPReceiver(t, 0)
+	s := startOTLPSender(t)
+
+	const ts = int64(1700000000123)
+	requests := model.NewOpenMx("http_requests_total", ts, 42)
+	requests.AddLabel("method", "GET")
+	requests.AddLabel("code", "200")
+	temperature := model.NewOpenMx("room_temperature", ts, 21.5)
+	bucket := model.NewOpenMx("latency_seconds_bucket", ts, 7)
+	bucket.AddLabel("le", "0.5")
+	quantile := model.NewOpenMx("rpc_seconds", ts, 0.2)
+	quantile.AddLabel("quantile", "0.9")
+	summaryCount := model.NewOpenMx("rpc_seconds_count", ts, 12)
+
+	result := model.NewConversionResult(
+		[]*model.OpenMx{requests, temperature, bucket, quantile, summaryCount},
+		[]*model.OpenMxHelp{
+			helpWithType("http_requests", "counter"),
+			helpWithType("room_temperature", "gauge"),
+			helpWithType("latency_seconds", "histogram"),
+			helpWithType("rpc_seconds", "summary"),
+		},
+	)
+	result.SetTarget("http://10.0.0.1:8080/metrics")
+	s.sendResult(result)
+
+	req := waitExport(t, receiver)
+	if got := req.GetResourceMetrics()[0].GetResource().GetAttributes()[0].GetValue().GetStringValue(); got != otlpServiceName {
+		t.Errorf("service.name = %q, want %q", got, otlpServiceName)
+	}
+	metrics := exportedMetrics(req)
+
+	counter := metrics["http_requests_total"]
+	if counter.GetSum() == nil || !counter.GetSum().GetIsMonotonic() ||
+		counter.GetSum().GetAggregationTemporality() != metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE {
+		t.Fatalf("expected http_requests_total as a cumulative monotonic sum, got %v", counter)
+	}
+	point := counter.GetSum().GetDataPoints()[0]
+	if point.GetAsDouble() != 42 || point.GetTimeUnixNano() != uint64(ts)*1e6 {
+		t.Errorf("unexpected counter point %v", point)
+	}
+	attributes := make(map[string]string)
+	for _, kv := range point.GetAttributes() {
+		attributes[kv.GetKey()] = kv.GetValue().GetStringValue()
+	}
+	if len(attributes) != 2 || attributes["method"] != "GET" || attributes["code"] != "200" {
+		t.Errorf("unexpected attributes %v", attributes)
+	}
+
+	for _, name := range []string{"latency_seconds_bucket", "rpc_seconds_count"} {
+		if metrics[name].GetSum() == nil || !metrics[name].GetSum().GetIsMonotonic() {
+			t.Errorf("expected %s as a monotonic sum, got %v", name, metrics[name])
+		}
+	}
+	for _, name := range []string{"room_temperature", "rpc_seconds"} {
+		if metrics[name].GetGauge() == nil {
+			t.Errorf("expected %s as a gauge, got %v", name, metrics[name])
+		}
+	}
+	if got := metrics["room_temperature"].GetGauge().GetDataPoints()[0].GetAsDouble(); got != 21.5 {
+		t.Errorf("room_temperature = %v, want 21.5", got)
+	}
+
+	receiver.mu.Lock()
+	md := receiver.headers[0]
+	receiver.mu.Unlock()
+	if got := md.Get("authorization"); len(got) != 1 || got[0] != "Bearer secret" {
+		t.Errorf("authorization header = %v", got)
+	}
+	if got := md.Get("x-scope"); len(got) != 1 || got[0] != "team-a" {
+		t.Errorf("x-scope header = %v", got)
+	}
+
+	// WhaTap packs are still produced alongside the OTLP export
+	if counts := queuedPackTypes(s); counts[model.OPEN_MX_PACK] != 1 {
+		t.Errorf("expected the WhaTap metric pack to be sent too, got %v", counts)
+	}
+}
+
+func TestOTLPSink_RemembersTypesAcrossResults(t *testing.T) {
+	receiver := startOTLPReceiver(t, 0)
+	s := startOTLPSender(t)
+
+	first := model.NewConversionResult(
+		[]*model.OpenMx{model.NewOpenMx("jobs_total", 1000, 1)},
+		[]*model.OpenMxHelp{helpWithType("jobs", "counter")},
+	)
+	s.sendResult(first)
+	waitExport(t, receiver)
+
+	// Metadata is only sent once per interval, later results carry no TYPE
+	second := model.NewConversionResult([]*model.OpenMx{model.NewOpenMx("jobs_total", 2000, 2)}, nil)
+	s.sendResult(second)
+	metrics := exportedMetrics(waitExport(t, receiver))
+	if metrics["jobs_total"].GetSum() == nil {
+		t.Fatalf("expected jobs_total to stay a sum without metadata, got %v", metrics["jobs_total"])
+	}
+}
+
+func TestOTLPSink_RetriesUnavailable(t *testing.T) {
+	receiver := startOTLPReceiver(t, 2)
+	s := startOTLPSender(t)
+
+	s.sendResult(model.NewConversionResult([]*model.OpenMx{model.NewOpenMx("up", 1000, 1)}, nil))
+	waitExport(t, receiver)
+
+	receiver.mu.Lock()
+	calls := len(receiver.headers)
+	receiver.mu.Unlock()
+	if calls != 3 {
+		t.Fatalf("expected 2 retries before the export succeeded, got %d calls", calls)
+	}
+	// exported is counted once the response reaches the sink
+	deadline := time.Now().Add(time.Second)
+	for s.otlp.exported.Load() != 1 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if s.otlp.exported.Load() != 1 || s.otlp.failed.Load() != 0 {
+		t.Errorf("exported = %d, failed = %d", s.otlp.exported.Load(), s.otlp.failed.Load())
+	}
+}
+
+func TestOTLPSink_DisabledByDefault(t *testing.T) {
+	s := newTestSender(func(p pack.Pack) error { return nil })
+	if s.otlp != nil {
+		t.Fatal("expected no OTLP sink unless openagent_otlp_enabled is set")
+	}
+}
+
+func TestRetryableOTLPError(t *testing.T) {
+	if !retryableOTLPError(status.Error(codes.Unavailable, "")) || !retryableOTLPError(status.Error(codes.ResourceExhausted, "")) {
+		t.Error("expected Unavailable and ResourceExhausted to be retried")
+	}
+	if retryableOTLPError(status.Error(codes.InvalidArgument, "")) || retryableOTLPError(status.Error(codes.Unauthenticated, "")) {
+		t.Error("expected InvalidArgument and Unauthenticated not to be retried")
+	}
+}
@@ -0,0 +1,167 @@
+package sender
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"open-agent/pkg/model"
+)
+
+const (
+	// MetricPipelineLatency is the time from the start of a scrape until its pack was sent
+	MetricPipelineLatency = "openagent_pipeline_latency_seconds"
+
+	// PipelineLatencyInterval is how often the pipeline latency histogram is sent
+	PipelineLatencyInterval = time.Minute
+
+	// PipelineLatencySLO is the scrape-to-send latency the agent is expected to stay under
+	PipelineLatencySLO = 60 * time.Second
+
+	// StageLive is the stage of packs sent on the first attempt from a buffer without a backlog
+	StageLive = "live"
+	// StageReplayed is the stage of packs sent on a retry, or from the backlog left by a failed send
+	StageReplayed = "replayed"
+)
+
+// pipelineLatencyBuckets are the upper bounds in seconds of the histogram buckets, with the SLO as a bound
+var pipelineLatencyBuckets = []float64{1, 2.5, 5, 10, 15, 30, 60, 120, 300, 600}
+
+// pipelineLatencyStages are the stages always present in the histogram, so dashboards see 0 instead of a gap
+var pipelineLatencyStages = []string{StageLive, StageReplayed}
+
+// latencyHistogram is the cumulative latency histogram of one stage
+type latencyHistogram struct {
+	// buckets counts the observations per bucket, not cumulated; the last one is +Inf
+	buckets []int64
+	count   int64
+	sum     time.Duration
+	// max is the highest latency since the last export, reported as the synthetic gauge
+	max time.Duration
+}
+
+// pipelineLatency tracks the scrape-to-send latency of sent packs by stage
+type pipelineLatency struct {
+	mu     sync.Mutex
+	stages map[string]*latencyHistogram
+}
+
+func newPipelineLatency() *pipelineLatency {
+	l := &pipelineLatency{stages: make(map[string]*latencyHistogram, len(pipelineLatencyStages))}
+	for _, stage := range pipelineLatencyStages {
+		l.stages[stage] = &latencyHistogram{buckets: make([]int64, len(pipelineLatencyBuckets)+1)}
+	}
+	return l
+}
+
+// observe records the latency of a pack sent in stage
+func (l *pipelineLatency) observe(stage string, latency time.Duration) {
+	if latency < 0 {
+		latency = 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	h := l.stages[stage]
+	i := 0
+	for i < len(pipelineLatencyBuckets) && latency.Seconds() > pipelineLatencyBuckets[i] {
+		i++
+	}
+	h.buckets[i]++
+	h.count++
+	h.sum += latency
+	if latency > h.max {
+		h.max = latency
+	}
+}
+
+// totals returns the packs observed in stage since startup, their summed latency, and how many were over the SLO
+func (l *pipelineLatency) totals(stage string) (count int64, sum time.Duration, overSLO int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	h := l.stages[stage]
+	var withinSLO int64
+	for i, bound := range pipelineLatencyBuckets {
+		if bound <= PipelineLatencySLO.Seconds() {
+			withinSLO += h.buckets[i]
+		}
+	}
+	return h.count, h.sum, h.count - withinSLO
+}
+
+// metrics returns the histogram as self-metric series: cumulative _bucket, _sum and _count series per
+// stage, and the synthetic openagent_pipeline_latency_seconds gauge with the highest latency of each stage
+// since the previous call, which is omitted for a stage without sends meanwhile
+func (l *pipelineLatency) metrics(now int64) *model.ConversionResult {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var series []*model.OpenMx
+	for _, stage := range pipelineLatencyStages {
+		h := l.stages[stage]
+		var cumulative int64
+		for i, n := range h.buckets {
+			cumulative += n
+			le := "+Inf"
+			if i < len(pipelineLatencyBuckets) {
+				le = strconv.FormatFloat(pipelineLatencyBuckets[i], 'g', -1, 64)
+			}
+			om := model.NewOpenMx(MetricPipelineLatency+"_bucket", now, float64(cumulative))
+			om.AddLabel("stage", stage)
+			om.AddLabel("le", le)
+			series = append(series, om)
+		}
+		for _, s := range []struct {
+			name  string
+			value float64
+		}{
+			{MetricPipelineLatency + "_sum", h.sum.Seconds()},
+			{MetricPipelineLatency + "_count", float64(h.count)},
+		} {
+			om := model.NewOpenMx(s.name, now, s.value)
+			om.AddLabel("stage", stage)
+			series = append(series, om)
+		}
+		if h.max > 0 {
+			om := model.NewOpenMx(MetricPipelineLatency, now, h.max.Seconds())
+			om.AddLabel("stage", stage)
+			series = append(series, om)
+			h.max = 0
+		}
+	}
+
+	var helpList []*model.OpenMxHelp
+	for _, h := range []struct{ name, help, kind string }{
+		{MetricPipelineLatency + "_bucket", "Packs sent by scrape-to-send latency bucket", "counter"},
+		{MetricPipelineLatency + "_sum", "Summed scrape-to-send latency of the sent packs", "counter"},
+		{MetricPipelineLatency + "_count", "Packs whose scrape-to-send latency was measured", "counter"},
+		{MetricPipelineLatency, "Highest scrape-to-send latency since the previous report", "gauge"},
+	} {
+		help := model.NewOpenMxHelp(h.name)
+		help.Put("help", h.help)
+		help.Put("type", h.kind)
+		helpList = append(helpList, help)
+	}
+
+	result := model.NewConversionResult(series, helpList)
+	result.SetCollectionTime(now)
+	return result
+}
+
+// PipelineLatencyTotals returns the packs sent in stage since startup with a known scrape start, their
+// summed scrape-to-send latency and how many took longer than PipelineLatencySLO
+func (s *Sender) PipelineLatencyTotals(stage string) (count int64, sum time.Duration, overSLO int64) {
+	return s.latency.totals(stage)
+}
+
+// recordLatency records the scrape-to-send latency of a pack sent now. Packs without a scrape start,
+// such as self-metrics and metadata, are not measured.
+func (s *Sender) recordLatency(queued queuedPack, replayed bool) {
+	if queued.scrapeStart == 0 {
+		return
+	}
+	stage := StageLive
+	if replayed {
+		stage = StageReplayed
+	}
+	s.latency.observe(stage, s.now().Sub(time.UnixMilli(queued.scrapeStart)))
+}
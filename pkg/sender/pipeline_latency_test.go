@@ -0,0 +1,132 @@
+package sender
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/whatap/golib/lang/pack"
+
+	"open-agent/pkg/model"
+)
+
+// scrapeResult is a processed scrape of one series whose scrape started at scrapeStart
+func scrapeResult(target string, scrapeStart time.Time) *model.ConversionResult {
+	result := model.NewConversionResult([]*model.OpenMx{model.NewOpenMx("up", scrapeStart.UnixMilli(), 1)}, nil)
+	result.SetTarget(target)
+	result.SetCollectionTime(scrapeStart.UnixMilli())
+	result.SetScrapeStart(scrapeStart.UnixMilli())
+	return result
+}
+
+// sendNext takes the next pack from the in-flight buffer and sends it like the network loop
+func sendNext(t *testing.T, s *Sender) {
+	t.Helper()
+	select {
+	case queued := <-s.packCh:
+		s.sendQueued(queued)
+	default:
+		t.Fatal("expected a queued pack")
+	}
+}
+
+// seriesValue returns the value of the series with the name and labels, failing when it is missing
+func seriesValue(t *testing.T, result *model.ConversionResult, name string, labels map[string]string) float64 {
+	t.Helper()
+	for _, om := range result.GetOpenMxList() {
+		if om.Metric != name {
+			continue
+		}
+		matched := 0
+		for _, label := range om.Labels {
+			if labels[label.Key] == label.Value {
+				matched++
+			}
+		}
+		if matched == len(labels) {
+			return om.Value
+		}
+	}
+	t.Fatalf("series %s%v not found", name, labels)
+	return 0
+}
+
+func TestPipelineLatency_RecordsScrapeToSendByStage(t *testing.T) {
+	scrapeStart := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	now := scrapeStart
+	failures := 0
+	s := newTestSender(func(p pack.Pack) error {
+		if failures > 0 {
+			failures--
+			return errors.New("connection reset")
+		}
+		return nil
+	})
+	s.now = func() time.Time { return now }
+
+	// Live: sent 12s after the scrape started, on the first attempt
+	s.sendResult(scrapeResult("http://a:9100/metrics", scrapeStart))
+	now = scrapeStart.Add(12 * time.Second)
+	sendNext(t, s)
+
+	// Replayed: the first attempt fails and the retry goes out 75s after the scrape, over the SLO
+	failures = 1
+	s.sendResult(scrapeResult("http://b:9100/metrics", scrapeStart))
+	now = scrapeStart.Add(75 * time.Second)
+	sendNext(t, s)
+
+	// Replayed: buffered behind a pack the sender gave up on
+	s.draining.Store(true)
+	s.sendResult(scrapeResult("http://c:9100/metrics", scrapeStart))
+	now = scrapeStart.Add(3 * time.Second)
+	sendNext(t, s)
+
+	// Self-metrics carry no scrape start and are not measured
+	s.draining.Store(false)
+	s.sendResult(model.NewConversionResult([]*model.OpenMx{model.NewOpenMx("openagent_up", now.UnixMilli(), 1)}, nil))
+	for len(s.packCh) > 0 {
+		sendNext(t, s)
+	}
+
+	count, sum, overSLO := s.PipelineLatencyTotals(StageLive)
+	if count != 1 || sum != 12*time.Second || overSLO != 0 {
+		t.Errorf("live totals = %d, %v, %d; want 1, 12s, 0", count, sum, overSLO)
+	}
+	count, sum, overSLO = s.PipelineLatencyTotals(StageReplayed)
+	if count != 2 || sum != 78*time.Second || overSLO != 1 {
+		t.Errorf("replayed totals = %d, %v, %d; want 2, 78s, 1", count, sum, overSLO)
+	}
+
+	result := s.latency.metrics(now.UnixMilli())
+	for _, tt := range []struct {
+		name   string
+		labels map[string]string
+		want   float64
+	}{
+		{MetricPipelineLatency + "_bucket", map[string]string{"stage": StageLive, "le": "10"}, 0},
+		{MetricPipelineLatency + "_bucket", map[string]string{"stage": StageLive, "le": "15"}, 1},
+		{MetricPipelineLatency + "_bucket", map[string]string{"stage": StageReplayed, "le": "5"}, 1},
+		{MetricPipelineLatency + "_bucket", map[string]string{"stage": StageReplayed, "le": "60"}, 1},
+		{MetricPipelineLatency + "_bucket", map[string]string{"stage": StageReplayed, "le": "120"}, 2},
+		{MetricPipelineLatency + "_bucket", map[string]string{"stage": StageReplayed, "le": "+Inf"}, 2},
+		{MetricPipelineLatency + "_sum", map[string]string{"stage": StageReplayed}, 78},
+		{MetricPipelineLatency + "_count", map[string]string{"stage": StageLive}, 1},
+		{MetricPipelineLatency, map[string]string{"stage": StageLive}, 12},
+		{MetricPipelineLatency, map[string]string{"stage": StageReplayed}, 75},
+	} {
+		if got := seriesValue(t, result, tt.name, tt.labels); got != tt.want {
+			t.Errorf("%s%v = %v, want %v", tt.name, tt.labels, got, tt.want)
+		}
+	}
+
+	// The gauge reports the highest latency since the previous report, the histogram stays cumulative
+	result = s.latency.metrics(now.UnixMilli())
+	for _, om := range result.GetOpenMxList() {
+		if om.Metric == MetricPipelineLatency {
+			t.Errorf("expected no gauge without sends since the previous report, got %+v", om)
+		}
+	}
+	if got := seriesValue(t, result, MetricPipelineLatency+"_count", map[string]string{"stage": StageReplayed}); got != 2 {
+		t.Errorf("replayed count = %v after the report, want 2", got)
+	}
+}
@@ -0,0 +1,215 @@
+package sender
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/whatap/gointernal/net/secure"
+	"open-agent/pkg/config"
+	"open-agent/pkg/model"
+)
+
+const (
+	// MaxRetryDelay caps the backoff between two attempts
+	MaxRetryDelay = 30 * time.Second
+
+	// DeadLetterMetricsInterval is how often the dead-letter counters are sent
+	DeadLetterMetricsInterval = time.Minute
+
+	MetricDeadLetterPacks = "openagent_sender_dead_letter_packs_total"
+	MetricSendRetries     = "openagent_sender_send_retries_total"
+	MetricLastSendError   = "openagent_sender_last_send_error_timestamp_seconds"
+
+	// DeadLetterPermanent is the reason of packs given up on after a permanent failure, without retrying
+	DeadLetterPermanent = "permanent"
+	// DeadLetterRetriesExhausted is the reason of packs given up on after every attempt failed
+	DeadLetterRetriesExhausted = "retries_exhausted"
+)
+
+// Kinds of send errors, the error label of the last send error. The message itself is not a label
+// value, since every distinct message would be a new series.
+const (
+	SendErrorTimeout   = "timeout"
+	SendErrorRefused   = "refused"
+	SendErrorClosed    = "closed"
+	SendErrorQueueFull = "queue_full"
+	SendErrorPermanent = "permanent"
+	SendErrorOther     = "other"
+)
+
+// ErrPermanent marks send failures retrying cannot fix, such as a pack that cannot be encoded
+var ErrPermanent = errors.New("permanent send failure")
+
+// permanentError wraps a send error retrying cannot fix
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string        { return e.err.Error() }
+func (e permanentError) Unwrap() error        { return e.err }
+func (e permanentError) Is(target error) bool { return target == ErrPermanent }
+
+// Permanent marks err as a failure that is not retried
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err: err}
+}
+
+// IsPermanent reports whether a send error must not be retried. Everything else, such as timeouts,
+// a full send queue or an agent not registered yet, is transient.
+func IsPermanent(err error) bool {
+	return errors.Is(err, ErrPermanent)
+}
+
+// sendMaxAttempts returns the attempts per pack configured with openagent_send_max_attempts
+func sendMaxAttempts() int {
+	attempts := config.GetIntWithDefault("openagent_send_max_attempts", MaxRetries)
+	if attempts <= 0 {
+		return MaxRetries
+	}
+	return attempts
+}
+
+// sendRetryDelay returns the delay before the first retry configured with openagent_send_retry_delay_ms
+func sendRetryDelay() time.Duration {
+	delay := time.Duration(config.GetIntWithDefault("openagent_send_retry_delay_ms", int(RetryDelay/time.Millisecond))) * time.Millisecond
+	if delay < 0 {
+		return RetryDelay
+	}
+	return delay
+}
+
+// sendMaxRetryDelay returns the backoff cap configured with openagent_send_max_retry_delay_ms
+func sendMaxRetryDelay() time.Duration {
+	delay := time.Duration(config.GetIntWithDefault("openagent_send_max_retry_delay_ms", int(MaxRetryDelay/time.Millisecond))) * time.Millisecond
+	if delay <= 0 {
+		return MaxRetryDelay
+	}
+	return delay
+}
+
+// backoff returns the delay before retry n (1 for the first retry): the retry delay doubled for each
+// earlier retry, capped at the maximum delay
+func backoff(n int, delay, maxDelay time.Duration) time.Duration {
+	for i := 1; i < n && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxDelay {
+		return maxDelay
+	}
+	return delay
+}
+
+// Dead-letter accounting since startup, shared by every sender like the packs sent
+var (
+	deadLetterPermanent atomic.Int64
+	deadLetterExhausted atomic.Int64
+	sendRetries         atomic.Int64
+	lastSendError       atomic.Pointer[sendError]
+)
+
+// sendError is the last send error with its kind and the time it happened
+type sendError struct {
+	message string
+	kind    string
+	time    int64
+}
+
+// recordSendError keeps err as the last send error
+func recordSendError(err error, now time.Time) {
+	lastSendError.Store(&sendError{message: err.Error(), kind: sendErrorKind(err), time: now.UnixMilli()})
+}
+
+// sendErrorKind maps a send error to one of the SendError kinds
+func sendErrorKind(err error) string {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, secure.ErrSendQueueFull):
+		return SendErrorQueueFull
+	case IsPermanent(err):
+		return SendErrorPermanent
+	case errors.Is(err, ErrSendTimeout), errors.Is(err, context.DeadlineExceeded), errors.Is(err, os.ErrDeadlineExceeded),
+		errors.As(err, &netErr) && netErr.Timeout():
+		return SendErrorTimeout
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return SendErrorRefused
+	case errors.Is(err, errSenderStopped), errors.Is(err, net.ErrClosed), errors.Is(err, io.EOF),
+		errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE):
+		return SendErrorClosed
+	default:
+		return SendErrorOther
+	}
+}
+
+// recordDeadLetter counts a pack given up on
+func recordDeadLetter(err error) {
+	if IsPermanent(err) {
+		deadLetterPermanent.Add(1)
+	} else {
+		deadLetterExhausted.Add(1)
+	}
+}
+
+// DeadLetterPacks returns the packs given up on since startup, after a permanent failure or after every attempt failed
+func DeadLetterPacks() (permanent, exhausted int64) {
+	return deadLetterPermanent.Load(), deadLetterExhausted.Load()
+}
+
+// SendRetries returns the retried send attempts since startup
+func SendRetries() int64 {
+	return sendRetries.Load()
+}
+
+// LastSendError returns the last send error and its unix millis, or "" and 0 if no send has failed yet
+func LastSendError() (message string, unixMillis int64) {
+	if e := lastSendError.Load(); e != nil {
+		return e.message, e.time
+	}
+	return "", 0
+}
+
+// deadLetterMetrics returns the dead-letter counters as a self-metrics result, with the time of the last
+// send error labeled with its kind once a send has failed
+func deadLetterMetrics(now int64) *model.ConversionResult {
+	permanent, exhausted := DeadLetterPacks()
+	var series []*model.OpenMx
+	for _, c := range []struct {
+		reason string
+		value  int64
+	}{
+		{DeadLetterPermanent, permanent},
+		{DeadLetterRetriesExhausted, exhausted},
+	} {
+		om := model.NewOpenMx(MetricDeadLetterPacks, now, float64(c.value))
+		om.AddLabel("reason", c.reason)
+		series = append(series, om)
+	}
+	series = append(series, model.NewOpenMx(MetricSendRetries, now, float64(SendRetries())))
+	if e := lastSendError.Load(); e != nil {
+		om := model.NewOpenMx(MetricLastSendError, now, float64(e.time)/1000)
+		om.AddLabel("error", e.kind)
+		series = append(series, om)
+	}
+
+	var helpList []*model.OpenMxHelp
+	for _, h := range []struct{ name, help, kind string }{
+		{MetricDeadLetterPacks, "Packs the sender gave up on, by reason", "counter"},
+		{MetricSendRetries, "Send attempts that were retries of a failed attempt", "counter"},
+		{MetricLastSendError, "Time of the last failed send attempt, labeled with the kind of its error", "gauge"},
+	} {
+		help := model.NewOpenMxHelp(h.name)
+		help.Put("help", h.help)
+		help.Put("type", h.kind)
+		helpList = append(helpList, help)
+	}
+
+	result := model.NewConversionResult(series, helpList)
+	result.SetCollectionTime(now)
+	return result
+}
@@ -0,0 +1,192 @@
+package sender
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/whatap/gointernal/net/secure"
+	"github.com/whatap/golib/lang/pack"
+
+	"open-agent/pkg/model"
+)
+
+// newRetrySender returns a sender whose send fails the first failures attempts with err, and which
+// records the backoff delays instead of waiting
+func newRetrySender(failures int, err error) (s *Sender, attempts *int, delays *[]time.Duration) {
+	attempts, delays = new(int), new([]time.Duration)
+	s = newTestSender(func(p pack.Pack) error {
+		*attempts++
+		if *attempts <= failures {
+			return err
+		}
+		return nil
+	})
+	s.retryDelay = 3 * time.Second
+	s.maxRetryDelay = MaxRetryDelay
+	s.after = func(d time.Duration) <-chan time.Time {
+		*delays = append(*delays, d)
+		ch := make(chan time.Time, 1)
+		ch <- time.Now()
+		return ch
+	}
+	return s, attempts, delays
+}
+
+func TestSendWithRetry_DeliversAfterTransientFailures(t *testing.T) {
+	s, attempts, delays := newRetrySender(2, errors.New("connection reset"))
+	retriesBefore := SendRetries()
+	_, exhaustedBefore := DeadLetterPacks()
+
+	sent, n := s.sendWithRetry(model.NewOpenMxPack(), s.sendFunc)
+	if !sent || n != 3 || *attempts != 3 {
+		t.Fatalf("sent = %v after %d attempts (%d sends), want delivery on the 3rd attempt", sent, n, *attempts)
+	}
+	if want := []time.Duration{3 * time.Second, 6 * time.Second}; !reflect.DeepEqual(*delays, want) {
+		t.Errorf("backoff delays = %v, want %v", *delays, want)
+	}
+	if got := SendRetries() - retriesBefore; got != 2 {
+		t.Errorf("retries counted = %d, want 2", got)
+	}
+	if _, exhausted := DeadLetterPacks(); exhausted != exhaustedBefore {
+		t.Errorf("a delivered pack must not be a dead letter")
+	}
+	if message, at := LastSendError(); message != "connection reset" || at == 0 {
+		t.Errorf("last send error = %q at %d, want connection reset", message, at)
+	}
+}
+
+func TestSendWithRetry_ExhaustedAttemptsAreDeadLetters(t *testing.T) {
+	s, attempts, _ := newRetrySender(10, fmt.Errorf("attempt failed: %w", ErrSendTimeout))
+	s.maxAttempts = 4
+	_, exhaustedBefore := DeadLetterPacks()
+
+	sent, n := s.sendWithRetry(model.NewOpenMxPack(), s.sendFunc)
+	if sent || n != 4 || *attempts != 4 {
+		t.Fatalf("sent = %v after %d attempts (%d sends), want 4 failed attempts", sent, n, *attempts)
+	}
+	if _, exhausted := DeadLetterPacks(); exhausted-exhaustedBefore != 1 {
+		t.Errorf("dead letters = %d, want 1", exhausted-exhaustedBefore)
+	}
+	if s.failedPacks.Load() != 1 || !s.draining.Load() {
+		t.Errorf("expected the pack to be counted as failed and the buffer to drain")
+	}
+}
+
+func TestSendWithRetry_PermanentFailureIsNotRetried(t *testing.T) {
+	s, attempts, delays := newRetrySender(10, Permanent(errors.New("pack cannot be encoded")))
+	permanentBefore, _ := DeadLetterPacks()
+	var outcome error
+	s.SetSendCallback(func(p pack.Pack, err error) { outcome = err })
+
+	if sent, _ := s.sendWithRetry(model.NewOpenMxPack(), s.sendFunc); sent {
+		t.Fatalf("expected the pack to be given up on")
+	}
+	if *attempts != 1 || len(*delays) != 0 {
+		t.Errorf("%d attempts with delays %v, want a single attempt", *attempts, *delays)
+	}
+	if permanent, _ := DeadLetterPacks(); permanent-permanentBefore != 1 {
+		t.Errorf("permanent dead letters = %d, want 1", permanent-permanentBefore)
+	}
+	if !IsPermanent(outcome) || outcome.Error() != "pack cannot be encoded" {
+		t.Errorf("callback error = %v, want the permanent error", outcome)
+	}
+}
+
+func TestSendWithRetry_PanicIsPermanent(t *testing.T) {
+	s, _, _ := newRetrySender(0, nil)
+	attempts := 0
+	s.sendFunc = func(p pack.Pack) error {
+		attempts++
+		panic("bad pack")
+	}
+
+	if sent, _ := s.sendWithRetry(model.NewOpenMxPack(), s.sendFunc); sent || attempts != 1 {
+		t.Fatalf("sent = %v after %d attempts, want one failed attempt", sent, attempts)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	for _, tc := range []struct {
+		retry int
+		want  time.Duration
+	}{
+		{1, 3 * time.Second},
+		{2, 6 * time.Second},
+		{3, 12 * time.Second},
+		{4, 24 * time.Second},
+		{5, 30 * time.Second},
+		{40, 30 * time.Second},
+	} {
+		if got := backoff(tc.retry, 3*time.Second, 30*time.Second); got != tc.want {
+			t.Errorf("backoff(%d) = %v, want %v", tc.retry, got, tc.want)
+		}
+	}
+}
+
+func TestNewSender_RetryPolicyFromConfig(t *testing.T) {
+	t.Setenv("openagent_send_max_attempts", "5")
+	t.Setenv("openagent_send_retry_delay_ms", "500")
+	t.Setenv("openagent_send_max_retry_delay_ms", "2000")
+
+	s := NewSender(make(chan *model.ConversionResult, 1), nil, false)
+	if s.maxAttempts != 5 || s.retryDelay != 500*time.Millisecond || s.maxRetryDelay != 2*time.Second {
+		t.Errorf("retry policy = %d attempts, %v, %v; want 5, 500ms, 2s", s.maxAttempts, s.retryDelay, s.maxRetryDelay)
+	}
+}
+
+func TestDeadLetterMetrics(t *testing.T) {
+	recordSendError(secure.ErrSendQueueFull, time.UnixMilli(1700000000000))
+
+	result := deadLetterMetrics(1700000060000)
+	values := make(map[string]*model.OpenMx)
+	for _, om := range result.GetOpenMxList() {
+		key := om.Metric
+		for _, label := range om.Labels {
+			key += "," + label.Key + "=" + label.Value
+		}
+		values[key] = om
+	}
+	for _, key := range []string{
+		MetricDeadLetterPacks + ",reason=" + DeadLetterPermanent,
+		MetricDeadLetterPacks + ",reason=" + DeadLetterRetriesExhausted,
+		MetricSendRetries,
+	} {
+		if values[key] == nil {
+			t.Errorf("missing series %s", key)
+		}
+	}
+	lastError := values[MetricLastSendError+",error="+SendErrorQueueFull]
+	if lastError == nil || lastError.Value != 1700000000 {
+		t.Errorf("last send error series = %v, want the error time in seconds", lastError)
+	}
+	if len(result.GetOpenMxHelpList()) != 3 {
+		t.Errorf("expected help for the 3 metrics, got %d", len(result.GetOpenMxHelpList()))
+	}
+}
+
+func TestSendErrorKind(t *testing.T) {
+	for err, want := range map[error]string{
+		secure.ErrSendQueueFull:                            SendErrorQueueFull,
+		fmt.Errorf("wrapped: %w", secure.ErrSendQueueFull): SendErrorQueueFull,
+		Permanent(errors.New("cannot encode pack")):        SendErrorPermanent,
+		ErrSendTimeout:           SendErrorTimeout,
+		context.DeadlineExceeded: SendErrorTimeout,
+		&net.OpError{Op: "write", Err: os.ErrDeadlineExceeded}:                             SendErrorTimeout,
+		&net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}: SendErrorRefused,
+		&net.OpError{Op: "write", Err: os.NewSyscallError("write", syscall.EPIPE)}:         SendErrorClosed,
+		net.ErrClosed:    SendErrorClosed,
+		errSenderStopped: SendErrorClosed,
+		errors.New("agent 10.0.0.1:6600 not registered"): SendErrorOther,
+	} {
+		if got := sendErrorKind(err); got != want {
+			t.Errorf("sendErrorKind(%v) = %q, want %q", err, got, want)
+		}
+	}
+}
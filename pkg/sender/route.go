@@ -0,0 +1,283 @@
+package sender
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/whatap/golib/lang/pack"
+	"github.com/whatap/golib/logger/logfile"
+	"gopkg.in/yaml.v3"
+
+	"open-agent/pkg/config"
+	"open-agent/pkg/model"
+)
+
+const (
+	// DefaultRouteName is the route of records no rule matches, sent on the agent's own session
+	DefaultRouteName = "default"
+
+	// RouteMetricsInterval is how often the per-route counters are sent
+	RouteMetricsInterval = time.Minute
+
+	MetricRouteRecords      = "openagent_route_records_total"
+	MetricRoutePacksSent    = "openagent_route_packs_sent_total"
+	MetricRouteSendFailures = "openagent_route_send_failures_total"
+)
+
+// RouteRule sends the records whose labels match to another WhaTap project
+type RouteRule struct {
+	Name string `yaml:"name"`
+	// Match maps label names to a value or an anchored regular expression; all must match.
+	// A record without the label matches as an empty value.
+	Match map[string]string `yaml:"match"`
+	Pcode int64             `yaml:"pcode"`
+	// LicenseRef is the whatap.conf key or environment variable holding the project's license
+	LicenseRef string `yaml:"licenseRef"`
+}
+
+// RouteSession sends packs to the project of one route
+type RouteSession interface {
+	Send(p pack.Pack) error
+}
+
+// errRouteSessionUnsupported is returned by the default session factory: the secure transport keeps a
+// single process-wide session for the agent's own license
+var errRouteSessionUnsupported = errors.New("the secure transport supports only the agent's own license session")
+
+// newRouteSession opens the session of a route; replaced in tests. Until the transport supports other
+// licenses it always fails, so a routes file is rejected and every record stays on the default session.
+var newRouteSession = func(rule RouteRule, license string) (RouteSession, error) {
+	return nil, errRouteSessionUnsupported
+}
+
+// route is a routing destination with its volume and failure counters
+type route struct {
+	name     string
+	matchers []routeMatcher
+	// pcode replaces the pcode label of the route's records; 0 for the default route
+	pcode int64
+	// session is nil for the default route, which uses the sender's own send function
+	session RouteSession
+
+	records  atomic.Int64
+	packs    atomic.Int64
+	failures atomic.Int64
+}
+
+type routeMatcher struct {
+	label string
+	re    *regexp.Regexp
+}
+
+func (r *route) matches(om *model.OpenMx) bool {
+	for _, m := range r.matchers {
+		value := ""
+		for _, label := range om.Labels {
+			if label.Key == m.label {
+				value = label.Value
+				break
+			}
+		}
+		if !m.re.MatchString(value) {
+			return false
+		}
+	}
+	return true
+}
+
+// router partitions records by the first matching route rule
+type router struct {
+	routes   []*route
+	fallback *route
+}
+
+// routedRecords are the records of one result going to one route, in their original order
+type routedRecords struct {
+	route   *route
+	records []*model.OpenMx
+}
+
+// loadRouter reads the routing rules from the file named by openagent_routes_file, and returns nil
+// when routing is not configured. A file that cannot be used disables routing as a whole, so every
+// record is sent on the default session instead of being dropped.
+func loadRouter(logger *logfile.FileLogger) *router {
+	path := config.Get("openagent_routes_file")
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		logger.Println("SenderRoute", fmt.Sprintf("Routing disabled, sending every record to the default project: cannot read %s: %v", path, err))
+		return nil
+	}
+	rules, err := parseRouteRules(data)
+	if err != nil {
+		logger.Println("SenderRoute", fmt.Sprintf("Routing disabled, sending every record to the default project: invalid %s: %v", path, err))
+		return nil
+	}
+	r, err := newRouter(rules, logger)
+	if err != nil {
+		logger.Println("SenderRoute", fmt.Sprintf("Routing disabled, sending every record to the default project: invalid %s: %v", path, err))
+		return nil
+	}
+	return r
+}
+
+// parseRouteRules parses a routes file:
+//
+//	routes:
+//	  - name: team-a
+//	    match: {namespace: team-a}
+//	    pcode: 1234
+//	    licenseRef: TEAM_A_LICENSE
+func parseRouteRules(data []byte) ([]RouteRule, error) {
+	var file struct {
+		Routes []RouteRule `yaml:"routes"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	return file.Routes, nil
+}
+
+// newRouter compiles the rules and opens a session per route. It fails when a route has no license
+// or its session cannot be opened, as the route's records could neither be sent nor kept.
+func newRouter(rules []RouteRule, logger *logfile.FileLogger) (*router, error) {
+	r := &router{fallback: &route{name: DefaultRouteName}}
+	names := map[string]bool{DefaultRouteName: true}
+	for i, rule := range rules {
+		if rule.Name == "" {
+			return nil, fmt.Errorf("route %d has no name", i)
+		}
+		if names[rule.Name] {
+			return nil, fmt.Errorf("duplicate route name %q", rule.Name)
+		}
+		names[rule.Name] = true
+		if len(rule.Match) == 0 {
+			return nil, fmt.Errorf("route %q has no match labels", rule.Name)
+		}
+
+		if rule.Pcode <= 0 {
+			return nil, fmt.Errorf("route %q has no pcode", rule.Name)
+		}
+
+		rt := &route{name: rule.Name, pcode: rule.Pcode}
+		for label, pattern := range rule.Match {
+			re, err := regexp.Compile("^(?:" + pattern + ")$")
+			if err != nil {
+				return nil, fmt.Errorf("route %q: invalid match for %s: %v", rule.Name, label, err)
+			}
+			rt.matchers = append(rt.matchers, routeMatcher{label: label, re: re})
+		}
+
+		license := ""
+		if rule.LicenseRef != "" {
+			license = config.Get(rule.LicenseRef)
+		}
+		if license == "" {
+			return nil, fmt.Errorf("route %q: no license in %q", rule.Name, rule.LicenseRef)
+		}
+		session, err := newRouteSession(rule, license)
+		if err != nil {
+			return nil, fmt.Errorf("route %q (pcode %d): cannot open a session: %v", rule.Name, rule.Pcode, err)
+		}
+		rt.session = session
+		logger.Println("SenderRoute", fmt.Sprintf("Route %s: sending matching records to pcode %d", rule.Name, rule.Pcode))
+		r.routes = append(r.routes, rt)
+	}
+	return r, nil
+}
+
+// route returns the first route whose rule matches the record, or the default route
+func (r *router) route(om *model.OpenMx) *route {
+	for _, rt := range r.routes {
+		if rt.matches(om) {
+			return rt
+		}
+	}
+	return r.fallback
+}
+
+// setPcode sets the pcode label of records to the route's project; the default route keeps the
+// agent's own pcode
+func (r *route) setPcode(records []*model.OpenMx) {
+	if r.pcode == 0 {
+		return
+	}
+	pcode := strconv.FormatInt(r.pcode, 10)
+	for _, om := range records {
+		replaced := false
+		for i := range om.Labels {
+			if om.Labels[i].Key == "pcode" {
+				om.Labels[i].Value = pcode
+				replaced = true
+			}
+		}
+		if !replaced {
+			om.AddLabel("pcode", pcode)
+		}
+	}
+}
+
+// partition splits records by route. Records keep their relative order within a route, and a series
+// always goes to the same route since matching only depends on its labels.
+func (r *router) partition(records []*model.OpenMx) []routedRecords {
+	byRoute := make(map[*route]int)
+	var parts []routedRecords
+	for _, om := range records {
+		rt := r.route(om)
+		i, ok := byRoute[rt]
+		if !ok {
+			i = len(parts)
+			byRoute[rt] = i
+			parts = append(parts, routedRecords{route: rt})
+		}
+		parts[i].records = append(parts[i].records, om)
+	}
+	return parts
+}
+
+// all returns the default route followed by the rule routes
+func (r *router) all() []*route {
+	return append([]*route{r.fallback}, r.routes...)
+}
+
+// metrics returns the per-route counters as a self-metrics result
+func (r *router) metrics(now int64) *model.ConversionResult {
+	var series []*model.OpenMx
+	for _, rt := range r.all() {
+		for _, counter := range []struct {
+			name  string
+			value int64
+		}{
+			{MetricRouteRecords, rt.records.Load()},
+			{MetricRoutePacksSent, rt.packs.Load()},
+			{MetricRouteSendFailures, rt.failures.Load()},
+		} {
+			om := model.NewOpenMx(counter.name, now, float64(counter.value))
+			om.AddLabel("route", rt.name)
+			series = append(series, om)
+		}
+	}
+
+	var helpList []*model.OpenMxHelp
+	for _, h := range []struct{ name, help string }{
+		{MetricRouteRecords, "Records routed to the route's project"},
+		{MetricRoutePacksSent, "Packs sent on the route's session"},
+		{MetricRouteSendFailures, "Packs that could not be sent on the route's session after retries"},
+	} {
+		help := model.NewOpenMxHelp(h.name)
+		help.Put("help", h.help)
+		help.Put("type", "counter")
+		helpList = append(helpList, help)
+	}
+
+	result := model.NewConversionResult(series, helpList)
+	result.SetCollectionTime(now)
+	return result
+}
@@ -0,0 +1,283 @@
+package sender
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/whatap/golib/lang/pack"
+	"github.com/whatap/golib/logger/logfile"
+
+	"open-agent/pkg/model"
+)
+
+// fakeSession records the metric packs sent on one route
+type fakeSession struct {
+	mu      sync.Mutex
+	records []string
+	pcodes  map[string]bool
+	help    int
+	fail    bool
+}
+
+func (f *fakeSession) Send(p pack.Pack) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.fail {
+		return errors.New("session closed")
+	}
+	switch p := p.(type) {
+	case *model.OpenMxPack:
+		for _, om := range p.GetRecords() {
+			f.records = append(f.records, recordKey(om))
+			for _, label := range om.Labels {
+				if label.Key == "pcode" {
+					if f.pcodes == nil {
+						f.pcodes = map[string]bool{}
+					}
+					f.pcodes[label.Value] = true
+				}
+			}
+		}
+	case *model.OpenMxHelpPack:
+		f.help++
+	}
+	return nil
+}
+
+func (f *fakeSession) Records() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.records...)
+}
+
+func recordKey(om *model.OpenMx) string {
+	for _, label := range om.Labels {
+		if label.Key == "namespace" {
+			return fmt.Sprintf("%s/%s@%d", label.Value, om.Metric, om.Timestamp)
+		}
+	}
+	return fmt.Sprintf("-/%s@%d", om.Metric, om.Timestamp)
+}
+
+func routedRecord(namespace, metric string, ts int64) *model.OpenMx {
+	om := model.NewOpenMx(metric, ts, 1)
+	if namespace != "" {
+		om.AddLabel("namespace", namespace)
+	}
+	om.AddLabel("pcode", "100")
+	return om
+}
+
+// newRoutedSender returns a sender routing team-a and team-b.* namespaces to fake sessions
+func newRoutedSender(t *testing.T, sessions map[string]*fakeSession, rules string) (*Sender, *fakeSession) {
+	t.Helper()
+	defer func(open func(RouteRule, string) (RouteSession, error)) { newRouteSession = open }(newRouteSession)
+	newRouteSession = func(rule RouteRule, license string) (RouteSession, error) {
+		if session, ok := sessions[rule.Name]; ok && license == "license-"+rule.Name {
+			return session, nil
+		}
+		return nil, errRouteSessionUnsupported
+	}
+	t.Setenv("TEAM_A_LICENSE", "license-team-a")
+	t.Setenv("TEAM_B_LICENSE", "license-team-b")
+
+	parsed, err := parseRouteRules([]byte(rules))
+	if err != nil {
+		t.Fatalf("parseRouteRules: %v", err)
+	}
+	defaultSession := &fakeSession{}
+	s := newTestSender(defaultSession.Send)
+	if s.router, err = newRouter(parsed, logfile.NewFileLogger()); err != nil {
+		t.Fatalf("newRouter: %v", err)
+	}
+	s.Start()
+	t.Cleanup(s.Stop)
+	return s, defaultSession
+}
+
+const twoTeamRoutes = `
+routes:
+  - name: team-a
+    match: {namespace: team-a}
+    pcode: 101
+    licenseRef: TEAM_A_LICENSE
+  - name: team-b
+    match: {namespace: "team-b.*"}
+    pcode: 102
+    licenseRef: TEAM_B_LICENSE
+`
+
+func waitRecords(t *testing.T, sessions ...*fakeSession) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		done := true
+		for _, session := range sessions {
+			if len(session.Records()) == 0 {
+				done = false
+			}
+		}
+		if done {
+			time.Sleep(20 * time.Millisecond)
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("timed out waiting for routed records")
+}
+
+func TestSender_RoutesRecordsByLabel(t *testing.T) {
+	teamA, teamB := &fakeSession{}, &fakeSession{}
+	s, defaultSession := newRoutedSender(t, map[string]*fakeSession{"team-a": teamA, "team-b": teamB}, twoTeamRoutes)
+
+	// Samples of the same series interleaved with other routes' records
+	result := model.NewConversionResult([]*model.OpenMx{
+		routedRecord("team-a", "up", 1),
+		routedRecord("team-b-prod", "up", 1),
+		routedRecord("kube-system", "up", 1),
+		routedRecord("team-a", "up", 2),
+		routedRecord("", "up", 1),
+		routedRecord("team-b-prod", "up", 2),
+		routedRecord("team-a", "up", 3),
+		routedRecord("team-bx", "up", 1),
+	}, []*model.OpenMxHelp{model.NewOpenMxHelp("up")})
+	result.SetTarget("http://10.0.0.1:8080/metrics")
+	s.processedQueue <- result
+	waitRecords(t, teamA, teamB, defaultSession)
+
+	want := map[*fakeSession]string{
+		teamA:          "team-a/up@1,team-a/up@2,team-a/up@3",
+		teamB:          "team-b-prod/up@1,team-b-prod/up@2,team-bx/up@1",
+		defaultSession: "kube-system/up@1,-/up@1",
+	}
+	wantPcode := map[*fakeSession]string{teamA: "101", teamB: "102", defaultSession: "100"}
+	for session, records := range want {
+		if got := strings.Join(session.Records(), ","); got != records {
+			t.Errorf("got %s, want %s", got, records)
+		}
+		if len(session.pcodes) != 1 || !session.pcodes[wantPcode[session]] {
+			t.Errorf("expected records of %s to carry pcode %s, got %v", records, wantPcode[session], session.pcodes)
+		}
+		if session.help != 1 {
+			t.Errorf("expected the metadata to be sent on every route, got %d help packs", session.help)
+		}
+	}
+
+	counts := map[string]int64{}
+	for _, rt := range s.router.all() {
+		counts[rt.name] = rt.records.Load()
+		if rt.packs.Load() != 2 || rt.failures.Load() != 0 {
+			t.Errorf("route %s: %d packs sent, %d failures", rt.name, rt.packs.Load(), rt.failures.Load())
+		}
+	}
+	if counts["team-a"] != 3 || counts["team-b"] != 3 || counts[DefaultRouteName] != 2 {
+		t.Errorf("unexpected per-route volumes %v", counts)
+	}
+}
+
+func TestNewRouter_UnavailableSessionRejected(t *testing.T) {
+	defer func(open func(RouteRule, string) (RouteSession, error)) { newRouteSession = open }(newRouteSession)
+	newRouteSession = func(rule RouteRule, license string) (RouteSession, error) {
+		if rule.Name == "team-a" {
+			return &fakeSession{}, nil
+		}
+		return nil, errRouteSessionUnsupported
+	}
+	t.Setenv("TEAM_A_LICENSE", "license-team-a")
+	t.Setenv("TEAM_B_LICENSE", "license-team-b")
+
+	parsed, err := parseRouteRules([]byte(twoTeamRoutes))
+	if err != nil {
+		t.Fatalf("parseRouteRules: %v", err)
+	}
+	// team-b has no session, routing must not start with records it can neither send nor keep
+	if _, err := newRouter(parsed, logfile.NewFileLogger()); err == nil || !strings.Contains(err.Error(), `route "team-b"`) {
+		t.Errorf("expected team-b to be rejected, got %v", err)
+	}
+
+	t.Setenv("TEAM_B_LICENSE", "")
+	newRouteSession = func(rule RouteRule, license string) (RouteSession, error) { return &fakeSession{}, nil }
+	if _, err := newRouter(parsed, logfile.NewFileLogger()); err == nil || !strings.Contains(err.Error(), "no license") {
+		t.Errorf("expected a route without license to be rejected, got %v", err)
+	}
+}
+
+func TestLoadRouter_FallsBackToDefaultSession(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "routes.yaml")
+	if err := os.WriteFile(path, []byte(twoTeamRoutes), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("openagent_routes_file", path)
+	t.Setenv("TEAM_A_LICENSE", "license-team-a")
+	t.Setenv("TEAM_B_LICENSE", "license-team-b")
+
+	// The production session factory cannot open other licenses, so routing is disabled
+	defaultSession := &fakeSession{}
+	s := newTestSender(defaultSession.Send)
+	if s.router = loadRouter(logfile.NewFileLogger()); s.router != nil {
+		t.Fatal("expected routing to be disabled")
+	}
+	s.Start()
+	t.Cleanup(s.Stop)
+
+	s.processedQueue <- model.NewConversionResult([]*model.OpenMx{
+		routedRecord("team-a", "up", 1),
+		routedRecord("team-b", "up", 1),
+	}, nil)
+	waitRecords(t, defaultSession)
+
+	if got := strings.Join(defaultSession.Records(), ","); got != "team-a/up@1,team-b/up@1" {
+		t.Errorf("expected every record on the default session, got %s", got)
+	}
+}
+
+func TestSender_RouteSendFailuresCounted(t *testing.T) {
+	teamA := &fakeSession{fail: true}
+	s, _ := newRoutedSender(t, map[string]*fakeSession{"team-a": teamA, "team-b": {}}, twoTeamRoutes)
+
+	s.processedQueue <- model.NewConversionResult([]*model.OpenMx{routedRecord("team-a", "up", 1)}, nil)
+	teamARoute := s.router.routes[0]
+	deadline := time.Now().Add(2 * time.Second)
+	for teamARoute.failures.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if teamARoute.failures.Load() != 1 || teamARoute.packs.Load() != 0 {
+		t.Errorf("expected 1 failed pack, got %d failures and %d sent", teamARoute.failures.Load(), teamARoute.packs.Load())
+	}
+
+	metrics := s.router.metrics(1000)
+	found := false
+	for _, om := range metrics.OpenMxList {
+		if om.Metric == MetricRouteSendFailures && om.Value == 1 && om.Labels[0].Value == "team-a" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected %s{route=team-a} 1 in the route counters", MetricRouteSendFailures)
+	}
+}
+
+func TestNewRouter_InvalidRules(t *testing.T) {
+	for _, rules := range []string{
+		"routes: [{name: a}]",
+		"routes: [{name: a, match: {namespace: a}}]",
+		"routes: [{match: {namespace: a}}]",
+		"routes: [{name: a, match: {namespace: a}, pcode: 1}, {name: a, match: {namespace: b}, pcode: 2}]",
+		"routes: [{name: default, match: {namespace: a}, pcode: 1}]",
+		`routes: [{name: a, match: {namespace: "("}, pcode: 1}]`,
+	} {
+		parsed, err := parseRouteRules([]byte(rules))
+		if err != nil {
+			t.Fatalf("parseRouteRules(%s): %v", rules, err)
+		}
+		if _, err := newRouter(parsed, logfile.NewFileLogger()); err == nil {
+			t.Errorf("expected %s to be rejected", rules)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package sender
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"github.com/whatap/gointernal/net/secure"
+
+	"open-agent/pkg/config"
+)
+
+const (
+	// DefaultSendPhaseCycle is the window the agents' send offsets are spread over
+	DefaultSendPhaseCycle = time.Minute
+
+	// DefaultSendPhaseJitter is the maximum random delay added to each pack on top of the offset
+	DefaultSendPhaseJitter = 2 * time.Second
+
+	// sendPhaseDrainThreshold is the number of queued packs from which the buffer is treated as draining
+	// a backlog, and packs are sent without delay
+	sendPhaseDrainThreshold = InFlightBufferSize / 4
+)
+
+// sendPhaseOffsetMillis is the offset in use, -1 while the send phase is off, reported in the keep-alive pack
+var sendPhaseOffsetMillis int64 = -1
+
+// SendPhaseOffsetMillis returns the agent's send offset within the cycle in milliseconds,
+// or -1 while the send phase is off
+func SendPhaseOffsetMillis() int64 {
+	return atomic.LoadInt64(&sendPhaseOffsetMillis)
+}
+
+// SendPhaseEnabled reports whether packs are held until the agent's send offset (openagent_send_phase_enabled)
+func SendPhaseEnabled() bool {
+	return config.GetBoolWithDefault("openagent_send_phase_enabled", false)
+}
+
+// sendPhaseCycle returns the cycle window configured with openagent_send_phase_cycle_ms
+func sendPhaseCycle() time.Duration {
+	cycle := time.Duration(config.GetIntWithDefault("openagent_send_phase_cycle_ms", int(DefaultSendPhaseCycle/time.Millisecond))) * time.Millisecond
+	if cycle <= 0 {
+		return DefaultSendPhaseCycle
+	}
+	return cycle
+}
+
+// sendPhaseJitter returns the maximum per-pack jitter configured with openagent_send_phase_jitter_ms
+func sendPhaseJitter() time.Duration {
+	jitter := time.Duration(config.GetIntWithDefault("openagent_send_phase_jitter_ms", int(DefaultSendPhaseJitter/time.Millisecond))) * time.Millisecond
+	if jitter < 0 {
+		return 0
+	}
+	return jitter
+}
+
+// phaseOffset returns the agent's deterministic offset within the cycle, derived from a hash of its OID,
+// so the fleet's sends spread evenly over the window and an agent keeps its slot across restarts
+func phaseOffset(oid int32, cycle time.Duration) time.Duration {
+	h := fnv.New64a()
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], uint32(oid))
+	h.Write(b[:])
+	return time.Duration(h.Sum64()%uint64(cycle.Milliseconds())) * time.Millisecond
+}
+
+// phaseSendAt returns when a pack queued at queuedAt is sent: at the offset within the pack's cycle, or
+// within the next cycle when that moment has passed, plus jitter. The delay stays below one cycle.
+func phaseSendAt(queuedAt time.Time, offset, cycle, jitter time.Duration) time.Time {
+	position := queuedAt.Sub(queuedAt.Truncate(cycle))
+	delay := (offset - position + cycle) % cycle
+	delay += jitter
+	if delay >= cycle {
+		delay = cycle - time.Millisecond
+	}
+	return queuedAt.Add(delay)
+}
+
+// securityMasterOID returns the agent's OID, 0 until the agent is registered
+func securityMasterOID() int32 {
+	if securityMaster := secure.GetSecurityMaster(); securityMaster != nil {
+		return securityMaster.OID
+	}
+	return 0
+}
+
+// phaseDelay returns how long to hold a pack queued at queuedAt so it goes out at the agent's send offset.
+// It is 0 when the send phase is off, the OID is not known yet, the buffer is draining a backlog after
+// failed sends, so an outage is never prolonged by the offset, or the sender is flushing on shutdown.
+func (s *Sender) phaseDelay(queuedAt time.Time) time.Duration {
+	if !SendPhaseEnabled() {
+		atomic.StoreInt64(&sendPhaseOffsetMillis, -1)
+		return 0
+	}
+	oid := s.oid()
+	if oid == 0 {
+		return 0
+	}
+
+	cycle := sendPhaseCycle()
+	offset := phaseOffset(oid, cycle)
+	if atomic.SwapInt64(&sendPhaseOffsetMillis, offset.Milliseconds()) != offset.Milliseconds() {
+		s.logger.Println("SenderPhase", fmt.Sprintf("Sending packs at offset %v of each %v cycle (oid %d, jitter up to %v)",
+			offset, cycle, oid, sendPhaseJitter()))
+	}
+
+	if s.draining.Load() || len(s.packCh) >= sendPhaseDrainThreshold || s.flushing() {
+		return 0
+	}
+
+	var jitter time.Duration
+	if max := sendPhaseJitter(); max > 0 {
+		jitter = time.Duration(s.randInt63n(int64(max)))
+	}
+	delay := phaseSendAt(queuedAt, offset, cycle, jitter).Sub(s.now())
+	if delay < 0 {
+		return 0
+	}
+	return delay
+}
+
+// waitPhase holds a pack until its send time and reports false when the sender stops meanwhile
+func (s *Sender) waitPhase(queuedAt time.Time) bool {
+	delay := s.phaseDelay(queuedAt)
+	if delay <= 0 {
+		return true
+	}
+	select {
+	case <-s.after(delay):
+		return true
+	case <-s.flushCh:
+		return true
+	case <-s.shutdownCh:
+		return false
+	}
+}
+
+// flushing reports whether Flush was called
+func (s *Sender) flushing() bool {
+	select {
+	case <-s.flushCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// defaultRandInt63n is the jitter source outside tests
+var defaultRandInt63n = rand.Int63n
@@ -0,0 +1,206 @@
+package sender
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/whatap/golib/lang/pack"
+
+	"open-agent/pkg/model"
+)
+
+func TestPhaseSendAt(t *testing.T) {
+	cycle := time.Minute
+	offset := 17 * time.Second
+	minute := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		queuedAt time.Time
+		jitter   time.Duration
+		want     time.Time
+	}{
+		{"top of the minute", minute.Add(500 * time.Millisecond), 0, minute.Add(offset)},
+		{"at the offset", minute.Add(offset), 0, minute.Add(offset)},
+		{"past the offset waits for the next cycle", minute.Add(30 * time.Second), 0, minute.Add(cycle + offset)},
+		{"jitter", minute.Add(time.Second), 1500 * time.Millisecond, minute.Add(offset + 1500*time.Millisecond)},
+		{"jitter never delays a full cycle", minute.Add(offset + time.Millisecond), 2 * time.Second, minute.Add(offset + cycle)},
+	}
+	for _, tt := range tests {
+		got := phaseSendAt(tt.queuedAt, offset, cycle, tt.jitter)
+		if !got.Equal(tt.want) {
+			t.Errorf("%s: send at %v, want %v", tt.name, got.Format("15:04:05.000"), tt.want.Format("15:04:05.000"))
+		}
+		if delay := got.Sub(tt.queuedAt); delay < 0 || delay >= cycle {
+			t.Errorf("%s: delay %v is outside [0, %v)", tt.name, delay, cycle)
+		}
+	}
+}
+
+func TestPhaseOffset_DeterministicAndSpread(t *testing.T) {
+	cycle := time.Minute
+	if phaseOffset(12345, cycle) != phaseOffset(12345, cycle) {
+		t.Fatalf("expected the offset of an OID to be stable")
+	}
+
+	buckets := make(map[time.Duration]int)
+	for oid := int32(1); oid <= 600; oid++ {
+		offset := phaseOffset(oid, cycle)
+		if offset < 0 || offset >= cycle {
+			t.Fatalf("oid %d: offset %v is outside the cycle", oid, offset)
+		}
+		buckets[offset/(10*time.Second)]++
+	}
+	// 600 agents over six 10s slots: each slot should get roughly 100
+	for slot := time.Duration(0); slot < 6; slot++ {
+		if n := buckets[slot]; n < 60 || n > 140 {
+			t.Errorf("slot %d got %d agents, expected the offsets to spread evenly: %v", slot, n, buckets)
+		}
+	}
+}
+
+// phaseClock is a mocked clock that records the delays the sender waits for
+type phaseClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	delays []time.Duration
+}
+
+func (c *phaseClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *phaseClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.delays = append(c.delays, d)
+	c.now = c.now.Add(d)
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+func (c *phaseClock) Delays() []time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]time.Duration(nil), c.delays...)
+}
+
+func newPhaseSender(clock *phaseClock, sent chan<- time.Time) *Sender {
+	s := newTestSender(func(p pack.Pack) error {
+		sent <- clock.Now()
+		return nil
+	})
+	s.now = clock.Now
+	s.after = clock.After
+	s.oid = func() int32 { return 42 }
+	s.randInt63n = func(n int64) int64 { return int64(250 * time.Millisecond) }
+	s.sendTimeout = time.Second
+	return s
+}
+
+func TestSender_AppliesSendPhaseOffset(t *testing.T) {
+	t.Setenv("openagent_send_phase_enabled", "true")
+	minute := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	clock := &phaseClock{now: minute.Add(2 * time.Second)}
+	sent := make(chan time.Time, 2)
+	s := newPhaseSender(clock, sent)
+
+	s.enqueue(model.NewOpenMxPack())
+	s.Start()
+	defer s.Stop()
+
+	sentAt := <-sent
+	offset := phaseOffset(42, DefaultSendPhaseCycle)
+	want := minute.Add(offset + 250*time.Millisecond)
+	if offset < 2*time.Second {
+		// The offset already passed in this minute, the pack goes out in the next one
+		want = want.Add(DefaultSendPhaseCycle)
+	}
+	if !sentAt.Equal(want) {
+		t.Errorf("pack sent at %v, want %v (offset %v)", sentAt.Format("15:04:05.000"), want.Format("15:04:05.000"), offset)
+	}
+	if got := SendPhaseOffsetMillis(); got != offset.Milliseconds() {
+		t.Errorf("expected the offset %v to be reported, got %dms", offset, got)
+	}
+	if delays := clock.Delays(); len(delays) != 1 || delays[0] >= DefaultSendPhaseCycle {
+		t.Errorf("expected one wait shorter than a cycle, got %v", delays)
+	}
+}
+
+func TestSender_SendPhaseSkippedWhileDraining(t *testing.T) {
+	t.Setenv("openagent_send_phase_enabled", "true")
+	clock := &phaseClock{now: time.Date(2026, 10, 16, 12, 0, 1, 0, time.UTC)}
+	s := newPhaseSender(clock, make(chan time.Time, 1))
+
+	idle := phaseSendAt(clock.Now(), phaseOffset(42, DefaultSendPhaseCycle), DefaultSendPhaseCycle, 250*time.Millisecond).Sub(clock.Now())
+	if d := s.phaseDelay(clock.Now()); d != idle || d == 0 {
+		t.Fatalf("expected a delay of %v while the buffer is idle, got %v", idle, d)
+	}
+
+	s.draining.Store(true)
+	if d := s.phaseDelay(clock.Now()); d != 0 {
+		t.Errorf("expected no delay after failed sends, got %v", d)
+	}
+	s.draining.Store(false)
+
+	for i := 0; i < sendPhaseDrainThreshold; i++ {
+		s.enqueue(model.NewOpenMxPack())
+	}
+	if d := s.phaseDelay(clock.Now()); d != 0 {
+		t.Errorf("expected no delay while a backlog drains, got %v", d)
+	}
+}
+
+func TestSender_SendPhaseDisabled(t *testing.T) {
+	clock := &phaseClock{now: time.Date(2026, 10, 16, 12, 0, 1, 0, time.UTC)}
+	s := newPhaseSender(clock, make(chan time.Time, 1))
+
+	if d := s.phaseDelay(clock.Now()); d != 0 {
+		t.Errorf("expected no delay with the send phase off, got %v", d)
+	}
+	if got := SendPhaseOffsetMillis(); got != -1 {
+		t.Errorf("expected -1 to be reported with the send phase off, got %d", got)
+	}
+}
+
+func TestSender_FlushReleasesHeldPacks(t *testing.T) {
+	t.Setenv("openagent_send_phase_enabled", "true")
+	clock := &phaseClock{now: time.Date(2026, 10, 16, 12, 0, 1, 0, time.UTC)}
+	sent := make(chan time.Time, 2)
+	s := newPhaseSender(clock, sent)
+	// The offset never arrives, only Flush can release the pack
+	s.after = func(d time.Duration) <-chan time.Time { return make(chan time.Time) }
+
+	s.enqueue(model.NewOpenMxPack())
+	s.Start()
+	defer s.Stop()
+
+	select {
+	case <-sent:
+		t.Fatal("expected the pack to be held until the send offset")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if got := s.Pending(); got != 1 {
+		t.Fatalf("expected the held pack to be pending, got %d", got)
+	}
+
+	s.Flush()
+	select {
+	case <-sent:
+	case <-time.After(time.Second):
+		t.Fatal("expected Flush to send the held pack")
+	}
+	if d := s.phaseDelay(clock.Now()); d != 0 {
+		t.Errorf("expected no delay after Flush, got %v", d)
+	}
+	deadline := time.Now().Add(time.Second)
+	for s.Pending() != 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if got := s.Pending(); got != 0 {
+		t.Errorf("expected nothing pending after the send, got %d", got)
+	}
+}
@@ -0,0 +1,155 @@
+package sender
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/whatap/golib/lang/pack"
+
+	"open-agent/pkg/model"
+)
+
+// newTestSender returns a sender whose network send is replaced by sendFunc
+func newTestSender(sendFunc func(p pack.Pack) error) *Sender {
+	s := NewSender(make(chan *model.ConversionResult, 10), nil, false)
+	s.sendFunc = sendFunc
+	s.sendTimeout = 50 * time.Millisecond
+	s.retryDelay = time.Millisecond
+	return s
+}
+
+func TestSendWithTimeout_BlockingSend(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+
+	s := newTestSender(func(p pack.Pack) error {
+		<-block
+		return nil
+	})
+
+	start := time.Now()
+	err := s.sendWithTimeout(model.NewOpenMxPack())
+	if !errors.Is(err, ErrSendTimeout) {
+		t.Fatalf("expected ErrSendTimeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("send watchdog took too long: %v", elapsed)
+	}
+}
+
+func TestSendToServerWithRetry_TimeoutIsRetried(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+
+	var calls int32
+	s := newTestSender(func(p pack.Pack) error {
+		// First attempt hangs, the retry succeeds
+		if atomic.AddInt32(&calls, 1) == 1 {
+			<-block
+		}
+		return nil
+	})
+
+	start := time.Now().UnixMilli()
+	s.sendToServerWithRetry(model.NewOpenMxPack())
+
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Fatalf("expected 2 send attempts, got %d", got)
+	}
+	if LastSuccessfulSendTime() < start {
+		t.Fatalf("expected last successful send time to be recorded, got %d", LastSuccessfulSendTime())
+	}
+}
+
+func TestSendToServerWithRetry_AllAttemptsTimeOut(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+
+	var calls int32
+	s := newTestSender(func(p pack.Pack) error {
+		atomic.AddInt32(&calls, 1)
+		<-block
+		return nil
+	})
+
+	before := LastSuccessfulSendTime()
+	s.sendToServerWithRetry(model.NewOpenMxPack())
+
+	if got := atomic.LoadInt32(&calls); got != MaxRetries {
+		t.Fatalf("expected %d send attempts, got %d", MaxRetries, got)
+	}
+	if LastSuccessfulSendTime() != before {
+		t.Fatalf("last successful send time must not change when every attempt times out")
+	}
+}
+
+func TestSendToServerWithRetry_SendCallback(t *testing.T) {
+	failures := 0
+	s := newTestSender(func(p pack.Pack) error {
+		if failures > 0 {
+			failures--
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+	var outcomes []error
+	s.SetSendCallback(func(p pack.Pack, err error) {
+		outcomes = append(outcomes, err)
+	})
+
+	// One failed attempt is retried and reported once, as sent
+	failures = 1
+	s.sendToServerWithRetry(model.NewOpenMxPack())
+	if len(outcomes) != 1 || outcomes[0] != nil {
+		t.Fatalf("expected one successful outcome, got %v", outcomes)
+	}
+
+	failures = MaxRetries
+	s.sendToServerWithRetry(model.NewOpenMxPack())
+	if len(outcomes) != 2 || outcomes[1] == nil || outcomes[1].Error() != "connection refused" {
+		t.Fatalf("expected the last error of a pack given up on, got %v", outcomes)
+	}
+}
+
+// TestSender_BlockedNetworkDoesNotHangStop verifies that a hung network send neither
+// blocks pack construction beyond the in-flight buffer nor prevents shutdown.
+func TestSender_BlockedNetworkDoesNotHangStop(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+
+	s := newTestSender(func(p pack.Pack) error {
+		<-block
+		return nil
+	})
+	s.sendTimeout = time.Hour
+	s.Start()
+
+	s.processedQueue <- &model.ConversionResult{
+		Target:         "target1",
+		CollectionTime: 1000,
+		OpenMxList:     []*model.OpenMx{model.NewOpenMx("up", 1000, 1)},
+	}
+
+	// The pack is built and handed off even though the network stage is stuck
+	deadline := time.After(time.Second)
+	for len(s.processedQueue) > 0 {
+		select {
+		case <-deadline:
+			t.Fatalf("pack construction stage did not consume the processed queue")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		s.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Stop did not return while a send was blocked")
+	}
+}
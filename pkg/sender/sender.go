@@ -1,14 +1,18 @@
 package sender
 
 import (
+	"errors"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/whatap/gointernal/net/secure"
 	"github.com/whatap/golib/lang/pack"
 	"github.com/whatap/golib/logger/logfile"
 
+	"open-agent/pkg/config"
+	"open-agent/pkg/diagnostics"
 	"open-agent/pkg/endpoint"
 	"open-agent/pkg/model"
 )
@@ -17,13 +21,34 @@ const (
 	// ChunkSize is the maximum number of metrics to send in a single batch
 	ChunkSize = 1000
 
-	// MaxRetries is the maximum number of retries for sending data
+	// MaxRetries is the default number of attempts to send a pack (openagent_send_max_attempts)
 	MaxRetries = 3
 
-	// RetryDelay is the delay between retries
-	RetryDelay = 5 * time.Second
+	// RetryDelay is the default delay before the first retry, doubled for each further retry
+	// (openagent_send_retry_delay_ms), so the default attempts span about 10 seconds
+	RetryDelay = 3 * time.Second
+
+	// InFlightBufferSize is the maximum number of packs waiting between pack construction and network sending
+	InFlightBufferSize = 100
+
+	// DefaultSendTimeout is the default time a single send may take before it is treated as failed
+	DefaultSendTimeout = 30 * time.Second
 )
 
+// ErrSendTimeout is returned when a send does not complete within the send timeout
+var ErrSendTimeout = errors.New("send timed out")
+
+// errSenderStopped is returned for a send still running when the sender stops
+var errSenderStopped = errors.New("sender stopped while sending")
+
+// lastSuccessfulSendTime is the unix millis of the last pack sent without error
+var lastSuccessfulSendTime int64
+
+// LastSuccessfulSendTime returns the unix millis of the last successful send, or 0 if nothing has been sent yet
+func LastSuccessfulSendTime() int64 {
+	return atomic.LoadInt64(&lastSuccessfulSendTime)
+}
+
 // Sender is responsible for sending processed metrics to the server
 type Sender struct {
 	processedQueue          chan *model.ConversionResult
@@ -31,8 +56,63 @@ type Sender struct {
 	shutdownCh              chan struct{}
 	doneCh                  chan struct{}
 	lastSendTime            map[string]int64
+	lastMetadataTime        map[string]time.Time
+	lastMetadataPrune       time.Time
 	mu                      sync.Mutex
 	endpointMeteringEnabled bool
+
+	// packCh is the bounded in-flight buffer between pack construction and network sending
+	packCh      chan queuedPack
+	sendTimeout time.Duration
+	// maxAttempts, retryDelay and maxRetryDelay are the retry policy of a failed send
+	maxAttempts   int
+	retryDelay    time.Duration
+	maxRetryDelay time.Duration
+	// sendFunc performs the actual network send; replaced in tests
+	sendFunc func(p pack.Pack) error
+	// stuckSends counts timed-out sends whose goroutine has not returned yet
+	stuckSends int32
+	wg         sync.WaitGroup
+
+	// groupByMetric sorts records by metric name and labels before building packs
+	groupByMetric  bool
+	lastGroupStats time.Time
+
+	// draining is set when a pack could not be sent and cleared once the in-flight buffer is empty;
+	// the send phase offset is not applied meanwhile
+	draining atomic.Bool
+	// failedPacks counts the packs given up on, after a permanent failure or the last attempt
+	failedPacks atomic.Int64
+	// flushCh is closed by Flush on shutdown, after which packs are sent without the send phase offset
+	flushCh   chan struct{}
+	flushOnce sync.Once
+	// sending is set while the network loop holds a pack taken from the in-flight buffer
+	sending  atomic.Bool
+	stopOnce sync.Once
+	// Clock, OID and jitter sources of the send phase; replaced in tests
+	now        func() time.Time
+	after      func(d time.Duration) <-chan time.Time
+	oid        func() int32
+	randInt63n func(n int64) int64
+
+	// otlp forwards results to an OpenTelemetry collector as well; nil unless openagent_otlp_enabled
+	otlp *otlpSink
+	// router sends records to other projects by label; nil unless openagent_routes_file is set
+	router *router
+	// sendCallback is told the outcome of every pack; nil unless SetSendCallback was called
+	sendCallback func(p pack.Pack, err error)
+	// latency is the scrape-to-send latency histogram of the sent packs
+	latency *pipelineLatency
+}
+
+// queuedPack is a pack in the in-flight buffer with the time it was queued
+type queuedPack struct {
+	pack.Pack
+	queuedAt time.Time
+	// route is the routing destination, nil without routing
+	route *route
+	// scrapeStart is the unix millis the records' scrape started, 0 for packs not built from a scrape
+	scrapeStart int64
 }
 
 // NewSender creates a new Sender instance
@@ -42,14 +122,52 @@ func NewSender(processedQueue chan *model.ConversionResult, logger *logfile.File
 		logger = logfile.NewFileLogger()
 	}
 
-	return &Sender{
+	sendTimeout := time.Duration(config.GetIntWithDefault("openagent_send_timeout_ms", int(DefaultSendTimeout/time.Millisecond))) * time.Millisecond
+	if sendTimeout <= 0 {
+		sendTimeout = DefaultSendTimeout
+	}
+
+	s := &Sender{
 		processedQueue:          processedQueue,
 		logger:                  logger,
 		shutdownCh:              make(chan struct{}),
 		doneCh:                  make(chan struct{}),
+		flushCh:                 make(chan struct{}),
 		lastSendTime:            make(map[string]int64),
+		lastMetadataTime:        make(map[string]time.Time),
 		endpointMeteringEnabled: endpointMeteringEnabled,
+		packCh:                  make(chan queuedPack, InFlightBufferSize),
+		sendTimeout:             sendTimeout,
+		maxAttempts:             sendMaxAttempts(),
+		retryDelay:              sendRetryDelay(),
+		maxRetryDelay:           sendMaxRetryDelay(),
+		groupByMetric:           config.GetBoolWithDefault("openagent_sender_group_by_metric", false),
+		now:                     time.Now,
+		after:                   time.After,
+		oid:                     securityMasterOID,
+		randInt63n:              defaultRandInt63n,
+		latency:                 newPipelineLatency(),
 	}
+	s.sendFunc = s.sendToServer
+
+	s.router = loadRouter(logger)
+
+	if settings, enabled := loadOTLPSettings(); enabled {
+		sink, err := newOTLPSink(settings, logger)
+		if err != nil {
+			logger.Println("SenderOTLP", fmt.Sprintf("OTLP export to %s disabled: %v", settings.endpoint, err))
+		} else {
+			s.otlp = sink
+			logger.Println("SenderOTLP", fmt.Sprintf("Exporting metrics to %s over OTLP/gRPC", settings.endpoint))
+		}
+	}
+	return s
+}
+
+// SetSendCallback sets a function called from the network loop with every pack once it was sent, with a
+// nil error, or given up on, with the last error. It must be set before Start.
+func (s *Sender) SetSendCallback(callback func(p pack.Pack, err error)) {
+	s.sendCallback = callback
 }
 
 // Start starts the sender
@@ -60,39 +178,169 @@ func (s *Sender) Start() {
 		s.logger = logfile.NewFileLogger()
 	}
 
-	go s.sendLoop()
+	s.wg.Add(2)
+	go diagnostics.Profile(diagnostics.ComponentSender, "", s.sendLoop)
+	go diagnostics.Profile(diagnostics.ComponentSender, "", s.networkLoop)
+	if s.otlp != nil {
+		s.wg.Add(1)
+		go s.otlp.run(s.shutdownCh, &s.wg)
+	}
+	go func() {
+		s.wg.Wait()
+		close(s.doneCh)
+	}()
 }
 
-// Stop gracefully stops the sender
+// Stop gracefully stops the sender. Packs still buffered are dropped, call Flush and wait for Pending first to send them.
 func (s *Sender) Stop() {
-	close(s.shutdownCh)
+	s.stopOnce.Do(func() { close(s.shutdownCh) })
 	<-s.doneCh
 }
 
-// sendLoop continuously sends processed data from the queue
+// Flush sends the buffered packs as fast as possible, without waiting for the send phase offset
+func (s *Sender) Flush() {
+	s.flushOnce.Do(func() { close(s.flushCh) })
+}
+
+// Pending returns the number of packs built but not sent yet
+func (s *Sender) Pending() int {
+	pending := len(s.packCh)
+	if s.sending.Load() {
+		pending++
+	}
+	return pending
+}
+
+// sendLoop continuously builds packs from the processed queue and hands them to the network loop
 func (s *Sender) sendLoop() {
 	defer func() {
 		if r := recover(); r != nil {
 			s.logger.Println("SenderPanic", fmt.Sprintf("Recovered from panic: %v", r))
 		}
-		close(s.doneCh)
+		s.wg.Done()
 	}()
 
+	// Per-route counters are sent through the processed queue like other self-metrics
+	var routeMetrics <-chan time.Time
+	if s.router != nil {
+		ticker := time.NewTicker(RouteMetricsInterval)
+		defer ticker.Stop()
+		routeMetrics = ticker.C
+	}
+	latencyTicker := time.NewTicker(PipelineLatencyInterval)
+	defer latencyTicker.Stop()
+	deadLetterTicker := time.NewTicker(DeadLetterMetricsInterval)
+	defer deadLetterTicker.Stop()
+
 	for {
 		select {
 		case <-s.shutdownCh:
 			s.logger.Println("Sender", "Shutdown requested, exiting send loop")
 			return
+		case <-routeMetrics:
+			select {
+			case s.processedQueue <- s.router.metrics(time.Now().UnixMilli()):
+			default:
+				s.logger.Println("SenderRoute", "Processed queue is full, dropping route counters")
+			}
+		case <-latencyTicker.C:
+			select {
+			case s.processedQueue <- s.latency.metrics(time.Now().UnixMilli()):
+			default:
+				s.logger.Println("SenderLatency", "Processed queue is full, dropping the pipeline latency histogram")
+			}
+		case <-deadLetterTicker.C:
+			select {
+			case s.processedQueue <- deadLetterMetrics(time.Now().UnixMilli()):
+			default:
+				s.logger.Println("SenderRetry", "Processed queue is full, dropping the dead-letter counters")
+			}
 		case result, ok := <-s.processedQueue:
 			if !ok {
 				s.logger.Println("Sender", "Process queue closed, exiting send loop")
 				return
 			}
-			s.sendResult(result)
+			diagnostics.Profile(diagnostics.ComponentSender, result.GetTarget(), func() {
+				s.sendResult(result)
+			})
+			diagnostics.Beat(diagnostics.ComponentSender)
+		}
+	}
+}
+
+// networkLoop sends packs from the in-flight buffer to the server
+func (s *Sender) networkLoop() {
+	defer func() {
+		if r := recover(); r != nil {
+			s.logger.Println("SenderPanic", fmt.Sprintf("Recovered from panic in network loop: %v", r))
+		}
+		s.wg.Done()
+	}()
+
+	for {
+		select {
+		case <-s.shutdownCh:
+			s.logger.Println("Sender", "Shutdown requested, exiting network loop")
+			return
+		case queued := <-s.packCh:
+			s.sending.Store(true)
+			// Hold the pack until the agent's send offset, so the fleet does not send all at once
+			if !s.waitPhase(queued.queuedAt) {
+				return
+			}
+			s.sendQueued(queued)
+			s.sending.Store(false)
+			if len(s.packCh) == 0 {
+				s.draining.Store(false)
+			}
+			diagnostics.Beat(diagnostics.ComponentSender)
 		}
 	}
 }
 
+// sendQueued sends a pack from the in-flight buffer on its route's session and records its latency.
+// A pack is replayed when it needed a retry or was still buffered after an earlier pack failed.
+func (s *Sender) sendQueued(queued queuedPack) {
+	send := s.sendFunc
+	if queued.route != nil && queued.route.session != nil {
+		send = queued.route.session.Send
+	}
+	replayed := s.draining.Load()
+	sent, attempts := s.sendWithRetry(queued.Pack, send)
+	if sent {
+		s.recordLatency(queued, replayed || attempts > 1)
+	}
+	if queued.route == nil {
+		return
+	}
+	if sent {
+		queued.route.packs.Add(1)
+	} else {
+		queued.route.failures.Add(1)
+	}
+}
+
+// enqueue hands a pack to the network loop, blocking while the in-flight buffer is full
+func (s *Sender) enqueue(p pack.Pack) bool {
+	return s.enqueueTo(p, nil)
+}
+
+// enqueueTo is enqueue for a pack of a route
+func (s *Sender) enqueueTo(p pack.Pack, rt *route) bool {
+	return s.enqueueQueued(queuedPack{Pack: p, route: rt})
+}
+
+// enqueueQueued hands a pack to the network loop, stamping the time it was queued
+func (s *Sender) enqueueQueued(queued queuedPack) bool {
+	queued.queuedAt = s.now()
+	select {
+	case s.packCh <- queued:
+		return true
+	case <-s.shutdownCh:
+		return false
+	}
+}
+
 // sendResult sends a single conversion result
 func (s *Sender) sendResult(result *model.ConversionResult) {
 	// Log target and timestamp information
@@ -115,25 +363,41 @@ func (s *Sender) sendResult(result *model.ConversionResult) {
 
 	target := result.GetTarget()
 
+	// Forward to the OTLP collector before metadata is thinned out by the metadata interval
+	if s.otlp != nil {
+		s.otlp.offer(result)
+	}
+
 	if s.endpointMeteringEnabled {
 		endpoint.Register(target)
 	}
 
-	// Send OpenMxHelp data
+	// Send OpenMxHelp data once per metadata interval, unless disabled
 	openMxHelpList := result.GetOpenMxHelpList()
-	if len(openMxHelpList) > 0 {
+	if len(openMxHelpList) > 0 && s.metadataDue(target, time.Now()) {
 		s.sendHelp(openMxHelpList)
 	}
 
 	// Send OpenMx data
 	openMxList := result.GetOpenMxList()
 	if len(openMxList) > 0 {
-		s.sendMetrics(openMxList, target)
+		s.sendMetrics(openMxList, target, result.GetScrapeStart())
 	}
 }
 
-// sendHelp sends OpenMxHelp data in chunks
+// sendHelp sends OpenMxHelp data in chunks, to every project records can be routed to
 func (s *Sender) sendHelp(helpList []*model.OpenMxHelp) {
+	if s.router == nil {
+		s.sendHelpTo(nil, helpList)
+		return
+	}
+	for _, rt := range s.router.all() {
+		s.sendHelpTo(rt, helpList)
+	}
+}
+
+// sendHelpTo sends OpenMxHelp data in chunks on one route
+func (s *Sender) sendHelpTo(rt *route, helpList []*model.OpenMxHelp) {
 	total := len(helpList)
 	for i := 0; i < total; i += ChunkSize {
 		end := i + ChunkSize
@@ -144,14 +408,33 @@ func (s *Sender) sendHelp(helpList []*model.OpenMxHelp) {
 
 		s.logger.Println("Sender", fmt.Sprintf("Sending %d OpenMxHelp records", len(chunk)))
 
-		// Create a pack and send it
+		// Create a pack and queue it for sending
 		helpPack := createHelpPack(chunk)
-		s.sendToServerWithRetry(helpPack)
+		if !s.enqueueTo(helpPack, rt) {
+			return
+		}
+	}
+}
+
+// sendMetrics sends OpenMx data in chunks, split by route when routing is configured
+func (s *Sender) sendMetrics(metrics []*model.OpenMx, target string, scrapeStart int64) {
+	if s.router == nil {
+		s.sendMetricsTo(nil, metrics, target, scrapeStart)
+		return
+	}
+	for _, part := range s.router.partition(metrics) {
+		part.route.records.Add(int64(len(part.records)))
+		part.route.setPcode(part.records)
+		s.sendMetricsTo(part.route, part.records, target, scrapeStart)
 	}
 }
 
-// sendMetrics sends OpenMx data in chunks
-func (s *Sender) sendMetrics(metrics []*model.OpenMx, target string) {
+// sendMetricsTo sends OpenMx data in chunks on one route
+func (s *Sender) sendMetricsTo(rt *route, metrics []*model.OpenMx, target string, scrapeStart int64) {
+	if s.groupByMetric {
+		metrics = s.group(metrics)
+	}
+
 	total := len(metrics)
 	for i := 0; i < total; i += ChunkSize {
 		end := i + ChunkSize
@@ -162,10 +445,30 @@ func (s *Sender) sendMetrics(metrics []*model.OpenMx, target string) {
 
 		s.logger.Println("Sender", fmt.Sprintf("Sending %d OpenMx records", len(chunk)))
 
-		// Create a pack and send it
+		// Create a pack and queue it for sending
 		metricsPack := createMetricsPack(chunk, s.endpointMeteringEnabled, target)
-		s.sendToServerWithRetry(metricsPack)
+		if !s.enqueueQueued(queuedPack{Pack: metricsPack, route: rt, scrapeStart: scrapeStart}) {
+			return
+		}
+	}
+}
+
+// group sorts metrics by name and labels. Once per GroupStatsInterval the pack size is also
+// computed in scrape order and both sizes are logged.
+func (s *Sender) group(metrics []*model.OpenMx) []*model.OpenMx {
+	grouped := groupMetrics(metrics)
+
+	if time.Since(s.lastGroupStats) >= GroupStatsInterval {
+		s.lastGroupStats = time.Now()
+		before, after := packedSize(metrics), packedSize(grouped)
+		saved := 0.0
+		if before > 0 {
+			saved = float64(before-after) * 100 / float64(before)
+		}
+		s.logger.Println("SenderGroup", fmt.Sprintf("Grouping %d records by metric: %d bytes -> %d bytes (%.1f%% smaller)",
+			len(metrics), before, after, saved))
 	}
+	return grouped
 }
 
 // createHelpPack creates a pack of OpenMxHelp records for sending
@@ -191,23 +494,96 @@ func createMetricsPack(metrics []*model.OpenMx, endpointMeteringEnabled bool, ta
 
 // sendToServerWithRetry sends a pack to the server with retry logic
 func (s *Sender) sendToServerWithRetry(p pack.Pack) {
+	s.sendWithRetry(p, s.sendFunc)
+}
+
+// sendWithRetry sends a pack with send, retrying transient failures with exponential backoff, and
+// reports whether it was sent and after how many attempts. A pack that fails permanently or on every
+// attempt is counted as a dead letter and dropped; this agent has no disk buffer to keep it in.
+func (s *Sender) sendWithRetry(p pack.Pack, send func(p pack.Pack) error) (bool, int) {
 	var err error
 
-	for retry := 0; retry < MaxRetries; retry++ {
-		if retry > 0 {
-			s.logger.Println("SenderRetry", fmt.Sprintf("Retrying send (attempt %d/%d)", retry+1, MaxRetries))
-			time.Sleep(RetryDelay)
+	attempt := 0
+	for attempt < s.maxAttempts {
+		if attempt > 0 {
+			delay := backoff(attempt, s.retryDelay, s.maxRetryDelay)
+			s.logger.Println("SenderRetry", fmt.Sprintf("Retrying send in %v (attempt %d/%d)", delay, attempt+1, s.maxAttempts))
+			select {
+			case <-s.after(delay):
+			case <-s.shutdownCh:
+				return false, attempt
+			}
+			sendRetries.Add(1)
 		}
+		attempt++
 
-		err = s.sendToServer(p)
+		err = s.sendWithTimeoutFunc(p, send)
 		if err == nil {
-			return
+			atomic.StoreInt64(&lastSuccessfulSendTime, time.Now().UnixMilli())
+			RecordPackSent(p)
+			if s.sendCallback != nil {
+				s.sendCallback(p, nil)
+			}
+			return true, attempt
 		}
 
+		recordSendError(err, s.now())
 		s.logger.Println("SenderError", fmt.Sprintf("Error sending data: %v", err))
+		if IsPermanent(err) {
+			break
+		}
+	}
+
+	if IsPermanent(err) {
+		s.logger.Println("SenderFailed", fmt.Sprintf("Dropping pack after a permanent failure: %v", err))
+	} else {
+		s.logger.Println("SenderFailed", fmt.Sprintf("Failed to send data after %d attempts", attempt))
 	}
+	recordDeadLetter(err)
+	s.failedPacks.Add(1)
+	s.draining.Store(true)
+	if s.sendCallback != nil {
+		s.sendCallback(p, err)
+	}
+	return false, attempt
+}
+
+// sendWithTimeout runs sendFunc under a watchdog, since secure.Send does not take a context.
+// A send that does not return within sendTimeout is reported as ErrSendTimeout; its goroutine is
+// left to finish on its own.
+func (s *Sender) sendWithTimeout(p pack.Pack) error {
+	return s.sendWithTimeoutFunc(p, s.sendFunc)
+}
+
+// sendWithTimeoutFunc is sendWithTimeout with the send function of a route
+func (s *Sender) sendWithTimeoutFunc(p pack.Pack, send func(p pack.Pack) error) error {
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- Permanent(fmt.Errorf("panic during send: %v", r))
+			}
+		}()
+		done <- send(p)
+	}()
 
-	s.logger.Println("SenderFailed", fmt.Sprintf("Failed to send data after %d attempts", MaxRetries))
+	timer := time.NewTimer(s.sendTimeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		stuck := atomic.AddInt32(&s.stuckSends, 1)
+		go func() {
+			<-done
+			atomic.AddInt32(&s.stuckSends, -1)
+		}()
+		s.logger.Println("SenderTimeout", fmt.Sprintf("Send did not complete within %v (%d sends still blocked)", s.sendTimeout, stuck))
+		return ErrSendTimeout
+	case <-s.shutdownCh:
+		return errSenderStopped
+	}
 }
 
 // sendToServer sends a pack to the server
@@ -227,8 +603,6 @@ func (s *Sender) sendToServer(p pack.Pack) error {
 	// Set the time to the current time
 	p.SetTime(time.Now().UnixMilli())
 
-	// Send the pack to the server using secure.Send
-	secure.Send(secure.NET_SECURE_HIDE, p, true)
-
-	return nil
+	// Queue the pack on the secure session; a full queue is reported so the pack is retried
+	return secure.TrySend(secure.NET_SECURE_HIDE, p, true)
 }
@@ -0,0 +1,58 @@
+package snapshot
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"open-agent/pkg/diagnostics"
+	"open-agent/pkg/scraper"
+)
+
+// crashErrors is reused by WriteCrashDiagnostics so the error ring is read without allocating
+var crashErrors [diagnostics.ErrorRingSize]diagnostics.ScrapeError
+
+// WriteCrashDiagnostics writes the diagnostics section of a crash dump: recent scrape errors,
+// queue lengths, component heartbeat ages and the active and unscheduled target counts.
+// It does not wait for component locks, since the component holding one may be the one that hung.
+func WriteCrashDiagnostics(w io.Writer, src Sources, now time.Time) {
+	fmt.Fprintf(w, "\n# diagnostics %s\n", now.Format(time.RFC3339))
+
+	fmt.Fprintf(w, "\n## heartbeats\n")
+	for _, c := range diagnostics.Components {
+		if age, ok := diagnostics.HeartbeatAge(c, now); ok {
+			fmt.Fprintf(w, "%s %v ago\n", c, age.Truncate(time.Millisecond))
+		} else {
+			fmt.Fprintf(w, "%s never\n", c)
+		}
+		if busy, done, ok := diagnostics.Busy(c, now); ok {
+			fmt.Fprintf(w, "%s busy %v (%d done)\n", c, busy.Truncate(time.Millisecond), done)
+		}
+	}
+
+	fmt.Fprintf(w, "\n## queues\n")
+	for _, q := range src.Queues {
+		fmt.Fprintf(w, "%s %d/%d\n", q.Name, q.Len(), q.Cap)
+	}
+
+	if src.Scraper == nil {
+		return
+	}
+
+	fmt.Fprintf(w, "\n## schedulers\n")
+	if count, ok := src.Scraper.ActiveSchedulerCount(); ok {
+		fmt.Fprintf(w, "active %d\n", count)
+	} else {
+		fmt.Fprintf(w, "active unknown (scheduler lock held)\n")
+	}
+	if unscheduled := scraper.UnscheduledTargets(); unscheduled > 0 {
+		fmt.Fprintf(w, "unscheduled %d (over openagent_max_schedulers)\n", unscheduled)
+	}
+
+	n := src.Scraper.ScrapeErrors().Snapshot(&crashErrors)
+	fmt.Fprintf(w, "\n## last scrape errors (%d)\n", n)
+	for i := 0; i < n; i++ {
+		e := &crashErrors[i]
+		fmt.Fprintf(w, "%s %s %s\n", e.Time.Format(time.RFC3339Nano), e.TargetID, e.Err)
+	}
+}
@@ -0,0 +1,217 @@
+package snapshot
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"open-agent/pkg/discovery"
+	"open-agent/pkg/processor"
+	"open-agent/pkg/scraper"
+)
+
+// Queue describes a channel whose length is reported in the snapshot. It is the queue gauge
+// the overload breaker watches, so one list of queues serves both.
+type Queue = scraper.QueueGauge
+
+// Sources are the components a snapshot is collected from. Nil sources are skipped.
+type Sources struct {
+	Discovery discovery.ServiceDiscovery
+	Scraper   *scraper.ScraperManager
+	Queues    []Queue
+}
+
+// QueueState is the length and capacity of a queue at snapshot time
+type QueueState struct {
+	Name string
+	Len  int
+	Cap  int
+}
+
+// Snapshot is a point-in-time view of discovery, scraper and runtime state
+type Snapshot struct {
+	Time       time.Time
+	Targets    []*discovery.Target
+	Schedulers []scraper.SchedulerState
+	Queues     []QueueState
+	Units      []processor.UnitConversionCount
+	Transforms []processor.ValueTransformCount
+	Labels     []processor.LabelLengthCount
+	NonFinite  []processor.NonFiniteCount
+	Goroutines int
+	HeapAlloc  uint64
+	HeapInuse  uint64
+	NumGC      uint32
+}
+
+// Collect gathers a snapshot without stopping any component
+func Collect(src Sources) *Snapshot {
+	s := &Snapshot{
+		Time:       time.Now(),
+		Goroutines: runtime.NumGoroutine(),
+	}
+
+	if src.Discovery != nil {
+		s.Targets = src.Discovery.GetAllTargets()
+	}
+	if src.Scraper != nil {
+		s.Schedulers = src.Scraper.GetSchedulerStates()
+	}
+	s.Units = processor.UnitConversionCounts()
+	s.Transforms = processor.ValueTransformCounts()
+	s.Labels = processor.LabelLengthCounts()
+	s.NonFinite = processor.NonFiniteCounts()
+	for _, q := range src.Queues {
+		s.Queues = append(s.Queues, QueueState{Name: q.Name, Len: q.Len(), Cap: q.Cap})
+	}
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	s.HeapAlloc = mem.HeapAlloc
+	s.HeapInuse = mem.HeapInuse
+	s.NumGC = mem.NumGC
+	return s
+}
+
+// Write formats the snapshot as human-readable text.
+// The same output is used for the SIGUSR1 dump file and the admin endpoint.
+func (s *Snapshot) Write(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "# open-agent state snapshot %s\n\n", s.Time.Format(time.RFC3339))
+
+	fmt.Fprintf(tw, "## runtime\n")
+	fmt.Fprintf(tw, "goroutines\t%d\n", s.Goroutines)
+	fmt.Fprintf(tw, "heap_alloc_bytes\t%d\n", s.HeapAlloc)
+	fmt.Fprintf(tw, "heap_inuse_bytes\t%d\n", s.HeapInuse)
+	fmt.Fprintf(tw, "num_gc\t%d\n\n", s.NumGC)
+
+	fmt.Fprintf(tw, "## queues\n")
+	fmt.Fprintf(tw, "NAME\tLEN\tCAP\n")
+	for _, q := range s.Queues {
+		fmt.Fprintf(tw, "%s\t%d\t%d\n", q.Name, q.Len, q.Cap)
+	}
+	fmt.Fprintf(tw, "\n")
+
+	fmt.Fprintf(tw, "## targets (%d)\n", len(s.Targets))
+	fmt.Fprintf(tw, "ID\tSTATE\tLAST_SEEN\tREADY_SINCE\tURL\tERROR\tSOURCES\tLABELS\n")
+	for _, t := range s.Targets {
+		readySince := "-"
+		if !t.ReadySince.IsZero() {
+			readySince = formatTime(t.ReadySince, s.Time)
+		}
+		invalidReason := t.InvalidReason
+		if invalidReason == "" {
+			invalidReason = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", t.ID, t.State, formatTime(t.LastSeen, s.Time), readySince, t.URL, invalidReason,
+			discovery.FormatSettingSources(t), formatLabels(t.Labels))
+	}
+	fmt.Fprintf(tw, "\n")
+
+	fmt.Fprintf(tw, "## schedulers (%d)\n", len(s.Schedulers))
+	fmt.Fprintf(tw, "TARGET\tINTERVAL\tTIMEOUT\tIN_PROGRESS\tLAST_SCRAPE\tDROPPED\tPAUSED_UNTIL\tDEFERRED_UNTIL\tLAST_ERROR\n")
+	for _, st := range s.Schedulers {
+		lastError := st.LastError
+		if lastError == "" {
+			lastError = "-"
+		}
+		pausedUntil := "-"
+		if !st.PausedUntil.IsZero() {
+			pausedUntil = fmt.Sprintf("%s (%d skipped)", st.PausedUntil.Format(time.RFC3339), st.PausedScrapes)
+		}
+		deferredUntil := "-"
+		if !st.DeferredUntil.IsZero() {
+			deferredUntil = fmt.Sprintf("%s (%d deferrals, %d skipped)", st.DeferredUntil.Format(time.RFC3339), st.Deferrals, st.DeferredScrapes)
+		}
+		fmt.Fprintf(tw, "%s\t%v\t%v\t%v\t%s\t%d\t%s\t%s\t%s\n", st.TargetID, st.Interval, st.Timeout, st.InProgress,
+			formatTime(st.LastScrape, s.Time), st.Dropped, pausedUntil, deferredUntil, lastError)
+	}
+
+	if len(s.Units) > 0 {
+		fmt.Fprintf(tw, "\n## unit conversions (%d)\n", len(s.Units))
+		fmt.Fprintf(tw, "TARGET\tCONVERTED\tSKIPPED\tCOLLISIONS\n")
+		for _, u := range s.Units {
+			collisions := strings.Join(u.Collisions, ",")
+			if collisions == "" {
+				collisions = "-"
+			}
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%s\n", u.Target, u.Converted, u.Skipped, collisions)
+		}
+	}
+
+	if len(s.Transforms) > 0 {
+		fmt.Fprintf(tw, "\n## value transforms (%d)\n", len(s.Transforms))
+		fmt.Fprintf(tw, "TARGET\tRULE\tTRANSFORMED\n")
+		for _, v := range s.Transforms {
+			fmt.Fprintf(tw, "%s\t%d: %s\t%d\n", v.Target, v.Index, v.Rule, v.Transformed)
+		}
+	}
+
+	if len(s.Labels) > 0 {
+		fmt.Fprintf(tw, "\n## label value length limits (%d)\n", len(s.Labels))
+		fmt.Fprintf(tw, "TARGET\tLIMIT\tTRUNCATED\tDROPPED\n")
+		for _, l := range s.Labels {
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%d\n", l.Target, l.Limit, l.Truncated, l.Dropped)
+		}
+	}
+
+	if len(s.NonFinite) > 0 {
+		fmt.Fprintf(tw, "\n## non-finite values (%d)\n", len(s.NonFinite))
+		fmt.Fprintf(tw, "TARGET\tPOLICY\tDROPPED\tZEROED\tPASSED\n")
+		for _, n := range s.NonFinite {
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\n", n.Target, n.Policy, n.Dropped, n.Zeroed, n.Passed)
+		}
+	}
+
+	return tw.Flush()
+}
+
+// WriteFile writes the snapshot to dir/state-YYYYMMDD-HHMMSS.txt and returns the file path
+func (s *Snapshot) WriteFile(dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("error creating snapshot directory: %v", err)
+	}
+	path := filepath.Join(dir, fmt.Sprintf("state-%s.txt", s.Time.Format("20060102-150405")))
+	f, err := os.Create(path)
+	if err != nil {
+		return "", fmt.Errorf("error creating snapshot file: %v", err)
+	}
+	defer f.Close()
+
+	if err := s.Write(f); err != nil {
+		return "", fmt.Errorf("error writing snapshot file: %v", err)
+	}
+	return path, nil
+}
+
+// formatTime prints a timestamp together with its age relative to now
+func formatTime(t time.Time, now time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return fmt.Sprintf("%s (%s ago)", t.Format(time.RFC3339), now.Sub(t).Truncate(time.Second))
+}
+
+// formatLabels prints labels sorted by name
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return "{}"
+	}
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", k, labels[k]))
+	}
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
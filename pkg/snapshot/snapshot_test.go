@@ -0,0 +1,70 @@
+package snapshot
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"open-agent/pkg/config"
+	"open-agent/pkg/discovery"
+)
+
+type fakeDiscovery struct {
+	targets []*discovery.Target
+}
+
+func (f *fakeDiscovery) LoadTargets(targets []config.TargetConfig) error { return nil }
+func (f *fakeDiscovery) Start(ctx context.Context) error                 { return nil }
+func (f *fakeDiscovery) GetReadyTargets() []*discovery.Target            { return f.targets }
+func (f *fakeDiscovery) GetAllTargets() []*discovery.Target              { return f.targets }
+func (f *fakeDiscovery) GetTarget(string) (*discovery.Target, bool)      { return nil, false }
+func (f *fakeDiscovery) Stop() error                                     { return nil }
+
+func TestCollectAndWrite(t *testing.T) {
+	queue := make(chan int, 8)
+	queue <- 1
+	queue <- 2
+
+	src := Sources{
+		Discovery: &fakeDiscovery{targets: []*discovery.Target{
+			{ID: "app/default/pod-a/8080", URL: "http://10.0.0.1:8080/metrics", State: discovery.TargetStateReady,
+				LastSeen: time.Now(), Labels: map[string]string{"job": "app"}},
+			{ID: "app/default/pod-b/8080", URL: "http://10.0.0.2:8080/metrics", State: discovery.TargetStatePending},
+		}},
+		Queues: []Queue{{Name: "rawQueue", Len: func() int { return len(queue) }, Cap: cap(queue)}},
+	}
+
+	snap := Collect(src)
+	if len(snap.Targets) != 2 || len(snap.Queues) != 1 || snap.Queues[0].Len != 2 {
+		t.Fatalf("unexpected snapshot: %+v", snap)
+	}
+	if snap.Goroutines <= 0 {
+		t.Errorf("expected goroutine count to be collected")
+	}
+
+	var buf bytes.Buffer
+	if err := snap.Write(&buf); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{"## targets (2)", "pod-a", "pending", "never", `job="app"`, "rawQueue", "## schedulers (0)"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected output to contain %q\n%s", want, out)
+		}
+	}
+
+	path, err := snap.WriteFile(t.TempDir())
+	if err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read file: %v", err)
+	}
+	if string(data) != out {
+		t.Errorf("file content differs from Write output")
+	}
+}
@@ -0,0 +1,261 @@
+package status
+
+import (
+	"math"
+	"strings"
+	"time"
+
+	"github.com/whatap/gointernal/net/secure"
+	"github.com/whatap/golib/lang/pack"
+	"github.com/whatap/golib/lang/value"
+
+	"open-agent/pkg/buildinfo"
+	"open-agent/pkg/discovery"
+	"open-agent/pkg/snapshot"
+	"open-agent/tools/util/logutil"
+)
+
+const (
+	// Interval is how often the agent status pack is sent
+	Interval = time.Minute
+	// Category is the TagCountPack category of the agent status pack, kept apart from metric data
+	Category = "open_agent_status"
+	// otypeIntegrations matches the otype tag of the common_agent_info pack
+	otypeIntegrations = 0x0016
+)
+
+// TargetStats is implemented by service discovery
+type TargetStats interface {
+	TargetStateCounts() map[discovery.TargetState]int
+}
+
+// ScrapeStats is implemented by the scraper manager
+type ScrapeStats interface {
+	ScrapeTotals() (scrapes, failures, bytes int64)
+}
+
+// ProcessorStats is implemented by the processor
+type ProcessorStats interface {
+	ProcessTotals() (samples, failures int64)
+}
+
+// SenderStats is implemented by the sender
+type SenderStats interface {
+	SendTotals() (packs, failures int64)
+}
+
+// LatencyStats is implemented by the sender
+type LatencyStats interface {
+	PipelineLatencyTotals(stage string) (count int64, sum time.Duration, overSLO int64)
+}
+
+// latencyStages are the sender's pipeline latency stages, reported as e.g. pipelineLatencyLiveAvgMs
+var latencyStages = []string{"live", "replayed"}
+
+// ConfigStats is implemented by the configuration manager
+type ConfigStats interface {
+	ConfigGeneration() int64
+}
+
+// Sources are the components the status pack is assembled from. Nil sources are skipped.
+type Sources struct {
+	Targets   TargetStats
+	Scraper   ScrapeStats
+	Processor ProcessorStats
+	Sender    SenderStats
+	Latency   LatencyStats
+	Config    ConfigStats
+	Queues    []snapshot.Queue
+}
+
+// reportedStates are the target states always present in the pack, so the dashboard sees 0 instead of a gap
+var reportedStates = []discovery.TargetState{
+	discovery.TargetStateReady,
+	discovery.TargetStatePending,
+	discovery.TargetStateWarming,
+	discovery.TargetStateError,
+	discovery.TargetStateInvalid,
+}
+
+// totals are the cumulative counters the per-minute figures are computed from
+type totals struct {
+	scrapes, scrapeFailures, scrapeBytes int64
+	samples, conversionFailures          int64
+	packs, sendFailures                  int64
+	latency                              map[string]latencyTotals
+}
+
+// latencyTotals are the cumulative pipeline latency figures of one stage
+type latencyTotals struct {
+	count, overSLO int64
+	sum            time.Duration
+}
+
+// StatusReporter sends one status pack per agent every Interval with the target states, per-minute
+// throughput, queue depths, configuration generation and error counts of the pipeline
+type StatusReporter struct {
+	src  Sources
+	send func(p *pack.TagCountPack)
+
+	last     totals
+	lastTime time.Time
+}
+
+// NewStatusReporter creates a reporter. Throughput in the first pack is measured from this call.
+func NewStatusReporter(src Sources) *StatusReporter {
+	r := &StatusReporter{src: src, send: sendPack}
+	r.last = r.collectTotals()
+	r.lastTime = time.Now()
+	return r
+}
+
+// Run sends the status pack every Interval, aligned to the minute, until stop is closed
+func (r *StatusReporter) Run(stop <-chan struct{}) {
+	for {
+		now := time.Now()
+		select {
+		case <-time.After(now.Truncate(Interval).Add(Interval).Sub(now)):
+			r.report(time.Now())
+		case <-stop:
+			return
+		}
+	}
+}
+
+// report builds and sends one status pack
+func (r *StatusReporter) report(now time.Time) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			logutil.Errorln("StatusReporter", "Recovered from panic:", rec)
+		}
+	}()
+	r.send(r.buildPack(now))
+}
+
+func (r *StatusReporter) collectTotals() totals {
+	var t totals
+	if r.src.Scraper != nil {
+		t.scrapes, t.scrapeFailures, t.scrapeBytes = r.src.Scraper.ScrapeTotals()
+	}
+	if r.src.Processor != nil {
+		t.samples, t.conversionFailures = r.src.Processor.ProcessTotals()
+	}
+	if r.src.Sender != nil {
+		t.packs, t.sendFailures = r.src.Sender.SendTotals()
+	}
+	if r.src.Latency != nil {
+		t.latency = make(map[string]latencyTotals, len(latencyStages))
+		for _, stage := range latencyStages {
+			var lt latencyTotals
+			lt.count, lt.sum, lt.overSLO = r.src.Latency.PipelineLatencyTotals(stage)
+			t.latency[stage] = lt
+		}
+	}
+	return t
+}
+
+// buildPack assembles the status pack and moves the throughput window to now
+func (r *StatusReporter) buildPack(now time.Time) *pack.TagCountPack {
+	p := pack.NewTagCountPack()
+	p.Time = now.UnixMilli()
+	p.Category = Category
+	p.Tags.Put("otype", value.NewDecimalValue(int64(otypeIntegrations)))
+
+	p.Put("version", buildinfo.Version())
+
+	// Targets by state
+	if r.src.Targets != nil {
+		counts := r.src.Targets.TargetStateCounts()
+		total := 0
+		for _, n := range counts {
+			total += n
+		}
+		p.Put("targets", int64(total))
+		for _, state := range reportedStates {
+			p.Put(stateField(state), int64(counts[state]))
+		}
+	}
+
+	// Throughput per minute and errors since the previous pack
+	current := r.collectTotals()
+	elapsed := now.Sub(r.lastTime)
+	if r.src.Scraper != nil {
+		p.Put("scrapesPerMin", perMinute(current.scrapes-r.last.scrapes, elapsed))
+		p.Put("scrapeBytesPerMin", perMinute(current.scrapeBytes-r.last.scrapeBytes, elapsed))
+		p.Put("scrapeErrors", nonNegative(current.scrapeFailures-r.last.scrapeFailures))
+	}
+	if r.src.Processor != nil {
+		p.Put("samplesPerMin", perMinute(current.samples-r.last.samples, elapsed))
+		p.Put("conversionErrors", nonNegative(current.conversionFailures-r.last.conversionFailures))
+	}
+	if r.src.Sender != nil {
+		p.Put("packsPerMin", perMinute(current.packs-r.last.packs, elapsed))
+		p.Put("sendErrors", nonNegative(current.sendFailures-r.last.sendFailures))
+	}
+	// Scrape-to-send latency of the packs sent since the previous pack, by stage
+	for _, stage := range latencyStages {
+		cur, ok := current.latency[stage]
+		if !ok {
+			continue
+		}
+		prev := r.last.latency[stage]
+		prefix := "pipelineLatency" + strings.ToUpper(stage[:1]) + stage[1:]
+		var avg int64
+		if count := cur.count - prev.count; count > 0 {
+			avg = (cur.sum - prev.sum).Milliseconds() / count
+		}
+		p.Put(prefix+"AvgMs", nonNegative(avg))
+		p.Put(prefix+"OverSLO", nonNegative(cur.overSLO-prev.overSLO))
+	}
+	r.last = current
+	r.lastTime = now
+
+	// Queue depths
+	for _, q := range r.src.Queues {
+		p.Put(q.Name+"Len", int64(q.Len()))
+		p.Put(q.Name+"Cap", int64(q.Cap))
+	}
+
+	if r.src.Config != nil {
+		p.Put("configGeneration", r.src.Config.ConfigGeneration())
+	}
+	return p
+}
+
+// stateField returns the pack field of a target state, e.g. targetsReady
+func stateField(state discovery.TargetState) string {
+	s := string(state)
+	if s == "" {
+		return "targetsUnknown"
+	}
+	return "targets" + strings.ToUpper(s[:1]) + s[1:]
+}
+
+// perMinute scales a counter delta over elapsed to a per-minute rate
+func perMinute(delta int64, elapsed time.Duration) int64 {
+	if delta <= 0 || elapsed <= 0 {
+		return 0
+	}
+	return int64(math.Round(float64(delta) * float64(time.Minute) / float64(elapsed)))
+}
+
+// nonNegative clamps deltas of counters that were reset, e.g. by a component restart
+func nonNegative(delta int64) int64 {
+	if delta < 0 {
+		return 0
+	}
+	return delta
+}
+
+// sendPack fills in the agent identity and sends the pack, skipping it until the agent is registered
+func sendPack(p *pack.TagCountPack) {
+	secu := secure.GetSecurityMaster()
+	if secu == nil || secu.PCODE == 0 || secu.OID == 0 {
+		return
+	}
+	p.Pcode = secu.PCODE
+	p.Oid = secu.OID
+	p.Okind = secu.OKIND
+	p.Onode = secu.ONODE
+	secure.Send(secure.NET_SECURE_HIDE, p, true)
+}
@@ -0,0 +1,152 @@
+package status
+
+import (
+	"testing"
+	"time"
+
+	"github.com/whatap/golib/lang/pack"
+
+	"open-agent/pkg/discovery"
+	"open-agent/pkg/snapshot"
+)
+
+type fakeTargets map[discovery.TargetState]int
+
+func (f fakeTargets) TargetStateCounts() map[discovery.TargetState]int { return f }
+
+type fakeScraper struct{ scrapes, failures, bytes int64 }
+
+func (f *fakeScraper) ScrapeTotals() (int64, int64, int64) { return f.scrapes, f.failures, f.bytes }
+
+type fakeProcessor struct{ samples, failures int64 }
+
+func (f *fakeProcessor) ProcessTotals() (int64, int64) { return f.samples, f.failures }
+
+type fakeSender struct{ packs, failures int64 }
+
+func (f *fakeSender) SendTotals() (int64, int64) { return f.packs, f.failures }
+
+type fakeLatency map[string][3]int64
+
+func (f fakeLatency) PipelineLatencyTotals(stage string) (int64, time.Duration, int64) {
+	totals := f[stage]
+	return totals[0], time.Duration(totals[1]) * time.Millisecond, totals[2]
+}
+
+type fakeConfig int64
+
+func (f fakeConfig) ConfigGeneration() int64 { return int64(f) }
+
+func TestStatusPackContents(t *testing.T) {
+	scr := &fakeScraper{scrapes: 100, failures: 2, bytes: 1000}
+	proc := &fakeProcessor{samples: 5000, failures: 1}
+	snd := &fakeSender{packs: 40, failures: 0}
+	var sent []*pack.TagCountPack
+
+	r := NewStatusReporter(Sources{
+		Targets:   fakeTargets{discovery.TargetStateReady: 3, discovery.TargetStatePending: 1},
+		Scraper:   scr,
+		Processor: proc,
+		Sender:    snd,
+		Config:    fakeConfig(4),
+		Queues: []snapshot.Queue{
+			{Name: "rawQueue", Len: func() int { return 7 }, Cap: 100},
+		},
+	})
+	r.send = func(p *pack.TagCountPack) { sent = append(sent, p) }
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	r.lastTime = start
+
+	// Two minutes of activity: the figures are per minute
+	scr.scrapes, scr.failures, scr.bytes = 160, 5, 201000
+	proc.samples, proc.failures = 25000, 1
+	snd.packs, snd.failures = 80, 2
+	r.report(start.Add(2 * time.Minute))
+
+	if len(sent) != 1 {
+		t.Fatalf("expected 1 pack, got %d", len(sent))
+	}
+	p := sent[0]
+	if p.Category != Category {
+		t.Errorf("Category = %q, want %q", p.Category, Category)
+	}
+	if p.Time != start.Add(2*time.Minute).UnixMilli() {
+		t.Errorf("unexpected pack time %d", p.Time)
+	}
+	want := map[string]int64{
+		"targets":           4,
+		"targetsReady":      3,
+		"targetsPending":    1,
+		"targetsWarming":    0,
+		"targetsError":      0,
+		"scrapesPerMin":     30,
+		"scrapeBytesPerMin": 100000,
+		"scrapeErrors":      3,
+		"samplesPerMin":     10000,
+		"conversionErrors":  0,
+		"packsPerMin":       20,
+		"sendErrors":        2,
+		"rawQueueLen":       7,
+		"rawQueueCap":       100,
+		"configGeneration":  4,
+	}
+	for field, v := range want {
+		if p.Get(field) == nil {
+			t.Errorf("field %s missing", field)
+			continue
+		}
+		if got := p.GetLong(field); got != v {
+			t.Errorf("%s = %d, want %d", field, got, v)
+		}
+	}
+
+	// The next pack covers only the activity since the previous one
+	scr.scrapes = 190
+	r.report(start.Add(3 * time.Minute))
+	if got := sent[1].GetLong("scrapesPerMin"); got != 30 {
+		t.Errorf("scrapesPerMin = %d, want 30", got)
+	}
+	if got := sent[1].GetLong("scrapeErrors"); got != 0 {
+		t.Errorf("scrapeErrors = %d, want 0", got)
+	}
+}
+
+func TestStatusPackPipelineLatency(t *testing.T) {
+	latency := fakeLatency{"live": {10, 50000, 0}, "replayed": {2, 130000, 1}}
+	r := NewStatusReporter(Sources{Latency: latency})
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// One minute later: 4 live packs took 8s in total, one replayed pack took 90s
+	latency["live"] = [3]int64{14, 58000, 0}
+	latency["replayed"] = [3]int64{3, 220000, 2}
+	p := r.buildPack(start.Add(time.Minute))
+	for field, v := range map[string]int64{
+		"pipelineLatencyLiveAvgMs":       2000,
+		"pipelineLatencyLiveOverSLO":     0,
+		"pipelineLatencyReplayedAvgMs":   90000,
+		"pipelineLatencyReplayedOverSLO": 1,
+	} {
+		if got := p.GetLong(field); p.Get(field) == nil || got != v {
+			t.Errorf("%s = %d, want %d", field, got, v)
+		}
+	}
+
+	// Without sends meanwhile the average is 0 rather than carried over
+	p = r.buildPack(start.Add(2 * time.Minute))
+	if got := p.GetLong("pipelineLatencyLiveAvgMs"); got != 0 {
+		t.Errorf("pipelineLatencyLiveAvgMs = %d without sends, want 0", got)
+	}
+}
+
+func TestStatusPackSkipsMissingSources(t *testing.T) {
+	r := NewStatusReporter(Sources{Config: fakeConfig(1)})
+	p := r.buildPack(time.Now())
+	for _, field := range []string{"targets", "scrapesPerMin", "samplesPerMin", "packsPerMin"} {
+		if p.Get(field) != nil {
+			t.Errorf("expected no %s field without its source", field)
+		}
+	}
+	if p.GetLong("configGeneration") != 1 {
+		t.Errorf("expected the config generation")
+	}
+}
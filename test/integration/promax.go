@@ -2,11 +2,11 @@ package main
 
 import (
 	"fmt"
-	"github.com/whatap/gointernal/net/secure"
 	"github.com/whatap/golib/logger/logfile"
-	"github.com/whatap/golib/util/dateutil"
 	"math/rand"
+	"net/http"
 	"open-agent/pkg/model"
+	"open-agent/pkg/samplegen"
 	"os"
 	"strings"
 	"time"
@@ -15,9 +15,6 @@ import (
 // Map to store the last value for each metric to calculate deltas
 var deltaMap = make(map[string]int64)
 
-// Last time help information was sent
-var lastHelpSendTime int64 = 0
-
 // promaxLogMessage logs a message to both the file logger and stdout
 func promaxLogMessage(logger *logfile.FileLogger, tag string, message string) {
 	logger.Println(tag, message)
@@ -26,17 +23,12 @@ func promaxLogMessage(logger *logfile.FileLogger, tag string, message string) {
 
 func main() {
 	// Check if environment variables are set
-	license := os.Getenv("WHATAP_LICENSE")
-	host := os.Getenv("WHATAP_HOST")
-	port := os.Getenv("WHATAP_PORT")
-	license = "x41pl22ek7jhv-z43cebasdv4il7-z62p3l35fj5502"
-	host = "15.165.146.117"
-	port = "6600"
-	if license == "" || host == "" || port == "" {
+	url := os.Getenv("OPENAGENT_INGEST_URL")
+	token := os.Getenv("OPENAGENT_INGEST_TOKEN")
+	if url == "" || token == "" {
 		fmt.Println("Please set the following environment variables:")
-		fmt.Println("WHATAP_LICENSE - The license key for the WHATAP server")
-		fmt.Println("WHATAP_HOST - The hostname or IP address of the WHATAP server")
-		fmt.Println("WHATAP_PORT - The port number of the WHATAP server")
+		fmt.Println("OPENAGENT_INGEST_URL - The ingest endpoint of a pipeline-only agent, e.g. http://127.0.0.1:6061/ingest")
+		fmt.Println("OPENAGENT_INGEST_TOKEN - The openagent_ingest_token of the agent")
 		os.Exit(1)
 	}
 
@@ -44,97 +36,28 @@ func main() {
 	logger := logfile.NewFileLogger()
 	promaxLogMessage(logger, "PromaX", "Starting PromaX sample sender")
 
-	// Initialize secure communication
-	servers := []string{fmt.Sprintf("%s:%s", host, port)}
-	secure.StartNet(secure.WithLogger(logger), secure.WithAccessKey(license), secure.WithServers(servers), secure.WithOname("test"))
-
 	// Initialize random number generator
 	rand.Seed(time.Now().UnixNano())
 
 	// Process metrics periodically
+	client := &http.Client{Timeout: 10 * time.Second}
 	for {
-		process(logger)
+		process(logger, client, url, token)
 		time.Sleep(10 * time.Second)
 	}
 }
 
-// process creates and sends metrics and help information
-func process(logger *logfile.FileLogger) {
+// process creates metrics and posts them to the ingest endpoint, which sends them with their help information
+func process(logger *logfile.FileLogger, client *http.Client, url, token string) {
 	// Create metrics
 	metrics := createMetrics()
 	promaxLogMessage(logger, "PromaX", fmt.Sprintf("Created %d metrics", len(metrics)))
 
-	// Create help information if needed
-	helpItems := make([]*model.OpenMxHelp, 0)
-	//startTime, _ := time.Parse("2006/01/02 15:04:05", "2025/07/11 10:45:00")
-	now := time.Now().UnixMilli()
-
-	// Send help information only once per minute
-	if now-lastHelpSendTime > 60*dateutil.MILLIS_PER_SECOND {
-		for _, mx := range metrics {
-			helpText := model.GetMetricHelp(mx.Metric)
-			metricType := model.GetMetricType(mx.Metric)
-
-			// Use default values if not found
-			if helpText == "" {
-				helpText = fmt.Sprintf("Help information for %s", mx.Metric)
-			}
-			if metricType == "" {
-				metricType = "gauge"
-			}
-
-			mxh := model.NewOpenMxHelp(mx.Metric)
-			mxh.Put("help", helpText)
-			mxh.Put("type", metricType)
-			//fmt.Printf("mxh-Metric=%v\n", mxh.Metric)
-			helpItems = append(helpItems, mxh)
-		}
-		lastHelpSendTime = now
-	}
-
-	// Send help information if available
-	if len(helpItems) > 0 {
-		helpPack := model.NewOpenMxHelpPack()
-		securityMaster := secure.GetSecurityMaster()
-		if securityMaster == nil {
-			promaxLogMessage(logger, "PromaX", "No security master available")
-			return
-		}
-		// Set PCODE and OID
-		helpPack.SetPCODE(securityMaster.PCODE)
-		helpPack.SetOID(securityMaster.OID)
-		helpPack.SetTime(now)
-		helpPack.SetRecords(helpItems)
-		//testRe := helpPack.GetRecords()
-
-		//fmt.Printf("helpPack.GetRecords()=%v\n", testRe)
-		// Get the security master
-		promaxLogMessage(logger, "PromaX", fmt.Sprintf("Sending %d help records", len(helpItems)))
-		//for _, helpItem := range helpItems {
-		//fmt.Printf("helpItem.Metric=%v//help=%v//type=%v\n", helpItem.Metric, helpItem.Get("help"), helpItem.Get("type"))
-		//}
-		secure.Send(secure.NET_SECURE_HIDE, helpPack, true)
-		time.Sleep(1000 * time.Millisecond)
-	}
-
-	//Send metrics
-	metricsPack := model.NewOpenMxPack()
-	metricsPack.SetTime(now)
-	metricsPack.SetRecords(metrics)
-
-	// Get the security master
-	securityMaster := secure.GetSecurityMaster()
-	if securityMaster == nil {
-		promaxLogMessage(logger, "PromaX", "No security master available")
+	if err := samplegen.Post(client, url, token, "promax", nil, samplegen.Exposition(metrics)); err != nil {
+		promaxLogMessage(logger, "PromaX", fmt.Sprintf("Failed to post metrics: %v", err))
 		return
 	}
-
-	//Set PCODE and OID
-	metricsPack.SetPCODE(securityMaster.PCODE)
-	metricsPack.SetOID(securityMaster.OID)
-
-	promaxLogMessage(logger, "PromaX", fmt.Sprintf("Sending %d metrics", len(metrics)))
-	secure.Send(secure.NET_SECURE_HIDE, metricsPack, true)
+	promaxLogMessage(logger, "PromaX", fmt.Sprintf("Posted %d metrics", len(metrics)))
 }
 
 // createMetrics creates sample metrics data
@@ -10,6 +10,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"io/ioutil"
@@ -48,6 +49,9 @@ type Logger struct {
 	//	static File logfile = null;
 
 	Level int
+
+	// trace enables per-object detail, e.g. one line per discovered pod, independently of Level
+	trace atomic.Bool
 }
 
 func NewLogger() *Logger {
@@ -399,6 +403,11 @@ func (this *Logger) clearOldLog() {
 			continue
 		}
 		name := f.Name()
+		// Crash dumps are removed with the logs of the same day
+		if date, ok := stackDumpDate(name); ok {
+			this.removeIfOld(searchDir, name, date, nowUnit)
+			continue
+		}
 		// prefix 구분
 		//fmt.Printf("file=%s", f.Name())
 		if !strings.HasPrefix(name, whatapPrefix+"-") {
@@ -432,24 +441,49 @@ func (this *Logger) clearOldLog() {
 			continue
 		}
 
-		func() {
-			defer func() {
-				if r := recover(); r != nil {
-					log.Println("WA10006", " File Delete Error", r)
-				}
-			}()
+		this.removeIfOld(searchDir, name, date, nowUnit)
+	}
+}
 
-			d := dateutil.GetYmdTime(date)
-			fileUnit := dateutil.GetDateUnit(d)
-			if nowUnit-fileUnit > int64(this.confLogKeepDays) {
-				//fmt.Println("File Remove", filepath.Join(searchDir,f.Name()))
-				err := os.Remove(filepath.Join(searchDir, f.Name()))
-				if err != nil {
-					log.Println("WA10007", " File Remove Error", err)
-				}
-			}
-		}()
+// removeIfOld removes a file dated YYYYMMDD that is older than the log keep days
+func (this *Logger) removeIfOld(dir, name, date string, nowUnit int64) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Println("WA10006", " File Delete Error", r)
+		}
+	}()
+
+	d := dateutil.GetYmdTime(date)
+	fileUnit := dateutil.GetDateUnit(d)
+	if nowUnit-fileUnit > int64(this.confLogKeepDays) {
+		//fmt.Println("File Remove", filepath.Join(dir, name))
+		err := os.Remove(filepath.Join(dir, name))
+		if err != nil {
+			log.Println("WA10007", " File Remove Error", err)
+		}
+	}
+}
+
+// Crash dumps written to the logs directory are named StackDumpPrefix + time + StackDumpSuffix
+const (
+	StackDumpPrefix = "stack-"
+	StackDumpSuffix = ".dump"
+)
+
+// stackDumpDate returns the YYYYMMDD date of a crash dump named stack-YYYYMMDD.dump or
+// stack-YYYYMMDD-HHMMSS.mmm.dump
+func stackDumpDate(name string) (string, bool) {
+	if !strings.HasPrefix(name, StackDumpPrefix) || !strings.HasSuffix(name, StackDumpSuffix) {
+		return "", false
+	}
+	date := strings.TrimSuffix(strings.TrimPrefix(name, StackDumpPrefix), StackDumpSuffix)
+	if len(date) < 8 {
+		return "", false
+	}
+	if _, err := strconv.Atoi(date[:8]); err != nil {
+		return "", false
 	}
+	return date[:8], true
 }
 
 func Info(id string, message string) {
@@ -473,6 +507,19 @@ func Debugf(id string, format string, v ...interface{}) {
 	logger.debug(id, fmt.Sprintf(format, v...))
 }
 
+// Tracef logs per-object detail that is too verbose even for debug, e.g. one line per discovered pod.
+// It is enabled with SetTrace, separately from the log level.
+func Tracef(id string, format string, v ...interface{}) {
+	if logger.trace.Load() {
+		logger.traceln(id, fmt.Sprintf(format, v...))
+	}
+}
+
+func (this *Logger) traceln(id string, message string) {
+	message = this.build(id, message)
+	this.printlnStd(message, false)
+}
+
 func (this *Logger) info(id string, message string) {
 	if this.Level <= LOG_LEVEL_INFO {
 		message = this.build(id, message)
@@ -504,6 +551,16 @@ func (this *Logger) SetLevel(lv int) {
 	this.Level = lv
 }
 
+// SetTrace enables or disables Tracef output
+func SetTrace(enabled bool) {
+	logger.trace.Store(enabled)
+}
+
+// IsTraceEnabled reports whether Tracef output is enabled
+func IsTraceEnabled() bool {
+	return logger.trace.Load()
+}
+
 // Errorf logs an error message, patterned after log.Printf.
 func (this *Logger) Errorf(format string, args ...interface{}) {
 	if this.Level <= LOG_LEVEL_ERROR {